@@ -0,0 +1,138 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The functions of this file are wm as a library, for programs that search
+// or open the log of a configuration without running the command line.
+// They report every failure as an error and never end the process.
+
+// LoadConfig reads the configuration file at path, or when path is empty the
+// one the command line would use: $WMCFG, a local wm.toml, or the user's.
+// Unlike the command line it never creates the file.  The root and extra
+// roots come back expanded.  Encryption, path_layout, and date_order go with
+// the configuration returned, so configurations loaded side by side don't
+// disturb each other, but date_locale, [date_keywords], day_start_hour,
+// month_filename, and the holidays apply to the process as a whole and are
+// those of the configuration loaded last.
+func LoadConfig(path string) (Configuration, error) {
+	src := configSource{Path: path}
+	if len(path) == 0 {
+		src = followConfigStub(findConfig(false))
+	}
+	if _, err := os.Stat(src.Path); err != nil {
+		return Configuration{}, fmt.Errorf("failed to read the configuration file: %w", err)
+	}
+	cfg, err := readConfig(src.Path)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Root = expandPath(cfg.Root)
+	for i, root := range cfg.ExtraRoots {
+		cfg.ExtraRoots[i] = expandPath(root)
+	}
+	resolveLocalRoot(&cfg, src)
+	return cfg, nil
+}
+
+// ParseDate reads a date the way the command line does: an ISO date, a
+// weekday, today or yesterday, a relative phrase such as "3 days ago", and
+// the other forms wm --help lists.
+func ParseDate(cfg Configuration, in string) (*DatePath, error) {
+	return parseDateString(cfg, in)
+}
+
+// ResolveEntryPath returns the path of the entry for pd under the root of
+// cfg, or in the extra root or archive that keeps it when the root doesn't.
+// The entry need not exist.
+func ResolveEntryPath(cfg Configuration, pd *DatePath) (string, error) {
+	path, err := topicEntryPath(cfg, pd, "")
+	if err != nil {
+		return "", err
+	}
+	return archivedEntry(cfg, pd, "", path), nil
+}
+
+// EnsureEntry returns the path of the entry for pd, first creating it from
+// the template that applies to it if it doesn't exist.  created reports
+// whether this call created it.  strict_create is not consulted; creating
+// the entry is what the caller asks for.
+func EnsureEntry(cfg Configuration, pd *DatePath) (path string, created bool, err error) {
+	path, err = ResolveEntryPath(cfg, pd)
+	if err != nil {
+		return "", false, err
+	}
+	return ensureEntryAt(cfg, path, pd, newTemplater(cfg, "", false).content)
+}
+
+// SearchOptions says what Search looks for and how, as the flags of the
+// search command do.
+type SearchOptions struct {
+	// Terms are regular expressions, or literal text with FixedStrings,
+	// of which every one must match an entry unless Any is set.
+	Terms []string
+	// From and To limit the search to entries of those dates, inclusive,
+	// as NewDatePath or ParseDate return them; either may be nil.
+	From, To *DatePath
+	// Topic limits the search to the entries of a topic.
+	Topic string
+
+	CaseSensitive  bool
+	SmartCase      bool
+	FixedStrings   bool
+	Word           bool
+	FoldDiacritics bool
+	Any            bool
+}
+
+// Search searches the entries of cfg, and its scratch notes when neither a
+// date range nor a topic is given, returning the hits by date.  Files that
+// can't be read are left out; if there are any, the hits of the others are
+// returned along with an error naming them.
+func Search(cfg Configuration, opts SearchOptions) ([]SearchHit, error) {
+	if len(opts.Terms) == 0 {
+		return nil, errors.New("nothing to search for; give a term")
+	}
+	m := termMode{
+		IgnoreCase: !opts.CaseSensitive,
+		SmartCase:  opts.SmartCase,
+		Fixed:      opts.FixedStrings,
+		Word:       opts.Word,
+		Fold:       opts.FoldDiacritics || cfg.SearchFoldDiacritics,
+	}
+	res, err := compileTerms(opts.Terms, m)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(cfg.Root); err != nil {
+		return nil, fmt.Errorf("the root can't be read: %w", err)
+	}
+	all, err := rootEntries(cfg, walkOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Topic) > 0 {
+		if err := checkTopic(opts.Topic); err != nil {
+			return nil, err
+		}
+		all = filterTopic(all, opts.Topic)
+	}
+	entries := skipOnlineOnly(filterEntries(all, opts.From, opts.To))
+	if len(opts.Topic) == 0 && opts.From == nil && opts.To == nil {
+		notes, err := listScratch(cfg)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, notes...)
+	}
+	q := searchTerms{Terms: opts.Terms, Res: res, Mode: m, Folds: termFolds(opts.Terms, m), Skip: newBoilerplate(cfg, Parameters{}), Any: opts.Any, Context: contextLines(cfg)}
+	results, failed := scanFiles(cfg, entries, q)
+	hits := collectHits(cfg, results)
+	if len(failed) > 0 {
+		return hits, fmt.Errorf("%d of %d files couldn't be read, the first %s: %w", len(failed), len(entries), failed[0].Entry.Path, failed[0].Err)
+	}
+	return hits, nil
+}
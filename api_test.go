@@ -0,0 +1,115 @@
+package wm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLibrary loads the configuration of a new root the way a program using
+// wm as a library would.
+func testLibrary(t *testing.T) Configuration {
+	t.Helper()
+	root := t.TempDir()
+	cfgFile, _ := testHome(t, root)
+	cfg, err := LoadConfig(cfgFile)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if filepath.FromSlash(cfg.Root) != root {
+		t.Fatalf("root = %s, want %s", cfg.Root, root)
+	}
+	return cfg
+}
+
+func TestLoadConfigMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wm.toml")
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("LoadConfig of a missing file succeeded, want an error")
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Error("LoadConfig created the missing configuration file")
+	}
+}
+
+func TestEnsureEntry(t *testing.T) {
+	testToday(t, 2024, time.March, 7)
+	cfg := testLibrary(t)
+	pd, err := ParseDate(cfg, "yesterday")
+	if err != nil || *pd != (DatePath{2024, 3, 6}) {
+		t.Fatalf("ParseDate(yesterday) = %v, %v, want 2024-03-06", pd, err)
+	}
+	want, err := ResolveEntryPath(cfg, pd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(want); err == nil {
+		t.Fatal("ResolveEntryPath created the entry")
+	}
+
+	path, created, err := EnsureEntry(cfg, pd)
+	if err != nil || !created || path != want {
+		t.Fatalf("EnsureEntry = %s, %v, %v, want %s created", path, created, err, want)
+	}
+	if err := os.WriteFile(path, []byte("kept\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path, created, err = EnsureEntry(cfg, pd)
+	if err != nil || created || path != want {
+		t.Fatalf("EnsureEntry again = %s, %v, %v, want %s found", path, created, err, want)
+	}
+	assertEntry(t, path, "kept\n")
+}
+
+func TestSearchLibrary(t *testing.T) {
+	cfg := testLibrary(t)
+	testEntries(t, cfg.Root, DatePath{2024, 3, 4}, DatePath{2024, 3, 5})
+	hits, err := Search(cfg, SearchOptions{Terms: []string{"03-05"}, FixedStrings: true})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 {
+		t.Fatalf("Search = %d hits, want 1: %+v", len(hits), hits)
+	}
+	h := hits[0]
+	if h.Date != "2024-03-05" || h.Term != "03-05" || !h.Fixed || h.Line != 1 || !strings.Contains(h.Context, "2024-03-05") {
+		t.Errorf("hit = %+v, want the only line of 2024-03-05", h)
+	}
+
+	from := NewDatePath(2024, time.March, 5)
+	hits, err = Search(cfg, SearchOptions{Terms: []string{"2024"}, From: &from})
+	if err != nil || len(hits) != 1 || hits[0].Date != "2024-03-05" {
+		t.Errorf("Search from 2024-03-05 = %+v, %v, want the one entry", hits, err)
+	}
+	if _, err := Search(cfg, SearchOptions{}); err == nil {
+		t.Error("Search without terms succeeded, want an error")
+	}
+	if _, err := Search(cfg, SearchOptions{Terms: []string{"("}}); err == nil {
+		t.Error("Search for an invalid pattern succeeded, want an error")
+	}
+}
+
+func TestNewDatePath(t *testing.T) {
+	pd := NewDatePath(2024, time.February, 30)
+	if pd.Year() != 2024 || pd.Month() != time.March || pd.Day() != 1 {
+		t.Errorf("NewDatePath(2024, February, 30) = %d-%d-%d, want 2024-3-1", pd.Year(), pd.Month(), pd.Day())
+	}
+	if pd.Iso() != "2024-03-01" {
+		t.Errorf("Iso = %s, want 2024-03-01", pd.Iso())
+	}
+}
+
+func TestParseDateOrderPerConfig(t *testing.T) {
+	us, eu := Configuration{DateOrder: "mdy"}, Configuration{DateOrder: "dmy"}
+	for _, tt := range []struct {
+		cfg  Configuration
+		want DatePath
+	}{{us, DatePath{2024, 3, 4}}, {eu, DatePath{2024, 4, 3}}, {us, DatePath{2024, 3, 4}}} {
+		pd, err := ParseDate(tt.cfg, "3/4/2024")
+		if err != nil || *pd != tt.want {
+			t.Errorf("ParseDate(%s, 3/4/2024) = %v, %v, want %s", tt.cfg.DateOrder, pd, err, tt.want.Iso())
+		}
+	}
+}
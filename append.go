@@ -0,0 +1,213 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// daySelector reports whether a day is one --each asks for.
+type daySelector func(t time.Time) bool
+
+// parseEach reads an --each selector: "day" for every day, "weekday" for
+// Monday to Friday, "workday" for weekdays that aren't holidays, or a comma
+// separated list of weekday names such as "monday,thu".
+func parseEach(each string) (daySelector, error) {
+	switch strings.ToLower(strings.TrimSpace(each)) {
+	case "", "day":
+		return func(time.Time) bool { return true }, nil
+	case "weekday":
+		return func(t time.Time) bool { return !isWeekend(t) }, nil
+	case "workday":
+		return func(t time.Time) bool { return !isWeekend(t) && !isHoliday(t) }, nil
+	}
+	days := map[time.Weekday]bool{}
+	for _, name := range strings.Split(each, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		found := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			full := strings.ToLower(d.String())
+			if name == full || (len(name) >= 3 && strings.HasPrefix(full, name)) {
+				days[d], found = true, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("bad --each '%s', expected day, weekday, workday, or weekday names", each)
+		}
+	}
+	return func(t time.Time) bool { return days[t.Weekday()] }, nil
+}
+
+// hasLine reports whether data has a line equal to line, ignoring
+// surrounding whitespace.
+func hasLine(data []byte, line string) bool {
+	line = strings.TrimSpace(line)
+	for _, l := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+	return false
+}
+
+// appendText returns the text to append: the words given or, without any,
+// the non-blank lines read from stdin.
+func appendText(params Parameters) (string, error) {
+	if len(params.Text) > 0 {
+		return strings.TrimRight(strings.Join(params.Text, " "), "\n"), nil
+	}
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("nothing to append; give the text or pipe it in")
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, l := range strings.Split(string(data), "\n") {
+		if l = strings.TrimRight(l, "\r"); len(strings.TrimSpace(l)) > 0 {
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// stampLines puts the time in front of every line of text.
+func stampLines(text string, t time.Time) string {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		lines[i] = "[" + t.Format("15:04") + "] " + l
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runAppend appends a line of text to today's entry, the one --date gives,
+// or, with range flags, every day in the range that --each selects, creating
+// the entries that don't exist yet from their templates.  Lines appended to
+// today's entry alone start with the time, and with timed_sections go under
+// the heading of the part of the day it is.
+func runAppend(cfg Configuration, params Parameters) error {
+	text, err := appendText(params)
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(text)) == 0 {
+		return errors.New("nothing to append")
+	}
+	selected, err := parseEach(params.Each)
+	if err != nil {
+		return err
+	}
+	today := datePathFromTime(dayNow())
+	if len(params.Date) > 0 {
+		pd, err := parseDateString(cfg, params.Date)
+		if err != nil {
+			return err
+		}
+		today = *pd
+	}
+	r := dateRange{From: &today, To: &today}
+	q := queryFor(params)
+	// the time it is now says nothing about another day
+	isToday := today == datePathFromTime(dayNow())
+	timed := cfg.TimedSections && q.empty() && isToday
+	if q.empty() {
+		switch {
+		case params.NoTime:
+		case isToday:
+			text = stampLines(text, now())
+		default:
+			tracef("append: no time in front, %s isn't today", today.Iso())
+		}
+	} else {
+		r, err = resolveQuery(cfg, q, dayNow())
+		if err != nil {
+			return err
+		}
+		if r.From == nil || r.To == nil {
+			return errors.New("append needs a range with both a start and an end")
+		}
+	}
+
+	tp := newTemplater(cfg, params.Template, params.Verbose)
+	touched, created, skipped := 0, 0, 0
+	for d := r.From.Time(); !d.After(r.To.Time()); d = d.AddDate(0, 0, 1) {
+		if !selected(d) {
+			continue
+		}
+		dp := datePathFromTime(d)
+		path, err := entryPath(cfg, &dp)
+		if err != nil {
+			return err
+		}
+		data, err := readEntry(cfg, path)
+		exists := err == nil
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if !exists {
+			if err := creationAllowed(cfg, &dp, params.Create, false); err != nil {
+				return err
+			}
+		}
+		if params.SkipIfPresent && hasLine(data, text) {
+			skipped++
+			continue
+		}
+		if params.DryRun {
+			if exists {
+				fmt.Println("would append to", path)
+			} else {
+				fmt.Println("would create and append to", path)
+			}
+			touched++
+			continue
+		}
+		if !exists {
+			_, _, err = ensureEntry(cfg, &dp, tp.content)
+			if err != nil {
+				return err
+			}
+			data, err = readEntry(cfg, path)
+			if err != nil {
+				return err
+			}
+			created++
+		}
+		addition := text + "\n"
+		heading := ""
+		if timed {
+			heading, err = sectionHeading(cfg, data, now())
+			if err != nil {
+				return err
+			}
+		}
+		if len(heading) > 0 {
+			addition = heading + addition
+		} else if len(data) > 0 && data[len(data)-1] != '\n' {
+			addition = "\n" + addition
+		}
+		if err := appendToEntry(cfg, path, addition); err != nil {
+			return err
+		}
+		touched++
+	}
+	verb := "appended to"
+	if params.DryRun {
+		verb = "would append to"
+	}
+	fmt.Printf("%s %d entries", verb, touched)
+	if created > 0 {
+		fmt.Printf(", %d of them created", created)
+	}
+	if skipped > 0 {
+		fmt.Printf(", skipped %d that already had the line", skipped)
+	}
+	fmt.Println()
+	return nil
+}
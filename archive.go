@@ -0,0 +1,540 @@
+package wm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// archiveSuffix ends the names of the per-year archives archive --compress
+// writes, such as 2019.tar.gz.
+const archiveSuffix = ".tar.gz"
+
+// archiveDir returns where archive moves old entries to: dest when given,
+// archive_dir, or a directory named after the root with -archive appended,
+// as ~/wm-archive for ~/wm.
+func archiveDir(cfg Configuration, dest string) string {
+	if len(dest) > 0 {
+		return expandPath(dest)
+	}
+	if len(cfg.ArchiveDir) > 0 {
+		return configRelative(cfg, cfg.ArchiveDir)
+	}
+	return filepath.Clean(cfg.Root) + "-archive"
+}
+
+// archiveLabel is the label shown after an entry's date when it was found in
+// an archive.
+func archiveLabel(e Entry) string {
+	if len(e.Archive) == 0 {
+		return ""
+	}
+	return " (in " + filepath.Base(e.Archive) + ")"
+}
+
+// archiveMember returns the entry the member called name of the archive at
+// path is, read by path_layout as a path under the root would be.
+func archiveMember(cfg Configuration, path, name string) (Entry, bool) {
+	name = filepath.ToSlash(filepath.Clean(name))
+	ext := entryExt(cfg, name)
+	if len(ext) == 0 || strings.HasPrefix(name, "../") || filepath.IsAbs(name) {
+		return Entry{}, false
+	}
+	main, topic := splitTopic(name, ext)
+	dp, err := entryLayout(cfg).parse(main, ext)
+	if err != nil {
+		return Entry{}, false
+	}
+	return Entry{Date: *dp, Path: filepath.Join(path, filepath.FromSlash(name)), Topic: topic, Archive: path}, true
+}
+
+// archiveFiles returns the per-year archives in dir by name.
+func archiveFiles(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+archiveSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// eachArchived reads the archive at path member by member, calling fn with
+// the entry each is and a reader of its content, without extracting
+// anything to disk.  Members that aren't entries of cfg's layout are skipped.
+func eachArchived(cfg Configuration, path string, fn func(e Entry, r io.Reader) error) error {
+	return eachArchivedMember(path, func(hdr *tar.Header, r io.Reader) error {
+		e, ok := archiveMember(cfg, path, hdr.Name)
+		if !ok {
+			return nil
+		}
+		e.ModTime = hdr.ModTime
+		return fn(e, r)
+	})
+}
+
+// compressedEntry returns the per-year archive in the archive directory
+// that holds the entry for pd and topic, "" when none does.
+func compressedEntry(cfg Configuration, pd *DatePath, topic string) (string, error) {
+	path := filepath.Join(archiveDir(cfg, ""), strconv.Itoa(pd.year)+archiveSuffix)
+	if _, err := os.Stat(path); err != nil {
+		return "", nil
+	}
+	found := false
+	err := eachArchived(cfg, path, func(e Entry, r io.Reader) error {
+		found = found || e.Date == *pd && e.Topic == topic
+		return nil
+	})
+	if err != nil || !found {
+		return "", err
+	}
+	return path, nil
+}
+
+// fileSum returns the SHA-256 of the file at path.
+func fileSum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// archiveCandidates returns the entries under the root dated before before,
+// with their paths relative to the root.
+func archiveCandidates(cfg Configuration, before *DatePath) ([]Entry, []string, error) {
+	all, err := listEntries(cfg, walkOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	var entries []Entry
+	var rels []string
+	for _, e := range all {
+		if !e.Date.Before(before) {
+			continue
+		}
+		rel, err := filepath.Rel(cfg.Root, e.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, e)
+		rels = append(rels, rel)
+	}
+	return entries, rels, nil
+}
+
+// archiveFile copies the entry at src to dest under the archive directory,
+// keeping its modification time, checks that the copy reads back the same,
+// and only then removes src.
+func archiveFile(cfg Configuration, src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return fmt.Errorf("%s is archived already", dest)
+	}
+	if err := makeDir(cfg, filepath.Dir(dest)); err != nil {
+		return err
+	}
+	if _, err := copyFileAtomic(cfg, src, dest); err != nil {
+		return err
+	}
+	os.Chtimes(dest, info.ModTime(), info.ModTime())
+	want, err := fileSum(src)
+	if err != nil {
+		return err
+	}
+	got, err := fileSum(dest)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(want, got) {
+		os.Remove(dest)
+		return fmt.Errorf("the copy of %s doesn't read back the same; left it in place", src)
+	}
+	return os.Remove(src)
+}
+
+// archiveYear writes the entries at srcs, whose names in the archive are
+// rels, into the archive of year in dir, keeping what the archive already
+// has.  The archive is written to a temporary file and read back, every
+// entry compared with its file, before it replaces the old one, so the
+// entries are only removed once they are safely in it.  Entries the archive
+// already has a member of that name for are left out and returned as
+// skipped.
+func archiveYear(cfg Configuration, dir string, year int, srcs, rels []string) (archived []string, skipped []string, err error) {
+	path := filepath.Join(dir, strconv.Itoa(year)+archiveSuffix)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+	have := map[string]bool{}
+	if _, err := os.Stat(path); err == nil {
+		err := eachArchivedMember(path, func(hdr *tar.Header, r io.Reader) error {
+			have[filepath.ToSlash(filepath.Clean(hdr.Name))] = true
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err := io.Copy(tw, r)
+			return err
+		})
+		if err != nil {
+			tmp.Close()
+			return nil, nil, err
+		}
+	}
+	sums := map[string][]byte{}
+	for i, src := range srcs {
+		name := filepath.ToSlash(rels[i])
+		if have[name] {
+			skipped = append(skipped, src)
+			continue
+		}
+		if err := addToArchive(tw, src, name, sums); err != nil {
+			tmp.Close()
+			return nil, nil, fmt.Errorf("failed to archive %s: %w", src, err)
+		}
+		archived = append(archived, src)
+	}
+	err = tw.Close()
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	read := map[string]bool{}
+	err = eachArchivedMember(tmp.Name(), func(hdr *tar.Header, r io.Reader) error {
+		name := filepath.ToSlash(filepath.Clean(hdr.Name))
+		want, ok := sums[name]
+		if !ok {
+			return nil
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return err
+		}
+		if !bytes.Equal(h.Sum(nil), want) {
+			return fmt.Errorf("%s doesn't read back the same from the new archive", name)
+		}
+		read[name] = true
+		return nil
+	})
+	if err == nil && len(read) != len(sums) {
+		err = errors.New("the new archive is missing entries written to it")
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), fileMode(cfg))
+	}
+	if err == nil {
+		err = retryLocked(func() error { return os.Rename(tmp.Name(), path) })
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return archived, skipped, nil
+}
+
+// eachArchivedMember reads every regular member of the archive at path.
+func eachArchivedMember(path string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s is not an archive: %w", path, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s is damaged: %w", path, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// addToArchive writes the file at src to tw as name, noting the SHA-256 of
+// what was written in sums.
+func addToArchive(tw *tar.Writer, src, name string, sums map[string][]byte) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: name, Mode: int64(info.Mode().Perm()), Size: info.Size(), ModTime: info.ModTime(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tw, h), f); err != nil {
+		return err
+	}
+	sums[name] = h.Sum(nil)
+	return nil
+}
+
+// runArchive moves the entries dated before --before out of the root into
+// the archive directory, keeping their paths under the root, or with
+// --compress into a .tar.gz per year there, and prints what was moved and
+// how many bytes that took off the root.  An entry is only removed once its
+// copy has been read back and found the same.  --list shows the archives
+// instead.
+func runArchive(cfg Configuration, params Parameters) error {
+	dir := archiveDir(cfg, params.Dest)
+	if params.List {
+		return listArchives(cfg, dir)
+	}
+	before, err := parseDateString(cfg, params.Before)
+	if err != nil {
+		return fmt.Errorf("bad --before: %w", err)
+	}
+	if insideDir(cfg.Root, dir) {
+		return fmt.Errorf("the archive directory %s must be outside the root", displayPath(dir))
+	}
+	entries, rels, err := archiveCandidates(cfg, before)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Printf("no entries before %s\n", before.Iso())
+		return nil
+	}
+	sizes := make([]int64, len(entries))
+	var total int64
+	for i, e := range entries {
+		if info, err := os.Stat(e.Path); err == nil {
+			sizes[i] = info.Size()
+			total += info.Size()
+		}
+	}
+	where := displayPath(dir)
+	if params.Compress {
+		where += " as a " + archiveSuffix + " per year"
+	}
+	if params.DryRun {
+		for i, rel := range rels {
+			fmt.Printf("would archive %s  %s bytes\n", filepath.ToSlash(rel), groupDigits(int(sizes[i])))
+		}
+		fmt.Printf("would archive %d entries to %s, %s bytes\n", len(entries), where, groupDigits(int(total)))
+		return nil
+	}
+	if !params.Yes && !confirm(fmt.Sprintf("archive the %d entries before %s, %s bytes, to %s?", len(entries), before.Iso(), groupDigits(int(total)), where)) {
+		return errors.New("nothing archived")
+	}
+	if err := makeDir(cfg, dir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	moved := map[string]bool{}
+	if params.Compress {
+		byYear := map[int][]int{}
+		var years []int
+		for i, e := range entries {
+			if _, ok := byYear[e.Date.year]; !ok {
+				years = append(years, e.Date.year)
+			}
+			byYear[e.Date.year] = append(byYear[e.Date.year], i)
+		}
+		for _, year := range years {
+			var srcs, names []string
+			for _, i := range byYear[year] {
+				srcs, names = append(srcs, entries[i].Path), append(names, rels[i])
+			}
+			archived, skipped, err := archiveYear(cfg, dir, year, srcs, names)
+			if err != nil {
+				return fmt.Errorf("failed to archive %d, nothing of it was removed: %w", year, err)
+			}
+			for _, src := range skipped {
+				log.Printf(":::note::: %s is in %d%s already; left it in place", src, year, archiveSuffix)
+			}
+			for _, src := range archived {
+				if err := os.Remove(src); err != nil {
+					return fmt.Errorf("archived %s but failed to remove it: %w", src, err)
+				}
+				moved[src] = true
+			}
+		}
+	} else {
+		for i, e := range entries {
+			if err := archiveFile(cfg, e.Path, filepath.Join(dir, rels[i])); err != nil {
+				log.Printf(":::note::: %s not archived: %v", e.Path, err)
+				continue
+			}
+			moved[e.Path] = true
+		}
+	}
+	var count int
+	var reclaimed int64
+	for i, e := range entries {
+		if !moved[e.Path] {
+			continue
+		}
+		fmt.Printf("%s  %s bytes\n", filepath.ToSlash(rels[i]), groupDigits(int(sizes[i])))
+		count++
+		reclaimed += sizes[i]
+		removeEmptyDirs(cfg.Root, filepath.Dir(e.Path))
+	}
+	fmt.Printf("archived %d entries to %s, %s bytes reclaimed\n", count, where, groupDigits(int(reclaimed)))
+	if count < len(entries) {
+		return fmt.Errorf("%d entries were left in place", len(entries)-count)
+	}
+	return nil
+}
+
+// listArchives prints the archives in dir, each with how many entries it
+// holds, the dates they span, and its size.
+func listArchives(cfg Configuration, dir string) error {
+	files, err := archiveFiles(dir)
+	if err != nil {
+		return err
+	}
+	shown := 0
+	show := func(name string, entries []Entry, size int64) {
+		if len(entries) == 0 {
+			return
+		}
+		first, last := entries[0].Date, entries[0].Date
+		for _, e := range entries[1:] {
+			if e.Date.Before(&first) {
+				first = e.Date
+			}
+			if last.Before(&e.Date) {
+				last = e.Date
+			}
+		}
+		fmt.Printf("%s  %d entries  %s..%s  %s bytes\n", name, len(entries), first.Iso(), last.Iso(), groupDigits(int(size)))
+		shown++
+	}
+	archived := cfg
+	archived.Root = dir
+	plain, err := listEntries(archived, walkOptions{})
+	if err != nil {
+		return err
+	}
+	var plainSize int64
+	for _, e := range plain {
+		if info, err := os.Stat(e.Path); err == nil {
+			plainSize += info.Size()
+		}
+	}
+	show(displayPath(dir)+" (not compressed)", plain, plainSize)
+	for _, f := range files {
+		var entries []Entry
+		err := eachArchived(cfg, f, func(e Entry, _ io.Reader) error {
+			entries = append(entries, e)
+			return nil
+		})
+		if err != nil {
+			log.Printf(":::note::: %v", err)
+			continue
+		}
+		var size int64
+		if info, err := os.Stat(f); err == nil {
+			size = info.Size()
+		}
+		show(filepath.Base(f), entries, size)
+	}
+	if shown == 0 {
+		fmt.Println("no archives in", displayPath(dir))
+	}
+	return nil
+}
+
+// scanArchives searches the archives in the archive directory for q: the
+// entries kept there as files, as the root's are, and the members of each
+// .tar.gz, read one after the other as the archive is decompressed.  Only
+// entries in r and of topic, unless it is "", are searched.
+func scanArchives(cfg Configuration, r dateRange, topic string, q searchTerms) ([]fileResult, []fileResult) {
+	dir := archiveDir(cfg, "")
+	if !isDir(dir) {
+		return nil, nil
+	}
+	archived := cfg
+	archived.Root = dir
+	plain, err := listEntries(archived, walkOptions{})
+	if err != nil {
+		log.Printf(":::note::: skipping the archives in %s: %v", dir, err)
+		return nil, nil
+	}
+	for i := range plain {
+		plain[i].Archive = dir
+	}
+	results, failed := scanFiles(cfg, filterEntries(filterTopic(plain, topic), r.From, r.To), q)
+	files, err := archiveFiles(dir)
+	if err != nil {
+		log.Printf(":::note::: skipping the archives in %s: %v", dir, err)
+		return results, failed
+	}
+	for _, f := range files {
+		err := eachArchived(cfg, f, func(e Entry, tr io.Reader) error {
+			if len(topic) > 0 && e.Topic != topic || len(filterEntries([]Entry{e}, r.From, r.To)) == 0 {
+				return nil
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			data, err = openEntry(cfg, e.Path, data)
+			if err != nil {
+				failed = append(failed, fileResult{Entry: e, Err: err})
+				return nil
+			}
+			if bytes.IndexByte(data, 0) >= 0 {
+				return nil
+			}
+			res := searchData(e, data, q)
+			if len(res.Hits) == 0 {
+				res.Data = nil
+			}
+			results = append(results, res)
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			failed = append(failed, fileResult{Entry: Entry{Path: f, Archive: f}, Err: err})
+		}
+	}
+	return results, failed
+}
@@ -0,0 +1,268 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sizeLabel is n bytes as attach and attachments show it, such as "24 KB".
+func sizeLabel(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d bytes", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%d KB", (n+1<<9)>>10)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	}
+}
+
+// attachmentName returns the name under dir, the attachments of a day, that
+// name can be stored as without replacing anything: name itself, or name
+// with -1, -2, and so on before its extension.
+func attachmentName(dir, name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(filepath.Join(dir, candidate)); errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+}
+
+// copyFileAtomic copies the file src to dest through a temporary file in
+// dest's directory, renamed into place once written and synced, so that an
+// interrupted copy never leaves a partial file under dest's name.
+func copyFileAtomic(cfg Configuration, src, dest string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tmp, in)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), fileMode(cfg))
+	}
+	if err == nil {
+		err = retryLocked(func() error { return os.Rename(tmp.Name(), dest) })
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return n, nil
+}
+
+// copyTreeAtomic copies the directory src to dest, which mustn't exist yet,
+// every file as copyFileAtomic does, and returns how many files and bytes
+// it copied.  Symbolic links are left out.
+func copyTreeAtomic(cfg Configuration, src, dest string) (int, int64, error) {
+	files, total := 0, int64(0)
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		switch {
+		case d.IsDir():
+			return makeDir(cfg, target)
+		case !d.Type().IsRegular():
+			log.Printf(":::note::: skipping %s: it isn't a regular file", path)
+			return nil
+		}
+		n, err := copyFileAtomic(cfg, path, target)
+		files++
+		total += n
+		return err
+	})
+	return files, total, err
+}
+
+// runAttach copies the file given into the attachments of the entry for the
+// date given, today by default, under a name that isn't taken yet, and
+// appends a line naming it to the entry, creating the entry from its
+// template first as opening it would.  A directory is only copied, as a
+// whole, with --recursive.
+func runAttach(cfg Configuration, params Parameters) error {
+	info, err := os.Stat(params.File)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() && !params.Recursive {
+		return fmt.Errorf("%s is a directory; use --recursive to attach it with everything in it", params.File)
+	}
+	if !info.IsDir() && !info.Mode().IsRegular() {
+		return fmt.Errorf("%s isn't a regular file", params.File)
+	}
+	pd, target, err := resolveEntry(cfg, params)
+	if err != nil {
+		return err
+	}
+	path, _, err := ensureEntryAt(cfg, target, pd, newEntryContent(cfg, params))
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(cfg.Root, attachmentsDir, pd.Iso())
+	if err := makeDir(cfg, dir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	name := attachmentName(dir, filepath.Base(filepath.Clean(params.File)))
+	dest := filepath.Join(dir, name)
+	size := ""
+	if info.IsDir() {
+		files, n, err := copyTreeAtomic(cfg, params.File, dest)
+		if err != nil {
+			os.RemoveAll(dest)
+			return err
+		}
+		size = fmt.Sprintf("%d files, %s", files, sizeLabel(n))
+	} else {
+		n, err := copyFileAtomic(cfg, params.File, dest)
+		if err != nil {
+			return err
+		}
+		size = sizeLabel(n)
+	}
+	if cfg.Encrypt {
+		log.Printf(":::note::: %s is stored as it is; attachments aren't encrypted", name)
+	}
+	rel := filepath.ToSlash(filepath.Join(attachmentsDir, pd.Iso(), name))
+	data, err := readEntry(cfg, path)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("attachment: %s (%s)\n", rel, size)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		line = "\n" + line
+	}
+	if err := appendToEntry(cfg, path, line); err != nil {
+		return fmt.Errorf("attached %s, but failed to note it in %s: %w", rel, displayPath(path), err)
+	}
+	fmt.Printf("attached %s (%s) to %s\n", rel, size, displayPath(path))
+	return nil
+}
+
+// storedAttachment is a file kept in the attachments of a day, by its name
+// under them.
+type storedAttachment struct {
+	Name string
+	Size int64
+}
+
+// storedAttachments returns the files kept in the attachments of each day,
+// by the day as 2006-01-02, or of the day iso alone when it is set.
+func storedAttachments(cfg Configuration, iso string) (map[string][]storedAttachment, error) {
+	base := filepath.Join(cfg.Root, attachmentsDir)
+	days := map[string][]storedAttachment{}
+	err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == base {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return nil
+		}
+		parts := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+		if len(parts) != 2 || (len(iso) > 0 && parts[0] != iso) {
+			return nil
+		}
+		if _, err := time.ParseInLocation("2006-01-02", parts[0], time.Local); err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		days[parts[0]] = append(days[parts[0]], storedAttachment{parts[1], info.Size()})
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return days, nil
+	}
+	return days, err
+}
+
+// runAttachments lists the attachments of the date given, or of every date
+// with any, with their sizes.
+func runAttachments(cfg Configuration, params Parameters) error {
+	iso := ""
+	if len(params.DateWords) > 0 {
+		pd, err := parseDateString(cfg, strings.Join(params.DateWords, " "))
+		if err != nil {
+			return err
+		}
+		iso = pd.Iso()
+	}
+	days, err := storedAttachments(cfg, iso)
+	if err != nil {
+		return err
+	}
+	if len(days) == 0 {
+		if len(iso) > 0 {
+			fmt.Printf("no attachments for %s\n", iso)
+		} else {
+			fmt.Println("no attachments")
+		}
+		return nil
+	}
+	dates := make([]string, 0, len(days))
+	for d := range days {
+		dates = append(dates, d)
+	}
+	sort.Strings(dates)
+	color := colorful()
+	for i, d := range dates {
+		if i > 0 {
+			fmt.Println()
+		}
+		label := d
+		if pd, err := parseDateString(cfg, d); err == nil {
+			label = humanDate(*pd)
+		}
+		if color {
+			label = heading(label)
+		}
+		fmt.Println(label)
+		files := days[d]
+		width := 0
+		for _, f := range files {
+			if len(f.Name) > width {
+				width = len(f.Name)
+			}
+		}
+		for _, f := range files {
+			fmt.Printf("  %-*s  %s\n", width, f.Name, sizeLabel(f.Size))
+		}
+	}
+	return nil
+}
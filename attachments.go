@@ -0,0 +1,100 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Attachments of an entry live under root/attachments/<date>/, where <date>
+// is the entry's date as 2006-01-02, e.g. attachments/2024-03-07/build.log.
+const attachmentsDir = "attachments"
+
+var (
+	defaultAttachmentTypes   = []string{"txt", "md", "csv", "log", "json"}
+	defaultAttachmentMaxSize = int64(1 << 20)
+)
+
+// listAttachments returns the searchable attachments under root: files whose
+// extension is in attachment_types and whose size is within
+// attachment_max_size.  They are returned as entries carrying the
+// attachment's name, sorted by date.
+func listAttachments(cfg Configuration) ([]Entry, error) {
+	types := cfg.AttachmentTypes
+	if len(types) == 0 {
+		types = defaultAttachmentTypes
+	}
+	maxSize := cfg.AttachmentMaxSize
+	if maxSize <= 0 {
+		maxSize = defaultAttachmentMaxSize
+	}
+	allowed := map[string]bool{}
+	for _, t := range types {
+		allowed[strings.ToLower(strings.TrimPrefix(t, "."))] = true
+	}
+
+	base := filepath.Join(cfg.Root, attachmentsDir)
+	var found []Entry
+	err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == base {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return nil
+		}
+		parts := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+		if len(parts) != 2 {
+			return nil
+		}
+		t, err := time.ParseInLocation("2006-01-02", parts[0], time.Local)
+		if err != nil {
+			return nil
+		}
+		if !allowed[strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxSize {
+			return nil
+		}
+		found = append(found, Entry{Date: datePathFromTime(t), Path: path, ModTime: info.ModTime(), Attachment: parts[1]})
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Date.Before(&found[j].Date) })
+	return found, err
+}
+
+// isBinary reports whether data looks like something other than text: it
+// contains a NUL byte or isn't valid UTF-8 near its start.
+func isBinary(data []byte) bool {
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	for len(head) > 0 {
+		r, size := utf8.DecodeRune(head)
+		if r == utf8.RuneError && size == 1 && len(head) >= utf8.UTFMax {
+			return true
+		}
+		head = head[size:]
+	}
+	return false
+}
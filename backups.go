@@ -0,0 +1,174 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// versionStampLayout is the timestamp backupEntry suffixes copies with.
+const versionStampLayout = "20060102T150405.000000000"
+
+var versionStampRe = regexp.MustCompile(`^\d{8}T\d{6}\.\d{9}$`)
+
+// entryVersion is a copy of an entry in the versions store.
+type entryVersion struct {
+	Path  string
+	Taken time.Time
+	Size  int64
+}
+
+// entryVersions returns the copies of the entry at path in the versions
+// store, newest first, whichever command made them.
+func entryVersions(cfg Configuration, path string) ([]entryVersion, error) {
+	rel, err := filepath.Rel(cfg.Root, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	base := filepath.Join(cfg.Root, versionsDir, rel)
+	files, err := os.ReadDir(filepath.Dir(base))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	prefix := filepath.Base(base) + "."
+	var versions []entryVersion
+	for _, f := range files {
+		stamp := strings.TrimPrefix(f.Name(), prefix)
+		if f.IsDir() || stamp == f.Name() || !versionStampRe.MatchString(stamp) {
+			continue
+		}
+		taken, err := time.ParseInLocation(versionStampLayout, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, entryVersion{filepath.Join(filepath.Dir(base), f.Name()), taken, info.Size()})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Taken.After(versions[j].Taken) })
+	return versions, nil
+}
+
+// pruneVersions removes all but the keep newest copies of the entry at path.
+func pruneVersions(cfg Configuration, path string, keep int) error {
+	versions, err := entryVersions(cfg, path)
+	if err != nil || len(versions) <= keep {
+		return err
+	}
+	for _, v := range versions[keep:] {
+		if err := os.Remove(v.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupBeforeEdit copies the entry at path to the versions store before the
+// editor is started on it, when backups is set, keeping the newest backups
+// copies of it.  An entry that doesn't exist yet is skipped, and so is one
+// unchanged since its newest copy, so that opening an entry again and again
+// doesn't push the useful copies out.
+func backupBeforeEdit(cfg Configuration, path string) error {
+	if cfg.Backups <= 0 {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	versions, err := entryVersions(cfg, path)
+	if err != nil {
+		return err
+	}
+	if len(versions) > 0 {
+		if newest, err := os.ReadFile(versions[0].Path); err == nil && bytes.Equal(newest, data) {
+			return nil
+		}
+	}
+	if _, err := backupEntry(cfg, path); err != nil {
+		return fmt.Errorf("%w; set backups = 0 to edit without backups", err)
+	}
+	return pruneVersions(cfg, path, cfg.Backups)
+}
+
+// runRestore lists the copies of the entry for the date given, newest first,
+// and puts back the one chosen by --backup, or asked for on a terminal.  The
+// entry as it is is copied to the versions store first, so a restore can be
+// undone by restoring again.
+func runRestore(cfg Configuration, params Parameters) error {
+	pd, target, err := resolveEntry(cfg, params)
+	if err != nil {
+		return err
+	}
+	versions, err := entryVersions(cfg, target)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("no backups of the entry for %s", pd.Iso())
+	}
+	choice := params.Backup
+	if len(choice) == 0 {
+		fmt.Printf("backups of %s, newest first:\n", displayPath(target))
+		for i, v := range versions {
+			fmt.Printf("  %2d  %s  %s bytes\n", i+1, v.Taken.Format("2006-01-02 15:04:05"), groupDigits(int(v.Size)))
+		}
+		if plainOutput || !term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Printf("restore one with \"wm restore %s --backup=<n>\"\n", pd.Iso())
+			return nil
+		}
+		if choice = ask("restore which", ""); len(choice) == 0 {
+			return nil
+		}
+	}
+	n, err := strconv.Atoi(choice)
+	if err != nil || n < 1 || n > len(versions) {
+		return fmt.Errorf("--backup must be a number from 1 to %d, not '%s'", len(versions), choice)
+	}
+	chosen := versions[n-1]
+	raw, err := os.ReadFile(chosen.Path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(target); errors.Is(err, fs.ErrNotExist) {
+		if err := makeDir(cfg, filepath.Dir(target)); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, raw, fileMode(cfg)); err != nil {
+			return err
+		}
+	} else {
+		data, err := readEntry(cfg, chosen.Path)
+		if err != nil {
+			return err
+		}
+		backup, err := rewriteEntry(cfg, target, data, rewriteOptions{Backup: true})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("the entry as it was is %s\n", displayPath(backup))
+		if cfg.Backups > 0 {
+			if err := pruneVersions(cfg, target, cfg.Backups); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Printf("restored %s from the backup of %s\n", displayPath(target), chosen.Taken.Format("2006-01-02 15:04:05"))
+	return nil
+}
@@ -0,0 +1,114 @@
+package wm
+
+import (
+	"bytes"
+	"log"
+)
+
+// boilerplate finds the parts of entries that wm wrote rather than the user:
+// the generated header and the lines of the entry's rendered template that
+// are still exactly as the template wrote them.  Search --no-boilerplate
+// hides hits there.  A nil *boilerplate finds nothing.
+type boilerplate struct {
+	tp     *templater
+	failed map[string]bool
+}
+
+func newBoilerplate(cfg Configuration, params Parameters) *boilerplate {
+	if !params.NoBoilerplate && !cfg.SearchSkipBoilerplate {
+		return nil
+	}
+	return &boilerplate{tp: newTemplater(cfg, params.Template, false), failed: map[string]bool{}}
+}
+
+// byteRange is a half-open range of byte offsets.
+type byteRange struct{ Start, End int }
+
+// boilerplateRegions are the byte ranges of an entry that are boilerplate.
+type boilerplateRegions []byteRange
+
+// contains reports whether the byte offset off is boilerplate.
+func (r boilerplateRegions) contains(off int) bool {
+	for _, b := range r {
+		if off >= b.Start && off < b.End {
+			return true
+		}
+	}
+	return false
+}
+
+// skeleton returns the lines of the template rendered for e, without the
+// header, that have anything on them.
+func (b *boilerplate) skeleton(e Entry) map[string]bool {
+	content, err := b.tp.content(&e.Date)
+	if err != nil {
+		if choice := b.tp.choose(&e.Date); !b.failed[choice.Path] {
+			b.failed[choice.Path] = true
+			log.Println(":::note::: only hiding headers:", err)
+		}
+		return nil
+	}
+	lines := map[string]bool{}
+	for _, l := range splitLines(stripHeader([]byte(content))) {
+		if !isBlank(l) {
+			lines[string(bytes.TrimRight(l, "\r\n"))] = true
+		}
+	}
+	return lines
+}
+
+// regions returns the boilerplate of e, whose content is data.  Attachments
+// and scratch and kind notes have none.
+func (b *boilerplate) regions(e Entry, data []byte) boilerplateRegions {
+	if b == nil || len(e.Attachment) > 0 || len(e.Scratch) > 0 || len(e.Note) > 0 {
+		return nil
+	}
+	var r boilerplateRegions
+	start := 0
+	if h, ok := findHeader(data); ok {
+		r = append(r, byteRange{h.Start, h.End})
+		start = h.End
+	}
+	skeleton := b.skeleton(e)
+	off := start
+	for _, l := range splitLines(data[start:]) {
+		if skeleton[string(bytes.TrimRight(l, "\r\n"))] {
+			r = append(r, byteRange{off, off + len(l)})
+		}
+		off += len(l)
+	}
+	return r
+}
+
+// lineSkip is the boilerplate of an entry searched a line at a time: its
+// header, found in the first part of it, and the lines of its template.  A
+// nil *lineSkip hides nothing.
+type lineSkip struct {
+	header   byteRange
+	skeleton map[string]bool
+}
+
+// lines returns the boilerplate of e for searching it a line at a time, head
+// being the start of its content.
+func (b *boilerplate) lines(e Entry, head []byte) *lineSkip {
+	if b == nil || len(e.Attachment) > 0 || len(e.Scratch) > 0 || len(e.Note) > 0 {
+		return nil
+	}
+	s := &lineSkip{skeleton: b.skeleton(e)}
+	if h, ok := findHeader(head); ok {
+		s.header = byteRange{h.Start, h.End}
+	}
+	return s
+}
+
+// hides reports whether the line starting at the byte offset off, its
+// terminator left out, is boilerplate.
+func (s *lineSkip) hides(off int, line []byte) bool {
+	if s == nil {
+		return false
+	}
+	if off >= s.header.Start && off < s.header.End {
+		return true
+	}
+	return off >= s.header.End && s.skeleton[string(line)]
+}
@@ -0,0 +1,167 @@
+package wm
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const bulkJournalFile = "bulk-created.txt"
+
+// bulkFile is one file for writeBulk to create.
+type bulkFile struct {
+	Path string
+	Data []byte
+}
+
+// bulkResult says what writeBulk did.  Created lists the files it wrote,
+// Identical counts files that already existed with the same content, and
+// Conflicts lists existing files with other content that were left alone.
+type bulkResult struct {
+	Created   []string
+	Identical int
+	Conflicts []string
+}
+
+// writeBulk creates many files at once for commands such as fill and split.
+// Files are grouped by directory so each directory is created once, written
+// through a buffer, and never replace an existing file unless replace is set,
+// so running the same batch again after a failure only writes what is still
+// missing.  When a write fails the files created so far are recorded in the
+// bulk journal in the state directory.  Progress goes to progress when it is
+// not nil.
+func writeBulk(cfg Configuration, files []bulkFile, replace bool, progress io.Writer) (bulkResult, error) {
+	var res bulkResult
+	sorted := make([]bulkFile, len(files))
+	copy(sorted, files)
+	sort.SliceStable(sorted, func(i, j int) bool { return filepath.Dir(sorted[i].Path) < filepath.Dir(sorted[j].Path) })
+
+	w := bufio.NewWriterSize(nil, 64*1024)
+	dir, fresh := "", false
+	for i, f := range sorted {
+		if d := filepath.Dir(f.Path); d != dir {
+			// nothing can be in a directory this run creates, so its
+			// files needn't be looked for first
+			_, err := os.Stat(d)
+			fresh = errors.Is(err, fs.ErrNotExist)
+			if err := makeDir(cfg, d); err != nil {
+				return res, res.fail(fmt.Errorf("failed to create %s: %w", d, err))
+			}
+			dir = d
+		}
+		flags := os.O_CREATE | os.O_EXCL | os.O_WRONLY
+		var existing []byte
+		err := fs.ErrNotExist
+		if !fresh {
+			existing, err = os.ReadFile(f.Path)
+		}
+		switch {
+		case err == nil && bytes.Equal(existing, f.Data):
+			res.Identical++
+			continue
+		case err == nil && !replace:
+			res.Conflicts = append(res.Conflicts, f.Path)
+			continue
+		case err == nil:
+			flags = os.O_TRUNC | os.O_WRONLY
+		case !errors.Is(err, fs.ErrNotExist):
+			return res, res.fail(err)
+		}
+		out, err := os.OpenFile(f.Path, flags, fileMode(cfg))
+		if err != nil {
+			return res, res.fail(err)
+		}
+		w.Reset(out)
+		_, err = w.Write(f.Data)
+		if err == nil {
+			err = w.Flush()
+		}
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return res, res.fail(fmt.Errorf("failed to write %s: %w", f.Path, err))
+		}
+		res.Created = append(res.Created, f.Path)
+		if progress != nil && ((i+1)%100 == 0 || i+1 == len(sorted)) {
+			fmt.Fprintf(progress, "\r%d/%d files", i+1, len(sorted))
+		}
+	}
+	if progress != nil && len(sorted) > 0 {
+		fmt.Fprintln(progress)
+	}
+	return res, nil
+}
+
+// fail records the files created so far in the bulk journal and returns err
+// annotated with where to find them.
+func (res bulkResult) fail(err error) error {
+	path, jerr := statePath(bulkJournalFile)
+	if jerr == nil {
+		jerr = os.WriteFile(path, []byte(strings.Join(res.Created, "\n")+"\n"), 0o600)
+	}
+	if jerr != nil {
+		return fmt.Errorf("%w (after creating %d files)", err, len(res.Created))
+	}
+	return fmt.Errorf("%w (the %d files created before the failure are listed in %s)", err, len(res.Created), path)
+}
+
+// runFill creates the missing entries for every day in the range.  Every
+// template is loaded before the first file is written.
+func runFill(cfg Configuration, params Parameters) error {
+	r, err := resolveQuery(cfg, queryFor(params), dayNow())
+	if err != nil {
+		return err
+	}
+	if r.From == nil || r.To == nil {
+		return errors.New("fill needs a range with both a start and an end")
+	}
+	tp := newTemplater(cfg, params.Template, params.Verbose)
+	x := loadMonthIndex(cfg)
+	defer x.save()
+	var files []bulkFile
+	for d := r.From.Time(); !d.After(r.To.Time()); d = d.AddDate(0, 0, 1) {
+		dp := datePathFromTime(d)
+		path, err := entryPath(cfg, &dp)
+		if err != nil {
+			return err
+		}
+		if has, ok, err := x.has(&dp); ok && err == nil {
+			if has {
+				continue
+			}
+		} else if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		content, err := tp.content(&dp)
+		if err != nil {
+			return err
+		}
+		files = append(files, bulkFile{Path: path, Data: []byte(content)})
+	}
+	if params.DryRun {
+		for _, f := range files {
+			fmt.Println("would create", f.Path)
+		}
+		return nil
+	}
+	var progress io.Writer
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		progress = os.Stderr
+	}
+	res, err := writeBulk(cfg, files, false, progress)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created %d entries\n", len(res.Created))
+	return nil
+}
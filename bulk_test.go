@@ -0,0 +1,143 @@
+package wm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testFill returns the entries a fill of years years from 2022 creates
+// under root, each from its weekday's template.
+func testFill(root string, years int) []bulkFile {
+	var files []bulkFile
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.Local)
+	for d := start; d.Before(start.AddDate(years, 0, 0)); d = d.AddDate(0, 0, 1) {
+		dp := datePathFromTime(d)
+		files = append(files, bulkFile{
+			Path: filepath.Join(root, filepath.FromSlash(dp.String())),
+			Data: []byte(renderHeader(&dp) + "## " + d.Weekday().String() + "\n"),
+		})
+	}
+	return files
+}
+
+func TestWriteBulkRetry(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	root := t.TempDir()
+	cfg := Configuration{Root: root}
+	files := testFill(root, 1)[:90]
+
+	// a file where the directory of March should be stops the run after
+	// January and February
+	blocker := filepath.Join(root, "2022", "3")
+	if err := os.MkdirAll(filepath.Dir(blocker), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := writeBulk(cfg, files, false, nil)
+	if err == nil {
+		t.Fatal("writeBulk into a blocked directory succeeded, want an error")
+	}
+	var want []string
+	for _, f := range files[:59] {
+		want = append(want, f.Path)
+	}
+	if !reflect.DeepEqual(res.Created, want) {
+		t.Fatalf("created %d files before failing, want the 59 of January and February", len(res.Created))
+	}
+	journal, jerr := statePath(bulkJournalFile)
+	if jerr != nil {
+		t.Fatal(jerr)
+	}
+	assertEntry(t, journal, strings.Join(want, "\n")+"\n")
+	if !strings.Contains(err.Error(), journal) {
+		t.Errorf("error = %v, want it to name %s", err, journal)
+	}
+
+	// retrying writes only what is missing and leaves edits alone
+	if err := os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(files[0].Path, []byte("edited\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err = writeBulk(cfg, files, false, nil)
+	if err != nil {
+		t.Fatalf("retrying writeBulk: %v", err)
+	}
+	if len(res.Created) != 31 || res.Identical != 58 || !reflect.DeepEqual(res.Conflicts, []string{files[0].Path}) {
+		t.Errorf("retry = %d created, %d identical, conflicts %q, want 31, 58, and the edited file", len(res.Created), res.Identical, res.Conflicts)
+	}
+	assertEntry(t, files[0].Path, "edited\n")
+	for _, f := range files[1:] {
+		assertEntry(t, f.Path, string(f.Data))
+	}
+
+	res, err = writeBulk(cfg, files, true, nil)
+	if err != nil || len(res.Created) != 1 || res.Identical != 89 {
+		t.Errorf("writeBulk replacing = %+v, %v, want only the edited file written", res, err)
+	}
+	assertEntry(t, files[0].Path, string(files[0].Data))
+}
+
+// naiveFill creates files the way every command did before writeBulk.
+func naiveFill(files []bulkFile) error {
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f.Path), 0o755); err != nil {
+			return err
+		}
+		out, err := os.Create(f.Path)
+		if err != nil {
+			return err
+		}
+		_, err = out.WriteString(string(f.Data))
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BenchmarkFill compares writeBulk with the naive loop over a fill of three
+// years.  Each iteration fills a new root, removed before the next.  The
+// gap is widest where every call is a round trip, so run it with TMPDIR on
+// the filesystem in question.
+func BenchmarkFill(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		fill func(root string, files []bulkFile) error
+	}{
+		{"naive", func(root string, files []bulkFile) error { return naiveFill(files) }},
+		{"bulk", func(root string, files []bulkFile) error {
+			_, err := writeBulk(Configuration{Root: root}, files, false, nil)
+			return err
+		}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			parent := b.TempDir()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				root, err := os.MkdirTemp(parent, "root")
+				if err != nil {
+					b.Fatal(err)
+				}
+				files := testFill(root, 3)
+				b.StartTimer()
+				if err := bb.fill(root, files); err != nil {
+					b.Fatal(err)
+				}
+				b.StopTimer()
+				os.RemoveAll(root)
+				b.StartTimer()
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package wm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// bundleVersion is the current bundle format.  Importing accepts this version
+// and every older one.
+const bundleVersion = 1
+
+const (
+	bundleManifest    = "manifest.json"
+	bundleConfig      = "config.toml"
+	bundleRootMarker  = "@WM_ROOT@"
+	defaultBundleFile = "wm-setup.tar.gz"
+)
+
+// bundleDirs are the setup directories kept next to the configuration file
+// that travel with a bundle.  Log content is never included.
+var bundleDirs = []string{"templates", "snippets", "prompts"}
+
+type manifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Root    string    `json:"root"`
+	Files   []string  `json:"files"`
+}
+
+var rootLineRe = regexp.MustCompile(`(?m)^(\s*root\s*=\s*)(".*?"|'.*?')(.*)$`)
+
+// runBundleExport writes the configuration and setup directories to a
+// gzipped tarball with the root path replaced by a placeholder.
+func runBundleExport(cfg Configuration, cfgFile string, params Parameters) error {
+	out := params.Out
+	if len(out) == 0 {
+		out = defaultBundleFile
+	}
+	cfgData, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+	cfgData = rootLineRe.ReplaceAll(cfgData, []byte(`${1}"`+bundleRootMarker+`"${3}`))
+
+	files := map[string][]byte{bundleConfig: cfgData}
+	names := []string{bundleConfig}
+	base := filepath.Dir(cfgFile)
+	for _, dir := range bundleDirs {
+		err := filepath.WalkDir(filepath.Join(base, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(base, path)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+			files[name] = data
+			names = append(names, name)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", dir, err)
+		}
+	}
+
+	m := manifest{Version: bundleVersion, Created: now(), Root: cfg.Root, Files: names}
+	mdata, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte) error {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: m.Created})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+	err = write(bundleManifest, mdata)
+	for _, name := range names {
+		if err != nil {
+			break
+		}
+		err = write(name, files[name])
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	fmt.Printf("wrote %d files to %s\n", len(names), out)
+	return nil
+}
+
+// readBundle returns the manifest and files of a bundle.
+func readBundle(path string) (manifest, map[string][]byte, error) {
+	var m manifest
+	f, err := os.Open(path)
+	if err != nil {
+		return m, nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return m, nil, fmt.Errorf("'%s' is not a wm bundle: %w", path, err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return m, nil, fmt.Errorf("'%s' is not a wm bundle: %w", path, err)
+		}
+		name := filepath.ToSlash(filepath.Clean(hdr.Name))
+		if strings.HasPrefix(name, "../") || filepath.IsAbs(name) {
+			return m, nil, fmt.Errorf("bundle contains unsafe path '%s'", hdr.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return m, nil, err
+		}
+		files[name] = data
+	}
+	mdata, ok := files[bundleManifest]
+	if !ok {
+		return m, nil, fmt.Errorf("'%s' has no manifest", path)
+	}
+	err = json.Unmarshal(mdata, &m)
+	if err != nil {
+		return m, nil, fmt.Errorf("bad bundle manifest: %w", err)
+	}
+	if m.Version < 1 || m.Version > bundleVersion {
+		return m, nil, fmt.Errorf("bundle version %d is not supported by this wm (up to %d)", m.Version, bundleVersion)
+	}
+	return m, files, nil
+}
+
+// runBundleImport installs a bundle's configuration and setup files, asking
+// for the root path to use on this machine and before overwriting anything.
+func runBundleImport(cfgFile string, params Parameters) error {
+	m, files, err := readBundle(params.Bundlefile)
+	if err != nil {
+		return err
+	}
+	root := m.Root
+	if !params.Yes {
+		root = ask("log root on this machine", m.Root)
+	}
+	root = strings.ReplaceAll(root, `\`, `\\`)
+
+	base := filepath.Dir(cfgFile)
+	installed := 0
+	for _, name := range m.Files {
+		data, ok := files[name]
+		if !ok {
+			return fmt.Errorf("bundle is missing '%s'", name)
+		}
+		dest := filepath.Join(base, filepath.FromSlash(name))
+		if name == bundleConfig {
+			dest = cfgFile
+			data = []byte(strings.ReplaceAll(string(data), bundleRootMarker, root))
+		}
+		if _, err := os.Stat(dest); err == nil && !params.Yes {
+			if !confirm(fmt.Sprintf("overwrite %s?", dest)) {
+				fmt.Println("skipped", dest)
+				continue
+			}
+		}
+		err = os.MkdirAll(filepath.Dir(dest), 0o755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(dest, data, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to install %s: %w", dest, err)
+		}
+		fmt.Println("installed", dest)
+		installed++
+	}
+	fmt.Printf("%d of %d files installed\n", installed, len(m.Files))
+	return nil
+}
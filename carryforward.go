@@ -0,0 +1,44 @@
+package wm
+
+import (
+	"strings"
+)
+
+// carriedOver returns the section a new entry for pd starts with when
+// carry_forward is on and pd is today: the open task list items of the
+// latest entry before it, copied line for line so that nested items keep
+// their indentation, but for the time append put in front of them, under
+// "## Carried over".  It is "" when there are none.
+// The entry they come from is left as it is.
+func carriedOver(cfg Configuration, pd *DatePath) (string, error) {
+	if !cfg.CarryForward || *pd != datePathFromTime(dayNow()) {
+		return "", nil
+	}
+	entries, err := listEntries(cfg, walkOptions{})
+	if err != nil {
+		return "", err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if len(e.Topic) > 0 || !e.Date.Before(pd) {
+			continue
+		}
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return "", err
+		}
+		var b strings.Builder
+		for _, it := range extractTodos(data) {
+			if !it.Done {
+				// today's entry isn't the day the time was of
+				line := strings.Replace(it.Raw, it.Stamp, "", 1)
+				b.WriteString(strings.TrimRight(line, "\r") + "\n")
+			}
+		}
+		if b.Len() == 0 {
+			return "", nil
+		}
+		return "## Carried over\n" + b.String() + "\n", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,106 @@
+package wm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCarriedOver(t *testing.T) {
+	testToday(t, 2024, time.March, 7)
+	today := DatePath{2024, 3, 7}
+	write := func(root string, pd DatePath, content string) string {
+		t.Helper()
+		p := filepath.Join(root, filepath.FromSlash(pd.String()))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	nested := "Working Memory File\n3/5/2024\n---\n\n" +
+		"- [ ] ship the release\n" +
+		"  - [x] tag it\n" +
+		"  - [ ] write the notes\n" +
+		"    * [ ] ask Sam about the date\n" +
+		"- [X] done already\n" +
+		"[09:30] - [ ] call back\r\n" +
+		"not a task\n"
+
+	tests := []struct {
+		name    string
+		entries map[DatePath]string
+		pd      DatePath
+		off     bool
+		want    string
+	}{
+		{name: "no previous entry", pd: today},
+		{name: "previous entry all done", pd: today, entries: map[DatePath]string{
+			{2024, 3, 1}: "- [ ] older, but not the latest\n",
+			{2024, 3, 5}: "- [x] one\n  - [X] two\n",
+		}},
+		{name: "nested items", pd: today, entries: map[DatePath]string{
+			{2024, 3, 1}: "- [ ] older\n",
+			{2024, 3, 5}: nested,
+		}, want: "## Carried over\n" +
+			"- [ ] ship the release\n" +
+			"  - [ ] write the notes\n" +
+			"    * [ ] ask Sam about the date\n" +
+			"- [ ] call back\n\n"},
+		{name: "later entries are ignored", pd: today, entries: map[DatePath]string{
+			{2024, 3, 5}: "- [ ] before\n",
+			{2024, 3, 9}: "- [ ] planned ahead\n",
+		}, want: "## Carried over\n- [ ] before\n\n"},
+		{name: "not today", pd: DatePath{2024, 3, 6}, entries: map[DatePath]string{
+			{2024, 3, 5}: "- [ ] before\n",
+		}},
+		{name: "carry_forward off", pd: today, off: true, entries: map[DatePath]string{
+			{2024, 3, 5}: "- [ ] before\n",
+		}},
+	}
+	for _, tt := range tests {
+		cfg := Configuration{Root: t.TempDir(), CarryForward: !tt.off}
+		paths := map[string]string{}
+		for pd, content := range tt.entries {
+			paths[write(cfg.Root, pd, content)] = content
+		}
+		got, err := carriedOver(cfg, &tt.pd)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: carriedOver = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+		for p, content := range paths {
+			if data, err := os.ReadFile(p); err != nil || string(data) != content {
+				t.Errorf("%s: %s was changed to %q, %v", tt.name, p, data, err)
+			}
+		}
+	}
+}
+
+func TestNewEntryCarriesOver(t *testing.T) {
+	testToday(t, 2024, time.March, 7)
+	root := t.TempDir()
+	cfg := Configuration{Root: root, CarryForward: true}
+	testEntries(t, root, DatePath{2024, 3, 6})
+	prev := filepath.Join(root, filepath.FromSlash((&DatePath{2024, 3, 6}).String()))
+	if err := os.WriteFile(prev, []byte("- [ ] open\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := filepath.Join(t.TempDir(), "day.md")
+	if err := os.WriteFile(tmpl, []byte("---\nmood: ok\n---\n## Notes\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pd := DatePath{2024, 3, 7}
+
+	got, err := newTemplater(cfg, tmpl, false).content(&pd)
+	want := renderHeader(&pd) + "---\nmood: ok\n---\n" + "## Carried over\n- [ ] open\n\n" + "## Notes\n"
+	if err != nil || got != want {
+		t.Errorf("content = %q, %v, want the carried items after the front matter: %q", got, err, want)
+	}
+	got, err = newTemplater(cfg, "", false).content(&pd)
+	if want := renderHeader(&pd) + "## Carried over\n- [ ] open\n\n"; err != nil || got != want {
+		t.Errorf("content without a template = %q, %v, want %q", got, err, want)
+	}
+}
@@ -0,0 +1,83 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkHeaders compares the date in each entry's generated header with the
+// date of its path.  With --fix the header is rewritten to match the path;
+// with --fix-by-header the file is moved to the header's date instead.  Both
+// back the entry up to the versions store first, and --dry-run only prints
+// what would be done.  It reports whether any mismatch was left unfixed.
+func checkHeaders(cfg Configuration, params Parameters) (bool, error) {
+	entries, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return false, err
+	}
+	unresolved := false
+	for _, e := range entries {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", e.Path, err)
+		}
+		h, ok := findHeader(data)
+		if !ok || h.Date == nil {
+			fmt.Printf("%s: no header date found\n", e.Path)
+			continue
+		}
+		if *h.Date == e.Date {
+			continue
+		}
+		fmt.Printf("%s: header says %s, path says %s\n", e.Path, h.Date.Iso(), e.Date.Iso())
+
+		switch {
+		case params.Fix:
+			if params.DryRun {
+				fmt.Printf("  would rewrite header to %s\n", e.Date.Iso())
+				continue
+			}
+			_, err := rewriteEntry(cfg, e.Path, rewriteHeader(data, &e.Date), rewriteOptions{Backup: true, KeepModTime: true})
+			if err != nil {
+				return false, fmt.Errorf("failed to rewrite header of %s: %w", e.Path, err)
+			}
+			fmt.Printf("  rewrote header to %s\n", e.Date.Iso())
+		case params.FixByHeader:
+			dest, err := topicEntryPath(cfg, h.Date, e.Topic)
+			if err != nil {
+				return false, err
+			}
+			if _, err := os.Stat(dest); err == nil {
+				fmt.Printf("  not moved: %s already exists\n", dest)
+				unresolved = true
+				continue
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return false, err
+			}
+			if params.DryRun {
+				fmt.Printf("  would move to %s\n", dest)
+				continue
+			}
+			if _, err := backupEntry(cfg, e.Path); err != nil {
+				return false, err
+			}
+			err = makeDir(cfg, filepath.Dir(dest))
+			if err != nil {
+				return false, fmt.Errorf("failed to create directory for %s: %w", dest, err)
+			}
+			err = os.Rename(e.Path, dest)
+			if err != nil {
+				return false, fmt.Errorf("failed to move %s: %w", e.Path, err)
+			}
+			if err := resealMoved(cfg, dest); err != nil {
+				return false, fmt.Errorf("failed to encrypt %s at its new path: %w", dest, err)
+			}
+			fmt.Printf("  moved to %s\n", dest)
+		default:
+			unresolved = true
+		}
+	}
+	return unresolved, nil
+}
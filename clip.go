@@ -0,0 +1,145 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+)
+
+// clipMaxSize is the most clip appends; more is taken for something pasted
+// by mistake, such as a whole file.
+const clipMaxSize = 1 << 20
+
+// pasteCommands are tried in order to read the clipboard, by GOOS; other
+// systems use the "" list.
+var pasteCommands = map[string][][]string{
+	"darwin":  {{"pbpaste"}},
+	"windows": {{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard -Raw"}},
+	"": {
+		{"wl-paste", "--no-newline"},
+		{"xclip", "-selection", "clipboard", "-o"},
+		{"xsel", "--clipboard", "--output"},
+	},
+}
+
+// pasteCommand returns the command line that prints the clipboard:
+// clipboard_command, or the first of pasteCommands found.
+func pasteCommand(cfg Configuration) ([]string, error) {
+	if len(strings.TrimSpace(cfg.ClipboardCommand)) > 0 {
+		argv, err := splitCommandLine(cfg.ClipboardCommand)
+		if err != nil || len(argv) == 0 {
+			return nil, fmt.Errorf("clipboard_command '%s' can't be run", cfg.ClipboardCommand)
+		}
+		return argv, nil
+	}
+	candidates, ok := pasteCommands[runtime.GOOS]
+	if !ok {
+		candidates = pasteCommands[""]
+	}
+	var names []string
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return c, nil
+		}
+		names = append(names, c[0])
+	}
+	return nil, fmt.Errorf("no clipboard command found; install one of %s, or set clipboard_command", strings.Join(names, ", "))
+}
+
+// readClipboard returns the text on the clipboard, refusing more than
+// clipMaxSize bytes and anything that isn't UTF-8 text.
+func readClipboard(cfg Configuration) (string, error) {
+	argv, err := pasteCommand(cfg)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to run %s: %w", argv[0], err)
+	}
+	data, err := io.ReadAll(io.LimitReader(out, clipMaxSize+1))
+	if len(data) > clipMaxSize {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return "", fmt.Errorf("the clipboard holds more than %d bytes; refusing to append it", clipMaxSize)
+	}
+	if werr := cmd.Wait(); err == nil && werr != nil {
+		err = fmt.Errorf("%s failed: %w", argv[0], werr)
+	}
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return "", errors.New("the clipboard doesn't hold text; refusing to append it")
+	}
+	return strings.ReplaceAll(string(data), "\r\n", "\n"), nil
+}
+
+// fence returns a code fence longer than any run of backticks in text, so
+// that the block can't be closed early by what it holds.
+func fence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		run++
+		if run > longest {
+			longest = run
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// runClip appends what is on the clipboard to the entry for the date given,
+// today by default, as a fenced block under a line with the time, creating
+// the entry from its template first as opening it would.  An empty clipboard
+// appends nothing.
+func runClip(cfg Configuration, params Parameters) error {
+	text, err := readClipboard(cfg)
+	if err != nil {
+		return err
+	}
+	text = strings.Trim(text, "\n")
+	if len(strings.TrimSpace(text)) == 0 {
+		fmt.Println("the clipboard is empty; nothing appended")
+		return nil
+	}
+	pd, target, err := resolveEntry(cfg, params)
+	if err != nil {
+		return err
+	}
+	path, _, err := ensureEntryAt(cfg, target, pd, newEntryContent(cfg, params))
+	if err != nil {
+		return err
+	}
+	data, err := readEntry(cfg, path)
+	if err != nil {
+		return err
+	}
+	f := fence(text)
+	addition := fmt.Sprintf("[%s]\n%s\n%s\n%s\n", now().Format("15:04"), f, text, f)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		addition = "\n" + addition
+	}
+	if err := appendToEntry(cfg, path, addition); err != nil {
+		return err
+	}
+	fmt.Printf("appended %d lines from the clipboard to %s\n", strings.Count(text, "\n")+1, displayPath(path))
+	return nil
+}
@@ -0,0 +1,88 @@
+package wm
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Clock tells wm what time it is.  Everything that depends on "now", from
+// resolving today and date keywords to edit-time windows and session
+// markers, asks the package clock rather than calling time.Now, so that it
+// can be fixed to reproduce a report.  Timeouts and durations keep using the
+// real time.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// fixedClock always returns the same instant.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+var clock Clock = realClock{}
+
+// now is the current time according to the package clock.
+func now() time.Time {
+	return clock.Now()
+}
+
+// clockFixed reports whether the clock was fixed with --now, WM_NOW, or
+// WM_FAKE_NOW.
+var clockFixed = false
+
+// invoked is the time the command started by the package clock.  Today and
+// date keywords are resolved against it rather than against now(), so that a
+// command that resolves several dates, or runs across midnight, sees the same
+// day throughout.
+var invoked time.Time
+
+// fakeNowLayouts are the forms --now and WM_NOW are read in, as local time
+// unless an offset is given.
+var fakeNowLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// setClock fixes the clock to flag, the value of --now, or else to WM_NOW,
+// or WM_FAKE_NOW by its older name, when one is set, for scripts and for
+// reproducing bug reports: WM_NOW=2024-03-07T23:59 wm ...  It then notes the
+// time the command started.
+func setClock(flag string) error {
+	name, v := "--now", flag
+	for _, env := range []string{"WM_NOW", "WM_FAKE_NOW"} {
+		if len(v) == 0 {
+			name, v = env, os.Getenv(env)
+		}
+	}
+	if len(v) > 0 {
+		t, err := parseClockTime(v)
+		if err != nil {
+			return fmt.Errorf("%s '%s' is not a time like 2024-03-07T23:59", name, v)
+		}
+		clock, clockFixed = fixedClock(t), true
+	}
+	invoked = now()
+	return nil
+}
+
+// parseClockTime reads v in one of fakeNowLayouts.
+func parseClockTime(v string) (time.Time, error) {
+	var err error
+	for _, layout := range fakeNowLayouts {
+		var t time.Time
+		t, err = time.ParseInLocation(layout, v, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,145 @@
+package wm
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetClock puts back the real clock.
+func resetClock() {
+	clock, clockFixed, invoked = realClock{}, false, time.Time{}
+}
+
+func TestSetClock(t *testing.T) {
+	at := func(layout, v string) time.Time {
+		tm, err := time.ParseInLocation(layout, v, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	tests := []struct {
+		flag, env, older string
+		want             time.Time
+	}{
+		{"2024-03-07T23:59", "", "", at("2006-01-02T15:04", "2024-03-07T23:59")},
+		{"2024-03-07T23:59:30", "", "", at("2006-01-02T15:04:05", "2024-03-07T23:59:30")},
+		{"2024-03-07 08:15", "", "", at("2006-01-02 15:04", "2024-03-07 08:15")},
+		{"2024-03-07", "", "", at("2006-01-02", "2024-03-07")},
+		{"2024-03-07T23:59:00+09:00", "", "", at(time.RFC3339, "2024-03-07T23:59:00+09:00")},
+		{"", "2024-03-08T01:30", "", at("2006-01-02T15:04", "2024-03-08T01:30")},
+		{"", "", "2024-03-09T01:30", at("2006-01-02T15:04", "2024-03-09T01:30")},
+		// --now wins over WM_NOW, and WM_NOW over its older name
+		{"2024-03-07T10:00", "2024-03-08T10:00", "2024-03-09T10:00", at("2006-01-02T15:04", "2024-03-07T10:00")},
+		{"", "2024-03-08T10:00", "2024-03-09T10:00", at("2006-01-02T15:04", "2024-03-08T10:00")},
+	}
+	t.Cleanup(resetClock)
+	for _, tt := range tests {
+		resetClock()
+		t.Setenv("WM_NOW", tt.env)
+		t.Setenv("WM_FAKE_NOW", tt.older)
+		if err := setClock(tt.flag); err != nil {
+			t.Errorf("setClock(%q) with WM_NOW %q, WM_FAKE_NOW %q: %v", tt.flag, tt.env, tt.older, err)
+			continue
+		}
+		if !now().Equal(tt.want) || !invoked.Equal(tt.want) || !clockFixed {
+			t.Errorf("setClock(%q) with WM_NOW %q, WM_FAKE_NOW %q: now %s, invoked %s, fixed %v; want %s",
+				tt.flag, tt.env, tt.older, now(), invoked, clockFixed, tt.want)
+		}
+	}
+
+	resetClock()
+	t.Setenv("WM_NOW", "")
+	t.Setenv("WM_FAKE_NOW", "")
+	before := time.Now()
+	if err := setClock(""); err != nil || clockFixed || invoked.Before(before) {
+		t.Errorf("setClock without an override: %v, fixed %v, invoked %s; want the real time", err, clockFixed, invoked)
+	}
+	for _, tt := range []struct{ flag, env, name string }{
+		{"yesterday", "", "--now"},
+		{"", "07/03/2024", "WM_NOW"},
+	} {
+		resetClock()
+		t.Setenv("WM_NOW", tt.env)
+		if err := setClock(tt.flag); err == nil || !strings.HasPrefix(err.Error(), tt.name+" '") {
+			t.Errorf("setClock(%q) with WM_NOW %q = %v, want an error naming %s", tt.flag, tt.env, err, tt.name)
+		}
+	}
+}
+
+func TestDayTakenOnce(t *testing.T) {
+	testNow(t, time.Date(2024, 3, 7, 23, 59, 59, 0, time.Local), 0, "")
+	invoked = now()
+	clock = fixedClock(time.Date(2024, 3, 8, 0, 0, 1, 0, time.Local))
+	for _, in := range []string{"today", "yesterday", "tomorrow"} {
+		got, err := parseDateString(Configuration{}, in)
+		want := map[string]DatePath{"today": {2024, 3, 7}, "yesterday": {2024, 3, 6}, "tomorrow": {2024, 3, 8}}[in]
+		if err != nil || *got != want {
+			t.Errorf("%s past midnight of a run started before = %v, %v, want %s", in, got, err, want.Iso())
+		}
+	}
+}
+
+func TestDayRolloverHour(t *testing.T) {
+	root := t.TempDir()
+	if err := writeRootMarker(Configuration{Root: root}); err != nil {
+		t.Fatal(err)
+	}
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+	testConfigAppend(t, cfgFile, "day_rollover_hour = 3\n")
+	entry := func(d int) string {
+		return filepath.Join(root, "2024", "3", strconv.Itoa(d)+".txt")
+	}
+	for _, tt := range []struct {
+		now, in string
+		want    int
+	}{
+		{"2024-03-08T00:00", "today", 7},
+		{"2024-03-08T01:30", "today", 7},
+		{"2024-03-08T02:59", "today", 7},
+		{"2024-03-08T03:00", "today", 8},
+		{"2024-03-08T02:59", "yesterday", 6},
+		{"2024-03-08T03:00", "yesterday", 7},
+		{"2024-03-08T02:59", "tomorrow", 8},
+		{"2024-03-08T03:00", "tomorrow", 9},
+		{"2024-03-07T23:30", "today", 7},
+	} {
+		out, stderr, code := runWM(t, root, env, "--now="+tt.now, "--print-path", "--create", tt.in)
+		if want := entry(tt.want) + "\n"; code != exitOK && code != exitCreated || string(out) != want {
+			t.Errorf("%s at %s = %q, %d (%s), want %q", tt.in, tt.now, out, code, stderr, want)
+		}
+	}
+
+	// WM_NOW does the same as --now, which wins over it
+	out, _, _ := runWM(t, root, append(env, "WM_NOW=2024-03-08T01:30"), "--print-path")
+	if want := entry(7) + "\n"; string(out) != want {
+		t.Errorf("WM_NOW=2024-03-08T01:30 wm = %q, want %q", out, want)
+	}
+	out, _, _ = runWM(t, root, append(env, "WM_NOW=2024-03-08T01:30"), "--now=2024-03-08T09:00", "--print-path")
+	if want := entry(8) + "\n"; string(out) != want {
+		t.Errorf("--now with WM_NOW = %q, want %q", out, want)
+	}
+
+	for _, tt := range []struct {
+		now  string
+		want int
+	}{{"2024-03-10T02:30", 9}, {"2024-03-10T03:30", 10}} {
+		if _, stderr, code := runWM(t, root, env, "--now="+tt.now, "append", "late note at "+tt.now); code != exitOK && code != exitCreated {
+			t.Fatalf("append at %s exited %d: %s", tt.now, code, stderr)
+		}
+		data, err := os.ReadFile(entry(tt.want))
+		if err != nil || !strings.Contains(string(data), "late note at "+tt.now) {
+			t.Errorf("append at %s didn't go to 2024-03-%02d: %q, %v", tt.now, tt.want, data, err)
+		}
+	}
+
+	testConfigAppend(t, cfgFile, "day_start_hour = 4\n")
+	if _, stderr, code := runWM(t, root, env, "--now=2024-03-08T09:00", "--print-path"); code != exitFailure || !strings.Contains(string(stderr), "same setting") {
+		t.Errorf("day_start_hour and day_rollover_hour both set: exit %d (%s), want the configuration refused", code, stderr)
+	}
+}
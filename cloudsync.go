@@ -0,0 +1,127 @@
+package wm
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Roots kept in a folder synced by Dropbox, OneDrive, and the like see files
+// locked for a moment while they are uploaded, and files that are only
+// placeholders until they are downloaded.  What can be told about a file
+// depends on the platform, so it is asked of syncFiles, which is a no-op
+// everywhere but Windows.
+
+// syncAttributes tells placeholders and sync locks apart from other files
+// and errors.
+type syncAttributes interface {
+	// onlineOnly reports whether the file at path is a placeholder whose
+	// content is only in the cloud, so reading it would download it or
+	// read nothing.
+	onlineOnly(path string, info fs.FileInfo) bool
+	// sharingViolation reports whether err is another process, such as
+	// the sync client, holding the file open.
+	sharingViolation(err error) bool
+}
+
+// syncRetryDelay is how long to wait before trying a locked file again.
+const syncRetryDelay = 250 * time.Millisecond
+
+// retryLocked runs op, and runs it once more after syncRetryDelay if it
+// failed because the file was locked.
+func retryLocked(op func() error) error {
+	err := op()
+	if err != nil && syncFiles.sharingViolation(err) {
+		time.Sleep(syncRetryDelay)
+		err = op()
+	}
+	return err
+}
+
+// readSynced is os.ReadFile, retried once if the file is locked.
+func readSynced(path string) ([]byte, error) {
+	var data []byte
+	err := retryLocked(func() error {
+		var err error
+		data, err = os.ReadFile(path)
+		return err
+	})
+	return data, err
+}
+
+// skipOnlineOnly leaves out the entries that are online-only placeholders,
+// with a note saying how many there were.
+func skipOnlineOnly(entries []Entry) []Entry {
+	kept := entries[:0:0]
+	skipped := 0
+	for _, e := range entries {
+		if info, err := os.Lstat(e.Path); err == nil && syncFiles.onlineOnly(e.Path, info) {
+			skipped++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	if skipped > 0 {
+		log.Printf(":::note::: online-only, skipped (%d files)", skipped)
+	}
+	return kept
+}
+
+// syncFolderNames are the folders sync clients keep their files in.  A name
+// ending in "*" matches any name starting with the rest, as in
+// "OneDrive - Contoso".
+var syncFolderNames = []string{
+	"Dropbox", "Dropbox (*",
+	"OneDrive", "OneDrive - *",
+	"Google Drive", "GoogleDrive", "My Drive",
+	"iCloud Drive", "iCloudDrive", "com~apple~CloudDocs",
+	"Box", "Box Sync",
+	"pCloudDrive", "MEGA", "Nextcloud", "ownCloud",
+}
+
+// syncFolder returns the sync folder the root is in and the client it
+// belongs to, or "" when it isn't in one.  A .dropbox marker next to a
+// folder with another name counts too.
+func syncFolder(root string) (dir, client string) {
+	p, err := filepath.Abs(root)
+	if err != nil {
+		return "", ""
+	}
+	if real, err := filepath.EvalSymlinks(p); err == nil {
+		p = real
+	}
+	for ; ; p = filepath.Dir(p) {
+		name := filepath.Base(p)
+		for _, n := range syncFolderNames {
+			if name == n || (strings.HasSuffix(n, "*") && strings.HasPrefix(name, strings.TrimSuffix(n, "*"))) {
+				return p, strings.TrimRight(strings.TrimSuffix(n, "*"), " -(")
+			}
+		}
+		if _, err := os.Stat(filepath.Join(p, ".dropbox")); err == nil {
+			return p, "Dropbox"
+		}
+		if filepath.Dir(p) == p {
+			return "", ""
+		}
+	}
+}
+
+func init() {
+	registerDoctorCheck(doctorCheck{
+		Name: "sync folder",
+		Check: func(in doctorInput) doctorResult {
+			dir, client := syncFolder(in.Cfg.Root)
+			if len(dir) == 0 {
+				return doctorOK("the root isn't in a synced folder")
+			}
+			return doctorResult{doctorWarn, fmt.Sprintf("the root is inside the %s folder %s.  Entries may be locked for a moment while "+
+				"they upload, which wm retries once, online-only files are skipped by search and list until they "+
+				"are downloaded, and editing the same entry on two machines before both have synced leaves a "+
+				"conflicted copy next to it", client, dir), "keep the root available offline to avoid the first two"}
+		},
+	})
+}
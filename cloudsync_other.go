@@ -0,0 +1,15 @@
+//go:build !windows
+
+package wm
+
+import "io/fs"
+
+// noSyncAttributes is syncAttributes where the platform exposes neither
+// placeholders nor sharing locks, and ordinary reads already succeed.
+type noSyncAttributes struct{}
+
+func (noSyncAttributes) onlineOnly(string, fs.FileInfo) bool { return false }
+
+func (noSyncAttributes) sharingViolation(error) bool { return false }
+
+var syncFiles syncAttributes = noSyncAttributes{}
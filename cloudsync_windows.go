@@ -0,0 +1,42 @@
+//go:build windows
+
+package wm
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// File attributes of cloud placeholders, from winnt.h; syscall only has the
+// older ones.
+const (
+	fileAttributeOffline            = 0x00001000
+	fileAttributeRecallOnOpen       = 0x00040000
+	fileAttributeRecallOnDataAccess = 0x00400000
+
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// windowsSyncAttributes reads the placeholder flags sync clients set through
+// the Cloud Files API, and older clients' zero-size reparse points.
+type windowsSyncAttributes struct{}
+
+func (windowsSyncAttributes) onlineOnly(_ string, info fs.FileInfo) bool {
+	d, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return false
+	}
+	a := d.FileAttributes
+	if a&(fileAttributeOffline|fileAttributeRecallOnOpen|fileAttributeRecallOnDataAccess) != 0 {
+		return true
+	}
+	return a&syscall.FILE_ATTRIBUTE_REPARSE_POINT != 0 && info.Size() == 0
+}
+
+func (windowsSyncAttributes) sharingViolation(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation)
+}
+
+var syncFiles syncAttributes = windowsSyncAttributes{}
@@ -0,0 +1,20 @@
+// Command wm opens, searches, and maintains a working-memory log; run it
+// with --help for everything it does.  The commands are those of package
+// wm; this hands them the command line, prints how they failed, and exits
+// with their status.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/tesla2013/wm"
+)
+
+func main() {
+	err := wm.Main(os.Args[1:])
+	if err != nil && len(err.Error()) > 0 {
+		log.Println(err)
+	}
+	os.Exit(wm.ExitStatus(err))
+}
@@ -0,0 +1,326 @@
+package wm
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Shell completion is generated from the usage text itself, the patterns
+// under "Usage:", so that a command or option added there can't be missing
+// from completion.  Date arguments complete to the date keywords and to the
+// dates of recent entries, which the scripts get from "wm list
+// --dates-only" as they run.
+
+// cliCommand is a command of the grammar: its name, "" for opening a date,
+// the words that may follow it, such as "set" after "config", its options,
+// and whether it takes dates.
+type cliCommand struct {
+	Name  string
+	Words []string
+	Flags []string
+	Dates bool
+}
+
+var (
+	usageWordRe = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+	usageFlagRe = regexp.MustCompile(`^--?[A-Za-z0-9][A-Za-z0-9-]*`)
+)
+
+// usagePatterns returns the patterns of the usage text without the leading
+// "wm", continuation lines joined to the pattern they continue.
+func usagePatterns(text string) []string {
+	var patterns []string
+	in := false
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case line == "Usage:":
+			in = true
+		case !in:
+		case len(strings.TrimSpace(line)) == 0:
+			return patterns
+		case strings.HasPrefix(line, "  wm"):
+			patterns = append(patterns, strings.TrimSpace(strings.TrimPrefix(line, "  wm")))
+		case len(patterns) > 0:
+			patterns[len(patterns)-1] += " " + strings.TrimSpace(line)
+		}
+	}
+	return patterns
+}
+
+// cliGrammar reads the commands of the usage text, merging the patterns of
+// each, in order of name.
+func cliGrammar(text string) []cliCommand {
+	byName := map[string]*cliCommand{}
+	seen := map[string]map[string]bool{}
+	add := func(c *cliCommand, list *[]string, s string) {
+		if !seen[c.Name][s] {
+			seen[c.Name][s] = true
+			*list = append(*list, s)
+		}
+	}
+	for _, p := range usagePatterns(text) {
+		tokens := strings.Fields(strings.NewReplacer("[", " ", "]", " ", "(", " ", ")", " ", "|", " ", "...", " ").Replace(p))
+		name := ""
+		if len(tokens) > 0 && usageWordRe.MatchString(tokens[0]) {
+			name, tokens = tokens[0], tokens[1:]
+		}
+		if name == "" && len(tokens) > 0 && (tokens[0] == "-h" || tokens[0] == "--version") {
+			continue
+		}
+		c, ok := byName[name]
+		if !ok {
+			c = &cliCommand{Name: name}
+			byName[name], seen[name] = c, map[string]bool{}
+		}
+		for _, t := range tokens {
+			switch {
+			case t == "<date>":
+				c.Dates = true
+			case t == "<shell>":
+				for _, shell := range completionShellNames() {
+					add(c, &c.Words, shell)
+				}
+			case usageWordRe.MatchString(t):
+				add(c, &c.Words, t)
+			case usageFlagRe.MatchString(t):
+				if t != "--" {
+					add(c, &c.Flags, usageFlagRe.FindString(t))
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	commands := make([]cliCommand, len(names))
+	for i, name := range names {
+		commands[i] = *byName[name]
+	}
+	return commands
+}
+
+// completionKeywords are the date keywords completed wherever a date may be
+// given, the built-in and the configured ones.
+func completionKeywords() []string {
+	var words []string
+	for name := range dateKeywords {
+		words = append(words, name)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// completionWords is what completes after the command c: the words that may
+// follow it and its options, and for opening a date, the command names.
+func completionWords(c cliCommand, commands []cliCommand) []string {
+	var words []string
+	if c.Name == "" {
+		for _, other := range commands {
+			if len(other.Name) > 0 {
+				words = append(words, other.Name)
+			}
+		}
+	}
+	words = append(words, c.Words...)
+	return append(words, c.Flags...)
+}
+
+// completionShells are the shells "completion" writes scripts for.
+var completionShells = map[string]func(io.Writer, []cliCommand, []string) error{
+	"bash":       bashCompletion,
+	"zsh":        zshCompletion,
+	"fish":       fishCompletion,
+	"powershell": powershellCompletion,
+}
+
+func completionShellNames() []string {
+	names := make([]string, 0, len(completionShells))
+	for name := range completionShells {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runCompletion prints the completion script for the shell given.
+func runCompletion(w io.Writer, shell string) error {
+	gen, ok := completionShells[strings.ToLower(shell)]
+	if !ok {
+		return fmt.Errorf("unknown shell '%s', expected bash, zsh, fish, or powershell", shell)
+	}
+	return gen(w, cliGrammar(usage), completionKeywords())
+}
+
+func bashCompletion(w io.Writer, commands []cliCommand, keywords []string) error {
+	var b strings.Builder
+	b.WriteString(`# wm completion for bash; add to ~/.bashrc:
+#   source <(wm completion bash)
+_wm() {
+    local cur=${COMP_WORDS[COMP_CWORD]} cmd="" w words dates=0
+    for w in "${COMP_WORDS[@]:1:COMP_CWORD-1}"; do
+        case $w in -*) ;; *) cmd=$w; break ;; esac
+    done
+    case $cmd in
+`)
+	for _, c := range commands {
+		if c.Name == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "        %s) words=%q", c.Name, strings.Join(completionWords(c, commands), " "))
+		if c.Dates {
+			b.WriteString("; dates=1")
+		}
+		b.WriteString(" ;;\n")
+	}
+	for _, c := range commands {
+		if c.Name == "" {
+			fmt.Fprintf(&b, "        *) words=%q; dates=1 ;;\n", strings.Join(completionWords(c, commands), " "))
+		}
+	}
+	fmt.Fprintf(&b, `    esac
+    if [[ $dates == 1 && $cur != -* ]]; then
+        words="$words %s $(wm list --dates-only 2>/dev/null)"
+    fi
+    COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -F _wm wm
+`, strings.Join(keywords, " "))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func zshCompletion(w io.Writer, commands []cliCommand, keywords []string) error {
+	var b strings.Builder
+	b.WriteString(`#compdef wm
+# wm completion for zsh; add to ~/.zshrc:
+#   source <(wm completion zsh)
+_wm() {
+    local cmd="" w dates=0
+    local -a candidates
+    for w in ${words[2,CURRENT-1]}; do
+        [[ $w == -* ]] || { cmd=$w; break }
+    done
+    case $cmd in
+`)
+	for _, c := range commands {
+		if c.Name == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "        %s) candidates=(%s)", c.Name, strings.Join(completionWords(c, commands), " "))
+		if c.Dates {
+			b.WriteString("; dates=1")
+		}
+		b.WriteString(" ;;\n")
+	}
+	for _, c := range commands {
+		if c.Name == "" {
+			fmt.Fprintf(&b, "        *) candidates=(%s); dates=1 ;;\n", strings.Join(completionWords(c, commands), " "))
+		}
+	}
+	fmt.Fprintf(&b, `    esac
+    if [[ $dates == 1 && $PREFIX != -* ]]; then
+        candidates+=(%s ${(f)"$(wm list --dates-only 2>/dev/null)"})
+    fi
+    compadd -- $candidates
+}
+compdef _wm wm
+`, strings.Join(keywords, " "))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fishFlag returns the options of complete that name flag.
+func fishFlag(flag string) string {
+	if strings.HasPrefix(flag, "--") {
+		return "-l " + flag[2:]
+	}
+	if len(flag) == 2 {
+		return "-s " + flag[1:]
+	}
+	return "-o " + flag[1:]
+}
+
+func fishCompletion(w io.Writer, commands []cliCommand, keywords []string) error {
+	var b strings.Builder
+	var names []string
+	for _, c := range commands {
+		if len(c.Name) > 0 {
+			names = append(names, c.Name)
+		}
+	}
+	dates := fmt.Sprintf("'%s (wm list --dates-only 2>/dev/null)'", strings.Join(keywords, " "))
+	b.WriteString("# wm completion for fish; save as ~/.config/fish/completions/wm.fish:\n")
+	b.WriteString("#   wm completion fish > ~/.config/fish/completions/wm.fish\n")
+	b.WriteString("complete -c wm -f\n")
+	fmt.Fprintf(&b, "set -l wm_commands %s\n", strings.Join(names, " "))
+	for _, c := range commands {
+		cond := fmt.Sprintf("'__fish_seen_subcommand_from %s'", c.Name)
+		if c.Name == "" {
+			cond = `"not __fish_seen_subcommand_from $wm_commands"`
+			b.WriteString("complete -c wm -n '__fish_use_subcommand' -a \"$wm_commands\"\n")
+		}
+		if len(c.Words) > 0 {
+			fmt.Fprintf(&b, "complete -c wm -n %s -a '%s'\n", cond, strings.Join(c.Words, " "))
+		}
+		for _, f := range c.Flags {
+			fmt.Fprintf(&b, "complete -c wm -n %s %s\n", cond, fishFlag(f))
+		}
+		if c.Dates {
+			fmt.Fprintf(&b, "complete -c wm -n %s -a %s\n", cond, dates)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// psList returns words as a PowerShell array literal.
+func psList(words []string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = "'" + strings.ReplaceAll(w, "'", "''") + "'"
+	}
+	return "@(" + strings.Join(quoted, ", ") + ")"
+}
+
+func powershellCompletion(w io.Writer, commands []cliCommand, keywords []string) error {
+	var b strings.Builder
+	b.WriteString(`# wm completion for PowerShell; add to $PROFILE:
+#   wm completion powershell | Out-String | Invoke-Expression
+Register-ArgumentCompleter -Native -CommandName wm -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $commands = @{
+`)
+	var dated []string
+	for _, c := range commands {
+		fmt.Fprintf(&b, "        '%s' = %s\n", c.Name, psList(completionWords(c, commands)))
+		if c.Dates {
+			dated = append(dated, c.Name)
+		}
+	}
+	fmt.Fprintf(&b, `    }
+    $dated = %s
+    $cmd = ''
+    foreach ($element in $commandAst.CommandElements | Select-Object -Skip 1) {
+        $w = $element.ToString()
+        if ($element.Extent.StartOffset -ge $cursorPosition - $wordToComplete.Length) { break }
+        if (-not $w.StartsWith('-')) { $cmd = $w; break }
+    }
+    if (-not $commands.ContainsKey($cmd)) { $cmd = '' }
+    $candidates = $commands[$cmd]
+    if ($dated -contains $cmd -and -not $wordToComplete.StartsWith('-')) {
+        $candidates += %s
+        $candidates += @(wm list --dates-only 2>$null)
+    }
+    $candidates | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`, psList(dated), psList(keywords))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
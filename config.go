@@ -0,0 +1,335 @@
+package wm
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	defaultConfigFile = "wm.toml"
+	userConfigName    = "config.toml"
+	localConfigFile   = ".wm.toml"
+)
+
+// takeFlag removes every occurrence of flag from args, reporting whether it
+// was there.  It is used for options that apply to every command.
+func takeFlag(args []string, flag string) ([]string, bool) {
+	var rest []string
+	found := false
+	for _, a := range args {
+		if a == flag {
+			found = true
+			continue
+		}
+		rest = append(rest, a)
+	}
+	return rest, found
+}
+
+// takeValueFlag removes flag and its value, given as "flag=value" or as the
+// next argument, from args and returns the value of its last occurrence.
+func takeValueFlag(args []string, flag string) ([]string, string) {
+	var rest []string
+	value := ""
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case strings.HasPrefix(a, flag+"="):
+			value = a[len(flag)+1:]
+		case a == flag && i+1 < len(args):
+			value = args[i+1]
+			i++
+		default:
+			rest = append(rest, a)
+		}
+	}
+	return rest, value
+}
+
+var negativeDaysRe = regexp.MustCompile(`^-\d+$`)
+
+// relativeDayArgs rewrites arguments such as "-3", which docopt would take
+// for options, into the equivalent "today-3".  Only the dates "wm" opens
+// are rewritten: after a command, "-0" and the like are that command's
+// flags.
+func relativeDayArgs(args []string) []string {
+	if len(args) > 0 && usageCommands()[args[0]] {
+		return args
+	}
+	out := make([]string, len(args))
+	for i, a := range args {
+		if negativeDaysRe.MatchString(a) {
+			a = "today" + a
+		}
+		out[i] = a
+	}
+	return out
+}
+
+// usageCommands returns the command words the usage patterns begin with,
+// such as "search" in "wm search [<term>...]".
+func usageCommands() map[string]bool {
+	commands := map[string]bool{}
+	for _, line := range strings.Split(usage, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "wm" || !strings.HasPrefix(line, "  wm ") {
+			continue
+		}
+		if c := fields[1][0]; c >= 'a' && c <= 'z' {
+			commands[fields[1]] = true
+		}
+	}
+	return commands
+}
+
+// findLocalConfig looks for a .wm.toml in dir and each of its parents up to
+// the filesystem root, like git looks for .git.
+func findLocalConfig(dir string) (string, bool) {
+	for {
+		p := filepath.Join(dir, localConfigFile)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// configSource is the configuration file in use and why it was chosen.
+// Hint is a line to note when it is one kept for older versions.
+type configSource struct {
+	Path   string
+	Reason string
+	Local  bool
+	Hint   string
+}
+
+// userConfigDir is the directory of the user configuration:
+// $XDG_CONFIG_HOME/wm when that is set, %APPDATA%\wm on Windows, and
+// ~/.config/wm elsewhere, macOS included.
+func userConfigDir() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "wm"), nil
+	}
+	if runtime.GOOS == "windows" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "wm"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "wm"), nil
+}
+
+// userConfigFile is where the user configuration lives: config.toml in
+// userConfigDir.  Where only the file older versions used exists, wm/wm.toml
+// in the platform's configuration directory, that one is.
+func userConfigFile() string {
+	dir, err := userConfigDir()
+	if err != nil {
+		return defaultConfigFile
+	}
+	p := filepath.Join(dir, userConfigName)
+	if _, err := os.Stat(p); err == nil {
+		return p
+	}
+	if old, err := os.UserConfigDir(); err == nil {
+		if _, err := os.Stat(filepath.Join(old, "wm", defaultConfigFile)); err == nil {
+			return filepath.Join(old, "wm", defaultConfigFile)
+		}
+	}
+	return p
+}
+
+// portableConfigFile is the wm.toml next to the executable, for copies of wm
+// carried around with their configuration, or "" if there is none.
+func portableConfigFile() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	p := filepath.Join(filepath.Dir(exe), defaultConfigFile)
+	if info, err := os.Stat(p); err != nil || info.IsDir() {
+		return ""
+	}
+	return p
+}
+
+// findConfig picks the configuration file: $WMCFG if set, otherwise a
+// .wm.toml found upward from the current directory unless noLocal is set,
+// otherwise the user configuration.  While there is no user configuration, a
+// wm.toml in the current directory, the old default, is still used with a
+// hint to migrate it, and then one next to the executable.  The user
+// configuration is created on first use where neither is.
+func findConfig(noLocal bool) configSource {
+	if env := os.Getenv("WMCFG"); len(env) > 0 {
+		return configSource{Path: env, Reason: "set by $WMCFG"}
+	}
+	cwd, err := os.Getwd()
+	if !noLocal && err == nil {
+		if p, ok := findLocalConfig(cwd); ok {
+			return configSource{Path: p, Local: true, Reason: fmt.Sprintf("found %s in %s, searching upward from %s", localConfigFile, filepath.Dir(p), cwd)}
+		}
+	}
+	reason := "the user configuration"
+	if noLocal {
+		reason += "; --no-local disabled looking for " + localConfigFile
+	} else {
+		reason += "; no " + localConfigFile + " above the current directory"
+	}
+	user := userConfigFile()
+	if _, err := os.Stat(user); err != nil {
+		if _, err := os.Stat(defaultConfigFile); err == nil {
+			reason = fmt.Sprintf("%s in the current directory, the old default; run \"wm config migrate\" to move it to %s", defaultConfigFile, user)
+			return configSource{Path: defaultConfigFile, Reason: reason, Hint: "using " + reason}
+		}
+		if p := portableConfigFile(); len(p) > 0 {
+			return configSource{Path: p, Reason: "next to the executable, as there is no user configuration"}
+		}
+	}
+	return configSource{Path: user, Reason: reason}
+}
+
+// configStub is a configuration file that "config migrate" left behind at an
+// old location.
+type configStub struct {
+	MovedTo string `toml:"moved_to"`
+}
+
+// followConfigStub reads the configuration a stub points to instead of the
+// stub, with a deprecation note so that scripts still naming the old file
+// get updated.
+func followConfigStub(src configSource) configSource {
+	data, err := os.ReadFile(src.Path)
+	if err != nil {
+		return src
+	}
+	var stub configStub
+	if _, err := toml.Decode(string(data), &stub); err != nil || len(stub.MovedTo) == 0 {
+		return src
+	}
+	log.Printf(":::note::: %s has moved to %s; reading that instead.  Reading the old location is deprecated, so update whatever points at it.", src.Path, stub.MovedTo)
+	return configSource{Path: stub.MovedTo, Reason: fmt.Sprintf("%s, which %s points to", src.Reason, src.Path)}
+}
+
+// expandPath expands a leading "~" in p to the home directory, as in "~" and
+// "~/logs", and gives the path the platform's separators.  A "~\logs" written
+// on Windows is read the same everywhere.  Other paths only have their
+// separators converted.
+func expandPath(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`) {
+		if hd, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(hd, filepath.FromSlash(strings.ReplaceAll(p[1:], `\`, "/")))
+		}
+	}
+	return filepath.FromSlash(p)
+}
+
+// resolveLocalRoot makes a relative root in a discovered .wm.toml relative to
+// the directory holding it, so the log can live inside the repository; root
+// = "." is that directory itself.  The file is shared between platforms, so
+// either separator is read as one.
+func resolveLocalRoot(cfg *Configuration, src configSource) {
+	if !src.Local || len(cfg.Root) == 0 || filepath.IsAbs(cfg.Root) || strings.HasPrefix(cfg.Root, "~") {
+		return
+	}
+	root := filepath.FromSlash(strings.ReplaceAll(cfg.Root, `\`, "/"))
+	cfg.Root = filepath.Join(filepath.Dir(src.Path), root)
+}
+
+// relativeBase is what --relative makes printed paths relative to, or ""
+// when it isn't given.
+var relativeBase string
+
+// setRelativeBase makes printed paths relative to the directory of a
+// discovered .wm.toml, typically the repository the log lives in, or to the
+// root otherwise.
+func setRelativeBase(cfg Configuration, src configSource) {
+	base := cfg.Root
+	if src.Local {
+		base = filepath.Dir(src.Path)
+	}
+	if abs, err := filepath.Abs(base); err == nil {
+		base = abs
+	}
+	relativeBase = base
+}
+
+// displayPath is p as printed for the user: unchanged, or with --relative
+// relative to relativeBase and with forward slashes, so that output
+// committed to a repository reads the same on every platform and names no
+// home directory.  Paths that can't be made relative, such as ones on
+// another volume, are printed as they are.
+func displayPath(p string) string {
+	if len(relativeBase) == 0 {
+		return p
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	rel, err := filepath.Rel(relativeBase, abs)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// runConfigShow prints which configuration file is in use, why, and the
+// settings that decide where entries go.
+func runConfigShow(cfg Configuration, src configSource) {
+	abs, err := filepath.Abs(src.Path)
+	if err != nil {
+		abs = src.Path
+	}
+	if _, err := os.Stat(src.Path); err != nil {
+		abs += " (does not exist yet)"
+	}
+	fmt.Println("config:", abs)
+	fmt.Println("source:", src.Reason)
+	fmt.Println("root:  ", cfg.Root)
+	fmt.Println("editor:", editorSetting(cfg))
+}
+
+// runConfigPath prints the path of the configuration file in use, for
+// scripts and for telling which of several is read.
+func runConfigPath(src configSource) {
+	abs, err := filepath.Abs(src.Path)
+	if err != nil {
+		abs = src.Path
+	}
+	fmt.Println(abs)
+}
+
+// configEditPath returns the absolute path of the configuration file for
+// the editor to open, after checking that it is a regular file, so that
+// neither a directory nor a name the editor could take for an option is
+// handed to it.
+func configEditPath(cfgFile string) (string, error) {
+	abs, err := filepath.Abs(cfgFile)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s isn't a regular file", abs)
+	}
+	return abs, nil
+}
@@ -0,0 +1,219 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docopt/docopt-go"
+)
+
+func TestRelativeDayArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-3"}, []string{"today-3"}},
+		{[]string{"-1", "-2"}, []string{"today-1", "today-2"}},
+		{[]string{"-3", "--create"}, []string{"today-3", "--create"}},
+		{[]string{"search", "-l", "-0", "hello"}, []string{"search", "-l", "-0", "hello"}},
+		{[]string{"search", "-0"}, []string{"search", "-0"}},
+		{[]string{"info", "-3"}, []string{"info", "-3"}},
+		{[]string{"yesterday"}, []string{"yesterday"}},
+		{nil, []string{}},
+	}
+	for _, tt := range tests {
+		got := relativeDayArgs(tt.args)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("relativeDayArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRelativeDayArgsParse(t *testing.T) {
+	bind := func(args ...string) Parameters {
+		t.Helper()
+		parser := &docopt.Parser{HelpHandler: docopt.NoHelpHandler}
+		opts, err := parser.ParseArgs(usage, relativeDayArgs(args), "")
+		if err != nil {
+			t.Fatalf("parsing %q: %v", args, err)
+		}
+		var params Parameters
+		if err := opts.Bind(&params); err != nil {
+			t.Fatalf("binding %q: %v", args, err)
+		}
+		return params
+	}
+
+	params := bind("-3")
+	if !reflect.DeepEqual(params.DateWords, []string{"today-3"}) {
+		t.Errorf("wm -3: DateWords = %q, want [today-3]", params.DateWords)
+	}
+
+	params = bind("search", "-l", "-0", "hello")
+	if !params.Search || !params.Print0 || !reflect.DeepEqual(params.Term, []string{"hello"}) {
+		t.Errorf("wm search -l -0 hello: Search = %v, Print0 = %v, Term = %q", params.Search, params.Print0, params.Term)
+	}
+}
+
+func TestTagsUsageParse(t *testing.T) {
+	bind := func(args ...string) Parameters {
+		t.Helper()
+		parser := &docopt.Parser{HelpHandler: docopt.NoHelpHandler}
+		opts, err := parser.ParseArgs(usage, args, "")
+		if err != nil {
+			t.Fatalf("parsing %q: %v", args, err)
+		}
+		var params Parameters
+		if err := opts.Bind(&params); err != nil {
+			t.Fatalf("binding %q: %v", args, err)
+		}
+		return params
+	}
+
+	// the list form takes a range, so it comes after the subcommands
+	params := bind("tags", "rename", "a", "b")
+	if !params.Tags || !params.Rename || params.Old != "a" || params.New != "b" {
+		t.Errorf("wm tags rename a b: Tags = %v, Rename = %v, Old = %q, New = %q", params.Tags, params.Rename, params.Old, params.New)
+	}
+	params = bind("tags", "merge", "a", "b", "--into=c")
+	if !params.Tags || !params.Merge || !reflect.DeepEqual(params.Tag, []string{"a", "b"}) || params.Into != "c" {
+		t.Errorf("wm tags merge a b --into=c: Tags = %v, Merge = %v, Tag = %q, Into = %q", params.Tags, params.Merge, params.Tag, params.Into)
+	}
+	params = bind("tags", "2024-03-01..2024-03-07")
+	if !params.Tags || params.Rename || params.Merge {
+		t.Errorf("wm tags <range>: Tags = %v, Rename = %v, Merge = %v", params.Tags, params.Rename, params.Merge)
+	}
+}
+
+// testConfigFile writes content as a configuration file in a new
+// directory, returning its path.
+func testConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "wm.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGetConfigCreatesMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "wm.toml")
+	cfg, err := GetConfig(path)
+	if err != nil {
+		t.Fatalf("GetConfig of a missing file: %v", err)
+	}
+	assertEntry(t, path, defaultConfig)
+	if cfg.Root != "~/.wm/logs" {
+		t.Errorf("root = %q, want the default", cfg.Root)
+	}
+}
+
+func TestGetConfigMalformed(t *testing.T) {
+	path := testConfigFile(t, "root = \"/tmp/wm\"\ncontext_lines = [\n")
+	_, err := GetConfig(path)
+	if err == nil {
+		t.Fatal("GetConfig of malformed TOML succeeded, want an error")
+	}
+	if errors.Is(err, errInvalidConfig) || !strings.Contains(err.Error(), path) {
+		t.Errorf("GetConfig = %v, want a decoding error naming %s", err, path)
+	}
+}
+
+func TestGetConfigValidation(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{`root = ""`, `"root" must be set`},
+		{"root = \"/tmp/wm\"\neditor = \"vim 'unclosed\"", `"editor" can't be read as a command line`},
+		{"root = \"/tmp/wm\"\ncontextSize = -5", `"contextSize" must be >= 0, got -5`},
+		{"root = \"/tmp/wm\"\ncontext_lines = -1", `"context_lines" must be >= 0, got -1`},
+		{"root = \"/tmp/wm\"\nbackups = -2", `"backups" must be >= 0, got -2`},
+		{"root = \"/tmp/wm\"\nencrypt = true", `"encrypt" needs "passphrase_command"`},
+	}
+	for _, tt := range tests {
+		_, err := GetConfig(testConfigFile(t, tt.content))
+		if !errors.Is(err, errInvalidConfig) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("GetConfig(%q) = %v, want an invalid configuration saying %s", tt.content, err, tt.want)
+		}
+	}
+
+	// every problem is reported at once
+	_, err := GetConfig(testConfigFile(t, "root = \"\"\nbackups = -2\n"))
+	if err == nil || !strings.Contains(err.Error(), `"root"`) || !strings.Contains(err.Error(), `"backups"`) {
+		t.Errorf("GetConfig with two problems = %v, want both named", err)
+	}
+}
+
+func TestGetConfigUnknownKeys(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	path := testConfigFile(t, "root = \"/tmp/wm\"\nedtor = \"vim\"\n")
+	if _, err := GetConfig(path); err != nil {
+		t.Fatalf("GetConfig with an unknown key: %v", err)
+	}
+	if !strings.Contains(logged.String(), `unknown keys`) || !strings.Contains(logged.String(), `"edtor"`) {
+		t.Errorf("logged %q, want a note naming edtor", logged.String())
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	sub := filepath.Join(home, "sub", "dir")
+	for _, tt := range []struct{ in, want string }{
+		{"~", home},
+		{"~/", home},
+		{"~/sub/dir", sub},
+		{`~\sub\dir`, sub},
+		{`~/sub\dir`, sub},
+		{"~/sub/dir/", sub},
+		{"/var/wm/logs", filepath.FromSlash("/var/wm/logs")},
+		{"logs/work", filepath.FromSlash("logs/work")},
+		{"~other/logs", filepath.FromSlash("~other/logs")},
+		{"", ""},
+	} {
+		if got := expandPath(tt.in); got != tt.want {
+			t.Errorf("expandPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTildeRootEverywhere(t *testing.T) {
+	cfgFile, env := testHome(t, "")
+	env = append(env, "WMCFG="+cfgFile, "WM_NOW=2024-03-10T09:00")
+	home := filepath.Dir(cfgFile)
+	if err := os.WriteFile(cfgFile, []byte("root = '~/logs'\neditor = 'true'\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(home, "logs")
+	testEntryBody(t, Configuration{Root: root}, DatePath{2024, 3, 7}, "met Ann about the parser\n")
+	if err := writeRootMarker(Configuration{Root: root}); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, stderr, code := runWM(t, home, env, "list", "--dates-only", "2024-03-01", "2024-03-31"); code != exitOK || string(out) != "2024-03-07\n" {
+		t.Errorf("list = %q, %d (%s), want the entry under ~/logs", out, code, stderr)
+	}
+	want := filepath.Join(root, "2024", "3", "7.txt") + "\n"
+	if out, stderr, code := runWM(t, home, env, "search", "-l", "parser"); code != exitOK || string(out) != want {
+		t.Errorf("search -l = %q, %d (%s), want %q", out, code, stderr, want)
+	}
+	if _, stderr, code := runWM(t, home, env, "append", "later"); code != exitOK && code != exitCreated {
+		t.Fatalf("append exited %d: %s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(root, "2024", "3", "10.txt")); err != nil {
+		t.Errorf("append didn't create the entry under ~/logs: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, "~")); err == nil {
+		t.Error(`a literal "~" directory was created`)
+	}
+}
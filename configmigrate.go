@@ -0,0 +1,259 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// legacyConfig is a configuration file found at an old default location.
+type legacyConfig struct {
+	Path    string
+	ModTime time.Time
+	Data    []byte
+	Config  Configuration
+}
+
+// legacyConfigDirs are the directories wm used to read wm.toml from: the
+// current directory, the home directory, and the executable's directory.
+func legacyConfigDirs() []string {
+	var dirs []string
+	if cwd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, cwd)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, home)
+	}
+	if exe, err := os.Executable(); err == nil {
+		dirs = append(dirs, filepath.Dir(exe))
+	}
+	return dirs
+}
+
+// findLegacyConfigs returns the wm.toml files in dirs, oldest first, leaving
+// out target, stubs, and files that don't decode, which are noted.
+func findLegacyConfigs(dirs []string, target string) ([]legacyConfig, error) {
+	seen := map[string]bool{}
+	if abs, err := filepath.Abs(target); err == nil {
+		seen[abs] = true
+	}
+	var found []legacyConfig
+	for _, dir := range dirs {
+		p, err := filepath.Abs(filepath.Join(dir, defaultConfigFile))
+		if err != nil || seen[p] {
+			continue
+		}
+		seen[p] = true
+		info, err := os.Stat(p)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var stub configStub
+		if _, err := toml.Decode(string(data), &stub); err == nil && len(stub.MovedTo) > 0 {
+			continue
+		}
+		c := legacyConfig{Path: p, ModTime: info.ModTime(), Data: data}
+		if _, err := toml.Decode(string(data), &c.Config); err != nil {
+			log.Printf(":::note::: skipping %s, which isn't valid TOML: %v", p, err)
+			continue
+		}
+		found = append(found, c)
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].ModTime.Before(found[j].ModTime) })
+	return found, nil
+}
+
+// summary describes a configuration in one line for choosing between them.
+func (c legacyConfig) summary() string {
+	keys := 0
+	for _, l := range scanTOML(c.Data) {
+		if len(l.Key) > 0 {
+			keys++
+		}
+	}
+	root := c.Config.Root
+	if len(root) == 0 {
+		root = "(unset)"
+	}
+	return fmt.Sprintf("root %s, editor %s, %d settings", root, c.Config.Editor, keys)
+}
+
+// tomlValues maps every key of data, as "table.key" or "key", to the text of
+// its value.
+func tomlValues(data []byte) (map[string]string, []tomlLine) {
+	values := map[string]string{}
+	var keyed []tomlLine
+	for _, l := range scanTOML(data) {
+		if len(l.Key) == 0 {
+			continue
+		}
+		values[tomlKeyName(l)] = strings.TrimSpace(string(data[l.ValStart:l.ValEnd]))
+		keyed = append(keyed, l)
+	}
+	return values, keyed
+}
+
+func tomlKeyName(l tomlLine) string {
+	if len(l.Table) == 0 {
+		return l.Key
+	}
+	return l.Table + "." + l.Key
+}
+
+// mergeConflict is a key set differently by two of the merged files.
+type mergeConflict struct {
+	Key     string
+	Kept    string
+	KeptIn  string
+	Dropped string
+	From    string
+}
+
+// mergeConfigs merges configs, given oldest first, into the text of the
+// newest: keys only older files set are added, and where files disagree the
+// newest file's value wins and the others are reported.
+func mergeConfigs(configs []legacyConfig) ([]byte, []mergeConflict) {
+	newest := configs[len(configs)-1]
+	merged := append([]byte(nil), newest.Data...)
+	owner := map[string]string{}
+	values, _ := tomlValues(merged)
+	for k := range values {
+		owner[k] = newest.Path
+	}
+	var conflicts []mergeConflict
+	for i := len(configs) - 2; i >= 0; i-- {
+		c := configs[i]
+		theirs, lines := tomlValues(c.Data)
+		for _, l := range lines {
+			k := tomlKeyName(l)
+			v := theirs[k]
+			if kept, ok := values[k]; ok {
+				if kept != v {
+					conflicts = append(conflicts, mergeConflict{Key: k, Kept: kept, KeptIn: owner[k], Dropped: v, From: c.Path})
+				}
+				continue
+			}
+			merged = setTOMLValue(merged, l.Table, l.Key, v)
+			values[k], owner[k] = v, c.Path
+		}
+	}
+	return merged, conflicts
+}
+
+// absoluteRoot makes a relative root in data, which was read relative to
+// the directory wm was run in, absolute against dir.
+func absoluteRoot(data []byte, dir string) []byte {
+	var c Configuration
+	if _, err := toml.Decode(string(data), &c); err != nil {
+		return data
+	}
+	if len(c.Root) == 0 || filepath.IsAbs(c.Root) || strings.HasPrefix(c.Root, "~") {
+		return data
+	}
+	root := filepath.Join(dir, c.Root)
+	log.Printf(":::note::: root %s in %s was relative; it is now %s", c.Root, dir, root)
+	return setTOMLValue(data, "", "root", tomlString(root))
+}
+
+// writeConfigStub replaces the configuration at path with a stub pointing at
+// target, keeping the original as <path>.bak.
+func writeConfigStub(path string, original []byte, target string) error {
+	err := os.WriteFile(path+".bak", original, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	stub := fmt.Sprintf("# This configuration moved to %s, which wm reads instead.\n# Reading it from here is deprecated.\nmoved_to = %s\n", target, tomlString(target))
+	return os.WriteFile(path, []byte(stub), 0o644)
+}
+
+// runConfigMigrate moves configuration files from the old default locations
+// to the user configuration, merging several if asked, and leaves a stub at
+// each old location.
+func runConfigMigrate(params Parameters) error {
+	target := userConfigFile()
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("%s already exists; move it aside to migrate another configuration there", target)
+	}
+	dirs := params.Dir
+	if len(dirs) == 0 {
+		dirs = legacyConfigDirs()
+	}
+	found, err := findLegacyConfigs(dirs, target)
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		fmt.Printf("no %s found in %s\n", defaultConfigFile, strings.Join(dirs, ", "))
+		return nil
+	}
+	for i, c := range found {
+		fmt.Printf("%d) %s, modified %s\n   %s\n", i+1, c.Path, c.ModTime.Format("2006-01-02 15:04"), c.summary())
+	}
+
+	chosen := found
+	switch {
+	case len(found) == 1 && !params.Yes:
+		if !confirm(fmt.Sprintf("move %s to %s?", found[0].Path, target)) {
+			return errors.New("cancelled")
+		}
+	case len(found) > 1 && !params.Yes:
+		reply := ask(`migrate which? a number, or "merge" to merge them all with the newest winning`, "")
+		if reply != "merge" {
+			n, err := strconv.Atoi(reply)
+			if err != nil || n < 1 || n > len(found) {
+				return fmt.Errorf("no configuration numbered '%s'", reply)
+			}
+			chosen = found[n-1 : n]
+		}
+	}
+
+	var data []byte
+	if len(chosen) == 1 {
+		data = absoluteRoot(chosen[0].Data, filepath.Dir(chosen[0].Path))
+	} else {
+		sources := make([]legacyConfig, len(chosen))
+		for i, c := range chosen {
+			sources[i] = c
+			sources[i].Data = absoluteRoot(c.Data, filepath.Dir(c.Path))
+		}
+		var conflicts []mergeConflict
+		data, conflicts = mergeConfigs(sources)
+		for _, c := range conflicts {
+			fmt.Printf("conflict: %s = %s from %s wins over %s from %s\n", c.Key, c.Kept, c.KeptIn, c.Dropped, c.From)
+		}
+	}
+	var check Configuration
+	if _, err := toml.Decode(string(data), &check); err != nil {
+		return fmt.Errorf("refusing to write an invalid configuration: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(target, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	fmt.Println("wrote", target)
+	for _, c := range chosen {
+		if err := writeConfigStub(c.Path, c.Data, target); err != nil {
+			return err
+		}
+		fmt.Printf("left a stub at %s pointing to it; the old file is %s.bak\n", c.Path, c.Path)
+	}
+	return nil
+}
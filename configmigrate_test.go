@@ -0,0 +1,122 @@
+package wm
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeConfigs(t *testing.T) {
+	configs := []legacyConfig{
+		{Path: "/a/wm.toml", Data: []byte("root = \"/a\"\nbackups = 2\n\n[lint]\nmax_line_length = 80\n")},
+		{Path: "/b/wm.toml", Data: []byte("root = \"/b\"\neditor = \"nano\"\n")},
+		{Path: "/c/wm.toml", Data: []byte("# mine\nroot = \"/c\"   # newest\neditor = \"vim\"\n")},
+	}
+	merged, conflicts := mergeConfigs(configs)
+	want := "# mine\nroot = \"/c\"   # newest\neditor = \"vim\"\nbackups = 2\n\n[lint]\nmax_line_length = 80\n"
+	if string(merged) != want {
+		t.Errorf("merged =\n%s\nwant\n%s", merged, want)
+	}
+	wantConflicts := []mergeConflict{
+		{Key: "root", Kept: `"/c"`, KeptIn: "/c/wm.toml", Dropped: `"/b"`, From: "/b/wm.toml"},
+		{Key: "editor", Kept: `"vim"`, KeptIn: "/c/wm.toml", Dropped: `"nano"`, From: "/b/wm.toml"},
+		{Key: "root", Kept: `"/c"`, KeptIn: "/c/wm.toml", Dropped: `"/a"`, From: "/a/wm.toml"},
+	}
+	if !reflect.DeepEqual(conflicts, wantConflicts) {
+		t.Errorf("conflicts = %+v, want %+v", conflicts, wantConflicts)
+	}
+
+	// a key only an older file sets is owned by it, and conflicts with
+	// the ones older still are reported against it
+	configs = append([]legacyConfig{{Path: "/z/wm.toml", Data: []byte("backups = 5\n")}}, configs...)
+	_, conflicts = mergeConfigs(configs)
+	last := conflicts[len(conflicts)-1]
+	if last.Key != "backups" || last.Kept != "2" || last.KeptIn != "/a/wm.toml" || last.From != "/z/wm.toml" {
+		t.Errorf("conflict over backups = %+v, want 2 from /a kept over 5 from /z", last)
+	}
+}
+
+func TestConfigStub(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	dir := t.TempDir()
+	old, target := filepath.Join(dir, "wm.toml"), filepath.Join(dir, "new", "config.toml")
+	original := []byte("root = '/logs'\n")
+	if err := os.WriteFile(old, original, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeConfigStub(old, original, target); err != nil {
+		t.Fatal(err)
+	}
+	assertEntry(t, old+".bak", string(original))
+	src := followConfigStub(configSource{Path: old, Reason: "set by $WMCFG"})
+	if src.Path != target || !strings.Contains(src.Reason, old) {
+		t.Errorf("followConfigStub = %+v, want %s", src, target)
+	}
+	if !strings.Contains(logged.String(), "deprecated") {
+		t.Errorf("following the stub logged %q, want a deprecation note", logged.String())
+	}
+	if src := followConfigStub(configSource{Path: old + ".bak"}); src.Path != old+".bak" {
+		t.Errorf("followConfigStub of a configuration = %+v, want it unchanged", src)
+	}
+	found, err := findLegacyConfigs([]string{dir}, target)
+	if err != nil || len(found) != 0 {
+		t.Errorf("findLegacyConfigs = %+v, %v, want the stub left out", found, err)
+	}
+}
+
+func TestConfigMigrate(t *testing.T) {
+	root := t.TempDir()
+	cfgFile, env := testHome(t, root)
+	home := filepath.Dir(cfgFile)
+	testConfigAppend(t, cfgFile, "# from home\n")
+	work := t.TempDir()
+	older := filepath.Join(work, defaultConfigFile)
+	if err := os.WriteFile(older, []byte("root = 'logs'\nbackups = 4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(older, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	out, stderr, code := runWM(t, work, env, "config", "migrate", "--yes", home, work)
+	if code != exitOK {
+		t.Fatalf("config migrate exited %d: %s%s", code, out, stderr)
+	}
+	if !strings.Contains(string(out), "conflict: root = '"+filepath.ToSlash(root)+"'") {
+		t.Errorf("config migrate = %s, want the conflict over root reported", out)
+	}
+	target := filepath.Join(home, ".config", "wm", userConfigName)
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "root = '" + filepath.ToSlash(root) + "'\neditor = 'true'\n# from home\nbackups = 4\n"; string(data) != want {
+		t.Errorf("%s =\n%s\nwant\n%s", target, data, want)
+	}
+
+	// both old files now point at the new one, and reading one says so
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	for _, old := range []string{cfgFile, older} {
+		src := followConfigStub(configSource{Path: old})
+		if src.Path != target {
+			t.Errorf("%s points to %s, want %s", old, src.Path, target)
+		}
+	}
+	_, stderr, code = runWM(t, work, append(env, "WMCFG="+older), "config", "--path")
+	if code != exitOK || !strings.Contains(string(stderr), "has moved to "+target) {
+		t.Errorf("reading the old file = %d, %s, want a deprecation note", code, stderr)
+	}
+
+	if _, stderr, code := runWM(t, work, env, "config", "migrate", "--yes", work); code == exitOK || !strings.Contains(string(stderr), "already exists") {
+		t.Errorf("migrating again = %d, %s, want it refused", code, stderr)
+	}
+}
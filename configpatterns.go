@@ -0,0 +1,115 @@
+package wm
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+)
+
+// configPattern is a regular expression set in the configuration, with the
+// key it is set under.
+type configPattern struct {
+	Key     string
+	Pattern string
+	Groups  int
+}
+
+// configPatterns returns the regular expressions set under the keys
+// configKeys marks as patterns, in the order of Configuration.  Labels are
+// taken off redact patterns.
+func configPatterns(cfg Configuration) []configPattern {
+	var out []configPattern
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := prefix + configKeyName(f)
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Struct {
+				walk(fv, name+".")
+				continue
+			}
+			key := configKeys[name]
+			if !key.Pattern {
+				continue
+			}
+			switch fv.Kind() {
+			case reflect.String:
+				if s := fv.String(); len(s) > 0 {
+					out = append(out, configPattern{name, s, key.Groups})
+				}
+			case reflect.Slice:
+				for j := 0; j < fv.Len(); j++ {
+					s := fv.Index(j).String()
+					if name == "redact.patterns" {
+						_, s = splitRedactLabel(s)
+					}
+					out = append(out, configPattern{name, s, key.Groups})
+				}
+			}
+		}
+	}
+	walk(reflect.ValueOf(cfg), "")
+	return out
+}
+
+// patternProblems compiles every configured pattern, so that a bad one is
+// reported when the configuration is read, naming its key, rather than by
+// whichever command first uses it.
+func patternProblems(cfg Configuration) []string {
+	var problems []string
+	for _, p := range configPatterns(cfg) {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf(`config: "%s" is not a regular expression: %v`, p.Key, err))
+			continue
+		}
+		if re.NumSubexp() < p.Groups {
+			problems = append(problems, fmt.Sprintf(`config: "%s" needs %d group(s), '%s' has %d`, p.Key, p.Groups, p.Pattern, re.NumSubexp()))
+		}
+	}
+	return problems
+}
+
+// nestedRepeat reports whether re repeats something that itself repeats,
+// such as (a+)+ or (\w*)*.
+func nestedRepeat(re *syntax.Regexp, inRepeat bool) bool {
+	repeats := re.Op == syntax.OpStar || re.Op == syntax.OpPlus || (re.Op == syntax.OpRepeat && (re.Max == -1 || re.Max > 1))
+	if repeats && inRepeat {
+		return true
+	}
+	for _, sub := range re.Sub {
+		if nestedRepeat(sub, inRepeat || repeats) {
+			return true
+		}
+	}
+	return false
+}
+
+// lintPatterns warns about configured patterns that compile but are likely
+// mistakes: ones matching the empty string, which match every line, and
+// nested repetitions.  The latter cost wm nothing, as Go's regular
+// expressions never backtrack, but the same pattern copied into grep -P or
+// an editor's search can take exponential time.
+func lintPatterns(cfg Configuration) []string {
+	var warnings []string
+	for _, p := range configPatterns(cfg) {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			// reported by validateConfig
+			continue
+		}
+		if re.MatchString("") {
+			warnings = append(warnings, fmt.Sprintf(`"%s": '%s' matches the empty string, so every line`, p.Key, p.Pattern))
+		}
+		if tree, err := syntax.Parse(p.Pattern, syntax.Perl); err == nil && nestedRepeat(tree.Simplify(), false) {
+			warnings = append(warnings, fmt.Sprintf(`"%s": '%s' nests repetitions, which backtracking engines can take exponential time on`, p.Key, p.Pattern))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,401 @@
+package wm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configKey describes a configuration key for "config schema".  The key's
+// type is not given here but read from Configuration, so that a setting
+// can't be added, renamed, or retyped without the schema following.
+type configKey struct {
+	Description string
+	Default     interface{}
+	Enum        []string
+	Required    bool
+	// Pattern marks keys holding regular expressions, which need at least
+	// Groups capture groups.
+	Pattern bool
+	Groups  int
+}
+
+// configKeys describes every configuration key, tables included, by its
+// dotted path.  configSchema fails for a key of Configuration that is
+// missing here, or one here that Configuration doesn't have.
+var configKeys = map[string]configKey{
+	"root":                    {Description: "Directory the entries are kept in", Required: true},
+	"extra_roots":             {Description: "Further directories of entries, such as archived years, that search, list, stats, and export read too"},
+	"editor":                  {Description: "Command line that opens entries, such as \"code --wait\"; unset, $VISUAL or $EDITOR"},
+	"editor_wait":             {Description: "Run the editor on the terminal and wait for it", Default: true},
+	"detach":                  {Description: "Start the editor and leave it running, for GUI editors that return at once", Default: false},
+	"clipboard_command":       {Description: "Command line printing the clipboard for clip, instead of pbpaste, Get-Clipboard, wl-paste, xclip, or xsel"},
+	"readonly_args":           {Description: "Editor arguments that open a file read-only for --read-only, such as \"-R\" for vim"},
+	"contextSize":             {Description: "Deprecated: bytes of context around search matches, used only without context_lines"},
+	"context_lines":           {Description: "Lines of context search shows around a match", Default: defaultContextLines},
+	"lint":                    {Description: "Rules of the lint command"},
+	"lint.required_sections":  {Description: "Headings every entry must have"},
+	"lint.heading_level":      {Description: "Level every heading must have; 0 doesn't check"},
+	"lint.max_line_length":    {Description: "Longest line allowed, in characters; 0 doesn't check"},
+	"lint.severity":           {Description: "Severity of a lint rule by its name", Enum: []string{severityOff, severityWarning, severityError}},
+	"time_format":             {Description: "Go time layout of the times modified and list --show-mtime print", Default: defaultTimeFormat},
+	"usage_stats":             {Description: "Record which commands are run for the usage command", Default: false},
+	"command_history":         {Description: "Record invocations for history and redo", Default: true},
+	"date_keywords":           {Description: "Custom date keywords in terms of the built-in ones, e.g. payday = \"eom-2\""},
+	"date_order":              {Description: "How numeric dates such as 3/7/2024 that are valid either way are read; unset, they are refused", Enum: []string{"mdy", "dmy"}},
+	"date_locale":             {Description: "Language of month and weekday names in dates and output", Default: "en", Enum: dateLocales()},
+	"gap_warning_days":        {Description: "Warn when today's entry is opened more than this many days after the previous one; 0 doesn't", Default: 0},
+	"month_filename":          {Description: "Name of the notes file in each month's directory, opened by giving a month without a day", Default: "month.txt"},
+	"backups":                 {Description: "Keep this many copies of an entry, taken before it is edited; 0 keeps none", Default: 0},
+	"carry_forward":           {Description: "Start today's new entry with the open todos of the previous one", Default: false},
+	"default_command":         {Description: "What wm given no arguments runs", Default: "open", Enum: defaultCommands},
+	"daily_word_goal":         {Description: "Words an entry should have, shown in list, stats, and after an edit; 0 is no goal", Default: 0},
+	"session_markers":         {Description: "Append a --- HH:MM --- line when today's entry is opened after a break", Default: false},
+	"session_gap":             {Description: "Shortest break that starts a new session", Default: defaultSessionGap.String()},
+	"timed_sections":          {Description: "Group what append adds to today's entry under a heading for the part of the day", Default: false},
+	"sections":                {Description: "Parts of the day for timed_sections by the hour each starts at, e.g. Morning = 5"},
+	"redact_tag":              {Description: "Tag of the entries export --redact-tag leaves out", Default: defaultRedactTag},
+	"review_stopwords":        {Description: "Words review leaves out of the top terms, besides common English words"},
+	"path_layout":             {Description: "Layout of entry paths: nested, flat, or a Go time layout such as 2006-01-02.md", Default: "nested"},
+	"path_format":             {Description: "Another name for path_layout"},
+	"normalize":               {Description: "Normalize line endings and strip trailing whitespace once the editor exits", Default: false},
+	"line_ending":             {Description: "Line ending normalize writes", Default: "lf", Enum: lineEndings},
+	"preserve_code_blocks":    {Description: "Leave trailing whitespace inside fenced code blocks when normalizing", Default: false},
+	"archive_dir":             {Description: "Directory archive moves old entries to; unset, the root's path with -archive appended"},
+	"trash":                   {Description: "Move the entries move merges into others to .wm-trash rather than removing them", Default: false},
+	"conflict_patterns":       {Description: "Regular expressions matching the names of sync conflict copies, whose groups put together name the entry", Pattern: true, Groups: 1},
+	"pager":                   {Description: "Command line long output goes through on a terminal, such as \"less -FRX\"; unset, $PAGER; \"off\" for none"},
+	"nearest_entry_days":      {Description: "Days either side of a past date searched for an entry to open when it has none", Default: defaultNearestEntryDays},
+	"open_range_limit":        {Description: "Most days opening a range such as 3/1..3/5 takes without --force", Default: defaultOpenRangeLimit},
+	"import_filename_regex":   {Description: "Regular expression finding the date in the path of a file import brings in, with year, month, and day groups", Default: defaultImportFilenameRegex, Pattern: true, Groups: 3},
+	"extension":               {Description: "Extension of new entries, such as md; unset, the one path_layout gives", Default: "txt"},
+	"legacy_extensions":       {Description: "Other extensions entries are read with, such as txt after extension changes", Default: []string{"txt"}},
+	"profiles":                {Description: "Configuration files of other profiles by name, for search --all-profiles"},
+	"notebooks":               {Description: "Logs kept in this configuration by name, each overriding root, editor, or template"},
+	"notebooks.root":          {Description: "Directory the notebook's entries are kept in"},
+	"notebooks.editor":        {Description: "Command line that opens the notebook's entries"},
+	"notes":                   {Description: "Kinds of notes that belong to no day, by name, for the note command"},
+	"notes.path":              {Description: "Path template of the kind's notes under the root, e.g. people/{{.Arg}}.md"},
+	"notes.template":          {Description: "Template of the kind's new notes"},
+	"notebooks.template":      {Description: "Default template for the notebook's new entries"},
+	"default_notebook":        {Description: "Notebook used without --notebook"},
+	"dir_mode":                {Description: "Permission of the directories created for entries, as 0o755 or \"0755\"", Default: fmt.Sprintf("%#o", defaultDirMode)},
+	"file_mode":               {Description: "Permission of the files created for entries, as 0o644 or \"0644\"", Default: fmt.Sprintf("%#o", defaultFileMode)},
+	"redact":                  {Description: "Patterns every redaction applies"},
+	"redact.patterns":         {Description: "Regular expressions to redact, optionally labelled as LABEL=pattern", Pattern: true},
+	"redact.pattern_files":    {Description: "Files of literal secrets to redact, one a line"},
+	"index":                   {Description: "Keep a search index in root/.wm-index", Default: false},
+	"lock_entries":            {Description: "Lock an entry while it is open in the editor, for roots shared between machines", Default: false},
+	"hooks":                   {Description: "Commands run around editing an entry"},
+	"hooks.pre_open":          {Description: "Commands run before an entry is opened; one failing stops it from being opened"},
+	"hooks.post_save":         {Description: "Commands run once the editor exits on an entry; failures are only noted"},
+	"hook_timeout_seconds":    {Description: "Seconds a hook may run before it is killed", Default: defaultHookTimeoutSeconds},
+	"lock_timeout":            {Description: "Age after which a lock on an entry is taken to be left over and stolen", Default: defaultLockTimeout.String()},
+	"git_autocommit":          {Description: "Commit an entry after editing it when the root is in a git work tree", Default: false},
+	"encrypt":                 {Description: "Keep entries encrypted with AES-256-GCM, decrypting them only in memory and in a temporary file while editing", Default: false},
+	"passphrase_command":      {Description: "Command line printing the passphrase entries are encrypted with, such as \"pass show wm\""},
+	"search_fold_diacritics":  {Description: "Make search match regardless of accents, as --fold-diacritics does", Default: false},
+	"search_workers":          {Description: "Files search reads at once; 0 is the number of CPUs up to 8", Default: 0},
+	"week_start":              {Description: "Weekday the week command starts weeks on", Default: "monday"},
+	"trim":                    {Description: "How trim recognizes pasted output"},
+	"trim.max_prose_ratio":    {Description: "Share of prose lines at most which a block is pasted output", Default: defaultMaxProseRatio},
+	"trim.long_line":          {Description: "Length from which a line counts as long", Default: defaultLongLine},
+	"trim.min_indented":       {Description: "Share of equally indented lines from which a block is pasted output", Default: defaultMinIndented},
+	"editor_line_arg":         {Description: "Editor argument that opens a file at a line, e.g. \"+{line}\""},
+	"editor_goto_format":      {Description: "Another name for editor_line_arg"},
+	"day_start_hour":          {Description: "Hour before which today is still the day before", Default: 0},
+	"day_rollover_hour":       {Description: "Another name for day_start_hour"},
+	"timezone":                {Description: "Time zone that decides which day today is, e.g. Europe/Berlin"},
+	"template":                {Description: "Default template for new entries"},
+	"templates":               {Description: "Templates by weekday or date range, e.g. monday or 2024-01-01..2024-03-31"},
+	"attachment_types":        {Description: "Extensions of the attachments search --include-attachments reads", Default: defaultAttachmentTypes},
+	"attachment_max_size":     {Description: "Largest attachment search --include-attachments reads, in bytes", Default: defaultAttachmentMaxSize},
+	"confirm_distance":        {Description: "How far from today a new entry may be dated before wm asks", Default: "365d"},
+	"confirm_parsed_date":     {Description: "Ask before opening a date whose day and month could be swapped", Default: false},
+	"holidays":                {Description: "Extra days off as \"YYYY-MM-DD Name\""},
+	"holidays_file":           {Description: "File of days off next to the configuration", Default: "holidays.txt"},
+	"output":                  {Description: "plain for screen-reader friendly output", Default: "normal", Enum: []string{"normal", "plain"}},
+	"strict_create":           {Description: "Create entries only with --create or commands such as fill", Default: false},
+	"follow_interval":         {Description: "How often search --follow polls", Default: defaultFollowInterval.String()},
+	"decisions":               {Description: "How the decisions command finds decision lines"},
+	"decisions.pattern":       {Description: "Regular expression of a decision line", Default: defaultDecisionPattern, Pattern: true},
+	"decisions.continuation":  {Description: "Include the indented lines following a match", Default: true},
+	"summary":                 {Description: "How the summary command finds the important lines"},
+	"summary.pattern":         {Description: "Regular expression of an important line", Default: defaultImportantPattern, Pattern: true},
+	"summary.continuation":    {Description: "Include the indented lines following a match", Default: true},
+	"due":                     {Description: "How the due command finds @due() annotations"},
+	"due.pattern":             {Description: "Regular expression of a due annotation, with the date as its first group", Default: defaultDuePattern, Pattern: true, Groups: 1},
+	"due.lookback":            {Description: "How far back due looks for open items", Default: defaultDueLookback},
+	"due.within":              {Description: "How far ahead due lists items by default", Default: defaultDueWithin},
+	"search_skip_boilerplate": {Description: "Make search behave as with --no-boilerplate", Default: false},
+}
+
+func dateLocales() []string {
+	var locales []string
+	for l := range monthNames {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// configKeyName is the key a field of Configuration is read from: its toml
+// tag, or its name starting in lowercase, which the decoder matches.
+func configKeyName(f reflect.StructField) string {
+	if tag := strings.Split(f.Tag.Get("toml"), ",")[0]; len(tag) > 0 {
+		return tag
+	}
+	r, size := utf8.DecodeRuneInString(f.Name)
+	return string(unicode.ToLower(r)) + f.Name[size:]
+}
+
+var permSettingType = reflect.TypeOf(permSetting(0))
+
+// schemaType returns the JSON Schema of values of type t, with the
+// properties of tables described from configKeys under prefix.  documented
+// collects the keys described.
+func schemaType(t reflect.Type, prefix string, documented map[string]bool) (map[string]interface{}, error) {
+	if t == permSettingType {
+		return map[string]interface{}{"type": []string{"integer", "string"}}, nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaType(t.Elem(), prefix, documented)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Slice:
+		items, err := schemaType(t.Elem(), prefix, documented)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		values, err := schemaType(t.Elem(), prefix, documented)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := prefix + configKeyName(f)
+			key, ok := configKeys[name]
+			if !ok {
+				return nil, fmt.Errorf("configuration key %s has no description", name)
+			}
+			documented[name] = true
+			s, err := schemaType(f.Type, name+".", documented)
+			if err != nil {
+				return nil, err
+			}
+			s["description"] = key.Description
+			if key.Default != nil {
+				s["default"] = key.Default
+			}
+			if len(key.Enum) > 0 {
+				if s["type"] == "object" {
+					s["additionalProperties"] = map[string]interface{}{"type": "string", "enum": key.Enum}
+				} else {
+					s["enum"] = key.Enum
+				}
+			}
+			if key.Pattern {
+				if items, ok := s["items"].(map[string]interface{}); ok {
+					items["format"] = "regex"
+				} else {
+					s["format"] = "regex"
+				}
+			}
+			if key.Required {
+				required = append(required, configKeyName(f))
+			}
+			properties[configKeyName(f)] = s
+		}
+		s := map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("configuration key %s has type %s, which the schema can't describe", strings.TrimSuffix(prefix, "."), t)
+}
+
+// configSchema returns the JSON Schema of the configuration file.
+func configSchema() (map[string]interface{}, error) {
+	documented := map[string]bool{}
+	s, err := schemaType(reflect.TypeOf(Configuration{}), "", documented)
+	if err != nil {
+		return nil, err
+	}
+	for name := range configKeys {
+		if !documented[name] {
+			return nil, fmt.Errorf("configuration key %s is described but doesn't exist", name)
+		}
+	}
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = "wm configuration"
+	return s, nil
+}
+
+// runConfigSchema prints the JSON Schema of the configuration file.
+func runConfigSchema(w io.Writer, params Parameters) error {
+	switch params.Format {
+	case "", "human", "json-schema":
+	default:
+		return fmt.Errorf("unknown schema format '%s', expected json-schema", params.Format)
+	}
+	s, err := configSchema()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// schemaProblems checks a decoded TOML value against schema s, as far as
+// the configuration schema goes: types, enums, and the keys of tables.
+func schemaProblems(s map[string]interface{}, v interface{}, path string) []string {
+	var problems []string
+	var types []string
+	switch t := s["type"].(type) {
+	case string:
+		types = []string{t}
+	case []string:
+		types = t
+	}
+	kind := tomlKind(v)
+	if !stringIn(kind, types) && !(kind == "integer" && stringIn("number", types)) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), kind)}
+	}
+	if enum, ok := s["enum"].([]string); ok {
+		if str, _ := v.(string); !stringIn(strings.ToLower(str), enum) {
+			problems = append(problems, fmt.Sprintf("%s: expected one of %s, got '%s'", path, strings.Join(enum, ", "), str))
+		}
+	}
+	switch v := v.(type) {
+	case []interface{}:
+		items, _ := s["items"].(map[string]interface{})
+		for i, item := range v {
+			problems = append(problems, schemaProblems(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case map[string]interface{}:
+		properties, _ := s["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			name := k
+			if len(path) > 0 {
+				name = path + "." + k
+			}
+			if p, ok := properties[k].(map[string]interface{}); ok {
+				problems = append(problems, schemaProblems(p, v[k], name)...)
+			} else if p, ok := s["additionalProperties"].(map[string]interface{}); ok {
+				problems = append(problems, schemaProblems(p, v[k], name)...)
+			} else {
+				problems = append(problems, fmt.Sprintf("%s: unknown key", name))
+			}
+		}
+		if required, ok := s["required"].([]string); ok {
+			for _, k := range required {
+				if _, ok := v[k]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: missing", k))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// tomlKind is the JSON Schema type of a decoded TOML value.
+func tomlKind(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "number"
+	case []interface{}, []map[string]interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "date-time"
+}
+
+func stringIn(s string, list []string) bool {
+	for _, l := range list {
+		if s == l {
+			return true
+		}
+	}
+	return false
+}
+
+// runConfigCheck checks the configuration file against the schema first and
+// then the way wm reads it, printing every problem found.  It reports
+// whether there were any.  With lint, it also warns about patterns that are
+// likely mistakes, which don't count as problems.
+func runConfigCheck(w io.Writer, cfgFile string, lint bool) (bool, error) {
+	data, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return false, err
+	}
+	s, err := configSchema()
+	if err != nil {
+		return false, err
+	}
+	var raw map[string]interface{}
+	if _, err := toml.Decode(string(data), &raw); err != nil {
+		fmt.Fprintf(w, "%s: %v\n", cfgFile, err)
+		return true, nil
+	}
+	problems := schemaProblems(s, raw, "")
+	var warnings []string
+	if len(problems) == 0 {
+		cfg, err := readConfig(cfgFile)
+		if err == nil {
+			err = validateConfig(cfg)
+		}
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else if lint {
+			warnings = lintPatterns(cfg)
+		}
+	}
+	for _, p := range problems {
+		fmt.Fprintf(w, "%s: %s\n", cfgFile, p)
+	}
+	for _, p := range warnings {
+		fmt.Fprintf(w, "%s: warning: %s\n", cfgFile, p)
+	}
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "%s: ok\n", cfgFile)
+	}
+	return len(problems) > 0, nil
+}
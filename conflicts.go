@@ -0,0 +1,384 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultConflictPatterns match the names sync clients give the copies they
+// leave when an entry was changed in two places: Syncthing's
+// 7.sync-conflict-20240307-101500-ABCDEF1.txt, Dropbox's and Nextcloud's
+// "7 (conflicted copy 2024-03-07).txt", and ownCloud's
+// 7_conflict-20240307-101500.txt.  The groups of a pattern put together are
+// the name of the entry the copy is of.
+var defaultConflictPatterns = []string{
+	`^(.+)\.sync-conflict-[0-9A-Za-z-]+(\.[^.]+)$`,
+	`^(.+?) \([^)]*conflicted copy[^)]*\)(\.[^.]+)?$`,
+	`^(.+)_conflict-\d{8}-\d{6}(\.[^.]+)?$`,
+}
+
+// trashDir is where merged conflict copies, and the entries move merges
+// into others while trash is on, are moved to under the root, keeping their
+// paths.
+const trashDir = ".wm-trash"
+
+// conflictCopy is a conflict copy found under the root and the entry it is
+// a copy of.
+type conflictCopy struct {
+	Path string
+	Base string
+}
+
+// conflictPatterns returns conflict_patterns compiled, or the defaults.
+func conflictPatterns(cfg Configuration) ([]*regexp.Regexp, error) {
+	patterns := cfg.ConflictPatterns
+	if len(patterns) == 0 {
+		patterns = defaultConflictPatterns
+	}
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("conflict_patterns: %w", err)
+		}
+		res[i] = re
+	}
+	return res, nil
+}
+
+// conflictBase returns the name of the file that name is a conflict copy
+// of, or "" when it doesn't look like one.
+func conflictBase(res []*regexp.Regexp, name string) string {
+	for _, re := range res {
+		m := re.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		base := strings.Join(m[1:], "")
+		if len(base) > 0 && base != name {
+			return base
+		}
+	}
+	return ""
+}
+
+// findConflicts walks the root for conflict copies, pairing each with the
+// entry next to it that it is a copy of.  Copies whose entry isn't there are
+// returned as problems.
+func findConflicts(cfg Configuration, opts walkOptions) ([]conflictCopy, []string, error) {
+	res, err := conflictPatterns(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	var found []conflictCopy
+	var problems []string
+	err = filepath.WalkDir(cfg.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == cfg.Root {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != cfg.Root && (opts.skipDir(d.Name()) || d.Name() == trashDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		base := conflictBase(res, d.Name())
+		if len(base) == 0 || !d.Type().IsRegular() {
+			return nil
+		}
+		basePath := filepath.Join(filepath.Dir(path), base)
+		if info, err := os.Stat(basePath); err != nil || !info.Mode().IsRegular() {
+			problems = append(problems, fmt.Sprintf("%s: there is no %s it is a copy of", displayPath(path), base))
+			return nil
+		}
+		found = append(found, conflictCopy{Path: path, Base: basePath})
+		return nil
+	})
+	return found, problems, err
+}
+
+func init() {
+	registerDoctorCheck(doctorCheck{
+		Name: "conflict copies",
+		Check: func(in doctorInput) doctorResult {
+			if !isDir(in.Cfg.Root) {
+				return doctorOK("none, as the root doesn't exist")
+			}
+			res, err := walkRoot(in.Cfg, walkOptions{})
+			if err != nil {
+				return doctorResult{doctorFail, fmt.Sprintf("the root can't be listed: %v", err), "check its permissions"}
+			}
+			if len(res.Conflicts) == 0 {
+				return doctorOK("none")
+			}
+			return doctorResult{doctorWarn, fmt.Sprintf("%d left by sync next to entries, such as %s; they are left out of every command",
+				len(res.Conflicts), res.Conflicts[0]), "compare and merge them with \"wm conflicts\""}
+		},
+	})
+}
+
+// diffLines splits data into lines without their line endings.  A final
+// line ending doesn't start another line.
+func diffLines(data []byte) []string {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if len(text) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffContext is how many unchanged lines unifiedDiff shows around a change.
+const diffContext = 3
+
+// maxDiffCells bounds the table unifiedDiff compares two files with, so that
+// a pair of huge files doesn't take all the memory there is.
+const maxDiffCells = 4 << 20
+
+// diffOp is a line of a diff: ' ' kept, '-' only in a, '+' only in b.
+type diffOp struct {
+	Kind byte
+	Text string
+}
+
+// lineDiff compares the lines of a and b by their longest common
+// subsequence.
+func lineDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	// lcs[i][j] is the length of the common subsequence of a[i:] and b[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var ops []diffOp
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	return ops
+}
+
+// unifiedDiff returns the changes from a, called nameA, to b, called nameB,
+// as a unified diff, or "" when their lines are the same.
+func unifiedDiff(a, b []byte, nameA, nameB string) (string, error) {
+	la, lb := diffLines(a), diffLines(b)
+	if (len(la)+1)*(len(lb)+1) > maxDiffCells {
+		return "", fmt.Errorf("%s and %s are too long to compare", nameA, nameB)
+	}
+	ops := lineDiff(la, lb)
+	var out strings.Builder
+	// lines before ops[k] of a and b, 1-based once printed
+	lineA := make([]int, len(ops)+1)
+	lineB := make([]int, len(ops)+1)
+	for k, op := range ops {
+		lineA[k+1], lineB[k+1] = lineA[k], lineB[k]
+		if op.Kind != '+' {
+			lineA[k+1]++
+		}
+		if op.Kind != '-' {
+			lineB[k+1]++
+		}
+	}
+	for k := 0; k < len(ops); {
+		if ops[k].Kind == ' ' {
+			k++
+			continue
+		}
+		// a hunk runs from diffContext lines before the change to
+		// diffContext lines after the last change that close to the next
+		start := k - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(ops) {
+			if ops[end].Kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].Kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end += diffContext
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(lineA[start], lineA[end]), hunkRange(lineB[start], lineB[end]))
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(&out, "%c%s\n", op.Kind, op.Text)
+		}
+		k = end
+	}
+	return out.String(), nil
+}
+
+// hunkRange is the "start,count" of a hunk covering the lines after before
+// up to through, as diff writes it.
+func hunkRange(before, through int) string {
+	count := through - before
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// mergedFromConflict returns base with the lines of conflict it doesn't
+// have, in the order of conflict, appended under a "Merged from conflict
+// copy" heading, and reports whether there were any.  Blank lines are never
+// taken over on their own.
+func mergedFromConflict(base, conflict []byte) ([]byte, bool) {
+	have := map[string]bool{}
+	for _, l := range diffLines(base) {
+		have[strings.TrimRight(l, " \t")] = true
+	}
+	var unique []string
+	for _, l := range diffLines(conflict) {
+		key := strings.TrimRight(l, " \t")
+		if len(strings.TrimSpace(key)) == 0 || have[key] {
+			continue
+		}
+		have[key] = true
+		unique = append(unique, l)
+	}
+	if len(unique) == 0 {
+		return base, false
+	}
+	var b bytes.Buffer
+	b.Write(base)
+	if len(base) > 0 && base[len(base)-1] != '\n' {
+		b.WriteString("\n")
+	}
+	if len(base) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("## Merged from conflict copy\n\n")
+	b.WriteString(strings.Join(unique, "\n") + "\n")
+	return b.Bytes(), true
+}
+
+// trashFile moves the file at path under the root's .wm-trash, keeping its
+// path relative to the root and never replacing a file moved there before.
+func trashFile(cfg Configuration, path string) (string, error) {
+	rel, err := filepath.Rel(cfg.Root, path)
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(cfg.Root, trashDir, rel)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(dest); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		dest = filepath.Join(cfg.Root, trashDir, fmt.Sprintf("%s.%d", rel, i))
+	}
+	if err := makeDir(cfg, filepath.Dir(dest)); err != nil {
+		return "", err
+	}
+	return dest, os.Rename(path, dest)
+}
+
+// runConflicts lists the conflict copies sync clients left under the root,
+// each with the entry it is a copy of, their sizes, and how the copy
+// differs, or with --merge appends the lines only the copy has to the entry
+// and moves the copy to .wm-trash.  It reports whether any copies were left
+// as they are.
+func runConflicts(cfg Configuration, params Parameters) (bool, error) {
+	copies, problems, err := findConflicts(cfg, walkOptionsFor(params))
+	if err != nil {
+		return false, err
+	}
+	if len(copies) == 0 && len(problems) == 0 {
+		fmt.Println("no conflict copies under", displayPath(cfg.Root))
+		return false, nil
+	}
+	for _, c := range copies {
+		base, err := readEntry(cfg, c.Base)
+		if err != nil {
+			return false, err
+		}
+		conflict, err := readEntry(cfg, c.Path)
+		if err != nil {
+			return false, err
+		}
+		fmt.Printf("%s (%s bytes) is a conflict copy of %s (%s bytes)\n",
+			displayPath(c.Path), groupDigits(len(conflict)), displayPath(c.Base), groupDigits(len(base)))
+		if !params.MergeConflicts {
+			diff, err := unifiedDiff(base, conflict, displayPath(c.Base), displayPath(c.Path))
+			switch {
+			case err != nil:
+				fmt.Println(" ", err)
+			case len(diff) == 0:
+				fmt.Println("  the same lines as the entry")
+			default:
+				fmt.Print(diff)
+			}
+			fmt.Println()
+			continue
+		}
+		merged, changed := mergedFromConflict(base, conflict)
+		if params.DryRun {
+			if changed {
+				fmt.Println("  would append the lines only the copy has to", displayPath(c.Base))
+			}
+			fmt.Println("  would move it to", trashDir)
+			continue
+		}
+		if changed {
+			if _, err := rewriteEntry(cfg, c.Base, merged, rewriteOptions{Backup: true}); err != nil {
+				return false, fmt.Errorf("failed to merge %s: %w", c.Path, err)
+			}
+			fmt.Println("  appended the lines only the copy has to", displayPath(c.Base))
+		}
+		dest, err := trashFile(cfg, c.Path)
+		if err != nil {
+			return false, fmt.Errorf("failed to move %s away: %w", c.Path, err)
+		}
+		fmt.Println("  moved it to", displayPath(dest))
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	return !params.MergeConflicts || params.DryRun || len(problems) > 0, nil
+}
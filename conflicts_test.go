@@ -0,0 +1,133 @@
+package wm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConflictBase(t *testing.T) {
+	res, err := conflictPatterns(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"7.sync-conflict-20240307-101500-ABCDEF1.txt": "7.txt",
+		"7 (conflicted copy 2024-03-07).txt":          "7.txt",
+		"7 (Sam's conflicted copy 2024-03-07).md":     "7.md",
+		"7_conflict-20240307-101500.txt":              "7.txt",
+		"7.txt":                                       "",
+		"sync-conflict.txt":                           "",
+	}
+	for name, want := range tests {
+		if got := conflictBase(res, name); got != want {
+			t.Errorf("conflictBase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name, a, b, want string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", ""},
+		{"identical but for line endings", "a\r\nb\r\n", "a\nb\n", ""},
+		{"disjoint", "one\ntwo\n", "three\n", "--- a\n+++ b\n@@ -1,2 +1 @@\n-one\n-two\n+three\n"},
+		{"from nothing", "", "new\n", "--- a\n+++ b\n@@ -0,0 +1 @@\n+new\n"},
+		{"interleaved", "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n", "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n",
+			"--- a\n+++ b\n@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n@@ -8,3 +8,4 @@\n h\n i\n j\n+k\n"},
+		{"close changes share a hunk", "a\nb\nc\nd\n", "A\nb\nc\nD\n",
+			"--- a\n+++ b\n@@ -1,4 +1,4 @@\n-a\n+A\n b\n c\n-d\n+D\n"},
+	}
+	for _, tt := range tests {
+		got, err := unifiedDiff([]byte(tt.a), []byte(tt.b), "a", "b")
+		if err != nil || got != tt.want {
+			t.Errorf("%s: unifiedDiff = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestMergedFromConflict(t *testing.T) {
+	tests := []struct {
+		name, base, conflict string
+		want                 string
+		changed              bool
+	}{
+		{"identical", "a\nb\n", "a\nb\n", "a\nb\n", false},
+		{"only trailing space differs", "a\nb\n", "a  \nb\n", "a\nb\n", false},
+		{"conflict is a subset", "a\nb\nc\n", "b\n", "a\nb\nc\n", false},
+		{"disjoint", "a\nb\n", "x\ny\n", "a\nb\n\n## Merged from conflict copy\n\nx\ny\n", true},
+		{"interleaved", "a\nb\nc\n", "a\nx\nb\n\ny\nc\nx\n", "a\nb\nc\n\n## Merged from conflict copy\n\nx\ny\n", true},
+		{"base without final newline", "a", "b\n", "a\n\n## Merged from conflict copy\n\nb\n", true},
+		{"empty base", "", "b\n", "## Merged from conflict copy\n\nb\n", true},
+	}
+	for _, tt := range tests {
+		got, changed := mergedFromConflict([]byte(tt.base), []byte(tt.conflict))
+		if string(got) != tt.want || changed != tt.changed {
+			t.Errorf("%s: mergedFromConflict = %q, %v, want %q, %v", tt.name, got, changed, tt.want, tt.changed)
+		}
+	}
+}
+
+func TestTrashFile(t *testing.T) {
+	cfg := Configuration{Root: t.TempDir()}
+	dir := filepath.Join(cfg.Root, "2024", "3")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	name := "7.sync-conflict-20240307-101500-ABCDEF1.txt"
+	var moved []string
+	for _, content := range []string{"first\n", "second\n"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		dest, err := trashFile(cfg, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s is still there: %v", p, err)
+		}
+		assertEntry(t, dest, content)
+		moved = append(moved, dest)
+	}
+	want := filepath.Join(cfg.Root, trashDir, "2024", "3", name)
+	if moved[0] != want || moved[1] != want+".2" {
+		t.Errorf("trashed to %q, want %s and a second name beside it", moved, want)
+	}
+}
+
+func TestWalkRootConflictCopies(t *testing.T) {
+	cfg := Configuration{Root: t.TempDir()}
+	testEntries(t, cfg.Root, DatePath{2024, 3, 5})
+	dir := filepath.Join(cfg.Root, "2024", "3")
+	copies := []string{"05 (conflicted copy).txt", "5.sync-conflict-20240305-101500-ABCDEF1.txt"}
+	for _, name := range copies {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("copy\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := walkRoot(cfg, walkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Entries) != 1 || len(res.Problems) != 0 || len(res.Conflicts) != len(copies) {
+		t.Fatalf("walkRoot = %d entries, problems %v, conflicts %q, want 1 entry and the %d copies as conflicts",
+			len(res.Entries), res.Problems, res.Conflicts, len(copies))
+	}
+
+	var check doctorCheck
+	for _, c := range doctorChecks {
+		if c.Name == "conflict copies" {
+			check = c
+		}
+	}
+	if check.Check == nil {
+		t.Fatal("doctor has no conflict copies check")
+	}
+	if r := check.Check(doctorInput{Cfg: cfg}); r.Status != doctorWarn || !strings.Contains(r.Hint, "wm conflicts") {
+		t.Errorf("doctor check = %+v, want a warning pointing at wm conflicts", r)
+	}
+}
@@ -0,0 +1,210 @@
+package wm
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A consolidated file starts with consolidateMagic and holds one block per
+// entry:
+//
+//	===== 2019-03-07 (Thursday) ===== 2019/3/7.txt 1234
+//	<exactly 1234 bytes of the entry>
+//
+// followed by a newline.  The separator carries the entry's path relative to
+// the root and its length, so split never has to search the content for the
+// next separator and entries that contain separator-like lines round trip
+// unchanged.
+const consolidateMagic = "wm consolidated v1\n"
+
+var separatorRe = regexp.MustCompile(`^===== (\d{4}-\d{2}-\d{2}) \(\w+\) ===== (\S+) (\d+)\n$`)
+
+// runConsolidate writes every entry of a year into a single file.  Entries
+// are copied straight from their files one at a time, so memory use doesn't
+// grow with the size of the year.
+func runConsolidate(cfg Configuration, params Parameters) error {
+	year, err := strconv.Atoi(params.Year)
+	if err != nil || year < 1000 || year > 9999 {
+		return fmt.Errorf("'%s' is not a year", params.Year)
+	}
+	out := params.Out
+	if len(out) == 0 {
+		out = params.Year + ".txt"
+		if params.Gzip {
+			out += ".gz"
+		}
+	}
+	all, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	var entries []Entry
+	for _, e := range all {
+		if e.Date.year == year {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no entries for %d", year)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	var w io.Writer = bw
+	var gz *gzip.Writer
+	if params.Gzip || strings.HasSuffix(out, ".gz") {
+		gz = gzip.NewWriter(bw)
+		w = gz
+	}
+	prog := newProgress("consolidating", len(entries))
+	_, err = io.WriteString(w, consolidateMagic)
+	for _, e := range entries {
+		if err != nil {
+			break
+		}
+		err = writeConsolidatedBlock(w, cfg, e)
+		prog.step()
+	}
+	prog.finish()
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out)
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	fmt.Printf("consolidated %d entries into %s\n", len(entries), out)
+	return nil
+}
+
+// writeConsolidatedBlock copies one entry into a consolidated file with its
+// separator.  The entry must keep the size the separator announces while it
+// is copied.
+func writeConsolidatedBlock(w io.Writer, cfg Configuration, e Entry) error {
+	rel, err := filepath.Rel(cfg.Root, e.Path)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(e.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "===== %s (%s) ===== %s %d\n", e.Date.Iso(), e.Date.Time().Weekday(), filepath.ToSlash(rel), info.Size())
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(w, src)
+	if err != nil {
+		return err
+	}
+	if n != info.Size() {
+		return fmt.Errorf("%s changed while it was copied", e.Path)
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// consolidatedBlock is one entry read back from a consolidated file.
+type consolidatedBlock struct {
+	Rel  string
+	Data []byte
+}
+
+// readConsolidated parses a consolidated file, gzip-compressed or not.
+func readConsolidated(r io.Reader) ([]consolidatedBlock, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(gz)
+	}
+	first, err := br.ReadString('\n')
+	if err != nil || first != consolidateMagic {
+		return nil, errors.New("not a consolidated file")
+	}
+	var blocks []consolidatedBlock
+	for {
+		sep, err := br.ReadString('\n')
+		if errors.Is(err, io.EOF) && len(sep) == 0 {
+			return blocks, nil
+		}
+		m := separatorRe.FindStringSubmatch(sep)
+		if m == nil {
+			return nil, fmt.Errorf("bad separator after entry %d: %q", len(blocks), strings.TrimSpace(sep))
+		}
+		rel := path.Clean(m[2])
+		if strings.HasPrefix(rel, "../") || path.IsAbs(rel) {
+			return nil, fmt.Errorf("unsafe entry path '%s'", m[2])
+		}
+		size, err := strconv.Atoi(m[3])
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+1)
+		_, err = io.ReadFull(br, data)
+		if err != nil || data[size] != '\n' {
+			return nil, fmt.Errorf("entry %s is truncated", rel)
+		}
+		blocks = append(blocks, consolidatedBlock{Rel: rel, Data: data[:size]})
+	}
+}
+
+// runSplit reconstructs the per-day files of a consolidated file under the
+// root, or the directory given with -o.  Existing files with the same content
+// are left alone; differing ones are only replaced with --yes.
+func runSplit(cfg Configuration, params Parameters) error {
+	f, err := os.Open(params.Consolidated)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	blocks, err := readConsolidated(f)
+	if err != nil {
+		return fmt.Errorf("'%s': %w", params.Consolidated, err)
+	}
+	dest := cfg.Root
+	if len(params.Out) > 0 {
+		dest = params.Out
+	}
+	files := make([]bulkFile, 0, len(blocks))
+	for _, blk := range blocks {
+		files = append(files, bulkFile{Path: filepath.Join(dest, filepath.FromSlash(blk.Rel)), Data: blk.Data})
+	}
+	res, err := writeBulk(cfg, files, params.Yes, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d entries, %d already identical\n", len(res.Created), res.Identical)
+	if len(res.Conflicts) > 0 {
+		for _, p := range res.Conflicts {
+			fmt.Println("differs, not replaced:", p)
+		}
+		return fmt.Errorf("%d entries already exist with other content; use --yes to replace them", len(res.Conflicts))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package wm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConsolidateSplitRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	entries := map[string]string{
+		"2019/3/7.txt":   "Working Memory File\n3/7/2019\n---\n\nplain\n",
+		"2019/3/8.txt":   "===== 2019-03-08 (Friday) ===== 2019/3/8.txt 12\nlooks like a separator\n===== 2019-03-09 (Saturday) ===== 2019/3/9.txt 0\n",
+		"2019/3/9.txt":   "",
+		"2019/4/1.txt":   "no trailing newline",
+		"2019/4/2.txt":   "crlf\r\nlines\r\n\n\n",
+		"2019/12/31.txt": "# last\n\x00\xff binary \xe2\x80 bytes\n",
+		"2020/1/1.txt":   "another year\n",
+	}
+	for rel, content := range entries {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := Configuration{Root: root}
+
+	for _, gz := range []bool{false, true} {
+		out := filepath.Join(t.TempDir(), "2019.txt")
+		if err := runConsolidate(cfg, Parameters{Year: "2019", Out: out, Gzip: gz}); err != nil {
+			t.Fatalf("consolidate, gzip %v: %v", gz, err)
+		}
+		if data, err := os.ReadFile(out); err != nil {
+			t.Fatal(err)
+		} else if compressed := bytes.HasPrefix(data, []byte{0x1f, 0x8b}); compressed != gz {
+			t.Errorf("gzip %v: the file is compressed: %v", gz, compressed)
+		}
+		dest := t.TempDir()
+		if err := runSplit(cfg, Parameters{Consolidated: out, Out: dest}); err != nil {
+			t.Fatalf("split, gzip %v: %v", gz, err)
+		}
+		for rel, content := range entries {
+			data, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(rel)))
+			if rel == "2020/1/1.txt" {
+				if err == nil {
+					t.Errorf("gzip %v: %s of another year was split out", gz, rel)
+				}
+				continue
+			}
+			if err != nil || string(data) != content {
+				t.Errorf("gzip %v: %s = %q, %v, want %q", gz, rel, data, err, content)
+			}
+		}
+		// splitting again over identical files changes nothing
+		if err := runSplit(cfg, Parameters{Consolidated: out, Out: dest}); err != nil {
+			t.Errorf("split again, gzip %v: %v", gz, err)
+		}
+	}
+}
+
+func TestReadConsolidatedRejects(t *testing.T) {
+	for _, content := range []string{
+		"not consolidated\n",
+		consolidateMagic + "===== 2019-03-07 (Thursday) ===== 2019/3/7.txt 10\nshort\n",
+		consolidateMagic + "===== 2019-03-07 (Thursday) ===== ../../etc/passwd 0\n\n",
+		consolidateMagic + "garbage\n",
+	} {
+		if blocks, err := readConsolidated(bytes.NewReader([]byte(content))); err == nil {
+			t.Errorf("readConsolidated(%q) = %v, want an error", content, blocks)
+		}
+	}
+}
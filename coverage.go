@@ -0,0 +1,168 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// searchCoverage is what a search with the current configuration and flags
+// reads: the live entries in range by year, the scratch notes and
+// attachments it adds, and what the walker left out.
+type searchCoverage struct {
+	Root        string
+	Range       dateRange
+	ByYear      map[int]int
+	InRange     int
+	Total       int
+	Scratch     int
+	ScratchUsed bool
+	Attachments int
+	Eligible    int
+	AttachUsed  bool
+	Excluded    []excludedDir
+	Problems    []error
+}
+
+// excludedDir is a directory the walker skipped and how many files it holds.
+type excludedDir struct {
+	Path  string
+	Files int
+}
+
+// countFiles counts the regular files under dir.
+func countFiles(dir string) int {
+	n := 0
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+// collectCoverage works out what search would read for params.
+func collectCoverage(cfg Configuration, params Parameters) (searchCoverage, error) {
+	c := searchCoverage{Root: cfg.Root, ByYear: map[int]int{}}
+	r, err := searchRange(cfg, params)
+	if err != nil {
+		return c, err
+	}
+	c.Range = r
+	walked, err := walkRoot(cfg, walkOptionsFor(params))
+	if err != nil {
+		return c, err
+	}
+	c.Total = len(walked.Entries)
+	c.Problems = walked.Problems
+	for _, e := range filterEntries(walked.Entries, r.From, r.To) {
+		c.ByYear[e.Date.year]++
+		c.InRange++
+	}
+	for _, rel := range walked.Excluded {
+		c.Excluded = append(c.Excluded, excludedDir{Path: rel, Files: countFiles(filepath.Join(cfg.Root, rel))})
+	}
+
+	notes, err := listScratch(cfg)
+	if err != nil {
+		return c, err
+	}
+	c.Scratch = len(notes)
+	c.ScratchUsed = !params.EntriesOnly && r.From == nil && r.To == nil
+
+	c.Attachments = countFiles(filepath.Join(cfg.Root, attachmentsDir))
+	eligible, err := listAttachments(cfg)
+	if err != nil {
+		return c, err
+	}
+	c.Eligible = len(filterEntries(eligible, r.From, r.To))
+	c.AttachUsed = params.IncludeAttachments
+	return c, nil
+}
+
+// print writes the coverage as "label: value" lines.
+func (c searchCoverage) print(w io.Writer) {
+	fmt.Fprintf(w, "root: %s\n", c.Root)
+	span := "all dates"
+	switch {
+	case c.Range.From != nil && c.Range.To != nil:
+		span = fmt.Sprintf("%s to %s", c.Range.From.Iso(), c.Range.To.Iso())
+	case c.Range.From != nil:
+		span = "from " + c.Range.From.Iso()
+	case c.Range.To != nil:
+		span = "through " + c.Range.To.Iso()
+	}
+	fmt.Fprintf(w, "range: %s\n", span)
+	fmt.Fprintf(w, "entries: %d of %d\n", c.InRange, c.Total)
+	years := make([]int, 0, len(c.ByYear))
+	for y := range c.ByYear {
+		years = append(years, y)
+	}
+	sort.Ints(years)
+	for _, y := range years {
+		fmt.Fprintf(w, "  %d: %d\n", y, c.ByYear[y])
+	}
+
+	state := "searched"
+	if !c.ScratchUsed {
+		state = "not searched, because of --entries-only or a range"
+	}
+	fmt.Fprintf(w, "scratch notes: %d, %s\n", c.Scratch, state)
+	state = "not searched without --include-attachments"
+	if c.AttachUsed {
+		state = "searched"
+	}
+	fmt.Fprintf(w, "attachments: %d of %d files eligible by attachment_types and attachment_max_size, %s\n", c.Eligible, c.Attachments, state)
+	fmt.Fprintln(w, "consolidated archives: never searched; \"wm split\" turns one back into entries")
+
+	if len(c.Excluded) == 0 {
+		fmt.Fprintln(w, "excluded directories: none")
+	} else {
+		fmt.Fprintln(w, "excluded directories (--all includes them):")
+		for _, d := range c.Excluded {
+			fmt.Fprintf(w, "  %s: %d files\n", d.Path, d.Files)
+		}
+	}
+	for _, p := range c.Problems {
+		fmt.Fprintf(w, "skipped: %v\n", p)
+	}
+	fmt.Fprintln(w, "index: none; every search reads the files, so results are always current")
+}
+
+// explainSearch prints the coverage of a search followed by the patterns it
+// compiled and how it reads the files, for search --explain.
+func explainSearch(w io.Writer, cfg Configuration, params Parameters, res []*regexp.Regexp) error {
+	c, err := collectCoverage(cfg, params)
+	if err != nil {
+		return err
+	}
+	c.print(w)
+	for i, re := range res {
+		fmt.Fprintf(w, "pattern: %s compiled as %s\n", params.Term[i], re.String())
+	}
+	backend := "reads each file directly"
+	if params.Follow {
+		backend += ", then polls for changes"
+	}
+	fmt.Fprintf(w, "backend: %s\n\n", backend)
+	return nil
+}
+
+// runCoverage prints what a search with the same flags would read.
+func runCoverage(cfg Configuration, params Parameters) error {
+	if _, err := os.Stat(cfg.Root); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("the root %s doesn't exist yet", cfg.Root)
+	}
+	c, err := collectCoverage(cfg, params)
+	if err != nil {
+		return err
+	}
+	c.print(os.Stdout)
+	return nil
+}
@@ -0,0 +1,516 @@
+package wm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// cryptMagic starts every encrypted entry, followed by the path the
+	// entry was sealed at, relative to its root, a newline, the nonce, and
+	// the AES-256-GCM sealed content, which the path is the additional data
+	// of.  Files without it are read as they are, so entries written before
+	// encryption was turned on still open, and so do those written before
+	// the path was sealed in, which start with cryptMagicV1 and have no
+	// additional data.
+	cryptMagic   = "wm-encrypted 2 "
+	cryptMagicV1 = "wm-encrypted 1\n"
+	// cryptKeyFile holds how the key is derived, the salt it is derived
+	// with, and a check the passphrase is verified against, so that a wrong
+	// one is refused before anything is decrypted or written.  Key files of
+	// cryptKeyVersionV1 derive it with PBKDF2 and have no kdf line.
+	cryptKeyFile      = ".wm-key"
+	cryptKeyVersion   = "wm-key 2"
+	cryptKeyVersionV1 = "wm-key 1"
+	cryptCheck        = "wm"
+	cryptKeySize      = 32
+	// pbkdf2Iterations is the work of deriving the key of a version 1 key
+	// file.
+	pbkdf2Iterations = 600000
+)
+
+// defaultKeyKDF is how the key of a new key file is derived: Argon2id with
+// the parameters RFC 9106 recommends when memory is constrained.  It is
+// done once per run.
+var defaultKeyKDF = keyKDF{Name: "argon2id", Time: 3, Memory: 64 << 10, Threads: 4}
+
+// entryCipher encrypts and decrypts entries with the key derived from the
+// passphrase passphrase_command prints, which is read the first time an
+// entry is.
+type entryCipher struct {
+	cfg  Configuration
+	once sync.Once
+	aead cipher.AEAD
+	err  error
+}
+
+// ciphers are the ciphers of the key files in use, by key file and
+// passphrase_command, so that the passphrase is asked for at most once per
+// run however often a configuration is passed around or loaded.
+var ciphers = struct {
+	sync.Mutex
+	byKey map[string]*entryCipher
+}{byKey: map[string]*entryCipher{}}
+
+// entryCrypt returns the cipher of cfg's entries while encrypt is on, and
+// nil otherwise.
+func entryCrypt(cfg Configuration) *entryCipher {
+	if !cfg.Encrypt {
+		return nil
+	}
+	key := filepath.Join(cfg.Root, cryptKeyFile) + "\x00" + cfg.PassphraseCommand
+	ciphers.Lock()
+	defer ciphers.Unlock()
+	c := ciphers.byKey[key]
+	if c == nil {
+		c = &entryCipher{cfg: cfg}
+		ciphers.byKey[key] = c
+	}
+	return c
+}
+
+// keyKDF is how a key file derives the key from the passphrase: Argon2id,
+// RFC 9106, with Time passes over Memory KiB in Threads lanes, or
+// PBKDF2-HMAC-SHA256, RFC 8018, with Time iterations.
+type keyKDF struct {
+	Name    string
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// parseKeyKDF reads the kdf line of a key file, such as
+// "kdf argon2id 3 65536 4".
+func parseKeyKDF(line string) (keyKDF, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 5 || fields[0] != "kdf" || fields[1] != "argon2id" {
+		return keyKDF{}, fmt.Errorf("unknown key derivation '%s'", line)
+	}
+	var n [3]uint64
+	for i, f := range fields[2:] {
+		bits := 32
+		if i == 2 {
+			bits = 8
+		}
+		v, err := strconv.ParseUint(f, 10, bits)
+		if err != nil || v == 0 {
+			return keyKDF{}, fmt.Errorf("bad key derivation '%s'", line)
+		}
+		n[i] = v
+	}
+	return keyKDF{Name: fields[1], Time: uint32(n[0]), Memory: uint32(n[1]), Threads: uint8(n[2])}, nil
+}
+
+func (k keyKDF) String() string {
+	return fmt.Sprintf("kdf %s %d %d %d", k.Name, k.Time, k.Memory, k.Threads)
+}
+
+// derive returns the size byte key k derives from password and salt.
+func (k keyKDF) derive(password, salt []byte, size uint32) []byte {
+	if k.Name == "pbkdf2-sha256" {
+		return pbkdf2.Key(password, salt, int(k.Time), int(size), sha256.New)
+	}
+	return argon2.IDKey(password, salt, k.Time, k.Memory, k.Threads, size)
+}
+
+// readPassphrase runs passphrase_command on the terminal, so that it can
+// prompt, and returns what it prints without the trailing newline.
+func readPassphrase(cfg Configuration) ([]byte, error) {
+	argv, err := splitCommandLine(cfg.PassphraseCommand)
+	if err != nil || len(argv) == 0 {
+		return nil, fmt.Errorf("passphrase_command '%s' can't be run", cfg.PassphraseCommand)
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin, cmd.Stderr = os.Stdin, os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("passphrase_command failed: %w", err)
+	}
+	out = bytes.TrimRight(out, "\r\n")
+	if len(out) == 0 {
+		return nil, errors.New("passphrase_command printed no passphrase")
+	}
+	return out, nil
+}
+
+// load derives the key and checks it against the root's key file, creating
+// the file with a new salt when there is none.
+func (c *entryCipher) load() (cipher.AEAD, error) {
+	c.once.Do(func() {
+		c.aead, c.err = loadEntryKey(c.cfg)
+	})
+	return c.aead, c.err
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func loadEntryKey(cfg Configuration) (cipher.AEAD, error) {
+	pass, err := readPassphrase(cfg)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(cfg.Root, cryptKeyFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return createEntryKey(path, pass)
+	}
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	kdf := keyKDF{Name: "pbkdf2-sha256", Time: pbkdf2Iterations}
+	switch {
+	case len(lines) == 3 && lines[0] == cryptKeyVersionV1:
+	case len(lines) == 4 && lines[0] == cryptKeyVersion:
+		kdf, err = parseKeyKDF(lines[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		lines = append(lines[:1], lines[2:]...)
+	default:
+		return nil, fmt.Errorf("%s is not a wm key file", path)
+	}
+	if !strings.HasPrefix(lines[1], "salt ") || !strings.HasPrefix(lines[2], "check ") {
+		return nil, fmt.Errorf("%s is not a wm key file", path)
+	}
+	salt, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(lines[1], "salt "))
+	if err != nil {
+		return nil, fmt.Errorf("%s has a bad salt: %w", path, err)
+	}
+	check, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(lines[2], "check "))
+	if err != nil {
+		return nil, fmt.Errorf("%s has a bad check: %w", path, err)
+	}
+	aead, err := newAEAD(kdf.derive(pass, salt, cryptKeySize))
+	if err != nil {
+		return nil, err
+	}
+	if plain, err := openSealed(aead, check, nil); err != nil || string(plain) != cryptCheck {
+		return nil, fmt.Errorf("wrong passphrase for %s", cfg.Root)
+	}
+	return aead, nil
+}
+
+func createEntryKey(path string, pass []byte) (cipher.AEAD, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(defaultKeyKDF.derive(pass, salt, cryptKeySize))
+	if err != nil {
+		return nil, err
+	}
+	check, err := seal(aead, []byte(cryptCheck), nil)
+	if err != nil {
+		return nil, err
+	}
+	content := fmt.Sprintf("%s\n%s\nsalt %s\ncheck %s\n", cryptKeyVersion, defaultKeyKDF,
+		base64.StdEncoding.EncodeToString(salt), base64.StdEncoding.EncodeToString(check))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the key file: %w", err)
+	}
+	_, err = f.WriteString(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write the key file: %w", err)
+	}
+	return aead, nil
+}
+
+// seal returns a random nonce followed by data sealed with it and ad.
+func seal(aead cipher.AEAD, data, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, ad), nil
+}
+
+func openSealed(aead cipher.AEAD, data, ad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("truncated")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], ad)
+}
+
+// isEncrypted reports whether data is an encrypted entry.
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(cryptMagic)) || bytes.HasPrefix(data, []byte(cryptMagicV1))
+}
+
+// sealedPath is the path an entry is sealed at: relative to the root, or
+// the extra root, it is under, with forward slashes, so that it is the same
+// on every machine the root is synced to.
+func sealedPath(cfg Configuration, path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	rel := ""
+	for _, root := range append([]string{cfg.Root}, cfg.ExtraRoots...) {
+		r, err := filepath.Rel(root, abs)
+		if err != nil {
+			continue
+		}
+		if !strings.HasPrefix(r, "..") {
+			return filepath.ToSlash(r)
+		}
+		if len(rel) == 0 {
+			rel = r
+		}
+	}
+	if len(rel) == 0 {
+		return filepath.ToSlash(abs)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// isEntryLocation reports whether rel, a sealed path, is where an entry
+// lives under its root, rather than a copy kept in one of wm's own
+// directories, such as a backup, or outside the root, such as in an
+// archive.
+func isEntryLocation(rel string) bool {
+	if strings.HasPrefix(rel, "..") || filepath.IsAbs(filepath.FromSlash(rel)) {
+		return false
+	}
+	for _, dir := range strings.Split(rel, "/")[:strings.Count(rel, "/")] {
+		if strings.HasPrefix(dir, ".") || containsString(internalDirs, dir) {
+			return false
+		}
+	}
+	return true
+}
+
+// sealEntry returns data as it is written to the entry at path: encrypted
+// while encrypt is on, with the entry's sealed path as the additional data,
+// and unchanged otherwise.
+func sealEntry(cfg Configuration, path string, data []byte) ([]byte, error) {
+	c := entryCrypt(cfg)
+	if c == nil {
+		return data, nil
+	}
+	aead, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	rel := sealedPath(cfg, path)
+	sealed, err := seal(aead, data, []byte(rel))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(cryptMagic+rel+"\n"), sealed...), nil
+}
+
+// splitSealed returns the path data was sealed at and the nonce and sealed
+// content after it, the path being "" for entries sealed without one.
+func splitSealed(data []byte) (string, []byte, error) {
+	if bytes.HasPrefix(data, []byte(cryptMagicV1)) {
+		return "", data[len(cryptMagicV1):], nil
+	}
+	rest := data[len(cryptMagic):]
+	i := bytes.IndexByte(rest, '\n')
+	if i <= 0 {
+		return "", nil, errors.New("truncated")
+	}
+	return string(rest[:i]), rest[i+1:], nil
+}
+
+// openEntry returns the content of an entry read as data from path,
+// decrypting it when it is encrypted.  An entry sealed at another path is
+// refused when path is where an entry lives, as it was moved there other
+// than by wm, or swapped with another, though a copy of it elsewhere, such
+// as a backup, still opens.
+func openEntry(cfg Configuration, path string, data []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		return data, nil
+	}
+	c := entryCrypt(cfg)
+	if c == nil {
+		return nil, fmt.Errorf("%s is encrypted; set encrypt = true and passphrase_command to read it", path)
+	}
+	plain, sealedAt, err := c.unseal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	if rel := sealedPath(cfg, path); len(sealedAt) > 0 && sealedAt != rel && isEntryLocation(rel) {
+		return nil, fmt.Errorf("%s was encrypted as %s; move it back, or with \"wm move\"", path, sealedAt)
+	}
+	return plain, nil
+}
+
+// unseal decrypts the encrypted entry data, returning its content and the
+// path it was sealed at.
+func (c *entryCipher) unseal(data []byte) ([]byte, string, error) {
+	aead, err := c.load()
+	if err != nil {
+		return nil, "", err
+	}
+	sealedAt, sealed, err := splitSealed(data)
+	if err != nil {
+		return nil, "", err
+	}
+	var ad []byte
+	if len(sealedAt) > 0 {
+		ad = []byte(sealedAt)
+	}
+	plain, err := openSealed(aead, sealed, ad)
+	return plain, sealedAt, err
+}
+
+// resealMoved encrypts the entry just moved to path again at its new path,
+// when it is encrypted and was sealed at another, so that it opens there.
+func resealMoved(cfg Configuration, path string) error {
+	c := entryCrypt(cfg)
+	if c == nil {
+		return nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil || !isEncrypted(raw) {
+		return err
+	}
+	plain, sealedAt, err := c.unseal(raw)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	if sealedAt == sealedPath(cfg, path) {
+		return nil
+	}
+	_, err = rewriteEntry(cfg, path, plain, rewriteOptions{KeepModTime: true})
+	return err
+}
+
+// readEntry is readSynced for entries: an encrypted one is decrypted in
+// memory.  Every command reading what entries say goes through it.
+func readEntry(cfg Configuration, path string) ([]byte, error) {
+	data, err := readSynced(path)
+	if err != nil {
+		return nil, err
+	}
+	return openEntry(cfg, path, data)
+}
+
+// shred overwrites the file at path with zeros before removing it.  On
+// copy-on-write and journaling file systems and SSDs the old blocks may
+// survive, so this only keeps the plain text from lying around in the
+// temporary directory.
+func shred(path string) {
+	if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+		if info, err := f.Stat(); err == nil {
+			io.CopyN(f, zeroReader{}, info.Size())
+			f.Sync()
+		}
+		f.Close()
+	}
+	os.Remove(path)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// editDecrypted decrypts the entries at paths into temporary files only the
+// user can read, runs edit on them, which must not return before the editor
+// has exited, and encrypts back what changed, along with entries still in
+// plain text.  What changed is encrypted back even when edit fails, whose
+// error is then returned.  The temporary files are shredded in any case.  A wrong
+// passphrase fails here, before edit is run.
+func editDecrypted(cfg Configuration, paths []string, edit func(files []string) error) error {
+	type decrypted struct {
+		path, tmp string
+		plain     []byte
+		sealed    bool
+	}
+	var files []decrypted
+	defer func() {
+		for _, f := range files {
+			shred(f.tmp)
+		}
+	}()
+	var tmps []string
+	for _, p := range paths {
+		raw, err := readSynced(p)
+		if err != nil {
+			return err
+		}
+		plain, err := openEntry(cfg, p, raw)
+		if err != nil {
+			return err
+		}
+		f, err := os.CreateTemp("", "wm-*-"+filepath.Base(p))
+		if err != nil {
+			return err
+		}
+		files = append(files, decrypted{p, f.Name(), plain, isEncrypted(raw)})
+		tmps = append(tmps, f.Name())
+		err = f.Chmod(0o600)
+		if err == nil {
+			_, err = f.Write(plain)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// An editor that saved and then failed still keeps what it saved.
+	editErr := edit(tmps)
+	for _, f := range files {
+		edited, err := os.ReadFile(f.tmp)
+		if err != nil {
+			if editErr != nil {
+				return editErr
+			}
+			return err
+		}
+		changed := !bytes.Equal(edited, f.plain)
+		if !changed && (f.sealed || editErr != nil) {
+			continue
+		}
+		if _, err := rewriteEntry(cfg, f.path, edited, rewriteOptions{KeepModTime: !changed}); err != nil {
+			return fmt.Errorf("failed to encrypt %s, the edit is kept in %s: %w", f.path, keepEdit(f.tmp), err)
+		}
+	}
+	return editErr
+}
+
+// keepEdit moves an edit that couldn't be encrypted out of the way of the
+// shredding, so that it isn't lost.
+func keepEdit(tmp string) string {
+	kept := tmp + ".kept"
+	if err := os.Rename(tmp, kept); err != nil {
+		return tmp
+	}
+	return kept
+}
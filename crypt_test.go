@@ -0,0 +1,282 @@
+package wm
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func TestKeyKDFVectors(t *testing.T) {
+	tests := []struct {
+		kdf            keyKDF
+		password, salt string
+		want           string
+	}{
+		// RFC 7914, section 11, cut to 32 bytes
+		{keyKDF{Name: "pbkdf2-sha256", Time: 1}, "passwd", "salt",
+			"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"},
+		{keyKDF{Name: "pbkdf2-sha256", Time: 80000}, "Password", "NaCl",
+			"4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56"},
+		// the Argon2id vectors of golang.org/x/crypto/argon2, 24 bytes
+		{keyKDF{Name: "argon2id", Time: 1, Memory: 64, Threads: 1}, "password", "somesalt",
+			"655ad15eac652dc59f7170a7332bf49b8469be1fdb9c28bb"},
+		{keyKDF{Name: "argon2id", Time: 2, Memory: 64, Threads: 2}, "password", "somesalt",
+			"350ac37222f436ccb5c0972f1ebd3bf6b958bf2071841362"},
+	}
+	for _, tt := range tests {
+		want, _ := hex.DecodeString(tt.want)
+		got := tt.kdf.derive([]byte(tt.password), []byte(tt.salt), uint32(len(want)))
+		if !bytes.Equal(got, want) {
+			t.Errorf("%v of %q, %q = %x, want %s", tt.kdf, tt.password, tt.salt, got, tt.want)
+		}
+	}
+}
+
+func TestParseKeyKDF(t *testing.T) {
+	k, err := parseKeyKDF(defaultKeyKDF.String())
+	if err != nil || k != defaultKeyKDF {
+		t.Errorf("parseKeyKDF(%q) = %v, %v, want %v", defaultKeyKDF.String(), k, err, defaultKeyKDF)
+	}
+	for _, line := range []string{
+		"kdf scrypt 3 65536 4",
+		"kdf argon2id 3 65536",
+		"kdf argon2id 0 65536 4",
+		"kdf argon2id 3 65536 256",
+		"salt argon2id 3 65536 4",
+	} {
+		if _, err := parseKeyKDF(line); err == nil {
+			t.Errorf("parseKeyKDF(%q) succeeded, want an error", line)
+		}
+	}
+}
+
+// testCrypt returns a configuration of root with encryption on and a random
+// key, as if its key file had been read.
+func testCrypt(t *testing.T, root string) Configuration {
+	t.Helper()
+	key := make([]byte, cryptKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Configuration{Root: root, Encrypt: true}
+	c := entryCrypt(cfg)
+	c.once.Do(func() { c.aead = aead })
+	t.Cleanup(func() {
+		ciphers.Lock()
+		defer ciphers.Unlock()
+		for key, held := range ciphers.byKey {
+			if held == c {
+				delete(ciphers.byKey, key)
+			}
+		}
+	})
+	return cfg
+}
+
+func TestSealEntryBindsPath(t *testing.T) {
+	root := t.TempDir()
+	cfg := testCrypt(t, root)
+	path := filepath.Join(root, "2024", "3", "7.txt")
+	sealed, err := sealEntry(cfg, path, []byte("the seventh\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := cryptMagic + "2024/3/7.txt\n"; !strings.HasPrefix(string(sealed), want) {
+		t.Fatalf("sealed entry starts %q, want %q", sealed[:len(want)], want)
+	}
+
+	plain, err := openEntry(cfg, path, sealed)
+	if err != nil || string(plain) != "the seventh\n" {
+		t.Errorf("opening at its own path = %q, %v", plain, err)
+	}
+	// a backup or trashed copy opens, an entry moved or swapped by hand
+	// doesn't
+	backup := filepath.Join(root, versionsDir, "2024", "3", "7.txt.20240307T120000.000000000")
+	if plain, err := openEntry(cfg, backup, sealed); err != nil || string(plain) != "the seventh\n" {
+		t.Errorf("opening its backup = %q, %v", plain, err)
+	}
+	if _, err := openEntry(cfg, filepath.Join(root, "2024", "3", "8.txt"), sealed); err == nil {
+		t.Error("opening it as 2024/3/8.txt succeeded, want an error")
+	}
+
+	// the path in front is what it is sealed with, so changing it fails
+	forged := bytes.Replace(sealed, []byte("2024/3/7.txt"), []byte("2024/3/8.txt"), 1)
+	if _, err := openEntry(cfg, filepath.Join(root, "2024", "3", "8.txt"), forged); err == nil {
+		t.Error("opening it with its path rewritten succeeded, want an error")
+	}
+}
+
+func TestOpenEntryVersion1(t *testing.T) {
+	root := t.TempDir()
+	cfg := testCrypt(t, root)
+	sealed, err := seal(entryCrypt(cfg).aead, []byte("old\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append([]byte(cryptMagicV1), sealed...)
+	plain, err := openEntry(cfg, filepath.Join(root, "2020", "1", "1.txt"), data)
+	if err != nil || string(plain) != "old\n" {
+		t.Errorf("opening a version 1 entry = %q, %v", plain, err)
+	}
+}
+
+func TestResealMoved(t *testing.T) {
+	root := t.TempDir()
+	cfg := testCrypt(t, root)
+	src := filepath.Join(root, "7.txt")
+	sealed, err := sealEntry(cfg, src, []byte("moved\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(root, "8.txt")
+	if err := os.WriteFile(dest, sealed, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readEntry(cfg, dest); err == nil {
+		t.Fatal("reading it before resealing succeeded, want an error")
+	}
+	if err := resealMoved(cfg, dest); err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := readEntry(cfg, dest); err != nil || string(plain) != "moved\n" {
+		t.Errorf("reading it after resealing = %q, %v", plain, err)
+	}
+}
+
+// passphraseConfig returns a configuration of root whose passphrase_command
+// prints pass.
+func passphraseConfig(t *testing.T, root, pass string) Configuration {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the passphrase command is a shell script")
+	}
+	script := filepath.Join(t.TempDir(), "pass")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho '"+pass+"'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return Configuration{Root: root, Encrypt: true, PassphraseCommand: script}
+}
+
+func TestLoadEntryKey(t *testing.T) {
+	root := t.TempDir()
+	if _, err := loadEntryKey(passphraseConfig(t, root, "secret")); err != nil {
+		t.Fatalf("creating the key file: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, cryptKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	if lines[0] != cryptKeyVersion || lines[1] != defaultKeyKDF.String() {
+		t.Errorf("key file starts %q, want %q and %q", lines[:2], cryptKeyVersion, defaultKeyKDF)
+	}
+	if _, err := loadEntryKey(passphraseConfig(t, root, "secret")); err != nil {
+		t.Errorf("reading the key file back: %v", err)
+	}
+	if _, err := loadEntryKey(passphraseConfig(t, root, "wrong")); err == nil {
+		t.Error("a wrong passphrase was taken")
+	}
+}
+
+func TestLoadEntryKeyVersion1(t *testing.T) {
+	root := t.TempDir()
+	salt := []byte("0123456789abcdef")
+	aead, err := newAEAD(pbkdf2.Key([]byte("secret"), salt, pbkdf2Iterations, cryptKeySize, sha256.New))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check, err := seal(aead, []byte(cryptCheck), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := fmt.Sprintf("%s\nsalt %s\ncheck %s\n", cryptKeyVersionV1,
+		base64.StdEncoding.EncodeToString(salt), base64.StdEncoding.EncodeToString(check))
+	if err := os.WriteFile(filepath.Join(root, cryptKeyFile), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadEntryKey(passphraseConfig(t, root, "secret")); err != nil {
+		t.Errorf("reading a version 1 key file: %v", err)
+	}
+	if _, err := loadEntryKey(passphraseConfig(t, root, "wrong")); err == nil {
+		t.Error("a wrong passphrase was taken for a version 1 key file")
+	}
+}
+
+func TestEditDecryptedEditorFails(t *testing.T) {
+	root := t.TempDir()
+	cfg := testCrypt(t, root)
+	path := filepath.Join(root, "2024", "3", "7.txt")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := sealEntry(cfg, path, []byte("before\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, sealed, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// an editor that saves and then exits non-zero
+	errEditor := errors.New("exit status 1")
+	var tmps []string
+	err = editDecrypted(cfg, []string{path}, func(files []string) error {
+		tmps = files
+		if err := os.WriteFile(files[0], []byte("after\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return errEditor
+	})
+	if !errors.Is(err, errEditor) {
+		t.Fatalf("editDecrypted = %v, want the editor's error", err)
+	}
+	if plain, err := readEntry(cfg, path); err != nil || string(plain) != "after\n" {
+		t.Errorf("entry after the failed edit = %q, %v, want the edit kept", plain, err)
+	}
+	if raw, _ := os.ReadFile(path); !isEncrypted(raw) {
+		t.Error("the kept edit was written in plain text")
+	}
+	for _, tmp := range tmps {
+		if _, err := os.Stat(tmp); err == nil {
+			t.Errorf("%s was left behind", tmp)
+		}
+	}
+}
+
+func TestEntryCryptPerConfig(t *testing.T) {
+	root := t.TempDir()
+	encrypted := testCrypt(t, root)
+	plain := Configuration{Root: root}
+	if entryCrypt(plain) != nil {
+		t.Fatal("a configuration without encrypt has a cipher")
+	}
+	path := filepath.Join(root, "2024", "3", "7.txt")
+	sealed, err := sealEntry(encrypted, path, []byte("secret\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := sealEntry(plain, path, []byte("open\n")); err != nil || string(data) != "open\n" {
+		t.Errorf("sealing without encrypt = %q, %v, want it unchanged", data, err)
+	}
+	if _, err := openEntry(plain, path, sealed); err == nil {
+		t.Error("opening an encrypted entry without encrypt succeeded, want an error")
+	}
+	if data, err := openEntry(encrypted, path, sealed); err != nil || string(data) != "secret\n" {
+		t.Errorf("opening it with encrypt = %q, %v", data, err)
+	}
+}
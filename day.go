@@ -0,0 +1,101 @@
+package wm
+
+import (
+	"fmt"
+	"time"
+)
+
+// dayStartHour and dayLocation decide which calendar day it is: with
+// day_start_hour = 4, 02:30 still counts as the previous day, and timezone
+// names the zone whose calendar is used.  Both are set when the configuration
+// is loaded.
+var (
+	dayStartHour = 0
+	dayLocation  = time.Local
+)
+
+// dayStartSetting returns the configured day start, given as day_start_hour
+// or day_rollover_hour.
+func dayStartSetting(c Configuration) int {
+	if c.DayStartHour != 0 {
+		return c.DayStartHour
+	}
+	return c.DayRolloverHour
+}
+
+// setDayBoundary validates and selects day_start_hour and timezone.
+func setDayBoundary(hour int, tz string) error {
+	if hour < 0 || hour > 23 {
+		return fmt.Errorf("day_start_hour must be between 0 and 23, not %d", hour)
+	}
+	loc := time.Local
+	if len(tz) > 0 {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return fmt.Errorf("unknown timezone '%s'", tz)
+		}
+		loc = l
+	}
+	dayStartHour, dayLocation = hour, loc
+	return nil
+}
+
+// dayAt returns t shifted so that its calendar day is the working day it
+// belongs to.  Only the date of the result is meaningful.  The day start is
+// compared with the wall clock rather than subtracted as a duration, which
+// would land on the wrong day when the clocks changed overnight.
+func dayAt(t time.Time) time.Time {
+	t = t.In(dayLocation)
+	y, m, d := t.Date()
+	if t.Hour() < dayStartHour {
+		d--
+	}
+	return time.Date(y, m, d, 12, 0, 0, 0, time.Local)
+}
+
+// daysBetween is the number of calendar days from a to b, negative when b is
+// earlier.  It counts on UTC dates, whose days are all 24 hours long, so
+// daylight saving changes in between never make it one off.
+func daysBetween(a, b DatePath) int {
+	ta := time.Date(a.year, time.Month(a.month), a.day, 0, 0, 0, 0, time.UTC)
+	tb := time.Date(b.year, time.Month(b.month), b.day, 0, 0, 0, 0, time.UTC)
+	return int(tb.Sub(ta) / (24 * time.Hour))
+}
+
+// addAge moves t by age, counting whole days, such as the "7d" of parseAge,
+// as calendar days so that a daylight saving change in between doesn't
+// shift the result by an hour.  Other ages are elapsed time.
+func addAge(t time.Time, age time.Duration) time.Time {
+	if age%(24*time.Hour) == 0 {
+		return t.AddDate(0, 0, int(age/(24*time.Hour)))
+	}
+	return t.Add(age)
+}
+
+// dayNow is dayAt for the time the command started.  Date keywords and
+// range flags are resolved against it.
+func dayNow() time.Time {
+	if invoked.IsZero() {
+		return dayAt(now())
+	}
+	return dayAt(invoked)
+}
+
+// historyEntries drops the entries dated after today unless includeFuture is
+// set.  Commands that summarize history, such as last, stats, streaks, gaps,
+// and review, see the archive through it so that files created ahead of time
+// for planning don't count as days already written; commands that address
+// explicit dates use every entry.
+func historyEntries(entries []Entry, includeFuture bool) []Entry {
+	if includeFuture {
+		return entries
+	}
+	today := datePathFromTime(dayNow())
+	var past []Entry
+	for _, e := range entries {
+		if !today.Before(&e.Date) {
+			past = append(past, e)
+		}
+	}
+	return past
+}
@@ -0,0 +1,270 @@
+package wm
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNow fixes the clock at t, with the day boundary of hour and tz, for
+// the test.
+func testNow(t *testing.T, now time.Time, hour int, tz string) {
+	t.Helper()
+	if err := setDayBoundary(hour, tz); err != nil {
+		t.Skip(err)
+	}
+	clock, invoked = fixedClock(now), time.Time{}
+	t.Cleanup(func() {
+		clock, invoked = realClock{}, time.Time{}
+		setDayBoundary(0, "")
+	})
+}
+
+func TestHistoryEntriesCutoff(t *testing.T) {
+	var entries []Entry
+	for _, d := range []int{6, 7, 8} {
+		entries = append(entries, Entry{Date: DatePath{2024, 3, d}})
+	}
+	local := func(day, hour, min int) time.Time { return time.Date(2024, 3, day, hour, min, 0, 0, time.Local) }
+	for _, tt := range []struct {
+		name string
+		now  time.Time
+		hour int
+		tz   string
+		last int
+	}{
+		{"before midnight", local(7, 23, 59), 0, "", 7},
+		{"at midnight", local(8, 0, 0), 0, "", 8},
+		{"before the day starts", local(8, 3, 59), 4, "", 7},
+		{"as the day starts", local(8, 4, 0), 4, "", 8},
+		{"ahead in Tokyo", time.Date(2024, 3, 7, 23, 30, 0, 0, time.UTC), 0, "Asia/Tokyo", 8},
+		{"behind in New York", time.Date(2024, 3, 8, 3, 0, 0, 0, time.UTC), 0, "America/New_York", 7},
+		{"New York before its day starts", time.Date(2024, 3, 8, 7, 0, 0, 0, time.UTC), 4, "America/New_York", 7},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testNow(t, tt.now, tt.hour, tt.tz)
+			past := historyEntries(entries, false)
+			if got := past[len(past)-1].Date.day; got != tt.last {
+				t.Errorf("the last entry of history is March %d, want March %d", got, tt.last)
+			}
+			if got := historyEntries(entries, true); len(got) != len(entries) {
+				t.Errorf("with future entries included, history has %d entries, want %d", len(got), len(entries))
+			}
+		})
+	}
+}
+
+func TestLastEntryBeforeSkipsFuture(t *testing.T) {
+	for _, layout := range []string{"nested", "flat"} {
+		cfg := Configuration{Root: t.TempDir(), PathLayout: layout}
+		for _, pd := range []DatePath{{2024, 3, 1}, {2024, 3, 9}} {
+			testEntryBody(t, cfg, pd, "planned\n")
+		}
+		last, ok, err := lastEntryBefore(cfg, &DatePath{2024, 3, 7})
+		if err != nil || !ok || last != (DatePath{2024, 3, 1}) {
+			t.Errorf("%s: lastEntryBefore(2024-03-07) = %v, %v, %v, want 2024-03-01", layout, last, ok, err)
+		}
+	}
+}
+
+func TestHistoryCommandsSkipFuture(t *testing.T) {
+	root := t.TempDir()
+	for d := 5; d <= 9; d++ {
+		testEntryBody(t, Configuration{Root: root}, DatePath{2024, 3, d}, "plan\n")
+	}
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+	wm := func(now string, args ...string) string {
+		t.Helper()
+		stdout, stderr, code := runWM(t, root, append(env, "WM_NOW="+now), args...)
+		if code != exitOK {
+			t.Fatalf("wm %q at %s exited %d: %s", args, now, code, stderr)
+		}
+		return string(stdout)
+	}
+
+	stats := func(now string, args ...string) (s rangeStats) {
+		t.Helper()
+		if err := json.Unmarshal([]byte(wm(now, append([]string{"stats", "--json"}, args...)...)), &s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	for _, tt := range []struct {
+		now     string
+		entries int
+	}{{"2024-03-07T23:59", 3}, {"2024-03-08T00:00", 4}} {
+		if s := stats(tt.now); s.Entries != tt.entries || s.LongestStreak != tt.entries || s.CurrentStreak != tt.entries {
+			t.Errorf("stats at %s = %d entries, streaks %d and %d, want %d of each", tt.now, s.Entries, s.CurrentStreak, s.LongestStreak, tt.entries)
+		}
+	}
+	if s := stats("2024-03-07T23:59", "--include-future"); s.Entries != 5 || s.LongestStreak != 5 {
+		t.Errorf("stats --include-future = %d entries, longest streak %d, want 5 and 5", s.Entries, s.LongestStreak)
+	}
+
+	want := filepath.Join(root, "2024", "3", "7.txt")
+	if got := strings.TrimSpace(wm("2024-03-07T23:59", "last", "--print-path")); got != want {
+		t.Errorf("last = %s, want %s", got, want)
+	}
+	want = filepath.Join(root, "2024", "3", "9.txt")
+	if got := strings.TrimSpace(wm("2024-03-07T23:59", "last", "--include-future", "--print-path")); got != want {
+		t.Errorf("last --include-future = %s, want %s", got, want)
+	}
+	if got := wm("2024-03-07T23:59", "review", "--year=2024"); !strings.Contains(got, "- Entries: 3\n") {
+		t.Errorf("review = %s, want 3 entries", got)
+	}
+	if got := wm("2024-03-07T23:59", "cat", "2024-03-09"); !strings.Contains(got, "plan") {
+		t.Errorf("cat of a future date = %q, want its entry", got)
+	}
+
+	// with the day starting at 04:00, 02:00 is still the day before
+	testConfigAppend(t, cfgFile, "day_start_hour = 4\n")
+	if s := stats("2024-03-08T02:00"); s.Entries != 3 {
+		t.Errorf("stats at 02:00 with day_start_hour = 4 = %d entries, want 3", s.Entries)
+	}
+	if s := stats("2024-03-08T04:00"); s.Entries != 4 {
+		t.Errorf("stats at 04:00 with day_start_hour = 4 = %d entries, want 4", s.Entries)
+	}
+}
+
+// testLocal makes tz the local zone for the test, as it is for a user who
+// lives there, skipping when the zone isn't installed.
+func testLocal(t *testing.T, tz string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		t.Skip(err)
+	}
+	saved := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = saved })
+	return loc
+}
+
+// dstChanges are days on which the clocks change: the spring and fall
+// transitions of 2024 in two zones that change at different hours.
+var dstChanges = []struct {
+	tz   string
+	days []DatePath
+}{
+	{"America/New_York", []DatePath{{2024, 3, 10}, {2024, 11, 3}}},
+	{"Europe/Berlin", []DatePath{{2024, 3, 31}, {2024, 10, 27}}},
+}
+
+func TestDayArithmeticDST(t *testing.T) {
+	for _, z := range dstChanges {
+		testLocal(t, z.tz)
+		for _, pd := range z.days {
+			before, after := pd.addDays(-1), pd.addDays(1)
+			if daysBetween(before, after) != 2 || daysBetween(after, before) != -2 || daysBetween(pd, pd) != 0 {
+				t.Errorf("%s: daysBetween around %s = %d, %d, want 2, -2", z.tz, pd.Iso(), daysBetween(before, after), daysBetween(after, before))
+			}
+			if next := before.addDays(1); next != pd {
+				t.Errorf("%s: %s + 1 day = %s, want %s", z.tz, before.Iso(), next.Iso(), pd.Iso())
+			}
+			first, last := pd.addDays(-2), pd.addDays(2)
+			if week, want := first.addDays(7), last.addDays(3); week != want {
+				t.Errorf("%s: a week from %s = %s, want %s", z.tz, first.Iso(), week.Iso(), want.Iso())
+			}
+			if n := (templateRange{From: before, To: after}).days(); n != 3 {
+				t.Errorf("%s: %s to %s = %d days, want 3", z.tz, before.Iso(), after.Iso(), n)
+			}
+
+			noon := time.Date(before.year, time.Month(before.month), before.day, 12, 0, 0, 0, time.Local)
+			for _, tt := range []struct {
+				age  time.Duration
+				want time.Time
+			}{
+				{24 * time.Hour, time.Date(pd.year, time.Month(pd.month), pd.day, 12, 0, 0, 0, time.Local)},
+				{7 * 24 * time.Hour, noon.AddDate(0, 0, 7)},
+				{12 * time.Hour, noon.Add(12 * time.Hour)},
+			} {
+				if got := addAge(noon, tt.age); !got.Equal(tt.want) || got.Hour() != tt.want.Hour() {
+					t.Errorf("%s: addAge(%s, %s) = %s, want %s", z.tz, noon, tt.age, got, tt.want)
+				}
+			}
+
+			days := map[DatePath]bool{}
+			for d := first; !last.Before(&d); d = d.addDays(1) {
+				days[d] = true
+			}
+			if current, longest, from := streaks(days, last); current != 5 || longest != 5 || from != first {
+				t.Errorf("%s: streaks across %s = %d, %d from %s, want 5, 5 from %s", z.tz, pd.Iso(), current, longest, from.Iso(), first.Iso())
+			}
+		}
+	}
+}
+
+func TestDayKeywordsDST(t *testing.T) {
+	for _, z := range dstChanges {
+		loc := testLocal(t, z.tz)
+		for _, pd := range z.days {
+			at := func(d DatePath, hour, min int) time.Time {
+				return time.Date(d.year, time.Month(d.month), d.day, hour, min, 0, 0, loc)
+			}
+			for _, tt := range []struct {
+				now   time.Time
+				start int
+				in    string
+				want  DatePath
+			}{
+				{at(pd.addDays(1), 0, 30), 0, "yesterday", pd},
+				{at(pd.addDays(1), 0, 30), 0, "today", pd.addDays(1)},
+				{at(pd.addDays(1), 0, 30), 0, "3 days ago", pd.addDays(-2)},
+				{at(pd, 0, 30), 0, "yesterday", pd.addDays(-1)},
+				{at(pd, 23, 30), 0, "tomorrow", pd.addDays(1)},
+				{at(pd.addDays(-1), 23, 30), 0, "tomorrow", pd},
+				{at(pd, 23, 30), 0, "yesterday", pd.addDays(-1)},
+				// past the change, still before a day start of 4
+				{at(pd, 3, 30), 4, "today", pd.addDays(-1)},
+				{at(pd, 3, 30), 4, "yesterday", pd.addDays(-2)},
+				{at(pd.addDays(1), 3, 30), 4, "yesterday", pd.addDays(-1)},
+			} {
+				testNow(t, tt.now, tt.start, z.tz)
+				got, err := parseDateString(Configuration{}, tt.in)
+				if err != nil || *got != tt.want {
+					t.Errorf("%s at %s, day_start_hour %d: %s = %v, %v, want %s", z.tz, tt.now, tt.start, tt.in, got, err, tt.want.Iso())
+				}
+			}
+
+			now := at(pd.addDays(1), 0, 30)
+			testNow(t, now, 0, z.tz)
+			since, err := sinceTime(Configuration{}, "1d", now)
+			if want := at(pd, 0, 30); err != nil || !since.Equal(want) {
+				t.Errorf("%s: --since 1d at %s = %s, %v, want %s", z.tz, now, since, err, want)
+			}
+			r, err := resolveQuery(Configuration{}, Query{Since: "2d"}, now)
+			if day := pd.addDays(-1); err != nil || r.From == nil || *r.From != day {
+				t.Errorf("%s: --since 2d at %s = %+v, %v, want from %s", z.tz, now, r, err, day.Iso())
+			}
+		}
+	}
+}
+
+func TestSessionMarkerDST(t *testing.T) {
+	loc := testLocal(t, "America/New_York")
+	root := t.TempDir()
+	cfg := Configuration{Root: root, SessionMarkers: true}
+	pd := DatePath{2024, 3, 10}
+	testEntryBody(t, cfg, pd, "--- 01:50 ---\nbefore the change\n")
+	path, err := entryPath(cfg, &pd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := renderHeader(&pd) + "--- 01:50 ---\nbefore the change\n"
+
+	// 03:10 is 20 minutes after 01:50 on the wall clock that skipped an hour
+	testNow(t, time.Date(2024, 3, 10, 3, 10, 0, 0, loc), 0, "America/New_York")
+	if err := addSessionMarker(cfg, path, &pd, false); err != nil {
+		t.Fatal(err)
+	}
+	assertEntry(t, path, want)
+
+	testNow(t, time.Date(2024, 3, 10, 3, 55, 0, 0, loc), 0, "America/New_York")
+	if err := addSessionMarker(cfg, path, &pd, false); err != nil {
+		t.Fatal(err)
+	}
+	assertEntry(t, path, want+"--- 03:55 ---\n")
+}
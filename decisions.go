@@ -0,0 +1,132 @@
+package wm
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultDecisionPattern matches lines starting with "DECISION:", optionally
+// as a bullet.
+const defaultDecisionPattern = `^\s*(?:[-*+]\s+)?DECISION:\s*`
+
+// decision is one entry of the decision register.
+type decision struct {
+	Entry Entry
+	extracted
+}
+
+// collectDecisions extracts the decisions of every entry, oldest first.
+func collectDecisions(cfg Configuration, x *extractor, entries []Entry) ([]decision, error) {
+	var found []decision
+	for _, e := range entries {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", e.Path, err)
+		}
+		for _, item := range x.extract(data) {
+			found = append(found, decision{e, item})
+		}
+	}
+	return found, nil
+}
+
+// runDecisions writes the register of decisions in the range.
+func runDecisions(cfg Configuration, params Parameters) error {
+	x, err := newExtractor("decisions", cfg.Decisions, defaultDecisionPattern)
+	if err != nil {
+		return err
+	}
+	r, err := resolveQuery(cfg, queryFor(params), dayNow())
+	if err != nil {
+		return err
+	}
+	entries, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	found, err := collectDecisions(cfg, x, filterEntries(entries, r.From, r.To))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if len(params.Out) > 0 {
+		f, err := os.Create(params.Out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	switch params.Format {
+	case "", "human":
+		return decisionsHuman(w, cfg, found)
+	case "markdown", "md":
+		return decisionsMarkdown(w, cfg, found)
+	case "csv":
+		return decisionsCSV(w, cfg, found)
+	}
+	return fmt.Errorf("unknown decisions format '%s', expected human, markdown, or csv", params.Format)
+}
+
+func relEntryPath(cfg Configuration, p string) string {
+	rel, err := filepath.Rel(cfg.Root, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+func decisionsHuman(w io.Writer, cfg Configuration, found []decision) error {
+	if len(found) == 0 {
+		_, err := fmt.Fprintln(w, "no decisions found")
+		return err
+	}
+	for _, d := range found {
+		fmt.Fprintf(w, "%s  %s  (%s:%d)\n", d.Entry.Date.Iso(), d.Text, relEntryPath(cfg, d.Entry.Path), d.Line)
+		for _, c := range d.Continued {
+			fmt.Fprintf(w, "            %s\n", c)
+		}
+	}
+	return nil
+}
+
+// markdownCell escapes text for a Markdown table cell.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func decisionsMarkdown(w io.Writer, cfg Configuration, found []decision) error {
+	fmt.Fprintln(w, "# Decisions")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Date | Decision | Entry |")
+	fmt.Fprintln(w, "| --- | --- | --- |")
+	for _, d := range found {
+		text := markdownCell(d.Text)
+		for _, c := range d.Continued {
+			text += "<br>" + markdownCell(c)
+		}
+		link := (&url.URL{Scheme: "file", Path: filepath.ToSlash(d.Entry.Path)}).String()
+		_, err := fmt.Fprintf(w, "| %s | %s | [%s:%d](%s) |\n", d.Entry.Date.Iso(), text, relEntryPath(cfg, d.Entry.Path), d.Line, link)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decisionsCSV(w io.Writer, cfg Configuration, found []decision) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "decision", "file", "line"})
+	for _, d := range found {
+		text := strings.Join(append([]string{d.Text}, d.Continued...), "\n")
+		cw.Write([]string{d.Entry.Date.Iso(), text, relEntryPath(cfg, d.Entry.Path), fmt.Sprint(d.Line)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,92 @@
+package wm
+
+import (
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractDecisions(t *testing.T) {
+	x, err := newExtractor("decisions", ExtractConfig{}, defaultDecisionPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		text string
+		want []extracted
+	}{
+		{"alone", "DECISION: ship it\nlunch", []extracted{{Line: 1, Text: "ship it"}}},
+		{"indented lines", "DECISION: ship it\n  because QA passed\n    and Ann agreed\nlunch",
+			[]extracted{{Line: 1, Text: "ship it", Continued: []string{"because QA passed", "and Ann agreed"}}}},
+		{"bullets after a line", "DECISION: ship it\n- QA passed\n- Ann agreed\nlunch",
+			[]extracted{{Line: 1, Text: "ship it", Continued: []string{"- QA passed", "- Ann agreed"}}}},
+		{"sibling bullet", "- DECISION: ship it\n- lunch", []extracted{{Line: 1, Text: "ship it"}}},
+		{"nested under a bullet", "- DECISION: ship it\n  - QA passed\n- lunch",
+			[]extracted{{Line: 1, Text: "ship it", Continued: []string{"- QA passed"}}}},
+		{"tab", "\tDECISION: ship it\n\t\tbecause\n\tlunch", []extracted{{Line: 1, Text: "ship it", Continued: []string{"because"}}}},
+		{"blank line ends it", "DECISION: ship it\n\n  indented later", []extracted{{Line: 1, Text: "ship it"}}},
+		{"next decision ends it", "DECISION: ship it\n  DECISION: and tag it\n    why",
+			[]extracted{{Line: 1, Text: "ship it"}, {Line: 2, Text: "and tag it", Continued: []string{"why"}}}},
+		{"session marker ends it", "DECISION: ship it\n  --- 14:00 ---\n  later", []extracted{{Line: 1, Text: "ship it"}}},
+		{"crlf", "DECISION: ship it\r\n  because\r\nlunch", []extracted{{Line: 1, Text: "ship it", Continued: []string{"because"}}}},
+		{"nothing after the marker", "DECISION:\n  ship it", []extracted{{Line: 1, Text: "DECISION:", Continued: []string{"ship it"}}}},
+		{"mid-line", "no DECISION: yet\nlunch", nil},
+		{"lowercase", "decision: ship it", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := x.extract([]byte(tt.text)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extract(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractDecisionsConfigured(t *testing.T) {
+	off := false
+	x, err := newExtractor("decisions", ExtractConfig{Pattern: `^Decided:\s*`, Continuation: &off}, defaultDecisionPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := x.extract([]byte("DECISION: not this\nDecided: this\n  alone"))
+	if want := []extracted{{Line: 2, Text: "this"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("extract with a pattern and no continuation = %+v, want %+v", got, want)
+	}
+	if _, err := newExtractor("decisions", ExtractConfig{Pattern: "("}, defaultDecisionPattern); err == nil || !strings.Contains(err.Error(), "decisions.pattern") {
+		t.Errorf("newExtractor with a bad pattern = %v, want an error naming decisions.pattern", err)
+	}
+}
+
+func TestDecisionsRegister(t *testing.T) {
+	root := t.TempDir()
+	cfg := Configuration{Root: root}
+	testEntryBody(t, cfg, DatePath{2024, 3, 7}, "standup\n- DECISION: move | rename\n  - Ann agreed\n")
+	testEntryBody(t, cfg, DatePath{2024, 2, 28}, "DECISION: keep the parser\n")
+	testEntryBody(t, cfg, DatePath{2024, 3, 8}, "nothing decided\n")
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+
+	out, stderr, code := runWM(t, root, env, "decisions", "--format=csv", "--from=2024-01-01", "--to=2024-12-31")
+	if code != exitOK {
+		t.Fatalf("decisions exited %d: %s", code, stderr)
+	}
+	rows, err := csv.NewReader(strings.NewReader(string(out))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"date", "decision", "file", "line"},
+		{"2024-02-28", "keep the parser", "2024/2/28.txt", "5"},
+		{"2024-03-07", "move | rename\n- Ann agreed", "2024/3/7.txt", "6"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("decisions --format=csv = %q, want %q", rows, want)
+	}
+
+	out, _, _ = runWM(t, root, env, "decisions", "--format=markdown", "--from=2024-03-01", "--to=2024-03-31")
+	if !strings.Contains(string(out), `| 2024-03-07 | move \| rename<br>- Ann agreed | [2024/3/7.txt:6](file://`) || strings.Contains(string(out), "keep the parser") {
+		t.Errorf("decisions --format=markdown for March = %s", out)
+	}
+}
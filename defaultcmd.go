@@ -0,0 +1,45 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// defaultCommands are what default_command can make a bare "wm" run.
+var defaultCommands = []string{"open", "list", "last", "search"}
+
+// checkDefaultCommand validates default_command.
+func checkDefaultCommand(cfg Configuration) error {
+	if len(cfg.DefaultCommand) == 0 || containsString(defaultCommands, cfg.DefaultCommand) {
+		return nil
+	}
+	return fmt.Errorf(`config: "default_command" must be one of %s, got '%s'`, strings.Join(defaultCommands, ", "), cfg.DefaultCommand)
+}
+
+// defaultCommandArgs returns the arguments "wm" given none at all runs with,
+// as default_command says, or nil to open today's entry as ever.  docopt
+// binds no arguments the same as an empty date, so main asks this before
+// taking them for one.  "search" asks for the terms on a terminal.
+func defaultCommandArgs(cfg Configuration) ([]string, error) {
+	switch cfg.DefaultCommand {
+	case "", "open":
+		return nil, nil
+	case "search":
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, errors.New(`default_command = "search" asks for what to search for on a terminal; give the terms, as in "wm search <term>"`)
+		}
+		terms, err := splitCommandLine(ask("search for", ""))
+		if err != nil {
+			return nil, err
+		}
+		if len(terms) == 0 {
+			return nil, errors.New("nothing to search for")
+		}
+		return append([]string{"search"}, terms...), nil
+	}
+	return []string{cfg.DefaultCommand}, nil
+}
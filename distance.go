@@ -0,0 +1,65 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const defaultConfirmDistance = 365 * 24 * time.Hour
+
+// errCreationRefused is returned when the user declines to create a far-off
+// entry.
+var errCreationRefused = errors.New("creation refused")
+
+// describeDistance renders how far day is from today, e.g. "22 years ago" or
+// "in 3 months".
+func describeDistance(days int) string {
+	n, unit := days, "day"
+	if n < 0 {
+		n = -n
+	}
+	switch {
+	case n >= 365:
+		n, unit = n/365, "year"
+	case n >= 60:
+		n, unit = n/30, "month"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	if days < 0 {
+		return fmt.Sprintf("%d %s ago", n, unit)
+	}
+	return fmt.Sprintf("in %d %s", n, unit)
+}
+
+// confirmDistantDate asks before a new entry is created for a date further
+// from today than confirm_distance (default one year), which is usually a
+// typo.  --yes and a non-interactive stdin skip the question.
+func confirmDistantDate(cfg Configuration, pd *DatePath, yes bool) error {
+	limit := defaultConfirmDistance
+	if len(cfg.ConfirmDistance) > 0 {
+		var err error
+		limit, err = parseAge(cfg.ConfirmDistance)
+		if err != nil {
+			return fmt.Errorf("bad confirm_distance: %w", err)
+		}
+	}
+	today := datePathFromTime(dayNow())
+	days := daysBetween(today, *pd)
+	distance := time.Duration(days) * 24 * time.Hour
+	if distance < 0 {
+		distance = -distance
+	}
+	if distance <= limit || yes || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	if !confirm(fmt.Sprintf("create a new entry dated %s (%s)?", describeDistance(days), pd.Iso())) {
+		return fmt.Errorf("%w: no entry created for %s", errCreationRefused, pd.Iso())
+	}
+	return nil
+}
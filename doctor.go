@@ -0,0 +1,276 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// doctorStatus grades what a check found.  Only failures make doctor exit
+// non-zero.
+type doctorStatus int
+
+const (
+	doctorPass doctorStatus = iota
+	doctorWarn
+	doctorFail
+)
+
+func (s doctorStatus) String() string {
+	return [...]string{"PASS", "WARN", "FAIL"}[s]
+}
+
+// doctorResult is what one check found, with a hint on how to fix it when
+// it isn't a pass.
+type doctorResult struct {
+	Status  doctorStatus
+	Message string
+	Hint    string
+}
+
+func doctorOK(format string, args ...interface{}) doctorResult {
+	return doctorResult{Status: doctorPass, Message: fmt.Sprintf(format, args...)}
+}
+
+// doctorInput is what the checks look at: the configuration, even one that
+// couldn't be read, where it came from, and the error reading it gave.
+type doctorInput struct {
+	Cfg     Configuration
+	Source  configSource
+	LoadErr error
+}
+
+// doctorCheck inspects the setup for one kind of problem.  Every check
+// stands alone, so that one failing says nothing about the others.
+type doctorCheck struct {
+	Name  string
+	Check func(in doctorInput) doctorResult
+}
+
+var doctorChecks = []doctorCheck{
+	{"config file", checkConfigFile},
+	{"config keys", checkConfigKeys},
+	{"paths", checkPaths},
+	{"root", checkRoot},
+	{"editor", checkEditor},
+	{"dates", checkDates},
+	{"entries", checkEntries},
+	{"templates", checkTemplates},
+}
+
+// registerDoctorCheck adds a check to the set run by 'wm doctor'.
+func registerDoctorCheck(c doctorCheck) {
+	doctorChecks = append(doctorChecks, c)
+}
+
+// configRelative resolves a path from the configuration file against its
+// directory.
+func configRelative(cfg Configuration, p string) string {
+	p = expandPath(p)
+	if !filepath.IsAbs(p) && len(cfg.dir) > 0 {
+		return filepath.Join(cfg.dir, p)
+	}
+	return p
+}
+
+// checkConfigFile checks that the configuration file exists and reads.
+func checkConfigFile(in doctorInput) doctorResult {
+	path := in.Source.Path
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		hint := "run any command, such as \"wm list\", to create it with defaults"
+		if len(os.Getenv("WMCFG")) > 0 {
+			hint = "point $WMCFG at an existing file, or unset it"
+		}
+		return doctorResult{doctorFail, fmt.Sprintf("%s doesn't exist (%s)", path, in.Source.Reason), hint}
+	} else if err != nil {
+		return doctorResult{doctorFail, fmt.Sprintf("%s can't be read: %v", path, err), "check the file's permissions"}
+	}
+	if in.LoadErr != nil && !errors.Is(in.LoadErr, errInvalidConfig) {
+		return doctorResult{doctorFail, in.LoadErr.Error(), "fix the file, then run \"wm config --check\""}
+	}
+	return doctorOK("%s (%s)", path, in.Source.Reason)
+}
+
+// checkConfigKeys checks the settings against the schema and the way wm
+// reads them.
+func checkConfigKeys(in doctorInput) doctorResult {
+	data, err := os.ReadFile(in.Source.Path)
+	if err != nil {
+		return doctorResult{doctorWarn, "not checked, as the configuration file can't be read", ""}
+	}
+	var raw map[string]interface{}
+	if _, err := toml.Decode(string(data), &raw); err != nil {
+		return doctorResult{doctorWarn, "not checked, as the configuration file isn't valid TOML", ""}
+	}
+	s, err := configSchema()
+	if err != nil {
+		return doctorResult{doctorFail, err.Error(), ""}
+	}
+	problems := schemaProblems(s, raw, "")
+	if errors.Is(in.LoadErr, errInvalidConfig) {
+		problems = append(problems, in.LoadErr.Error())
+	}
+	if len(problems) > 0 {
+		return doctorResult{doctorFail, strings.Join(problems, "; "), "run \"wm config schema\" to see every key and what it takes"}
+	}
+	return doctorOK("%d settings, all valid", len(raw))
+}
+
+// checkPaths checks that "~" was expanded in the root and extra roots, and
+// that none of them has separators of another platform in it.
+func checkPaths(in doctorInput) doctorResult {
+	roots := append([]string{in.Cfg.Root}, in.Cfg.ExtraRoots...)
+	if len(in.Cfg.Root) == 0 {
+		return doctorResult{doctorWarn, "not checked, as no root is set", ""}
+	}
+	for _, p := range roots {
+		if strings.HasPrefix(p, "~") {
+			return doctorResult{doctorFail, fmt.Sprintf("%s wasn't expanded, as the home directory can't be found", p), "set $HOME, or give the root as an absolute path"}
+		}
+		if runtime.GOOS != "windows" && strings.Contains(p, `\`) {
+			return doctorResult{doctorWarn, fmt.Sprintf("%s has a backslash, which isn't a separator here", p), "write the path with forward slashes, which work everywhere"}
+		}
+	}
+	return doctorOK("%s", strings.Join(roots, ", "))
+}
+
+// checkRoot checks that the root is a directory wm can write to, by creating
+// and removing a probe file, or that it can be created.
+func checkRoot(in doctorInput) doctorResult {
+	root := in.Cfg.Root
+	if len(root) == 0 {
+		return doctorResult{doctorFail, "no root is set", "set root in the configuration file, as root = \"~/.wm/logs\""}
+	}
+	info, err := os.Stat(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		parent := filepath.Dir(root)
+		for !isDir(parent) && filepath.Dir(parent) != parent {
+			parent = filepath.Dir(parent)
+		}
+		if err := probeWrite(parent); err != nil {
+			return doctorResult{doctorFail, fmt.Sprintf("%s doesn't exist and can't be created: %v", root, err), "create it, or point root at a directory you can write to"}
+		}
+		return doctorResult{doctorWarn, fmt.Sprintf("%s doesn't exist yet", root), "it is created with the first entry, or run \"wm init\""}
+	} else if err != nil {
+		return doctorResult{doctorFail, fmt.Sprintf("%s can't be read: %v", root, err), "check its permissions"}
+	}
+	if !info.IsDir() {
+		return doctorResult{doctorFail, fmt.Sprintf("%s isn't a directory", root), "point root at a directory"}
+	}
+	if err := probeWrite(root); err != nil {
+		return doctorResult{doctorFail, fmt.Sprintf("%s isn't writable: %v", root, err), "check its permissions, or point root elsewhere"}
+	}
+	return doctorOK("%s is writable", root)
+}
+
+// probeWrite creates and removes a file in dir.
+func probeWrite(dir string) error {
+	f, err := os.CreateTemp(dir, ".wm-doctor-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkEditor checks that the editor's program can be found.
+func checkEditor(in doctorInput) doctorResult {
+	if _, err := splitCommandLine(editorSetting(in.Cfg)); err != nil {
+		return doctorResult{doctorFail, fmt.Sprintf("the editor '%s' can't be read: %v", editorSetting(in.Cfg), err), "quote the program's path if it has spaces"}
+	}
+	name := editorName(in.Cfg)
+	p, err := exec.LookPath(name)
+	if err != nil {
+		return doctorResult{doctorFail, fmt.Sprintf("%s isn't on PATH", name), "install it, or set editor, $VISUAL, or $EDITOR to an editor that is"}
+	}
+	return doctorOK("%s", p)
+}
+
+// checkDates checks that dates read, the configured keywords and locale
+// included.
+func checkDates(in doctorInput) doctorResult {
+	for _, sample := range []string{"today", "2024-03-07", "yesterday"} {
+		if _, err := parseDateString(in.Cfg, sample); err != nil {
+			return doctorResult{doctorFail, fmt.Sprintf("'%s' doesn't read as a date: %v", sample, err), "check date_locale, date_order, and [date_keywords]"}
+		}
+	}
+	pd, _ := parseDateString(in.Cfg, "today")
+	return doctorOK("today is %s", pd.Iso())
+}
+
+// checkEntries looks for entries under the root.
+func checkEntries(in doctorInput) doctorResult {
+	if !isDir(in.Cfg.Root) {
+		return doctorResult{doctorWarn, "none, as the root doesn't exist", ""}
+	}
+	res, err := walkRoot(in.Cfg, walkOptions{})
+	if err != nil {
+		return doctorResult{doctorFail, fmt.Sprintf("the root can't be listed: %v", err), "check its permissions"}
+	}
+	if len(res.Problems) > 0 {
+		return doctorResult{doctorWarn, fmt.Sprintf("%d entries, and %d files shaped like entries whose date can't be read, such as %v", len(res.Entries), len(res.Problems), res.Problems[0]), "rename or move those files"}
+	}
+	if len(res.Entries) == 0 {
+		return doctorResult{doctorWarn, "none yet", "check that root and path_layout match where your entries are"}
+	}
+	return doctorOK("%d, the latest %s", len(res.Entries), res.Entries[len(res.Entries)-1].Date.Iso())
+}
+
+// checkTemplates checks that every configured template can be read.
+func checkTemplates(in doctorInput) doctorResult {
+	cfg := in.Cfg
+	var problems []string
+	missing := func(setting, p string) {
+		_, err := os.Stat(configRelative(cfg, p))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			problems = append(problems, fmt.Sprintf("%s points at %s, which doesn't exist", setting, p))
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s points at %s, which can't be read: %v", setting, p, err))
+		}
+	}
+	if len(cfg.Template) > 0 {
+		missing("template", cfg.Template)
+	}
+	keys := make([]string, 0, len(cfg.Templates))
+	for k := range cfg.Templates {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		missing(fmt.Sprintf("templates.%q", k), cfg.Templates[k])
+	}
+	if len(problems) > 0 {
+		return doctorResult{doctorFail, strings.Join(problems, "; "), "fix the path, relative to the configuration file's directory"}
+	}
+	count := len(keys)
+	if len(cfg.Template) > 0 {
+		count++
+	}
+	return doctorOK("%d configured, all readable", count)
+}
+
+// runDoctor runs every check, printing a line for each and a hint under the
+// ones that didn't pass, and reports whether any failed.
+func runDoctor(in doctorInput) bool {
+	failed := false
+	for _, c := range doctorChecks {
+		r := c.Check(in)
+		fmt.Printf("%s  %s: %s\n", r.Status, c.Name, r.Message)
+		if r.Status != doctorPass && len(r.Hint) > 0 {
+			fmt.Printf("      %s\n", r.Hint)
+		}
+		if r.Status == doctorFail {
+			failed = true
+		}
+	}
+	return failed
+}
@@ -0,0 +1,125 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docopt/docopt-go"
+)
+
+// dryRun is set by --dry-run, which every command accepts.  Commands that
+// change entries in bulk take it as their own option and print the changes
+// they would make.  Opening a date resolves everything it would and prints
+// what it would create and run, see dryRunOpen, and search, which only
+// reads, runs as usual.  Any other command refuses it rather than run for
+// real.  Nothing that runs under it writes history, usage stats, or a
+// configuration file that doesn't exist yet.
+var dryRun = false
+
+// dryRunArgs takes --dry-run out of args unless the command given accepts it
+// as its own option, and reports whether it was there.
+func dryRunArgs(args []string) ([]string, bool) {
+	rest, found := takeFlag(args, "--dry-run")
+	if !found {
+		return args, false
+	}
+	p := &docopt.Parser{HelpHandler: docopt.NoHelpHandler}
+	if _, err := p.ParseArgs(usage, args, ""); err == nil {
+		return args, true
+	}
+	return rest, true
+}
+
+// checkDryRun fails for a command that --dry-run was given to but that can't
+// honor it.
+func checkDryRun(opts docopt.Opts, params Parameters) error {
+	if !dryRun || params.DryRun || params.Search {
+		return nil
+	}
+	if command := commandName(opts); command != "open" {
+		return fmt.Errorf("%s doesn't support --dry-run", command)
+	}
+	return nil
+}
+
+// dryRunOpen is the open flow with --dry-run: the date and the entry are
+// resolved, and the template rendered, as opening it would, and what would
+// be created and run is printed instead of done.
+func dryRunOpen(cfg Configuration, params Parameters) (openOutcome, error) {
+	resolve := resolveEntry
+	if params.ReadOnly {
+		resolve = existingEntry
+	}
+	pd, target, err := resolve(cfg, params)
+	if err != nil {
+		if params.ReadOnly {
+			err = withExitCode(exitNoEntry, err)
+		}
+		return openOutcome{}, err
+	}
+	if !params.ReadOnly {
+		pd, target, err = openNearest(cfg, params, pd, target)
+		if err != nil {
+			return openOutcome{}, err
+		}
+	}
+	out := openOutcome{Path: target}
+	data, err := readEntry(cfg, target)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		content, err := newEntryContent(cfg, params)(pd)
+		if err != nil {
+			return out, err
+		}
+		data, out.Created = []byte(content), true
+		if _, err := os.Stat(rootDir(cfg)); errors.Is(err, fs.ErrNotExist) {
+			fmt.Printf("would create the root %s and mark it with %s\n", displayPath(rootDir(cfg)), rootMarkerFile)
+		}
+		if dir := filepath.Dir(target); !isDir(dir) {
+			fmt.Printf("would create directory %s\n", displayPath(dir))
+		}
+		fmt.Printf("would create %s from its template, %d lines\n", displayPath(target), strings.Count(content, "\n"))
+	case err != nil:
+		return out, err
+	}
+	out.Empty = len(bytes.TrimSpace(stripHeader(data))) == 0
+
+	if params.PrintPath {
+		fmt.Println(displayPath(target))
+	}
+	if params.PrintPath || params.NoEdit {
+		return out, nil
+	}
+	args := []string{target}
+	readonlyArgs, _ := splitCommandLine(cfg.ReadonlyArgs)
+	switch {
+	case params.ReadOnly && (len(readonlyArgs) == 0 || cfg.Encrypt):
+		args = []string{"<read-only copy of " + filepath.Base(target) + ">"}
+	case params.ReadOnly:
+		args = append(readonlyArgs, target)
+	case cfg.Encrypt:
+		args = []string{"<decrypted copy of " + filepath.Base(target) + ">"}
+	}
+	cmd := editorCommand(cfg, editTarget{kindEntry, pd, out.Created}, target, args...)
+	fmt.Printf("would run %s\n", argvLine(cmd.Args))
+	return out, nil
+}
+
+// isDir reports whether path is an existing directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// noteDryRunSearch says that search, given --dry-run, runs as usual.
+func noteDryRunSearch() {
+	if dryRun {
+		log.Println(":::note::: --dry-run: search only reads entries, so it runs as usual, except that the search index isn't saved")
+	}
+}
@@ -0,0 +1,238 @@
+package wm
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DueConfig configures "wm due".  Pattern is a regular expression whose first
+// group is the due date, Lookback how far back entries are scanned, and
+// Within how far ahead upcoming items are shown.
+type DueConfig struct {
+	Pattern  string `toml:"pattern"`
+	Lookback string `toml:"lookback"`
+	Within   string `toml:"within"`
+}
+
+const (
+	defaultDuePattern  = `@due\(([^)]*)\)`
+	defaultDueLookback = "90d"
+	defaultDueWithin   = "14d"
+)
+
+var doneTodoRe = regexp.MustCompile(`^\s*[-*+]\s*\[[xX]\]`)
+
+// dueItem is an open item carrying a due date.
+type dueItem struct {
+	Due   DatePath
+	Text  string
+	Entry Entry
+	Line  int
+}
+
+// parseDueDate reads the date of an annotation written in the entry for
+// written.  Keywords are relative to that day rather than today, and dates
+// without a year, such as 3/15 or "mar 15", fall in the year that puts them
+// nearest to it, so that an item copied forward past its date keeps it.
+func parseDueDate(cfg Configuration, in string, written DatePath) (*DatePath, error) {
+	in = strings.ToLower(strings.TrimSpace(in))
+	if len(in) == 0 {
+		return nil, fmt.Errorf("empty due date")
+	}
+	t, ok, err := resolveKeyword(in, written.Time())
+	if ok {
+		if err != nil {
+			return nil, err
+		}
+		dp := datePathFromTime(t)
+		return &dp, nil
+	}
+	if dp, err := parseDateString(cfg, in); err == nil {
+		return dp, nil
+	}
+	sep := " "
+	if strings.Contains(in, "/") {
+		sep = "/"
+	} else if strings.Contains(in, "-") {
+		sep = "-"
+	}
+	dp, err := parseDateString(cfg, fmt.Sprintf("%s%s%d", in, sep, written.year))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse '%s'", in)
+	}
+	switch d := daysBetween(written, *dp); {
+	case d < -183:
+		dp.year++
+	case d > 183:
+		dp.year--
+	}
+	return dp, nil
+}
+
+// collectDue finds the open items with a due date in entries, oldest entry
+// first.  An item carried forward into later entries is reported once, from
+// the latest entry mentioning it, and not at all once that one ticks it off.
+// Malformed due dates are noted with their file and line.
+func collectDue(cfg Configuration, re *regexp.Regexp, entries []Entry) []dueItem {
+	var order []string
+	latest := map[string]*dueItem{}
+	for _, e := range entries {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			log.Println(":::note::: failed to read", e.Path)
+			continue
+		}
+		for i, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+			m := re.FindStringSubmatchIndex(line)
+			if m == nil || isSessionMarker(line) {
+				continue
+			}
+			due, err := parseDueDate(cfg, line[m[2]:m[3]], e.Date)
+			if err != nil {
+				log.Printf(":::note::: %s:%d: bad due date: %v", relEntryPath(cfg, e.Path), i+1, err)
+				continue
+			}
+			text := strings.TrimSpace(line[:m[0]] + line[m[1]:])
+			text = strings.Join(strings.Fields(text), " ")
+			key := due.Iso() + " " + strings.TrimSpace(todoLikeRe.ReplaceAllString(text, "$3"))
+			if _, ok := latest[key]; !ok {
+				order = append(order, key)
+			}
+			if doneTodoRe.MatchString(line) {
+				latest[key] = nil
+				continue
+			}
+			latest[key] = &dueItem{Due: *due, Text: text, Entry: e, Line: i + 1}
+		}
+	}
+	var items []dueItem
+	for _, k := range order {
+		if it := latest[k]; it != nil {
+			items = append(items, *it)
+		}
+	}
+	return items
+}
+
+// dueGroups is what is due, grouped as "due" and "notify" show it.
+type dueGroups struct {
+	Overdue, Today, Upcoming []dueItem
+}
+
+func (g dueGroups) empty() bool {
+	return len(g.Overdue)+len(g.Today)+len(g.Upcoming) == 0
+}
+
+// findDue collects the open items due up to within from now in the entries
+// the range options of params select, the [due] lookback by default.
+func findDue(cfg Configuration, params Parameters, within string) (dueGroups, error) {
+	pattern := cfg.Due.Pattern
+	if len(pattern) == 0 {
+		pattern = defaultDuePattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return dueGroups{}, fmt.Errorf("bad due.pattern '%s': %w", pattern, err)
+	}
+	if re.NumSubexp() < 1 {
+		return dueGroups{}, fmt.Errorf("due.pattern '%s' needs a group around the date", pattern)
+	}
+	ahead, err := parseAge(within)
+	if err != nil {
+		return dueGroups{}, err
+	}
+
+	q := queryFor(params)
+	if q.empty() {
+		q.Last = cfg.Due.Lookback
+		if len(q.Last) == 0 {
+			q.Last = defaultDueLookback
+		}
+	}
+	today := dayNow()
+	r, err := resolveQuery(cfg, q, today)
+	if err != nil {
+		return dueGroups{}, err
+	}
+	entries, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return dueGroups{}, err
+	}
+	items := collectDue(cfg, re, filterEntries(entries, r.From, r.To))
+
+	now := datePathFromTime(today)
+	horizon := datePathFromTime(addAge(today, ahead))
+	var g dueGroups
+	for _, it := range items {
+		switch {
+		case it.Due.Before(&now):
+			g.Overdue = append(g.Overdue, it)
+		case it.Due == now:
+			g.Today = append(g.Today, it)
+		case !horizon.Before(&it.Due):
+			g.Upcoming = append(g.Upcoming, it)
+		}
+	}
+	for _, list := range [][]dueItem{g.Overdue, g.Today, g.Upcoming} {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Due.Before(&list[j].Due) })
+	}
+	return g, nil
+}
+
+// print writes the groups under their headings.
+func (g dueGroups) print(w io.Writer, cfg Configuration) {
+	now := datePathFromTime(dayNow())
+	printDue(w, cfg, "overdue", g.Overdue, now)
+	printDue(w, cfg, "today", g.Today, now)
+	printDue(w, cfg, "upcoming", g.Upcoming, now)
+}
+
+// runDue lists the open items due up to --within from now, grouped into
+// overdue, today, and upcoming.  It reports whether any item is overdue.
+func runDue(cfg Configuration, params Parameters) (bool, error) {
+	within := params.Within
+	if len(within) == 0 {
+		within = cfg.Due.Within
+	}
+	if len(within) == 0 {
+		within = defaultDueWithin
+	}
+	g, err := findDue(cfg, params, within)
+	if err != nil {
+		return false, err
+	}
+	if g.empty() {
+		fmt.Printf("nothing due within %s\n", within)
+		return false, nil
+	}
+	g.print(os.Stdout, cfg)
+	return len(g.Overdue) > 0, nil
+}
+
+func printDue(w io.Writer, cfg Configuration, heading string, items []dueItem, today DatePath) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s:\n", heading)
+	for _, it := range items {
+		days := daysBetween(today, it.Due)
+		when := ""
+		switch {
+		case days < -1:
+			when = fmt.Sprintf(", %d days ago", -days)
+		case days == -1:
+			when = ", yesterday"
+		case days == 1:
+			when = ", tomorrow"
+		case days > 1:
+			when = fmt.Sprintf(", in %d days", days)
+		}
+		fmt.Fprintf(w, "  %s  %s\n", humanDate(it.Due), it.Text)
+		fmt.Fprintln(w, faint(fmt.Sprintf("      written %s in %s:%d%s", it.Entry.Date.Iso(), relEntryPath(cfg, it.Entry.Path), it.Line, when)))
+	}
+}
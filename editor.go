@@ -0,0 +1,469 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// What the editor is opened on, passed to it as WM_KIND.
+const (
+	kindEntry   = "entry"
+	kindConfig  = "config"
+	kindScratch = "scratch"
+	kindMonth   = "month"
+	// The notes of a month or a year; see periodnotes.go.
+	kindMonthNotes = "month-notes"
+	kindYearNotes  = "year-notes"
+	// A note of a kind from [notes]; see notes.go.
+	kindNote = "note"
+)
+
+// editTarget describes what the editor is opened on, for the environment
+// editor plugins read: WM_FILE, WM_DATE, WM_PROFILE, WM_ROOT, WM_KIND, and
+// WM_CREATED.  Date is nil for anything but entries.
+type editTarget struct {
+	Kind    string
+	Date    *DatePath
+	Created bool
+}
+
+// activeProfile returns the name cfg is listed under in its own [profiles],
+// or "" when it isn't.
+func activeProfile(cfg Configuration) string {
+	self, err := filepath.Abs(cfg.file)
+	if err != nil || len(cfg.file) == 0 {
+		return ""
+	}
+	for name, file := range cfg.Profiles {
+		if p, err := filepath.Abs(configRelative(cfg, file)); err == nil && p == self {
+			return name
+		}
+	}
+	return ""
+}
+
+// editorEnv is the environment of an editor opened on file.  The variables
+// are always set, to "" when they don't apply, so a plugin can tell an
+// unset value from a stale one inherited from an outer wm.
+func editorEnv(cfg Configuration, t editTarget, file string) []string {
+	date := ""
+	if t.Date != nil {
+		date = t.Date.Iso()
+	}
+	created := "0"
+	if t.Created {
+		created = "1"
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	return append(os.Environ(),
+		"WM_FILE="+file,
+		"WM_DATE="+date,
+		"WM_PROFILE="+activeProfile(cfg),
+		"WM_ROOT="+cfg.Root,
+		"WM_KIND="+t.Kind,
+		"WM_CREATED="+created,
+	)
+}
+
+// splitCommandLine splits an editor setting such as `code --wait` or
+// `"C:\Program Files\Vim\gvim.exe" -p` into the program and its
+// arguments.  Single and double quotes group words; backslashes are kept as
+// they are, so Windows paths need no escaping.
+func splitCommandLine(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	quote := rune(0)
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed %c in '%s'", quote, s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// editorSetting is the editor command line in effect: the editor key, or
+// else $VISUAL, $EDITOR, and notepad on Windows or vi elsewhere, in that
+// order.
+func editorSetting(cfg Configuration) string {
+	for _, e := range []string{cfg.Editor, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if len(strings.TrimSpace(e)) > 0 {
+			return e
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// editorArgs is editorSetting split into the program and its arguments.
+// A setting that can't be split, which validateConfig reports, is taken as
+// the program's name.
+func editorArgs(cfg Configuration) []string {
+	setting := editorSetting(cfg)
+	words, err := splitCommandLine(setting)
+	if err != nil || len(words) == 0 {
+		return []string{setting}
+	}
+	return words
+}
+
+// editorName is the editor's program, for messages.
+func editorName(cfg Configuration) string {
+	return editorArgs(cfg)[0]
+}
+
+// editorWait reports whether wm runs the editor in the terminal and waits
+// for it to exit, which it does unless detach = true or editor_wait = false
+// says to start it and leave it, for GUI editors that return at once.
+// Encrypted entries are always waited for, as they are encrypted again once
+// the editor is done, and so are entries committed or normalized after
+// editing or locked while they are edited, and when post_save hooks run.
+func editorWait(cfg Configuration) bool {
+	if cfg.Encrypt || cfg.GitAutocommit || cfg.LockEntries || cfg.Normalize || len(cfg.Hooks.PostSave) > 0 {
+		return true
+	}
+	if cfg.Detach {
+		return false
+	}
+	if cfg.EditorWait != nil {
+		return *cfg.EditorWait
+	}
+	return true
+}
+
+// editorCommand returns the command running the editor with args on file,
+// with its environment set for t.  Every launch of the editor goes through
+// it, and through startEditor.
+func editorCommand(cfg Configuration, t editTarget, file string, args ...string) *exec.Cmd {
+	argv := editorArgs(cfg)
+	cmd := exec.Command(argv[0], append(argv[1:], args...)...)
+	cmd.Env = editorEnv(cfg, t, file)
+	return cmd
+}
+
+// startEditor starts cmd, and when wait is set runs it on the terminal until
+// it exits.  The editor's program must resolve through PATH, or be a path to
+// an executable, before anything is run; one found only relative to the
+// current directory is refused, and programs on PATH with a similar name are
+// suggested.  An editor that exits with a failure fails with the command
+// line run, its exit status, and the end of what it wrote to standard error.
+// Its status is only reported, never passed on: wm's own statuses 3, 4, and
+// 5 mean something else, so editorError ends wm with exitEditorErr instead.
+func startEditor(cmd *exec.Cmd, wait bool) error {
+	if _, err := exec.LookPath(cmd.Args[0]); err != nil {
+		if similar := similarCommands(cmd.Args[0]); len(similar) > 0 {
+			return fmt.Errorf("the editor '%s' can't be run, so nothing was started (did you mean %s?): %w",
+				cmd.Args[0], strings.Join(similar, ", "), err)
+		}
+		return fmt.Errorf("the editor '%s' can't be run, so nothing was started: %w", cmd.Args[0], err)
+	}
+	tracef("editor: %s, waiting for it: %t", argvLine(cmd.Args), wait)
+	if !wait {
+		return cmd.Start()
+	}
+	stderr := &tailBuffer{max: editorStderrTail}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, io.MultiWriter(os.Stderr, stderr)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	msg := fmt.Sprintf("%s exited with %v", argvLine(cmd.Args), exitErr)
+	if text := strings.TrimSpace(stderr.String()); len(text) > 0 {
+		msg += "; it wrote:\n" + text
+	}
+	return errors.New(msg)
+}
+
+// editorError marks err, from starting or running the editor, to end wm
+// with exitEditorErr, whatever status the editor itself exited with.
+func editorError(err error) error {
+	return withExitCode(exitEditorErr, err)
+}
+
+// editorStderrTail is how much of what the editor writes to standard error
+// a failure shows, from the end.
+const editorStderrTail = 4 << 10
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max  int
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > b.max {
+		b.data = append([]byte(nil), b.data[len(b.data)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string { return string(b.data) }
+
+// similarCommands returns up to three programs on PATH whose names are
+// within two edits of the name of program, closest first, for suggesting
+// when it isn't found.
+func similarCommands(program string) []string {
+	name := strings.ToLower(filepath.Base(program))
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var found []candidate
+	seen := map[string]bool{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			c := e.Name()
+			if runtime.GOOS == "windows" {
+				ext := strings.ToLower(filepath.Ext(c))
+				if ext != ".exe" && ext != ".cmd" && ext != ".bat" {
+					continue
+				}
+				c = strings.TrimSuffix(c, filepath.Ext(c))
+			} else if info, err := e.Info(); err != nil || info.IsDir() || info.Mode()&0o111 == 0 {
+				continue
+			}
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			if d := editDistance(name, strings.ToLower(c)); d > 0 && d <= 2 {
+				found = append(found, candidate{c, d})
+			}
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].distance != found[j].distance {
+			return found[i].distance < found[j].distance
+		}
+		return found[i].name < found[j].name
+	})
+	var names []string
+	for i := 0; i < len(found) && i < 3; i++ {
+		names = append(names, found[i].name)
+	}
+	return names
+}
+
+// editDistance is the Levenshtein distance between a and b, in bytes.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// fileEdit is a run of the editor on files, for editFiles.  Paths are the
+// files opened, the first of them the one Target describes.  Dates holds
+// the date of each that is an entry, by index, which its commit names, and
+// Topic is the topic they are committed under.  Line, when it isn't 0, is
+// where the editor is put in a lone file.  Wait runs the editor in the
+// terminal whatever editor_wait says, for a command with more to do once the
+// edit is over.  Prepare, when set, runs once the files are locked and backed
+// up, to change them before the editor opens them.
+type fileEdit struct {
+	Target  editTarget
+	Paths   []string
+	Dates   []*DatePath
+	Topic   string
+	Line    int
+	Wait    bool
+	Prepare func() error
+}
+
+// editFiles is how every command edits what is kept under the root.  The
+// files are locked as lock_entries says and backed up as backups says, but
+// for a lone one Target says was just created, and entries go through the
+// pre_open hooks.  Entries and month and year notes are opened decrypted
+// while encrypt is on.  Once the editor exits, entries are normalized,
+// committed as git_autocommit says, and passed to the post_save hooks, and
+// a lone entry's progress against daily_word_goal is shown.  The month view is a read-only copy outside the
+// root, so only the editor is run on it.
+func editFiles(cfg Configuration, e fileEdit) error {
+	t := e.Target
+	entries := t.Kind == kindEntry
+	if t.Kind != kindMonth {
+		if cfg.LockEntries {
+			timeout, err := lockTimeout(cfg)
+			if err != nil {
+				return err
+			}
+			for _, p := range e.Paths {
+				unlock, err := lockEntry(p, timeout)
+				if err != nil {
+					return err
+				}
+				defer unlock()
+			}
+		}
+		for _, p := range e.Paths {
+			if len(e.Paths) == 1 && t.Created {
+				continue
+			}
+			if err := backupBeforeEdit(cfg, p); err != nil {
+				return err
+			}
+		}
+	}
+	if e.Prepare != nil {
+		if err := e.Prepare(); err != nil {
+			return err
+		}
+	}
+	if entries {
+		if err := preOpenHooks(cfg, t, e.Paths...); err != nil {
+			return err
+		}
+	}
+	wait := e.Wait || editorWait(cfg)
+	edit := func(files []string) error {
+		return runEditorOn(cfg, t, files, e.Line, wait)
+	}
+	var err error
+	if cfg.Encrypt && (entries || t.Kind == kindMonthNotes) {
+		err = editDecrypted(cfg, e.Paths, edit)
+	} else {
+		err = edit(e.Paths)
+	}
+	if err != nil {
+		return editorError(err)
+	}
+	if !entries {
+		return nil
+	}
+	normalizeEdited(cfg, e.Paths...)
+	for i, p := range e.Paths {
+		if i < len(e.Dates) && e.Dates[i] != nil {
+			autocommit(cfg, p, e.Dates[i], e.Topic)
+		}
+	}
+	postSaveHooks(cfg, t, e.Paths...)
+	if cfg.DailyWordGoal > 0 && wait && len(e.Paths) == 1 && t.Date != nil {
+		if data, err := readEntry(cfg, e.Paths[0]); err == nil {
+			printGoalProgress(os.Stderr, cfg, t.Date, data)
+		}
+	}
+	return nil
+}
+
+// runEditorOn starts the editor on the files as they are, at line in a lone
+// file unless line is 0 or the editor can't be put on a line.
+func runEditorOn(cfg Configuration, t editTarget, paths []string, line int, wait bool) error {
+	if line > 0 && len(paths) == 1 {
+		err := launchEditorAt(cfg, t, paths[0], line, wait)
+		var ran *exitError
+		if err == nil || errors.As(err, &ran) {
+			return err
+		}
+		log.Println(":::note:::", err)
+	}
+	first := ""
+	if len(paths) > 0 {
+		first = paths[0]
+	}
+	cmd := editorCommand(cfg, t, first, paths...)
+	err := startEditor(cmd, wait)
+	if err != nil {
+		return fmt.Errorf("failed to open %s using %s: %w", strings.Join(paths, ", "), editorName(cfg), err)
+	}
+	return nil
+}
+
+// errNoLineArg is returned by launchEditorAt when editor_line_arg is unset.
+var errNoLineArg = errors.New("editor_line_arg is not configured")
+
+// lineArgSetting returns the configured editor_line_arg, given as
+// editor_line_arg or editor_goto_format.
+func lineArgSetting(cfg Configuration) string {
+	if len(strings.TrimSpace(cfg.EditorLineArg)) > 0 {
+		return cfg.EditorLineArg
+	}
+	return cfg.EditorGotoFormat
+}
+
+// launchEditorAt opens path in the editor positioned at line using the
+// editor_line_arg template, such as "+{line}" for vim or
+// "--goto {file}:{line}" for VS Code.  When the template doesn't mention
+// {file} the path is passed after it.
+func launchEditorAt(cfg Configuration, t editTarget, path string, line int, wait bool) error {
+	lineArg := lineArgSetting(cfg)
+	if len(strings.TrimSpace(lineArg)) == 0 {
+		return errNoLineArg
+	}
+	var args []string
+	hasFile := false
+	for _, field := range strings.Fields(lineArg) {
+		if strings.Contains(field, "{file}") {
+			hasFile = true
+		}
+		field = strings.ReplaceAll(field, "{file}", path)
+		args = append(args, strings.ReplaceAll(field, "{line}", strconv.Itoa(line)))
+	}
+	if !hasFile {
+		args = append(args, path)
+	}
+	cmd := editorCommand(cfg, t, path, args...)
+	err := startEditor(cmd, wait)
+	if err != nil {
+		return fmt.Errorf("failed to open %s using %s: %w", path, editorName(cfg), err)
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// heldEditor writes an editor script that appends a line to each file it is
+// given once the file at gate exists, having created started to say it is
+// running.
+func heldEditor(t *testing.T, started, gate string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the test editor is a shell script")
+	}
+	script := filepath.Join(t.TempDir(), "editor")
+	body := "#!/bin/sh\n" +
+		": > '" + started + "'\n" +
+		"while [ ! -e '" + gate + "' ]; do sleep 0.01; done\n" +
+		"for f; do echo edited >> \"$f\"; done\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func waitForFile(t *testing.T, path string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s never appeared", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEditFilesLocksEveryFile(t *testing.T) {
+	dir := t.TempDir()
+	started := filepath.Join(dir, "started")
+	gate := filepath.Join(dir, "gate")
+	cfg := Configuration{Root: dir, LockEntries: true}
+	cfg.Editor = heldEditor(t, started, gate)
+
+	var paths []string
+	for _, name := range []string{"1.txt", "2.txt"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("entry\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	first := DatePath{2024, 3, 1}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- editFiles(cfg, fileEdit{Target: editTarget{Kind: kindEntry, Date: &first}, Paths: paths})
+	}()
+	waitForFile(t, started)
+
+	// Both files are held while the editor runs, as the week and pick
+	// commands open several at once.
+	for _, p := range paths {
+		err := editFiles(cfg, fileEdit{Target: editTarget{Kind: kindEntry, Date: &first}, Paths: []string{p}})
+		if !errors.Is(err, errEntryLocked) {
+			t.Errorf("editing %s while it is open: got %v, want errEntryLocked", p, err)
+		}
+	}
+
+	if err := os.WriteFile(gate, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("editFiles: %v", err)
+	}
+	for _, p := range paths {
+		if _, err := os.Stat(entryLockPath(p)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s is still locked after the edit: %v", p, err)
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "entry\nedited\n" {
+			t.Errorf("%s = %q, want the edit appended once", p, data)
+		}
+	}
+}
+
+func TestEditFilesLockContention(t *testing.T) {
+	dir := t.TempDir()
+	started := filepath.Join(dir, "started")
+	gate := filepath.Join(dir, "gate")
+	if err := os.WriteFile(gate, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Configuration{Root: dir, LockEntries: true}
+	cfg.Editor = heldEditor(t, started, gate)
+	path := filepath.Join(dir, "entry.txt")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pd := DatePath{2024, 3, 1}
+
+	// However the edits race, each one either gets the entry to itself or
+	// is refused; none is lost to another's.
+	const editors = 8
+	errs := make(chan error, editors)
+	for i := 0; i < editors; i++ {
+		go func() {
+			errs <- editFiles(cfg, fileEdit{Target: editTarget{Kind: kindEntry, Date: &pd}, Paths: []string{path}})
+		}()
+	}
+	edited := 0
+	for i := 0; i < editors; i++ {
+		switch err := <-errs; {
+		case err == nil:
+			edited++
+		case !errors.Is(err, errEntryLocked):
+			t.Errorf("editFiles: %v", err)
+		}
+	}
+	if edited == 0 {
+		t.Fatal("no edit got the lock")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := len("edited\n") * edited; len(data) != want {
+		t.Errorf("entry has %d bytes after %d edits, want %d", len(data), edited, want)
+	}
+}
+
+func TestEditFilesAutocommitsEachEntry(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	// the gate is open from the start, so the editor doesn't wait
+	gate := filepath.Join(t.TempDir(), "gate")
+	if err := os.WriteFile(gate, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Configuration{Root: dir, GitAutocommit: true}
+	cfg.Editor = heldEditor(t, filepath.Join(t.TempDir(), "started"), gate)
+	git := func(args ...string) string {
+		t.Helper()
+		out, err := runGit(dir, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	git("init", "--quiet")
+	git("config", "user.name", "wm")
+	git("config", "user.email", "wm@example.com")
+
+	dates := []*DatePath{{2024, 3, 4}, {2024, 3, 5}}
+	var paths []string
+	for _, pd := range dates {
+		p := filepath.Join(dir, pd.Iso()+".txt")
+		if err := os.WriteFile(p, []byte(pd.Iso()+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	// the way week opens the entries of a week
+	err := editFiles(cfg, fileEdit{Target: editTarget{Kind: kindEntry, Date: dates[0]}, Paths: paths, Dates: dates})
+	if err != nil {
+		t.Fatalf("editFiles: %v", err)
+	}
+	if log, want := git("log", "--format=%s"), "wm: 2024-03-05\nwm: 2024-03-04\n"; log != want {
+		t.Errorf("commits = %q, want one per entry: %q", log, want)
+	}
+	if status := git("status", "--porcelain"); len(status) > 0 {
+		t.Errorf("uncommitted after the edit:\n%s", status)
+	}
+}
+
+func TestEditorFailureExitStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test editor is a shell script")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "entry.txt")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pd := DatePath{2024, 3, 1}
+	for _, status := range []int{1, exitCreated, exitEmpty, exitNoEntry, 42} {
+		script := filepath.Join(t.TempDir(), "editor")
+		body := fmt.Sprintf("#!/bin/sh\necho 'no swap file' >&2\nexit %d\n", status)
+		if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		cfg := Configuration{Root: dir, Editor: script}
+		err := editFiles(cfg, fileEdit{Target: editTarget{Kind: kindEntry, Date: &pd}, Paths: []string{path}, Wait: true})
+		if code := exitCode(err); code != exitEditorErr {
+			t.Errorf("an editor exiting %d ends wm with %d, want %d", status, code, exitEditorErr)
+		}
+		if want := fmt.Sprintf("exit status %d", status); err == nil || !strings.Contains(err.Error(), want) || !strings.Contains(err.Error(), "no swap file") {
+			t.Errorf("an editor exiting %d fails with %v, want its status and what it wrote", status, err)
+		}
+	}
+}
+
+// recordingEditor writes an editor script that records the arguments it is
+// run with, one per line, in dir/args and its WM_* variables in dir/env.
+func recordingEditor(t *testing.T, dir string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the test editor is a shell script")
+	}
+	script := filepath.Join(t.TempDir(), "editor")
+	body := "#!/bin/sh\n" +
+		"printf '%s\\n' \"$@\" > '" + filepath.Join(dir, "args") + "'\n" +
+		"env | grep '^WM_' | sort > '" + filepath.Join(dir, "env") + "'\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+// recorded returns the lines recordingEditor wrote to dir/name.
+func recorded(t *testing.T, dir, name string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestEditorEnvironment(t *testing.T) {
+	root := t.TempDir()
+	cfgFile, env := testHome(t, root)
+	out := t.TempDir()
+	editor := recordingEditor(t, out)
+	content := "root = '" + filepath.ToSlash(root) + "'\neditor = '" + editor + "'\n"
+	if err := os.WriteFile(cfgFile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env = append(env, "WMCFG="+cfgFile, "WM_NOW=2024-03-07T09:00", "WM_DATE=stale from an outer wm")
+	entry := filepath.Join(root, "2024", "3", "7.txt")
+
+	tests := []struct {
+		args []string
+		want map[string]string
+	}{
+		{[]string{"today"}, map[string]string{"WM_KIND": "entry", "WM_DATE": "2024-03-07", "WM_CREATED": "1", "WM_FILE": entry}},
+		{[]string{"today"}, map[string]string{"WM_KIND": "entry", "WM_DATE": "2024-03-07", "WM_CREATED": "0", "WM_FILE": entry}},
+		{[]string{"config"}, map[string]string{"WM_KIND": "config", "WM_DATE": "", "WM_CREATED": "0", "WM_FILE": cfgFile}},
+		{[]string{"scratch", "ideas"}, map[string]string{"WM_KIND": "scratch", "WM_DATE": "", "WM_CREATED": "1"}},
+	}
+	for _, tt := range tests {
+		if _, stderr, code := runWM(t, root, env, tt.args...); code != 0 {
+			t.Fatalf("wm %q exited %d: %s", tt.args, code, stderr)
+		}
+		got := map[string]string{}
+		for _, line := range recorded(t, out, "env") {
+			k, v, _ := strings.Cut(line, "=")
+			got[k] = v
+		}
+		for _, k := range []string{"WM_FILE", "WM_DATE", "WM_PROFILE", "WM_ROOT", "WM_KIND", "WM_CREATED"} {
+			if _, ok := got[k]; !ok {
+				t.Errorf("wm %q: the editor had no %s", tt.args, k)
+			}
+		}
+		if got["WM_ROOT"] != root || got["WM_PROFILE"] != "" {
+			t.Errorf("wm %q: WM_ROOT = %q, WM_PROFILE = %q, want %s and none", tt.args, got["WM_ROOT"], got["WM_PROFILE"], root)
+		}
+		for k, want := range tt.want {
+			if got[k] != want {
+				t.Errorf("wm %q: %s = %q, want %q", tt.args, k, got[k], want)
+			}
+		}
+		if args := recorded(t, out, "args"); args[len(args)-1] != got["WM_FILE"] {
+			t.Errorf("wm %q: the editor was given %q, but WM_FILE is %s", tt.args, args, got["WM_FILE"])
+		}
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"vim", []string{"vim"}},
+		{"code --wait", []string{"code", "--wait"}},
+		{"  emacsclient\t-c  -a ''  ", []string{"emacsclient", "-c", "-a", ""}},
+		{`"C:\Program Files\Vim\gvim.exe" -p`, []string{`C:\Program Files\Vim\gvim.exe`, "-p"}},
+		{`subl -n --command 'goto "line"'`, []string{"subl", "-n", "--command", `goto "line"`}},
+		{`a"b c"d`, []string{"ab cd"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, err := splitCommandLine(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommandLine(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{`vim "unclosed`, "code 'x"} {
+		if got, err := splitCommandLine(in); err == nil {
+			t.Errorf("splitCommandLine(%q) = %q, want an error", in, got)
+		}
+	}
+}
+
+func TestEditorArguments(t *testing.T) {
+	out := t.TempDir()
+	script := recordingEditor(t, out)
+	path := filepath.Join(t.TempDir(), "7.txt")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pd := DatePath{2024, 3, 7}
+	edit := func(cfg Configuration) []string {
+		t.Helper()
+		err := editFiles(cfg, fileEdit{Target: editTarget{Kind: kindEntry, Date: &pd}, Paths: []string{path}, Wait: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return recorded(t, out, "args")
+	}
+
+	got := edit(Configuration{Root: filepath.Dir(path), Editor: "'" + script + "' --wait \"two words\" ''"})
+	if want := []string{"--wait", "two words", "", path}; !reflect.DeepEqual(got, want) {
+		t.Errorf("editor with arguments was run with %q, want %q", got, want)
+	}
+
+	// unset, $VISUAL is used before $EDITOR
+	t.Setenv("VISUAL", script+" --from-visual")
+	t.Setenv("EDITOR", script+" --from-editor")
+	if got := edit(Configuration{Root: filepath.Dir(path)}); !reflect.DeepEqual(got, []string{"--from-visual", path}) {
+		t.Errorf("with $VISUAL set the editor was run with %q", got)
+	}
+	t.Setenv("VISUAL", "")
+	if got := edit(Configuration{Root: filepath.Dir(path)}); !reflect.DeepEqual(got, []string{"--from-editor", path}) {
+		t.Errorf("with only $EDITOR set the editor was run with %q", got)
+	}
+	t.Setenv("EDITOR", "")
+	want := "vi"
+	if runtime.GOOS == "windows" {
+		want = "notepad"
+	}
+	if got := editorSetting(Configuration{}); got != want {
+		t.Errorf("with neither set the editor is %s, want %s", got, want)
+	}
+}
+
+func TestEditorWait(t *testing.T) {
+	no, yes := false, true
+	tests := []struct {
+		cfg  Configuration
+		want bool
+	}{
+		{Configuration{}, true},
+		{Configuration{EditorWait: &no}, false},
+		{Configuration{EditorWait: &yes, Detach: true}, false},
+		{Configuration{Detach: true}, false},
+		{Configuration{EditorWait: &no, Encrypt: true}, true},
+		{Configuration{EditorWait: &no, LockEntries: true}, true},
+		{Configuration{Detach: true, GitAutocommit: true}, true},
+	}
+	for _, tt := range tests {
+		if got := editorWait(tt.cfg); got != tt.want {
+			t.Errorf("editorWait(%+v) = %v, want %v", tt.cfg, got, tt.want)
+		}
+	}
+}
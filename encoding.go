@@ -0,0 +1,163 @@
+package wm
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// cp1252High maps the bytes 0x80-0x9F of Windows-1252 to their characters;
+// zero marks the five bytes it leaves undefined.  Every other byte means the
+// same as in ISO-8859-1.
+var cp1252High = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+// latin9Diff holds the characters where ISO-8859-15 differs from ISO-8859-1.
+var latin9Diff = map[byte]rune{
+	0xA4: '€', 0xA6: 'Š', 0xA8: 'š', 0xB4: 'Ž', 0xB8: 'ž', 0xBC: 'Œ', 0xBD: 'œ', 0xBE: 'Ÿ',
+}
+
+// singleByteDecoders are the legacy encodings check --encoding can convert
+// from, by every name they are commonly given.
+var singleByteDecoders = map[string]func(b byte) (rune, bool){
+	"windows-1252": func(b byte) (rune, bool) {
+		if b >= 0x80 && b <= 0x9F {
+			r := cp1252High[b-0x80]
+			return r, r != 0
+		}
+		return rune(b), true
+	},
+	"iso-8859-1": func(b byte) (rune, bool) {
+		return rune(b), true
+	},
+	"iso-8859-15": func(b byte) (rune, bool) {
+		if r, ok := latin9Diff[b]; ok {
+			return r, true
+		}
+		return rune(b), true
+	},
+}
+
+var encodingAliases = map[string]string{
+	"cp1252":  "windows-1252",
+	"latin1":  "iso-8859-1",
+	"latin-1": "iso-8859-1",
+	"latin9":  "iso-8859-15",
+	"latin-9": "iso-8859-15",
+}
+
+// decodeSingleByte converts data from a legacy single-byte encoding to UTF-8.
+func decodeSingleByte(data []byte, encoding string) ([]byte, error) {
+	name := strings.ToLower(strings.TrimSpace(encoding))
+	if alias, ok := encodingAliases[name]; ok {
+		name = alias
+	}
+	decode, ok := singleByteDecoders[name]
+	if !ok {
+		var known []string
+		for n := range singleByteDecoders {
+			known = append(known, n)
+		}
+		sort.Strings(known)
+		return nil, fmt.Errorf("unsupported encoding '%s', expected one of %s", encoding, strings.Join(known, ", "))
+	}
+	var b bytes.Buffer
+	for i, c := range data {
+		if c < 0x80 {
+			b.WriteByte(c)
+			continue
+		}
+		r, ok := decode(c)
+		if !ok {
+			return nil, fmt.Errorf("byte 0x%02X at offset %d is not defined in %s", c, i, name)
+		}
+		b.WriteRune(r)
+	}
+	return b.Bytes(), nil
+}
+
+// guessEncoding names the likely encoding of text that isn't valid UTF-8.
+// Bytes in 0x80-0x9F are control characters in ISO-8859-1 that real text
+// doesn't use, but smart quotes and dashes in Windows-1252, so their presence
+// points to the latter.
+func guessEncoding(data []byte) string {
+	for _, c := range data {
+		if c >= 0x80 && c <= 0x9F && cp1252High[c-0x80] != 0 {
+			return "windows-1252"
+		}
+	}
+	return "iso-8859-1"
+}
+
+// validText replaces invalid UTF-8 in s so it is never written to the
+// terminal as is.
+func validText(s string) string {
+	return strings.ToValidUTF8(s, "�")
+}
+
+// firstInvalidLine returns the 1-based number and content of the first line
+// of data that isn't valid UTF-8.
+func firstInvalidLine(data []byte) (int, []byte) {
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if !utf8.Valid(line) {
+			return i + 1, line
+		}
+	}
+	return 0, nil
+}
+
+// checkEncoding lists the entries that aren't valid UTF-8 with a sample
+// line decoded as their likely encoding.  With --fix they are converted from
+// --from-encoding to UTF-8 in place, each backed up to the versions store
+// first so the conversion can be undone.  It reports whether any entry was
+// left unconverted.
+func checkEncoding(cfg Configuration, params Parameters) (bool, error) {
+	if params.Fix && len(params.FromEncoding) == 0 {
+		return false, fmt.Errorf("--fix needs --from-encoding, such as --from-encoding=windows-1252")
+	}
+	entries, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return false, err
+	}
+	unresolved := false
+	for _, e := range entries {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", e.Path, err)
+		}
+		if utf8.Valid(data) {
+			continue
+		}
+		guess := guessEncoding(data)
+		line, sample := firstInvalidLine(data)
+		decoded, err := decodeSingleByte(sample, guess)
+		if err != nil {
+			decoded = []byte(validText(string(sample)))
+		}
+		fmt.Printf("%s:%d: not UTF-8, likely %s: %s\n", e.Path, line, guess, strings.TrimSpace(string(decoded)))
+		if !params.Fix {
+			unresolved = true
+			continue
+		}
+		converted, err := decodeSingleByte(data, params.FromEncoding)
+		if err != nil {
+			fmt.Printf("  not converted: %v\n", err)
+			unresolved = true
+			continue
+		}
+		if params.DryRun {
+			fmt.Printf("  would convert from %s\n", params.FromEncoding)
+			continue
+		}
+		backup, err := rewriteEntry(cfg, e.Path, converted, rewriteOptions{Backup: true, KeepModTime: true})
+		if err != nil {
+			return false, fmt.Errorf("failed to convert %s: %w", e.Path, err)
+		}
+		fmt.Printf("  converted from %s; the original is %s\n", params.FromEncoding, backup)
+	}
+	return unresolved, nil
+}
@@ -0,0 +1,173 @@
+package wm
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is a working memory file found under the root along with the date
+// encoded in its path.  Topic is set for the entry of a topic within the
+// day.  Attachment is set instead for an attachment of the entry, to its
+// name, Scratch for a scratch note, which has no date, and Note for a note
+// of a kind from [notes], to the kind and the note's path.  Profile and
+// Editor are set for entries read from another profile by search
+// --all-profiles, to its name and the editor it is opened with.  Root is
+// set by rootEntries when extra_roots are configured, to the root the entry
+// was found under.
+type Entry struct {
+	Date       DatePath
+	Path       string
+	ModTime    time.Time
+	Topic      string
+	Attachment string
+	Scratch    string
+	Note       string
+	Profile    string
+	Editor     string
+	Root       string
+	Archive    string
+}
+
+// NewDatePath returns the date of year, month, and day, for programs using
+// wm as a library.  A day the month doesn't have, such as February 30, is
+// normalized as time.Date does.
+func NewDatePath(year int, month time.Month, day int) DatePath {
+	return datePathFromTime(time.Date(year, month, day, 12, 0, 0, 0, time.Local))
+}
+
+// Year, Month, and Day are the parts of the date.
+func (ds *DatePath) Year() int         { return ds.year }
+func (ds *DatePath) Month() time.Month { return time.Month(ds.month) }
+func (ds *DatePath) Day() int          { return ds.day }
+
+// Time returns the date as a time.Time at midnight local time.
+func (ds *DatePath) Time() time.Time {
+	return time.Date(ds.year, time.Month(ds.month), ds.day, 0, 0, 0, 0, time.Local)
+}
+
+// Before reports whether ds is strictly earlier than other.
+func (ds *DatePath) Before(other *DatePath) bool {
+	return ds.Time().Before(other.Time())
+}
+
+// Iso returns the date formatted as YYYY-MM-DD.
+func (ds *DatePath) Iso() string {
+	return fmt.Sprintf("%04d-%02d-%02d", ds.year, ds.month, ds.day)
+}
+
+// addDays returns the date n days after ds, n < 0 for before, across month
+// and year ends.
+func (ds *DatePath) addDays(n int) DatePath {
+	return datePathFromTime(ds.Time().AddDate(0, 0, n))
+}
+
+// datePathFromTime truncates t to its calendar day.
+func datePathFromTime(t time.Time) DatePath {
+	return DatePath{year: t.Year(), month: int(t.Month()), day: t.Day()}
+}
+
+// parseEntryPath recovers the date from a path in the nested layout,
+// root/YYYY/M/D.txt.
+// The month directory may also be named, as in root/2024/03-März/07.txt.
+func parseEntryPath(path string) (*DatePath, error) {
+	dayPart := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	monthPart := filepath.Base(filepath.Dir(path))
+	yearPart := filepath.Base(filepath.Dir(filepath.Dir(path)))
+	year, err := strconv.Atoi(yearPart)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not an entry path: bad year", path)
+	}
+	month, err := parseMonthSegment(monthPart)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not an entry path: %w", path, err)
+	}
+	day, err := strconv.Atoi(dayPart)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not an entry path: bad day", path)
+	}
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day {
+		return nil, fmt.Errorf("'%s' is not an entry path: no such date", path)
+	}
+	return &DatePath{year: year, month: month, day: day}, nil
+}
+
+// parseDateRange parses either a single date or a "<from>..<to>" pair.  An
+// empty string returns nil bounds, meaning the whole archive.
+func parseDateRange(cfg Configuration, in string) (from *DatePath, to *DatePath, err error) {
+	in = strings.TrimSpace(in)
+	if len(in) == 0 {
+		return nil, nil, nil
+	}
+	parts := strings.SplitN(in, "..", 2)
+	from, err = parseDateString(cfg, parts[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(parts) == 1 {
+		return from, from, nil
+	}
+	to, err = parseDateString(cfg, parts[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	if to.Before(from) {
+		return nil, nil, fmt.Errorf("range end %s is before its start %s", to.Iso(), from.Iso())
+	}
+	return from, to, nil
+}
+
+// filterEntries keeps the entries that fall within the inclusive range.  Nil
+// bounds are open.
+func filterEntries(entries []Entry, from *DatePath, to *DatePath) []Entry {
+	var kept []Entry
+	for _, e := range entries {
+		if from != nil && e.Date.Before(from) {
+			continue
+		}
+		if to != nil && to.Before(&e.Date) {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// entryPreview returns the first non-empty line of an entry after the
+// generated header, skipping session markers.
+func entryPreview(data []byte) string {
+	lines := strings.Split(string(stripHeader(data)), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if len(line) > 0 && !isSessionMarker(line) {
+			return validText(line)
+		}
+	}
+	return ""
+}
+
+var tagRe = regexp.MustCompile(`(?:^|\s)(#[A-Za-z][A-Za-z0-9_-]*)`)
+
+// entryTags returns the #tags in data in the order they appear.
+func entryTags(data []byte) []string {
+	var tags []string
+	for _, m := range tagRe.FindAllStringSubmatch(string(data), -1) {
+		tags = append(tags, m[1])
+	}
+	return tags
+}
+
+// hasTag reports whether data carries tag, given with or without its '#'.
+func hasTag(data []byte, tag string) bool {
+	tag = "#" + strings.TrimPrefix(tag, "#")
+	for _, t := range entryTags(data) {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// entryPath returns the path of the working memory file for pd.  The root
+// has been expanded by expandPath when the configuration was loaded.  An
+// existing entry with a legacy extension is used rather than a new one
+// created with the configured extension, and when the day has entries with
+// more than one, the one with the configured extension is used and the
+// others noted.
+func entryPath(cfg Configuration, pd *DatePath) (string, error) {
+	layout := entryLayout(cfg)
+	p := filepath.Join(cfg.Root, filepath.FromSlash(layout.render(pd)))
+	layouts := []pathLayout{layout}
+	if !layout.nested() {
+		// An entry written before path_layout was set keeps being used.
+		layouts = append(layouts, nestedLayout)
+	}
+	for _, l := range layouts {
+		var found []string
+		for _, ext := range entryExts(cfg) {
+			l.Ext = ext
+			candidate := filepath.Join(cfg.Root, filepath.FromSlash(l.render(pd)))
+			if _, err := os.Stat(candidate); err == nil {
+				found = append(found, candidate)
+			}
+		}
+		if len(found) == 0 {
+			continue
+		}
+		if len(found) > 1 {
+			others := make([]string, len(found)-1)
+			for i, f := range found[1:] {
+				others[i] = displayPath(f)
+			}
+			log.Printf(":::note::: %s has entries with more than one extension; using %s, not %s", pd.Iso(), displayPath(found[0]), strings.Join(others, ", "))
+		}
+		return checkInRoot(cfg, pd, found[0])
+	}
+	return checkInRoot(cfg, pd, p)
+}
+
+// checkInRoot returns p, the path of the entry for pd, if it lies under the
+// root once cleaned, and otherwise an error, so that no layout or date can
+// put an entry outside it.
+func checkInRoot(cfg Configuration, pd *DatePath, p string) (string, error) {
+	root := filepath.Clean(cfg.Root)
+	p = filepath.Clean(p)
+	if p == root || !insideDir(root, p) {
+		return "", fmt.Errorf("the entry for %s would be %s, which isn't inside the root %s", pd.Iso(), p, root)
+	}
+	return p, nil
+}
+
+// ensureEntry returns the path of the working memory file for pd, creating
+// it with the content returned by newEntry first if it doesn't exist.
+// newEntry is only called, and any error it returns reported, before anything
+// is created.  created reports whether the file was created by this call.
+func ensureEntry(cfg Configuration, pd *DatePath, newEntry func(*DatePath) (string, error)) (path string, created bool, err error) {
+	wmPath, err := entryPath(cfg, pd)
+	if err != nil {
+		return "", false, err
+	}
+	return ensureEntryAt(cfg, wmPath, pd, newEntry)
+}
+
+// ensureEntryAt is ensureEntry for the entry for pd at wmPath, such as the
+// entry of a topic.
+func ensureEntryAt(cfg Configuration, wmPath string, pd *DatePath, newEntry func(*DatePath) (string, error)) (path string, created bool, err error) {
+	_, err = os.Stat(wmPath)
+	if errors.Is(err, fs.ErrPermission) {
+		if err := repairDirs(cfg.Root, filepath.Dir(wmPath), dirMode(cfg)); err != nil {
+			return "", false, err
+		}
+		_, err = os.Stat(wmPath)
+	}
+	if err == nil {
+		return wmPath, false, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", false, fmt.Errorf("failed to verify working memory file exists: %w", err)
+	}
+	content, err := newEntry(pd)
+	if err != nil {
+		return "", false, err
+	}
+
+	err = initRootIfMissing(cfg)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create the root: %w", err)
+	}
+	wmDir := filepath.Dir(wmPath)
+	err = makeDir(cfg, wmDir)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create directory for working memory file: %w", err)
+	}
+
+	data, err := sealEntry(cfg, wmPath, []byte(content))
+	if err != nil {
+		return "", false, err
+	}
+	f, err := os.OpenFile(wmPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, fileMode(cfg))
+	if err != nil {
+		return "", false, fmt.Errorf("working memory file not found at '%s' and failed to create: %w", wmPath, err)
+	}
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		os.Remove(wmPath)
+		return "", false, fmt.Errorf("working memory file not found at '%s' and failed to write its content: %w", wmPath, err)
+	}
+	err = f.Close()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to close file with error %w", err)
+	}
+	return wmPath, true, nil
+}
+
+// createEntry creates the entry for pd from the template that applies to it,
+// unless it already exists, and returns its path.  It starts no editor.
+func createEntry(cfg Configuration, pd *DatePath) (string, error) {
+	tp := newTemplater(cfg, "", false)
+	path, _, err := ensureEntry(cfg, pd, tp.content)
+	return path, err
+}
+
+// creationAllowed decides whether a command may create the missing entry for
+// pd, and is the only place strict_create is interpreted:
+//
+//	strict_create  --create  command creates by design  result
+//	off            -         -                          create
+//	on             given     -                          create
+//	on             -         yes (fill)                 create
+//	on             -         no                         refuse
+//
+// Writes to entries that already exist are never affected.
+func creationAllowed(cfg Configuration, pd *DatePath, create bool, byDesign bool) error {
+	if !cfg.StrictCreate || create || byDesign {
+		return nil
+	}
+	return fmt.Errorf("no entry for %s (run with --create to start one)", pd.Iso())
+}
+
+// strictContent wraps newEntry so that it refuses, per creationAllowed,
+// before anything is created.
+func strictContent(cfg Configuration, create bool, newEntry func(*DatePath) (string, error)) func(*DatePath) (string, error) {
+	return func(pd *DatePath) (string, error) {
+		if err := creationAllowed(cfg, pd, create, false); err != nil {
+			return "", err
+		}
+		return newEntry(pd)
+	}
+}
+
+// How rewriteEntry writes the temporary file and renames it over the entry,
+// replaced by the tests to make either fail.
+var (
+	writeTemp   = func(f *os.File, data []byte) (int, error) { return f.Write(data) }
+	renameEntry = os.Rename
+)
+
+// rewriteOptions says what rewriteEntry does besides replacing the content.
+type rewriteOptions struct {
+	// Backup copies the entry to the versions store first.
+	Backup bool
+	// KeepModTime leaves the entry's modification time as it was, for
+	// rewrites that don't change what the entry says.
+	KeepModTime bool
+}
+
+// rewriteEntry replaces the content of the entry at path with data.  Every
+// command that rewrites entries goes through it so that a crash or a failed
+// write never leaves an entry truncated: data is written to a temporary file
+// in the same directory, synced, and renamed over the entry, which keeps its
+// mode.  While encrypt is on, data is encrypted.  It returns the path of the
+// backup, if one was made.
+func rewriteEntry(cfg Configuration, path string, data []byte, opts rewriteOptions) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	backup := ""
+	if opts.Backup {
+		backup, err = backupEntry(cfg, path)
+		if err != nil {
+			return "", err
+		}
+	}
+	data, err = sealEntry(cfg, path, data)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return backup, err
+	}
+	_, err = writeTemp(tmp, data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode())
+	}
+	if err == nil && opts.KeepModTime {
+		err = os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = retryLocked(func() error { return renameEntry(tmp.Name(), path) })
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return backup, err
+	}
+	syncDir(filepath.Dir(path))
+	return backup, nil
+}
+
+// syncDir makes a rename in dir durable where the platform allows it.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,225 @@
+package wm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEntry writes an entry for 2024-03-07 under a new root, returning its
+// path.
+func testEntry(t *testing.T, content string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "2024", "3")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "7.txt")
+	if err := os.WriteFile(path, []byte(content), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// tempFiles returns the names of the files rewriteEntry writes next to the
+// entry at path.
+func tempFiles(t *testing.T, path string) []string {
+	t.Helper()
+	found, err := filepath.Glob(filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func assertEntry(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("entry = %q, want %q", data, want)
+	}
+}
+
+func TestRewriteEntryWriteFails(t *testing.T) {
+	path := testEntry(t, "before\n")
+	errFull := errors.New("no space left on device")
+	writeTemp = func(f *os.File, data []byte) (int, error) {
+		n, _ := f.Write(data[:len(data)/2])
+		return n, errFull
+	}
+	t.Cleanup(func() { writeTemp = func(f *os.File, data []byte) (int, error) { return f.Write(data) } })
+
+	_, err := rewriteEntry(Configuration{}, path, []byte("after, and longer\n"), rewriteOptions{})
+	if !errors.Is(err, errFull) {
+		t.Fatalf("rewriteEntry = %v, want the write's error", err)
+	}
+	assertEntry(t, path, "before\n")
+	if left := tempFiles(t, path); len(left) > 0 {
+		t.Errorf("temporary files left behind: %q", left)
+	}
+}
+
+func TestRewriteEntryRenameFails(t *testing.T) {
+	path := testEntry(t, "before\n")
+	errBusy := errors.New("device or resource busy")
+	renameEntry = func(string, string) error { return errBusy }
+	t.Cleanup(func() { renameEntry = os.Rename })
+
+	_, err := rewriteEntry(Configuration{}, path, []byte("after\n"), rewriteOptions{})
+	if !errors.Is(err, errBusy) {
+		t.Fatalf("rewriteEntry = %v, want the rename's error", err)
+	}
+	assertEntry(t, path, "before\n")
+	if left := tempFiles(t, path); len(left) > 0 {
+		t.Errorf("temporary files left behind: %q", left)
+	}
+}
+
+func TestRewriteEntryLeftoverTemp(t *testing.T) {
+	path := testEntry(t, "before\n")
+	root := filepath.Dir(filepath.Dir(filepath.Dir(path)))
+	// what a rewrite killed halfway leaves
+	leftover := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".123456.tmp")
+	if err := os.WriteFile(leftover, []byte("aft"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := listEntries(Configuration{Root: root}, walkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Path != path {
+		t.Errorf("listEntries = %v, want only %s", entries, path)
+	}
+
+	old := time.Date(2024, 3, 7, 18, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rewriteEntry(Configuration{}, path, []byte("after\n"), rewriteOptions{KeepModTime: true}); err != nil {
+		t.Fatalf("rewriteEntry next to a leftover temporary file: %v", err)
+	}
+	assertEntry(t, path, "after\n")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Errorf("mode = %v, want the entry's own 0640", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("modification time = %v, want it kept at %v", info.ModTime(), old)
+	}
+	if left := tempFiles(t, path); len(left) != 1 || left[0] != leftover {
+		t.Errorf("temporary files = %q, want only the leftover", left)
+	}
+}
+
+func TestPathLayoutPerConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := Configuration{Root: root}
+	flat := Configuration{Root: root, PathLayout: "2006-01-02.md"}
+	pd := DatePath{2024, 3, 7}
+	for _, tt := range []struct {
+		cfg  Configuration
+		want string
+	}{{nested, "2024/3/7.txt"}, {flat, "2024-03-07.md"}, {nested, "2024/3/7.txt"}} {
+		path, err := entryPath(tt.cfg, &pd)
+		if want := filepath.Join(root, filepath.FromSlash(tt.want)); err != nil || path != want {
+			t.Errorf("entryPath(%q) = %s, %v, want %s", tt.cfg.PathLayout, path, err, want)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "2024-03-07.md"), []byte("flat\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		cfg  Configuration
+		want int
+	}{{flat, 1}, {nested, 0}} {
+		entries, err := listEntries(tt.cfg, walkOptions{})
+		if err != nil || len(entries) != tt.want {
+			t.Errorf("listEntries(%q) = %v, %v, want %d entries", tt.cfg.PathLayout, entries, err, tt.want)
+		}
+	}
+}
+
+func TestCreationAllowed(t *testing.T) {
+	pd := DatePath{2024, 3, 7}
+	for _, tt := range []struct {
+		strict, create, byDesign bool
+		allowed                  bool
+	}{
+		{false, false, false, true},
+		{false, true, false, true},
+		{true, true, false, true},
+		{true, false, true, true},
+		{true, false, false, false},
+	} {
+		err := creationAllowed(Configuration{StrictCreate: tt.strict}, &pd, tt.create, tt.byDesign)
+		if (err == nil) != tt.allowed {
+			t.Errorf("creationAllowed(strict %v, --create %v, by design %v) = %v, want allowed %v", tt.strict, tt.create, tt.byDesign, err, tt.allowed)
+		}
+		if err != nil && err.Error() != "no entry for 2024-03-07 (run with --create to start one)" {
+			t.Errorf("creationAllowed refused with %q", err)
+		}
+	}
+}
+
+func TestStrictCreate(t *testing.T) {
+	root := t.TempDir()
+	cfgFile, env := testHome(t, root)
+	testConfigAppend(t, cfgFile, "strict_create = true\n")
+	env = append(env, "WMCFG="+cfgFile, "WM_NOW=2024-03-10T09:00")
+	if err := writeRootMarker(Configuration{Root: root}); err != nil {
+		t.Fatal(err)
+	}
+	today := filepath.Join(root, "2024", "3", "10.txt")
+	wm := func(want int, args ...string) string {
+		t.Helper()
+		_, stderr, code := runWM(t, root, env, args...)
+		if code != want {
+			t.Errorf("wm %q exited %d, want %d: %s", args, code, want, stderr)
+		}
+		return string(stderr)
+	}
+
+	// refused before anything, directories included, is made
+	before := treeOf(t, root)
+	for _, tt := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"--no-edit", "today"}, exitFailure},
+		{[]string{"append", "note"}, exitFailure},
+		{[]string{"--read-only", "--create", "today"}, exitNoEntry},
+	} {
+		if stderr := wm(tt.code, tt.args...); !strings.Contains(stderr, "no entry for 2024-03-10") {
+			t.Errorf("wm %q = %s, want no entry for 2024-03-10", tt.args, stderr)
+		}
+		if after := treeOf(t, root); len(after) != len(before) {
+			t.Errorf("wm %q wrote %v under the root", tt.args, after)
+		}
+	}
+
+	wm(exitOK, "append", "--create", "first")
+	wm(exitOK, "append", "second")
+	data, err := os.ReadFile(today)
+	if err != nil || !strings.Contains(string(data), "first") || !strings.Contains(string(data), "second") {
+		t.Errorf("today's entry = %q, %v, want both appends", data, err)
+	}
+	wm(exitOK, "--no-edit", "today")
+	wm(exitOK, "--no-edit", "--create", "2024-03-09")
+	wm(exitOK, "fill", "--from=2024-03-01", "--to=2024-03-02")
+	for _, day := range []string{"9", "1", "2"} {
+		if _, err := os.Stat(filepath.Join(root, "2024", "3", day+".txt")); err != nil {
+			t.Errorf("entry for March %s: %v", day, err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultLockTimeout is how old a lock on an entry must be before it is
+// taken to be left over, from a machine that crashed or an editor left
+// open overnight, and stolen.
+const defaultLockTimeout = 8 * time.Hour
+
+// entryLock is who holds the lock on an entry, as written to its lock file,
+// "<host> <pid> <RFC 3339 time>".  The lock file sits next to the entry, so
+// that machines sharing a synced root see each other's.
+type entryLock struct {
+	Host  string
+	PID   int
+	Taken time.Time
+}
+
+func (l entryLock) String() string {
+	return fmt.Sprintf("%s %d %s\n", l.Host, l.PID, l.Taken.Format(time.RFC3339))
+}
+
+func entryLockPath(path string) string {
+	return path + ".lock"
+}
+
+// readEntryLock reads the lock file at lock.
+func readEntryLock(lock string) (entryLock, error) {
+	data, err := os.ReadFile(lock)
+	if err != nil {
+		return entryLock{}, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 3 {
+		return entryLock{}, fmt.Errorf("%s is not a wm lock file", lock)
+	}
+	pid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return entryLock{}, fmt.Errorf("%s is not a wm lock file", lock)
+	}
+	taken, err := time.Parse(time.RFC3339, fields[2])
+	if err != nil {
+		return entryLock{}, fmt.Errorf("%s is not a wm lock file", lock)
+	}
+	return entryLock{fields[0], pid, taken}, nil
+}
+
+// lockTimeout is the lock_timeout setting, defaultLockTimeout when unset.
+func lockTimeout(cfg Configuration) (time.Duration, error) {
+	if len(strings.TrimSpace(cfg.LockTimeout)) == 0 {
+		return defaultLockTimeout, nil
+	}
+	d, err := parseAge(cfg.LockTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("bad lock_timeout: %w", err)
+	}
+	return d, nil
+}
+
+// errEntryLocked is wrapped by the error of lockEntry when another wm holds
+// the lock.
+var errEntryLocked = errors.New("entry is locked")
+
+// lockEntry takes the lock on the entry at path for the process, returning
+// the function that releases it.  A lock held elsewhere is refused, naming
+// its holder, unless it is older than timeout, when it is stolen with a
+// note.  A lock file that can't be read is treated as stale, as wm never
+// writes one it can't read back.
+func lockEntry(path string, timeout time.Duration) (func(), error) {
+	lock := entryLockPath(path)
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	// to the second, as the lock file keeps it
+	mine := entryLock{host, os.Getpid(), now().Truncate(time.Second)}
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_, err = f.WriteString(mine.String())
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(lock)
+				return nil, err
+			}
+			holding(path, true)
+			return func() {
+				holding(path, false)
+				releaseEntryLock(lock, mine)
+			}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) || attempt > 0 {
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		held, err := readEntryLock(lock)
+		if err == nil && now().Sub(held.Taken) < timeout {
+			return nil, fmt.Errorf("%w: %s is being edited on %s by process %d since %s; wait for it, or remove the lock with \"wm unlock\"",
+				errEntryLocked, path, held.Host, held.PID, held.Taken.Format(time.RFC3339))
+		}
+		if err == nil {
+			log.Printf(":::note::: stealing the lock on %s held on %s by process %d since %s", path, held.Host, held.PID, held.Taken.Format(time.RFC3339))
+		} else {
+			log.Printf(":::note::: removing %s: %v", lock, err)
+		}
+		if err := os.Remove(lock); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+}
+
+// heldLocks are the entries whose lock this process holds, so that writing
+// to an entry the editor has open from here, such as a session marker before
+// it starts, isn't refused by the process's own lock.
+var heldLocks = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: map[string]bool{}}
+
+func holding(path string, held bool) {
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+	if held {
+		heldLocks.paths[filepath.Clean(path)] = true
+	} else {
+		delete(heldLocks.paths, filepath.Clean(path))
+	}
+}
+
+// lockForWrite takes the lock on the entry at path, as editFiles does, for
+// a command that writes to it without the editor, when lock_entries is on.
+// An addition to an entry open in an editor elsewhere would otherwise be
+// lost when the editor saves.  An entry this process holds needs no lock.
+func lockForWrite(cfg Configuration, path string) (func(), error) {
+	heldLocks.Lock()
+	held := heldLocks.paths[filepath.Clean(path)]
+	heldLocks.Unlock()
+	if !cfg.LockEntries || held {
+		return func() {}, nil
+	}
+	timeout, err := lockTimeout(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return lockEntry(path, timeout)
+}
+
+// releaseEntryLock removes the lock file at lock when it is still mine, so
+// that a lock stolen in the meantime is left to its new holder.
+func releaseEntryLock(lock string, mine entryLock) {
+	held, err := readEntryLock(lock)
+	if err != nil || held.Host != mine.Host || held.PID != mine.PID || !held.Taken.Equal(mine.Taken) {
+		return
+	}
+	os.Remove(lock)
+}
+
+// runUnlock removes the lock on the entry for the date given, whoever holds
+// it, for when wm can't: a machine that is gone, or an editor killed.
+func runUnlock(cfg Configuration, params Parameters) error {
+	_, target, err := resolveEntry(cfg, params)
+	if err != nil {
+		return err
+	}
+	lock := entryLockPath(target)
+	held, readErr := readEntryLock(lock)
+	if errors.Is(readErr, fs.ErrNotExist) {
+		fmt.Printf("%s is not locked\n", displayPath(target))
+		return nil
+	}
+	if err := os.Remove(lock); err != nil {
+		return err
+	}
+	if readErr != nil {
+		fmt.Printf("removed %s\n", displayPath(lock))
+		return nil
+	}
+	fmt.Printf("unlocked %s, held on %s by process %d since %s\n", displayPath(target), held.Host, held.PID, held.Taken.Format(time.RFC3339))
+	return nil
+}
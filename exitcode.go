@@ -0,0 +1,86 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit statuses of the open flow, documented under "Exit status" in the
+// usage text so that scripts and shell prompts can rely on them.  Every
+// other failure exits 1.
+const (
+	exitOK        = 0
+	exitFailure   = 1
+	exitCreated   = 3 // the entry was created, with --fail-if-created
+	exitEmpty     = 4 // the entry has nothing but its header, with --fail-if-empty
+	exitNoEntry   = 5 // the entry doesn't exist and --no-create, --strict, or a past date without --create kept it that way
+	exitEditorErr = 6 // the editor couldn't be started or failed; its own status is only reported on stderr
+)
+
+// Exit statuses of search, which are grep's, so that "wm search -q" can be
+// tested in shell conditionals.  A match exits 0.
+const (
+	exitNoMatch     = 1 // nothing matched
+	exitSearchError = 2 // the search couldn't be run, or nothing could be read
+	exitNoFiles     = 3 // the root has no files to search
+)
+
+// exitError carries the status an error should end the process with.  Err
+// is nil for a status with nothing to say, such as the 1 of "exists" for a
+// missing entry.
+type exitError struct {
+	Code int
+	Err  error
+}
+
+func (e *exitError) Error() string {
+	if e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+func (e *exitError) Unwrap() error { return e.Err }
+
+// withExitCode marks err to end the process with code.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{Code: code, Err: err}
+}
+
+// exitStatus is the error that ends the process with code and prints
+// nothing, or nil for 0.
+func exitStatus(code int) error {
+	if code == exitOK {
+		return nil
+	}
+	return &exitError{Code: code}
+}
+
+// failed marks err as the failure of a command, as failed("move failed",
+// err), ending the process with status 1 whatever status err carries.
+func failed(prefix string, err error) error {
+	return withExitCode(exitFailure, fmt.Errorf("%s: %w", prefix, err))
+}
+
+// exitCode is the status err should end the process with: the one it was
+// marked with, or 1.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return exitFailure
+}
+
+// ExitStatus is the status the wm command line exits with when Main returns
+// err: 0 for nil, 1 for most failures, and the others listed under "Exit
+// status" in wm --help.
+func ExitStatus(err error) int {
+	return exitCode(err)
+}
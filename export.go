@@ -0,0 +1,282 @@
+package wm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+)
+
+const defaultRedactTag = "#private"
+
+type exportEntry struct {
+	Date     string
+	Weekday  string
+	Topic    string
+	Body     string
+	Redacted bool
+	day      DatePath
+}
+
+type exportPage struct {
+	Title string
+	Range string
+	Print bool
+}
+
+// exportTemplate is written in pieces, the page head, each month's heading,
+// each entry, and the closing tags, so that export never holds more than one
+// entry in memory.
+var exportTemplate = template.Must(template.New("export").Parse(`
+{{- define "head" -}}
+<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; padding: 0 1em; line-height: 1.45; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.3em; border-bottom: 1px solid #ccc; }
+h3 { font-size: 1.05em; margin-bottom: 0.3em; }
+.body { white-space: pre-wrap; }
+.redacted { font-style: italic; color: #777; }
+.running { display: none; }
+{{- if .Print}}
+@page { margin: 2cm 2cm 2.5cm; @top-center { content: "{{.Range}}"; font-family: serif; font-size: 9pt; } }
+@media print {
+  body { font-family: Georgia, "Times New Roman", serif; font-size: 11pt; max-width: none; margin: 0; padding: 0; }
+  .running { display: block; position: fixed; top: 0; left: 0; right: 0; text-align: center; font-size: 9pt; color: #555; }
+  .month { break-before: page; page-break-before: always; }
+  .month:first-of-type { break-before: auto; page-break-before: auto; }
+  .entry { break-inside: avoid-page; page-break-inside: avoid; }
+  h2, h3 { break-after: avoid; page-break-after: avoid; }
+}
+{{- end}}
+</style>
+</head>
+<body>
+<div class="running">{{.Range}}</div>
+<h1>{{.Title}}</h1>
+{{- end}}
+{{- define "month"}}
+<section class="month">
+<h2>{{.}}</h2>
+{{- end}}
+{{- define "entry"}}
+<article class="entry" id="d{{.Date}}{{if .Topic}}-{{.Topic}}{{end}}">
+<h3>{{.Date}} ({{.Weekday}}){{if .Topic}} · {{.Topic}}{{end}}</h3>
+{{- if .Redacted}}
+<p class="redacted">redacted</p>
+{{- else}}
+<div class="body">{{.Body}}</div>
+{{- end}}
+</article>
+{{- end}}
+{{- define "monthEnd"}}
+</section>
+{{- end}}
+{{- define "foot"}}
+</body>
+</html>
+{{end}}`))
+
+// runExport writes the entries in the range as one Markdown document, or
+// with --html, or --format=html, as a single self-contained HTML file,
+// writing each entry as it is read.  Entries with nothing below their
+// header are left out, and how many entries and words were exported is
+// reported on stderr.  With --print the page carries a print stylesheet for
+// printing to PDF, and --redact-tag replaces entries carrying redact_tag
+// with a placeholder.
+func runExport(cfg Configuration, params Parameters) error {
+	html := params.Html
+	switch params.Format {
+	case "html":
+		html = true
+	case "", "human", "markdown", "md":
+	default:
+		return fmt.Errorf("unknown export format '%s', expected markdown or html", params.Format)
+	}
+	if params.Print && !html {
+		return errors.New("--print only applies to --html")
+	}
+	r, err := resolveQuery(cfg, queryFor(params), dayNow())
+	if err != nil {
+		return err
+	}
+	entries, err := rootEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	entries = filterEntries(entries, r.From, r.To)
+	if len(entries) == 0 {
+		return errors.New("no entries in range")
+	}
+	redactTag := cfg.RedactTag
+	if len(redactTag) == 0 {
+		redactTag = defaultRedactTag
+	}
+	var red *redactor
+	if params.Redacted {
+		red, err = configRedactor(cfg)
+		if err != nil {
+			return err
+		}
+		if len(red.rules) == 0 {
+			return errors.New("--redacted needs patterns or pattern_files in [redact]")
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if len(params.Out) > 0 {
+		f, err := os.Create(params.Out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	var out exportWriter = &markdownExport{w: bw}
+	if html {
+		out = &htmlExport{w: bw, print: params.Print}
+	}
+
+	prog := newProgress("exporting", len(entries))
+	exported, words := 0, 0
+	for _, e := range entries {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return err
+		}
+		ee := exportEntry{
+			Date:    e.Date.Iso(),
+			Weekday: weekdayName(e.Date.Time().Weekday()),
+			Topic:   e.Topic,
+			Body:    strings.Trim(string(stripHeader(data)), "\r\n"),
+			day:     e.Date,
+		}
+		prog.step()
+		if len(strings.TrimSpace(ee.Body)) == 0 {
+			continue
+		}
+		if red != nil {
+			ee.Body = red.redact(ee.Body)
+		}
+		if params.RedactTag && hasTag(data, redactTag) {
+			ee.Redacted = true
+			ee.Body = ""
+		}
+		if exported == 0 {
+			err = out.head(e.Date, entries[len(entries)-1].Date)
+		}
+		if err == nil {
+			err = out.entry(ee)
+		}
+		if err != nil {
+			return err
+		}
+		exported++
+		words += len(strings.Fields(ee.Body))
+	}
+	prog.finish()
+	if exported == 0 {
+		return errors.New("every entry in range is empty")
+	}
+	err = out.foot()
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		fmt.Fprintf(os.Stderr, "exported %d entries, %d words\n", exported, words)
+	}
+	return err
+}
+
+// exportWriter writes an export in one of its formats.  head is called
+// before the first entry with the first and last dates of the range.
+type exportWriter interface {
+	head(first, last DatePath) error
+	entry(ee exportEntry) error
+	foot() error
+}
+
+// markdownExport writes each entry under a level-two heading with its full
+// date, with its body as it is.
+type markdownExport struct {
+	w       io.Writer
+	written bool
+}
+
+func (m *markdownExport) head(first, last DatePath) error {
+	return nil
+}
+
+func (m *markdownExport) entry(ee exportEntry) error {
+	if m.written {
+		fmt.Fprintln(m.w)
+	}
+	m.written = true
+	heading := fmt.Sprintf("## %s, %s %d, %d", ee.Weekday, monthName(ee.day.month), ee.day.day, ee.day.year)
+	if len(ee.Topic) > 0 {
+		heading += " · " + ee.Topic
+	}
+	body := ee.Body
+	if ee.Redacted {
+		body = "_redacted_"
+	}
+	_, err := fmt.Fprintf(m.w, "%s\n\n%s\n", heading, body)
+	return err
+}
+
+func (m *markdownExport) foot() error {
+	return nil
+}
+
+// htmlExport writes the page through exportTemplate, with a section for
+// every month.
+type htmlExport struct {
+	w     io.Writer
+	print bool
+	month string
+}
+
+func (h *htmlExport) head(first, last DatePath) error {
+	page := exportPage{
+		Title: "Working Memory",
+		Range: first.Iso() + " – " + last.Iso(),
+		Print: h.print,
+	}
+	if first == last {
+		page.Range = first.Iso()
+	}
+	return exportTemplate.ExecuteTemplate(h.w, "head", page)
+}
+
+func (h *htmlExport) entry(ee exportEntry) error {
+	var err error
+	if name := fmt.Sprintf("%s %d", monthName(ee.day.month), ee.day.year); name != h.month {
+		if len(h.month) > 0 {
+			err = exportTemplate.ExecuteTemplate(h.w, "monthEnd", nil)
+		}
+		if err == nil {
+			err = exportTemplate.ExecuteTemplate(h.w, "month", name)
+		}
+		h.month = name
+	}
+	if err == nil {
+		err = exportTemplate.ExecuteTemplate(h.w, "entry", ee)
+	}
+	return err
+}
+
+func (h *htmlExport) foot() error {
+	err := exportTemplate.ExecuteTemplate(h.w, "monthEnd", nil)
+	if err == nil {
+		err = exportTemplate.ExecuteTemplate(h.w, "foot", nil)
+	}
+	return err
+}
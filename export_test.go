@@ -0,0 +1,106 @@
+package wm
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+// assertGolden compares got with testdata/name, or with -update rewrites it.
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from what was written:\n%s", path, got)
+	}
+}
+
+// testExportEntries writes entries across a month boundary, one of them
+// tagged private.
+func testExportEntries(t *testing.T, root string) {
+	t.Helper()
+	for _, e := range []struct {
+		pd   DatePath
+		body string
+	}{
+		{DatePath{2024, 2, 28}, "Decided: keep the <old> parser & ship.\n"},
+		{DatePath{2024, 2, 29}, "\n"},
+		{DatePath{2024, 3, 1}, "salary talk #private\n"},
+		{DatePath{2024, 3, 4}, "Decided: move the review to Fridays.\n"},
+	} {
+		testEntryBody(t, Configuration{Root: root}, e.pd, e.body)
+	}
+}
+
+func TestExportPrintGolden(t *testing.T) {
+	root := t.TempDir()
+	testExportEntries(t, root)
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+	out, stderr, code := runWM(t, root, env, "export", "--html", "--print", "--redact-tag", "2024-02-01", "2024-03-31")
+	if code != exitOK {
+		t.Fatalf("export exited %d: %s", code, stderr)
+	}
+	assertGolden(t, "export_print.html", out)
+	if !strings.Contains(string(stderr), "exported 3 entries") {
+		t.Errorf("stderr = %q, want 3 entries exported, the empty one left out", stderr)
+	}
+}
+
+func TestExportRedactTag(t *testing.T) {
+	root := t.TempDir()
+	testExportEntries(t, root)
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+
+	out, _, _ := runWM(t, root, env, "export", "--html", "2024-02-01", "2024-03-31")
+	if !strings.Contains(string(out), "salary talk") || strings.Contains(string(out), "@media print") {
+		t.Errorf("export --html without --print or --redact-tag = %s, want the private entry and no print stylesheet", out)
+	}
+
+	// with redact_tag configured, #private is an ordinary tag
+	testConfigAppend(t, cfgFile, "redact_tag = '#salary'\n")
+	testEntryBody(t, Configuration{Root: root}, DatePath{2024, 3, 5}, "#salary review\n")
+	out, _, _ = runWM(t, root, env, "export", "--html", "--redact-tag", "2024-02-01", "2024-03-31")
+	if got := strings.Count(string(out), `<p class="redacted">redacted</p>`); got != 1 || strings.Contains(string(out), "#salary review") {
+		t.Errorf("export with redact_tag = #salary has %d placeholders: %s, want the 2024-03-05 one", got, out)
+	}
+	if !strings.Contains(string(out), `id="d2024-03-05"`) {
+		t.Errorf("export dropped the redacted day: %s", out)
+	}
+
+	if _, stderr, code := runWM(t, root, env, "export", "--print", "2024-02-01", "2024-03-31"); code == exitOK || !strings.Contains(string(stderr), "--print only applies to --html") {
+		t.Errorf("export --print of Markdown = %d, %s, want an error", code, stderr)
+	}
+}
+
+// testEntryBody writes the entry for pd where cfg puts it, with body below
+// its header.
+func testEntryBody(t *testing.T, cfg Configuration, pd DatePath, body string) {
+	t.Helper()
+	path, err := entryPath(cfg, &pd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, append([]byte(renderHeader(&pd)), body...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
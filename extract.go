@@ -0,0 +1,110 @@
+package wm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ExtractConfig configures an extractor of marked lines, such as the
+// [decisions] table.  Pattern is a regular expression matching the first line
+// of an item; whatever it matches is dropped from the item's text.
+// Continuation groups the indented or bulleted lines right after a match into
+// the item and defaults to true.
+type ExtractConfig struct {
+	Pattern      string `toml:"pattern"`
+	Continuation *bool  `toml:"continuation"`
+}
+
+// extractor pulls marked items out of entries.
+type extractor struct {
+	re           *regexp.Regexp
+	continuation bool
+}
+
+// newExtractor compiles c, falling back to defaultPattern when it doesn't
+// set one.  name is the config table, for error messages.
+func newExtractor(name string, c ExtractConfig, defaultPattern string) (*extractor, error) {
+	pattern := c.Pattern
+	if len(pattern) == 0 {
+		pattern = defaultPattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("bad %s.pattern '%s': %w", name, pattern, err)
+	}
+	x := &extractor{re: re, continuation: true}
+	if c.Continuation != nil {
+		x.continuation = *c.Continuation
+	}
+	return x, nil
+}
+
+// extracted is one item found in an entry.  Line is the 1-based line of the
+// match and Continued holds its continuation lines without their indentation.
+type extracted struct {
+	Line      int
+	Text      string
+	Continued []string
+}
+
+var bulletRe = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s`)
+
+// indentWidth is the width of a line's leading whitespace, counting a tab as
+// four columns.
+func indentWidth(line string) int {
+	w := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			w++
+		case '\t':
+			w += 4
+		default:
+			return w
+		}
+	}
+	return w
+}
+
+// continues reports whether line belongs to the item started by first: it is
+// indented deeper than first, or a bullet following a line that isn't one.  A
+// blank line always ends the item.
+func continues(first, line string) bool {
+	if len(strings.TrimSpace(line)) == 0 {
+		return false
+	}
+	if indentWidth(line) > indentWidth(first) {
+		return true
+	}
+	return bulletRe.MatchString(line) && !bulletRe.MatchString(first)
+}
+
+// extract returns the items in data in the order they appear.  Session
+// markers never match and end an item.
+func (x *extractor) extract(data []byte) []extracted {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	var items []extracted
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		loc := x.re.FindStringIndex(line)
+		if loc == nil || isSessionMarker(line) {
+			continue
+		}
+		text := strings.TrimSpace(line[loc[1]:])
+		if len(text) == 0 {
+			text = strings.TrimSpace(line)
+		}
+		item := extracted{Line: i + 1, Text: text}
+		for x.continuation && i+1 < len(lines) {
+			next := lines[i+1]
+			if x.re.MatchString(next) || isSessionMarker(next) || !continues(line, next) {
+				break
+			}
+			item.Continued = append(item.Continued, strings.TrimSpace(next))
+			i++
+		}
+		items = append(items, item)
+	}
+	return items
+}
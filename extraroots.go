@@ -0,0 +1,94 @@
+package wm
+
+import (
+	"log"
+	"os"
+	"sort"
+)
+
+// Extra roots are further trees of entries, typically old years archived to
+// another drive, that search, list, stats, and export read along with the
+// root:
+//
+//	extra_roots = ['D:\archive\wm']
+//
+// New entries are only ever created under the root.
+
+// rootEntries is listEntries over the root and then every extra root, each
+// entry tagged with the root it was found under.  An entry for the same date
+// and topic in more than one of them is taken from the root, or from the
+// extra root listed first, with a note naming the others.  Extra roots that
+// are unavailable, such as an unplugged drive, are skipped with a note.
+func rootEntries(cfg Configuration, opts walkOptions) ([]Entry, error) {
+	all, err := listEntries(cfg, opts)
+	if err != nil || len(cfg.ExtraRoots) == 0 {
+		return all, err
+	}
+	type key struct {
+		Date  DatePath
+		Topic string
+	}
+	seen := map[key]string{}
+	for i := range all {
+		all[i].Root = cfg.Root
+		seen[key{all[i].Date, all[i].Topic}] = cfg.Root
+	}
+	for _, root := range cfg.ExtraRoots {
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			log.Printf(":::note::: skipping extra root %s: it is unavailable", root)
+			continue
+		}
+		extra := cfg
+		extra.Root = root
+		entries, err := listEntries(extra, opts)
+		if err != nil {
+			log.Printf(":::note::: skipping extra root %s: %v", root, err)
+			continue
+		}
+		for _, e := range entries {
+			k := key{e.Date, e.Topic}
+			if winner, ok := seen[k]; ok {
+				name := e.Date.Iso()
+				if len(e.Topic) > 0 {
+					name += " " + e.Topic
+				}
+				log.Printf(":::note::: the entry for %s is in both %s and %s; using the one in %s", name, winner, root, winner)
+				continue
+			}
+			seen[k] = root
+			e.Root = root
+			all = append(all, e)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.Date != b.Date {
+			return a.Date.Before(&b.Date)
+		}
+		return a.Topic < b.Topic
+	})
+	return all, nil
+}
+
+// archivedEntry returns the path of the entry for pd and topic in the first
+// extra root that has it, or in the directory archive moved it to, when the
+// root doesn't, so that opening a date kept only in an archive opens it
+// rather than creating an empty entry.  Otherwise target, the entry's path
+// under the root, is returned.
+func archivedEntry(cfg Configuration, pd *DatePath, topic, target string) string {
+	if _, err := os.Stat(target); err == nil {
+		return target
+	}
+	for _, root := range append(append([]string{}, cfg.ExtraRoots...), archiveDir(cfg, "")) {
+		extra := cfg
+		extra.Root = root
+		p, err := topicEntryPath(extra, pd, topic)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p
+		}
+	}
+	return target
+}
@@ -0,0 +1,209 @@
+package wm
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// With --fold-diacritics, or search_fold_diacritics, search matches terms
+// and entries with their accents taken off, so that "reunion" finds
+// "reunión" and the other way round: both are decomposed, NFD, and the
+// combining marks dropped.  A term that ignores case is also matched with
+// full Unicode case folding, so that "strasse" finds "Straße".  Matches are
+// found in the folded text, then mapped back to the bytes of the entry they
+// came from, so offsets, columns, and context are those of the file.
+
+// textFold is how a term and the text it is matched in are folded.
+type textFold int
+
+const (
+	foldNone textFold = iota
+	foldMarks
+	foldMarksAndCase
+)
+
+// foldedRunes caches what each rune other than ASCII folds to, by fold.
+var foldedRunes [foldMarksAndCase + 1]sync.Map
+
+// foldRune returns r decomposed, without its combining marks, and with
+// foldMarksAndCase case folded.
+func foldRune(r rune, f textFold) string {
+	if s, ok := foldedRunes[f].Load(r); ok {
+		return s.(string)
+	}
+	s := norm.NFD.String(string(r))
+	if f == foldMarksAndCase {
+		// a Caser can't be shared between the search workers
+		s = norm.NFD.String(cases.Fold().String(s))
+	}
+	s = strings.Map(func(c rune) rune {
+		if unicode.Is(unicode.Mn, c) {
+			return -1
+		}
+		return c
+	}, s)
+	foldedRunes[f].Store(r, s)
+	return s
+}
+
+// foldString returns s folded as f says.
+func foldString(s string, f textFold) string {
+	if f == foldNone {
+		return s
+	}
+	return string(foldText([]byte(s), f).text)
+}
+
+// foldPattern folds the literal text of the regular expression pattern,
+// and the single characters of its classes, leaving escapes such as \S and
+// \p{Lu} as they are.  A pattern that doesn't parse is returned as it is,
+// for compileTerms to report.
+func foldPattern(pattern string, f textFold) string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil || f == foldNone {
+		return pattern
+	}
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		switch re.Op {
+		case syntax.OpLiteral:
+			re.Rune = []rune(foldString(string(re.Rune), f))
+		case syntax.OpCharClass:
+			for i, n := 0, len(re.Rune); i < n; i += 2 {
+				if re.Rune[i] != re.Rune[i+1] {
+					continue
+				}
+				if folded := []rune(foldString(string(re.Rune[i]), f)); len(folded) == 1 && !inClass(re.Rune[:n], folded[0]) {
+					re.Rune = append(re.Rune, folded[0], folded[0])
+				}
+			}
+		}
+		for _, sub := range re.Sub {
+			walk(sub)
+		}
+	}
+	walk(re)
+	return re.String()
+}
+
+// inClass reports whether r is in the ranges of a character class.
+func inClass(ranges []rune, r rune) bool {
+	for i := 0; i < len(ranges); i += 2 {
+		if ranges[i] <= r && r <= ranges[i+1] {
+			return true
+		}
+	}
+	return false
+}
+
+// foldedText is text folded from orig, with src[i] the offset in orig of
+// the rune byte i of text came from, and src[len(text)] the length of orig.
+type foldedText struct {
+	orig []byte
+	text []byte
+	src  []int
+}
+
+func foldText(data []byte, f textFold) foldedText {
+	ft := foldedText{orig: data, text: make([]byte, 0, len(data)), src: make([]int, 0, len(data)+1)}
+	for i := 0; i < len(data); {
+		if c := data[i]; c < utf8.RuneSelf {
+			if f == foldMarksAndCase && 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			ft.text = append(ft.text, c)
+			ft.src = append(ft.src, i)
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		folded := string(data[i : i+size])
+		if r != utf8.RuneError || size > 1 {
+			folded = foldRune(r, f)
+		}
+		for j := 0; j < len(folded); j++ {
+			ft.text = append(ft.text, folded[j])
+			ft.src = append(ft.src, i)
+		}
+		i += size
+	}
+	ft.src = append(ft.src, len(data))
+	return ft
+}
+
+// span returns the bytes of orig that text[a:b] came from: whole runes,
+// with the marks dropped after the last of them, so a match is never cut
+// inside a character or between a letter and its accent.
+func (ft foldedText) span(a, b int) (int, int) {
+	start, end := ft.src[a], ft.src[b]
+	if b > a {
+		// b may be inside what a single rune folded to, as in the first
+		// "s" of the "ss" of "ß"
+		if last := ft.src[b-1]; end <= last {
+			_, size := utf8.DecodeRune(ft.orig[last:])
+			end = last + size
+		}
+	}
+	return start, end
+}
+
+// ignoresCase reports whether term is matched regardless of case in mode m.
+func ignoresCase(term string, m termMode) bool {
+	if m.SmartCase && hasCapitals(term, m.Fixed) {
+		return false
+	}
+	return m.IgnoreCase && (m.Fixed || !setsCase(term))
+}
+
+// termFolds returns how each term is folded in mode m, or nil when
+// diacritics aren't folded.
+func termFolds(terms []string, m termMode) []textFold {
+	if !m.Fold {
+		return nil
+	}
+	folds := make([]textFold, len(terms))
+	for i, t := range terms {
+		folds[i] = foldMarks
+		if ignoresCase(t, m) {
+			folds[i] = foldMarksAndCase
+		}
+	}
+	return folds
+}
+
+// findAll returns up to n matches of the i-th term of q in data, all of them
+// when n is negative, as FindAllIndex does, folding data first when the
+// term is.  The offsets are those of data either way.
+func (q searchTerms) findAll(i int, data []byte, n int) [][]int {
+	re := q.Res[i]
+	if q.Folds == nil || q.Folds[i] == foldNone {
+		return re.FindAllIndex(data, n)
+	}
+	return foldedFindAll(re, data, n, q.Folds[i])
+}
+
+func foldedFindAll(re *regexp.Regexp, data []byte, n int, f textFold) [][]int {
+	ft := foldText(data, f)
+	locs := re.FindAllIndex(ft.text, n)
+	kept := locs[:0]
+	for _, m := range locs {
+		m[0], m[1] = ft.span(m[0], m[1])
+		// matches inside what one rune folded to, as "s" twice in "ß",
+		// are the same hit in the entry
+		if k := len(kept); k > 0 && kept[k-1][0] == m[0] && kept[k-1][1] == m[1] {
+			continue
+		}
+		kept = append(kept, m)
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
@@ -0,0 +1,152 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// defaultFollowInterval is how often search --follow polls for changes when
+// follow_interval isn't set.
+const defaultFollowInterval = 2 * time.Second
+
+// followedFile is what search --follow remembers about one file.  Offset is
+// the end of the last complete line scanned, so a line still being written is
+// only scanned once it is finished; Reported holds the lines already shown,
+// so a file rewritten by an editor doesn't repeat its old matches.
+type followedFile struct {
+	Size     int64
+	ModTime  time.Time
+	Offset   int
+	Reported map[string]bool
+}
+
+// completeLines is the length of data up to and including its last newline.
+func completeLines(data []byte) int {
+	return bytes.LastIndexByte(data, '\n') + 1
+}
+
+// followHit is a match found while following, with its 1-based line.
+type followHit struct {
+	Line int
+	Text string
+}
+
+// scanFrom returns the matches of the terms of q in the complete lines of
+// data from the line containing offset on.
+func scanFrom(data []byte, offset int, q searchTerms) []followHit {
+	end := completeLines(data)
+	if offset > end {
+		offset = end
+	}
+	start := bytes.LastIndexByte(data[:offset], '\n') + 1
+	firstLine := bytes.Count(data[:start], []byte("\n")) + 1
+	chunk := data[start:end]
+	var hits []followHit
+	for i := range q.Res {
+		loc := newLocator(chunk)
+		for _, m := range q.findAll(i, chunk, -1) {
+			line, _ := loc.locate(m[0])
+			hits = append(hits, followHit{firstLine + line - 1, lineAt(chunk, m[0])})
+		}
+	}
+	return hits
+}
+
+func (f *followedFile) key(h followHit) string {
+	return fmt.Sprintf("%d:%s", h.Line, h.Text)
+}
+
+// followInterval is the polling interval from follow_interval.
+func followInterval(cfg Configuration) (time.Duration, error) {
+	if len(cfg.FollowInterval) == 0 {
+		return defaultFollowInterval, nil
+	}
+	d, err := time.ParseDuration(cfg.FollowInterval)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("bad follow_interval '%s', expected a duration such as 2s", cfg.FollowInterval)
+	}
+	return d, nil
+}
+
+// followSearch keeps watching the entries in r after the initial results have
+// been printed and writes every new match as it appears, with the entry's
+// date and the time it was seen.  Only entries in the range are watched; new
+// entries created in it are picked up.  It returns on Ctrl-C after a summary.
+func followSearch(w io.Writer, cfg Configuration, params Parameters, r dateRange, q searchTerms) error {
+	interval, err := followInterval(cfg)
+	if err != nil {
+		return err
+	}
+	files := map[string]*followedFile{}
+	poll := func(report bool) (int, error) {
+		all, err := listEntries(cfg, walkOptionsFor(params))
+		if err != nil {
+			return 0, err
+		}
+		found := 0
+		for _, e := range filterEntries(all, r.From, r.To) {
+			info, err := os.Stat(e.Path)
+			if err != nil {
+				continue
+			}
+			f, known := files[e.Path]
+			if known && info.Size() == f.Size && info.ModTime().Equal(f.ModTime) {
+				continue
+			}
+			if !known {
+				f = &followedFile{Reported: map[string]bool{}}
+				files[e.Path] = f
+			}
+			data, err := readEntry(cfg, e.Path)
+			if err != nil || isBinary(data) {
+				continue
+			}
+			if len(data) < f.Offset {
+				f.Offset = 0
+			}
+			for _, h := range scanFrom(data, f.Offset, q) {
+				if f.Reported[f.key(h)] {
+					continue
+				}
+				f.Reported[f.key(h)] = true
+				if report {
+					found++
+					fmt.Fprintf(w, "[%s, seen %s] %s:%d: %s\n", humanDate(e.Date), now().Format("15:04:05"), e.Path, h.Line, strings.TrimSpace(h.Text))
+				}
+			}
+			f.Size, f.ModTime, f.Offset = info.Size(), info.ModTime(), completeLines(data)
+		}
+		return found, nil
+	}
+	if _, err := poll(false); err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	started := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	fmt.Fprintf(w, "following %d entries for new matches every %s; press Ctrl-C to stop\n", len(files), interval)
+	total := 0
+	for {
+		select {
+		case <-interrupt:
+			fmt.Fprintf(w, "\nstopped after %s with %d new matches\n", time.Since(started).Round(time.Second), total)
+			return nil
+		case <-ticker.C:
+			n, err := poll(true)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			total += n
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package wm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Entries may carry front matter, fields such as mood, location, or project
+// between two "---" lines, written in TOML or as YAML's "key: value" lines:
+//
+//	---
+//	project: apollo
+//	mood: good
+//	---
+//
+// It is only taken for front matter at the very top of the entry or right
+// after the generated header, so a "---" rule further down is never
+// mistaken for it.  A template can start new entries with a block.
+
+var frontMatterKeyRe = regexp.MustCompile(`^([A-Za-z0-9_.-]+)\s*:\s*(.*)$`)
+
+func isFrontMatterFence(line []byte) bool {
+	return string(bytes.TrimRight(line, "\r\n")) == "---"
+}
+
+// frontMatterSpan returns the offsets of the front matter block of data,
+// fences included, and whether it has one.
+func frontMatterSpan(data []byte) (start, end int, ok bool) {
+	if h, found := findHeader(data); found {
+		start = h.End
+	}
+	lines := splitLines(data[start:])
+	if len(lines) == 0 || !isFrontMatterFence(lines[0]) {
+		return 0, 0, false
+	}
+	end = start + len(lines[0])
+	for _, l := range lines[1:] {
+		end += len(l)
+		if isFrontMatterFence(l) {
+			return start, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// parseFrontMatter returns the fields of the front matter of an entry and
+// the entry without it.  An entry without front matter, as most are, has no
+// fields and is returned as it is.  Nested TOML tables give dotted keys, and
+// lists their items joined by ", ".
+func parseFrontMatter(data []byte) (map[string]string, []byte, error) {
+	start, end, ok := frontMatterSpan(data)
+	if !ok {
+		return nil, data, nil
+	}
+	lines := splitLines(data[start:end])
+	block := bytes.Join(lines[1:len(lines)-1], nil)
+	fields := map[string]string{}
+	var decoded map[string]interface{}
+	if _, err := toml.Decode(string(block), &decoded); err == nil {
+		flattenFrontMatter("", decoded, fields)
+	} else if err := parseFrontMatterLines(block, fields); err != nil {
+		return nil, data, err
+	}
+	rest := append(append([]byte{}, data[:start]...), data[end:]...)
+	return fields, rest, nil
+}
+
+func flattenFrontMatter(prefix string, v interface{}, fields map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if len(prefix) > 0 {
+				k = prefix + "." + k
+			}
+			flattenFrontMatter(k, item, fields)
+		}
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		fields[prefix] = strings.Join(items, ", ")
+	case time.Time:
+		fields[prefix] = v.Format(time.RFC3339)
+	default:
+		fields[prefix] = fmt.Sprint(v)
+	}
+}
+
+// parseFrontMatterLines reads the YAML form: "key: value" lines, values
+// optionally quoted or written as a [list], and # comments.  Anything more
+// of YAML than that is refused.
+func parseFrontMatterLines(block []byte, fields map[string]string) error {
+	for i, line := range strings.Split(string(block), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := frontMatterKeyRe.FindStringSubmatch(line)
+		if m == nil {
+			return fmt.Errorf("line %d of the front matter is neither TOML nor a \"key: value\" line", i+1)
+		}
+		value := m[2]
+		switch {
+		case len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0]:
+			value = value[1 : len(value)-1]
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			items := strings.Split(value[1:len(value)-1], ",")
+			for j, item := range items {
+				items[j] = strings.Trim(strings.TrimSpace(item), `"'`)
+			}
+			value = strings.Join(items, ", ")
+		default:
+			if k := strings.Index(value, " #"); k >= 0 {
+				value = strings.TrimSpace(value[:k])
+			}
+		}
+		fields[m[1]] = value
+	}
+	return nil
+}
+
+// frontMatterMatches reports whether the field value matches want, ignoring
+// case: the whole of it, or one item of a list.
+func frontMatterMatches(value, want string) bool {
+	if strings.EqualFold(value, want) {
+		return true
+	}
+	for _, item := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), want) {
+			return true
+		}
+	}
+	return false
+}
+
+// metaHit is an entry whose front matter has the field asked for.
+type metaHit struct {
+	Entry Entry
+	Value string
+}
+
+// runMeta lists the entries in range whose front matter sets <key>, to
+// <value> when one is given, with the value each sets.  Entries whose front
+// matter can't be read are skipped with a note.
+func runMeta(cfg Configuration, params Parameters) error {
+	r, err := resolveQuery(cfg, queryFor(params), dayNow())
+	if err != nil {
+		return err
+	}
+	entries, err := rootEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	var hits []metaHit
+	for _, e := range filterEntries(entries, r.From, r.To) {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return err
+		}
+		fields, _, err := parseFrontMatter(data)
+		if err != nil {
+			log.Printf(":::note::: skipping %s: %v", displayPath(e.Path), err)
+			continue
+		}
+		for k, v := range fields {
+			if strings.EqualFold(k, params.Key) && (len(params.Value) == 0 || frontMatterMatches(v, params.Value)) {
+				hits = append(hits, metaHit{e, v})
+				break
+			}
+		}
+	}
+	if len(hits) == 0 {
+		fmt.Printf("no entries with %s\n", metaQueryName(params))
+		return nil
+	}
+	printMetaHits(os.Stdout, cfg, hits)
+	return nil
+}
+
+func metaQueryName(params Parameters) string {
+	if len(params.Value) > 0 {
+		return fmt.Sprintf("%s = %s", params.Key, params.Value)
+	}
+	return params.Key
+}
+
+func printMetaHits(w io.Writer, cfg Configuration, hits []metaHit) {
+	width := 0
+	for _, h := range hits {
+		if n := len([]rune(h.Value)); n > width {
+			width = n
+		}
+	}
+	for _, h := range hits {
+		pad := strings.Repeat(" ", width-len([]rune(h.Value)))
+		fmt.Fprintf(w, "%s  %s%s  %s\n", humanDate(h.Entry.Date), h.Value, pad, faint(relEntryPath(cfg, h.Entry.Path)))
+	}
+}
@@ -0,0 +1,81 @@
+package wm
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// gapLookbackYears bounds how far back lastEntryBefore looks in the nested
+// layout: this year and the one before, at most 24 month directories.
+const gapLookbackYears = 2
+
+// lastEntryBefore returns the date of the latest main entry before pd.  In
+// the nested layout it reads the month index back from pd's month for
+// gapLookbackYears, so that opening today's entry stays fast; other layouts
+// walk the root.
+func lastEntryBefore(cfg Configuration, pd *DatePath) (DatePath, bool, error) {
+	x := loadMonthIndex(cfg)
+	defer x.save()
+	year, month := pd.year, pd.month
+	for year > pd.year-gapLookbackYears {
+		days, ok, err := x.days(year, month)
+		if err != nil {
+			return DatePath{}, false, err
+		}
+		if !ok {
+			break
+		}
+		last := 0
+		for d := range days {
+			if d > last && (year != pd.year || month != pd.month || d < pd.day) {
+				last = d
+			}
+		}
+		if last > 0 {
+			return DatePath{year: year, month: month, day: last}, true, nil
+		}
+		if month--; month == 0 {
+			year, month = year-1, 12
+		}
+	}
+	if entryLayout(cfg).nested() {
+		return DatePath{}, false, nil
+	}
+	entries, err := listEntries(cfg, walkOptions{})
+	if err != nil {
+		return DatePath{}, false, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if e := entries[i]; len(e.Topic) == 0 && e.Date.Before(pd) {
+			return e.Date, true, nil
+		}
+	}
+	return DatePath{}, false, nil
+}
+
+// gapNotice tells, when today's entry is opened on a terminal, how long ago
+// the previous entry was, and warns when that is more than gap_warning_days.
+// Nothing is said in a root without earlier entries.
+func gapNotice(w io.Writer, cfg Configuration, pd *DatePath) {
+	today := datePathFromTime(dayNow())
+	if *pd != today || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return
+	}
+	last, ok, err := lastEntryBefore(cfg, pd)
+	if err != nil || !ok {
+		return
+	}
+	days := daysBetween(last, today)
+	ago := fmt.Sprintf("%d days ago", days)
+	if days == 1 {
+		ago = "yesterday"
+	}
+	if cfg.GapWarningDays > 0 && days > cfg.GapWarningDays {
+		fmt.Fprintf(w, "warning: last entry: %s (%s), more than gap_warning_days = %d\n", last.Iso(), ago, cfg.GapWarningDays)
+		return
+	}
+	fmt.Fprintf(w, "last entry: %s (%s)\n", last.Iso(), ago)
+}
@@ -0,0 +1,109 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runGit runs git in dir and returns its standard output, or an error
+// carrying what it printed to standard error.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// inWorkTree reports whether dir is inside a git work tree.
+func inWorkTree(dir string) bool {
+	out, err := runGit(dir, "rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// commitEntry commits the entry at path, and nothing else staged, when it
+// changed, with a message naming its date and topic.
+func commitEntry(cfg Configuration, path string, pd *DatePath, topic string) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return errors.New("git not found")
+	}
+	if !inWorkTree(cfg.Root) {
+		return nil
+	}
+	status, err := runGit(cfg.Root, "status", "--porcelain", "--", path)
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(status)) == 0 {
+		return nil
+	}
+	msg := "wm: " + pd.Iso()
+	if len(topic) > 0 {
+		msg += " " + topic
+	}
+	if _, err := runGit(cfg.Root, "add", "--", path); err != nil {
+		return err
+	}
+	_, err = runGit(cfg.Root, "commit", "--quiet", "-m", msg, "--", path)
+	return err
+}
+
+// autocommit commits the entry just edited when git_autocommit is on.
+// Failing to is only noted: a missing git or a repository in the middle of a
+// merge must not get in the way of writing.
+func autocommit(cfg Configuration, path string, pd *DatePath, topic string) {
+	if !cfg.GitAutocommit {
+		return
+	}
+	if err := commitEntry(cfg, path, pd, topic); err != nil {
+		log.Printf(":::note::: %s was not committed: %v", path, err)
+	}
+}
+
+// runSync commits every change under the root, then pulls with --rebase and
+// pushes, so that one command brings a log kept in git up to date both
+// ways.
+func runSync(cfg Configuration) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return errors.New("git not found")
+	}
+	if !inWorkTree(cfg.Root) {
+		return fmt.Errorf("%s is not in a git work tree", cfg.Root)
+	}
+	root, err := filepath.Abs(cfg.Root)
+	if err != nil {
+		return err
+	}
+	status, err := runGit(root, "status", "--porcelain", "--", ".")
+	if err != nil {
+		return err
+	}
+	if changed := strings.TrimSpace(status); len(changed) > 0 {
+		if _, err := runGit(root, "add", "--all", "--", "."); err != nil {
+			return err
+		}
+		if _, err := runGit(root, "commit", "--quiet", "-m", "wm: sync", "--", "."); err != nil {
+			return err
+		}
+		fmt.Printf("committed %d changed files\n", len(strings.Split(changed, "\n")))
+	}
+	if _, err := runGit(root, "pull", "--rebase", "--quiet"); err != nil {
+		return err
+	}
+	if _, err := runGit(root, "push", "--quiet"); err != nil {
+		return err
+	}
+	fmt.Println("synced")
+	return nil
+}
@@ -5,4 +5,9 @@ go 1.19
 require (
 	github.com/BurntSushi/toml v1.2.0
 	github.com/docopt/docopt-go v0.0.0-20180111231733-ee0de3bc6815
+	golang.org/x/crypto v0.6.0
+	golang.org/x/term v0.5.0
+	golang.org/x/text v0.7.0
 )
+
+require golang.org/x/sys v0.5.0 // indirect
@@ -0,0 +1,86 @@
+package wm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// wordCounter counts the words of entries as daily_word_goal sees them:
+// only what was written, without the generated header, front matter,
+// session markers, or the lines of the template the entry was started from.
+// list, stats, and the line printed after an edit all count with it.
+type wordCounter struct {
+	tp *templater
+}
+
+func newWordCounter(cfg Configuration) *wordCounter {
+	return &wordCounter{tp: newTemplater(cfg, "", false)}
+}
+
+// boilerplate returns the lines the template for pd renders, trimmed, so
+// that headings and prompts it puts in every entry aren't counted.  A
+// template that can't be read or rendered has none.
+func (wc *wordCounter) boilerplate(pd *DatePath) map[string]bool {
+	lines := map[string]bool{}
+	body, err := wc.tp.render(pd)
+	if err != nil {
+		tracef("word count: %v", err)
+		return lines
+	}
+	for _, l := range strings.Split(body, "\n") {
+		if l = strings.TrimSpace(l); len(l) > 0 {
+			lines[l] = true
+		}
+	}
+	return lines
+}
+
+// count returns the number of words written in data, the entry of pd.
+func (wc *wordCounter) count(pd *DatePath, data []byte) int {
+	if start, end, ok := frontMatterSpan(data); ok {
+		data = append(append([]byte{}, data[:start]...), data[end:]...)
+	}
+	boiler := wc.boilerplate(pd)
+	n := 0
+	for _, l := range strings.Split(string(stripHeader(data)), "\n") {
+		l = strings.TrimSpace(l)
+		if len(l) == 0 || boiler[l] || isSessionMarker(l) {
+			continue
+		}
+		n += len(strings.Fields(l))
+	}
+	return n
+}
+
+// goalMark is what list shows next to the word count of an entry that
+// reached daily_word_goal.
+const goalMark = "✓"
+
+// printGoalProgress writes how many words the entry of pd, just edited,
+// has against daily_word_goal, e.g. "412 words today — goal met (goal:
+// 300)".  data is the entry as saved.
+func printGoalProgress(w io.Writer, cfg Configuration, pd *DatePath, data []byte) {
+	if cfg.DailyWordGoal <= 0 {
+		return
+	}
+	words := newWordCounter(cfg).count(pd, data)
+	unit := "words"
+	if words == 1 {
+		unit = "word"
+	}
+	day := "on " + pd.Iso()
+	if *pd == datePathFromTime(dayNow()) {
+		day = "today"
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s %s — ", groupDigits(words), unit, day)
+	if words >= cfg.DailyWordGoal {
+		b.WriteString("goal met")
+	} else {
+		fmt.Fprintf(&b, "%s to go", groupDigits(cfg.DailyWordGoal-words))
+	}
+	fmt.Fprintf(&b, " (goal: %s)\n", groupDigits(cfg.DailyWordGoal))
+	w.Write(b.Bytes())
+}
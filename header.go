@@ -0,0 +1,152 @@
+package wm
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// The generated header looks like:
+//
+//	Working Memory File
+//	3/7/2024
+//	-------------------
+//
+// Every feature that reads or writes the header goes through this file so
+// that none of them mistake user content for a header or mangle it.
+
+const headerTitle = "Working Memory File"
+
+var (
+	headerTitleRe = regexp.MustCompile(`(?i)^\s*working\s+memory\s+file\b[\s:.-]*$`)
+	headerDateRe  = regexp.MustCompile(`^\s*(\d{1,2})/(\d{1,2})/(\d{4})\s*$`)
+	headerRuleRe  = regexp.MustCompile(`^\s*-{3,}\s*$`)
+)
+
+// header describes the generated header found in an entry.  Start and End are
+// byte offsets of the region, End including the blank line after the rule
+// when present.  Date is nil when the date line is missing or unreadable,
+// otherwise DateLine is its 1-based line number.
+type header struct {
+	Start    int
+	End      int
+	Date     *DatePath
+	DateLine int
+}
+
+// renderHeader returns the header written at the top of new entries.
+func renderHeader(dp *DatePath) string {
+	return fmt.Sprintf("%s\n%s\n-------------------\n\n", headerTitle, headerDateLine(dp))
+}
+
+// headerDateLine returns the date as it appears in the header.
+func headerDateLine(dp *DatePath) string {
+	return fmt.Sprintf("%d/%d/%d", dp.month, dp.day, dp.year)
+}
+
+// splitLines splits data into lines keeping their terminators, so offsets can
+// be recovered by summing lengths.
+func splitLines(data []byte) [][]byte {
+	var lines [][]byte
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, data)
+			break
+		}
+		lines = append(lines, data[:i+1])
+		data = data[i+1:]
+	}
+	return lines
+}
+
+func isBlank(line []byte) bool {
+	return len(bytes.TrimSpace(line)) == 0
+}
+
+// parseHeaderDate reads a header date line, returning nil if it isn't one.
+func parseHeaderDate(line []byte) *DatePath {
+	m := headerDateRe.FindSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	month, _ := strconv.Atoi(string(m[1]))
+	day, _ := strconv.Atoi(string(m[2]))
+	year, _ := strconv.Atoi(string(m[3]))
+	dp := DatePath{year: year, month: month, day: day}
+	if datePathFromTime(dp.Time()) != dp {
+		return nil
+	}
+	return &dp
+}
+
+// findHeader locates the generated header in data.  It tolerates leading
+// blank lines, changes in case or punctuation of the title, a removed date
+// line or rule, and a deleted title as long as the date and rule lines remain.
+// Anything else is treated as user content and ok is false.
+func findHeader(data []byte) (h header, ok bool) {
+	lines := splitLines(data)
+	i, off := 0, 0
+	for i < len(lines) && isBlank(lines[i]) {
+		off += len(lines[i])
+		i++
+	}
+	if i == len(lines) {
+		return header{}, false
+	}
+	h.Start = off
+
+	title := headerTitleRe.Match(lines[i])
+	if title {
+		off += len(lines[i])
+		i++
+	}
+	if i < len(lines) {
+		if dp := parseHeaderDate(lines[i]); dp != nil {
+			h.Date = dp
+			h.DateLine = i + 1
+			off += len(lines[i])
+			i++
+		}
+	}
+	rule := false
+	if i < len(lines) && headerRuleRe.Match(lines[i]) && (title || h.Date != nil) {
+		rule = true
+		off += len(lines[i])
+		i++
+	}
+	if !title && !(h.Date != nil && rule) {
+		return header{}, false
+	}
+	if i < len(lines) && isBlank(lines[i]) {
+		off += len(lines[i])
+	}
+	h.End = off
+	return h, true
+}
+
+// stripHeader returns data without its generated header, if it has one.
+func stripHeader(data []byte) []byte {
+	h, ok := findHeader(data)
+	if !ok {
+		return data
+	}
+	return data[h.End:]
+}
+
+// rewriteHeader replaces the header in data with the one for dp.  When data
+// has no recognisable header, one is inserted at the top and the existing
+// content is left untouched below it.
+func rewriteHeader(data []byte, dp *DatePath) []byte {
+	rendered := renderHeader(dp)
+	h, ok := findHeader(data)
+	if !ok {
+		return append([]byte(rendered), data...)
+	}
+	var b bytes.Buffer
+	b.Write(data[:h.Start])
+	b.WriteString(rendered)
+	b.Write(data[h.End:])
+	return b.Bytes()
+}
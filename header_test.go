@@ -0,0 +1,74 @@
+package wm
+
+import (
+	"bytes"
+	"testing"
+)
+
+// headerCorpus is entries as users leave them: headers edited, cut down,
+// or gone, and content that only looks like a header.  rest is what
+// follows the header, or all of the entry when there is none.
+var headerCorpus = []struct {
+	name string
+	in   string
+	ok   bool
+	date *DatePath
+	rest string
+}{
+	{"intact", "Working Memory File\n3/7/2024\n-------------------\n\nnotes\n", true, &DatePath{2024, 3, 7}, "notes\n"},
+	{"crlf", "Working Memory File\r\n3/7/2024\r\n-------------------\r\n\r\nnotes\r\n", true, &DatePath{2024, 3, 7}, "notes\r\n"},
+	{"title edited", "  working memory FILE:\n3/7/2024\n---\nnotes\n", true, &DatePath{2024, 3, 7}, "notes\n"},
+	{"leading blanks", "\n\nWorking Memory File\n3/7/2024\n-------------------\n\nnotes\n", true, &DatePath{2024, 3, 7}, "notes\n"},
+	{"no date", "Working Memory File\n-------------------\n\nnotes\n", true, nil, "notes\n"},
+	{"no rule", "Working Memory File\n3/7/2024\nnotes\n", true, &DatePath{2024, 3, 7}, "notes\n"},
+	{"title only", "Working Memory File\nnotes\n", true, nil, "notes\n"},
+	{"no title", "3/7/2024\n-------------------\n\nnotes\n", true, &DatePath{2024, 3, 7}, "notes\n"},
+	{"no blank after", "Working Memory File\n3/7/2024\n-------------------\nnotes\n", true, &DatePath{2024, 3, 7}, "notes\n"},
+	{"second rule kept", "Working Memory File\n3/7/2024\n---\n\n---\nnotes\n", true, &DatePath{2024, 3, 7}, "---\nnotes\n"},
+	{"impossible date kept", "Working Memory File\n2/30/2024\n---\nnotes\n", true, nil, "2/30/2024\n---\nnotes\n"},
+	{"header only", "Working Memory File\n3/7/2024\n-------------------\n", true, &DatePath{2024, 3, 7}, ""},
+
+	{"empty", "", false, nil, ""},
+	{"blank", "\n\n  \n", false, nil, "\n\n  \n"},
+	{"plain notes", "met Bob\n---\n", false, nil, "met Bob\n---\n"},
+	{"rule first", "-------------------\nnotes\n", false, nil, "-------------------\nnotes\n"},
+	{"date without rule", "3/7/2024\nmet Bob\n", false, nil, "3/7/2024\nmet Bob\n"},
+	{"title in a sentence", "Working memory file format, discussed\nnotes\n", false, nil, "Working memory file format, discussed\nnotes\n"},
+	{"title further down", "notes\nWorking Memory File\n3/7/2024\n---\n", false, nil, "notes\nWorking Memory File\n3/7/2024\n---\n"},
+	{"markdown front", "# March 7\n\n- [ ] todo\n", false, nil, "# March 7\n\n- [ ] todo\n"},
+}
+
+func TestFindHeader(t *testing.T) {
+	for _, tt := range headerCorpus {
+		h, ok := findHeader([]byte(tt.in))
+		if ok != tt.ok {
+			t.Errorf("%s: findHeader ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if (h.Date == nil) != (tt.date == nil) || h.Date != nil && *h.Date != *tt.date {
+			t.Errorf("%s: date = %v, want %v", tt.name, h.Date, tt.date)
+		}
+		if got := string(stripHeader([]byte(tt.in))); got != tt.rest {
+			t.Errorf("%s: stripHeader = %q, want %q", tt.name, got, tt.rest)
+		}
+	}
+}
+
+func TestRewriteHeader(t *testing.T) {
+	pd := DatePath{2024, 3, 8}
+	for _, tt := range headerCorpus {
+		got := rewriteHeader([]byte(tt.in), &pd)
+		h, ok := findHeader(got)
+		if !ok || h.Date == nil || *h.Date != pd {
+			t.Errorf("%s: rewritten header = %v, %v, want one for %s", tt.name, h.Date, ok, pd.Iso())
+			continue
+		}
+		// nothing but the header changes, and the rewrite is stable
+		if rest := string(stripHeader(got)); rest != tt.rest {
+			t.Errorf("%s: content after rewriting = %q, want %q", tt.name, rest, tt.rest)
+		}
+		if again := rewriteHeader(got, &pd); !bytes.Equal(again, got) {
+			t.Errorf("%s: rewriting twice = %q, want %q", tt.name, again, got)
+		}
+	}
+}
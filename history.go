@@ -0,0 +1,208 @@
+package wm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	historyFile    = "history.json"
+	historyVersion = 1
+	// historyLimit is how many invocations are kept per root.
+	historyLimit = 100
+)
+
+// historyRecord is one recorded invocation: its time and its arguments as
+// given, which is all that is needed to run it again.
+type historyRecord struct {
+	When time.Time `json:"when"`
+	Args []string  `json:"args"`
+}
+
+// unrecordedCommands are never recorded: history and redo themselves, the
+// porcelain that scripts and shell prompts call, and append, whose argument
+// is entry content.
+var unrecordedCommands = map[string]bool{
+	"history": true,
+	"redo":    true,
+	"info":    true,
+	"exists":  true,
+	"path":    true,
+	"append":  true,
+}
+
+// historyArgs maps "wm !!" to "wm redo", before parsing.
+func historyArgs(args []string) []string {
+	if len(args) > 0 && args[0] == "!!" {
+		return append([]string{"redo"}, args[1:]...)
+	}
+	return args
+}
+
+// historyEnabled reports whether the invocation of command should be
+// recorded.  Setting command_history = false turns recording off, and runs
+// with the clock fixed by --now or WM_NOW, which reproduce reports in a
+// sandbox, are never recorded.
+func historyEnabled(cfg Configuration, command string) bool {
+	if cfg.CommandHistory != nil && !*cfg.CommandHistory {
+		return false
+	}
+	if clockFixed {
+		return false
+	}
+	return !unrecordedCommands[command]
+}
+
+func decodeHistory(data []byte) (map[string][]historyRecord, error) {
+	all := map[string][]historyRecord{}
+	if len(data) == 0 {
+		return all, nil
+	}
+	err := json.Unmarshal(data, &all)
+	if err != nil {
+		return nil, fmt.Errorf("bad %s: %w", historyFile, err)
+	}
+	return all, nil
+}
+
+// readHistory returns the recorded invocations for root, oldest first.
+func readHistory(root string) ([]historyRecord, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := readState(filepath.Join(dir, historyFile), historyVersion)
+	if errors.Is(err, errStateCorrupt) {
+		log.Println(":::note::: ignoring unreadable", historyFile)
+		data, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	all, err := decodeHistory(data)
+	if err != nil {
+		return nil, err
+	}
+	return all[rootKey(root)], nil
+}
+
+// recordHistory appends args to the history of root, keeping the last
+// historyLimit invocations.  Like recordUsage, failures are ignored and a
+// slow disk is given a short grace period at most.
+func recordHistory(root string, args []string) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		path, err := statePath(historyFile)
+		if err != nil {
+			return
+		}
+		updateState(path, historyVersion, func(old []byte) ([]byte, error) {
+			all, err := decodeHistory(old)
+			if err != nil {
+				all = map[string][]historyRecord{}
+			}
+			key := rootKey(root)
+			records := append(all[key], historyRecord{When: now(), Args: args})
+			if len(records) > historyLimit {
+				records = records[len(records)-historyLimit:]
+			}
+			all[key] = records
+			return json.MarshalIndent(all, "", "  ")
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+var shellSafeRe = regexp.MustCompile(`^[A-Za-z0-9@%+=:,./_-]+$`)
+
+// shellQuote quotes arg for a POSIX shell, leaving plain words alone.
+func shellQuote(arg string) string {
+	if shellSafeRe.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// commandLine renders args as a command line that can be pasted into a shell.
+func commandLine(args []string) string {
+	parts := []string{"wm"}
+	for _, a := range args {
+		parts = append(parts, shellQuote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// runHistory lists the recorded invocations for the root, most recent first,
+// numbered as "redo" takes them.
+func runHistory(cfg Configuration) error {
+	records, err := readHistory(cfg.Root)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Println("no commands recorded yet")
+		return nil
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		r := records[i]
+		fmt.Printf("%3d  %s  %s\n", len(records)-i, formatTime(cfg, r.When), commandLine(r.Args))
+	}
+	return nil
+}
+
+// runRedo runs the n-th most recent recorded invocation again, the last one
+// by default, returning its exit status.  The arguments are passed to a new
+// wm process as they were recorded, with no shell in between, so they are
+// parsed exactly as the first time.  With --edit the command line is printed
+// instead.
+func runRedo(cfg Configuration, params Parameters) (int, error) {
+	n := 1
+	if len(params.Count) > 0 {
+		var err error
+		n, err = strconv.Atoi(params.Count)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("'%s' is not a positive number", params.Count)
+		}
+	}
+	records, err := readHistory(cfg.Root)
+	if err != nil {
+		return 0, err
+	}
+	if n > len(records) {
+		if len(records) == 0 {
+			return 0, errors.New("no commands recorded yet")
+		}
+		return 0, fmt.Errorf("only %d commands recorded", len(records))
+	}
+	args := records[len(records)-n].Args
+	if params.Edit {
+		fmt.Println(commandLine(args))
+		return 0, nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	log.Println(":::note::: running", commandLine(args))
+	cmd := exec.Command(self, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
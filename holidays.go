@@ -0,0 +1,390 @@
+package wm
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Holidays are days off that workday keywords such as lastworkday skip.  They
+// come from the holidays list in the configuration and from holidays_file, a
+// text file of "YYYY-MM-DD Name" lines that "holidays import" generates from
+// the country presets below.
+
+// holidayKind selects how a holiday rule finds its date.
+type holidayKind int
+
+const (
+	// fixedDate is Month/Day every year.
+	fixedDate holidayKind = iota
+	// nthWeekday is the Nth Weekday of Month; a negative N counts from the
+	// end of the month.
+	nthWeekday
+	// weekdayOnOrBefore is the last Weekday on or before Month/Day.
+	weekdayOnOrBefore
+	// easterOffset is Day days after Western Easter Sunday.
+	easterOffset
+)
+
+// observance says what happens to a holiday falling on a weekend.
+type observance int
+
+const (
+	// observedNone keeps the holiday on its day.
+	observedNone observance = iota
+	// observedNearest moves Saturday to Friday and Sunday to Monday.
+	observedNearest
+	// observedNext moves it to the next weekday that isn't already a holiday.
+	observedNext
+)
+
+type holidayRule struct {
+	Name    string
+	Kind    holidayKind
+	Month   time.Month
+	Day     int
+	Weekday time.Weekday
+	N       int
+	// Since is the first year the holiday was observed, if it matters.
+	Since int
+}
+
+type holidayPreset struct {
+	Name     string
+	Observed observance
+	Rules    []holidayRule
+}
+
+var holidayPresets = map[string]holidayPreset{
+	"US": {"United States (federal)", observedNearest, []holidayRule{
+		{Name: "New Year's Day", Kind: fixedDate, Month: time.January, Day: 1},
+		{Name: "Martin Luther King Jr. Day", Kind: nthWeekday, Month: time.January, Weekday: time.Monday, N: 3},
+		{Name: "Washington's Birthday", Kind: nthWeekday, Month: time.February, Weekday: time.Monday, N: 3},
+		{Name: "Memorial Day", Kind: nthWeekday, Month: time.May, Weekday: time.Monday, N: -1},
+		{Name: "Juneteenth", Kind: fixedDate, Month: time.June, Day: 19, Since: 2021},
+		{Name: "Independence Day", Kind: fixedDate, Month: time.July, Day: 4},
+		{Name: "Labor Day", Kind: nthWeekday, Month: time.September, Weekday: time.Monday, N: 1},
+		{Name: "Columbus Day", Kind: nthWeekday, Month: time.October, Weekday: time.Monday, N: 2},
+		{Name: "Veterans Day", Kind: fixedDate, Month: time.November, Day: 11},
+		{Name: "Thanksgiving Day", Kind: nthWeekday, Month: time.November, Weekday: time.Thursday, N: 4},
+		{Name: "Christmas Day", Kind: fixedDate, Month: time.December, Day: 25},
+	}},
+	"GB": {"United Kingdom (England and Wales)", observedNext, []holidayRule{
+		{Name: "New Year's Day", Kind: fixedDate, Month: time.January, Day: 1},
+		{Name: "Good Friday", Kind: easterOffset, Day: -2},
+		{Name: "Easter Monday", Kind: easterOffset, Day: 1},
+		{Name: "Early May bank holiday", Kind: nthWeekday, Month: time.May, Weekday: time.Monday, N: 1},
+		{Name: "Spring bank holiday", Kind: nthWeekday, Month: time.May, Weekday: time.Monday, N: -1},
+		{Name: "Summer bank holiday", Kind: nthWeekday, Month: time.August, Weekday: time.Monday, N: -1},
+		{Name: "Christmas Day", Kind: fixedDate, Month: time.December, Day: 25},
+		{Name: "Boxing Day", Kind: fixedDate, Month: time.December, Day: 26},
+	}},
+	"CA": {"Canada (federal)", observedNext, []holidayRule{
+		{Name: "New Year's Day", Kind: fixedDate, Month: time.January, Day: 1},
+		{Name: "Good Friday", Kind: easterOffset, Day: -2},
+		{Name: "Victoria Day", Kind: weekdayOnOrBefore, Month: time.May, Day: 24, Weekday: time.Monday},
+		{Name: "Canada Day", Kind: fixedDate, Month: time.July, Day: 1},
+		{Name: "Labour Day", Kind: nthWeekday, Month: time.September, Weekday: time.Monday, N: 1},
+		{Name: "National Day for Truth and Reconciliation", Kind: fixedDate, Month: time.September, Day: 30, Since: 2021},
+		{Name: "Thanksgiving", Kind: nthWeekday, Month: time.October, Weekday: time.Monday, N: 2},
+		{Name: "Remembrance Day", Kind: fixedDate, Month: time.November, Day: 11},
+		{Name: "Christmas Day", Kind: fixedDate, Month: time.December, Day: 25},
+		{Name: "Boxing Day", Kind: fixedDate, Month: time.December, Day: 26},
+	}},
+	"DE": {"Germany (national)", observedNone, []holidayRule{
+		{Name: "New Year's Day", Kind: fixedDate, Month: time.January, Day: 1},
+		{Name: "Good Friday", Kind: easterOffset, Day: -2},
+		{Name: "Easter Monday", Kind: easterOffset, Day: 1},
+		{Name: "Labour Day", Kind: fixedDate, Month: time.May, Day: 1},
+		{Name: "Ascension Day", Kind: easterOffset, Day: 39},
+		{Name: "Whit Monday", Kind: easterOffset, Day: 50},
+		{Name: "German Unity Day", Kind: fixedDate, Month: time.October, Day: 3},
+		{Name: "Christmas Day", Kind: fixedDate, Month: time.December, Day: 25},
+		{Name: "Second Day of Christmas", Kind: fixedDate, Month: time.December, Day: 26},
+	}},
+	"FR": {"France", observedNone, []holidayRule{
+		{Name: "New Year's Day", Kind: fixedDate, Month: time.January, Day: 1},
+		{Name: "Easter Monday", Kind: easterOffset, Day: 1},
+		{Name: "Labour Day", Kind: fixedDate, Month: time.May, Day: 1},
+		{Name: "Victory in Europe Day", Kind: fixedDate, Month: time.May, Day: 8},
+		{Name: "Ascension Day", Kind: easterOffset, Day: 39},
+		{Name: "Whit Monday", Kind: easterOffset, Day: 50},
+		{Name: "Bastille Day", Kind: fixedDate, Month: time.July, Day: 14},
+		{Name: "Assumption", Kind: fixedDate, Month: time.August, Day: 15},
+		{Name: "All Saints' Day", Kind: fixedDate, Month: time.November, Day: 1},
+		{Name: "Armistice Day", Kind: fixedDate, Month: time.November, Day: 11},
+		{Name: "Christmas Day", Kind: fixedDate, Month: time.December, Day: 25},
+	}},
+}
+
+// easter returns Western Easter Sunday of year, by the anonymous Gregorian
+// algorithm.
+func easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 12, 0, 0, 0, time.Local)
+}
+
+// date returns the day the rule falls on in year, before any observance.
+func (r holidayRule) date(year int) time.Time {
+	switch r.Kind {
+	case nthWeekday:
+		if r.N < 0 {
+			d := time.Date(year, r.Month+1, 0, 12, 0, 0, 0, time.Local)
+			d = d.AddDate(0, 0, -((int(d.Weekday()) - int(r.Weekday) + 7) % 7))
+			return d.AddDate(0, 0, 7*(r.N+1))
+		}
+		d := time.Date(year, r.Month, 1, 12, 0, 0, 0, time.Local)
+		d = d.AddDate(0, 0, (int(r.Weekday)-int(d.Weekday())+7)%7)
+		return d.AddDate(0, 0, 7*(r.N-1))
+	case weekdayOnOrBefore:
+		d := time.Date(year, r.Month, r.Day, 12, 0, 0, 0, time.Local)
+		return d.AddDate(0, 0, -((int(d.Weekday()) - int(r.Weekday) + 7) % 7))
+	case easterOffset:
+		return easter(year).AddDate(0, 0, r.Day)
+	}
+	return time.Date(year, r.Month, r.Day, 12, 0, 0, 0, time.Local)
+}
+
+// holiday is one day off.
+type holiday struct {
+	Date DatePath
+	Name string
+}
+
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// presetHolidays returns the observed holidays of a country in year, in date
+// order.  With observedNext the holidays that already fall on weekdays are
+// placed first, so a substitute day never lands on another holiday: in 2022
+// Boxing Day stays on Monday the 26th and Christmas moves to Tuesday the 27th.
+func presetHolidays(country string, year int) ([]holiday, error) {
+	preset, ok := holidayPresets[strings.ToUpper(country)]
+	if !ok {
+		return nil, fmt.Errorf("no holiday preset for '%s', expected one of %s", country, strings.Join(holidayCountries(), ", "))
+	}
+	taken := map[DatePath]bool{}
+	var days []holiday
+	var weekend []holidayRule
+	for _, r := range preset.Rules {
+		if r.Since > year {
+			continue
+		}
+		d := r.date(year)
+		if !isWeekend(d) || preset.Observed == observedNone {
+			days = append(days, holiday{datePathFromTime(d), r.Name})
+			taken[datePathFromTime(d)] = true
+			continue
+		}
+		weekend = append(weekend, r)
+	}
+	for _, r := range weekend {
+		d := r.date(year)
+		name := r.Name + " (observed)"
+		switch preset.Observed {
+		case observedNearest:
+			if d.Weekday() == time.Saturday {
+				d = d.AddDate(0, 0, -1)
+			} else {
+				d = d.AddDate(0, 0, 1)
+			}
+		case observedNext:
+			for isWeekend(d) || taken[datePathFromTime(d)] {
+				d = d.AddDate(0, 0, 1)
+			}
+		}
+		days = append(days, holiday{datePathFromTime(d), name})
+		taken[datePathFromTime(d)] = true
+	}
+	sort.SliceStable(days, func(i, j int) bool { return days[i].Date.Before(&days[j].Date) })
+	return days, nil
+}
+
+func holidayCountries() []string {
+	var codes []string
+	for code := range holidayPresets {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// holidays are the configured days off, set when the configuration is
+// loaded.
+var holidays = map[DatePath]string{}
+
+// isHoliday reports whether t's calendar day is a configured holiday.
+func isHoliday(t time.Time) bool {
+	_, ok := holidays[datePathFromTime(t)]
+	return ok
+}
+
+// holidaysFilePath is where holidays_file points, relative to the
+// configuration file, or holidays.txt next to it when unset.
+func holidaysFilePath(cfg Configuration) string {
+	p := cfg.HolidaysFile
+	if len(p) == 0 {
+		p = "holidays.txt"
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(cfg.dir, p)
+	}
+	return p
+}
+
+// parseHolidayLine reads a "YYYY-MM-DD Name" line.
+func parseHolidayLine(line string) (holiday, error) {
+	date, name, _ := strings.Cut(strings.TrimSpace(line), " ")
+	t, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return holiday{}, fmt.Errorf("'%s' doesn't start with a YYYY-MM-DD date", line)
+	}
+	return holiday{datePathFromTime(t), strings.TrimSpace(name)}, nil
+}
+
+// readHolidaysFile reads a holidays file, skipping blank lines and # comments.
+// A missing file has no holidays.
+func readHolidaysFile(path string) ([]holiday, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var days []holiday
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		h, err := parseHolidayLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		days = append(days, h)
+	}
+	return days, scanner.Err()
+}
+
+// loadHolidays collects the holidays of the configuration.
+func loadHolidays(cfg Configuration) error {
+	loaded := map[DatePath]string{}
+	for _, line := range cfg.Holidays {
+		h, err := parseHolidayLine(line)
+		if err != nil {
+			return fmt.Errorf("holidays: %w", err)
+		}
+		loaded[h.Date] = h.Name
+	}
+	days, err := readHolidaysFile(holidaysFilePath(cfg))
+	if err != nil {
+		return err
+	}
+	for _, h := range days {
+		if _, ok := loaded[h.Date]; !ok {
+			loaded[h.Date] = h.Name
+		}
+	}
+	holidays = loaded
+	return nil
+}
+
+// runHolidaysImport writes the preset holidays of a country and year into the
+// holidays file, keeping the days already in it, and points holidays_file at
+// it when the configuration doesn't yet.
+func runHolidaysImport(cfg Configuration, cfgFile string, params Parameters) error {
+	year, err := strconv.Atoi(params.Year)
+	if err != nil {
+		return fmt.Errorf("bad year '%s'", params.Year)
+	}
+	days, err := presetHolidays(params.Country, year)
+	if err != nil {
+		return err
+	}
+	path := holidaysFilePath(cfg)
+	existing, err := readHolidaysFile(path)
+	if err != nil {
+		return err
+	}
+	merged := map[DatePath]string{}
+	for _, h := range existing {
+		merged[h.Date] = h.Name
+	}
+	added := 0
+	for _, h := range days {
+		if _, ok := merged[h.Date]; !ok {
+			merged[h.Date] = h.Name
+			added++
+		}
+	}
+	all := make([]holiday, 0, len(merged))
+	for d, name := range merged {
+		all = append(all, holiday{d, name})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Date.Before(&all[j].Date) })
+	var b bytes.Buffer
+	b.WriteString("# Holidays skipped by workday keywords, one \"YYYY-MM-DD Name\" per line.\n")
+	for _, h := range all {
+		fmt.Fprintf(&b, "%s %s\n", h.Date.Iso(), h.Name)
+	}
+	err = os.WriteFile(path, b.Bytes(), 0o644)
+	if err != nil {
+		return err
+	}
+	if len(cfg.HolidaysFile) == 0 {
+		err = editConfig(cfgFile, "", "holidays_file", tomlString(filepath.Base(path)))
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("added %d %s holidays for %d to %s\n", added, strings.ToUpper(params.Country), year, path)
+	return nil
+}
+
+// runHolidaysList prints the active holidays, optionally for one year.
+func runHolidaysList(params Parameters) error {
+	year := 0
+	if len(params.Year) > 0 {
+		y, err := strconv.Atoi(params.Year)
+		if err != nil {
+			return fmt.Errorf("bad year '%s'", params.Year)
+		}
+		year = y
+	}
+	var days []holiday
+	for d, name := range holidays {
+		if year == 0 || d.year == year {
+			days = append(days, holiday{d, name})
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date.Before(&days[j].Date) })
+	if len(days) == 0 {
+		fmt.Println("no holidays configured; try holidays import --country=<code> --year=<year>")
+		return nil
+	}
+	for _, h := range days {
+		fmt.Printf("%s %s  %s\n", h.Date.Iso(), weekdayAbbr(h.Date.Time().Weekday()), h.Name)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package wm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEaster(t *testing.T) {
+	for year, want := range map[int]string{
+		2019: "2019-04-21", 2024: "2024-03-31", 2025: "2025-04-20", 2038: "2038-04-25", 2285: "2285-03-22",
+	} {
+		if got := easter(year).Format("2006-01-02"); got != want {
+			t.Errorf("easter(%d) = %s, want %s", year, got, want)
+		}
+	}
+}
+
+// TestPresetHolidays checks the presets against the published calendars of
+// years whose weekends move holidays in each direction.
+func TestPresetHolidays(t *testing.T) {
+	tests := []struct {
+		country string
+		year    int
+		want    []string
+	}{
+		{"US", 2022, []string{
+			"2021-12-31 New Year's Day (observed)", "2022-01-17 Martin Luther King Jr. Day", "2022-02-21 Washington's Birthday",
+			"2022-05-30 Memorial Day", "2022-06-20 Juneteenth (observed)", "2022-07-04 Independence Day", "2022-09-05 Labor Day",
+			"2022-10-10 Columbus Day", "2022-11-11 Veterans Day", "2022-11-24 Thanksgiving Day", "2022-12-26 Christmas Day (observed)",
+		}},
+		{"US", 2023, []string{
+			"2023-01-02 New Year's Day (observed)", "2023-01-16 Martin Luther King Jr. Day", "2023-02-20 Washington's Birthday",
+			"2023-05-29 Memorial Day", "2023-06-19 Juneteenth", "2023-07-04 Independence Day", "2023-09-04 Labor Day",
+			"2023-10-09 Columbus Day", "2023-11-10 Veterans Day (observed)", "2023-11-23 Thanksgiving Day", "2023-12-25 Christmas Day",
+		}},
+		// before Juneteenth was a federal holiday
+		{"us", 2020, []string{
+			"2020-01-01 New Year's Day", "2020-01-20 Martin Luther King Jr. Day", "2020-02-17 Washington's Birthday",
+			"2020-05-25 Memorial Day", "2020-07-03 Independence Day (observed)", "2020-09-07 Labor Day",
+			"2020-10-12 Columbus Day", "2020-11-11 Veterans Day", "2020-11-26 Thanksgiving Day", "2020-12-25 Christmas Day",
+		}},
+		// Christmas and Boxing Day both on a weekend: the 27th and 28th
+		{"GB", 2021, []string{
+			"2021-01-01 New Year's Day", "2021-04-02 Good Friday", "2021-04-05 Easter Monday", "2021-05-03 Early May bank holiday",
+			"2021-05-31 Spring bank holiday", "2021-08-30 Summer bank holiday", "2021-12-27 Christmas Day (observed)",
+			"2021-12-28 Boxing Day (observed)",
+		}},
+		// Boxing Day on the Monday keeps it, Christmas moves past it
+		{"GB", 2022, []string{
+			"2022-01-03 New Year's Day (observed)", "2022-04-15 Good Friday", "2022-04-18 Easter Monday",
+			"2022-05-02 Early May bank holiday", "2022-05-30 Spring bank holiday", "2022-08-29 Summer bank holiday",
+			"2022-12-26 Boxing Day", "2022-12-27 Christmas Day (observed)",
+		}},
+		{"CA", 2024, []string{
+			"2024-01-01 New Year's Day", "2024-03-29 Good Friday", "2024-05-20 Victoria Day", "2024-07-01 Canada Day",
+			"2024-09-02 Labour Day", "2024-09-30 National Day for Truth and Reconciliation", "2024-10-14 Thanksgiving",
+			"2024-11-11 Remembrance Day", "2024-12-25 Christmas Day", "2024-12-26 Boxing Day",
+		}},
+		// no observed days: Christmas stays on the Sunday
+		{"DE", 2022, []string{
+			"2022-01-01 New Year's Day", "2022-04-15 Good Friday", "2022-04-18 Easter Monday", "2022-05-01 Labour Day",
+			"2022-05-26 Ascension Day", "2022-06-06 Whit Monday", "2022-10-03 German Unity Day", "2022-12-25 Christmas Day",
+			"2022-12-26 Second Day of Christmas",
+		}},
+	}
+	for _, tt := range tests {
+		days, err := presetHolidays(tt.country, tt.year)
+		if err != nil {
+			t.Errorf("%s %d: %v", tt.country, tt.year, err)
+			continue
+		}
+		var got []string
+		for _, h := range days {
+			got = append(got, h.Date.Iso()+" "+h.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %d =\n%s\nwant\n%s", tt.country, tt.year, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+
+	_, err := presetHolidays("XX", 2024)
+	if err == nil || !strings.Contains(err.Error(), "CA, DE, FR, GB, US") {
+		t.Errorf("presetHolidays(XX) = %v, want the supported countries listed", err)
+	}
+}
+
+func TestHolidaysImport(t *testing.T) {
+	root := t.TempDir()
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+	holidaysFile := filepath.Join(filepath.Dir(cfgFile), "holidays.txt")
+	if err := os.WriteFile(holidaysFile, []byte("# mine\n2024-12-24 Christmas Eve\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, stderr, code := runWM(t, root, env, "holidays", "import", "--country=us", "--year=2024")
+	if code != exitOK || !strings.Contains(string(out), "added 11 US holidays for 2024") {
+		t.Fatalf("holidays import = %d, %s%s", code, out, stderr)
+	}
+	data, err := os.ReadFile(cfgFile)
+	if err != nil || !strings.Contains(string(data), `holidays_file = "holidays.txt"`) {
+		t.Errorf("configuration after import = %q, %v, want holidays_file set", data, err)
+	}
+
+	out, _, _ = runWM(t, root, env, "holidays", "list", "--year=2024")
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 12 || !strings.HasPrefix(lines[0], "2024-01-01 Mon") || !strings.Contains(lines[10], "Christmas Eve") {
+		t.Errorf("holidays list --year=2024 =\n%s\nwant the 11 imported days and the one kept", out)
+	}
+
+	// importing again adds nothing
+	out, _, _ = runWM(t, root, env, "holidays", "import", "--country=US", "--year=2024")
+	if !strings.Contains(string(out), "added 0 US holidays") {
+		t.Errorf("importing again = %s, want nothing added", out)
+	}
+	if _, stderr, code := runWM(t, root, env, "holidays", "import", "--country=XX", "--year=2024"); code == exitOK || !strings.Contains(string(stderr), "expected one of CA, DE, FR, GB, US") {
+		t.Errorf("importing XX = %d, %s, want an error listing the presets", code, stderr)
+	}
+}
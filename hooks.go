@@ -0,0 +1,120 @@
+package wm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// HooksConfig lists the commands run around editing an entry: PreOpen ones
+// before the editor starts, any of them failing stopping it from being
+// opened, and PostSave ones once the editor exits, their failures only
+// noted.  Each is a command line as editor is, run with the entry's
+// environment, WM_FILE, WM_DATE, and WM_ROOT among it.
+type HooksConfig struct {
+	PostSave []string `toml:"post_save"`
+	PreOpen  []string `toml:"pre_open"`
+}
+
+// defaultHookTimeoutSeconds is how long a hook may run before it is
+// killed, unless hook_timeout_seconds says otherwise.
+const defaultHookTimeoutSeconds = 30
+
+// hookTimeout returns the configured hook_timeout_seconds, or the default.
+func hookTimeout(cfg Configuration) time.Duration {
+	if cfg.HookTimeoutSeconds > 0 {
+		return time.Duration(cfg.HookTimeoutSeconds) * time.Second
+	}
+	return defaultHookTimeoutSeconds * time.Second
+}
+
+// hookProblems returns what is wrong with the configured hooks, for
+// validateConfig.
+func hookProblems(cfg Configuration) []string {
+	var problems []string
+	check := func(key string, hooks []string) {
+		for _, h := range hooks {
+			if argv, err := splitCommandLine(h); err != nil || len(argv) == 0 {
+				problems = append(problems, fmt.Sprintf(`config: "hooks.%s" has a command that can't be run: '%s'`, key, h))
+			}
+		}
+	}
+	check("pre_open", cfg.Hooks.PreOpen)
+	check("post_save", cfg.Hooks.PostSave)
+	if cfg.HookTimeoutSeconds < 0 {
+		problems = append(problems, fmt.Sprintf(`config: "hook_timeout_seconds" must be >= 0, got %d`, cfg.HookTimeoutSeconds))
+	}
+	return problems
+}
+
+// runHook runs the hook command line with the environment of the entry t
+// and file, killing it after hook_timeout_seconds.  Its output goes to
+// standard error as it runs; what it wrote to standard error is returned
+// too, for the error to show.
+func runHook(cfg Configuration, t editTarget, file, hook string) ([]byte, error) {
+	argv, err := splitCommandLine(hook)
+	if err != nil || len(argv) == 0 {
+		return nil, fmt.Errorf("the hook '%s' can't be run", hook)
+	}
+	timeout := hookTimeout(cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = editorEnv(cfg, t, file)
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	tracef("hook: %s on %s", argvLine(cmd.Args), file)
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return stderr.Bytes(), fmt.Errorf("the hook '%s' was killed after %s", hook, timeout)
+	}
+	if err != nil {
+		return stderr.Bytes(), fmt.Errorf("the hook '%s' failed: %w", hook, err)
+	}
+	return stderr.Bytes(), nil
+}
+
+// preOpenHooks runs the pre_open hooks on each of paths before the editor
+// is started on them, returning the first failure with what the hook wrote
+// to standard error.  Nothing is run with --dry-run.
+func preOpenHooks(cfg Configuration, t editTarget, paths ...string) error {
+	if dryRun || len(cfg.Hooks.PreOpen) == 0 {
+		return nil
+	}
+	for _, p := range paths {
+		for _, h := range cfg.Hooks.PreOpen {
+			stderr, err := runHook(cfg, t, p, h)
+			if err == nil {
+				continue
+			}
+			if msg := strings.TrimSpace(string(stderr)); len(msg) > 0 {
+				err = fmt.Errorf("%w: %s", err, msg)
+			}
+			return fmt.Errorf("not opened: %w", err)
+		}
+	}
+	return nil
+}
+
+// postSaveHooks runs the post_save hooks on each of paths once the editor
+// is done with them.  A hook failing is only noted, as the edit itself went
+// through.  Nothing is run with --dry-run.
+func postSaveHooks(cfg Configuration, t editTarget, paths ...string) {
+	if dryRun || len(cfg.Hooks.PostSave) == 0 {
+		return
+	}
+	for _, p := range paths {
+		for _, h := range cfg.Hooks.PostSave {
+			if _, err := runHook(cfg, t, p, h); err != nil {
+				log.Printf(":::note::: %v", err)
+			}
+		}
+	}
+}
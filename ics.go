@@ -0,0 +1,254 @@
+package wm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icsEvent is the subset of a VEVENT that wm uses.
+type icsEvent struct {
+	UID      string
+	Summary  string
+	Location string
+	Start    time.Time
+	End      time.Time
+	AllDay   bool
+	RRule    map[string]string
+}
+
+// openICS opens an iCalendar file from a local path or an http(s) URL.
+func openICS(src string) (io.ReadCloser, error) {
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		resp, err := http.Get(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch calendar: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to fetch calendar: %s", resp.Status)
+		}
+		return resp.Body, nil
+	}
+	return os.Open(src)
+}
+
+// unfoldICS joins folded content lines, which continue with a leading space
+// or tab, into logical lines.
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// unescapeICS reverses the TEXT value escaping of RFC 5545.
+func unescapeICS(v string) string {
+	r := strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return r.Replace(v)
+}
+
+// parseICSTime parses a DATE or DATE-TIME value with its TZID parameter.
+func parseICSTime(params map[string]string, v string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(v) == 8 {
+		t, err := time.ParseInLocation("20060102", v, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err := time.Parse("20060102T150405Z", v)
+		return t.Local(), false, err
+	}
+	loc := time.Local
+	if tzid, ok := params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", v, loc)
+	return t.Local(), false, err
+}
+
+// parseICS reads the events from an iCalendar stream.
+func parseICS(r io.Reader) ([]icsEvent, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, err
+	}
+	var events []icsEvent
+	var cur *icsEvent
+	for _, line := range lines {
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		nameParams, value := line[:colon], line[colon+1:]
+		parts := strings.Split(nameParams, ";")
+		name := strings.ToUpper(parts[0])
+		params := map[string]string{}
+		for _, p := range parts[1:] {
+			if eq := strings.Index(p, "="); eq > 0 {
+				params[strings.ToUpper(p[:eq])] = strings.Trim(p[eq+1:], `"`)
+			}
+		}
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			cur = &icsEvent{}
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if cur != nil && !cur.Start.IsZero() {
+				if cur.End.IsZero() {
+					if cur.AllDay {
+						cur.End = cur.Start.AddDate(0, 0, 1)
+					} else {
+						cur.End = cur.Start
+					}
+				}
+				events = append(events, *cur)
+			}
+			cur = nil
+		case cur == nil:
+		case name == "UID":
+			cur.UID = value
+		case name == "SUMMARY":
+			cur.Summary = unescapeICS(value)
+		case name == "LOCATION":
+			cur.Location = unescapeICS(value)
+		case name == "DTSTART":
+			cur.Start, cur.AllDay, err = parseICSTime(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("bad DTSTART '%s': %w", value, err)
+			}
+		case name == "DTEND":
+			cur.End, _, err = parseICSTime(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("bad DTEND '%s': %w", value, err)
+			}
+		case name == "RRULE":
+			cur.RRule = map[string]string{}
+			for _, kv := range strings.Split(value, ";") {
+				if eq := strings.Index(kv, "="); eq > 0 {
+					cur.RRule[strings.ToUpper(kv[:eq])] = strings.ToUpper(kv[eq+1:])
+				}
+			}
+		}
+	}
+	return events, nil
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// occursOn reports whether the event has an occurrence starting on the given
+// day and returns that occurrence.  Only simple DAILY and WEEKLY rules with
+// INTERVAL, COUNT, UNTIL, and (for WEEKLY) BYDAY are expanded; any other
+// recurrence is treated as its first occurrence only.
+func (ev icsEvent) occursOn(day time.Time) (icsEvent, bool) {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	first := time.Date(ev.Start.Year(), ev.Start.Month(), ev.Start.Day(), 0, 0, 0, 0, time.Local)
+	at := func(d time.Time) icsEvent {
+		occ := ev
+		occ.Start = time.Date(d.Year(), d.Month(), d.Day(), ev.Start.Hour(), ev.Start.Minute(), ev.Start.Second(), 0, time.Local)
+		occ.End = occ.Start.Add(ev.End.Sub(ev.Start))
+		return occ
+	}
+	if day.Equal(first) {
+		return ev, true
+	}
+	freq := ev.RRule["FREQ"]
+	if day.Before(first) || (freq != "DAILY" && freq != "WEEKLY") {
+		return icsEvent{}, false
+	}
+	interval := 1
+	if n, err := strconv.Atoi(ev.RRule["INTERVAL"]); err == nil && n > 0 {
+		interval = n
+	}
+	count := -1
+	if n, err := strconv.Atoi(ev.RRule["COUNT"]); err == nil {
+		count = n
+	}
+	if until, ok := ev.RRule["UNTIL"]; ok {
+		u, _, err := parseICSTime(map[string]string{}, until)
+		if err == nil && day.After(time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.Local)) {
+			return icsEvent{}, false
+		}
+	}
+	var byDay []time.Weekday
+	for _, d := range strings.Split(ev.RRule["BYDAY"], ",") {
+		if wd, ok := icsWeekdays[strings.TrimLeft(d, "+-0123456789")]; ok {
+			byDay = append(byDay, wd)
+		}
+	}
+	if len(byDay) == 0 {
+		byDay = []time.Weekday{first.Weekday()}
+	}
+	weekStart := func(t time.Time) time.Time {
+		return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	}
+	matches := func(d time.Time) bool {
+		days := daysBetween(datePathFromTime(first), datePathFromTime(d))
+		if freq == "DAILY" {
+			return days%interval == 0
+		}
+		weeks := daysBetween(datePathFromTime(weekStart(first)), datePathFromTime(weekStart(d))) / 7
+		if weeks%interval != 0 {
+			return false
+		}
+		for _, wd := range byDay {
+			if d.Weekday() == wd {
+				return true
+			}
+		}
+		return false
+	}
+	if !matches(day) {
+		return icsEvent{}, false
+	}
+	if count >= 0 {
+		n := 1
+		for d := first.AddDate(0, 0, 1); d.Before(day); d = d.AddDate(0, 0, 1) {
+			if matches(d) {
+				n++
+			}
+		}
+		if n+1 > count {
+			return icsEvent{}, false
+		}
+	}
+	return at(day), true
+}
+
+// eventsOn returns the occurrences of events starting on day, ordered by start
+// time.
+func eventsOn(events []icsEvent, day time.Time, skipAllDay bool) []icsEvent {
+	var out []icsEvent
+	for _, ev := range events {
+		if skipAllDay && ev.AllDay {
+			continue
+		}
+		if occ, ok := ev.occursOn(day); ok {
+			out = append(out, occ)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].AllDay != out[j].AllDay {
+			return out[i].AllDay
+		}
+		return out[i].Start.Before(out[j].Start)
+	})
+	return out
+}
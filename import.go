@@ -0,0 +1,262 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultImportFilenameRegex finds the date in the names of dated notes such
+// as 2023-05-06.md, 20230506.txt, or 2023/05/06.md, relative to the
+// directory imported.
+const defaultImportFilenameRegex = `(?P<year>\d{4})[-_./]?(?P<month>\d{1,2})[-_./]?(?P<day>\d{1,2})`
+
+// Ways import can tell the date of a file, given as --date-from.
+const (
+	importDateFilename    = "filename"
+	importDateMtime       = "mtime"
+	importDateFrontmatter = "frontmatter"
+)
+
+// importFile is a file to import and the date of the entry it goes into.
+type importFile struct {
+	Path string
+	Date DatePath
+}
+
+// importFilenameRegex returns import_filename_regex compiled, after checking
+// that it names the year, month, and day groups.
+func importFilenameRegex(cfg Configuration) (*regexp.Regexp, error) {
+	pattern := cfg.ImportFilenameRegex
+	if len(pattern) == 0 {
+		pattern = defaultImportFilenameRegex
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("import_filename_regex: %w", err)
+	}
+	for _, group := range []string{"year", "month", "day"} {
+		if re.SubexpIndex(group) < 0 {
+			return nil, fmt.Errorf("import_filename_regex '%s' needs a (?P<%s>...) group", pattern, group)
+		}
+	}
+	return re, nil
+}
+
+// filenameDate reads the date out of rel, the path of a file relative to the
+// directory imported, with re.
+func filenameDate(re *regexp.Regexp, rel string) (DatePath, error) {
+	m := re.FindStringSubmatch(filepath.ToSlash(rel))
+	if m == nil {
+		return DatePath{}, errors.New("its name has no date")
+	}
+	year, _ := strconv.Atoi(m[re.SubexpIndex("year")])
+	month, _ := strconv.Atoi(m[re.SubexpIndex("month")])
+	day, _ := strconv.Atoi(m[re.SubexpIndex("day")])
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day {
+		return DatePath{}, fmt.Errorf("its name has %s, which isn't a date", m[0])
+	}
+	return datePathFromTime(t), nil
+}
+
+// frontMatterDate reads the date field of the front matter of data, as
+// 2006-01-02, a TOML date and time, or any date wm reads.
+func frontMatterDate(cfg Configuration, data []byte) (DatePath, error) {
+	fields, _, err := parseFrontMatter(data)
+	if err != nil {
+		return DatePath{}, err
+	}
+	value := strings.Trim(strings.TrimSpace(fields["date"]), `"'`)
+	if len(value) == 0 {
+		return DatePath{}, errors.New("it has no date in its front matter")
+	}
+	if len(value) >= 10 {
+		if t, err := time.ParseInLocation("2006-01-02", value[:10], time.Local); err == nil {
+			return datePathFromTime(t), nil
+		}
+	}
+	pd, err := parseDateString(cfg, value)
+	if err != nil {
+		return DatePath{}, fmt.Errorf("its front matter date '%s' can't be read: %w", value, err)
+	}
+	return *pd, nil
+}
+
+// importSources walks dir for the files to import, those whose name matches
+// one of patterns or every file without any, and dates each the way
+// dateFrom says.  Files that can't be dated are returned as problems rather
+// than stopping the walk.
+func importSources(cfg Configuration, dir, dateFrom string, patterns []string) ([]importFile, []string, error) {
+	var re *regexp.Regexp
+	switch dateFrom {
+	case "", importDateFilename:
+		var err error
+		if re, err = importFilenameRegex(cfg); err != nil {
+			return nil, nil, err
+		}
+	case importDateMtime, importDateFrontmatter:
+	default:
+		return nil, nil, fmt.Errorf("unknown --date-from '%s', expected filename, mtime, or frontmatter", dateFrom)
+	}
+	for _, p := range patterns {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, nil, fmt.Errorf("bad --pattern '%s': %w", p, err)
+		}
+	}
+	var files []importFile
+	var problems []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		if len(patterns) > 0 {
+			matched := false
+			for _, p := range patterns {
+				if ok, _ := filepath.Match(p, d.Name()); ok {
+					matched = true
+				}
+			}
+			if !matched {
+				return nil
+			}
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		var pd DatePath
+		switch dateFrom {
+		case importDateMtime:
+			var info fs.FileInfo
+			if info, err = d.Info(); err == nil {
+				pd = datePathFromTime(info.ModTime())
+			}
+		case importDateFrontmatter:
+			var data []byte
+			if data, err = os.ReadFile(path); err == nil {
+				pd, err = frontMatterDate(cfg, data)
+			}
+		default:
+			pd, err = filenameDate(re, rel)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			return nil
+		}
+		files = append(files, importFile{path, pd})
+		return nil
+	})
+	return files, problems, err
+}
+
+// runImport copies the notes under a directory into the entries of their
+// dates: a new entry is the generated header followed by the note, and a
+// note for a day that has an entry already is appended to it after an
+// "Imported from" line.  --dry-run lists where each note would go instead.
+// Notes whose date can't be told are listed at the end, and make import
+// fail once the others are in.
+func runImport(cfg Configuration, params Parameters) error {
+	src, err := filepath.Abs(params.Source)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(src); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s isn't a directory", src)
+	}
+	if root, err := filepath.Abs(cfg.Root); err == nil && (insideDir(root, src) || insideDir(src, root)) {
+		return fmt.Errorf("%s and the root %s overlap; import from a directory outside the root", src, root)
+	}
+	files, problems, err := importSources(cfg, src, params.DateFrom, params.PatternFlags)
+	if err != nil {
+		return err
+	}
+	imported, created := 0, 0
+	planned := map[string]bool{}
+	for _, f := range files {
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", f.Path, err))
+			continue
+		}
+		if isBinary(data) {
+			problems = append(problems, fmt.Sprintf("%s: it isn't text", f.Path))
+			continue
+		}
+		body := strings.TrimRight(string(data), "\r\n") + "\n"
+		path, err := entryPath(cfg, &f.Date)
+		if err != nil {
+			return err
+		}
+		if params.DryRun {
+			// a day is only new to the first of its files
+			_, statErr := os.Stat(path)
+			exists := statErr == nil || planned[path]
+			planned[path] = true
+			verb := "new entry"
+			if exists {
+				verb = "appended"
+			}
+			fmt.Printf("%s -> %s (%s)\n", displayPath(f.Path), displayPath(path), verb)
+			imported++
+			continue
+		}
+		_, isNew, err := ensureEntryAt(cfg, path, &f.Date, func(pd *DatePath) (string, error) {
+			return renderHeader(pd) + body, nil
+		})
+		if err != nil {
+			return err
+		}
+		if isNew {
+			created++
+		} else {
+			existing, err := readEntry(cfg, path)
+			if err != nil {
+				return err
+			}
+			var b strings.Builder
+			if len(existing) > 0 && existing[len(existing)-1] != '\n' {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "\n--- Imported from %s ---\n\n%s", f.Path, body)
+			if err := appendToEntry(cfg, path, b.String()); err != nil {
+				return err
+			}
+		}
+		imported++
+	}
+	if params.DryRun {
+		fmt.Printf("%d files would be imported\n", imported)
+	} else {
+		fmt.Printf("imported %d files, %d into new entries\n", imported, created)
+	}
+	if len(problems) > 0 {
+		fmt.Println("not imported:")
+		for _, p := range problems {
+			fmt.Println("  " + p)
+		}
+		return fmt.Errorf("%d files weren't imported", len(problems))
+	}
+	return nil
+}
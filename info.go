@@ -0,0 +1,134 @@
+package wm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// entryInfo is the metadata "wm info" reports for a day.  Its JSON form is
+// stable porcelain for scripts and shell prompts: fields are only ever added.
+type entryInfo struct {
+	Date      string     `json:"date"`
+	Path      string     `json:"path"`
+	Exists    bool       `json:"exists"`
+	Size      int64      `json:"size"`
+	Mtime     *time.Time `json:"mtime"`
+	Words     int        `json:"words"`
+	OpenTodos int        `json:"open_todos"`
+}
+
+var openTodoRe = regexp.MustCompile(`^\s*[-*+]\s*\[ \]`)
+
+// infoFor reads the metadata of the entry for pd, touching no other file.
+func infoFor(cfg Configuration, pd *DatePath) (entryInfo, error) {
+	path, err := entryPath(cfg, pd)
+	if err != nil {
+		return entryInfo{}, err
+	}
+	info := entryInfo{Date: pd.Iso(), Path: path}
+	data, err := readEntry(cfg, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return info, nil
+	}
+	if err != nil {
+		return entryInfo{}, err
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return entryInfo{}, err
+	}
+	mtime := st.ModTime()
+	info.Exists, info.Size, info.Mtime = true, st.Size(), &mtime
+	body := stripHeader(data)
+	info.Words = len(strings.Fields(string(body)))
+	for _, line := range strings.Split(string(body), "\n") {
+		if openTodoRe.MatchString(line) {
+			info.OpenTodos++
+		}
+	}
+	return info, nil
+}
+
+// entryExists reports whether the entry for pd exists, with a single stat of
+// its month directory when the month index has it, or of the entry.
+func entryExists(cfg Configuration, pd *DatePath) (bool, error) {
+	x := loadMonthIndex(cfg)
+	if has, ok, err := x.has(pd); ok && err == nil {
+		x.save()
+		return has, nil
+	}
+	path, err := entryPath(cfg, pd)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// infoRange resolves --range, which is a period such as last-week or 2024-03,
+// a date, or "<from>..<to>".
+func infoRange(cfg Configuration, in string) (dateRange, error) {
+	if r, err := resolvePeriod(in, dayNow()); err == nil {
+		return r, nil
+	}
+	from, to, err := parseDateRange(cfg, in)
+	if err != nil {
+		return dateRange{}, err
+	}
+	return dateRange{from, to}, nil
+}
+
+// runInfo prints the metadata of one day, or of every day in --range, as
+// "key: value" lines or, with --format json, one JSON object per line.
+func runInfo(cfg Configuration, params Parameters) error {
+	var days []DatePath
+	if len(params.Range) > 0 {
+		r, err := infoRange(cfg, params.Range)
+		if err != nil {
+			return err
+		}
+		for d := r.From.Time(); !d.After(r.To.Time()); d = d.AddDate(0, 0, 1) {
+			days = append(days, datePathFromTime(d))
+		}
+	} else {
+		pd, err := parseDateString(cfg, strings.Join(params.DateWords, " "))
+		if err != nil {
+			return err
+		}
+		days = append(days, *pd)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for i := range days {
+		info, err := infoFor(cfg, &days[i])
+		if err != nil {
+			return err
+		}
+		info.Path = displayPath(info.Path)
+		switch params.Format {
+		case "json":
+			if err := enc.Encode(info); err != nil {
+				return err
+			}
+		case "", "human":
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("date: %s\npath: %s\nexists: %t\n", info.Date, info.Path, info.Exists)
+			if info.Exists {
+				fmt.Printf("size: %d\nmtime: %s\nwords: %d\nopen todos: %d\n", info.Size, formatTime(cfg, *info.Mtime), info.Words, info.OpenTodos)
+			}
+		default:
+			return fmt.Errorf("unknown info format '%s', expected human or json", params.Format)
+		}
+	}
+	return nil
+}
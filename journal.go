@@ -0,0 +1,189 @@
+package wm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+)
+
+// Programmatic additions to entries go through an append journal so that a
+// crash or a full disk can't lose them or leave a torn last line.  The
+// pending addition is written to the journal and synced first, then appended
+// to the entry and synced, and only then is the journal cleared.  A journal
+// left behind is replayed the next time wm starts.
+const (
+	appendJournalFile    = "append.journal"
+	appendJournalVersion = 1
+)
+
+// pendingAppend is the journal's record of one addition.  Size and Sum
+// describe the entry as it was before the addition, so replay can tell
+// whether the addition was applied, torn, or overtaken by another edit.
+type pendingAppend struct {
+	Path string `json:"path"`
+	Size int    `json:"size"`
+	Sum  string `json:"sum"`
+	Text string `json:"text"`
+}
+
+func contentSum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// appendToEntry appends text to the entry at path through the journal,
+// creating the entry when it doesn't exist.  The entry is locked as
+// lock_entries says.  An encrypted entry can't be appended to in place and
+// is rewritten whole instead.
+func appendToEntry(cfg Configuration, path, text string) error {
+	unlock, err := lockForWrite(cfg, path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if cfg.Encrypt {
+		return appendEncrypted(cfg, path, text)
+	}
+	journal, err := statePath(appendJournalFile)
+	if err != nil {
+		return err
+	}
+	unlockJournal, err := lockState(journal)
+	if err != nil {
+		return err
+	}
+	defer unlockJournal()
+	if err := replayLocked(cfg, journal); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	p := pendingAppend{Path: path, Size: len(data), Sum: contentSum(data), Text: text}
+	record, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := writeStateAtomic(journal, appendJournalVersion, record); err != nil {
+		return fmt.Errorf("failed to journal addition to %s: %w", path, err)
+	}
+	if err := writeSynced(cfg, path, []byte(text)); err != nil {
+		return fmt.Errorf("failed to append to %s: %w", path, err)
+	}
+	return os.Remove(journal)
+}
+
+// appendEncrypted appends text to the entry at path by rewriting it
+// encrypted, which is atomic without the journal.
+func appendEncrypted(cfg Configuration, path, text string) error {
+	data, err := readEntry(cfg, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = sealEntry(cfg, path, []byte(text))
+		if err == nil {
+			err = os.WriteFile(path, data, fileMode(cfg))
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	_, err = rewriteEntry(cfg, path, append(data, text...), rewriteOptions{})
+	return err
+}
+
+// writeSynced appends data to the file at path and syncs it to disk,
+// creating it with file_mode.
+func writeSynced(cfg Configuration, path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, fileMode(cfg))
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// replayAppendJournal finishes an addition interrupted by a crash.  It runs
+// at startup and is quick when there is nothing to replay.
+func replayAppendJournal(cfg Configuration) error {
+	journal, err := statePath(appendJournalFile)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(journal); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	unlock, err := lockState(journal)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return replayLocked(cfg, journal)
+}
+
+// replayLocked applies the pending addition in the journal, if any, and
+// clears it.  The caller holds the journal's lock.  The entry is locked as
+// lock_entries says; while an editor elsewhere holds it, the journal is left
+// to be replayed later.
+//
+// A journal that fails its checksum was torn while being written, before the
+// entry was touched, so it is dropped.  Otherwise the entry is compared with
+// the recorded state: unchanged means the addition never landed and it is
+// applied now; the old content followed by part or all of the addition means
+// the append was torn or completed and only the rest is written.  Anything
+// else means the entry was edited since, and the addition is saved next to
+// the journal for the user instead of being forced in.
+func replayLocked(cfg Configuration, journal string) error {
+	record, err := readState(journal, appendJournalVersion)
+	if errors.Is(err, errStateCorrupt) {
+		log.Println(":::note::: dropped an incomplete append journal; the addition never reached its entry")
+		return os.Remove(journal)
+	}
+	if err != nil || record == nil {
+		return err
+	}
+	var p pendingAppend
+	if err := json.Unmarshal(record, &p); err != nil {
+		log.Println(":::note::: dropped an unreadable append journal")
+		return os.Remove(journal)
+	}
+	unlockEntry, err := lockForWrite(cfg, p.Path)
+	if err != nil {
+		return err
+	}
+	defer unlockEntry()
+	data, err := os.ReadFile(p.Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(data) >= p.Size && contentSum(data[:p.Size]) == p.Sum {
+		written := data[p.Size:]
+		if bytes.HasPrefix([]byte(p.Text), written) {
+			if rest := []byte(p.Text)[len(written):]; len(rest) > 0 {
+				if err := writeSynced(cfg, p.Path, rest); err != nil {
+					return fmt.Errorf("failed to replay addition to %s: %w", p.Path, err)
+				}
+				log.Println(":::note::: finished an interrupted addition to", p.Path)
+			}
+			return os.Remove(journal)
+		}
+	}
+	saved := journal + ".conflict-" + now().Format("20060102T150405")
+	if err := os.WriteFile(saved, []byte(p.Text), 0o600); err != nil {
+		return err
+	}
+	log.Printf(":::note::: %s changed after an interrupted addition; the addition was saved to %s", p.Path, saved)
+	return os.Remove(journal)
+}
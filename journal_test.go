@@ -0,0 +1,70 @@
+package wm
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestAppendToEntryFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no permission bits")
+	}
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	root := t.TempDir()
+	cfg := Configuration{Root: root, FileMode: 0o600}
+	path := filepath.Join(root, "7.txt")
+	if err := appendToEntry(cfg, path, "- [ ] call back\n"); err != nil {
+		t.Fatal(err)
+	}
+	assertEntry(t, path, "- [ ] call back\n")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want file_mode's 0600", info.Mode().Perm())
+	}
+}
+
+func TestAppendToEntryLocked(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	root := t.TempDir()
+	cfg := Configuration{Root: root, LockEntries: true}
+	path := filepath.Join(root, "7.txt")
+	if err := os.WriteFile(path, []byte("open elsewhere\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// an editor on another machine has it open
+	other := entryLock{"elsewhere", 4242, now().Truncate(time.Second)}
+	if err := os.WriteFile(entryLockPath(path), []byte(other.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendToEntry(cfg, path, "added\n"); !errors.Is(err, errEntryLocked) {
+		t.Fatalf("appending to an entry locked elsewhere = %v, want errEntryLocked", err)
+	}
+	assertEntry(t, path, "open elsewhere\n")
+	if err := os.Remove(entryLockPath(path)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the editor this process runs has it open, as when open adds a
+	// session marker
+	unlock, err := lockEntry(path, defaultLockTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := appendToEntry(cfg, path, "added\n"); err != nil {
+		t.Fatalf("appending to an entry this process holds: %v", err)
+	}
+	unlock()
+	assertEntry(t, path, "open elsewhere\nadded\n")
+	if _, err := os.Stat(entryLockPath(path)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the lock is left after the edit: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package wm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// findSection returns the 1-based line of the first heading matching
+// section.  "## Next" must match the line exactly, ignoring case and
+// surrounding space; "Next" matches a heading of any level with that text.
+func findSection(data []byte, section string) int {
+	want := strings.ToLower(strings.TrimSpace(section))
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == want {
+			return i + 1
+		}
+		if !strings.HasPrefix(want, "#") && strings.HasPrefix(line, "#") && strings.TrimSpace(strings.TrimLeft(line, "#")) == want {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// findTagLine returns the 1-based line of the first line carrying tag.
+func findTagLine(data []byte, tag string) int {
+	for i, line := range strings.Split(string(data), "\n") {
+		if hasTag([]byte(line), tag) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// jumpLine finds the line --at or --at-tag asks for in the entry at path,
+// appending the section first when it is missing and --ensure-template is
+// set.  It returns 0 when there is nowhere to jump to.
+func jumpLine(cfg Configuration, path string, params Parameters) (int, error) {
+	data, err := readEntry(cfg, path)
+	if err != nil {
+		return 0, err
+	}
+	if len(params.AtTag) > 0 {
+		return findTagLine(data, params.AtTag), nil
+	}
+	if line := findSection(data, params.At); line > 0 || !params.EnsureTemplate {
+		return line, nil
+	}
+	heading := strings.TrimSpace(params.At)
+	if !strings.HasPrefix(heading, "#") {
+		heading = "## " + heading
+	}
+	var b bytes.Buffer
+	b.Write(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		b.WriteString("\n")
+	}
+	if len(data) > 0 && !bytes.HasSuffix(b.Bytes(), []byte("\n\n")) {
+		b.WriteString("\n")
+	}
+	b.WriteString(heading + "\n")
+	err = appendToEntry(cfg, path, string(b.Bytes()[len(data):]))
+	if err != nil {
+		return 0, fmt.Errorf("failed to add section '%s': %w", heading, err)
+	}
+	return findSection(b.Bytes(), heading), nil
+}
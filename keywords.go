@@ -0,0 +1,433 @@
+package wm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateResolver turns "now" into the date a keyword refers to.
+type dateResolver func(now time.Time) (time.Time, error)
+
+// dateKeyword is a named entry in the keyword registry.
+type dateKeyword struct {
+	Name        string
+	Description string
+	Resolve     dateResolver
+	Custom      bool
+}
+
+var dateKeywords = map[string]dateKeyword{}
+
+// registerDateKeyword adds a keyword to the registry consulted by
+// parseDateString.
+func registerDateKeyword(kw dateKeyword) {
+	dateKeywords[kw.Name] = kw
+}
+
+func init() {
+	registerDateKeyword(dateKeyword{
+		Name:        "today",
+		Description: "the current date",
+		Resolve: func(now time.Time) (time.Time, error) {
+			return now, nil
+		},
+	})
+	registerDateKeyword(dateKeyword{
+		Name:        "yesterday",
+		Description: "the day before today",
+		Resolve: func(now time.Time) (time.Time, error) {
+			return now.AddDate(0, 0, -1), nil
+		},
+	})
+	registerDateKeyword(dateKeyword{
+		Name:        "tomorrow",
+		Description: "the day after today",
+		Resolve: func(now time.Time) (time.Time, error) {
+			return now.AddDate(0, 0, 1), nil
+		},
+	})
+	registerDateKeyword(dateKeyword{
+		Name:        "sow",
+		Description: "the Monday starting the current week",
+		Resolve: func(now time.Time) (time.Time, error) {
+			return now.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7)), nil
+		},
+	})
+	registerDateKeyword(dateKeyword{
+		Name:        "eow",
+		Description: "the Sunday ending the current week",
+		Resolve: func(now time.Time) (time.Time, error) {
+			return now.AddDate(0, 0, (7-int(now.Weekday()))%7), nil
+		},
+	})
+	registerDateKeyword(dateKeyword{
+		Name:        "som",
+		Description: "the first day of the current month",
+		Resolve: func(now time.Time) (time.Time, error) {
+			return now.AddDate(0, 0, 1-now.Day()), nil
+		},
+	})
+	registerDateKeyword(dateKeyword{
+		Name:        "eom",
+		Description: "the last day of the current month",
+		Resolve: func(now time.Time) (time.Time, error) {
+			return time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, now.Location()), nil
+		},
+	})
+	registerDateKeyword(dateKeyword{
+		Name:        "lastworkday",
+		Description: "the most recent weekday before today that isn't a holiday",
+		Resolve: func(now time.Time) (time.Time, error) {
+			d := now.AddDate(0, 0, -1)
+			for isWeekend(d) || isHoliday(d) {
+				d = d.AddDate(0, 0, -1)
+			}
+			return d, nil
+		},
+	})
+}
+
+// datePhrase is a spoken form of a date that a single keyword can't express,
+// such as "the 7th" or "next month on the 3rd".  Resolve receives the
+// submatches of Re.
+type datePhrase struct {
+	Example     string
+	Description string
+	Re          *regexp.Regexp
+	Resolve     func(m []string, now time.Time) (time.Time, error)
+}
+
+var datePhrases = []datePhrase{
+	{
+		Example:     "-3",
+		Description: "three days before today; \"+2\" is two days after it",
+		Re:          regexp.MustCompile(`^([+-])(\d+)$`),
+		Resolve: func(m []string, now time.Time) (time.Time, error) {
+			n, err := strconv.Atoi(m[2])
+			if err != nil {
+				return time.Time{}, err
+			}
+			if m[1] == "-" {
+				n = -n
+			}
+			return now.AddDate(0, 0, n), nil
+		},
+	},
+	{
+		Example:     "friday",
+		Description: "the most recent Friday, today if it is one; also \"fri\"",
+		Re:          regexp.MustCompile(`^([a-z]+)$`),
+		Resolve: func(m []string, now time.Time) (time.Time, error) {
+			return nearestWeekday(now, m[1], 0)
+		},
+	},
+	{
+		Example:     "last monday",
+		Description: "the Monday before today, a week ago on a Monday; \"next fri\" is the Friday after today",
+		Re:          regexp.MustCompile(`^(last|next)\s+([a-z]+)$`),
+		Resolve: func(m []string, now time.Time) (time.Time, error) {
+			return nearestWeekday(now, m[2], map[string]int{"last": -1, "next": 1}[m[1]])
+		},
+	},
+	{
+		Example:     "the 7th",
+		Description: "the 7th of the current month",
+		Re:          regexp.MustCompile(`^(?:the\s+)?(\d{1,2})(?:st|nd|rd|th)$`),
+		Resolve: func(m []string, now time.Time) (time.Time, error) {
+			day, _ := strconv.Atoi(m[1])
+			return dayOfMonth(now, 0, day)
+		},
+	},
+	{
+		Example:     "next month 3",
+		Description: "the 3rd of the next month; also \"next month on the 3rd\" and \"last month 28\"",
+		Re:          regexp.MustCompile(`^(next|last|this)\s+month(?:\s+on)?(?:\s+the)?\s+(\d{1,2})(?:st|nd|rd|th)?$`),
+		Resolve: func(m []string, now time.Time) (time.Time, error) {
+			shift := map[string]int{"last": -1, "this": 0, "next": 1}[m[1]]
+			day, _ := strconv.Atoi(m[2])
+			return dayOfMonth(now, shift, day)
+		},
+	},
+	{
+		Example:     "2 weeks ago",
+		Description: "two weeks before today; also days, months, and years, and \"a month ago\"",
+		Re:          regexp.MustCompile(`^(\d+|a|an|one)\s+([a-z]+)\s+ago$`),
+		Resolve: func(m []string, now time.Time) (time.Time, error) {
+			return shiftByUnits(now, m[1], m[2], -1)
+		},
+	},
+	{
+		Example:     "in 3 days",
+		Description: "three days after today; also weeks, months, and years, and \"in a week\"",
+		Re:          regexp.MustCompile(`^in\s+(\d+|a|an|one)\s+([a-z]+)$`),
+		Resolve: func(m []string, now time.Time) (time.Time, error) {
+			return shiftByUnits(now, m[1], m[2], 1)
+		},
+	},
+}
+
+// shiftByUnits returns now moved count units, such as "3" and "weeks", in
+// direction, -1 into the past and 1 into the future.  Months and years keep
+// the day of the month, normalized as AddDate does: a month after January 31
+// is March 2 or 3.
+func shiftByUnits(now time.Time, count, unit string, direction int) (time.Time, error) {
+	n := 1
+	if c, err := strconv.Atoi(count); err == nil {
+		n = c
+	}
+	n *= direction
+	switch strings.TrimSuffix(unit, "s") {
+	case "day":
+		return now.AddDate(0, 0, n), nil
+	case "week":
+		return now.AddDate(0, 0, 7*n), nil
+	case "month":
+		return now.AddDate(0, n, 0), nil
+	case "year":
+		return now.AddDate(n, 0, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown unit '%s': use days, weeks, months, or years", unit)
+}
+
+// weekdayWords maps the full and abbreviated English weekday names.
+var weekdayWords = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// errNotWeekday tells resolveKeyword that a word matched by a weekday phrase
+// isn't a weekday after all, so other keywords are tried.
+var errNotWeekday = fmt.Errorf("not a weekday")
+
+// nearestWeekday returns the day named by word nearest to now in direction:
+// 0 for the most recent one including today, -1 for the one strictly before
+// today, and 1 for the one strictly after.
+func nearestWeekday(now time.Time, word string, direction int) (time.Time, error) {
+	wd, ok := weekdayWords[word]
+	if !ok {
+		return time.Time{}, errNotWeekday
+	}
+	back := (int(now.Weekday()) - int(wd) + 7) % 7
+	switch direction {
+	case -1:
+		if back == 0 {
+			back = 7
+		}
+	case 1:
+		ahead := (int(wd) - int(now.Weekday()) + 7) % 7
+		if ahead == 0 {
+			ahead = 7
+		}
+		return now.AddDate(0, 0, ahead), nil
+	}
+	return now.AddDate(0, 0, -back), nil
+}
+
+// dayOfMonth returns the given day of the month shift months away from now's.
+// A day past the end of that month is an error rather than rolling over into
+// the next one.
+func dayOfMonth(now time.Time, shift, day int) (time.Time, error) {
+	month := time.Date(now.Year(), now.Month(), 1, 12, 0, 0, 0, now.Location()).AddDate(0, shift, 0)
+	last := month.AddDate(0, 1, -1).Day()
+	if day < 1 || day > last {
+		return time.Time{}, fmt.Errorf("%s %d has no %s", month.Month(), month.Year(), ordinal(day))
+	}
+	return month.AddDate(0, 0, day-1), nil
+}
+
+// ordinal renders n as "1st", "2nd", "11th", and so on.
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+var keywordExprRe = regexp.MustCompile(`^([a-z][a-z0-9_]*)\s*(?:([+-])\s*(\d+))?$`)
+
+// splitKeywordExpr splits an expression such as "eom-2" into its keyword and
+// day offset.  ok is false when in doesn't have that shape.
+func splitKeywordExpr(in string) (name string, offset int, ok bool) {
+	m := keywordExprRe.FindStringSubmatch(strings.ToLower(strings.TrimSpace(in)))
+	if m == nil {
+		return "", 0, false
+	}
+	if len(m[3]) > 0 {
+		offset, _ = strconv.Atoi(m[3])
+		if m[2] == "-" {
+			offset = -offset
+		}
+	}
+	return m[1], offset, true
+}
+
+// resolveKeyword resolves a date phrase or a keyword, optionally followed by
+// a day offset such as "eom-2" or "today+3".  ok is false when in doesn't name a registered
+// keyword, so the caller can go on to try the date layouts.
+func resolveKeyword(in string, now time.Time) (t time.Time, ok bool, err error) {
+	t, _, ok, err = matchKeyword(in, now)
+	return t, ok, err
+}
+
+// matchKeyword is resolveKeyword, also describing the phrase or keyword that
+// matched for --explain-date.
+func matchKeyword(in string, now time.Time) (t time.Time, rule string, ok bool, err error) {
+	phrase := strings.Join(strings.Fields(strings.ToLower(in)), " ")
+	for _, p := range datePhrases {
+		if m := p.Re.FindStringSubmatch(phrase); m != nil {
+			t, err = p.Resolve(m, now)
+			if err == errNotWeekday {
+				continue
+			}
+			return t, fmt.Sprintf("the phrase like '%s'", p.Example), true, err
+		}
+	}
+	name, offset, ok := splitKeywordExpr(in)
+	if !ok {
+		return time.Time{}, "", false, nil
+	}
+	kw, ok := dateKeywords[name]
+	if !ok {
+		return time.Time{}, "", false, nil
+	}
+	t, err = kw.Resolve(now)
+	if err != nil {
+		return time.Time{}, "", true, fmt.Errorf("keyword '%s': %w", name, err)
+	}
+	rule = fmt.Sprintf("the keyword '%s'", name)
+	if offset != 0 {
+		rule += fmt.Sprintf(" offset by %+d days", offset)
+	}
+	return t.AddDate(0, 0, offset), rule, true, nil
+}
+
+// registerCustomKeywords adds the user's [date_keywords] to the registry.
+// Each definition is a keyword plus an optional day offset and may refer to
+// other custom keywords; every definition must resolve and cycles are
+// rejected, so mistakes are reported when the configuration is loaded rather
+// than when the keyword is first used.
+func registerCustomKeywords(defs map[string]string) error {
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if _, _, ok := splitKeywordExpr(key); !ok || strings.ContainsAny(key, "+- ") {
+			return fmt.Errorf("'%s' is not a valid keyword name", name)
+		}
+		if kw, ok := dateKeywords[key]; ok && !kw.Custom {
+			return fmt.Errorf("'%s' would replace a built-in keyword", name)
+		}
+		if _, _, ok := splitKeywordExpr(defs[name]); !ok {
+			return fmt.Errorf("'%s = \"%s\"' must be a keyword with an optional offset, like \"eom-2\"", name, defs[name])
+		}
+	}
+
+	var resolve func(name string, seen []string) (time.Time, error)
+	resolve = func(name string, seen []string) (time.Time, error) {
+		for _, s := range seen {
+			if s == name {
+				return time.Time{}, fmt.Errorf("cycle: %s -> %s", strings.Join(seen, " -> "), name)
+			}
+		}
+		def, ok := defs[name]
+		if !ok {
+			kw, ok := dateKeywords[name]
+			if !ok || kw.Custom {
+				return time.Time{}, fmt.Errorf("unknown keyword '%s'", name)
+			}
+			return kw.Resolve(dayNow())
+		}
+		ref, offset, _ := splitKeywordExpr(def)
+		t, err := resolve(ref, append(seen, name))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t.AddDate(0, 0, offset), nil
+	}
+
+	lowered := map[string]string{}
+	for name, def := range defs {
+		lowered[strings.ToLower(name)] = def
+	}
+	defs = lowered
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if _, err := resolve(key, nil); err != nil {
+			return fmt.Errorf("'%s': %w", name, err)
+		}
+	}
+
+	for _, name := range names {
+		key := strings.ToLower(name)
+		def := defs[key]
+		ref, offset, _ := splitKeywordExpr(def)
+		registerDateKeyword(dateKeyword{
+			Name:        key,
+			Description: fmt.Sprintf("custom: %s", def),
+			Custom:      true,
+			Resolve: func(now time.Time) (time.Time, error) {
+				t, ok, err := resolveKeyword(ref, now)
+				if !ok {
+					return time.Time{}, fmt.Errorf("unknown keyword '%s'", ref)
+				}
+				if err != nil {
+					return time.Time{}, err
+				}
+				return t.AddDate(0, 0, offset), nil
+			},
+		})
+	}
+	return nil
+}
+
+// printDateHelp lists every date keyword and the accepted date layouts.
+func printDateHelp() {
+	names := make([]string, 0, len(dateKeywords))
+	for name := range dateKeywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println("Date keywords (any may be followed by a day offset, e.g. eom-2 or today+3):")
+	for _, name := range names {
+		fmt.Printf("  %-12s %s\n", name, dateKeywords[name].Description)
+	}
+	fmt.Println()
+	fmt.Println("Relative days and days of a month:")
+	for _, p := range datePhrases {
+		fmt.Printf("  %-14s %s\n", p.Example, p.Description)
+	}
+	fmt.Println()
+	fmt.Println("Date layouts, tried in order:")
+	for _, df := range dateFormats {
+		fmt.Printf("  %s\n", df)
+	}
+	fmt.Println()
+	var months []string
+	for m := 1; m <= 12; m++ {
+		months = append(months, monthNames[dateLocale][m-1])
+	}
+	fmt.Printf("Month names (%s): %s\n", dateLocale, strings.Join(months, ", "))
+	fmt.Println()
+	fmt.Println("Custom keywords are defined in the [date_keywords] table, e.g.")
+	fmt.Println(`  payday = "eom-2"`)
+}
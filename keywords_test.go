@@ -0,0 +1,131 @@
+package wm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelativePhrases(t *testing.T) {
+	tests := []struct {
+		today time.Time
+		in    string
+		want  string
+	}{
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "1 day ago", "2024-03-06"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "3 days ago", "2024-03-04"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "0 days ago", "2024-03-07"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "a week ago", "2024-02-29"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "2 weeks ago", "2024-02-22"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "one month ago", "2024-02-07"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "an year ago", "2023-03-07"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "10 years ago", "2014-03-07"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "  2   weeks\tago ", "2024-02-22"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "2 Weeks AGO", "2024-02-22"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "in 1 day", "2024-03-08"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "in a week", "2024-03-14"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "in  3  months", "2024-06-07"},
+		{time.Date(2024, 3, 7, 12, 0, 0, 0, time.Local), "in 2 years", "2026-03-07"},
+		// months and years keep the day of the month, as AddDate does
+		{time.Date(2024, 1, 31, 12, 0, 0, 0, time.Local), "in 1 month", "2024-03-02"},
+		{time.Date(2023, 1, 31, 12, 0, 0, 0, time.Local), "in a month", "2023-03-03"},
+		{time.Date(2024, 3, 31, 12, 0, 0, 0, time.Local), "1 month ago", "2024-03-02"},
+		{time.Date(2024, 5, 31, 12, 0, 0, 0, time.Local), "1 month ago", "2024-05-01"},
+		{time.Date(2024, 2, 29, 12, 0, 0, 0, time.Local), "1 year ago", "2023-03-01"},
+		{time.Date(2024, 2, 29, 12, 0, 0, 0, time.Local), "in 4 years", "2028-02-29"},
+		{time.Date(2024, 1, 3, 12, 0, 0, 0, time.Local), "1 week ago", "2023-12-27"},
+	}
+	t.Cleanup(func() { clock, invoked = realClock{}, time.Time{} })
+	for _, tt := range tests {
+		clock, invoked = fixedClock(tt.today), time.Time{}
+		pd, err := parseDateString(Configuration{}, tt.in)
+		if err != nil || pd.Iso() != tt.want {
+			t.Errorf("%q on %s = %v, %v, want %s", tt.in, tt.today.Format("2006-01-02"), pd, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"2 fortnights ago", "in 3 decades", "3 dayz ago"} {
+		_, err := parseDateString(Configuration{}, in)
+		if err == nil || !strings.Contains(err.Error(), "days, weeks, months, or years") {
+			t.Errorf("%q: %v, want the units named", in, err)
+		}
+	}
+}
+
+func TestRelativePhrasesInRanges(t *testing.T) {
+	testToday(t, 2024, time.March, 7)
+	r, err := searchRange(Configuration{}, Parameters{Since: "3 months ago", To: "in 2 weeks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.From == nil || r.From.Iso() != "2023-12-07" || r.To == nil || r.To.Iso() != "2024-03-21" {
+		t.Errorf("--since \"3 months ago\" --to \"in 2 weeks\" = %v..%v, want 2023-12-07..2024-03-21", r.From, r.To)
+	}
+	r, err = searchRange(Configuration{}, Parameters{From: "1 week ago"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.From.Iso() != "2024-02-29" || r.To.Iso() != "2024-03-07" {
+		t.Errorf("--from \"1 week ago\" = %v..%v, want 2024-02-29..2024-03-07", r.From, r.To)
+	}
+}
+
+func TestDayOfMonthPhrases(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 12, 0, 0, 0, time.Local) }
+	tests := []struct {
+		today time.Time
+		in    string
+		want  string
+	}{
+		{day(time.March, 20), "the 7th", "2024-03-07"},
+		{day(time.March, 1), "the 31st", "2024-03-31"},
+		{day(time.March, 20), "1st", "2024-03-01"},
+		{day(time.March, 20), "The 22nd", "2024-03-22"},
+		{day(time.February, 10), "the 29th", "2024-02-29"},
+		{day(time.March, 20), "next month 3", "2024-04-03"},
+		{day(time.March, 20), "next month on the 3rd", "2024-04-03"},
+		{day(time.March, 20), "last month 28", "2024-02-28"},
+		{day(time.March, 31), "last month the 29th", "2024-02-29"},
+		{day(time.March, 20), "this month 5", "2024-03-05"},
+		// across the turn of the year
+		{day(time.December, 15), "next month 31", "2025-01-31"},
+		{day(time.January, 15), "last month on the 31st", "2023-12-31"},
+		{day(time.January, 31), "next month 29", "2024-02-29"},
+	}
+	t.Cleanup(func() { clock, invoked = realClock{}, time.Time{} })
+	for _, tt := range tests {
+		clock, invoked = fixedClock(tt.today), time.Time{}
+		pd, err := parseDateString(Configuration{}, tt.in)
+		if err != nil || pd.Iso() != tt.want {
+			t.Errorf("%q on %s = %v, %v, want %s", tt.in, tt.today.Format("2006-01-02"), pd, err, tt.want)
+		}
+	}
+
+	// a day the month doesn't have is an error, never the next month's
+	for _, tt := range []struct {
+		today time.Time
+		in    string
+		month string
+	}{
+		{day(time.April, 10), "the 31st", "April 2024"},
+		{day(time.February, 10), "the 30th", "February 2024"},
+		{day(time.January, 31), "next month 30", "February 2024"},
+		{day(time.March, 31), "last month 30", "February 2024"},
+		{day(time.December, 1), "next month 0", "January 2025"},
+		{time.Date(2023, time.January, 31, 12, 0, 0, 0, time.Local), "next month 29", "February 2023"},
+	} {
+		clock, invoked = fixedClock(tt.today), time.Time{}
+		pd, err := parseDateString(Configuration{}, tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.month+" has no") {
+			t.Errorf("%q on %s = %v, %v, want an error that %s has no such day", tt.in, tt.today.Format("2006-01-02"), pd, err, tt.month)
+		}
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	for n, want := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 22: "22nd", 23: "23rd", 31: "31st"} {
+		if got := ordinal(n); got != want {
+			t.Errorf("ordinal(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
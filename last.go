@@ -0,0 +1,54 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// lastDateArgs keeps "wm last friday" opening last Friday: "last" followed
+// by anything but a count is joined into one date argument, which the
+// "last" command would otherwise take.
+func lastDateArgs(args []string) []string {
+	if len(args) < 2 || args[0] != "last" || len(args[1]) == 0 || args[1][0] == '-' {
+		return args
+	}
+	if _, err := strconv.Atoi(args[1]); err == nil {
+		return args
+	}
+	return append([]string{"last " + args[1]}, args[2:]...)
+}
+
+// runLast opens the n-th most recent existing entry, 1 by default, without
+// creating anything.  Entries dated after today don't count unless
+// --include-future is given.
+func runLast(cfg Configuration, params Parameters) (openOutcome, error) {
+	n := 1
+	if len(params.Count) > 0 {
+		var err error
+		n, err = strconv.Atoi(params.Count)
+		if err != nil || n < 1 {
+			return openOutcome{}, fmt.Errorf("'%s' is not a positive number", params.Count)
+		}
+	}
+	all, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return openOutcome{}, err
+	}
+	var entries []Entry
+	for _, e := range historyEntries(all, params.IncludeFuture) {
+		if len(e.Topic) == 0 {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return openOutcome{}, withExitCode(exitNoEntry, errors.New("there are no entries yet; \"wm today\" starts one"))
+	}
+	if n > len(entries) {
+		return openOutcome{}, withExitCode(exitNoEntry, fmt.Errorf("there are only %d entries", len(entries)))
+	}
+	e := entries[len(entries)-n]
+	params.DateWords = []string{e.Date.Iso()}
+	params.NoCreate = true
+	return runOpen(cfg, params)
+}
@@ -0,0 +1,286 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// pathLayout says where the entry for each date lives under the root.  Layout
+// is a Go time layout using '/' for directories, Ext the file extension.
+type pathLayout struct {
+	Layout string
+	Ext    string
+}
+
+// nestedLayout is the original root/YYYY/M/D.txt layout.  It is the only one
+// whose month directories may also be named, as in 2024/03-März/07.txt.
+var nestedLayout = pathLayout{Layout: "2006/1/2", Ext: ".txt"}
+
+// parseLayout reads a path_layout value: "nested" (the default), "flat" for
+// root/2006-01-02.txt, or a Go time layout optionally ending in an extension,
+// such as "2006-01-02.md".  The layout must encode the year, month, and day so
+// that every path maps back to exactly one date.
+func parseLayout(s string) (pathLayout, error) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "", "nested":
+		return nestedLayout, nil
+	case "flat":
+		return pathLayout{Layout: "2006-01-02", Ext: ".txt"}, nil
+	}
+	l := pathLayout{Layout: s, Ext: ".txt"}
+	if ext := layoutExt(s); len(ext) > 0 {
+		l = pathLayout{Layout: strings.TrimSuffix(s, ext), Ext: ext}
+	}
+	if path.IsAbs(l.Layout) || strings.Contains("/"+l.Layout+"/", "/../") {
+		return pathLayout{}, fmt.Errorf("path_layout '%s' must stay inside the root", s)
+	}
+	for _, t := range []time.Time{
+		time.Date(2019, 11, 23, 0, 0, 0, 0, time.Local),
+		time.Date(2024, 3, 7, 0, 0, 0, 0, time.Local),
+	} {
+		dp := datePathFromTime(t)
+		got, err := l.parse(l.render(&dp), l.Ext)
+		if err != nil || *got != dp {
+			return pathLayout{}, fmt.Errorf("path_layout '%s' must contain the year, month, and day", s)
+		}
+	}
+	return l, nil
+}
+
+// layoutExt returns the extension a path_layout value ends in, or "" when
+// it names none.
+func layoutExt(s string) string {
+	s = strings.TrimSpace(s)
+	if ext := path.Ext(s); strings.IndexFunc(ext, unicode.IsLetter) >= 0 {
+		return ext
+	}
+	return ""
+}
+
+// parseExtension reads an extension setting, "md" or ".md", for setting.
+func parseExtension(setting, s string) (string, error) {
+	ext := strings.TrimPrefix(strings.TrimSpace(s), ".")
+	valid := len(ext) > 0
+	for _, r := range ext {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			valid = false
+		}
+	}
+	if !valid {
+		return "", fmt.Errorf("%s '%s' must be letters and digits, such as md", setting, s)
+	}
+	return "." + ext, nil
+}
+
+// configuredLayout returns the layout of c's entry paths, path_layout with
+// the extension of extension, and the legacy_extensions also read.  An
+// extension given in path_layout as well must be the same one.
+func configuredLayout(c Configuration) (pathLayout, []string, error) {
+	s := layoutSetting(c)
+	l, err := parseLayout(s)
+	if err != nil {
+		return pathLayout{}, nil, err
+	}
+	if len(c.Extension) > 0 {
+		ext, err := parseExtension("extension", c.Extension)
+		if err != nil {
+			return pathLayout{}, nil, err
+		}
+		if given := layoutExt(s); len(given) > 0 && given != ext {
+			return pathLayout{}, nil, fmt.Errorf("path_layout '%s' ends in %s, but extension is '%s'", s, given, c.Extension)
+		}
+		l.Ext = ext
+	}
+	legacy := []string{".txt"}
+	if c.LegacyExtensions != nil {
+		legacy = nil
+		for _, e := range c.LegacyExtensions {
+			ext, err := parseExtension("legacy_extensions", e)
+			if err != nil {
+				return pathLayout{}, nil, err
+			}
+			legacy = append(legacy, ext)
+		}
+	}
+	return l, legacy, nil
+}
+
+// entryLayout returns the layout of c's entry paths.  The configuration was
+// checked when it was read, so a layout that doesn't parse, as only one
+// built by hand can have, is the nested one.
+func entryLayout(c Configuration) pathLayout {
+	l, _, err := configuredLayout(c)
+	if err != nil {
+		return nestedLayout
+	}
+	return l
+}
+
+// entryExts returns every extension an entry of c may have, the configured
+// one first and then those of legacy_extensions, which entries written
+// before extension was changed may have.  Entries of these are read like the
+// others, but new ones are never created with them.
+func entryExts(c Configuration) []string {
+	l, legacy, err := configuredLayout(c)
+	if err != nil {
+		l, legacy = nestedLayout, []string{".txt"}
+	}
+	exts := []string{l.Ext}
+	for _, e := range legacy {
+		if !containsString(exts, e) {
+			exts = append(exts, e)
+		}
+	}
+	return exts
+}
+
+// entryExt returns the extension of name if it is one an entry of c may
+// have, or "" when it isn't.
+func entryExt(c Configuration, name string) string {
+	return matchExt(entryExts(c), name)
+}
+
+// matchExt returns the one of exts name ends in, or "" when it ends in none.
+func matchExt(exts []string, name string) string {
+	for _, e := range exts {
+		if strings.HasSuffix(name, e) {
+			return e
+		}
+	}
+	return ""
+}
+
+// layoutSetting returns the configured layout, given as path_layout or
+// path_format, or "" when neither is set.
+func layoutSetting(c Configuration) string {
+	if len(c.PathLayout) > 0 {
+		return c.PathLayout
+	}
+	return c.PathFormat
+}
+
+// nested reports whether l is the root/YYYY/M/D layout, whatever its
+// extension.
+func (l pathLayout) nested() bool {
+	return l.Layout == nestedLayout.Layout
+}
+
+// depth is the number of directories between the root and an entry.
+func (l pathLayout) depth() int {
+	return strings.Count(l.Layout, "/")
+}
+
+// render returns the path of the entry for dp relative to the root, using '/'
+// separators.
+func (l pathLayout) render(dp *DatePath) string {
+	return dp.Time().Format(l.Layout) + l.Ext
+}
+
+// layoutPattern spells the fields of a Go time layout the way people write
+// them, longest first so that "2006" isn't read as a day.
+var layoutPattern = strings.NewReplacer("January", "<month>", "Jan", "<mon>", "2006", "YYYY", "01", "MM", "02", "DD", "_2", "D", "1", "M", "2", "D")
+
+// pattern is the path of the entries of l relative to the root, as
+// "YYYY/M/D.txt", for messages.
+func (l pathLayout) pattern() string {
+	return filepath.FromSlash(layoutPattern.Replace(l.Layout)) + l.Ext
+}
+
+// parse recovers the date from a path relative to the root ending in ext,
+// l.Ext or one of the legacy extensions.
+func (l pathLayout) parse(rel, ext string) (*DatePath, error) {
+	rel = filepath.ToSlash(rel)
+	if l.nested() {
+		return parseEntryPath(rel)
+	}
+	if !strings.HasSuffix(rel, ext) {
+		return nil, fmt.Errorf("'%s' is not an entry path", rel)
+	}
+	t, err := time.ParseInLocation(l.Layout, strings.TrimSuffix(rel, ext), time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not an entry path", rel)
+	}
+	dp := datePathFromTime(t)
+	return &dp, nil
+}
+
+// runMigrate moves every entry from the configured layout to the one given
+// with --layout, removing directories left empty.  Entries keep their
+// extension unless --layout names one.  Entries whose destination already
+// exists are left where they are.
+func runMigrate(cfg Configuration, params Parameters) error {
+	target, err := parseLayout(params.Layout)
+	if err != nil {
+		return err
+	}
+	keepExt := len(layoutExt(params.Layout)) == 0
+	if keepExt {
+		target.Ext = entryLayout(cfg).Ext
+	}
+	if target == entryLayout(cfg) {
+		return errors.New("entries already use this layout")
+	}
+	entries, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	moved, skipped := 0, 0
+	for _, e := range entries {
+		to := target
+		if ext := entryExt(cfg, e.Path); keepExt && len(ext) > 0 {
+			to.Ext = ext
+		}
+		dest := filepath.Join(cfg.Root, filepath.FromSlash(withTopic(to.render(&e.Date), to.Ext, e.Topic)))
+		if _, err := os.Stat(dest); err == nil {
+			fmt.Printf("skipped %s: %s already exists\n", e.Path, dest)
+			skipped++
+			continue
+		}
+		if params.DryRun {
+			fmt.Printf("would move %s to %s\n", e.Path, dest)
+			moved++
+			continue
+		}
+		err = makeDir(cfg, filepath.Dir(dest))
+		if err != nil {
+			return err
+		}
+		err = os.Rename(e.Path, dest)
+		if err != nil {
+			return err
+		}
+		if err := resealMoved(cfg, dest); err != nil {
+			return fmt.Errorf("failed to encrypt %s at its new path: %w", dest, err)
+		}
+		removeEmptyDirs(cfg.Root, filepath.Dir(e.Path))
+		moved++
+	}
+	if params.DryRun {
+		fmt.Printf("%d entries would be moved, %d skipped\n", moved, skipped)
+		return nil
+	}
+	fmt.Printf("moved %d entries, %d skipped\n", moved, skipped)
+	fmt.Printf("set path_layout = %q in the configuration file to use the new layout\n", params.Layout)
+	if skipped > 0 {
+		return fmt.Errorf("%d entries could not be moved", skipped)
+	}
+	return nil
+}
+
+// removeEmptyDirs removes dir and its parents up to, but not including, root
+// for as long as they are empty.
+func removeEmptyDirs(root string, dir string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
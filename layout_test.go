@@ -0,0 +1,120 @@
+package wm
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// layoutFixture is the archive the layout suite runs against, across a year
+// and a month boundary.
+var layoutFixture = []struct {
+	pd   DatePath
+	body string
+}{
+	{DatePath{2023, 12, 31}, "year end, nothing on the parser\n"},
+	{DatePath{2024, 2, 28}, "parser bug in the date line\n"},
+	{DatePath{2024, 3, 7}, "met Ann about the parser\n"},
+}
+
+// layoutFiles lists the files under root relative to it, with '/'.
+func layoutFiles(t *testing.T, root string) []string {
+	t.Helper()
+	var files []string
+	for path := range treeOf(t, root) {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// writeLayout points cfgFile at root with path_layout set to layout.
+func writeLayout(t *testing.T, cfgFile, root, layout string) {
+	t.Helper()
+	content := "root = '" + filepath.ToSlash(root) + "'\neditor = 'true'\npath_layout = '" + layout + "'\n"
+	if err := os.WriteFile(cfgFile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLayouts runs the core commands against the same archive in the nested
+// and the flat layout, then migrates it to the other layout and back.
+func TestLayouts(t *testing.T) {
+	layouts := []struct {
+		name  string
+		files []string
+	}{
+		{"nested", []string{"2023/12/31.txt", "2024/2/28.txt", "2024/3/7.txt", "2024/3/8.txt"}},
+		{"flat", []string{"2023-12-31.txt", "2024-02-28.txt", "2024-03-07.txt", "2024-03-08.txt"}},
+	}
+	for i, l := range layouts {
+		other := layouts[1-i]
+		t.Run(l.name, func(t *testing.T) {
+			root := t.TempDir()
+			cfgFile, env := testHome(t, root)
+			env = append(env, "WMCFG="+cfgFile, "WM_NOW=2024-03-10T09:00")
+			writeLayout(t, cfgFile, root, l.name)
+			cfg := Configuration{Root: root, PathLayout: l.name}
+			for _, e := range layoutFixture {
+				testEntryBody(t, cfg, e.pd, e.body)
+			}
+			wm := func(args ...string) string {
+				t.Helper()
+				stdout, stderr, code := runWM(t, root, env, args...)
+				if code != exitOK {
+					t.Fatalf("wm %q exited %d: %s", args, code, stderr)
+				}
+				return string(stdout)
+			}
+
+			wm("append", "--date=2024-03-08", "parser fixed")
+			if got := layoutFiles(t, root); !reflect.DeepEqual(got, l.files) {
+				t.Fatalf("files after append = %q, want %q", got, l.files)
+			}
+			dates := "2024-03-08\n2024-03-07\n2024-02-28\n2023-12-31\n"
+			if got := wm("list", "--dates-only", "2023-01-01", "2024-12-31"); got != dates {
+				t.Errorf("list = %q, want %q", got, dates)
+			}
+			var found []string
+			for _, path := range strings.Fields(wm("search", "-l", "parser")) {
+				rel, _ := filepath.Rel(root, path)
+				found = append(found, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(found, l.files) {
+				t.Errorf("search -l parser = %q, want %q", found, l.files)
+			}
+			if got := wm("cat", "2024-03-07"); !strings.Contains(got, "met Ann") {
+				t.Errorf("cat 2024-03-07 = %q, want its entry", got)
+			}
+			if got := wm("month", "--cat", "2024-03"); !strings.Contains(got, "met Ann") || !strings.Contains(got, "parser fixed") || strings.Contains(got, "date line") {
+				t.Errorf("month --cat 2024-03 = %q, want both March entries only", got)
+			}
+			var stats struct{ Entries int }
+			if err := json.Unmarshal([]byte(wm("stats", "--json", "--year=2024")), &stats); err != nil || stats.Entries != 3 {
+				t.Errorf("stats --year=2024 = %+v, %v, want 3 entries", stats, err)
+			}
+
+			// to the other layout and back, leaving nothing behind
+			wm("migrate", "--layout="+other.name, "--force-root")
+			if got := layoutFiles(t, root); !reflect.DeepEqual(got, other.files) {
+				t.Fatalf("files after migrating to %s = %q, want %q", other.name, got, other.files)
+			}
+			writeLayout(t, cfgFile, root, other.name)
+			if got := wm("list", "--dates-only", "2023-01-01", "2024-12-31"); got != dates {
+				t.Errorf("list in %s = %q, want %q", other.name, got, dates)
+			}
+			wm("migrate", "--layout="+l.name, "--force-root")
+			if got := layoutFiles(t, root); !reflect.DeepEqual(got, l.files) {
+				t.Errorf("files after migrating back = %q, want %q", got, l.files)
+			}
+			assertEntry(t, filepath.Join(root, filepath.FromSlash(l.files[2])), renderHeader(&DatePath{2024, 3, 7})+"met Ann about the parser\n")
+		})
+	}
+}
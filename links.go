@@ -0,0 +1,233 @@
+package wm
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Link statuses.  Only relative targets are checked; a target that resolves
+// outside the root is never looked at, and is reported as such.
+const (
+	linkExternal = "external"
+	linkOK       = "ok"
+	linkBroken   = "broken"
+	linkOutside  = "outside-root"
+)
+
+// entryLink is a link or image reference found in a Markdown entry.
+type entryLink struct {
+	Date   string `json:"date"`
+	Topic  string `json:"topic,omitempty"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Kind   string `json:"kind"`
+	Text   string `json:"text"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+}
+
+var (
+	// inlineLinkRe matches [text](target) and ![alt](target), with an
+	// optional title after the target.
+	inlineLinkRe = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*<?([^)\s>]*)>?(?:\s+["'(][^)]*)?\s*\)`)
+	// refLinkRe matches [text][ref], [text][] and ![alt][ref].
+	refLinkRe = regexp.MustCompile(`(!?)\[([^\]]+)\]\[([^\]]*)\]`)
+	// linkDefRe matches a reference definition, [ref]: target "title".
+	linkDefRe  = regexp.MustCompile(`^\s{0,3}\[([^\]]+)\]:\s*<?(\S+?)>?(?:\s+.*)?$`)
+	codeSpanRe = regexp.MustCompile("`+[^`]*`+")
+)
+
+// rawLink is a link as written, before its target is checked.
+type rawLink struct {
+	Line   int
+	Image  bool
+	Text   string
+	Target string
+}
+
+// extractLinks returns the inline and reference-style links and images of a
+// Markdown document, leaving out code fences and code spans.  References
+// are resolved by their definitions, matched regardless of case; undefined
+// ones are left out since they read as plain text.
+func extractLinks(data []byte) []rawLink {
+	lines := strings.Split(string(data), "\n")
+	defs := map[string]string{}
+	inFence := false
+	code := make([]bool, len(lines))
+	for i, l := range lines {
+		if fenceRe.MatchString(l) {
+			inFence = !inFence
+			code[i] = true
+			continue
+		}
+		code[i] = inFence
+		if !inFence {
+			if m := linkDefRe.FindStringSubmatch(l); m != nil {
+				defs[strings.ToLower(m[1])] = m[2]
+			}
+		}
+	}
+	var links []rawLink
+	for i, l := range lines {
+		if code[i] || linkDefRe.MatchString(l) {
+			continue
+		}
+		l = codeSpanRe.ReplaceAllStringFunc(l, func(s string) string { return strings.Repeat(" ", len(s)) })
+		for _, m := range inlineLinkRe.FindAllStringSubmatch(l, -1) {
+			links = append(links, rawLink{Line: i + 1, Image: m[1] == "!", Text: m[2], Target: m[3]})
+		}
+		for _, m := range refLinkRe.FindAllStringSubmatch(l, -1) {
+			ref := m[3]
+			if len(ref) == 0 {
+				ref = m[2]
+			}
+			if target, ok := defs[strings.ToLower(ref)]; ok {
+				links = append(links, rawLink{Line: i + 1, Image: m[1] == "!", Text: m[2], Target: target})
+			}
+		}
+	}
+	return links
+}
+
+// resolveLink works out the status of target, linked from the entry at
+// path.  Relative targets are taken from the entry's directory, and ones
+// starting with "/" from the root.  A target that leaves the root, directly
+// or through a symbolic link, is reported as outside-root and not followed.
+func resolveLink(root, path, target string) string {
+	if len(target) == 0 || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "//") {
+		return linkExternal
+	}
+	if u, err := url.Parse(target); err != nil || len(u.Scheme) > 0 {
+		return linkExternal
+	}
+	if i := strings.IndexAny(target, "#?"); i >= 0 {
+		target = target[:i]
+	}
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	var p string
+	if strings.HasPrefix(target, "/") {
+		p = filepath.Join(root, filepath.FromSlash(target))
+	} else {
+		p = filepath.Join(filepath.Dir(path), filepath.FromSlash(target))
+	}
+	if !insideDir(root, p) {
+		return linkOutside
+	}
+	real, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return linkBroken
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err == nil && !insideDir(realRoot, real) {
+		return linkOutside
+	}
+	return linkOK
+}
+
+// insideDir reports whether p is dir or lies under it.
+func insideDir(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// collectLinks returns the links of the Markdown entries in the range.
+func collectLinks(cfg Configuration, params Parameters) ([]entryLink, error) {
+	r, err := resolveQuery(cfg, queryFor(params), dayNow())
+	if err != nil {
+		return nil, err
+	}
+	all, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return nil, err
+	}
+	links := []entryLink{}
+	for _, e := range filterEntries(all, r.From, r.To) {
+		if !isMarkdown(e.Path) {
+			continue
+		}
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", e.Path, err)
+		}
+		for _, l := range extractLinks(data) {
+			kind := "link"
+			if l.Image {
+				kind = "image"
+			}
+			links = append(links, entryLink{
+				Date:   e.Date.Iso(),
+				Topic:  e.Topic,
+				File:   e.Path,
+				Line:   l.Line,
+				Kind:   kind,
+				Text:   l.Text,
+				Target: l.Target,
+				Status: resolveLink(cfg.Root, e.Path, l.Target),
+			})
+		}
+	}
+	return links, nil
+}
+
+// runLinks lists the links and image references of Markdown entries with
+// whether their relative targets exist.
+func runLinks(cfg Configuration, params Parameters) error {
+	links, err := collectLinks(cfg, params)
+	if err != nil {
+		return err
+	}
+	switch params.Format {
+	case "", "human":
+		for _, l := range links {
+			fmt.Printf("%s:%d: %s [%s] %s (%s)\n", l.File, l.Line, l.Kind, l.Text, l.Target, l.Status)
+		}
+		if len(links) == 0 {
+			fmt.Println("no links in Markdown entries")
+		}
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"date", "topic", "file", "line", "kind", "text", "target", "status"})
+		for _, l := range links {
+			w.Write([]string{l.Date, l.Topic, l.File, fmt.Sprint(l.Line), l.Kind, l.Text, l.Target, l.Status})
+		}
+		w.Flush()
+		return w.Error()
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(links)
+	default:
+		return fmt.Errorf("unknown links format '%s', expected human, csv, or json", params.Format)
+	}
+	return nil
+}
+
+// checkLinks reports the relative links of Markdown entries that are broken
+// or point outside the root, and whether there were any.
+func checkLinks(cfg Configuration, params Parameters) (bool, error) {
+	links, err := collectLinks(cfg, params)
+	if err != nil {
+		return false, err
+	}
+	checked, bad := 0, 0
+	for _, l := range links {
+		switch l.Status {
+		case linkExternal:
+			continue
+		case linkBroken, linkOutside:
+			fmt.Printf("%s:%d: %s %s\n", l.File, l.Line, l.Status, l.Target)
+			bad++
+		}
+		checked++
+	}
+	fmt.Printf("%d relative links checked, %d broken or outside the root\n", checked, bad)
+	return bad > 0, nil
+}
@@ -0,0 +1,279 @@
+package wm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// LintConfig holds the settings for 'wm lint', read from the [lint] table.
+type LintConfig struct {
+	RequiredSections []string          `toml:"required_sections"`
+	HeadingLevel     int               `toml:"heading_level"`
+	MaxLineLength    int               `toml:"max_line_length"`
+	Severity         map[string]string `toml:"severity"`
+}
+
+const (
+	severityOff     = "off"
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+// lintFinding is a single problem reported by a lint rule.
+type lintFinding struct {
+	Line    int
+	Rule    string
+	Message string
+}
+
+// lintEntry is the input handed to every rule.
+type lintEntry struct {
+	Entry Entry
+	Data  []byte
+	Lines []string
+	Cfg   LintConfig
+}
+
+// lintRule checks an entry.  Rules with a fix function can repair a single
+// offending line in place with 'wm lint --fix'.
+type lintRule struct {
+	Name     string
+	Severity string
+	Check    func(le *lintEntry) []lintFinding
+	Fix      func(line string) string
+}
+
+var lintRules []lintRule
+
+// registerLintRule adds a rule to the set run by 'wm lint'.  Severity is the
+// default and may be overridden in the [lint.severity] table.
+func registerLintRule(r lintRule) {
+	lintRules = append(lintRules, r)
+}
+
+var (
+	headingRe  = regexp.MustCompile(`^(#+)\s`)
+	todoLikeRe = regexp.MustCompile(`^(\s*)[-*+]?\s*\[\s*([xX]?)\s*\]\s*(.*)$`)
+	todoRe     = regexp.MustCompile(`^\s*- \[[ x]\] \S`)
+)
+
+func init() {
+	registerLintRule(lintRule{
+		Name:     "required-sections",
+		Severity: severityError,
+		Check: func(le *lintEntry) []lintFinding {
+			var findings []lintFinding
+			for _, section := range le.Cfg.RequiredSections {
+				found := false
+				for _, line := range le.Lines {
+					if strings.TrimSpace(line) == section {
+						found = true
+						break
+					}
+				}
+				if !found {
+					findings = append(findings, lintFinding{Line: 1, Message: fmt.Sprintf("missing section '%s'", section)})
+				}
+			}
+			return findings
+		},
+	})
+	registerLintRule(lintRule{
+		Name:     "heading-level",
+		Severity: severityWarning,
+		Check: func(le *lintEntry) []lintFinding {
+			if le.Cfg.HeadingLevel <= 0 {
+				return nil
+			}
+			var findings []lintFinding
+			for i, line := range le.Lines {
+				m := headingRe.FindStringSubmatch(line)
+				if m != nil && len(m[1]) != le.Cfg.HeadingLevel {
+					findings = append(findings, lintFinding{Line: i + 1, Message: fmt.Sprintf("heading level %d, expected %d", len(m[1]), le.Cfg.HeadingLevel)})
+				}
+			}
+			return findings
+		},
+	})
+	registerLintRule(lintRule{
+		Name:     "todo-syntax",
+		Severity: severityError,
+		Check: func(le *lintEntry) []lintFinding {
+			var findings []lintFinding
+			for i, line := range le.Lines {
+				if todoLikeRe.MatchString(line) && !todoRe.MatchString(line) {
+					findings = append(findings, lintFinding{Line: i + 1, Message: "todo should be written as '- [ ] ' or '- [x] '"})
+				}
+			}
+			return findings
+		},
+		Fix: func(line string) string {
+			m := todoLikeRe.FindStringSubmatch(line)
+			if m == nil || todoRe.MatchString(line) {
+				return line
+			}
+			mark := " "
+			if len(m[2]) > 0 {
+				mark = "x"
+			}
+			return fmt.Sprintf("%s- [%s] %s", m[1], mark, m[3])
+		},
+	})
+	registerLintRule(lintRule{
+		Name:     "max-line-length",
+		Severity: severityWarning,
+		Check: func(le *lintEntry) []lintFinding {
+			if le.Cfg.MaxLineLength <= 0 {
+				return nil
+			}
+			var findings []lintFinding
+			for i, line := range le.Lines {
+				if n := utf8.RuneCountInString(line); n > le.Cfg.MaxLineLength {
+					findings = append(findings, lintFinding{Line: i + 1, Message: fmt.Sprintf("line is %d characters, limit is %d", n, le.Cfg.MaxLineLength)})
+				}
+			}
+			return findings
+		},
+	})
+	registerLintRule(lintRule{
+		Name:     "trailing-whitespace",
+		Severity: severityWarning,
+		Check: func(le *lintEntry) []lintFinding {
+			var findings []lintFinding
+			for i, line := range le.Lines {
+				if line != strings.TrimRight(line, " \t") {
+					findings = append(findings, lintFinding{Line: i + 1, Message: "trailing whitespace"})
+				}
+			}
+			return findings
+		},
+		Fix: func(line string) string {
+			return strings.TrimRight(line, " \t")
+		},
+	})
+	registerLintRule(lintRule{
+		Name:     "header-date",
+		Severity: severityError,
+		Check: func(le *lintEntry) []lintFinding {
+			h, ok := findHeader(le.Data)
+			if !ok {
+				return []lintFinding{{Line: 1, Message: "missing generated header"}}
+			}
+			if h.Date == nil {
+				return []lintFinding{{Line: 1, Message: "generated header has no date"}}
+			}
+			if *h.Date != le.Entry.Date {
+				return []lintFinding{{Line: h.DateLine, Message: fmt.Sprintf("header date %s does not match the file date %s", h.Date.Iso(), le.Entry.Date.Iso())}}
+			}
+			return nil
+		},
+	})
+}
+
+// severity returns the effective severity of a rule under cfg.
+func (r lintRule) severity(cfg LintConfig) string {
+	if s, ok := cfg.Severity[r.Name]; ok {
+		return strings.ToLower(s)
+	}
+	return r.Severity
+}
+
+// lintFile runs every enabled rule against the entry, applying fixes first
+// when fix is set.  It returns the findings as printable lines and whether
+// any of them were errors.
+func lintFile(e Entry, cfg Configuration, fix bool) ([]string, bool, error) {
+	data, err := readEntry(cfg, e.Path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", e.Path, err)
+	}
+	le := &lintEntry{Entry: e, Data: data, Lines: strings.Split(string(data), "\n"), Cfg: cfg.Lint}
+
+	if fix {
+		changed := false
+		for _, r := range lintRules {
+			if r.Fix == nil || r.severity(cfg.Lint) == severityOff {
+				continue
+			}
+			for i, line := range le.Lines {
+				if isSessionMarker(line) {
+					continue
+				}
+				if fixed := r.Fix(line); fixed != line {
+					le.Lines[i] = fixed
+					changed = true
+				}
+			}
+		}
+		if changed {
+			le.Data = []byte(strings.Join(le.Lines, "\n"))
+			_, err := rewriteEntry(cfg, e.Path, le.Data, rewriteOptions{})
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to write fixes to %s: %w", e.Path, err)
+			}
+		}
+	}
+
+	type reported struct {
+		lintFinding
+		severity string
+	}
+	var found []reported
+	failed := false
+	for _, r := range lintRules {
+		sev := r.severity(cfg.Lint)
+		if sev == severityOff {
+			continue
+		}
+		for _, f := range r.Check(le) {
+			if f.Line > 0 && f.Line <= len(le.Lines) && isSessionMarker(le.Lines[f.Line-1]) {
+				continue
+			}
+			f.Rule = r.Name
+			found = append(found, reported{f, sev})
+			if sev == severityError {
+				failed = true
+			}
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Line < found[j].Line })
+	var out []string
+	for _, f := range found {
+		out = append(out, fmt.Sprintf("%s:%d: %s: %s: %s", e.Path, f.Line, f.severity, f.Rule, f.Message))
+	}
+	return out, failed, nil
+}
+
+// runLint lints every entry in the range and reports whether any error-level
+// rule failed.
+func runLint(cfg Configuration, params Parameters) (bool, error) {
+	for name, sev := range cfg.Lint.Severity {
+		switch strings.ToLower(sev) {
+		case severityOff, severityWarning, severityError:
+		default:
+			return false, fmt.Errorf("lint rule '%s' has unknown severity '%s'", name, sev)
+		}
+	}
+	r, err := resolveQuery(cfg, queryFor(params), dayNow())
+	if err != nil {
+		return false, err
+	}
+	entries, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return false, err
+	}
+	failed := false
+	for _, e := range filterEntries(entries, r.From, r.To) {
+		out, bad, err := lintFile(e, cfg, params.Fix)
+		if err != nil {
+			return false, err
+		}
+		for _, line := range out {
+			fmt.Println(line)
+		}
+		failed = failed || bad
+	}
+	return failed, nil
+}
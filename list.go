@@ -0,0 +1,164 @@
+package wm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultListLast is how far back "wm list" looks without a range.
+const defaultListLast = "30d"
+
+// runList prints the entries in the range, newest first, with their size and
+// a preview of their first line after the header.  Without a range it shows
+// the last 30 days.  With --limit, only that many are read and printed, and
+// a token to continue with follows them.  While daily_word_goal is set, each
+// entry's words follow its size, with a check mark once they reach it.
+func runList(cfg Configuration, params Parameters) error {
+	q := queryFor(params)
+	if q.empty() {
+		q.Last = defaultListLast
+	}
+	r, err := resolveQuery(cfg, q, dayNow())
+	if err != nil {
+		return err
+	}
+	all, err := rootEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	if len(params.Topic) > 0 {
+		if err := checkTopic(params.Topic); err != nil {
+			return err
+		}
+		all = filterTopic(all, params.Topic)
+	}
+	entries := skipOnlineOnly(filterEntries(all, r.From, r.To))
+	limit := 0
+	if len(params.Limit) > 0 {
+		limit, err = strconv.Atoi(params.Limit)
+		if err != nil || limit < 1 {
+			return fmt.Errorf("--limit must be a positive number, not '%s'", params.Limit)
+		}
+	}
+	entries, next, err := entryPage(entries, params.PageToken, limit)
+	if err != nil {
+		return err
+	}
+	if params.Format != "json" && params.Format != "human" && len(params.Format) > 0 {
+		return fmt.Errorf("unknown list format '%s', expected human or json", params.Format)
+	}
+	if params.DatesOnly {
+		last := ""
+		for _, e := range entries {
+			if d := e.Date.Iso(); d != last {
+				fmt.Println(d)
+				last = d
+			}
+		}
+		return nil
+	}
+	if len(entries) == 0 && params.Format != "json" {
+		fmt.Println("no entries in range")
+		return nil
+	}
+
+	sizes := make([]int64, len(entries))
+	previews := make([]string, len(entries))
+	var words []int
+	var wc *wordCounter
+	if cfg.DailyWordGoal > 0 {
+		words = make([]int, len(entries))
+		wc = newWordCounter(cfg)
+	}
+	width, wordsWidth := 0, 0
+	for i, e := range entries {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return err
+		}
+		sizes[i] = int64(len(data))
+		previews[i] = truncate(entryPreview(data), 60)
+		if n := len(strconv.FormatInt(sizes[i], 10)); n > width {
+			width = n
+		}
+		if wc != nil {
+			words[i] = wc.count(&e.Date, data)
+			if n := len(strconv.Itoa(words[i])); n > wordsWidth {
+				wordsWidth = n
+			}
+		}
+	}
+	if params.Format == "json" {
+		page := listPage{Entries: []listedEntry{}, NextToken: next}
+		for i, e := range entries {
+			le := listedEntry{Date: e.Date.Iso(), Topic: e.Topic, Path: displayPath(e.Path), Size: sizes[i], Modified: e.ModTime, Preview: previews[i]}
+			if words != nil {
+				le.Words = &words[i]
+				le.GoalMet = words[i] >= cfg.DailyWordGoal
+			}
+			page.Entries = append(page.Entries, le)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(page)
+	}
+	for i, e := range entries {
+		if plainOutput {
+			fmt.Printf("date: %s, ", humanDate(e.Date))
+			if len(e.Topic) > 0 {
+				fmt.Printf("topic: %s, ", e.Topic)
+			}
+			fmt.Printf("size: %d bytes", sizes[i])
+			if words != nil {
+				fmt.Printf(", words: %d", words[i])
+				if words[i] >= cfg.DailyWordGoal {
+					fmt.Print(", goal met")
+				}
+			}
+			if params.ShowMtime {
+				fmt.Printf(", modified: %s", formatTime(cfg, e.ModTime))
+			}
+			fmt.Printf(", preview: %s\n", previews[i])
+			continue
+		}
+		line := fmt.Sprintf("%s%s  %*d", humanDate(e.Date), topicLabel(e), width, sizes[i])
+		if words != nil {
+			mark := " "
+			if words[i] >= cfg.DailyWordGoal {
+				mark = goalMark
+			}
+			line += fmt.Sprintf("  %*d %s", wordsWidth, words[i], mark)
+		}
+		if params.ShowMtime {
+			line += "  " + formatTime(cfg, e.ModTime)
+		}
+		fmt.Println(strings.TrimRight(line+"  "+previews[i], " "))
+	}
+	if len(next) > 0 {
+		fmt.Printf("more with --page-token=%s\n", next)
+	}
+	return nil
+}
+
+// listPage is a page of "list --format=json": the entries, newest first,
+// and the token of the next page, "" on the last.
+type listPage struct {
+	Entries   []listedEntry `json:"entries"`
+	NextToken string        `json:"next_token"`
+}
+
+type listedEntry struct {
+	Date     string    `json:"date"`
+	Topic    string    `json:"topic,omitempty"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"mtime"`
+	Preview  string    `json:"preview"`
+	// Words and GoalMet are only given while daily_word_goal is set.
+	Words   *int `json:"words,omitempty"`
+	GoalMet bool `json:"goal_met,omitempty"`
+}
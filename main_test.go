@@ -0,0 +1,171 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestMain runs wm itself instead of the tests when runWM starts the test
+// binary again, or a state writer for TestUpdateStateConcurrentProcesses.
+func TestMain(m *testing.M) {
+	if os.Getenv("WM_TEST_MAIN") == "1" {
+		// what cmd/wm does
+		err := Main(os.Args[1:])
+		if err != nil && len(err.Error()) > 0 {
+			log.Println(err)
+		}
+		os.Exit(ExitStatus(err))
+	}
+	if path := os.Getenv("WM_TEST_STATE_WRITER"); len(path) > 0 {
+		stateWriter(path, os.Getenv("WM_TEST_STATE_UPDATES"))
+	}
+	os.Exit(m.Run())
+}
+
+// testHome returns a configuration file for root in a new home directory,
+// and the environment wm is run with from runWM: that home, and nothing of
+// the user's own configuration, state, or clock.
+func testHome(t *testing.T, root string) (string, []string) {
+	t.Helper()
+	home := t.TempDir()
+	cfgFile := filepath.Join(home, "wm.toml")
+	content := "root = '" + filepath.ToSlash(root) + "'\neditor = 'true'\n"
+	if err := os.WriteFile(cfgFile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		switch name, _, _ := strings.Cut(kv, "="); name {
+		case "WMCFG", "WM_NOW", "WM_FAKE_NOW", "WM_TEMPLATE", "PAGER", "HOME", "USERPROFILE", "APPDATA",
+			"XDG_CONFIG_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME", "XDG_DATA_HOME":
+			continue
+		}
+		env = append(env, kv)
+	}
+	env = append(env, "HOME="+home, "USERPROFILE="+home, "APPDATA="+filepath.Join(home, "AppData"),
+		"XDG_CONFIG_HOME="+filepath.Join(home, ".config"), "XDG_STATE_HOME="+filepath.Join(home, ".state"),
+		"XDG_CACHE_HOME="+filepath.Join(home, ".cache"), "XDG_DATA_HOME="+filepath.Join(home, ".data"))
+	return cfgFile, env
+}
+
+// testConfigAppend adds lines to the end of the configuration file of
+// testHome.
+func testConfigAppend(t *testing.T, cfgFile, lines string) {
+	t.Helper()
+	f, err := os.OpenFile(cfgFile, os.O_APPEND|os.O_WRONLY, 0)
+	if err == nil {
+		_, err = f.WriteString(lines)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runWM runs wm with args in dir and env, returning what it wrote to
+// standard output and standard error and its exit status.
+func runWM(t *testing.T, dir string, env []string, args ...string) ([]byte, []byte, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(env, "WM_TEST_MAIN=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("running wm %q: %v", args, err)
+	}
+	return stdout.Bytes(), stderr.Bytes(), cmd.ProcessState.ExitCode()
+}
+
+// treeOf lists every file and directory under dir with its size.
+func treeOf(t *testing.T, dir string) map[string]int64 {
+	t.Helper()
+	tree := map[string]int64{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		tree[path] = info.Size()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestStartupWritesNothing(t *testing.T) {
+	// no configuration anywhere: the commands that don't need one mustn't
+	// create it, nor the root or any state
+	cfgFile, env := testHome(t, t.TempDir())
+	if err := os.Remove(cfgFile); err != nil {
+		t.Fatal(err)
+	}
+	home := filepath.Dir(cfgFile)
+	cwd := t.TempDir()
+	for _, args := range [][]string{{"--version"}, {"--help"}, {"-h"}} {
+		before, beforeCwd := treeOf(t, home), treeOf(t, cwd)
+		stdout, stderr, code := runWM(t, cwd, env, args...)
+		if code != 0 || len(stdout) == 0 {
+			t.Errorf("wm %q exited %d with %q\nstderr:\n%s", args, code, stdout, stderr)
+		}
+		if after := treeOf(t, home); !reflect.DeepEqual(after, before) {
+			t.Errorf("wm %q wrote to the home directory: %v, was %v", args, after, before)
+		}
+		if after := treeOf(t, cwd); !reflect.DeepEqual(after, beforeCwd) {
+			t.Errorf("wm %q wrote to the current directory: %v, was %v", args, after, beforeCwd)
+		}
+	}
+}
+
+func BenchmarkStartupVersion(b *testing.B) {
+	env := append(os.Environ(), "WM_TEST_MAIN=1")
+	for i := 0; i < b.N; i++ {
+		cmd := exec.Command(os.Args[0], "--version")
+		cmd.Env = env
+		if err := cmd.Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMainReturnsStatus(t *testing.T) {
+	root := t.TempDir()
+	cfgFile, env := testHome(t, root)
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "XDG_") || name == "HOME" {
+			t.Setenv(name, value)
+		}
+	}
+	t.Setenv("WMCFG", cfgFile)
+	testEntries(t, root, DatePath{2024, 3, 7})
+
+	if err := Main([]string{"exists", "2024-03-07"}); err != nil {
+		t.Errorf("wm exists for an entry = %v, want nil", err)
+	}
+	// a status with nothing to say
+	err := Main([]string{"exists", "2024-03-08"})
+	if ExitStatus(err) != 1 || err == nil || err.Error() != "" {
+		t.Errorf("wm exists for a missing entry = %q, status %d, want an empty error with status 1", err, ExitStatus(err))
+	}
+	err = Main([]string{"search"})
+	if ExitStatus(err) != exitSearchError || err == nil || !strings.Contains(err.Error(), "nothing to search for") {
+		t.Errorf("wm search without terms = %v, status %d, want the reason with status %d", err, ExitStatus(err), exitSearchError)
+	}
+	err = Main([]string{"--no-such-flag"})
+	if ExitStatus(err) != 1 {
+		t.Errorf("wm with a bad flag = %v, status %d, want 1", err, ExitStatus(err))
+	}
+}
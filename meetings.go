@@ -0,0 +1,128 @@
+package wm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var meetingLineRe = regexp.MustCompile(`^- (all day|\d\d:\d\d-\d\d:\d\d) (.*?)(?: @ .*)?$`)
+
+// meetingLine renders the line for one event.
+func meetingLine(ev icsEvent) string {
+	when := "all day"
+	if !ev.AllDay {
+		when = fmt.Sprintf("%s-%s", ev.Start.Format("15:04"), ev.End.Format("15:04"))
+	}
+	line := fmt.Sprintf("- %s %s", when, ev.Summary)
+	if len(ev.Location) > 0 {
+		line += " @ " + ev.Location
+	}
+	return line
+}
+
+// meetingKey identifies a meeting line across re-runs by its time and title,
+// so that notes written under it survive a location change.
+func meetingKey(line string) (string, bool) {
+	m := meetingLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return "", false
+	}
+	return m[1] + " " + m[2], true
+}
+
+// renderMeetings builds the body of the meetings region.  Notes the user
+// wrote under a meeting line in the previous body are carried over under the
+// same meeting, and notes under meetings that disappeared from the calendar
+// are kept at the end rather than dropped.
+func renderMeetings(events []icsEvent, previous string) string {
+	var preamble []string
+	notes := map[string][]string{}
+	var order []string
+	original := map[string]string{}
+	key := ""
+	for _, line := range strings.Split(strings.TrimSuffix(previous, "\n"), "\n") {
+		if k, ok := meetingKey(line); ok {
+			key = k
+			order = append(order, k)
+			original[k] = line
+			continue
+		}
+		if len(key) == 0 {
+			if len(strings.TrimSpace(line)) > 0 {
+				preamble = append(preamble, line)
+			}
+			continue
+		}
+		notes[key] = append(notes[key], line)
+	}
+
+	var b strings.Builder
+	for _, line := range preamble {
+		b.WriteString(line + "\n")
+	}
+	used := map[string]bool{}
+	for _, ev := range events {
+		line := meetingLine(ev)
+		k, _ := meetingKey(line)
+		b.WriteString(line + "\n")
+		if !used[k] {
+			for _, n := range notes[k] {
+				b.WriteString(n + "\n")
+			}
+			used[k] = true
+		}
+	}
+	for _, k := range order {
+		if used[k] || len(strings.TrimSpace(strings.Join(notes[k], ""))) == 0 {
+			continue
+		}
+		b.WriteString(original[k] + " (no longer on the calendar)\n")
+		for _, n := range notes[k] {
+			b.WriteString(n + "\n")
+		}
+	}
+	if len(events) == 0 && b.Len() == 0 {
+		b.WriteString("- no meetings\n")
+	}
+	return b.String()
+}
+
+// runMeetings writes the day's calendar events into the entry's meetings
+// section, replacing what a previous run generated.
+func runMeetings(cfg Configuration, params Parameters) error {
+	pd, err := parseDateString(cfg, params.Date)
+	if err != nil {
+		return err
+	}
+	src, err := openICS(params.FromIcs)
+	if err != nil {
+		return err
+	}
+	events, err := parseICS(src)
+	src.Close()
+	if err != nil {
+		return fmt.Errorf("failed to parse calendar: %w", err)
+	}
+	todays := eventsOn(events, pd.Time(), params.SkipAllday)
+
+	path, _, err := ensureEntry(cfg, pd, strictContent(cfg, params.Create, newTemplater(cfg, "", false).content))
+	if err != nil {
+		return err
+	}
+	data, err := readEntry(cfg, path)
+	if err != nil {
+		return err
+	}
+	previous := ""
+	if start, end, ok := findRegion(data, "meetings"); ok {
+		previous = string(data[start:end])
+	}
+	updated := replaceRegion(data, "meetings", "## Meetings", renderMeetings(todays, previous))
+	_, err = rewriteEntry(cfg, path, updated, rewriteOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	fmt.Printf("%d meetings written to %s\n", len(todays), path)
+	return nil
+}
@@ -0,0 +1,66 @@
+package wm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTimeFormat = "2006-01-02 15:04"
+
+// formatTime renders t using the configured time_format.
+func formatTime(cfg Configuration, t time.Time) string {
+	if len(cfg.TimeFormat) == 0 {
+		return t.Format(defaultTimeFormat)
+	}
+	return t.Format(cfg.TimeFormat)
+}
+
+// parseAge parses a look-back window such as "2d", "1w", or any duration
+// understood by time.ParseDuration.
+func parseAge(in string) (time.Duration, error) {
+	in = strings.TrimSpace(strings.ToLower(in))
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(in, suffix)); err == nil && strings.HasSuffix(in, suffix) {
+			if n < 0 {
+				return 0, fmt.Errorf("age '%s' must not be negative", in)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(in)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse age '%s': use e.g. 2d, 1w, or 12h", in)
+	}
+	return d, nil
+}
+
+// runModified lists entries edited within the window, most recently edited
+// first, regardless of the date they are for.
+func runModified(cfg Configuration, params Parameters) error {
+	since := params.Since
+	if len(since) == 0 {
+		since = "7d"
+	}
+	cutoff, err := sinceTime(cfg, since, now())
+	if err != nil {
+		return err
+	}
+	entries, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	var recent []Entry
+	for _, e := range entries {
+		if e.ModTime.After(cutoff) {
+			recent = append(recent, e)
+		}
+	}
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].ModTime.After(recent[j].ModTime) })
+	for _, e := range recent {
+		fmt.Printf("%s  edited %s  %s\n", e.Date.Iso(), formatTime(cfg, e.ModTime), e.Path)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// isoMonthRe matches "2024-03" and "2024/3", monthYearRe "3/2024".
+	isoMonthRe  = regexp.MustCompile(`^(\d{4})[-/.](\d{1,2})$`)
+	monthYearRe = regexp.MustCompile(`^(\d{1,2})[-/.](\d{4})$`)
+	// namedMonthRe matches a month name with an optional year, as in
+	// "march 2024", "mar. 2024", and "März".
+	namedMonthRe = regexp.MustCompile(`^(\pL+)\.?(?:,?\s+(\d{4}))?$`)
+)
+
+// isMonthString reports whether in names a whole month in one of the forms
+// parseMonthString reads itself, rather than a day.
+func isMonthString(in string) bool {
+	in = strings.TrimSpace(in)
+	if isoMonthRe.MatchString(in) || monthYearRe.MatchString(in) {
+		return true
+	}
+	if m := namedMonthRe.FindStringSubmatch(in); m != nil {
+		_, err := resolveMonthName(m[1])
+		return err == nil
+	}
+	return false
+}
+
+// parseMonthString reads a month: "2024-03", "3/2024", a month name in
+// English or the configured locale with or without a year, the current one
+// by default, or any date parseDateString reads, whose month it is.  An
+// empty string is the current month.
+func parseMonthString(cfg Configuration, in string) (int, time.Month, error) {
+	in = strings.TrimSpace(in)
+	now := dayNow()
+	if len(in) == 0 {
+		return now.Year(), now.Month(), nil
+	}
+	year, month := 0, 0
+	if m := isoMonthRe.FindStringSubmatch(in); m != nil {
+		year, _ = strconv.Atoi(m[1])
+		month, _ = strconv.Atoi(m[2])
+	} else if m := monthYearRe.FindStringSubmatch(in); m != nil {
+		month, _ = strconv.Atoi(m[1])
+		year, _ = strconv.Atoi(m[2])
+	} else if m := namedMonthRe.FindStringSubmatch(in); m != nil {
+		if n, err := resolveMonthName(m[1]); err == nil {
+			month, year = n, now.Year()
+			if len(m[2]) > 0 {
+				year, _ = strconv.Atoi(m[2])
+			}
+		}
+	}
+	if year == 0 {
+		pd, err := parseDateString(cfg, in)
+		if err != nil {
+			return 0, 0, err
+		}
+		return pd.year, time.Month(pd.month), nil
+	}
+	if month < 1 || month > 12 {
+		return 0, 0, fmt.Errorf("'%s' has no month %d", in, month)
+	}
+	return year, time.Month(month), nil
+}
+
+// runMonth gathers the existing entries of the month of the date given,
+// the current month by default, under a "### March 5, 2024" heading each,
+// and opens them in the editor as a read-only file in the temporary
+// directory or, with --cat, prints them.  Days without an entry are left
+// out.
+func runMonth(cfg Configuration, params Parameters) error {
+	year, month, err := parseMonthString(cfg, strings.Join(params.DateWords, " "))
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	days := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for d := 1; d <= days; d++ {
+		pd := &DatePath{year: year, month: int(month), day: d}
+		path, err := entryPath(cfg, pd)
+		if err != nil {
+			return err
+		}
+		data, err := readEntry(cfg, path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "### %s %d, %d\n\n", monthName(int(month)), d, year)
+		b.Write(data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			b.WriteString("\n")
+		}
+	}
+	if b.Len() == 0 {
+		fmt.Printf("no entries in %s %d\n", monthName(int(month)), year)
+		return nil
+	}
+	if params.Cat {
+		_, err = os.Stdout.Write(b.Bytes())
+		return err
+	}
+	f, err := os.CreateTemp("", fmt.Sprintf("wm-%d-%02d-*%s", year, month, entryLayout(cfg).Ext))
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b.Bytes())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// a read-only copy, so that nothing is written into it in the
+		// belief that it is an entry
+		err = os.Chmod(f.Name(), 0o444)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return editFiles(cfg, fileEdit{Target: editTarget{Kind: kindMonth}, Paths: []string{f.Name()}})
+}
@@ -0,0 +1,54 @@
+package wm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonthForms(t *testing.T) {
+	testToday(t, 2024, time.March, 7)
+	tests := []struct {
+		in    string
+		year  int
+		month time.Month
+	}{
+		{"2024-03", 2024, time.March},
+		{"2023/11", 2023, time.November},
+		{"3/2024", 2024, time.March},
+		{"march 2023", 2023, time.March},
+		{"Mar. 2023", 2023, time.March},
+		{"june", 2024, time.June},
+	}
+	for _, tt := range tests {
+		year, month, err := parseMonthString(Configuration{}, tt.in)
+		if err != nil || year != tt.year || month != tt.month {
+			t.Errorf("parseMonthString(%q) = %d, %v, %v, want %d, %v", tt.in, year, month, err, tt.year, tt.month)
+		}
+		// a day is never guessed from a month
+		if pd, err := parseDateString(Configuration{}, tt.in); err == nil || !strings.Contains(err.Error(), "is a month") {
+			t.Errorf("parseDateString(%q) = %v, %v, want it refused as a month", tt.in, pd, err)
+		}
+	}
+	// a date stands for its month
+	if year, month, err := parseMonthString(Configuration{}, "2023-11-05"); err != nil || year != 2023 || month != time.November {
+		t.Errorf("parseMonthString(2023-11-05) = %d, %v, %v", year, month, err)
+	}
+	if _, _, err := parseMonthString(Configuration{}, "2024-13"); err == nil {
+		t.Error("parseMonthString(2024-13) succeeded")
+	}
+}
+
+func TestMonthCat(t *testing.T) {
+	root := t.TempDir()
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+	testEntries(t, root, DatePath{2024, 2, 29}, DatePath{2024, 3, 5}, DatePath{2024, 3, 21}, DatePath{2024, 4, 1})
+	want := "### March 5, 2024\n\n2024-03-05\n\n### March 21, 2024\n\n2024-03-21\n"
+	for _, args := range [][]string{{"march", "2024"}, {"2024-03"}, {"Mar.", "2024"}} {
+		stdout, stderr, code := runWM(t, root, env, append([]string{"month", "--cat"}, args...)...)
+		if code != 0 || string(stdout) != want {
+			t.Errorf("wm month --cat %q = %q, %d (%s), want the two entries of March:\n%s", args, stdout, code, stderr, want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package wm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The month index remembers which days of each month directory have an
+// entry, so that questions about a date or a range, such as exists and
+// fill, are answered from one stat of the month directory instead of one
+// per day.  A month's manifest is only trusted while the directory's mtime
+// is the one it was listed at, and when the listing came at least
+// monthIndexSlack after it, since filesystems with coarse timestamps may
+// not move the mtime for a file created right after the listing.  Anything
+// else falls back to a real listing, so a stale index costs time but never
+// gives a wrong answer.
+
+const (
+	monthIndexFile    = "months.json"
+	monthIndexVersion = 1
+	monthIndexSlack   = 2 * time.Second
+)
+
+// monthManifest is the days with an entry in one month directory, and the
+// directory's mtime and the time it was listed, in nanoseconds.
+type monthManifest struct {
+	ModTime int64 `json:"mtime"`
+	Listed  int64 `json:"listed"`
+	Days    []int `json:"days"`
+}
+
+func (m monthManifest) fresh(mtime time.Time) bool {
+	return m.ModTime == mtime.UnixNano() && m.Listed-m.ModTime >= int64(monthIndexSlack)
+}
+
+func decodeMonthIndex(data []byte) map[string]map[string]monthManifest {
+	all := map[string]map[string]monthManifest{}
+	if len(data) == 0 || json.Unmarshal(data, &all) != nil {
+		return map[string]map[string]monthManifest{}
+	}
+	return all
+}
+
+// monthIndex is the index of the root of cfg as read at the start of a
+// command, with the manifests that command listed afresh.  Only the nested
+// layout, root/YYYY/M/D.txt, is indexed.
+type monthIndex struct {
+	cfg       Configuration
+	manifests map[string]monthManifest
+	updates   map[string]monthManifest
+}
+
+// indexKey is what the manifests of the root of cfg are kept under: the root
+// and the extensions an entry may have, since a listing with other
+// extensions counts other days.
+func indexKey(cfg Configuration) string {
+	return rootKey(cfg.Root) + " " + strings.Join(entryExts(cfg), ",")
+}
+
+// loadMonthIndex reads the index of the root of cfg.  An unreadable index is
+// an empty one.
+func loadMonthIndex(cfg Configuration) *monthIndex {
+	x := &monthIndex{cfg: cfg, manifests: map[string]monthManifest{}, updates: map[string]monthManifest{}}
+	path, err := statePath(monthIndexFile)
+	if err != nil {
+		return x
+	}
+	data, err := readState(path, monthIndexVersion)
+	if err != nil {
+		return x
+	}
+	if m := decodeMonthIndex(data)[indexKey(cfg)]; m != nil {
+		x.manifests = m
+	}
+	return x
+}
+
+// days returns the days of the month with an entry.  ok is false when the
+// layout isn't indexed, and the caller has to look at the files itself.
+func (x *monthIndex) days(year, month int) (days map[int]bool, ok bool, err error) {
+	if !entryLayout(x.cfg).nested() {
+		return nil, false, nil
+	}
+	dir := filepath.Join(x.cfg.Root, strconv.Itoa(year), strconv.Itoa(month))
+	days = map[int]bool{}
+	info, err := os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return days, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	key := fmt.Sprintf("%d/%d", year, month)
+	m, found := x.updates[key]
+	if !found {
+		m, found = x.manifests[key]
+	}
+	if !found || !m.fresh(info.ModTime()) {
+		listed := time.Now()
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, false, err
+		}
+		m = monthManifest{ModTime: info.ModTime().UnixNano(), Listed: listed.UnixNano(), Days: []int{}}
+		for _, f := range files {
+			if d, ok := entryDay(x.cfg, f.Name()); ok && f.Type().IsRegular() {
+				m.Days = append(m.Days, d)
+			}
+		}
+		x.updates[key] = m
+	}
+	for _, d := range m.Days {
+		days[d] = true
+	}
+	return days, true, nil
+}
+
+// entryDay returns the day of a main entry's file name in a month directory.
+func entryDay(cfg Configuration, name string) (int, bool) {
+	ext := entryExt(cfg, name)
+	if len(ext) == 0 {
+		return 0, false
+	}
+	if _, topic := splitTopic(name, ext); len(topic) > 0 {
+		return 0, false
+	}
+	d, err := strconv.Atoi(strings.TrimSuffix(name, ext))
+	if err != nil || d < 1 || d > 31 || strconv.Itoa(d)+ext != name {
+		return 0, false
+	}
+	return d, true
+}
+
+// has reports whether pd has an entry.  ok is false when the layout isn't
+// indexed.
+func (x *monthIndex) has(pd *DatePath) (has bool, ok bool, err error) {
+	days, ok, err := x.days(pd.year, pd.month)
+	return days[pd.day], ok, err
+}
+
+// save stores the manifests listed afresh.  Failing to is not an error, as
+// the index is only ever a shortcut.
+func (x *monthIndex) save() {
+	if len(x.updates) == 0 {
+		return
+	}
+	path, err := statePath(monthIndexFile)
+	if err != nil {
+		return
+	}
+	updateState(path, monthIndexVersion, func(old []byte) ([]byte, error) {
+		all := decodeMonthIndex(old)
+		key := indexKey(x.cfg)
+		if all[key] == nil {
+			all[key] = map[string]monthManifest{}
+		}
+		for dir, m := range x.updates {
+			all[key][dir] = m
+		}
+		return json.Marshal(all)
+	})
+	x.updates = map[string]monthManifest{}
+}
+
+// recordWalk updates the index from a walk of the whole root of cfg that
+// started at started, for the month directories holding entries.  Months
+// whose manifest is already fresh are left alone, so a walk of an unchanged
+// root writes nothing.
+func recordWalk(cfg Configuration, entries []Entry, started time.Time) {
+	if !entryLayout(cfg).nested() {
+		return
+	}
+	x := loadMonthIndex(cfg)
+	byDir := map[string][]int{}
+	for _, e := range entries {
+		if len(e.Topic) > 0 || len(e.Attachment) > 0 || len(e.Scratch) > 0 || len(e.Note) > 0 {
+			continue
+		}
+		dir := filepath.Dir(e.Path)
+		if filepath.Base(dir) != strconv.Itoa(e.Date.month) || filepath.Base(e.Path) != strconv.Itoa(e.Date.day)+entryExt(cfg, e.Path) {
+			// named months, such as 03-März, and padded days aren't
+			// where entryPath looks, so they aren't indexed
+			continue
+		}
+		byDir[dir] = append(byDir[dir], e.Date.day)
+	}
+	for dir, days := range byDir {
+		info, err := os.Stat(dir)
+		if err != nil || info.ModTime().After(started) {
+			continue
+		}
+		key, err := filepath.Rel(cfg.Root, dir)
+		if err != nil {
+			continue
+		}
+		key = filepath.ToSlash(key)
+		if m, ok := x.manifests[key]; ok && m.fresh(info.ModTime()) {
+			continue
+		}
+		x.updates[key] = monthManifest{ModTime: info.ModTime().UnixNano(), Listed: started.UnixNano(), Days: days}
+	}
+	x.save()
+}
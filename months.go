@@ -0,0 +1,216 @@
+package wm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// monthNames holds the full month names for each supported locale.  English
+// is always understood; date_locale adds one more language to both the date
+// parser and the path parser and selects the names wm renders.
+var monthNames = map[string][12]string{
+	"en": {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	"de": {"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+	"fr": {"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+	"es": {"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+	"it": {"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+	"nl": {"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+	"pt": {"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+}
+
+// weekdayNames holds the full and abbreviated weekday names for each
+// locale, indexed by time.Weekday, so Sunday first.
+var weekdayNames = map[string][2][7]string{
+	"en": {{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}, {"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}},
+	"de": {{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"}, {"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"}},
+	"fr": {{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"}, {"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."}},
+	"es": {{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"}, {"dom", "lun", "mar", "mié", "jue", "vie", "sáb"}},
+	"it": {{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"}, {"dom", "lun", "mar", "mer", "gio", "ven", "sab"}},
+	"nl": {{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"}, {"zo", "ma", "di", "wo", "do", "vr", "za"}},
+	"pt": {{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"}, {"dom", "seg", "ter", "qua", "qui", "sex", "sáb"}},
+}
+
+// dateLocale is the configured date_locale, set when the configuration is
+// loaded.  outputLocale is the locale of the names wm renders; it follows
+// date_locale unless --locale overrides it.
+var (
+	dateLocale   = "en"
+	outputLocale = "en"
+)
+
+// checkLocale normalizes locale, which the name setting is for.
+func checkLocale(setting, locale string) (string, error) {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if len(locale) == 0 {
+		locale = "en"
+	}
+	if _, ok := monthNames[locale]; !ok {
+		var known []string
+		for l := range monthNames {
+			known = append(known, l)
+		}
+		sort.Strings(known)
+		return "", fmt.Errorf("unsupported %s '%s', expected one of %s", setting, locale, strings.Join(known, ", "))
+	}
+	return locale, nil
+}
+
+// setDateLocale validates and selects the locale used for month names, both
+// parsed and rendered.
+func setDateLocale(locale string) error {
+	locale, err := checkLocale("date_locale", locale)
+	if err != nil {
+		return err
+	}
+	dateLocale, outputLocale = locale, locale
+	return nil
+}
+
+// setOutputLocale selects the locale of rendered names for this run, as
+// given with --locale.
+func setOutputLocale(locale string) error {
+	locale, err := checkLocale("--locale", locale)
+	if err != nil {
+		return err
+	}
+	outputLocale = locale
+	return nil
+}
+
+// monthName renders month m (1-12) in the output locale.
+func monthName(m int) string {
+	return monthNames[outputLocale][m-1]
+}
+
+// weekdayName renders wd in full in the output locale.
+func weekdayName(wd time.Weekday) string {
+	return weekdayNames[outputLocale][0][wd]
+}
+
+// weekdayAbbr renders wd abbreviated in the output locale, padded to the
+// width of the longest abbreviation so that columns stay aligned.
+func weekdayAbbr(wd time.Weekday) string {
+	return padRunes(weekdayNames[outputLocale][1][wd], weekdayWidth(1))
+}
+
+// weekdayWidth is the width of the longest full (0) or abbreviated (1)
+// weekday name in the output locale.
+func weekdayWidth(form int) int {
+	w := 0
+	for _, name := range weekdayNames[outputLocale][form] {
+		if n := utf8.RuneCountInString(name); n > w {
+			w = n
+		}
+	}
+	return w
+}
+
+// padRunes pads s with spaces to width runes.  fmt pads by bytes, which
+// misaligns names with accents.
+func padRunes(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// monthLookup maps every accepted lowercase spelling, full or abbreviated to
+// its first three letters, to the months it can mean.
+func monthLookup() map[string][]int {
+	table := map[string][]int{}
+	add := func(name string, m int) {
+		name = strings.ToLower(name)
+		for _, existing := range table[name] {
+			if existing == m {
+				return
+			}
+		}
+		table[name] = append(table[name], m)
+	}
+	for _, locale := range []string{"en", dateLocale} {
+		for i, name := range monthNames[locale] {
+			add(name, i+1)
+			if r := []rune(name); len(r) > 3 {
+				add(string(r[:3]), i+1)
+			}
+		}
+	}
+	return table
+}
+
+// resolveMonthName returns the month named by word in English or the
+// configured locale.
+func resolveMonthName(word string) (int, error) {
+	months := monthLookup()[strings.ToLower(word)]
+	switch len(months) {
+	case 0:
+		return 0, fmt.Errorf("unknown month name '%s'", word)
+	case 1:
+		return months[0], nil
+	}
+	return 0, fmt.Errorf("month name '%s' is ambiguous", word)
+}
+
+var monthSegmentSplitRe = regexp.MustCompile(`[-_. ]+`)
+
+// parseMonthSegment resolves the month directory of an entry path.  It
+// accepts a number ("3", "03"), a name ("März", "mar"), or both ("03-März"),
+// in which case they must agree.
+func parseMonthSegment(seg string) (int, error) {
+	number, name := 0, 0
+	for _, part := range monthSegmentSplitRe.Split(seg, -1) {
+		if len(part) == 0 {
+			continue
+		}
+		if n, err := strconv.Atoi(part); err == nil {
+			if number != 0 || n < 1 || n > 12 {
+				return 0, fmt.Errorf("bad month segment '%s'", seg)
+			}
+			number = n
+			continue
+		}
+		if name != 0 {
+			return 0, fmt.Errorf("bad month segment '%s'", seg)
+		}
+		m, err := resolveMonthName(part)
+		if err != nil {
+			return 0, err
+		}
+		name = m
+	}
+	switch {
+	case number != 0 && name != 0 && number != name:
+		return 0, fmt.Errorf("month segment '%s' has number %d but names %s", seg, number, monthNames["en"][name-1])
+	case number != 0:
+		return number, nil
+	case name != 0:
+		return name, nil
+	}
+	return 0, fmt.Errorf("bad month segment '%s'", seg)
+}
+
+var wordRe = regexp.MustCompile(`\p{L}+`)
+
+// englishMonths rewrites month names of the configured locale in a date
+// string into English so time.Parse can read them.
+func englishMonths(in string) string {
+	if dateLocale == "en" {
+		return in
+	}
+	return wordRe.ReplaceAllStringFunc(in, func(word string) string {
+		m, err := resolveMonthName(word)
+		if err != nil {
+			return word
+		}
+		full := monthNames["en"][m-1]
+		if len([]rune(word)) <= 3 {
+			return full[:3]
+		}
+		return full
+	})
+}
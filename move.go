@@ -0,0 +1,156 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// movedContent returns dest with the body of src, the entry of from, appended
+// under a "Moved from" heading, and reports whether src had anything to
+// append besides its header.
+func movedContent(dest, src []byte, from *DatePath) ([]byte, bool) {
+	body := bytes.TrimLeft(stripHeader(src), "\r\n")
+	if len(bytes.TrimSpace(body)) == 0 {
+		return dest, false
+	}
+	var b bytes.Buffer
+	b.Write(dest)
+	if len(dest) > 0 && dest[len(dest)-1] != '\n' {
+		b.WriteString("\n")
+	}
+	if len(dest) > 0 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "## Moved from %s\n\n", from.Iso())
+	b.Write(body)
+	if body[len(body)-1] != '\n' {
+		b.WriteString("\n")
+	}
+	return b.Bytes(), true
+}
+
+// removeMoved removes the entry at path once move has merged it into
+// another, backing it up first, or with trash on moves it to .wm-trash.
+func removeMoved(cfg Configuration, path string) (string, error) {
+	if cfg.Trash {
+		dest, err := trashFile(cfg, path)
+		if err != nil {
+			return "", err
+		}
+		return "moved it to " + displayPath(dest), nil
+	}
+	if _, err := backupEntry(cfg, path); err != nil {
+		return "", err
+	}
+	return "removed it", os.Remove(path)
+}
+
+// runMove moves the entry of one date to another: renamed, with its header
+// rewritten to the new date, when the other date has no entry, and otherwise
+// appended to that entry under a "Moved from" heading and then removed.
+// --dry-run only prints what would be done.
+func runMove(cfg Configuration, params Parameters) error {
+	from, err := parseDateString(cfg, params.From)
+	if err != nil {
+		return fmt.Errorf("bad <from>: %w", err)
+	}
+	to, err := parseDateString(cfg, params.To)
+	if err != nil {
+		return fmt.Errorf("bad <to>: %w", err)
+	}
+	if len(params.Topic) > 0 {
+		if err := checkTopic(params.Topic); err != nil {
+			return err
+		}
+	}
+	if *from == *to {
+		return fmt.Errorf("%s and %s are the same day", params.From, params.To)
+	}
+	src, err := topicEntryPath(cfg, from, params.Topic)
+	if err != nil {
+		return err
+	}
+	dest, err := topicEntryPath(cfg, to, params.Topic)
+	if err != nil {
+		return err
+	}
+	data, err := readEntry(cfg, src)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no entry for %s", from.Iso())
+	} else if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	existing, err := readEntry(cfg, dest)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return moveEntry(cfg, params, src, dest, data, to)
+	case err != nil:
+		return fmt.Errorf("failed to read %s: %w", dest, err)
+	}
+	merged, changed := movedContent(existing, data, from)
+	if params.DryRun {
+		if changed {
+			fmt.Printf("would append %s to %s under \"Moved from %s\"\n", displayPath(src), displayPath(dest), from.Iso())
+		}
+		if cfg.Trash {
+			fmt.Printf("would move %s to %s\n", displayPath(src), trashDir)
+		} else {
+			fmt.Printf("would remove %s\n", displayPath(src))
+		}
+		return nil
+	}
+	if changed {
+		if _, err := rewriteEntry(cfg, dest, merged, rewriteOptions{Backup: true}); err != nil {
+			return fmt.Errorf("failed to append to %s: %w", dest, err)
+		}
+		fmt.Printf("appended %s to %s under \"Moved from %s\"\n", displayPath(src), displayPath(dest), from.Iso())
+	}
+	done, err := removeMoved(cfg, src)
+	if err != nil {
+		return fmt.Errorf("failed to remove %s: %w", src, err)
+	}
+	fmt.Printf("%s: %s\n", displayPath(src), done)
+	return nil
+}
+
+// moveEntry renames the entry at src, whose content is data, to dest, the
+// entry of to, which doesn't exist yet, and rewrites its header to the new
+// date.  The entry is backed up first.
+func moveEntry(cfg Configuration, params Parameters, src, dest string, data []byte, to *DatePath) error {
+	h, ok := findHeader(data)
+	rewrite := ok && h.Date != nil && *h.Date != *to
+	if params.DryRun {
+		if dir := filepath.Dir(dest); !isDir(dir) {
+			fmt.Printf("would create directory %s\n", displayPath(dir))
+		}
+		fmt.Printf("would move %s to %s\n", displayPath(src), displayPath(dest))
+		if rewrite {
+			fmt.Printf("would rewrite its header to %s\n", to.Iso())
+		}
+		return nil
+	}
+	if _, err := backupEntry(cfg, src); err != nil {
+		return err
+	}
+	if err := makeDir(cfg, filepath.Dir(dest)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", dest, err)
+	}
+	if err := os.Rename(src, dest); err != nil {
+		return fmt.Errorf("failed to move %s: %w", src, err)
+	}
+	if err := resealMoved(cfg, dest); err != nil {
+		return fmt.Errorf("failed to encrypt %s at its new path: %w", dest, err)
+	}
+	fmt.Printf("moved %s to %s\n", displayPath(src), displayPath(dest))
+	if rewrite {
+		if _, err := rewriteEntry(cfg, dest, rewriteHeader(data, to), rewriteOptions{KeepModTime: true}); err != nil {
+			return fmt.Errorf("failed to rewrite header of %s: %w", dest, err)
+		}
+		fmt.Printf("rewrote its header to %s\n", to.Iso())
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package wm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMovedContent(t *testing.T) {
+	from := DatePath{2024, 3, 7}
+	src := renderHeader(&from) + "wrote this on the wrong day\n"
+	tests := []struct {
+		name, dest, src string
+		want            string
+		changed         bool
+	}{
+		{"appended", "kept\n", src, "kept\n\n## Moved from 2024-03-07\n\nwrote this on the wrong day\n", true},
+		{"no final newline", "kept", src, "kept\n\n## Moved from 2024-03-07\n\nwrote this on the wrong day\n", true},
+		{"empty destination", "", src, "## Moved from 2024-03-07\n\nwrote this on the wrong day\n", true},
+		{"source without a final newline", "kept\n", renderHeader(&from) + "last", "kept\n\n## Moved from 2024-03-07\n\nlast\n", true},
+		{"header only", "kept\n", renderHeader(&from), "kept\n", false},
+		{"blank body", "kept\n", renderHeader(&from) + "\n  \n", "kept\n", false},
+	}
+	for _, tt := range tests {
+		got, changed := movedContent([]byte(tt.dest), []byte(tt.src), &from)
+		if string(got) != tt.want || changed != tt.changed {
+			t.Errorf("%s: movedContent = %q, %v; want %q, %v", tt.name, got, changed, tt.want, tt.changed)
+		}
+	}
+}
+
+// testMoveRoot returns a root for move with entries of the given bodies, and
+// the environment to run wm in it.
+func testMoveRoot(t *testing.T, bodies map[DatePath]string) (string, string, []string) {
+	t.Helper()
+	root := t.TempDir()
+	for pd, body := range bodies {
+		testEntryBody(t, Configuration{Root: root}, pd, body)
+	}
+	if err := writeRootMarker(Configuration{Root: root}); err != nil {
+		t.Fatal(err)
+	}
+	cfgFile, env := testHome(t, root)
+	return root, cfgFile, append(env, "WMCFG="+cfgFile)
+}
+
+func TestMoveRenames(t *testing.T) {
+	jan31, feb1 := DatePath{2024, 1, 31}, DatePath{2024, 2, 1}
+	root, _, env := testMoveRoot(t, map[DatePath]string{jan31: "opened today just after midnight\n"})
+	src := filepath.Join(root, "2024", "1", "31.txt")
+	dest := filepath.Join(root, "2024", "2", "1.txt")
+
+	out, stderr, code := runWM(t, root, env, "move", "2024-01-31", "2024-02-01", "--dry-run")
+	want := "would create directory " + filepath.Dir(dest) + "\nwould move " + src + " to " + dest + "\nwould rewrite its header to 2024-02-01\n"
+	if code != exitOK || string(out) != want {
+		t.Errorf("move --dry-run = %q, %d (%s), want %q", out, code, stderr, want)
+	}
+	if _, err := os.Stat(filepath.Dir(dest)); err == nil {
+		t.Error("move --dry-run created the directory")
+	}
+	assertEntry(t, src, renderHeader(&jan31)+"opened today just after midnight\n")
+
+	out, stderr, code = runWM(t, root, env, "move", "2024-01-31", "2024-02-01")
+	if code != exitOK || !strings.Contains(string(out), "rewrote its header to 2024-02-01") {
+		t.Fatalf("move = %q, %d (%s)", out, code, stderr)
+	}
+	if _, err := os.Stat(src); err == nil {
+		t.Errorf("%s is still there after the move", src)
+	}
+	assertEntry(t, dest, renderHeader(&feb1)+"opened today just after midnight\n")
+
+	// back across a year, into a year without a directory yet
+	dec31 := DatePath{2023, 12, 31}
+	if _, stderr, code := runWM(t, root, env, "move", "2024-02-01", "2023-12-31"); code != exitOK {
+		t.Fatalf("move to 2023-12-31 exited %d: %s", code, stderr)
+	}
+	assertEntry(t, filepath.Join(root, "2023", "12", "31.txt"), renderHeader(&dec31)+"opened today just after midnight\n")
+}
+
+func TestMoveMerges(t *testing.T) {
+	mar7, mar8 := DatePath{2024, 3, 7}, DatePath{2024, 3, 8}
+	bodies := map[DatePath]string{mar7: "the wrong day's notes\n", mar8: "already here\n"}
+	for _, trash := range []bool{false, true} {
+		root, cfgFile, env := testMoveRoot(t, bodies)
+		if trash {
+			testConfigAppend(t, cfgFile, "trash = true\n")
+		}
+		src := filepath.Join(root, "2024", "3", "7.txt")
+		dest := filepath.Join(root, "2024", "3", "8.txt")
+
+		out, stderr, code := runWM(t, root, env, "move", "2024-03-07", "2024-03-08", "--dry-run")
+		gone := "would remove " + src
+		if trash {
+			gone = "would move " + src + " to " + trashDir
+		}
+		want := "would append " + src + " to " + dest + " under \"Moved from 2024-03-07\"\n" + gone + "\n"
+		if code != exitOK || string(out) != want {
+			t.Errorf("trash %v: move --dry-run = %q, %d (%s), want %q", trash, out, code, stderr, want)
+		}
+		assertEntry(t, dest, renderHeader(&mar8)+"already here\n")
+
+		if _, stderr, code := runWM(t, root, env, "move", "2024-03-07", "2024-03-08"); code != exitOK {
+			t.Fatalf("trash %v: move exited %d: %s", trash, code, stderr)
+		}
+		assertEntry(t, dest, renderHeader(&mar8)+"already here\n\n## Moved from 2024-03-07\n\nthe wrong day's notes\n")
+		if _, err := os.Stat(src); err == nil {
+			t.Errorf("trash %v: %s is still there after the merge", trash, src)
+		}
+		trashed := filepath.Join(root, trashDir, "2024", "3", "7.txt")
+		if _, err := os.Stat(trashed); (err == nil) != trash {
+			t.Errorf("trash %v: %s exists = %v", trash, trashed, err == nil)
+		}
+	}
+}
+
+func TestMoveRefuses(t *testing.T) {
+	root, _, env := testMoveRoot(t, map[DatePath]string{{2024, 3, 7}: "notes\n"})
+	before := treeOf(t, root)
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"move", "2024-03-01", "2024-03-08"}, "no entry for 2024-03-01"},
+		{[]string{"move", "2024-03-07", "2024-03-07"}, "are the same day"},
+		{[]string{"move", "2024-03-07", "someday"}, "bad <to>"},
+	} {
+		if _, stderr, code := runWM(t, root, env, tt.args...); code != exitFailure || !strings.Contains(string(stderr), tt.want) {
+			t.Errorf("wm %s exited %d (%s), want an error with %q", strings.Join(tt.args, " "), code, stderr, tt.want)
+		}
+	}
+	if after := treeOf(t, root); !reflect.DeepEqual(after, before) {
+		t.Errorf("a refused move changed the root: %v, was %v", after, before)
+	}
+}
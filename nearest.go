@@ -0,0 +1,70 @@
+package wm
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// defaultNearestEntryDays is how many days either side of a past date
+// without an entry are looked at for one to open instead, unless
+// nearest_entry_days says otherwise.
+const defaultNearestEntryDays = 3
+
+// nearestEntryDays is the nearest_entry_days setting, the default when
+// unset.  0 turns looking for a nearby entry off.
+func nearestEntryDays(cfg Configuration) int {
+	if cfg.NearestEntryDays != nil {
+		return *cfg.NearestEntryDays
+	}
+	return defaultNearestEntryDays
+}
+
+// nearestEntry returns the date and path of the existing entry nearest to
+// pd within days either side of it, the earlier of two as near.  Days after
+// today aren't looked at, so a past date never opens a future entry.  ok is
+// false when there is none.
+func nearestEntry(cfg Configuration, pd *DatePath, days int) (*DatePath, string, bool) {
+	today := datePathFromTime(dayNow())
+	for n := 1; n <= days; n++ {
+		for _, d := range []DatePath{pd.addDays(-n), pd.addDays(n)} {
+			if today.Before(&d) {
+				continue
+			}
+			target, err := topicEntryPath(cfg, &d, "")
+			if err != nil {
+				continue
+			}
+			target = archivedEntry(cfg, &d, "", target)
+			if info, err := os.Stat(target); err == nil && info.Mode().IsRegular() {
+				return &d, target, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// openNearest stands in the nearest existing entry for a past date given
+// without one, so that a misremembered day opens the entry that was meant
+// rather than an empty one.  When there is none, nothing is created for the
+// past date unless --create is given.  It leaves pd and target as they are
+// when the entry exists, for today and later, for a topic, and with
+// --create or --strict, which open the date given or nothing.
+func openNearest(cfg Configuration, params Parameters, pd *DatePath, target string) (*DatePath, string, error) {
+	days := nearestEntryDays(cfg)
+	if days <= 0 || params.Create || params.Strict || len(params.Topic) > 0 {
+		return pd, target, nil
+	}
+	if today := datePathFromTime(dayNow()); !pd.Before(&today) {
+		return pd, target, nil
+	}
+	if _, err := os.Stat(target); err == nil {
+		return pd, target, nil
+	}
+	near, path, ok := nearestEntry(cfg, pd, days)
+	if !ok {
+		return nil, "", withExitCode(exitNoEntry, fmt.Errorf("no entry for %s or within %d days of it; give --create to start one", pd.Iso(), days))
+	}
+	log.Printf(":::note::: no entry for %s; opening nearest: %s", pd.Iso(), near.Iso())
+	return near, path, nil
+}
@@ -0,0 +1,70 @@
+package wm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testToday fixes the clock at noon of the day given for the test.
+func testToday(t *testing.T, year int, month time.Month, day int) {
+	t.Helper()
+	clock, invoked = fixedClock(time.Date(year, month, day, 12, 0, 0, 0, time.Local)), time.Time{}
+	t.Cleanup(func() { clock, invoked = realClock{}, time.Time{} })
+}
+
+// testEntries writes an entry for each of dates under root.
+func testEntries(t *testing.T, root string, dates ...DatePath) {
+	t.Helper()
+	for _, pd := range dates {
+		p := filepath.Join(root, filepath.FromSlash(pd.String()))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(pd.Iso()+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNearestEntrySkipsFuture(t *testing.T) {
+	testToday(t, 2024, time.March, 7)
+	cfg := Configuration{Root: t.TempDir()}
+	// the day after the date asked for is after today, the one three
+	// days before isn't
+	testEntries(t, cfg.Root, DatePath{2024, 3, 8}, DatePath{2024, 3, 3})
+
+	near, _, ok := nearestEntry(cfg, &DatePath{2024, 3, 6}, 3)
+	if !ok || *near != (DatePath{2024, 3, 3}) {
+		t.Errorf("nearestEntry(2024-03-06) = %v, %v, want 2024-03-03", near, ok)
+	}
+	if near, _, ok := nearestEntry(cfg, &DatePath{2024, 3, 6}, 2); ok {
+		t.Errorf("nearestEntry(2024-03-06) within 2 days = %v, want none", near)
+	}
+}
+
+func TestOpenNearestRefusesToCreate(t *testing.T) {
+	testToday(t, 2024, time.March, 7)
+	cfg := Configuration{Root: t.TempDir()}
+	testEntries(t, cfg.Root, DatePath{2024, 3, 8})
+	pd := DatePath{2024, 3, 6}
+	target := filepath.Join(cfg.Root, filepath.FromSlash(pd.String()))
+
+	_, _, err := openNearest(cfg, Parameters{}, &pd, target)
+	if exitCode(err) != exitNoEntry {
+		t.Errorf("openNearest without an entry near = %v, want exit %d", err, exitNoEntry)
+	}
+	for _, params := range []Parameters{{Create: true}, {Strict: true}} {
+		got, path, err := openNearest(cfg, params, &pd, target)
+		if err != nil || *got != pd || path != target {
+			t.Errorf("openNearest with %+v = %v, %s, %v, want the date given", params, got, path, err)
+		}
+	}
+	// today is never redirected, nor created behind the user's back
+	today := DatePath{2024, 3, 7}
+	todayPath := filepath.Join(cfg.Root, filepath.FromSlash(today.String()))
+	if got, _, err := openNearest(cfg, Parameters{}, &today, todayPath); err != nil || *got != today {
+		t.Errorf("openNearest(today) = %v, %v, want today", got, err)
+	}
+}
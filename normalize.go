@@ -0,0 +1,100 @@
+package wm
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+)
+
+// normalizeOptions says how normalizeText rewrites an entry.
+type normalizeOptions struct {
+	// LineEnding ends every line, "\n" or "\r\n".
+	LineEnding string
+	// PreserveCodeBlocks leaves the trailing whitespace of lines inside
+	// fenced code blocks alone.
+	PreserveCodeBlocks bool
+}
+
+// lineEndings are the values of line_ending.
+var lineEndings = []string{"lf", "crlf", "native"}
+
+// normalizeOptionsFor returns the options of normalize set in cfg.
+func normalizeOptionsFor(cfg Configuration) (normalizeOptions, error) {
+	opts := normalizeOptions{LineEnding: "\n", PreserveCodeBlocks: cfg.PreserveCodeBlocks}
+	switch strings.ToLower(cfg.LineEnding) {
+	case "", "lf":
+	case "crlf":
+		opts.LineEnding = "\r\n"
+	case "native":
+		if runtime.GOOS == "windows" {
+			opts.LineEnding = "\r\n"
+		}
+	default:
+		return opts, fmt.Errorf(`config: "line_ending" must be lf, crlf, or native, got '%s'`, cfg.LineEnding)
+	}
+	return opts, nil
+}
+
+// isCodeFence reports whether line opens or closes a fenced code block.
+func isCodeFence(line string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	return len(line)-len(trimmed) < 4 && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"))
+}
+
+// normalizeText returns data with every line ended by opts.LineEnding and
+// stripped of trailing whitespace, and with blank lines at the end dropped
+// so that it ends in exactly one line ending.  Nothing stays nothing.
+func normalizeText(data []byte, opts normalizeOptions) []byte {
+	lines := strings.Split(string(data), "\n")
+	inCode := false
+	for i, l := range lines {
+		l = strings.TrimSuffix(l, "\r")
+		fence := isCodeFence(l)
+		if !(opts.PreserveCodeBlocks && inCode && !fence) {
+			l = strings.TrimRight(l, " \t\r\v\f")
+		}
+		if fence {
+			inCode = !inCode
+		}
+		lines[i] = l
+	}
+	for len(lines) > 0 && len(strings.TrimSpace(lines[len(lines)-1])) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return []byte{}
+	}
+	return []byte(strings.Join(lines, opts.LineEnding) + opts.LineEnding)
+}
+
+// normalizeEdited applies normalize to the entries at paths once the editor
+// is done with them, rewriting only those it changes.  A failure is noted
+// rather than returned, as the edit itself went through.
+func normalizeEdited(cfg Configuration, paths ...string) {
+	if !cfg.Normalize {
+		return
+	}
+	opts, err := normalizeOptionsFor(cfg)
+	if err != nil {
+		log.Println(":::note:::", err)
+		return
+	}
+	for _, p := range paths {
+		data, err := readEntry(cfg, p)
+		if err != nil {
+			log.Printf(":::note::: %s wasn't normalized: %v", p, err)
+			continue
+		}
+		out := normalizeText(data, opts)
+		if bytes.Equal(out, data) {
+			continue
+		}
+		if _, err := rewriteEntry(cfg, p, out, rewriteOptions{}); err != nil {
+			log.Printf(":::note::: %s wasn't normalized: %v", p, err)
+			continue
+		}
+		tracef("normalize: rewrote %s", p)
+	}
+}
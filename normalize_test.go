@@ -0,0 +1,82 @@
+package wm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNormalizeText(t *testing.T) {
+	lf := normalizeOptions{LineEnding: "\n"}
+	crlf := normalizeOptions{LineEnding: "\r\n"}
+	code := normalizeOptions{LineEnding: "\n", PreserveCodeBlocks: true}
+	tests := []struct {
+		name string
+		in   string
+		opts normalizeOptions
+		want string
+	}{
+		{"empty", "", lf, ""},
+		{"only blank lines", "\n \n\t\n", lf, ""},
+		{"already normal", "a\nb\n", lf, "a\nb\n"},
+		{"no final newline", "a\nb", lf, "a\nb\n"},
+		{"trailing blank lines", "a\n\n\n  \n", lf, "a\n"},
+		{"blank lines inside kept", "a\n\n\nb\n", lf, "a\n\n\nb\n"},
+		{"trailing whitespace", "a  \nb\t\nc \t \n", lf, "a\nb\nc\n"},
+		{"leading whitespace kept", "  a\n\tb\n", lf, "  a\n\tb\n"},
+		{"crlf to lf", "a\r\nb\r\n", lf, "a\nb\n"},
+		{"mixed to lf", "a\r\nb\nc\r\n", lf, "a\nb\nc\n"},
+		{"lf to crlf", "a\nb\n", crlf, "a\r\nb\r\n"},
+		{"mixed to crlf", "a\r\nb\n", crlf, "a\r\nb\r\n"},
+		{"trailing whitespace before crlf", "a \r\nb\t\r\n", crlf, "a\r\nb\r\n"},
+		{"stray carriage return", "a\r\r\nb\n", lf, "a\nb\n"},
+		{"code stripped when not preserved", "```\nx  \n```\n", lf, "```\nx\n```\n"},
+		{"code preserved", "text  \n```\nx  \n  y\t\n```  \nafter  \n", code, "text\n```\nx  \n  y\t\n```\nafter\n"},
+		{"tilde fence preserved", "~~~go\nx  \n~~~\n", code, "~~~go\nx  \n~~~\n"},
+		{"indented four is no fence", "    ```\nx  \n", code, "    ```\nx\n"},
+		{"unclosed fence", "```\nx  \n", code, "```\nx  \n"},
+		{"code with crlf", "```\r\nx  \r\n```\r\n", normalizeOptions{LineEnding: "\r\n", PreserveCodeBlocks: true}, "```\r\nx  \r\n```\r\n"},
+	}
+	for _, tt := range tests {
+		if got := string(normalizeText([]byte(tt.in), tt.opts)); got != tt.want {
+			t.Errorf("%s: normalizeText(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+		// normalizing is idempotent
+		if again := string(normalizeText([]byte(tt.want), tt.opts)); again != tt.want {
+			t.Errorf("%s: normalizing %q again = %q", tt.name, tt.want, again)
+		}
+	}
+}
+
+func TestNormalizeOptionsFor(t *testing.T) {
+	for setting, want := range map[string]string{"": "\n", "lf": "\n", "LF": "\n", "crlf": "\r\n"} {
+		opts, err := normalizeOptionsFor(Configuration{LineEnding: setting})
+		if err != nil || opts.LineEnding != want {
+			t.Errorf("line_ending %q: %q, %v, want %q", setting, opts.LineEnding, err, want)
+		}
+	}
+	if _, err := normalizeOptionsFor(Configuration{LineEnding: "cr"}); err == nil {
+		t.Error(`line_ending "cr" was taken`)
+	}
+}
+
+func TestNormalizeEditedOnlyRewritesChanges(t *testing.T) {
+	cfg := Configuration{Root: t.TempDir(), Normalize: true}
+	clean := filepath.Join(cfg.Root, "clean.txt")
+	dirty := filepath.Join(cfg.Root, "dirty.txt")
+	old := time.Date(2024, 3, 7, 18, 0, 0, 0, time.UTC)
+	for p, content := range map[string]string{clean: "fine\n", dirty: "tidy me  \r\n\n"} {
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	normalizeEdited(cfg, clean, dirty)
+	if info, err := os.Stat(clean); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("an entry needing nothing was rewritten: %v", err)
+	}
+	assertEntry(t, dirty, "tidy me\n")
+}
@@ -0,0 +1,74 @@
+package wm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Notebooks are separate logs kept in one configuration, each with its own
+// root and optionally its own editor and template:
+//
+//	default_notebook = "work"
+//
+//	[notebooks.work]
+//	root = "~/logs/work"
+//
+//	[notebooks.personal]
+//	root = "~/logs/personal"
+//	editor = "vim"
+//
+// -n/--notebook selects one for a run, default_notebook otherwise.  Unlike
+// profiles, notebooks share every other setting of the configuration.
+
+// notebook overrides the top-level settings of the same names; those left
+// unset are inherited.
+type notebook struct {
+	Root     string `toml:"root"`
+	Editor   string `toml:"editor"`
+	Template string `toml:"template"`
+}
+
+// notebookName is the notebook given with -n/--notebook, or "".
+var notebookName string
+
+// notebookNames returns the names of the notebooks of cfg in order.
+func notebookNames(cfg Configuration) []string {
+	names := make([]string, 0, len(cfg.Notebooks))
+	for name := range cfg.Notebooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectNotebook applies the settings of the notebook named, or of
+// default_notebook when name is "", to cfg.  Without either, cfg is left as
+// it is.
+func selectNotebook(cfg *Configuration, name string) error {
+	setting := "--notebook"
+	if len(name) == 0 {
+		name, setting = cfg.DefaultNotebook, "default_notebook"
+	}
+	if len(name) == 0 {
+		return nil
+	}
+	nb, ok := cfg.Notebooks[name]
+	if !ok {
+		names := notebookNames(*cfg)
+		if len(names) == 0 {
+			return fmt.Errorf("%s names notebook '%s', but no [notebooks] are defined", setting, name)
+		}
+		return fmt.Errorf("%s names notebook '%s', which isn't defined; the notebooks are %s", setting, name, strings.Join(names, ", "))
+	}
+	if len(nb.Root) > 0 {
+		cfg.Root = nb.Root
+	}
+	if len(nb.Editor) > 0 {
+		cfg.Editor = nb.Editor
+	}
+	if len(nb.Template) > 0 {
+		cfg.Template = nb.Template
+	}
+	return nil
+}
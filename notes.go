@@ -0,0 +1,243 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Note kinds are named notes that don't belong to one day, such as a file
+// per person for one-on-ones or one per week for retros, each kept where its
+// path template says under the root:
+//
+//	[notes.oneonone]
+//	path = "people/{{.Arg}}.md"
+//
+//	[notes.retro]
+//	path = "retros/{{.Year}}-W{{.ISOWeek}}.md"
+//	template = "~/templates/retro.md"
+//
+// "wm note <kind> [<arg>]" opens the note the template gives for today and
+// the argument, creating it first, from the kind's template if it has one.
+type noteKind struct {
+	Path     string `toml:"path"`
+	Template string `toml:"template"`
+}
+
+// noteData is what the path and the template of a note kind can refer to,
+// e.g. "{{.Arg}}".  Month and ISOWeek are two digits.
+type noteData struct {
+	Arg     string
+	Year    string
+	Month   string
+	ISOWeek string
+	Date    string
+}
+
+// noteDataFor returns the noteData of today and arg.
+func noteDataFor(arg string) noteData {
+	t := dayNow()
+	_, week := t.ISOWeek()
+	return noteData{
+		Arg:     arg,
+		Year:    fmt.Sprintf("%d", t.Year()),
+		Month:   fmt.Sprintf("%02d", int(t.Month())),
+		ISOWeek: fmt.Sprintf("%02d", week),
+		Date:    t.Format("2006-01-02"),
+	}
+}
+
+// noteKindNames returns the names of the note kinds of cfg in order.
+func noteKindNames(cfg Configuration) []string {
+	names := make([]string, 0, len(cfg.Notes))
+	for name := range cfg.Notes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// noteKindFor returns the kind called name, failing with the kinds there
+// are for one that isn't defined.
+func noteKindFor(cfg Configuration, name string) (noteKind, error) {
+	kind, ok := cfg.Notes[name]
+	if ok {
+		return kind, nil
+	}
+	if len(cfg.Notes) == 0 {
+		return kind, fmt.Errorf("no note kinds are defined; add one as [notes.%s] with a path", name)
+	}
+	return kind, fmt.Errorf("unknown note kind '%s'; defined kinds: %s", name, strings.Join(noteKindNames(cfg), ", "))
+}
+
+// renderNotePath returns the path, relative to the root, that the path
+// template of the kind called name gives for data.
+func renderNotePath(name string, kind noteKind, data noteData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(kind.Path)
+	if err != nil {
+		return "", fmt.Errorf("notes.%s: bad path: %w", name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("notes.%s: bad path: %w", name, err)
+	}
+	return filepath.Clean(filepath.FromSlash(b.String())), nil
+}
+
+// noteKindProblems returns what is wrong with the note kinds of cfg, for
+// validateConfig.
+func noteKindProblems(cfg Configuration) []string {
+	var problems []string
+	for _, name := range noteKindNames(cfg) {
+		kind := cfg.Notes[name]
+		if len(strings.TrimSpace(kind.Path)) == 0 {
+			problems = append(problems, fmt.Sprintf(`config: [notes.%s] needs a path`, name))
+			continue
+		}
+		rel, err := renderNotePath(name, kind, noteDataFor("x"))
+		if err != nil {
+			problems = append(problems, "config: "+err.Error())
+			continue
+		}
+		if filepath.IsAbs(rel) || rel == "." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || rel == ".." {
+			problems = append(problems, fmt.Sprintf(`config: [notes.%s] path '%s' must be a file under the root`, name, kind.Path))
+		}
+	}
+	return problems
+}
+
+// checkNoteArg rejects arguments that would put a note outside the
+// directory its path template names.
+func checkNoteArg(arg string) error {
+	if strings.ContainsAny(arg, `/\`) || strings.HasPrefix(arg, ".") {
+		return fmt.Errorf("'%s' can't be a note argument; it must be a name without slashes or a leading dot", arg)
+	}
+	return nil
+}
+
+// notePath returns the file of the note of the kind called name for arg,
+// which the kind's path must use exactly when it is given.
+func notePath(cfg Configuration, name string, kind noteKind, arg string) (string, error) {
+	if err := checkNoteArg(arg); err != nil {
+		return "", err
+	}
+	rel, err := renderNotePath(name, kind, noteDataFor(arg))
+	if err != nil {
+		return "", err
+	}
+	without, err := renderNotePath(name, kind, noteDataFor(""))
+	if err != nil {
+		return "", err
+	}
+	with, err := renderNotePath(name, kind, noteDataFor("x"))
+	if err != nil {
+		return "", err
+	}
+	switch usesArg := with != without; {
+	case usesArg && len(arg) == 0:
+		return "", fmt.Errorf("note kind '%s' needs an argument, as in wm note %s <arg>", name, name)
+	case !usesArg && len(arg) > 0:
+		return "", fmt.Errorf("note kind '%s' takes no argument", name)
+	}
+	path := filepath.Join(cfg.Root, rel)
+	if !insideDir(cfg.Root, path) {
+		return "", fmt.Errorf("notes.%s: '%s' isn't under the root", name, rel)
+	}
+	return path, nil
+}
+
+// noteContent returns the content of a new note of the kind called name:
+// its template rendered with data, or nothing without one.
+func noteContent(cfg Configuration, name string, kind noteKind, data noteData) ([]byte, error) {
+	if len(strings.TrimSpace(kind.Template)) == 0 {
+		return nil, nil
+	}
+	path := configRelative(cfg, kind.Template)
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("notes.%s: failed to read the template: %w", name, err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("notes.%s: template %s: %w", name, path, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("notes.%s: failed to render template %s: %w", name, path, err)
+	}
+	return []byte(b.String()), nil
+}
+
+// listNotes returns the notes of every kind there are under the root, found
+// by putting a wildcard for each field of the kind's path, as entries
+// labeled with the kind and the path.  Kinds whose path can't be made a
+// pattern are skipped with a note.
+func listNotes(cfg Configuration) []Entry {
+	all := noteData{"*", "*", "*", "*", "*"}
+	var found []Entry
+	seen := map[string]bool{}
+	for _, name := range noteKindNames(cfg) {
+		rel, err := renderNotePath(name, cfg.Notes[name], all)
+		if err != nil {
+			log.Printf(":::note::: skipping the notes of %s: %v", name, err)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(cfg.Root, rel))
+		if err != nil {
+			log.Printf(":::note::: skipping the notes of %s: %v", name, err)
+			continue
+		}
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil || !info.Mode().IsRegular() || seen[m] {
+				continue
+			}
+			seen[m] = true
+			label := m
+			if r, err := filepath.Rel(cfg.Root, m); err == nil {
+				label = filepath.ToSlash(r)
+			}
+			found = append(found, Entry{Path: m, ModTime: info.ModTime(), Note: name + " " + label})
+		}
+	}
+	return found
+}
+
+// runNote opens the note of the kind and argument given, creating it first
+// from the kind's template when it doesn't exist.
+func runNote(cfg Configuration, params Parameters) error {
+	kind, err := noteKindFor(cfg, params.NoteKind)
+	if err != nil {
+		return err
+	}
+	path, err := notePath(cfg, params.NoteKind, kind, params.NoteArg)
+	if err != nil {
+		return err
+	}
+	created := false
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		content, err := noteContent(cfg, params.NoteKind, kind, noteDataFor(params.NoteArg))
+		if err != nil {
+			return err
+		}
+		if err := initRootIfMissing(cfg); err != nil {
+			return fmt.Errorf("failed to create the root: %w", err)
+		}
+		if err := makeDir(cfg, filepath.Dir(path)); err != nil {
+			return fmt.Errorf("failed to create the directory of %s: %w", path, err)
+		}
+		if err := os.WriteFile(path, content, fileMode(cfg)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		created = true
+	} else if err != nil {
+		return err
+	}
+	return editFiles(cfg, fileEdit{Target: editTarget{Kind: kindNote, Created: created}, Paths: []string{path}})
+}
@@ -0,0 +1,138 @@
+package wm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const (
+	// defaultNotifyWithin is how far ahead "notify" looks without
+	// --due-within: what is due by tomorrow.
+	defaultNotifyWithin = "1d"
+	// notifyMaxItems is how many items a notification names before it
+	// only counts the rest.
+	notifyMaxItems = 5
+)
+
+// notifier posts a desktop notification.
+type notifier interface {
+	notify(title, body string) error
+}
+
+// commandNotifier posts notifications by running a command, which is given
+// the title and body as its last two arguments or, with fromEnv, reads them
+// from WM_NOTIFY_TITLE and WM_NOTIFY_BODY.  Neither is ever quoted for a
+// shell.
+type commandNotifier struct {
+	name    string
+	args    []string
+	fromEnv bool
+}
+
+func (n commandNotifier) notify(title, body string) error {
+	args := n.args
+	if !n.fromEnv {
+		args = append(append([]string{}, n.args...), title, body)
+	}
+	cmd := exec.Command(n.name, args...)
+	cmd.Env = append(os.Environ(), "WM_NOTIFY_TITLE="+title, "WM_NOTIFY_BODY="+body)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); len(msg) > 0 {
+			return fmt.Errorf("%s failed: %w: %s", n.name, err, msg)
+		}
+		return fmt.Errorf("%s failed: %w", n.name, err)
+	}
+	return nil
+}
+
+// windowsToast shows a toast through the Windows Runtime, which PowerShell
+// can reach without any module installed.
+const windowsToast = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$x = $t.GetElementsByTagName('text')
+$x.Item(0).AppendChild($t.CreateTextNode($env:WM_NOTIFY_TITLE)) > $null
+$x.Item(1).AppendChild($t.CreateTextNode($env:WM_NOTIFY_BODY)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('wm').Show([Windows.UI.Notifications.ToastNotification]::new($t))`
+
+// systemNotifier returns the notifier of the platform: notify-send on Linux
+// and the BSDs, osascript on macOS, and a PowerShell toast on Windows.
+var systemNotifier = func() (notifier, error) {
+	var n commandNotifier
+	switch runtime.GOOS {
+	case "darwin":
+		n = commandNotifier{"osascript", []string{"-e", "on run argv", "-e", "display notification (item 2 of argv) with title (item 1 of argv)", "-e", "end run"}, false}
+	case "windows":
+		n = commandNotifier{"powershell.exe", []string{"-NoProfile", "-NonInteractive", "-Command", windowsToast}, true}
+	default:
+		n = commandNotifier{"notify-send", []string{"--app-name=wm"}, false}
+	}
+	if _, err := exec.LookPath(n.name); err != nil {
+		return nil, fmt.Errorf("no notifier found: %w", err)
+	}
+	return n, nil
+}
+
+// notification is the title and body of the one notification posted for
+// g.
+func notification(g dueGroups) (string, string) {
+	var counts []string
+	for _, c := range []struct {
+		n    int
+		what string
+	}{{len(g.Overdue), "overdue"}, {len(g.Today), "due today"}, {len(g.Upcoming), "coming up"}} {
+		if c.n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", c.n, c.what))
+		}
+	}
+	var lines []string
+	all := append(append(append([]dueItem{}, g.Overdue...), g.Today...), g.Upcoming...)
+	for i, it := range all {
+		if i == notifyMaxItems {
+			lines = append(lines, fmt.Sprintf("and %d more", len(all)-i))
+			break
+		}
+		// the checkbox means nothing in a notification
+		lines = append(lines, fmt.Sprintf("%s %s", it.Due.Iso(), todoLikeRe.ReplaceAllString(it.Text, "$3")))
+	}
+	return "wm: " + strings.Join(counts, ", "), strings.Join(lines, "\n")
+}
+
+// runNotify posts a single notification of the open items that are overdue
+// or due within --due-within, for running from cron or a scheduled task.
+// The items are printed to standard output in any case, so that when the
+// notifier can't be reached the error still comes with them, in the mail
+// cron sends.
+func runNotify(cfg Configuration, params Parameters) error {
+	within := params.DueWithin
+	if len(within) == 0 {
+		within = defaultNotifyWithin
+	}
+	g, err := findDue(cfg, params, within)
+	if err != nil {
+		return err
+	}
+	if g.empty() {
+		fmt.Printf("nothing due within %s\n", within)
+		return nil
+	}
+	g.print(os.Stdout, cfg)
+	title, body := notification(g)
+	if params.DryRun {
+		fmt.Printf("would notify: %s\n", title)
+		for _, l := range strings.Split(body, "\n") {
+			fmt.Printf("  %s\n", l)
+		}
+		return nil
+	}
+	n, err := systemNotifier()
+	if err == nil {
+		err = n.notify(title, body)
+	}
+	if err != nil {
+		return fmt.Errorf("the items above were not notified: %w", err)
+	}
+	return nil
+}
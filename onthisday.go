@@ -0,0 +1,117 @@
+package wm
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// onThisDayLines is how many lines of each entry onthisday shows without
+// --full.
+const onThisDayLines = 3
+
+// isLeapYear reports whether year has a February 29.
+func isLeapYear(year int) bool {
+	return time.Date(year, time.February, 29, 0, 0, 0, 0, time.UTC).Month() == time.February
+}
+
+// onThisDay reports whether d is the day of an earlier year that stands for
+// pd: its month and day, or February 28 for February 29 in the years that
+// have none.
+func onThisDay(d, pd DatePath) bool {
+	if d.year >= pd.year {
+		return false
+	}
+	if pd.month == 2 && pd.day == 29 && !isLeapYear(d.year) {
+		return d.month == 2 && d.day == 28
+	}
+	return d.month == pd.month && d.day == pd.day
+}
+
+// yearsAgo says how long before pd the entry of d was, as "1 year ago".
+func yearsAgo(d, pd DatePath) string {
+	n := pd.year - d.year
+	if n == 1 {
+		return "1 year ago"
+	}
+	return fmt.Sprintf("%d years ago", n)
+}
+
+// firstLines returns up to n of the lines of body that aren't blank.
+func firstLines(body []byte, n int) []string {
+	var lines []string
+	for _, l := range strings.Split(string(body), "\n") {
+		l = strings.TrimRight(l, " \t\r")
+		if len(strings.TrimSpace(l)) == 0 {
+			continue
+		}
+		lines = append(lines, l)
+		if len(lines) == n {
+			break
+		}
+	}
+	return lines
+}
+
+// runOnThisDay looks back at the entries of the same day, today's by default,
+// in every earlier year under the root and the extra roots, the most recent
+// year first.  Each is printed as its date and first lines, whole with
+// --full, or with --open they are all opened in the editor at once.
+func runOnThisDay(cfg Configuration, params Parameters) error {
+	pd, err := parseDateString(cfg, strings.Join(params.DateWords, " "))
+	if err != nil {
+		return err
+	}
+	all, err := rootEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	var found []Entry
+	for _, e := range all {
+		if len(e.Topic) == 0 && onThisDay(e.Date, *pd) {
+			found = append(found, e)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[j].Date.Before(&found[i].Date) })
+	day := fmt.Sprintf("%s %d", monthName(pd.month), pd.day)
+	if len(found) == 0 {
+		fmt.Printf("no entries on %s in the years before %d\n", day, pd.year)
+		return nil
+	}
+	if pd.month == 2 && pd.day == 29 {
+		log.Printf(":::note::: February 29 is only in leap years; the other years show February 28")
+	}
+	if params.OpenEditor {
+		paths := make([]string, len(found))
+		dates := make([]*DatePath, len(found))
+		for i := range found {
+			paths[i] = found[i].Path
+			dates[i] = &found[i].Date
+		}
+		return editFiles(cfg, fileEdit{Target: editTarget{Kind: kindEntry, Date: dates[0]}, Paths: paths, Dates: dates})
+	}
+	for i, e := range found {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", e.Path, err)
+		}
+		if i > 0 && !params.Full {
+			fmt.Println()
+		}
+		fmt.Printf("=== %s (%s) ===\n", humanDate(e.Date), yearsAgo(e.Date, *pd))
+		if params.Full {
+			os.Stdout.Write(data)
+			if len(data) > 0 && data[len(data)-1] != '\n' {
+				fmt.Println()
+			}
+			continue
+		}
+		for _, l := range firstLines(stripHeader(data), onThisDayLines) {
+			fmt.Println("  " + l)
+		}
+	}
+	return nil
+}
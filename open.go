@@ -0,0 +1,187 @@
+package wm
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// openOutcome is what the open flow found or did, which decides its exit
+// status.
+type openOutcome struct {
+	Path    string
+	Created bool
+	Empty   bool
+}
+
+// openStatus maps the outcome of the open flow to its exit status.  Creating
+// the entry and finding it empty only fail when asked to with
+// --fail-if-created and --fail-if-empty; creation is checked first.
+func openStatus(o openOutcome, params Parameters) int {
+	switch {
+	case o.Created && params.FailIfCreated:
+		return exitCreated
+	case o.Empty && params.FailIfEmpty:
+		return exitEmpty
+	}
+	return exitOK
+}
+
+// resolveEntry reads the date and --topic given into the date and path of
+// the entry they name, which may not exist yet.  Opening a date and "path"
+// go through it, so that both read dates the same way.  An entry only an
+// extra root has is found there.
+func resolveEntry(cfg Configuration, params Parameters) (*DatePath, string, error) {
+	pd, m, err := parseDate(cfg, strings.Join(params.DateWords, " "))
+	if err != nil {
+		return nil, "", fmt.Errorf("error parsing date: %w", err)
+	}
+	if params.ExplainDate || params.Verbose {
+		explainDate(os.Stderr, pd, m)
+	}
+	if err := confirmParsedDate(cfg, pd, m, params.Yes); err != nil {
+		return nil, "", err
+	}
+	if len(params.Topic) > 0 {
+		if err := checkTopic(params.Topic); err != nil {
+			return nil, "", err
+		}
+	}
+	target, err := topicEntryPath(cfg, pd, params.Topic)
+	if err != nil {
+		return nil, "", err
+	}
+	target = archivedEntry(cfg, pd, params.Topic, target)
+	if _, err := os.Stat(target); err != nil && !params.Create {
+		archive, err := compressedEntry(cfg, pd, params.Topic)
+		if err != nil {
+			log.Println(":::note:::", err)
+		} else if len(archive) > 0 {
+			return nil, "", withExitCode(exitNoEntry, fmt.Errorf("the entry for %s is archived in %s; extract it to the root to open it, or give --create for a new one", pd.Iso(), displayPath(archive)))
+		}
+	}
+	tracef("entry: %s", target)
+	return pd, target, nil
+}
+
+// newEntryContent returns the content of an entry created for params: its
+// template, after asking about distant dates, unless --no-create, --strict,
+// or strict_create stop it.
+func newEntryContent(cfg Configuration, params Parameters) func(pd *DatePath) (string, error) {
+	tp := newTemplater(cfg, params.Template, params.Verbose)
+	return strictContent(cfg, params.Create, func(pd *DatePath) (string, error) {
+		if params.NoCreate || params.Strict {
+			return "", withExitCode(exitNoEntry, fmt.Errorf("no entry for %s", pd.Iso()))
+		}
+		if err := confirmDistantDate(cfg, pd, params.Yes); err != nil {
+			return "", err
+		}
+		return tp.content(pd)
+	})
+}
+
+// runPath prints the absolute path of the entry for the date given and
+// reports whether it exists.  Nothing is created unless --create is given,
+// when a missing entry is made from its template as opening it would.
+func runPath(cfg Configuration, params Parameters) (bool, error) {
+	pd, target, err := resolveEntry(cfg, params)
+	if err != nil {
+		return false, err
+	}
+	path := target
+	exists := true
+	if params.Create {
+		path, _, err = ensureEntryAt(cfg, target, pd, newEntryContent(cfg, params))
+		if err != nil {
+			return false, err
+		}
+	} else if _, err := os.Stat(target); err != nil {
+		exists = false
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	fmt.Println(displayPath(path))
+	return exists, nil
+}
+
+// runOpen is the default command: it opens the entry for the date given,
+// creating it from its template first unless --no-create is given.  A past
+// date without an entry opens the nearest one instead; see openNearest.  With
+// --print-path it prints the entry's path instead of starting the editor, and
+// --no-edit starts nothing at all, so the flow can be used as a cheap probe.
+// --read-only opens it without creating or changing it; see openReadOnly.
+// --dry-run only prints what would be done; see dryRunOpen.  A month or a
+// year given without a day opens its notes instead; see openPeriodNotes, and
+// a range such as 3/1..3/5 opens the entries of every day in it; see
+// openRange.
+func runOpen(cfg Configuration, params Parameters) (openOutcome, error) {
+	if words := strings.Join(params.DateWords, " "); isOpenRange(words) {
+		return openRange(cfg, params, words)
+	}
+	if pd, g, _, err := parseDateGranularity(cfg, strings.Join(params.DateWords, " ")); g != granularityDay {
+		if err != nil {
+			return openOutcome{}, fmt.Errorf("error parsing date: %w", err)
+		}
+		return openPeriodNotes(cfg, params, pd, g)
+	}
+	if dryRun {
+		return dryRunOpen(cfg, params)
+	}
+	if params.ReadOnly {
+		return openReadOnly(cfg, params)
+	}
+	pd, target, err := resolveEntry(cfg, params)
+	if err != nil {
+		return openOutcome{}, err
+	}
+	pd, target, err = openNearest(cfg, params, pd, target)
+	if err != nil {
+		return openOutcome{}, err
+	}
+	wmPath, created, err := ensureEntryAt(cfg, target, pd, newEntryContent(cfg, params))
+	if err != nil {
+		return openOutcome{}, err
+	}
+	out := openOutcome{Path: wmPath, Created: created}
+	data, err := readEntry(cfg, wmPath)
+	if err != nil {
+		return out, err
+	}
+	out.Empty = len(bytes.TrimSpace(stripHeader(data))) == 0
+
+	if params.PrintPath {
+		fmt.Println(displayPath(wmPath))
+	}
+	if params.PrintPath || params.NoEdit {
+		return out, nil
+	}
+	gapNotice(os.Stderr, cfg, pd)
+	line := 0
+	if len(params.At) > 0 || len(params.AtTag) > 0 {
+		line, err = jumpLine(cfg, wmPath, params)
+		switch {
+		case err != nil:
+			log.Println(":::note:::", err)
+		case line == 0:
+			log.Println(":::note::: nothing matching --at or --at-tag in", wmPath)
+		}
+	}
+	err = editFiles(cfg, fileEdit{
+		Target: editTarget{kindEntry, pd, created},
+		Paths:  []string{wmPath},
+		Dates:  []*DatePath{pd},
+		Topic:  params.Topic,
+		Line:   line,
+		Prepare: func() error {
+			if err := addSessionMarker(cfg, wmPath, pd, created); err != nil {
+				return fmt.Errorf("failed to add session marker: %w", err)
+			}
+			return nil
+		},
+	})
+	return out, err
+}
@@ -0,0 +1,127 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// defaultOpenRangeLimit is the most days a range is opened for without
+// --force, unless open_range_limit says otherwise.
+const defaultOpenRangeLimit = 31
+
+// openRangeLimit returns the configured open_range_limit, or the default.
+func openRangeLimit(cfg Configuration) int {
+	if cfg.OpenRangeLimit > 0 {
+		return cfg.OpenRangeLimit
+	}
+	return defaultOpenRangeLimit
+}
+
+// isOpenRange reports whether the date words given are a range to open,
+// such as 3/1/2024..3/5/2024.
+func isOpenRange(words string) bool {
+	return strings.Contains(words, "..")
+}
+
+// parseOpenRange reads the two ends of a range to open, split at its only
+// "..", the earlier first whichever way round they were given.
+func parseOpenRange(cfg Configuration, words string) (*DatePath, *DatePath, error) {
+	parts := strings.SplitN(words, "..", 2)
+	if strings.Contains(parts[1], "..") {
+		return nil, nil, fmt.Errorf("'%s' has more than one '..'; a range is <from>..<to>", words)
+	}
+	if len(strings.TrimSpace(parts[0])) == 0 || len(strings.TrimSpace(parts[1])) == 0 {
+		return nil, nil, fmt.Errorf("'%s' needs a date on both sides of '..'", words)
+	}
+	from, err := parseDateString(cfg, strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing the start of the range: %w", err)
+	}
+	to, err := parseDateString(cfg, strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing the end of the range: %w", err)
+	}
+	if to.Before(from) {
+		log.Printf(":::note::: %s is before %s; opening %s..%s", to.Iso(), from.Iso(), to.Iso(), from.Iso())
+		from, to = to, from
+	}
+	return from, to, nil
+}
+
+// openRange is the open flow for a range of dates: every day's entry is
+// created from its template as opening it would, or with --existing-only
+// only those already there are taken, and all of them are opened in one
+// run of the editor.  A range longer than open_range_limit needs --force,
+// so that a typo in a year doesn't create hundreds of entries.
+func openRange(cfg Configuration, params Parameters, words string) (openOutcome, error) {
+	if len(params.At) > 0 || len(params.AtTag) > 0 || params.ReadOnly {
+		return openOutcome{}, errors.New("--at, --at-tag, and --read-only open a single entry, not a range")
+	}
+	from, to, err := parseOpenRange(cfg, words)
+	if err != nil {
+		return openOutcome{}, err
+	}
+	if len(params.Topic) > 0 {
+		if err := checkTopic(params.Topic); err != nil {
+			return openOutcome{}, err
+		}
+	}
+	days := int(to.Time().Sub(from.Time()).Hours()/24+0.5) + 1
+	if limit := openRangeLimit(cfg); days > limit && !params.Force && !params.ExistingOnly {
+		return openOutcome{}, fmt.Errorf("%s..%s is %d days, more than open_range_limit (%d); give --force to open it anyway, or --existing-only to open only the entries there are", from.Iso(), to.Iso(), days, limit)
+	}
+
+	var out openOutcome
+	var paths []string
+	var dates []*DatePath
+	newEntry := newEntryContent(cfg, params)
+	for d := from.Time(); !d.After(to.Time()); d = d.AddDate(0, 0, 1) {
+		dp := datePathFromTime(d)
+		target, err := topicEntryPath(cfg, &dp, params.Topic)
+		if err != nil {
+			return out, err
+		}
+		if _, err := os.Stat(target); err != nil && params.ExistingOnly {
+			continue
+		}
+		if dryRun {
+			if _, err := os.Stat(target); err != nil {
+				fmt.Println("would create", displayPath(target))
+			}
+			paths = append(paths, target)
+			continue
+		}
+		path, created, err := ensureEntryAt(cfg, target, &dp, newEntry)
+		if err != nil {
+			return out, err
+		}
+		out.Created = out.Created || created
+		paths = append(paths, path)
+		dates = append(dates, &dp)
+	}
+	if len(paths) == 0 {
+		return out, withExitCode(exitNoEntry, fmt.Errorf("no entries between %s and %s", from.Iso(), to.Iso()))
+	}
+	out.Path = paths[0]
+	if dryRun {
+		fmt.Printf("would run %s\n", argvLine(editorCommand(cfg, editTarget{Kind: kindEntry, Date: from}, paths[0], paths...).Args))
+		return out, nil
+	}
+	if params.PrintPath {
+		for _, p := range paths {
+			fmt.Println(displayPath(p))
+		}
+	}
+	if params.PrintPath || params.NoEdit {
+		return out, nil
+	}
+	return out, editFiles(cfg, fileEdit{
+		Target: editTarget{Kind: kindEntry, Date: dates[0], Created: out.Created},
+		Paths:  paths,
+		Dates:  dates,
+		Topic:  params.Topic,
+	})
+}
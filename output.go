@@ -0,0 +1,99 @@
+package wm
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// plainOutput switches every command to linear output for screen readers:
+// "label: value" lines, words instead of glyphs, no drawing or color, and no
+// full-screen picker.  It is set by --plain or output = "plain".
+var plainOutput = false
+
+// setOutputMode validates the output key and selects plain output when it or
+// the --plain flag asks for it.
+func setOutputMode(mode string, flag bool) error {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "normal":
+		plainOutput = flag
+	case "plain":
+		plainOutput = true
+	default:
+		return fmt.Errorf("unknown output '%s', expected normal or plain", mode)
+	}
+	return nil
+}
+
+// noColor turns color off on terminals too.  It is set by --no-color; a
+// non-empty NO_COLOR in the environment does the same, as
+// https://no-color.org asks.
+var noColor = false
+
+// colorful reports whether stdout may use ANSI escapes.
+func colorful() bool {
+	return !plainOutput && !noColor && len(os.Getenv("NO_COLOR")) == 0 && stdoutTerminal()
+}
+
+// faint renders s in faint text when color is allowed.
+func faint(s string) string {
+	if !colorful() {
+		return s
+	}
+	return "\x1b[2m" + s + "\x1b[0m"
+}
+
+// highlight renders s, a match, in bold red, and heading renders s, a date
+// above search results, in bold cyan.  Unlike faint they don't check
+// colorful, which their callers have decided already.
+func highlight(s string) string {
+	return "\x1b[1;31m" + s + "\x1b[0m"
+}
+
+func heading(s string) string {
+	return "\x1b[1;36m" + s + "\x1b[0m"
+}
+
+// truncate shortens s to n runes, marking the cut with an ellipsis, or with
+// "..." in plain output.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if plainOutput {
+		return string(r[:n-3]) + "..."
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// pickPlain is the picker for plain output and for when there is no
+// terminal: a numbered list and a prompt for the numbers to choose, which
+// reads well with a screen reader.  Stdin closed without a reply chooses
+// nothing.
+func pickPlain(items []pickItem, prompt string, multi bool) ([]int, error) {
+	for i, it := range items {
+		fmt.Printf("%d: %s\n", i+1, it.Label)
+	}
+	question := prompt + " number"
+	if multi {
+		question = prompt + " numbers, separated by spaces"
+	}
+	reply := ask(question, "")
+	if len(reply) == 0 {
+		return nil, errPickCancelled
+	}
+	var chosen []int
+	for _, field := range strings.Fields(reply) {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(items) {
+			return nil, fmt.Errorf("'%s' is not one of the numbers listed", field)
+		}
+		chosen = append(chosen, n-1)
+		if !multi {
+			break
+		}
+	}
+	return chosen, nil
+}
@@ -0,0 +1,62 @@
+package wm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// TestPlainGolden checks the plain output of the commands with tables,
+// bars, and glyphs in normal output against testdata, and that it is
+// linear text without drawing or escapes.
+func TestPlainGolden(t *testing.T) {
+	root := t.TempDir()
+	cfg := Configuration{Root: root}
+	testEntryBody(t, cfg, DatePath{2024, 3, 7}, "met Ann about the parser #work\n")
+	testEntryBody(t, cfg, DatePath{2024, 3, 8}, "parser bug fixed\nno more box drawing\n")
+	testEntryBody(t, cfg, DatePath{2024, 3, 9}, "rest\n")
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WM_NOW=2024-03-10T09:00")
+	// output = "plain" in the configuration, and --plain with the default
+	plainFile, _ := testHome(t, root)
+	testConfigAppend(t, plainFile, "output = 'plain'\n")
+
+	for _, tt := range []struct {
+		golden string
+		args   []string
+	}{
+		{"plain_list.txt", []string{"list", "2024-03-01", "2024-03-31"}},
+		{"plain_stats.txt", []string{"stats"}},
+		{"plain_search.txt", []string{"search", "parser"}},
+	} {
+		for _, run := range []struct {
+			cfgFile string
+			args    []string
+		}{{plainFile, tt.args}, {cfgFile, append([]string{"--plain"}, tt.args...)}} {
+			out, stderr, code := runWM(t, root, append(env, "WMCFG="+run.cfgFile), run.args...)
+			if code != exitOK {
+				t.Fatalf("wm %q exited %d: %s", run.args, code, stderr)
+			}
+			out = bytes.ReplaceAll(out, []byte(root), []byte("ROOT"))
+			assertGolden(t, tt.golden, out)
+			if i := bytes.IndexFunc(out, func(r rune) bool { return r > unicode.MaxASCII || r == '\x1b' || r == '\t' }); i >= 0 {
+				t.Errorf("wm %q wrote %q, which isn't plain", run.args, out[i:])
+			}
+		}
+	}
+}
+
+func TestTruncatePlain(t *testing.T) {
+	if got := truncate("working memory", 8); got != "working…" {
+		t.Errorf("truncate = %q, want an ellipsis", got)
+	}
+	plainOutput = true
+	defer func() { plainOutput = false }()
+	if got := truncate("working memory", 8); got != "worki..." || strings.ContainsRune(got, '…') {
+		t.Errorf("plain truncate = %q, want three dots", got)
+	}
+	if got := truncate("short", 8); got != "short" {
+		t.Errorf("truncate of a short string = %q", got)
+	}
+}
@@ -0,0 +1,80 @@
+package wm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pageTokenVersion prefixes every page token, so that a token from a
+// version that orders or encodes differently is refused rather than
+// misread.
+const pageTokenVersion = "1"
+
+// pageKey is the place of an entry in a listing, newest first: by date, and
+// within a day the main entry first and then its topics by name.
+type pageKey struct {
+	Date  DatePath
+	Topic string
+}
+
+func keyOf(e Entry) pageKey {
+	return pageKey{e.Date, e.Topic}
+}
+
+// before reports whether k comes before o, newest first.
+func (k pageKey) before(o pageKey) bool {
+	if k.Date != o.Date {
+		return o.Date.Before(&k.Date)
+	}
+	return k.Topic < o.Topic
+}
+
+// encodePageToken returns the opaque token for continuing a listing after
+// k.  It holds k itself rather than a position, so that it means the same
+// to every process and entries added or removed since don't shift what
+// comes next.
+func encodePageToken(k pageKey) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(pageTokenVersion + " " + k.Date.Iso() + " " + k.Topic))
+}
+
+func decodePageToken(token string) (pageKey, error) {
+	bad := fmt.Errorf("bad page token '%s'", token)
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return pageKey{}, bad
+	}
+	parts := strings.SplitN(string(data), " ", 3)
+	if len(parts) != 3 || parts[0] != pageTokenVersion {
+		return pageKey{}, bad
+	}
+	t, err := time.ParseInLocation("2006-01-02", parts[1], time.Local)
+	if err != nil {
+		return pageKey{}, bad
+	}
+	return pageKey{datePathFromTime(t), parts[2]}, nil
+}
+
+// entryPage returns the entries, newest first, that follow token, or from
+// the newest when it is "", at most limit of them unless limit is 0.  next
+// is the token for the page after, "" when there is none.
+func entryPage(entries []Entry, token string, limit int) (page []Entry, next string, err error) {
+	sorted := append([]Entry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return keyOf(sorted[i]).before(keyOf(sorted[j])) })
+	start := 0
+	if len(token) > 0 {
+		after, err := decodePageToken(token)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(sorted), func(i int) bool { return after.before(keyOf(sorted[i])) })
+	}
+	page = sorted[start:]
+	if limit > 0 && len(page) > limit {
+		page = page[:limit]
+		next = encodePageToken(keyOf(page[limit-1]))
+	}
+	return page, next, nil
+}
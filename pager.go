@@ -0,0 +1,199 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// noPager keeps output off the pager.  It is set by --no-pager.
+var noPager = false
+
+// pagerOff are the values of pager that turn it off.
+var pagerOff = []string{"off", "never", "false", "none"}
+
+// pagerArgs returns the pager command line: the pager setting, $PAGER, or
+// less -R, more on Windows.  It returns nil when pager turns it off.
+func pagerArgs(cfg Configuration) ([]string, error) {
+	line := strings.TrimSpace(cfg.Pager)
+	if containsString(pagerOff, strings.ToLower(line)) {
+		return nil, nil
+	}
+	if len(line) == 0 {
+		line = strings.TrimSpace(os.Getenv("PAGER"))
+	}
+	if len(line) == 0 {
+		if runtime.GOOS == "windows" {
+			return []string{"more"}, nil
+		}
+		return []string{"less", "-R"}, nil
+	}
+	argv, err := splitCommandLine(line)
+	if err != nil || len(argv) == 0 {
+		return nil, fmt.Errorf(`config: "pager" '%s' can't be run`, line)
+	}
+	return argv, nil
+}
+
+// pagedOutput stands in for stdout while a command's output might go to the
+// pager.  Output is held until it is longer than the terminal, when the
+// pager is started and given it all, or until the command is done, when it
+// is written to the terminal as it is.  Once the pager has quit, whatever
+// else is written is read and dropped, so quitting it early never fails the
+// command.
+type pagedOutput struct {
+	stdout *os.File
+	pipe   *os.File
+	argv   []string
+	height int
+	done   chan struct{}
+
+	mu    sync.Mutex
+	held  bytes.Buffer
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+// pager is the pagedOutput in use, or nil.
+var pager *pagedOutput
+
+// pagedCommand reports whether the output of the command params gives is
+// paged: that of search, list, cat, and export to stdout, but not output
+// that keeps coming, such as search --follow.
+func pagedCommand(params Parameters) bool {
+	switch {
+	case params.Search:
+		return !params.Follow && !params.OpenEditor && !params.Quiet
+	case params.List:
+		return !params.Holidays && !params.Scratch
+	case params.Export:
+		return !params.Bundle && len(params.Out) == 0
+	}
+	return params.CatCmd
+}
+
+// stdoutTerminal reports whether stdout is a terminal, or stands in for one
+// while output may be paged.
+func stdoutTerminal() bool {
+	return pager != nil || term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// startPager puts stdout through the pager for the rest of the command when
+// stdout is a terminal and neither --no-pager nor pager turns it off.
+func startPager(cfg Configuration) {
+	if noPager || pager != nil || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return
+	}
+	argv, err := pagerArgs(cfg)
+	if err != nil || argv == nil {
+		return
+	}
+	_, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || height <= 0 {
+		return
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	p := &pagedOutput{stdout: os.Stdout, pipe: w, argv: argv, height: height, done: make(chan struct{})}
+	go p.read(r)
+	pager = p
+	os.Stdout = w
+	tracef("pager: %s once output is over %d lines", argvLine(argv), height-1)
+}
+
+// read takes the output from r until the command is done with it.
+func (p *pagedOutput) read(r *os.File) {
+	defer close(p.done)
+	defer r.Close()
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			p.write(buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// write holds data, or passes it to the pager once there is one.
+func (p *pagedOutput) write(data []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stdin != nil {
+		if _, err := p.stdin.Write(data); err != nil {
+			// the pager quit; drop what it would have shown
+			p.stdin.Close()
+			p.stdin = nopWriteCloser{io.Discard}
+		}
+		return
+	}
+	p.held.Write(data)
+	if bytes.Count(p.held.Bytes(), []byte("\n")) < p.height-1 {
+		return
+	}
+	cmd := exec.Command(p.argv[0], p.argv[1:]...)
+	cmd.Stdout, cmd.Stderr = p.stdout, os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		tracef("pager: %s didn't start: %v", argvLine(p.argv), err)
+		stdin = nopWriteCloser{p.stdout}
+	} else {
+		p.cmd = cmd
+	}
+	p.stdin = stdin
+	held := p.held.Bytes()
+	p.held.Reset()
+	if _, err := p.stdin.Write(held); err != nil {
+		p.stdin.Close()
+		p.stdin = nopWriteCloser{io.Discard}
+	}
+}
+
+// stopPager ends the output of the command: what is held is written out,
+// or the pager is waited for until it quits.
+func stopPager() {
+	p := pager
+	if p == nil {
+		return
+	}
+	pager = nil
+	os.Stdout = p.stdout
+	p.pipe.Close()
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stdin == nil {
+		p.stdout.Write(p.held.Bytes())
+		return
+	}
+	p.stdin.Close()
+	if p.cmd != nil {
+		// Ctrl-C is the pager's to handle while it runs
+		signal.Ignore(os.Interrupt)
+		var exitErr *exec.ExitError
+		if err := p.cmd.Wait(); err != nil && !errors.As(err, &exitErr) {
+			tracef("pager: %v", err)
+		}
+	}
+}
+
+// nopWriteCloser is a writer whose Close does nothing.
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,37 @@
+package wm
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// explainDate writes how the date words were read and what they resolved to,
+// for --explain-date and -v.
+func explainDate(w io.Writer, pd *DatePath, m dateMatch) {
+	input := m.Input
+	if len(input) == 0 {
+		input = "(nothing, so today)"
+	}
+	fmt.Fprintf(w, "date: '%s' matched %s: %s, %s\n", input, m.Rule, pd.Iso(), weekdayName(pd.Time().Weekday()))
+	if m.Other != nil {
+		fmt.Fprintf(w, "date: with day and month swapped it would be %s, %s\n", m.Other.Iso(), weekdayName(m.Other.Time().Weekday()))
+	}
+}
+
+// confirmParsedDate asks for a key press before a date read by a numeric
+// layout is used when confirm_parsed_date is set and the day and month could
+// have been meant the other way around.  --yes and a non-interactive stdin
+// skip the question.
+func confirmParsedDate(cfg Configuration, pd *DatePath, m dateMatch, yes bool) error {
+	if !cfg.ConfirmParsedDate || m.Other == nil || yes || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	question := fmt.Sprintf("'%s' read as %s, %s, not %s; continue?", m.Input, pd.Iso(), weekdayName(pd.Time().Weekday()), m.Other.Iso())
+	if !confirmKey(question) {
+		return fmt.Errorf("'%s' not confirmed as %s", m.Input, pd.Iso())
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Besides its days, a month and a year can have notes of their own, opened
+// by giving the month or the year without a day, as "2024-03", "3/2024",
+// "march 2024", or "2024".  They are kept as root/2024/3/month.txt and
+// root/2024/year.txt, which no command taking dates counts as entries.
+
+// dateGranularity is how much of a date was given: a day, or only its month
+// or year.
+type dateGranularity int
+
+const (
+	granularityDay dateGranularity = iota
+	granularityMonth
+	granularityYear
+)
+
+// monthFilename is the month_filename setting, the name of the notes file in
+// each month's directory.  The year's is "year" with the same extension.
+var monthFilename = "month.txt"
+
+// setMonthFilename validates and selects the month_filename setting.  The
+// name must be a plain file name that can't be taken for a day's entry.
+func setMonthFilename(name string) error {
+	if len(name) == 0 {
+		name = "month.txt"
+	}
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." || len(stem) == 0 {
+		return fmt.Errorf("month_filename '%s' must be a file name, such as month.txt", name)
+	}
+	if _, err := strconv.Atoi(stem); err == nil {
+		return fmt.Errorf("month_filename '%s' would be read as a day's entry", name)
+	}
+	monthFilename = name
+	return nil
+}
+
+// yearFilename is the name of a year's notes file.
+func yearFilename() string {
+	return "year" + filepath.Ext(monthFilename)
+}
+
+// parseDateGranularity is parseDate for input that may also be a month or a
+// year without a day.  Those return the first day of the month or year.
+// Only forms no day layout reads are taken for a month: "3/2024" is March,
+// while "3/2/2024" stays a day, and a month name needs its year.
+func parseDateGranularity(cfg Configuration, in string) (*DatePath, dateGranularity, dateMatch, error) {
+	in = strings.ToLower(strings.TrimSpace(in))
+	m := dateMatch{Input: in}
+	year, month := 0, 0
+	if yearRe.MatchString(in) {
+		year, _ = strconv.Atoi(in)
+		m.Rule = "a year"
+		return &DatePath{year: year, month: 1, day: 1}, granularityYear, m, nil
+	}
+	if g := isoMonthRe.FindStringSubmatch(in); g != nil {
+		year, _ = strconv.Atoi(g[1])
+		month, _ = strconv.Atoi(g[2])
+	} else if g := monthYearRe.FindStringSubmatch(in); g != nil {
+		month, _ = strconv.Atoi(g[1])
+		year, _ = strconv.Atoi(g[2])
+	} else if g := namedMonthRe.FindStringSubmatch(in); g != nil && len(g[2]) > 0 {
+		if n, err := resolveMonthName(g[1]); err == nil {
+			month = n
+			year, _ = strconv.Atoi(g[2])
+		}
+	}
+	if year == 0 {
+		pd, dm, err := parseDate(cfg, in)
+		return pd, granularityDay, dm, err
+	}
+	if month < 1 || month > 12 {
+		return nil, granularityMonth, m, fmt.Errorf("'%s' has no month %d", in, month)
+	}
+	m.Rule = "a month"
+	return &DatePath{year: year, month: month, day: 1}, granularityMonth, m, nil
+}
+
+// periodNotesPath returns the path of the notes of the month or year of pd.
+func periodNotesPath(cfg Configuration, pd *DatePath, g dateGranularity) string {
+	year := filepath.Join(cfg.Root, strconv.Itoa(pd.year))
+	if g == granularityYear {
+		return filepath.Join(year, yearFilename())
+	}
+	return filepath.Join(year, strconv.Itoa(pd.month), monthFilename)
+}
+
+// periodName names the month or year of pd, as "March 2024" or "2024".
+func periodName(pd *DatePath, g dateGranularity) string {
+	if g == granularityMonth {
+		return fmt.Sprintf("%s %d", monthName(pd.month), pd.year)
+	}
+	return strconv.Itoa(pd.year)
+}
+
+// periodHeader is the header of new notes of a month or year, which names
+// the period where an entry's header has its date.
+func periodHeader(pd *DatePath, g dateGranularity) string {
+	return fmt.Sprintf("%s\n%s\n-------------------\n\n", headerTitle, periodName(pd, g))
+}
+
+// openPeriodNotes is the open flow for a month or a year: its notes are
+// created with their header if missing, unless --no-create is given, and
+// opened.  --print-path, --no-edit, and --dry-run work as for a day; a
+// topic or --read-only can't be given.
+func openPeriodNotes(cfg Configuration, params Parameters, pd *DatePath, g dateGranularity) (openOutcome, error) {
+	t := editTarget{Kind: kindMonthNotes}
+	if g == granularityYear {
+		t.Kind = kindYearNotes
+	}
+	if len(params.Topic) > 0 || params.ReadOnly {
+		return openOutcome{}, fmt.Errorf("notes of %s take neither --topic nor --read-only", periodName(pd, g))
+	}
+	path := periodNotesPath(cfg, pd, g)
+	tracef("notes: %s", path)
+	out := openOutcome{Path: path}
+	data, err := readEntry(cfg, path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if params.NoCreate {
+			return out, withExitCode(exitNoEntry, fmt.Errorf("no notes for %s", periodName(pd, g)))
+		}
+		out.Created, out.Empty = true, true
+		if dryRun {
+			fmt.Printf("would create %s\n", displayPath(path))
+			break
+		}
+		if err := makeDir(cfg, filepath.Dir(path)); err != nil {
+			return out, err
+		}
+		sealed, err := sealEntry(cfg, path, []byte(periodHeader(pd, g)))
+		if err != nil {
+			return out, err
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, fileMode(cfg))
+		if err != nil {
+			return out, err
+		}
+		_, err = f.Write(sealed)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+			return out, err
+		}
+	case err != nil:
+		return out, err
+	default:
+		out.Empty = len(strings.TrimSpace(string(stripHeader(data)))) == 0
+	}
+
+	if params.PrintPath {
+		fmt.Println(displayPath(path))
+	}
+	if params.PrintPath || params.NoEdit {
+		return out, nil
+	}
+	t.Created = out.Created
+	if dryRun {
+		fmt.Printf("would run %s\n", argvLine(editorCommand(cfg, t, path, path).Args))
+		return out, nil
+	}
+	return out, editFiles(cfg, fileEdit{Target: t, Paths: []string{path}})
+}
@@ -0,0 +1,110 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultDirMode  fs.FileMode = 0o755
+	defaultFileMode fs.FileMode = 0o644
+)
+
+// permSetting is a permission setting such as dir_mode, given as a TOML
+// octal integer (0o700) or a string ("0700", "0o700", or "700").  Zero means
+// unset.  The process umask still applies on top.
+type permSetting fs.FileMode
+
+func (p *permSetting) UnmarshalTOML(v interface{}) error {
+	var n uint64
+	switch v := v.(type) {
+	case int64:
+		if v < 0 {
+			return fmt.Errorf("permission %d is negative", v)
+		}
+		n = uint64(v)
+	case string:
+		var err error
+		n, err = strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(v), "0o"), 8, 32)
+		if err != nil {
+			return fmt.Errorf("permission '%s' is not an octal number such as 0o700", v)
+		}
+	default:
+		return fmt.Errorf("permission %v is not an octal number such as 0o700", v)
+	}
+	if n == 0 || n > 0o777 {
+		return fmt.Errorf("permission %#o is not between 0o001 and 0o777", n)
+	}
+	*p = permSetting(n)
+	return nil
+}
+
+// dirMode is the permission of the directories wm creates for entries.
+func dirMode(cfg Configuration) fs.FileMode {
+	if cfg.DirMode != 0 {
+		return fs.FileMode(cfg.DirMode)
+	}
+	return defaultDirMode
+}
+
+// fileMode is the permission of the entries and other files wm creates.
+func fileMode(cfg Configuration) fs.FileMode {
+	if cfg.FileMode != 0 {
+		return fs.FileMode(cfg.FileMode)
+	}
+	return defaultFileMode
+}
+
+// makeDir creates dir and its parents with dirMode.  Directories under the
+// root that can't be entered, such as the ones earlier versions created with
+// no permission bits at all, are repaired to dirMode on the way, each with a
+// note; one that can't be repaired is named in the error.
+func makeDir(cfg Configuration, dir string) error {
+	mode := dirMode(cfg)
+	err := os.MkdirAll(dir, mode)
+	if err == nil || errors.Is(err, fs.ErrPermission) {
+		if rerr := repairDirs(cfg.Root, dir, mode); rerr != nil {
+			return rerr
+		}
+	}
+	if errors.Is(err, fs.ErrPermission) {
+		err = os.MkdirAll(dir, mode)
+	}
+	return err
+}
+
+// repairDirs gives every existing directory from root down to dir that its
+// owner can't enter the permission mode.  Directories outside root are left
+// alone.
+func repairDirs(root, dir string, mode fs.FileMode) error {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || !insideDir(root, dir) {
+		return nil
+	}
+	p := root
+	parts := []string{""}
+	if rel != "." {
+		parts = append(parts, strings.Split(rel, string(filepath.Separator))...)
+	}
+	for _, part := range parts {
+		p = filepath.Join(p, part)
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() || info.Mode().Perm()&0o100 != 0 {
+			continue
+		}
+		if err := os.Chmod(p, mode); err != nil {
+			return fmt.Errorf("directory %s has mode %#o, which can't be entered, and repairing it failed: %w", p, info.Mode().Perm(), err)
+		}
+		log.Printf(":::note::: repaired directory %s, whose mode was %#o, to %#o", p, info.Mode().Perm(), mode)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package wm
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+// assertMode checks the permission bits of the file at path.  Bits the
+// umask clears are allowed to be missing.
+func assertMode(t *testing.T, path string, want fs.FileMode) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := info.Mode().Perm()
+	if got&^want != 0 || got&0o700 != want&0o700 {
+		t.Errorf("%s has mode %#o, want %#o", path, got, want)
+	}
+}
+
+func TestMakeDirMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no permission bits")
+	}
+	cfg := Configuration{Root: t.TempDir()}
+	dir := filepath.Join(cfg.Root, "2024", "3")
+	if err := makeDir(cfg, dir); err != nil {
+		t.Fatal(err)
+	}
+	assertMode(t, filepath.Join(cfg.Root, "2024"), defaultDirMode)
+	assertMode(t, dir, defaultDirMode)
+
+	cfg.DirMode = 0o700
+	private := filepath.Join(cfg.Root, "2025", "1")
+	if err := makeDir(cfg, private); err != nil {
+		t.Fatal(err)
+	}
+	assertMode(t, private, 0o700)
+}
+
+func TestMakeDirRepairs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no permission bits")
+	}
+	cfg := Configuration{Root: t.TempDir()}
+	// what os.MkdirAll(dir, fs.ModeDir) used to leave
+	broken := filepath.Join(cfg.Root, "2024")
+	if err := os.Mkdir(broken, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(broken, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(broken, 0o755) })
+
+	dir := filepath.Join(broken, "3")
+	if err := makeDir(cfg, dir); err != nil {
+		t.Fatalf("makeDir under a directory with mode 0: %v", err)
+	}
+	assertMode(t, broken, defaultDirMode)
+	assertMode(t, dir, defaultDirMode)
+
+	// directories outside the root are never touched
+	outside := filepath.Join(t.TempDir(), "other")
+	if err := os.Mkdir(outside, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(outside, 0o755) })
+	if err := repairDirs(cfg.Root, outside, defaultDirMode); err != nil {
+		t.Fatal(err)
+	}
+	assertMode(t, outside, 0o600)
+}
+
+func TestPermSetting(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want fs.FileMode
+	}{
+		{"dir_mode = 0o700", 0o700},
+		{`dir_mode = "0700"`, 0o700},
+		{`dir_mode = "0o750"`, 0o750},
+		{`dir_mode = "700"`, 0o700},
+	} {
+		var cfg Configuration
+		if _, err := toml.Decode(tt.in, &cfg); err != nil || dirMode(cfg) != tt.want {
+			t.Errorf("%s = %#o, %v, want %#o", tt.in, dirMode(cfg), err, tt.want)
+		}
+	}
+	for _, in := range []string{"dir_mode = -1", `dir_mode = "rwx"`, "dir_mode = 0o1000", `dir_mode = "0"`} {
+		var cfg Configuration
+		if _, err := toml.Decode(in, &cfg); err == nil {
+			t.Errorf("%s was taken as %#o", in, dirMode(cfg))
+		}
+	}
+	if dirMode(Configuration{}) != defaultDirMode || fileMode(Configuration{}) != defaultFileMode {
+		t.Error("unset modes aren't the defaults")
+	}
+}
@@ -0,0 +1,79 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pickItemsForEntries builds picker rows showing the date, weekday, and a
+// preview of each entry, newest first.  The filter text additionally carries
+// the entry's tags so they can be typed even when they aren't in the preview.
+func pickItemsForEntries(cfg Configuration, entries []Entry) []pickItem {
+	items := make([]pickItem, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			data = nil
+		}
+		preview := entryPreview(data)
+		preview = truncate(preview, 60)
+		label := fmt.Sprintf("%s  %s  %s", e.Date.Iso(), padRunes(weekdayName(e.Date.Time().Weekday()), weekdayWidth(0)), preview)
+		tags := entryTags(data)
+		items = append(items, pickItem{
+			Label:  label,
+			Filter: label + " " + strings.Join(tags, " "),
+		})
+	}
+	return items
+}
+
+// recentEntries returns the entries of the last days days up to today, in
+// date order.
+func recentEntries(entries []Entry, days int) []Entry {
+	today := datePathFromTime(dayNow())
+	from := today.addDays(1 - days)
+	var recent []Entry
+	for _, e := range entries {
+		if e.Date.Before(&from) || today.Before(&e.Date) {
+			continue
+		}
+		recent = append(recent, e)
+	}
+	return recent
+}
+
+// runPick lets the user choose one or more of the entries of the last --days
+// days and opens them in the editor.
+func runPick(cfg Configuration, params Parameters) error {
+	days, err := strconv.Atoi(params.Days)
+	if err != nil || days < 1 {
+		return fmt.Errorf("--days must be a positive number of days, not '%s'", params.Days)
+	}
+	entries, err := listEntries(cfg, walkOptions{})
+	if err != nil {
+		return err
+	}
+	entries = recentEntries(entries, days)
+	if len(entries) == 0 {
+		return fmt.Errorf("no entries in the last %d days under %s", days, cfg.Root)
+	}
+	items := pickItemsForEntries(cfg, entries)
+	chosen, err := pick(items, "entry", true)
+	if errors.Is(err, errPickCancelled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var paths []string
+	var dates []*DatePath
+	for _, idx := range chosen {
+		e := entries[len(entries)-1-idx]
+		paths = append(paths, e.Path)
+		dates = append(dates, &e.Date)
+	}
+	return editFiles(cfg, fileEdit{Target: editTarget{Kind: kindEntry, Date: dates[0]}, Paths: paths, Dates: dates})
+}
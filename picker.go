@@ -0,0 +1,243 @@
+package wm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// pickItem is a single row offered by the picker.  Label is what gets drawn,
+// and Filter is the text the typed query is fuzzy matched against.
+type pickItem struct {
+	Label  string
+	Filter string
+}
+
+// errPickCancelled is returned when the user leaves the picker without
+// choosing anything.
+var errPickCancelled = errors.New("selection cancelled")
+
+// fuzzyScore reports whether every rune of query appears in text in order,
+// ignoring case, and returns the width of the span that contained the match.
+// Smaller spans are better matches.
+func fuzzyScore(query string, text string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 {
+		return 0, true
+	}
+	start, qi := -1, 0
+	for i, r := range []rune(strings.ToLower(text)) {
+		if r != q[qi] {
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		qi++
+		if qi == len(q) {
+			return i - start + 1, true
+		}
+	}
+	return 0, false
+}
+
+// filterItems returns the indexes of the items matching every whitespace
+// separated word of query, best match first and otherwise in their original
+// order.
+func filterItems(items []pickItem, query string) []int {
+	words := strings.Fields(query)
+	type scored struct{ idx, score int }
+	var matches []scored
+	for i, it := range items {
+		total, ok := 0, true
+		for _, w := range words {
+			s, found := fuzzyScore(w, it.Filter)
+			if !found {
+				ok = false
+				break
+			}
+			total += s
+		}
+		if ok {
+			matches = append(matches, scored{i, total})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score < matches[j].score })
+	out := make([]int, len(matches))
+	for i, m := range matches {
+		out[i] = m.idx
+	}
+	return out
+}
+
+// pick shows an interactive fuzzy-filterable list and returns the indexes of
+// the chosen items.  Typing filters the list, the arrow keys (or ctrl-p and
+// ctrl-n) move the cursor, Enter accepts, and when multi is set Tab toggles
+// the item under the cursor so several can be chosen at once.  Plain output,
+// and stdin or stdout that isn't a terminal, such as over ssh without a
+// pseudo-terminal, ask for the numbers of a listed item instead, which
+// "echo 2 | wm pick" answers too.
+func pick(items []pickItem, prompt string, multi bool) ([]int, error) {
+	inFd, outFd := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if plainOutput || !term.IsTerminal(inFd) || !term.IsTerminal(outFd) {
+		return pickPlain(items, prompt, multi)
+	}
+	state, err := term.MakeRaw(inFd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put the terminal in raw mode: %w", err)
+	}
+	defer term.Restore(inFd, state)
+
+	_, height, err := term.GetSize(outFd)
+	if err != nil || height < 3 {
+		height = 24
+	}
+	p := &picker{
+		items:    items,
+		prompt:   prompt,
+		multi:    multi,
+		rows:     height - 2,
+		selected: map[int]bool{},
+		out:      os.Stdout,
+	}
+	defer fmt.Fprint(os.Stdout, "\x1b[2J\x1b[H")
+	return p.run(bufio.NewReader(os.Stdin))
+}
+
+type picker struct {
+	items    []pickItem
+	prompt   string
+	multi    bool
+	rows     int
+	query    string
+	visible  []int
+	cursor   int
+	offset   int
+	selected map[int]bool
+	out      io.Writer
+}
+
+func (p *picker) refilter() {
+	p.visible = filterItems(p.items, p.query)
+	p.cursor, p.offset = 0, 0
+}
+
+func (p *picker) move(delta int) {
+	p.cursor += delta
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+	if p.cursor >= len(p.visible) {
+		p.cursor = len(p.visible) - 1
+	}
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+p.rows {
+		p.offset = p.cursor - p.rows + 1
+	}
+}
+
+func (p *picker) draw() {
+	var b strings.Builder
+	b.WriteString("\x1b[2J\x1b[H")
+	fmt.Fprintf(&b, "%s> %s\r\n", p.prompt, p.query)
+	fmt.Fprintf(&b, "  %d/%d", len(p.visible), len(p.items))
+	if p.multi && len(p.selected) > 0 {
+		fmt.Fprintf(&b, " (%d selected)", len(p.selected))
+	}
+	b.WriteString("\r\n")
+	for i := p.offset; i < len(p.visible) && i < p.offset+p.rows; i++ {
+		marker, sel := " ", " "
+		if i == p.cursor {
+			marker = ">"
+		}
+		if p.selected[p.visible[i]] {
+			sel = "*"
+		}
+		fmt.Fprintf(&b, "%s%s %s\r\n", marker, sel, p.items[p.visible[i]].Label)
+	}
+	fmt.Fprintf(&b, "\x1b[1;%dH", utf8.RuneCountInString(p.prompt)+3+utf8.RuneCountInString(p.query))
+	fmt.Fprint(p.out, b.String())
+}
+
+func (p *picker) run(in *bufio.Reader) ([]int, error) {
+	p.refilter()
+	for {
+		p.draw()
+		r, _, err := in.ReadRune()
+		if err != nil {
+			return nil, errPickCancelled
+		}
+		switch r {
+		case 3, 4: // ctrl-c, ctrl-d
+			return nil, errPickCancelled
+		case 27: // escape sequences and bare escape
+			if in.Buffered() == 0 {
+				return nil, errPickCancelled
+			}
+			seq := make([]byte, 2)
+			if _, err := io.ReadFull(in, seq); err != nil {
+				return nil, errPickCancelled
+			}
+			if seq[0] == '[' || seq[0] == 'O' {
+				switch seq[1] {
+				case 'A':
+					p.move(-1)
+				case 'B':
+					p.move(1)
+				}
+			}
+		case 16: // ctrl-p
+			p.move(-1)
+		case 14: // ctrl-n
+			p.move(1)
+		case '\t':
+			if p.multi && len(p.visible) > 0 {
+				idx := p.visible[p.cursor]
+				if p.selected[idx] {
+					delete(p.selected, idx)
+				} else {
+					p.selected[idx] = true
+				}
+				p.move(1)
+			}
+		case '\r', '\n':
+			if len(p.selected) > 0 {
+				var chosen []int
+				for idx := range p.items {
+					if p.selected[idx] {
+						chosen = append(chosen, idx)
+					}
+				}
+				return chosen, nil
+			}
+			if len(p.visible) == 0 {
+				continue
+			}
+			return []int{p.visible[p.cursor]}, nil
+		case 127, 8: // backspace
+			if len(p.query) > 0 {
+				_, size := utf8.DecodeLastRuneInString(p.query)
+				p.query = p.query[:len(p.query)-size]
+				p.refilter()
+			}
+		case 21: // ctrl-u
+			p.query = ""
+			p.refilter()
+		default:
+			if unicode.IsPrint(r) {
+				p.query += string(r)
+				p.refilter()
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package wm
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Profiles are separate configurations, each with its own root, such as one
+// for work and one for personal notes.  They are listed in the active
+// configuration by name:
+//
+//	[profiles]
+//	work = "~/.config/wm/work.toml"
+//	personal = "~/.config/wm/personal.toml"
+//
+// search --all-profiles reads the roots of every one of them with their own
+// settings.
+
+// profile is a configuration listed under [profiles].
+type profile struct {
+	Name string
+	Cfg  Configuration
+}
+
+// loadProfile reads the configuration of a profile without applying any of
+// its settings to the running process, unlike readConfig.
+func loadProfile(cfg Configuration, name, file string) (profile, error) {
+	path := configRelative(cfg, file)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return profile{}, err
+	}
+	var pcfg Configuration
+	if _, err := toml.Decode(string(data), &pcfg); err != nil {
+		return profile{}, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	pcfg.file, pcfg.dir = path, filepath.Dir(path)
+	pcfg.Root = expandPath(pcfg.Root)
+	return profile{Name: name, Cfg: pcfg}, nil
+}
+
+// listProfiles returns the profiles of cfg by name.  Profiles whose
+// configuration can't be read or whose root is missing are skipped with a
+// note.  If the active configuration's root isn't among them, it is added as
+// "current".
+func listProfiles(cfg Configuration) []profile {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var profiles []profile
+	seen := map[string]bool{}
+	for _, name := range names {
+		p, err := loadProfile(cfg, name, cfg.Profiles[name])
+		if err != nil {
+			log.Printf(":::note::: skipping profile %s: %v", name, err)
+			continue
+		}
+		if info, err := os.Stat(p.Cfg.Root); err != nil || !info.IsDir() {
+			log.Printf(":::note::: skipping profile %s: its root %s is unavailable", name, p.Cfg.Root)
+			continue
+		}
+		seen[rootKey(p.Cfg.Root)] = true
+		profiles = append(profiles, p)
+	}
+	if !seen[rootKey(cfg.Root)] {
+		profiles = append(profiles, profile{Name: "current", Cfg: cfg})
+	}
+	return profiles
+}
+
+// profileEntries returns the entries of every profile, and with attachments
+// their attachments, in date order across profiles.  Each root is walked
+// with its own profile's path_layout.
+func profileEntries(cfg Configuration, params Parameters) ([]Entry, error) {
+	var all []Entry
+	for _, p := range listProfiles(cfg) {
+		if _, _, err := configuredLayout(p.Cfg); err != nil {
+			log.Printf(":::note::: skipping profile %s: %v", p.Name, err)
+			continue
+		}
+		entries, err := listEntries(p.Cfg, walkOptionsFor(params))
+		if err != nil {
+			log.Printf(":::note::: skipping profile %s: %v", p.Name, err)
+			continue
+		}
+		if params.IncludeAttachments {
+			attachments, err := listAttachments(p.Cfg)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, attachments...)
+		}
+		for i := range entries {
+			entries[i].Profile, entries[i].Editor = p.Name, p.Cfg.Editor
+		}
+		all = append(all, entries...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.Date != b.Date {
+			return a.Date.Before(&b.Date)
+		}
+		return a.Profile < b.Profile
+	})
+	return all, nil
+}
+
+// profileLabel is the label shown after an entry's date for its profile.
+func profileLabel(e Entry) string {
+	if len(e.Profile) == 0 {
+		return ""
+	}
+	return " [" + e.Profile + "]"
+}
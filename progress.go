@@ -0,0 +1,53 @@
+package wm
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// progress reports how far a long pass over the archive has got on stderr,
+// so it never mixes with output written to stdout.  On a terminal it redraws
+// one line; in plain output it writes a line at most every few seconds; it
+// is silent when stderr isn't a terminal.
+type progress struct {
+	label string
+	total int
+	done  int
+	shown time.Time
+	live  bool
+}
+
+func newProgress(label string, total int) *progress {
+	return &progress{label: label, total: total, live: term.IsTerminal(int(os.Stderr.Fd()))}
+}
+
+// step records one more item done.
+func (p *progress) step() {
+	p.done++
+	if !p.live {
+		return
+	}
+	every := 100 * time.Millisecond
+	if plainOutput {
+		every = 5 * time.Second
+	}
+	if time.Since(p.shown) < every && p.done < p.total {
+		return
+	}
+	p.shown = time.Now()
+	if plainOutput {
+		fmt.Fprintf(os.Stderr, "%s: %d of %d\n", p.label, p.done, p.total)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\r%s %d/%d", p.label, p.done, p.total)
+}
+
+// finish clears the progress line.
+func (p *progress) finish() {
+	if p.live && !plainOutput && !p.shown.IsZero() {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+	}
+}
@@ -0,0 +1,55 @@
+package wm
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// ask prints question to stderr and returns the trimmed line typed in reply,
+// or def when the reply is empty or stdin is closed.
+func ask(question string, def string) string {
+	if len(def) > 0 {
+		fmt.Fprintf(os.Stderr, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: ", question)
+	}
+	line, err := stdinReader.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if err != nil && len(line) == 0 {
+		fmt.Fprintln(os.Stderr)
+		return def
+	}
+	if len(line) == 0 {
+		return def
+	}
+	return line
+}
+
+// confirm asks a yes/no question, defaulting to no.
+func confirm(question string) bool {
+	reply := strings.ToLower(ask(question+" [y/N]", ""))
+	return reply == "y" || reply == "yes"
+}
+
+// confirmKey asks a yes/no question answered by a single key press,
+// defaulting to no.  It falls back to confirm when the terminal can't be put
+// in raw mode.
+func confirmKey(question string) bool {
+	fd := int(os.Stdin.Fd())
+	old, err := term.MakeRaw(fd)
+	if err != nil {
+		return confirm(question)
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	var key [1]byte
+	_, err = os.Stdin.Read(key[:])
+	term.Restore(fd, old)
+	fmt.Fprintln(os.Stderr)
+	return err == nil && (key[0] == 'y' || key[0] == 'Y')
+}
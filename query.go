@@ -0,0 +1,248 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query holds the range flags shared by every command that works on a span of
+// entries.  resolveQuery is the only place their meaning is defined.
+type Query struct {
+	// Range is the positional "<from>..<to>" or single-date argument.
+	Range string
+	From  string
+	To    string
+	// In names a whole period: a year, a month, or a relative period such as
+	// "last-month".
+	In string
+	// Last is a look-back window such as "10d" or "2w" ending today.
+	Last string
+	// Weeks covers the current week and the Weeks-1 before it.
+	Weeks string
+	// Since is --from by the name search and export also take it by, as in
+	// --since "3 months ago", and takes an age as well; see sinceTime.
+	Since string
+}
+
+// queryFor collects the range flags from the parsed arguments.
+func queryFor(params Parameters) Query {
+	return Query{Range: params.Range, From: params.From, To: params.To, In: params.In, Last: params.Last, Weeks: params.Weeks, Since: params.Since}
+}
+
+// empty reports whether no range flag was given.
+func (q Query) empty() bool {
+	return len(strings.TrimSpace(q.Range+q.From+q.To+q.In+q.Last+q.Weeks+q.Since)) == 0
+}
+
+// dateRange is an inclusive range of days.  A nil bound is open.
+type dateRange struct {
+	From *DatePath
+	To   *DatePath
+}
+
+var (
+	relativePeriodRe = regexp.MustCompile(`^(this|last)[\s-]?(week|month|quarter|year)$`)
+	yearRe           = regexp.MustCompile(`^\d{4}$`)
+	yearMonthRe      = regexp.MustCompile(`^(\d{4})-(\d{1,2})$`)
+	quarterRe        = regexp.MustCompile(`^(?:(\d{4})[\s-]?)?q([1-4])(?:[\s-]?(\d{4}))?$`)
+)
+
+// resolveQuery turns the range flags into a concrete range of days relative
+// to now.  At most one of the positional range, --in, --last, and --weeks may
+// be given, and --from/--to only combine with each other.  --from alone is
+// open-ended, --to alone starts at the beginning of the archive, and no flags
+// at all select the whole archive.
+func resolveQuery(cfg Configuration, q Query, now time.Time) (dateRange, error) {
+	fromFlag := "--from"
+	var since *DatePath
+	if len(q.Since) > 0 {
+		if len(q.From) > 0 {
+			return dateRange{}, errors.New("--since and --from cannot be combined")
+		}
+		t, err := sinceTime(cfg, q.Since, now)
+		if err != nil {
+			return dateRange{}, err
+		}
+		from := datePathFromTime(t)
+		since, q.From, fromFlag = &from, q.Since, "--since"
+	}
+	var set []string
+	for _, f := range []struct{ name, value string }{
+		{"<range>", q.Range}, {"--in", q.In}, {"--last", q.Last}, {"--weeks", q.Weeks},
+	} {
+		if len(strings.TrimSpace(f.value)) > 0 {
+			set = append(set, f.name)
+		}
+	}
+	fromTo := len(q.From) > 0 || len(q.To) > 0
+	if len(set) > 1 || (len(set) == 1 && fromTo) {
+		if fromTo {
+			set = append(set, "--from/--to")
+		}
+		return dateRange{}, fmt.Errorf("%s cannot be combined", strings.Join(set, " and "))
+	}
+	today := datePathFromTime(now)
+
+	switch {
+	case len(q.Range) > 0:
+		from, to, err := parseDateRange(cfg, q.Range)
+		return dateRange{from, to}, err
+
+	case fromTo:
+		r := dateRange{From: since}
+		var err error
+		if since == nil && len(q.From) > 0 {
+			r.From, err = parseDateString(cfg, q.From)
+			if err != nil {
+				return dateRange{}, fmt.Errorf("bad %s: %w", fromFlag, err)
+			}
+		}
+		if len(q.To) > 0 {
+			r.To, err = parseDateString(cfg, q.To)
+			if err != nil {
+				return dateRange{}, fmt.Errorf("bad --to: %w", err)
+			}
+		}
+		if r.From != nil && r.To != nil && r.To.Before(r.From) {
+			return dateRange{}, fmt.Errorf("--to %s is before %s %s", r.To.Iso(), fromFlag, r.From.Iso())
+		}
+		return r, nil
+
+	case len(q.In) > 0:
+		return resolvePeriod(q.In, now)
+
+	case len(q.Last) > 0:
+		age, err := parseAge(q.Last)
+		if err != nil {
+			return dateRange{}, fmt.Errorf("bad --last: %w", err)
+		}
+		days := int(age / (24 * time.Hour))
+		if days < 1 {
+			return dateRange{}, fmt.Errorf("--last %s is shorter than a day", q.Last)
+		}
+		from := datePathFromTime(now.AddDate(0, 0, 1-days))
+		return dateRange{&from, &today}, nil
+
+	case len(q.Weeks) > 0:
+		n, err := strconv.Atoi(q.Weeks)
+		if err != nil || n < 1 {
+			return dateRange{}, fmt.Errorf("--weeks must be a positive number, not '%s'", q.Weeks)
+		}
+		monday := now.AddDate(0, 0, -((int(now.Weekday())+6)%7)-7*(n-1))
+		from := datePathFromTime(monday)
+		return dateRange{&from, &today}, nil
+	}
+	return dateRange{}, nil
+}
+
+// sinceTime resolves --since, which means the same in every command that
+// takes it: an age such as 2d, 1w, or 12h reaches back that far from now,
+// and any date wm reads, 2024-03-01 or "3 months ago" among them, starts at
+// the beginning of that day.  search and export keep the day; modified the
+// time, as it compares edit times.
+func sinceTime(cfg Configuration, in string, now time.Time) (time.Time, error) {
+	if age, err := parseAge(in); err == nil {
+		return addAge(now, -age), nil
+	}
+	pd, err := parseDateString(cfg, in)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad --since: '%s' is neither an age such as 2d, 1w, or 12h nor a date: %w", in, err)
+	}
+	return pd.Time(), nil
+}
+
+// resolvePeriod resolves the --in value: "2024", "2024-03", a month name with
+// an optional year ("march", "march 2023"), a quarter with an optional year
+// ("q1", "2024-q1", "q1 2024"), or one of this-week, last-week, this-month,
+// last-month, this-quarter, last-quarter, this-year, and last-year.  A month
+// name or quarter without a year means its most recent occurrence.
+func resolvePeriod(in string, now time.Time) (dateRange, error) {
+	in = strings.ToLower(strings.TrimSpace(in))
+	if m := relativePeriodRe.FindStringSubmatch(in); m != nil {
+		in = m[1] + "-" + m[2]
+	}
+	span := func(from, to time.Time) (dateRange, error) {
+		f, t := datePathFromTime(from), datePathFromTime(to)
+		return dateRange{&f, &t}, nil
+	}
+	month := func(year, m int) (dateRange, error) {
+		first := time.Date(year, time.Month(m), 1, 0, 0, 0, 0, time.Local)
+		return span(first, first.AddDate(0, 1, -1))
+	}
+	monday := now.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7))
+	quarter := func(year, q int) (dateRange, error) {
+		first := time.Date(year, time.Month(3*q-2), 1, 0, 0, 0, 0, time.Local)
+		return span(first, first.AddDate(0, 3, -1))
+	}
+	thisQuarter := (int(now.Month())-1)/3 + 1
+
+	switch in {
+	case "this-week":
+		return span(monday, monday.AddDate(0, 0, 6))
+	case "last-week":
+		return span(monday.AddDate(0, 0, -7), monday.AddDate(0, 0, -1))
+	case "this-month":
+		return month(now.Year(), int(now.Month()))
+	case "last-month":
+		prev := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.Local)
+		return month(prev.Year(), int(prev.Month()))
+	case "this-quarter":
+		return quarter(now.Year(), thisQuarter)
+	case "last-quarter":
+		if thisQuarter == 1 {
+			return quarter(now.Year()-1, 4)
+		}
+		return quarter(now.Year(), thisQuarter-1)
+	case "this-year":
+		in = strconv.Itoa(now.Year())
+	case "last-year":
+		in = strconv.Itoa(now.Year() - 1)
+	}
+
+	if yearRe.MatchString(in) {
+		year, _ := strconv.Atoi(in)
+		return span(time.Date(year, 1, 1, 0, 0, 0, 0, time.Local), time.Date(year, 12, 31, 0, 0, 0, 0, time.Local))
+	}
+	if m := yearMonthRe.FindStringSubmatch(in); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		mon, _ := strconv.Atoi(m[2])
+		if mon < 1 || mon > 12 {
+			return dateRange{}, fmt.Errorf("bad month in --in '%s'", in)
+		}
+		return month(year, mon)
+	}
+	if m := quarterRe.FindStringSubmatch(in); m != nil && (len(m[1]) == 0 || len(m[3]) == 0) {
+		q, _ := strconv.Atoi(m[2])
+		year := now.Year()
+		switch {
+		case len(m[1]) > 0:
+			year, _ = strconv.Atoi(m[1])
+		case len(m[3]) > 0:
+			year, _ = strconv.Atoi(m[3])
+		case q > thisQuarter:
+			year--
+		}
+		return quarter(year, q)
+	}
+	fields := strings.Fields(in)
+	if len(fields) == 1 || len(fields) == 2 {
+		mon, err := resolveMonthName(fields[0])
+		if err == nil {
+			year := now.Year()
+			if len(fields) == 2 {
+				if !yearRe.MatchString(fields[1]) {
+					return dateRange{}, fmt.Errorf("bad year in --in '%s'", in)
+				}
+				year, _ = strconv.Atoi(fields[1])
+			} else if mon > int(now.Month()) {
+				year--
+			}
+			return month(year, mon)
+		}
+	}
+	return dateRange{}, errors.New("unable to parse --in '" + in + "': use e.g. 2024, 2024-03, march, q1, or last-month")
+}
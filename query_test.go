@@ -0,0 +1,97 @@
+package wm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveQuery(t *testing.T) {
+	// a Thursday
+	testToday(t, 2024, time.March, 7)
+	now := dayNow()
+	day := func(y, m, d int) *DatePath { return &DatePath{y, m, d} }
+	tests := []struct {
+		name     string
+		q        Query
+		from, to *DatePath
+		fails    bool
+	}{
+		{name: "no flags", q: Query{}},
+		{name: "range", q: Query{Range: "2024-03-01..2024-03-05"}, from: day(2024, 3, 1), to: day(2024, 3, 5)},
+		{name: "from", q: Query{From: "2024-03-01"}, from: day(2024, 3, 1)},
+		{name: "to", q: Query{To: "2024-03-05"}, to: day(2024, 3, 5)},
+		{name: "from and to", q: Query{From: "2024-03-01", To: "2024-03-05"}, from: day(2024, 3, 1), to: day(2024, 3, 5)},
+		{name: "to before from", q: Query{From: "2024-03-05", To: "2024-03-01"}, fails: true},
+		{name: "from a phrase", q: Query{From: "3 months ago"}, from: day(2023, 12, 7)},
+		{name: "since a date", q: Query{Since: "2024-03-01"}, from: day(2024, 3, 1)},
+		{name: "since a phrase", q: Query{Since: "3 months ago"}, from: day(2023, 12, 7)},
+		{name: "since days", q: Query{Since: "3d"}, from: day(2024, 3, 4)},
+		{name: "since a week", q: Query{Since: "1w"}, from: day(2024, 2, 29)},
+		{name: "since and to", q: Query{Since: "3d", To: "2024-03-06"}, from: day(2024, 3, 4), to: day(2024, 3, 6)},
+		{name: "since and from", q: Query{Since: "3d", From: "2024-03-01"}, fails: true},
+		{name: "since nonsense", q: Query{Since: "soon"}, fails: true},
+		{name: "in a month", q: Query{In: "2024-02"}, from: day(2024, 2, 1), to: day(2024, 2, 29)},
+		{name: "in a month name", q: Query{In: "march"}, from: day(2024, 3, 1), to: day(2024, 3, 31)},
+		{name: "in a later month name", q: Query{In: "april"}, from: day(2023, 4, 1), to: day(2023, 4, 30)},
+		{name: "in last month", q: Query{In: "last-month"}, from: day(2024, 2, 1), to: day(2024, 2, 29)},
+		{name: "in a quarter", q: Query{In: "q1"}, from: day(2024, 1, 1), to: day(2024, 3, 31)},
+		{name: "in this week", q: Query{In: "this-week"}, from: day(2024, 3, 4), to: day(2024, 3, 10)},
+		{name: "in nonsense", q: Query{In: "someday"}, fails: true},
+		{name: "last days", q: Query{Last: "10d"}, from: day(2024, 2, 27), to: day(2024, 3, 7)},
+		{name: "last hours", q: Query{Last: "12h"}, fails: true},
+		{name: "weeks", q: Query{Weeks: "2"}, from: day(2024, 2, 26), to: day(2024, 3, 7)},
+		{name: "weeks zero", q: Query{Weeks: "0"}, fails: true},
+		{name: "range and in", q: Query{Range: "2024-03-01", In: "march"}, fails: true},
+		{name: "in and last", q: Query{In: "march", Last: "10d"}, fails: true},
+		{name: "in and from", q: Query{In: "march", From: "2024-03-01"}, fails: true},
+		{name: "weeks and to", q: Query{Weeks: "2", To: "2024-03-01"}, fails: true},
+		{name: "last and since", q: Query{Last: "10d", Since: "3d"}, fails: true},
+	}
+	for _, tt := range tests {
+		r, err := resolveQuery(Configuration{}, tt.q, now)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("%s: resolveQuery = %v to %v, want an error", tt.name, r.From, r.To)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: resolveQuery: %v", tt.name, err)
+			continue
+		}
+		if !sameDay(r.From, tt.from) || !sameDay(r.To, tt.to) {
+			t.Errorf("%s: resolveQuery = %v to %v, want %v to %v", tt.name, r.From, r.To, tt.from, tt.to)
+		}
+	}
+}
+
+func sameDay(a, b *DatePath) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func TestSinceTime(t *testing.T) {
+	testToday(t, 2024, time.March, 7)
+	now := time.Date(2024, time.March, 7, 15, 30, 0, 0, time.Local)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2d", time.Date(2024, time.March, 5, 15, 30, 0, 0, time.Local)},
+		{"1w", time.Date(2024, time.February, 29, 15, 30, 0, 0, time.Local)},
+		{"12h", time.Date(2024, time.March, 7, 3, 30, 0, 0, time.Local)},
+		{"2024-03-01", time.Date(2024, time.March, 1, 0, 0, 0, 0, time.Local)},
+		{"2 weeks ago", time.Date(2024, time.February, 22, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		got, err := sinceTime(Configuration{}, tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("sinceTime(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := sinceTime(Configuration{}, "-2d", now); err == nil {
+		t.Error("sinceTime(-2d) succeeded, want an error")
+	}
+}
@@ -0,0 +1,93 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// existingEntry resolves the date and --topic given like opening them does,
+// but never creates the entry: a missing one is an error.
+func existingEntry(cfg Configuration, params Parameters) (*DatePath, string, error) {
+	pd, target, err := resolveEntry(cfg, params)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := os.Stat(target); errors.Is(err, fs.ErrNotExist) {
+		return nil, "", fmt.Errorf("no entry for %s", pd.Iso())
+	} else if err != nil {
+		return nil, "", err
+	}
+	return pd, target, nil
+}
+
+// runCat prints the entry for the date given, decrypted, without creating
+// it or touching it.
+func runCat(cfg Configuration, params Parameters) error {
+	_, target, err := existingEntry(cfg, params)
+	if err != nil {
+		return err
+	}
+	data, err := readEntry(cfg, target)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// openReadOnly is the open flow with --read-only: the entry is never
+// created, and the editor is given readonly_args, or when those aren't set,
+// or the entry is encrypted, a copy only readable, which is removed once the
+// editor exits when wm waits for it.  Neither session markers nor locks nor
+// commits apply, as nothing is written.
+func openReadOnly(cfg Configuration, params Parameters) (openOutcome, error) {
+	pd, target, err := existingEntry(cfg, params)
+	if err != nil {
+		return openOutcome{}, withExitCode(exitNoEntry, err)
+	}
+	data, err := readEntry(cfg, target)
+	if err != nil {
+		return openOutcome{}, err
+	}
+	out := openOutcome{Path: target, Empty: len(bytes.TrimSpace(stripHeader(data))) == 0}
+	t := editTarget{kindEntry, pd, false}
+	readonlyArgs, _ := splitCommandLine(cfg.ReadonlyArgs)
+	if len(readonlyArgs) > 0 && !cfg.Encrypt {
+		cmd := editorCommand(cfg, t, target, append(readonlyArgs, target)...)
+		if err := startEditor(cmd, editorWait(cfg)); err != nil {
+			return out, editorError(fmt.Errorf("failed to open %s using %s: %w", target, editorName(cfg), err))
+		}
+		return out, nil
+	}
+	f, err := os.CreateTemp("", "wm-readonly-*-"+filepath.Base(target))
+	if err != nil {
+		return out, err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o400)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return out, err
+	}
+	wait := editorWait(cfg)
+	if wait {
+		defer func() {
+			os.Chmod(f.Name(), 0o600)
+			shred(f.Name())
+		}()
+	}
+	cmd := editorCommand(cfg, t, f.Name(), f.Name())
+	if err := startEditor(cmd, wait); err != nil {
+		return out, editorError(fmt.Errorf("failed to open %s using %s: %w", target, editorName(cfg), err))
+	}
+	return out, nil
+}
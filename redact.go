@@ -0,0 +1,254 @@
+package wm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RedactConfig holds the patterns applied by every redaction: by "redact"
+// on top of its own, and by export --redacted on their own.  Patterns are
+// regular expressions, optionally labelled as in "PERSON=\b[A-Z][a-z]+
+// [A-Z][a-z]+\b"; PatternFiles name files of literal secrets, one a line.
+type RedactConfig struct {
+	Patterns     []string `toml:"patterns"`
+	PatternFiles []string `toml:"pattern_files"`
+}
+
+const (
+	// defaultRedactLabel names the placeholders of unlabelled patterns.
+	defaultRedactLabel = "REDACTED"
+	// secretLabel names the placeholders of the literals in pattern files.
+	secretLabel = "SECRET"
+)
+
+var redactLabelRe = regexp.MustCompile(`^([A-Z][A-Z0-9_]*)=(.+)$`)
+
+type redactRule struct {
+	Label string
+	Re    *regexp.Regexp
+}
+
+// redaction is one original text and the placeholder standing for it.
+type redaction struct {
+	Placeholder string
+	Original    string
+}
+
+// redactor replaces what its rules match by placeholders such as PERSON-1,
+// numbered per label in the order they first appear.  The same text, in
+// any case, always gets the same placeholder.
+type redactor struct {
+	rules        []redactRule
+	placeholders map[string]string
+	counts       map[string]int
+	mapping      []redaction
+}
+
+func newRedactor() *redactor {
+	return &redactor{placeholders: map[string]string{}, counts: map[string]int{}}
+}
+
+// splitRedactLabel splits the optional LABEL= prefix off a pattern.
+func splitRedactLabel(p string) (label, pattern string) {
+	if m := redactLabelRe.FindStringSubmatch(p); m != nil {
+		return m[1], m[2]
+	}
+	return defaultRedactLabel, p
+}
+
+// addPattern adds a regular expression, with an optional LABEL= prefix.
+func (r *redactor) addPattern(p string) error {
+	label, p := splitRedactLabel(p)
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return fmt.Errorf("bad redaction pattern '%s': %w", p, err)
+	}
+	r.rules = append(r.rules, redactRule{label, re})
+	return nil
+}
+
+// addPatternFile adds every line of the file at path as a literal secret.
+// Blank lines and lines starting with '#' are skipped.
+func (r *redactor) addPatternFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r.rules = append(r.rules, redactRule{secretLabel, regexp.MustCompile(regexp.QuoteMeta(line))})
+	}
+	return nil
+}
+
+// configRedactor returns a redactor with the patterns of the [redact]
+// table.
+func configRedactor(cfg Configuration) (*redactor, error) {
+	r := newRedactor()
+	for _, p := range cfg.Redact.Patterns {
+		if err := r.addPattern(p); err != nil {
+			return nil, fmt.Errorf("in [redact]: %w", err)
+		}
+	}
+	for _, f := range cfg.Redact.PatternFiles {
+		if err := r.addPatternFile(configRelative(cfg, f)); err != nil {
+			return nil, fmt.Errorf("in [redact]: %w", err)
+		}
+	}
+	return r, nil
+}
+
+func (r *redactor) placeholder(label, text string) string {
+	key := label + "\x00" + strings.ToLower(text)
+	if p, ok := r.placeholders[key]; ok {
+		return p
+	}
+	r.counts[label]++
+	p := fmt.Sprintf("%s-%d", label, r.counts[label])
+	r.placeholders[key] = p
+	r.mapping = append(r.mapping, redaction{p, text})
+	return p
+}
+
+// redact returns text with every match replaced.  Where matches overlap,
+// the one starting first wins, and of those starting together the longest,
+// so a secret inside a matched name is not redacted twice.
+func (r *redactor) redact(text string) string {
+	type match struct {
+		Start, End int
+		Label      string
+	}
+	var matches []match
+	for _, rule := range r.rules {
+		for _, loc := range rule.Re.FindAllStringIndex(text, -1) {
+			if loc[1] > loc[0] {
+				matches = append(matches, match{loc[0], loc[1], rule.Label})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End > matches[j].End
+	})
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		if m.Start < last {
+			continue
+		}
+		b.WriteString(text[last:m.Start])
+		b.WriteString(r.placeholder(m.Label, text[m.Start:m.End]))
+		last = m.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// clipboardCommands are tried in order to copy to the clipboard.
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip.exe"},
+}
+
+func copyToClipboard(data []byte) error {
+	for _, c := range clipboardCommands {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(c[0], c[1:]...)
+		cmd.Stdin = bytes.NewReader(data)
+		return cmd.Run()
+	}
+	return errors.New("no clipboard command found; install xclip, xsel, or wl-copy, or use --to=stdout")
+}
+
+// runRedact writes a redacted copy of the entry for the date given, with
+// the patterns of --pattern, --pattern-file, and [redact].  The entry itself
+// is never changed.  --mapping-out records which placeholder stands for
+// what.
+func runRedact(cfg Configuration, params Parameters) error {
+	pd, err := parseDateString(cfg, strings.Join(params.DateWords, " "))
+	if err != nil {
+		return err
+	}
+	path, err := entryPath(cfg, pd)
+	if err != nil {
+		return err
+	}
+	data, err := readEntry(cfg, path)
+	if err != nil {
+		return err
+	}
+	r, err := configRedactor(cfg)
+	if err != nil {
+		return err
+	}
+	for _, p := range params.PatternFlags {
+		if err := r.addPattern(p); err != nil {
+			return err
+		}
+	}
+	for _, f := range params.PatternFile {
+		if err := r.addPatternFile(f); err != nil {
+			return err
+		}
+	}
+	if len(r.rules) == 0 {
+		return errors.New("nothing to redact; give --pattern or --pattern-file, or set patterns in [redact]")
+	}
+	out := []byte(r.redact(string(data)))
+
+	switch params.To {
+	case "", "stdout":
+		_, err = os.Stdout.Write(out)
+	case "clipboard":
+		err = copyToClipboard(out)
+	case "file":
+		if len(params.Out) == 0 {
+			return errors.New("--to=file needs -o <file>")
+		}
+		if sameFile(params.Out, path) {
+			return errors.New("refusing to write the redacted copy over the entry")
+		}
+		err = os.WriteFile(params.Out, out, fileMode(cfg))
+	default:
+		return fmt.Errorf("unknown --to '%s', expected stdout, clipboard, or file", params.To)
+	}
+	if err != nil {
+		return err
+	}
+	if len(params.MappingOut) > 0 {
+		var b strings.Builder
+		for _, m := range r.mapping {
+			fmt.Fprintf(&b, "%s\t%s\n", m.Placeholder, m.Original)
+		}
+		if err := os.WriteFile(params.MappingOut, []byte(b.String()), 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameFile reports whether a and b name the same existing file.
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
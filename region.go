@@ -0,0 +1,62 @@
+package wm
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Generated regions are blocks of an entry that wm rewrites on request while
+// leaving the rest of the file alone.  They are delimited by marker lines:
+//
+//	<!-- wm:begin meetings -->
+//	...
+//	<!-- wm:end meetings -->
+
+func regionMarkers(name string) (begin string, end string) {
+	return fmt.Sprintf("<!-- wm:begin %s -->", name), fmt.Sprintf("<!-- wm:end %s -->", name)
+}
+
+// findRegion returns the body of the named region in data, between (but not
+// including) its marker lines.  ok is false if either marker is missing.
+func findRegion(data []byte, name string) (start int, end int, ok bool) {
+	begin, finish := regionMarkers(name)
+	b := bytes.Index(data, []byte(begin))
+	if b < 0 {
+		return 0, 0, false
+	}
+	start = b + len(begin)
+	if start < len(data) && data[start] == '\n' {
+		start++
+	}
+	e := bytes.Index(data[start:], []byte(finish))
+	if e < 0 {
+		return 0, 0, false
+	}
+	return start, start + e, true
+}
+
+// replaceRegion replaces the body of the named region with body.  When the
+// region doesn't exist yet it is appended to data under heading.
+func replaceRegion(data []byte, name string, heading string, body string) []byte {
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		body += "\n"
+	}
+	if start, end, ok := findRegion(data, name); ok {
+		var b bytes.Buffer
+		b.Write(data[:start])
+		b.WriteString(body)
+		b.Write(data[end:])
+		return b.Bytes()
+	}
+	begin, finish := regionMarkers(name)
+	var b bytes.Buffer
+	b.Write(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		b.WriteString("\n")
+	}
+	if len(heading) > 0 {
+		fmt.Fprintf(&b, "\n%s\n", heading)
+	}
+	fmt.Fprintf(&b, "%s\n%s%s\n", begin, body, finish)
+	return b.Bytes()
+}
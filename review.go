@@ -0,0 +1,221 @@
+package wm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// reviewTopTerms is how many of the most frequent terms the year in review
+// lists for each month and the year.
+const reviewTopTerms = 15
+
+// defaultStopwords are the words too common to say anything about an
+// entry, left out of the terms of the year in review.  review_stopwords
+// adds to them.
+var defaultStopwords = []string{
+	"a", "about", "after", "again", "all", "also", "am", "an", "and", "any",
+	"are", "as", "at", "be", "because", "been", "before", "being", "but", "by",
+	"can", "could", "did", "do", "does", "done", "dont", "for", "from", "get",
+	"got", "had", "has", "have", "he", "her", "here", "him", "his", "how", "i",
+	"if", "im", "in", "into", "is", "it", "its", "ive", "just", "like", "me",
+	"more", "my", "need", "no", "not", "now", "of", "on", "one", "only", "or",
+	"other", "our", "out", "over", "she", "so", "some", "still", "than",
+	"that", "the", "their", "them", "then", "there", "these", "they", "this",
+	"those", "to", "too", "up", "us", "very", "was", "we", "were", "what",
+	"when", "which", "while", "who", "why", "will", "with", "would", "you",
+	"your",
+}
+
+// stopwordSet is defaultStopwords and the configured review_stopwords.
+func stopwordSet(cfg Configuration) map[string]bool {
+	stop := map[string]bool{}
+	for _, w := range defaultStopwords {
+		stop[w] = true
+	}
+	for _, w := range cfg.ReviewStopwords {
+		for _, t := range tokenize([]byte(w)) {
+			stop[t] = true
+		}
+	}
+	return stop
+}
+
+// tokenize returns the words of data in lower case, split at anything that
+// isn't a letter or digit.  Apostrophes are dropped rather than split at,
+// so "don't" is "dont".  Numbers alone aren't words.
+func tokenize(data []byte) []string {
+	text := strings.NewReplacer("'", "", "’", "").Replace(strings.ToLower(string(data)))
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	words := fields[:0]
+	for _, f := range fields {
+		if strings.IndexFunc(f, unicode.IsLetter) >= 0 {
+			words = append(words, f)
+		}
+	}
+	return words
+}
+
+// termCount is how often a term was used.
+type termCount struct {
+	Term  string
+	Count int
+}
+
+// periodReview is what the year in review says about a month or the year:
+// how many entries there were and the words in them, the most frequent
+// terms, and the tags used, by how often.
+type periodReview struct {
+	Entries int
+	Words   int
+	Terms   []termCount
+	Tags    []termCount
+}
+
+// reviewTally adds up the entries of a month or the year as they are read,
+// so that only the counts are kept rather than the entries.
+type reviewTally struct {
+	stop    map[string]bool
+	entries int
+	words   int
+	terms   map[string]int
+	tags    map[string]int
+}
+
+func newReviewTally(stop map[string]bool) *reviewTally {
+	return &reviewTally{stop: stop, terms: map[string]int{}, tags: map[string]int{}}
+}
+
+// add counts an entry whose content is data.
+func (t *reviewTally) add(data []byte) {
+	body := stripHeader(data)
+	t.entries++
+	t.words += len(strings.Fields(string(body)))
+	for _, w := range tokenize(body) {
+		if !t.stop[w] && len([]rune(w)) > 1 {
+			t.terms[w]++
+		}
+	}
+	for _, tag := range entryTags(data) {
+		t.tags[strings.ToLower(tag)]++
+	}
+}
+
+// review returns what the entries added say.
+func (t *reviewTally) review() periodReview {
+	return periodReview{
+		Entries: t.entries,
+		Words:   t.words,
+		Terms:   topCounts(t.terms, reviewTopTerms),
+		Tags:    topCounts(t.tags, 0),
+	}
+}
+
+// topCounts returns the n most frequent of counts, or all of them for n 0,
+// the most frequent first and ties in alphabetical order.
+func topCounts(counts map[string]int, n int) []termCount {
+	list := make([]termCount, 0, len(counts))
+	for t, c := range counts {
+		list = append(list, termCount{t, c})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Term < list[j].Term
+	})
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// joinCounts lists counts as "deploy (12), review (9)".
+func joinCounts(counts []termCount) string {
+	parts := make([]string, len(counts))
+	for i, c := range counts {
+		parts[i] = fmt.Sprintf("%s (%d)", c.Term, c.Count)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writePeriodReview writes r as the body of a section of the report.
+func writePeriodReview(w io.Writer, r periodReview) {
+	if r.Entries == 0 {
+		fmt.Fprintf(w, "no entries\n\n")
+		return
+	}
+	fmt.Fprintf(w, "- Entries: %s\n", groupDigits(r.Entries))
+	fmt.Fprintf(w, "- Words: %s\n", groupDigits(r.Words))
+	if len(r.Terms) > 0 {
+		fmt.Fprintf(w, "- Top terms: %s\n", joinCounts(r.Terms))
+	}
+	if len(r.Tags) > 0 {
+		fmt.Fprintf(w, "- Tags: %s\n", joinCounts(r.Tags))
+	}
+	fmt.Fprintln(w)
+}
+
+// runReview writes the year in review for --year, this year by default, as
+// Markdown: the entries, words, top terms, and tags of the year and then of
+// each month, months without entries included.  Entries dated after today
+// are left out unless --include-future is given.
+func runReview(cfg Configuration, params Parameters) error {
+	year := dayNow().Year()
+	if len(params.Year) > 0 {
+		y, err := strconv.Atoi(params.Year)
+		if err != nil || !yearRe.MatchString(params.Year) {
+			return fmt.Errorf("--year needs a year such as 2024, not '%s'", params.Year)
+		}
+		year = y
+	}
+	all, err := rootEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	all = historyEntries(all, params.IncludeFuture)
+	stop := stopwordSet(cfg)
+	total := newReviewTally(stop)
+	var months [12]*reviewTally
+	for i := range months {
+		months[i] = newReviewTally(stop)
+	}
+	for _, e := range all {
+		if e.Date.year != year {
+			continue
+		}
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", e.Path, err)
+		}
+		months[e.Date.month-1].add(data)
+		total.add(data)
+	}
+
+	var w io.Writer = os.Stdout
+	if len(params.Out) > 0 {
+		f, err := os.Create(params.Out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	fmt.Fprintf(w, "# %d in review\n\n", year)
+	fmt.Fprintf(w, "## The year\n\n")
+	writePeriodReview(w, total.review())
+	for m := 1; m <= 12; m++ {
+		fmt.Fprintf(w, "## %s\n\n", monthName(m))
+		writePeriodReview(w, months[m-1].review())
+	}
+	if len(params.Out) > 0 {
+		fmt.Printf("wrote the review of %d, %d entries, to %s\n", year, total.entries, params.Out)
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package wm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	reviewQueueFile    = "review-queue.json"
+	reviewQueueVersion = 1
+)
+
+// reviewItem is one queued entry, identified by its date.
+type reviewItem struct {
+	Date     string     `json:"date"`
+	Added    time.Time  `json:"added"`
+	Reviewed *time.Time `json:"reviewed,omitempty"`
+}
+
+// reviewQueues holds the queue of every root, keyed by rootKey.
+type reviewQueues map[string][]reviewItem
+
+func reviewQueuePath() (string, error) {
+	return statePath(reviewQueueFile)
+}
+
+func decodeReviewQueues(data []byte) reviewQueues {
+	queues := reviewQueues{}
+	if len(data) > 0 && json.Unmarshal(data, &queues) != nil {
+		return reviewQueues{}
+	}
+	return queues
+}
+
+// readReviewQueue returns the queue of root, oldest entry first.
+func readReviewQueue(root string) ([]reviewItem, error) {
+	p, err := reviewQueuePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := readState(p, reviewQueueVersion)
+	if errors.Is(err, errStateCorrupt) {
+		return nil, fmt.Errorf("%s is unreadable; remove it to start a new queue", p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeReviewQueues(data)[rootKey(root)], nil
+}
+
+// updateReviewQueue applies update to the queue of root under the state
+// file's lock.
+func updateReviewQueue(root string, update func([]reviewItem) []reviewItem) error {
+	p, err := reviewQueuePath()
+	if err != nil {
+		return err
+	}
+	return updateState(p, reviewQueueVersion, func(old []byte) ([]byte, error) {
+		queues := decodeReviewQueues(old)
+		items := update(queues[rootKey(root)])
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Date < items[j].Date })
+		queues[rootKey(root)] = items
+		return json.MarshalIndent(queues, "", "  ")
+	})
+}
+
+// hasGlobMeta reports whether pattern uses glob syntax.
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// expandDatePattern returns the entries a pattern names.  A glob such as
+// "2023-11-*" is matched against each entry's date and its path relative to
+// the root, anything else is read as a date or "<from>..<to>" range.
+func expandDatePattern(cfg Configuration, entries []Entry, pattern string) ([]Entry, error) {
+	if hasGlobMeta(pattern) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad pattern '%s': %w", pattern, err)
+		}
+		var matched []Entry
+		for _, e := range entries {
+			rel, _ := filepath.Rel(cfg.Root, e.Path)
+			iso, _ := path.Match(pattern, e.Date.Iso())
+			byPath, _ := path.Match(pattern, filepath.ToSlash(rel))
+			if iso || byPath {
+				matched = append(matched, e)
+			}
+		}
+		return matched, nil
+	}
+	from, to, err := parseDateRange(cfg, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return filterEntries(entries, from, to), nil
+}
+
+// runReviewQueue dispatches the review-queue subcommands.
+func runReviewQueue(cfg Configuration, params Parameters) error {
+	switch {
+	case params.Add:
+		return reviewQueueAdd(cfg, params.Pattern)
+	case params.Next:
+		return reviewQueueNext(cfg)
+	case params.Stats:
+		return reviewQueueStats(cfg)
+	}
+	return reviewQueueList(cfg)
+}
+
+func reviewQueueAdd(cfg Configuration, patterns []string) error {
+	entries, err := listEntries(cfg, walkOptions{})
+	if err != nil {
+		return err
+	}
+	var dates []string
+	for _, p := range patterns {
+		matched, err := expandDatePattern(cfg, entries, p)
+		if err != nil {
+			return err
+		}
+		if len(matched) == 0 {
+			log.Println(":::note::: no entries match", p)
+		}
+		for _, e := range matched {
+			dates = append(dates, e.Date.Iso())
+		}
+	}
+	added := 0
+	err = updateReviewQueue(cfg.Root, func(items []reviewItem) []reviewItem {
+		queued := map[string]bool{}
+		for _, it := range items {
+			queued[it.Date] = true
+		}
+		at := now()
+		for _, d := range dates {
+			if !queued[d] {
+				queued[d] = true
+				items = append(items, reviewItem{Date: d, Added: at})
+				added++
+			}
+		}
+		return items
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("queued %d entries\n", added)
+	return nil
+}
+
+// reviewTarget resolves a queued date to its entry path, reporting whether
+// the entry still exists.
+func reviewTarget(cfg Configuration, date string) (string, bool) {
+	t, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return "", false
+	}
+	dp := datePathFromTime(t)
+	p, err := entryPath(cfg, &dp)
+	if err != nil {
+		return "", false
+	}
+	_, err = os.Stat(p)
+	return p, err == nil
+}
+
+func reviewQueueList(cfg Configuration) error {
+	items, err := readReviewQueue(cfg.Root)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, it := range items {
+		if it.Reviewed != nil {
+			continue
+		}
+		pending++
+		p, ok := reviewTarget(cfg, it.Date)
+		if !ok {
+			fmt.Printf("%s  (missing: the entry no longer exists)\n", it.Date)
+			continue
+		}
+		preview := ""
+		if data, err := readEntry(cfg, p); err == nil {
+			preview = entryPreview(data)
+		}
+		fmt.Printf("%s  %s\n", it.Date, preview)
+	}
+	if pending == 0 {
+		fmt.Println("the review queue is empty")
+	}
+	return nil
+}
+
+// reviewQueueNext opens the oldest unreviewed entry, locked, backed up, and
+// committed like any edit, waits for the editor to exit, and marks the entry
+// reviewed.  Entries deleted since they were queued
+// are skipped with a note.
+func reviewQueueNext(cfg Configuration) error {
+	items, err := readReviewQueue(cfg.Root)
+	if err != nil {
+		return err
+	}
+	for _, it := range items {
+		if it.Reviewed != nil {
+			continue
+		}
+		p, ok := reviewTarget(cfg, it.Date)
+		if !ok {
+			log.Printf(":::note::: skipping %s: the entry no longer exists", it.Date)
+			continue
+		}
+		dp, _ := parseDateString(cfg, it.Date)
+		// it is marked reviewed once the editor exits, so it is waited for
+		err = editFiles(cfg, fileEdit{
+			Target: editTarget{Kind: kindEntry, Date: dp},
+			Paths:  []string{p},
+			Dates:  []*DatePath{dp},
+			Wait:   true,
+		})
+		if err != nil {
+			return fmt.Errorf("%s not marked reviewed: %w", it.Date, err)
+		}
+		date := it.Date
+		return updateReviewQueue(cfg.Root, func(items []reviewItem) []reviewItem {
+			at := now()
+			for i := range items {
+				if items[i].Date == date && items[i].Reviewed == nil {
+					items[i].Reviewed = &at
+				}
+			}
+			return items
+		})
+	}
+	fmt.Println("nothing left to review")
+	return nil
+}
+
+func reviewQueueStats(cfg Configuration) error {
+	items, err := readReviewQueue(cfg.Root)
+	if err != nil {
+		return err
+	}
+	reviewed, missing := 0, 0
+	for _, it := range items {
+		if it.Reviewed != nil {
+			reviewed++
+		} else if _, ok := reviewTarget(cfg, it.Date); !ok {
+			missing++
+		}
+	}
+	pct := 0
+	if len(items) > 0 {
+		pct = reviewed * 100 / len(items)
+	}
+	fmt.Printf("%d queued, %d reviewed (%d%%), %d left", len(items), reviewed, pct, len(items)-reviewed)
+	if missing > 0 {
+		fmt.Printf(", %d missing from disk", missing)
+	}
+	fmt.Println()
+	return nil
+}
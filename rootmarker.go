@@ -0,0 +1,163 @@
+package wm
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rootMarkerFile marks a directory as a wm root.  Commands that move,
+// rewrite, or create many files refuse to run on a root without one, so a
+// root mistakenly pointed at an unrelated directory can't be damaged.
+const (
+	rootMarkerFile    = ".wm-root"
+	rootMarkerVersion = 1
+)
+
+// rootMarker is the content of the marker file.
+type rootMarker struct {
+	Version int
+	Created time.Time
+}
+
+// rootDir is the root with a leading "~" expanded to the home directory.
+func rootDir(cfg Configuration) string {
+	return expandPath(cfg.Root)
+}
+
+func rootMarkerPath(cfg Configuration) string {
+	return filepath.Join(rootDir(cfg), rootMarkerFile)
+}
+
+// writeRootMarker marks the root, creating it if needed.
+func writeRootMarker(cfg Configuration) error {
+	err := makeDir(cfg, rootDir(cfg))
+	if err != nil {
+		return err
+	}
+	content := fmt.Sprintf("# This directory is a wm root.\nversion = %d\ncreated = %s\n", rootMarkerVersion, now().Format(time.RFC3339))
+	return os.WriteFile(rootMarkerPath(cfg), []byte(content), 0o644)
+}
+
+// readRootMarker reads the marker of the root.
+func readRootMarker(cfg Configuration) (rootMarker, error) {
+	data, err := os.ReadFile(rootMarkerPath(cfg))
+	if err != nil {
+		return rootMarker{}, err
+	}
+	var m rootMarker
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "version":
+			m.Version, _ = strconv.Atoi(strings.TrimSpace(value))
+		case "created":
+			m.Created, _ = time.Parse(time.RFC3339, strings.TrimSpace(value))
+		}
+	}
+	if m.Version < 1 {
+		return rootMarker{}, fmt.Errorf("%s is not a wm root marker", rootMarkerPath(cfg))
+	}
+	return m, nil
+}
+
+// initRootIfMissing marks the root when wm is the one creating it.
+func initRootIfMissing(cfg Configuration) error {
+	if _, err := os.Stat(rootDir(cfg)); !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return writeRootMarker(cfg)
+}
+
+// requireRootMarker fails unless the root carries a marker.  A root that
+// doesn't exist yet is created and marked, since there is nothing in it that
+// could be mistaken for entries.
+func requireRootMarker(cfg Configuration) error {
+	if err := initRootIfMissing(cfg); err != nil {
+		return err
+	}
+	_, err := readRootMarker(cfg)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s has no %s marker, so it may not be a wm root; run \"wm init\" to adopt it, or pass --force-root", cfg.Root, rootMarkerFile)
+	}
+	return err
+}
+
+// needsRootMarker reports whether the command moves, rewrites, or creates
+// files in bulk, and so must only run on a marked root.
+func needsRootMarker(params Parameters) bool {
+	if params.DryRun {
+		return false
+	}
+	return (params.Migrate && !params.Config) || params.Consolidate || params.Fill || params.Tags || params.Move || (params.Archive && !params.List) ||
+		(params.Split && len(params.Out) == 0) ||
+		(params.Check && (params.Fix || params.FixByHeader)) ||
+		(params.Lint && params.Fix) ||
+		(params.Append && !queryFor(params).empty())
+}
+
+// runInit marks the root.  An existing directory without a marker is adopted
+// after showing what is in it.
+func runInit(cfg Configuration, params Parameters) error {
+	if _, err := os.Stat(rootDir(cfg)); errors.Is(err, fs.ErrNotExist) {
+		if err := writeRootMarker(cfg); err != nil {
+			return err
+		}
+		fmt.Println("created", cfg.Root)
+		return nil
+	}
+	if m, err := readRootMarker(cfg); err == nil {
+		fmt.Printf("%s is already a wm root, marked %s\n", cfg.Root, formatTime(cfg, m.Created))
+		return nil
+	}
+
+	entries, err := listEntries(cfg, walkOptions{})
+	if err != nil {
+		return err
+	}
+	dirEntries, err := os.ReadDir(cfg.Root)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, de := range dirEntries {
+		name := de.Name()
+		if de.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("%s holds %d entries", cfg.Root, len(entries))
+	if len(entries) > 0 {
+		fmt.Printf(" from %s to %s", entries[0].Date.Iso(), entries[len(entries)-1].Date.Iso())
+	}
+	fmt.Println(" and at its top level:")
+	for i, name := range names {
+		if i == 20 {
+			fmt.Printf("  ... and %d more\n", len(names)-i)
+			break
+		}
+		fmt.Println(" ", name)
+	}
+	if !params.Yes && !confirm("adopt it as a wm root?") {
+		return errors.New("not adopted")
+	}
+	if err := writeRootMarker(cfg); err != nil {
+		return err
+	}
+	fmt.Println("marked", cfg.Root, "as a wm root")
+	return nil
+}
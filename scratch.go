@@ -0,0 +1,129 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Scratch notes are named files that belong to no day, kept as
+// root/scratch/<name>.txt.  The walker treats the directory as internal, so
+// nothing that works on dates sees them.
+const scratchDir = "scratch"
+
+var scratchNameRe = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+// scratchSlug suggests a valid scratch name for name.
+func scratchSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// checkScratchName rejects names that aren't slugs and names that read as a
+// date or period, which would be ambiguous next to the date argument.
+func checkScratchName(cfg Configuration, name string) error {
+	if !scratchNameRe.MatchString(name) || len(name) > 64 {
+		if slug := scratchSlug(name); len(slug) > 0 && slug != name && len(slug) <= 64 {
+			return fmt.Errorf("'%s' is not a valid scratch name; use lowercase letters, digits, and dashes, such as '%s'", name, slug)
+		}
+		return fmt.Errorf("'%s' is not a valid scratch name; use up to 64 lowercase letters, digits, and dashes", name)
+	}
+	if strings.Trim(name, "0123456789-") == "" {
+		return fmt.Errorf("'%s' looks like a date; scratch names must not", name)
+	}
+	if _, err := parseDateString(cfg, strings.ReplaceAll(name, "-", " ")); err == nil {
+		return fmt.Errorf("'%s' reads as a date; scratch names must not", name)
+	}
+	if _, err := resolvePeriod(name, dayNow()); err == nil {
+		return fmt.Errorf("'%s' reads as a period of dates; scratch names must not", name)
+	}
+	return nil
+}
+
+// scratchPath returns the file of the scratch note called name.
+func scratchPath(cfg Configuration, name string) string {
+	return filepath.Join(cfg.Root, scratchDir, name+".txt")
+}
+
+// listScratch returns the scratch notes under the root sorted by name, as
+// entries carrying the note's name and no date.
+func listScratch(cfg Configuration) ([]Entry, error) {
+	base := filepath.Join(cfg.Root, scratchDir)
+	dir, err := os.ReadDir(base)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var found []Entry
+	for _, d := range dir {
+		name := strings.TrimSuffix(d.Name(), ".txt")
+		if d.IsDir() || name == d.Name() || !scratchNameRe.MatchString(name) {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+		found = append(found, Entry{Path: filepath.Join(base, d.Name()), ModTime: info.ModTime(), Scratch: name})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Scratch < found[j].Scratch })
+	return found, nil
+}
+
+// runScratch opens the scratch note named on the command line, creating it
+// first if needed, or lists the notes with --list.
+func runScratch(cfg Configuration, params Parameters) error {
+	if params.List {
+		notes, err := listScratch(cfg)
+		if err != nil {
+			return err
+		}
+		if len(notes) == 0 {
+			fmt.Println("no scratch notes; create one with wm scratch <name>")
+			return nil
+		}
+		for _, n := range notes {
+			fmt.Printf("%s  %s\n", n.ModTime.Format("2006-01-02"), n.Scratch)
+		}
+		return nil
+	}
+	if err := checkScratchName(cfg, params.Name); err != nil {
+		return err
+	}
+	path := scratchPath(cfg, params.Name)
+	created := false
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if err := initRootIfMissing(cfg); err != nil {
+			return fmt.Errorf("failed to create the root: %w", err)
+		}
+		if err := makeDir(cfg, filepath.Dir(path)); err != nil {
+			return fmt.Errorf("failed to create the scratch directory: %w", err)
+		}
+		if err := os.WriteFile(path, nil, fileMode(cfg)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		created = true
+	} else if err != nil {
+		return err
+	}
+	return editFiles(cfg, fileEdit{Target: editTarget{Kind: kindScratch, Created: created}, Paths: []string{path}})
+}
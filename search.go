@@ -0,0 +1,1242 @@
+package wm
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchHit is a single match of a search term within a log file.  Term is
+// the term as it was given; Case, Word, Fold, and Fixed tell whether it was
+// matched case-sensitively, as a whole word, regardless of accents, and as
+// literal text.  Line and
+// Column are 1-based; Column and Length are counted in runes so that editors
+// can highlight the match regardless of multi-byte characters.  A tab counts
+// as a single column.  Offset is the byte offset of the match in the file.
+// Modified is the last time the file was edited.  Kind is "entry",
+// "attachment", "scratch", or "note"; Date is the date of the owning entry,
+// Attachment the name of the attachment, Scratch the name of the scratch
+// note, and Note the kind and path of a note from [notes], which have no
+// date.  Archive is the archive an entry found with --include-archives is
+// kept in.  With --all-profiles, Profile is the profile the file belongs to and
+// Editor the editor configured to open it.  Context is the context human
+// output shows around the match, as lines.
+type SearchHit struct {
+	Kind       string    `json:"kind"`
+	Date       string    `json:"date,omitempty"`
+	Attachment string    `json:"attachment,omitempty"`
+	Scratch    string    `json:"scratch,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	Archive    string    `json:"archive,omitempty"`
+	Topic      string    `json:"topic,omitempty"`
+	Profile    string    `json:"profile,omitempty"`
+	Editor     string    `json:"editor,omitempty"`
+	File       string    `json:"file"`
+	Term       string    `json:"term"`
+	Case       bool      `json:"case_sensitive"`
+	Word       bool      `json:"whole_word"`
+	Fold       bool      `json:"fold_diacritics"`
+	Fixed      bool      `json:"fixed_strings"`
+	Line       int       `json:"line"`
+	Column     int       `json:"column"`
+	Offset     int       `json:"offset"`
+	Length     int       `json:"length"`
+	Text       string    `json:"text"`
+	Context    string    `json:"context"`
+	Modified   time.Time `json:"modified"`
+}
+
+// locator converts byte offsets into line and column positions.  Offsets
+// must be supplied in ascending order, which is what FindAllIndex returns, so
+// the file is only walked once per term.
+type locator struct {
+	data      []byte
+	pos       int
+	line      int
+	lineStart int
+}
+
+func newLocator(data []byte) *locator {
+	return &locator{data: data, line: 1}
+}
+
+// locate returns the 1-based line and rune column of the byte offset off.
+func (l *locator) locate(off int) (line int, column int) {
+	if off < l.pos {
+		l.pos, l.line, l.lineStart = 0, 1, 0
+	}
+	for {
+		i := bytes.IndexByte(l.data[l.pos:off], '\n')
+		if i < 0 {
+			break
+		}
+		l.line++
+		l.pos += i + 1
+		l.lineStart = l.pos
+	}
+	l.pos = off
+	return l.line, utf8.RuneCount(l.data[l.lineStart:off]) + 1
+}
+
+// findHits returns every match of the i-th term of q in data, or with first
+// only the first one, with its location resolved.
+func findHits(file string, data []byte, q searchTerms, i int, first bool) []SearchHit {
+	n := -1
+	if first {
+		n = 1
+	}
+	locs := q.findAll(i, data, n)
+	if locs == nil {
+		return nil
+	}
+	loc := newLocator(data)
+	hits := make([]SearchHit, 0, len(locs))
+	for _, m := range locs {
+		hit := q.hit(file, i)
+		hit.Line, hit.Column = loc.locate(m[0])
+		hit.Offset = m[0]
+		hit.Length = utf8.RuneCount(data[m[0]:m[1]])
+		hit.Text = string(data[m[0]:m[1]])
+		hits = append(hits, hit)
+	}
+	return hits
+}
+
+// lineAt returns the full line of data containing the byte offset off,
+// without its line terminator.
+func lineAt(data []byte, off int) string {
+	start := bytes.LastIndexByte(data[:off], '\n') + 1
+	end := bytes.IndexByte(data[off:], '\n')
+	if end < 0 {
+		end = len(data)
+	} else {
+		end += off
+	}
+	return validText(strings.TrimSuffix(string(data[start:end]), "\r"))
+}
+
+// inlineFlagsRe finds the inline flag groups of a regular expression, such as
+// "(?i)", "(?-i)" and "(?is:".
+var inlineFlagsRe = regexp.MustCompile(`\(\?[a-zA-Z]*-?[a-zA-Z]*[:)]`)
+
+// setsCase reports whether term chooses its own case sensitivity with an
+// inline flag, which compileTerms then leaves alone.
+func setsCase(term string) bool {
+	for _, f := range inlineFlagsRe.FindAllString(term, -1) {
+		if strings.ContainsRune(f, 'i') {
+			return true
+		}
+	}
+	return false
+}
+
+// termMode is how search terms are read: regardless of case, with
+// SmartCase only for terms without capitals, as literal text with -F, as
+// whole words with -w, and with Fold regardless of accents; see fold.go.
+type termMode struct {
+	IgnoreCase bool
+	SmartCase  bool
+	Fixed      bool
+	Word       bool
+	Fold       bool
+}
+
+// termModeFor returns the term mode selected on the command line.  Case is
+// ignored unless --case-sensitive or -S is given.
+func termModeFor(params Parameters) termMode {
+	return termMode{IgnoreCase: !params.CaseSensitive, SmartCase: params.SmartCase, Fixed: params.FixedStrings, Word: params.Word, Fold: params.FoldDiacritics}
+}
+
+// hasCapitals reports whether term asks for a capital letter, for -S: one
+// of its literal characters is upper case.  Escapes such as \S and \W and
+// class names such as \p{Lu} don't count.
+func hasCapitals(term string, fixed bool) bool {
+	re, err := syntax.Parse(term, syntax.Perl)
+	if fixed || err != nil {
+		return strings.ToLower(term) != term
+	}
+	var walk func(re *syntax.Regexp) bool
+	walk = func(re *syntax.Regexp) bool {
+		if re.Op == syntax.OpLiteral {
+			for _, r := range re.Rune {
+				if unicode.IsUpper(r) {
+					return true
+				}
+			}
+		}
+		for _, sub := range re.Sub {
+			if walk(sub) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(re)
+}
+
+// isWordByte reports whether b is a letter, digit, or underscore, what \b
+// tells apart from everything else.
+func isWordByte(b byte) bool {
+	return b == '_' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// termPattern returns the regular expression term is searched with in mode
+// m.  A literal term is quoted, and with -w only gets a word boundary at an
+// end that is a word character, so that "c++" still matches "c++ code".  A
+// pattern gets one at both ends.  With Fold, the term is folded as the text
+// it is matched in will be.
+func termPattern(term string, m termMode) string {
+	ignore := ignoresCase(term, m)
+	if folds := termFolds([]string{term}, m); folds != nil {
+		if m.Fixed {
+			term = foldString(term, folds[0])
+		} else {
+			term = foldPattern(term, folds[0])
+		}
+	}
+	pattern := term
+	if m.Fixed {
+		pattern = regexp.QuoteMeta(term)
+	}
+	if m.Word {
+		before, after := `\b`, `\b`
+		if m.Fixed && len(term) > 0 {
+			if !isWordByte(term[0]) {
+				before = ""
+			}
+			if !isWordByte(term[len(term)-1]) {
+				after = ""
+			}
+		}
+		pattern = before + "(?:" + pattern + ")" + after
+	}
+	if ignore {
+		pattern = "(?i)" + pattern
+	}
+	return pattern
+}
+
+// compileTerms compiles every search term into a regular expression as
+// termPattern reads it.  A term that isn't a valid regular expression is
+// named in the error, along with -F.
+func compileTerms(terms []string, m termMode) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, term := range terms {
+		re, err := regexp.Compile(termPattern(term, m))
+		if err != nil {
+			var serr *syntax.Error
+			if errors.As(err, &serr) {
+				err = fmt.Errorf("%s in '%s'", serr.Code, serr.Expr)
+			}
+			return nil, fmt.Errorf("search term '%s' is not a valid regular expression: %v; to search for it as it is, use -F", term, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// searchRange resolves the range search reads.  A --from without --to
+// searches through today, leaving out entries created ahead of time.
+func searchRange(cfg Configuration, params Parameters) (dateRange, error) {
+	r, err := resolveQuery(cfg, queryFor(params), dayNow())
+	if err == nil && len(params.From+params.Since) > 0 && len(params.To) == 0 {
+		today := datePathFromTime(dayNow())
+		r.To = &today
+	}
+	return r, err
+}
+
+// runSearch searches every log file under the configured root for the terms
+// provided and writes the results in the requested format, reporting whether
+// anything matched.  Files that can't be read are noted and left out, unless
+// nothing else matched, which is then an error.
+func runSearch(cfg Configuration, params Parameters) (bool, error) {
+	noteDryRunSearch()
+	if len(params.Term) == 0 && len(params.TagFilter) == 0 {
+		return false, errors.New("nothing to search for; give a term, or --tag=<tag> for the entries carrying a tag")
+	}
+	r, err := searchRange(cfg, params)
+	if err != nil {
+		return false, err
+	}
+	params.FoldDiacritics = params.FoldDiacritics || cfg.SearchFoldDiacritics
+	switch {
+	case params.JSON:
+		params.Format = "json"
+	case params.CSV:
+		params.Format = "csv"
+	case params.Line:
+		params.Format = "line"
+	}
+	var all []Entry
+	if params.AllProfiles {
+		all, err = profileEntries(cfg, params)
+	} else if _, err = os.Stat(cfg.Root); err != nil {
+		return false, fmt.Errorf("the root can't be read: %w", err)
+	} else {
+		all, err = rootEntries(cfg, walkOptionsFor(params))
+	}
+	if err != nil {
+		return false, err
+	}
+	if params.IncludeAttachments && !params.AllProfiles {
+		attachments, err := listAttachments(cfg)
+		if err != nil {
+			return false, err
+		}
+		all = append(all, attachments...)
+		sort.SliceStable(all, func(i, j int) bool { return all[i].Date.Before(&all[j].Date) })
+	}
+	if len(params.Topic) > 0 {
+		if err := checkTopic(params.Topic); err != nil {
+			return false, err
+		}
+		all = filterTopic(all, params.Topic)
+	}
+	entries := skipOnlineOnly(filterEntries(all, r.From, r.To))
+	tagOnly := false
+	if len(params.TagFilter) > 0 {
+		tag, err := normalizeTag(params.TagFilter)
+		if err != nil {
+			return false, err
+		}
+		entries = entriesTagged(cfg, entries, tag)
+		if len(entries) == 0 {
+			if !params.Quiet {
+				fmt.Printf("no entries in range carry %s\n", tag)
+			}
+			return false, nil
+		}
+		if len(params.Term) == 0 {
+			params.Term, tagOnly = []string{tag}, true
+		}
+	}
+	if !params.EntriesOnly && !params.AllProfiles && len(params.Topic) == 0 && r.From == nil && r.To == nil {
+		notes, err := listScratch(cfg)
+		if err != nil {
+			return false, err
+		}
+		entries = append(entries, notes...)
+	}
+	if params.AllNotes {
+		entries = append(entries, listNotes(cfg)...)
+	}
+	if len(all) == 0 && len(entries) == 0 && !params.AllProfiles && !params.IncludeArchives {
+		return false, withExitCode(exitNoFiles, noFilesError(cfg))
+	}
+	m := termModeFor(params)
+	if tagOnly {
+		// --tag alone searches for the tag itself, as tags are matched
+		m = termMode{IgnoreCase: true, Fixed: true, Word: true}
+	}
+	res, err := compileTerms(params.Term, m)
+	if err != nil {
+		return false, err
+	}
+	if cfg.ContextLines == nil && cfg.ContextSize > 0 {
+		log.Println(":::note::: contextSize is deprecated and cuts context mid-line; set context_lines instead")
+	}
+	q := searchTerms{Terms: params.Term, Res: res, Mode: m, Folds: termFolds(params.Term, m), Skip: newBoilerplate(cfg, params), Any: params.Any, FirstOnly: params.FilesWithMatches || params.Quiet, Context: contextLines(cfg)}
+	if params.Quiet && (params.Explain || params.Follow) {
+		return false, errors.New("-q prints nothing and can't be combined with --explain or --follow")
+	}
+	if params.Explain {
+		// Keep the output that scripts read free of the explanation.
+		w := io.Writer(os.Stdout)
+		if params.FilesWithMatches || (params.Format != "" && params.Format != "human") {
+			w = os.Stderr
+		}
+		if err := explainSearch(w, cfg, params, res); err != nil {
+			return false, err
+		}
+	}
+
+	if (params.FilesWithMatches || params.CountMatches) && (params.Format != "human" && params.Format != "" || params.InlineDates || params.Follow) {
+		return false, errors.New("-l and -c print no context and can't be combined with --format, --json, --csv, --line, --inline-dates, or --follow")
+	}
+	if params.Follow && params.Format != "" && params.Format != "human" && params.Format != "grep" {
+		return false, errors.New("--follow works with the human and grep formats only")
+	}
+	if params.OpenEditor && (params.FilesWithMatches || params.CountMatches || params.Quiet || params.Follow || params.Format != "" && params.Format != "human") {
+		return false, errors.New("--open starts the editor instead of printing hits and can't be combined with -l, -c, -q, --follow, or another format")
+	}
+	if params.Follow && params.AllProfiles {
+		return false, errors.New("--follow can't be combined with --all-profiles")
+	}
+	if params.IncludeArchives && (params.OpenEditor || params.Follow || params.AllProfiles || len(params.TagFilter) > 0) {
+		return false, errors.New("--include-archives can't be combined with --open, --follow, --all-profiles, or --tag")
+	}
+	searched := entries
+	// the index knows the words of entries as they are written, accents and all
+	if cfg.Index && !params.AllProfiles && !params.FoldDiacritics {
+		entries = indexedCandidates(cfg, entries, q)
+	}
+	results, failed := scanFiles(cfg, entries, q)
+	if params.IncludeArchives {
+		archived, unreadable := scanArchives(cfg, r, params.Topic, q)
+		results, failed = append(archived, results...), append(failed, unreadable...)
+	}
+	found := false
+	for _, r := range results {
+		if len(r.Hits) > 0 {
+			found = true
+			break
+		}
+	}
+	if !found && len(failed) > 0 {
+		noteUnreadable(failed)
+		return false, fmt.Errorf("%d of %d files couldn't be read, and the others don't match", len(failed), len(entries))
+	}
+	if params.Quiet {
+		noteUnreadable(failed)
+		return found, nil
+	}
+	if params.OpenEditor {
+		noteUnreadable(failed)
+		if !found {
+			explainNoMatches(os.Stdout, cfg, params, all, searched)
+			return false, nil
+		}
+		return true, openSearchHit(cfg, results, params.First)
+	}
+	if params.FilesWithMatches {
+		err := searchFilesWithMatches(os.Stdout, results, params.Print0)
+		noteUnreadable(failed)
+		return found, err
+	}
+	if params.CountMatches {
+		err := searchCount(os.Stdout, results)
+		noteUnreadable(failed)
+		return found, err
+	}
+
+	switch params.Format {
+	case "", "human":
+		style := humanStyle{InlineDates: params.InlineDates, Dim: colorful(), Color: colorful()}
+		search := searchHuman
+		if plainOutput {
+			search = searchPlain
+		}
+		sum := search(os.Stdout, cfg, results, q, style)
+		if sum.Files == 0 {
+			explainNoMatches(os.Stdout, cfg, params, all, searched)
+		}
+		if q.Skip != nil {
+			sum.print(os.Stdout)
+		}
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(collectHits(cfg, results))
+	case "csv":
+		err = writeHitsCSV(os.Stdout, collectHits(cfg, results))
+	case "line":
+		err = writeHitLines(os.Stdout, cfg, results)
+	case "grep":
+		for _, r := range results {
+			for i, hit := range r.Hits {
+				fmt.Printf("%s:%d:%d:%s\n", displayPath(hit.File), hit.Line, hit.Column, r.line(i))
+			}
+		}
+	default:
+		return false, fmt.Errorf("unknown search format '%s'", params.Format)
+	}
+	noteUnreadable(failed)
+	if err != nil {
+		return found, err
+	}
+	if params.Follow {
+		return found, followSearch(os.Stdout, cfg, params, r, q)
+	}
+	return found, nil
+}
+
+// openSearchHit starts the editor at the first hit of the one file with
+// hits, or with first, of the newest of them.  Several files with hits
+// are listed instead, and the error returned then exits 1, as there is no
+// telling which was meant.
+func openSearchHit(cfg Configuration, results []fileResult, first bool) error {
+	var hit []fileResult
+	for _, r := range results {
+		if len(r.Hits) > 0 {
+			hit = append(hit, r)
+		}
+	}
+	if len(hit) > 1 && !first {
+		for _, r := range hit {
+			fmt.Printf("%s  %s\n", hitLabel(r.Entry), displayPath(r.Entry.Path))
+		}
+		return withExitCode(exitFailure, fmt.Errorf("%d files match; narrow the search with a date or --topic, or add --first to open the newest", len(hit)))
+	}
+	r := hit[len(hit)-1]
+	e := r.Entry
+	line := r.Hits[0].Line
+	t := editTarget{Kind: kindEntry, Date: &e.Date}
+	if len(e.Scratch) > 0 || len(e.Attachment) > 0 {
+		t = editTarget{Kind: kindScratch}
+	} else if len(e.Note) > 0 {
+		t = editTarget{Kind: kindNote}
+	}
+	tracef("search: opening %s at line %d", e.Path, line)
+	return editFiles(cfg, fileEdit{Target: t, Paths: []string{e.Path}, Dates: []*DatePath{t.Date}, Topic: e.Topic, Line: line})
+}
+
+// noFilesError says that the root has nothing to search, which is told apart
+// from a search that found nothing as it is usually a root set to the wrong
+// directory: it names the root as expanded and the paths entries were
+// looked for at.
+func noFilesError(cfg Configuration) error {
+	root := cfg.Root
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	roots := []string{root}
+	roots = append(roots, cfg.ExtraRoots...)
+	var patterns []string
+	for _, r := range roots {
+		patterns = append(patterns, filepath.Join(r, entryLayout(cfg).pattern()))
+	}
+	return fmt.Errorf("no files to search: 0 files match %s; check that root (%s) is the directory the entries are in", strings.Join(patterns, ", "), root)
+}
+
+// searchFilesWithMatches writes only the paths of entries that match,
+// separated by newlines or, with print0, NUL bytes.  Nothing else is written
+// to w so the output can be fed straight to xargs.
+func searchFilesWithMatches(w io.Writer, results []fileResult, print0 bool) error {
+	sep := "\n"
+	if print0 {
+		sep = "\x00"
+	}
+	for _, r := range results {
+		if len(r.Hits) > 0 {
+			if _, err := io.WriteString(w, displayPath(r.Entry.Path)+sep); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hitLabel names the file e in the output of -c and --line: its date, with
+// its topic, profile, or attachment, or its name for a scratch note.
+func hitLabel(e Entry) string {
+	label := e.Date.Iso() + topicLabel(e) + profileLabel(e) + archiveLabel(e)
+	switch {
+	case len(e.Attachment) > 0:
+		label += " (attachment " + e.Attachment + ")"
+	case len(e.Scratch) > 0:
+		label = "scratch " + e.Scratch
+	case len(e.Note) > 0:
+		label = "note " + e.Note
+	}
+	return label
+}
+
+// searchCount writes how many hits each entry with any has, labelled with
+// its date, or its name for scratch notes.
+func searchCount(w io.Writer, results []fileResult) error {
+	for _, r := range results {
+		if len(r.Hits) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s: %d\n", hitLabel(r.Entry), len(r.Hits)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSearchable reads a file to search, reporting whether it is text.
+// Binary files are skipped silently, and ones that can't be read with the
+// error.  Entries in a legacy encoding are still searched; what is shown from
+// them is made valid UTF-8.
+func readSearchable(cfg Configuration, e Entry) ([]byte, bool, error) {
+	data, err := readEntry(cfg, e.Path)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(e.Attachment) == 0 {
+		return data, bytes.IndexByte(data, 0) < 0, nil
+	}
+	return data, !isBinary(data), nil
+}
+
+// describeHit fills in what hit says about e, the file it was found in.
+func describeHit(hit SearchHit, e Entry) SearchHit {
+	hit.File = displayPath(hit.File)
+	hit.Kind, hit.Date, hit.Attachment, hit.Topic = "entry", e.Date.Iso(), e.Attachment, e.Topic
+	hit.Profile, hit.Editor = e.Profile, e.Editor
+	if len(e.Archive) > 0 {
+		hit.Archive = displayPath(e.Archive)
+	}
+	switch {
+	case len(e.Attachment) > 0:
+		hit.Kind = "attachment"
+	case len(e.Scratch) > 0:
+		hit.Kind, hit.Date, hit.Scratch = "scratch", "", e.Scratch
+	case len(e.Note) > 0:
+		hit.Kind, hit.Date, hit.Note = "note", "", e.Note
+	}
+	hit.Modified = e.ModTime
+	return hit
+}
+
+// collectHits gathers the hits of every file that matches.
+func collectHits(cfg Configuration, results []fileResult) []SearchHit {
+	hits := []SearchHit{}
+	for _, r := range results {
+		for i, hit := range r.Hits {
+			var context []string
+			for _, l := range contextFor(cfg, r, i) {
+				context = append(context, l.Text)
+			}
+			hit = describeHit(hit, r.Entry)
+			hit.Context = strings.Join(context, "\n")
+			hits = append(hits, hit)
+		}
+	}
+	return hits
+}
+
+// maxHitLine is the most runes of a line --line prints; longer lines are
+// cut around the match.
+const maxHitLine = 500
+
+// excerpt returns line, or when it is longer than max runes, the max runes
+// of it centred on the match at the 1-based rune column col, length runes
+// long, with an ellipsis where it was cut.
+func excerpt(line string, col, length, max int) string {
+	runes := []rune(line)
+	if len(runes) <= max {
+		return line
+	}
+	start := col - 1 + length/2 - max/2
+	if start > len(runes)-max {
+		start = len(runes) - max
+	}
+	if start < 0 {
+		start = 0
+	}
+	out := string(runes[start : start+max])
+	if start > 0 {
+		out = "…" + out
+	}
+	if start+max < len(runes) {
+		out += "…"
+	}
+	return out
+}
+
+// writeHitLines writes one line per hit, as "2024-03-07:42: the line", with
+// the line as it is, tabs kept, and no context.  Hits on the same line are
+// printed once for each.
+func writeHitLines(w io.Writer, cfg Configuration, results []fileResult) error {
+	for _, r := range results {
+		for i, hit := range r.Hits {
+			hit = describeHit(hit, r.Entry)
+			text := excerpt(r.line(i), hit.Column, hit.Length, maxHitLine)
+			if _, err := fmt.Fprintf(w, "%s:%d: %s\n", hitLabel(r.Entry), hit.Line, text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeHitsCSV writes hits as CSV with a header row.  The context is a
+// single field, quoted with its line breaks.
+func writeHitsCSV(w io.Writer, hits []SearchHit) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"kind", "date", "topic", "file", "term", "line", "column", "offset", "length", "text", "context"})
+	for _, h := range hits {
+		date := h.Date
+		switch h.Kind {
+		case "scratch":
+			date = h.Scratch
+		case "note":
+			date = h.Note
+		}
+		cw.Write([]string{h.Kind, date, h.Topic, h.File, h.Term, strconv.Itoa(h.Line), strconv.Itoa(h.Column),
+			strconv.Itoa(h.Offset), strconv.Itoa(h.Length), h.Text, h.Context})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// dateMarkerEvery is how many context blocks of one file are shown between
+// repeats of its date.
+const dateMarkerEvery = 5
+
+// humanStyle adjusts the human search output.  InlineDates prefixes every
+// context block with the entry's date; Dim renders the repeated date markers
+// in faint text for terminals, and Color highlights the matches and the date
+// headers.
+type humanStyle struct {
+	InlineDates bool
+	Dim         bool
+	Color       bool
+}
+
+// humanDate is an entry's date as shown next to search results.
+func humanDate(dp DatePath) string {
+	return fmt.Sprintf("%s %s", weekdayAbbr(dp.Time().Weekday()), dp.Iso())
+}
+
+// fileResult is what a search found in one file: its hits for every term in
+// the order they appear in the file, with Terms[i] the term, as given, that
+// produced Hits[i], and the number of hits Hidden in boilerplate.  Data is
+// the content of the file, or for a streamed one nil, with Contexts[i] the
+// context of Hits[i] instead.  Err is why the file couldn't be read.
+type fileResult struct {
+	Entry    Entry
+	Data     []byte
+	Hits     []SearchHit
+	Terms    []string
+	Contexts [][]snippetLine
+	Hidden   int
+	Err      error
+}
+
+// line returns the line Hits[i] is on, without its line terminator.
+func (r fileResult) line(i int) string {
+	if r.Contexts == nil {
+		return lineAt(r.Data, r.Hits[i].Offset)
+	}
+	for _, l := range r.Contexts[i] {
+		if l.Match {
+			return l.Text
+		}
+	}
+	return ""
+}
+
+// searchTerms is what a search looks for: the terms as given and compiled,
+// the boilerplate to ignore, and whether a file matches when every term is
+// found in it, the default, or with Any when one of them is.  With
+// FirstOnly, only whether a file matches is wanted, and its hits are cut
+// short: at most one per term, and none once the outcome is decided.
+// Context is the number of lines of context streamed files keep.  Folds is
+// how the text each term is matched in is folded, nil unless diacritics are,
+// and Mode how the terms were compiled.
+type searchTerms struct {
+	Terms     []string
+	Res       []*regexp.Regexp
+	Mode      termMode
+	Folds     []textFold
+	Skip      *boilerplate
+	Any       bool
+	FirstOnly bool
+	Context   int
+}
+
+// hit returns a hit in file for the i-th term, with the term as given and
+// how it was matched, for the caller to locate.
+func (q searchTerms) hit(file string, i int) SearchHit {
+	return SearchHit{
+		File:  file,
+		Term:  q.Terms[i],
+		Case:  !ignoresCase(q.Terms[i], q.Mode),
+		Word:  q.Mode.Word,
+		Fold:  q.Mode.Fold,
+		Fixed: q.Mode.Fixed,
+	}
+}
+
+// searchFile evaluates every term against e before deciding whether it
+// matches, leaving out hits in its boilerplate.  A file that doesn't match
+// has no hits; false reports that it couldn't be searched at all.  Large
+// files are streamed rather than read whole.
+func searchFile(cfg Configuration, e Entry, q searchTerms) (fileResult, bool) {
+	if streamable(e) {
+		if r, ok, streamed := streamSearch(e, q); streamed {
+			return r, ok
+		}
+	}
+	data, ok, err := readSearchable(cfg, e)
+	if !ok {
+		return fileResult{Entry: e, Err: err}, false
+	}
+	return searchData(e, data, q), true
+}
+
+// searchData finds the terms of q in data, the content of e.
+func searchData(e Entry, data []byte, q searchTerms) fileResult {
+	r := fileResult{Entry: e, Data: data}
+	skip := q.Skip.regions(e, data)
+	found := 0
+	for i := range q.Res {
+		if q.FirstOnly && ((q.Any && found > 0) || (!q.Any && found < i)) {
+			break
+		}
+		n := len(r.Hits)
+		hits := findHits(e.Path, data, q, i, q.FirstOnly && skip == nil)
+		for _, hit := range hits {
+			if skip.contains(hit.Offset) {
+				r.Hidden++
+				continue
+			}
+			r.Hits = append(r.Hits, hit)
+			r.Terms = append(r.Terms, q.Terms[i])
+			if q.FirstOnly {
+				break
+			}
+		}
+		if len(r.Hits) > n {
+			found++
+		}
+	}
+	if found == 0 || (!q.Any && found < len(q.Res)) {
+		r.Hits, r.Terms = nil, nil
+		return r
+	}
+	sort.Stable(byOffset(r))
+	return r
+}
+
+// searchSummary counts what the human search output showed: the files and
+// hits shown and the hits hidden in boilerplate.
+type searchSummary struct {
+	Files  int
+	Hits   int
+	Hidden int
+}
+
+// add counts r, reporting whether it has hits to show.
+func (s *searchSummary) add(r fileResult) bool {
+	s.Hidden += r.Hidden
+	if len(r.Hits) == 0 {
+		return false
+	}
+	s.Files++
+	s.Hits += len(r.Hits)
+	return true
+}
+
+// print writes the summary line shown when boilerplate is hidden, so that
+// hits left out are never swallowed silently.
+func (s searchSummary) print(w io.Writer) {
+	if plainOutput {
+		fmt.Fprintf(w, "matches: %d, boilerplate matches hidden: %d\n", s.Hits, s.Hidden)
+		return
+	}
+	fmt.Fprintf(w, "%d matches, %d boilerplate matches hidden\n", s.Hits, s.Hidden)
+}
+
+// byOffset sorts the hits of a fileResult, with their terms, by position.
+type byOffset fileResult
+
+func (r byOffset) Len() int           { return len(r.Hits) }
+func (r byOffset) Less(i, j int) bool { return r.Hits[i].Offset < r.Hits[j].Offset }
+func (r byOffset) Swap(i, j int) {
+	r.Hits[i], r.Hits[j] = r.Hits[j], r.Hits[i]
+	r.Terms[i], r.Terms[j] = r.Terms[j], r.Terms[i]
+	if r.Contexts != nil {
+		r.Contexts[i], r.Contexts[j] = r.Contexts[j], r.Contexts[i]
+	}
+}
+
+// defaultContextLines is how many lines search shows on either side of the
+// lines a match is on.
+const defaultContextLines = 2
+
+// snippetLine is one line of the context shown around a match.  Number is
+// its 1-based line in the file, or 0 in byte mode, and Match is set for the
+// lines the match is on.
+type snippetLine struct {
+	Number int
+	Text   string
+	Match  bool
+	// Start and End are the byte range of data Text was taken from, its
+	// line terminator left out, and raw those bytes as they are.
+	Start, End int
+	raw        string
+}
+
+// lineStart returns the offset of the start of the line containing off.
+func lineStart(data []byte, off int) int {
+	return bytes.LastIndexByte(data[:off], '\n') + 1
+}
+
+// lineEnd returns the offset just past the end of the line containing off,
+// its terminator included.
+func lineEnd(data []byte, off int) int {
+	i := bytes.IndexByte(data[off:], '\n')
+	if i < 0 {
+		return len(data)
+	}
+	return off + i + 1
+}
+
+// contextSnippet returns the lines of data a match at loc, a byte range as
+// returned by FindIndex, is on, with up to n lines before and after, clamped
+// to the start and end of the file.  Lines are never cut, so neither are
+// runes.
+func contextSnippet(data []byte, loc []int, n int) []snippetLine {
+	last := loc[1]
+	if last > loc[0] {
+		last--
+	}
+	start, end := lineStart(data, loc[0]), lineEnd(data, last)
+	for i := 0; i < n && start > 0; i++ {
+		start = lineStart(data, start-1)
+	}
+	for i := 0; i < n && end < len(data); i++ {
+		end = lineEnd(data, end)
+	}
+	number := bytes.Count(data[:start], []byte("\n")) + 1
+	var lines []snippetLine
+	off := start
+	for _, l := range splitLines(data[start:end]) {
+		text := strings.TrimRight(string(l), "\r\n")
+		lines = append(lines, snippetLine{
+			Number: number,
+			Text:   validText(text),
+			Match:  off <= last && off+len(l) > loc[0],
+			Start:  off,
+			End:    off + len(text),
+			raw:    text,
+		})
+		number++
+		off += len(l)
+	}
+	return lines
+}
+
+// byteSnippet is the deprecated byte mode of contextSnippet: up to size
+// bytes on either side of the middle of the match, and at least the match,
+// moved inwards to whole runes.  A window that comes out empty, as for an
+// empty match, is the whole line of the match instead.
+func byteSnippet(data []byte, loc []int, size int) []snippetLine {
+	mid := loc[0] + (loc[1]-loc[0])/2
+	lb, rb := mid-size, mid+size
+	if lb > loc[0] {
+		lb = loc[0]
+	}
+	if rb < loc[1] {
+		rb = loc[1]
+	}
+	if lb < 0 {
+		lb = 0
+	}
+	if rb > len(data) {
+		rb = len(data)
+	}
+	for lb < loc[0] && !utf8.RuneStart(data[lb]) {
+		lb++
+	}
+	for rb > loc[1] && rb < len(data) && !utf8.RuneStart(data[rb]) {
+		rb--
+	}
+	if lb >= rb {
+		return contextSnippet(data, loc, 0)
+	}
+	var lines []snippetLine
+	off := lb
+	for _, l := range strings.Split(string(data[lb:rb]), "\n") {
+		lines = append(lines, snippetLine{Text: validText(l), Start: off, End: off + len(l), raw: l})
+		off += len(l) + 1
+	}
+	return lines
+}
+
+// markMatches returns the text of l with every hit on it highlighted, hits
+// of any term.  The line is rebuilt from its bytes between the hits, left to
+// right, so the escapes inserted never move the offsets of the hits after
+// them; where hits overlap, the later one is cut to what is left.
+func markMatches(l snippetLine, hits []SearchHit) string {
+	var ranges [][2]int
+	for _, h := range hits {
+		start, end := h.Offset, h.Offset+len(h.Text)
+		if start < l.Start {
+			start = l.Start
+		}
+		if end > l.End {
+			end = l.End
+		}
+		if start < end {
+			ranges = append(ranges, [2]int{start, end})
+		}
+	}
+	if len(ranges) == 0 {
+		return l.Text
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var b strings.Builder
+	pos := l.Start
+	for _, r := range ranges {
+		if r[1] <= pos {
+			continue
+		}
+		if r[0] < pos {
+			r[0] = pos
+		}
+		b.WriteString(validText(l.raw[pos-l.Start : r[0]-l.Start]))
+		b.WriteString(highlight(validText(l.raw[r[0]-l.Start : r[1]-l.Start])))
+		pos = r[1]
+	}
+	b.WriteString(validText(l.raw[pos-l.Start:]))
+	return b.String()
+}
+
+// contextLines is the context_lines setting, defaultContextLines when unset.
+func contextLines(cfg Configuration) int {
+	if cfg.ContextLines != nil {
+		return *cfg.ContextLines
+	}
+	return defaultContextLines
+}
+
+// contextFor returns the context shown around Hits[i] of r: context_lines
+// lines, or contextSize bytes for configurations that only set that.  A
+// streamed file has its context gathered already.
+func contextFor(cfg Configuration, r fileResult, i int) []snippetLine {
+	if r.Contexts != nil {
+		return r.Contexts[i]
+	}
+	hit := r.Hits[i]
+	loc := []int{hit.Offset, hit.Offset + len(hit.Text)}
+	if cfg.ContextLines == nil && cfg.ContextSize > 0 {
+		return byteSnippet(r.Data, loc, cfg.ContextSize)
+	}
+	return contextSnippet(r.Data, loc, contextLines(cfg))
+}
+
+// searchHuman writes the search results as context blocks for reading in a
+// terminal, under a header naming the date of each matching file, and
+// returns what it showed.  Hits are numbered through the
+// file and labelled with the term they matched.  Long lists of blocks repeat
+// the date every few blocks so it stays in view.
+func searchHuman(w io.Writer, cfg Configuration, results []fileResult, q searchTerms, style humanStyle) searchSummary {
+	fmt.Fprintln(w, "searching for", q.Terms)
+	var sum searchSummary
+	for _, r := range results {
+		if !sum.add(r) {
+			continue
+		}
+		e := r.Entry
+		head := func(s string) string { return s }
+		if style.Color {
+			head = heading
+		}
+		date := humanDate(e.Date) + profileLabel(e) + archiveLabel(e)
+		switch {
+		case len(e.Attachment) > 0:
+			fmt.Fprintf(w, "%s (attachment %s)\n----------\n\n", head(date), e.Attachment)
+		case len(e.Scratch) > 0:
+			date = "scratch " + e.Scratch
+			fmt.Fprintf(w, "%s\n----------\n\n", head("scratch note "+e.Scratch))
+		case len(e.Note) > 0:
+			date = "note " + e.Note
+			fmt.Fprintf(w, "%s\n----------\n\n", head(date))
+		default:
+			date += topicLabel(e)
+			fmt.Fprintf(w, "%s\n----------\n\n", head(date))
+		}
+		for i := range r.Hits {
+			context := ""
+			for _, line := range contextFor(cfg, r, i) {
+				mark := " "
+				if line.Match {
+					mark = ">"
+				}
+				text := line.Text
+				if style.Color {
+					text = markMatches(line, r.Hits)
+				}
+				context += fmt.Sprintf("\t%s %s\n", mark, text)
+			}
+			switch {
+			case style.InlineDates:
+				fmt.Fprintf(w, "[%s] ", head(date))
+			case i > 0 && i%dateMarkerEvery == 0:
+				fmt.Fprintln(w, faint("· "+date+" ·"))
+			}
+			fmt.Fprintf(w, "%d (%s):\n%s\n", i+1, r.Terms[i], context)
+		}
+	}
+	return sum
+}
+
+// searchPlain is searchHuman for plain output: every file and match is
+// described in "label: value" lines, with the matching line and its context
+// spelled out and no separators to read past.
+func searchPlain(w io.Writer, cfg Configuration, results []fileResult, q searchTerms, _ humanStyle) searchSummary {
+	fmt.Fprintf(w, "searching for: %s\n", strings.Join(q.Terms, ", "))
+	var sum searchSummary
+	for _, r := range results {
+		if !sum.add(r) {
+			continue
+		}
+		e := r.Entry
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "file: %s\n", displayPath(e.Path))
+		if len(e.Scratch) > 0 {
+			fmt.Fprintf(w, "scratch: %s\n", e.Scratch)
+		} else if len(e.Note) > 0 {
+			fmt.Fprintf(w, "note: %s\n", e.Note)
+		} else {
+			fmt.Fprintf(w, "date: %s\n", humanDate(e.Date))
+		}
+		if len(e.Topic) > 0 {
+			fmt.Fprintf(w, "topic: %s\n", e.Topic)
+		}
+		if len(e.Profile) > 0 {
+			fmt.Fprintf(w, "profile: %s\n", e.Profile)
+		}
+		if len(e.Attachment) > 0 {
+			fmt.Fprintf(w, "attachment: %s\n", e.Attachment)
+		}
+		for i, hit := range r.Hits {
+			fmt.Fprintf(w, "match %d: term %s, line %d: %s\n", i+1, r.Terms[i], hit.Line, strings.TrimSpace(r.line(i)))
+			var context []string
+			for _, line := range contextFor(cfg, r, i) {
+				context = append(context, strings.Fields(line.Text)...)
+			}
+			fmt.Fprintf(w, "context: %s\n", strings.Join(context, " "))
+		}
+	}
+	return sum
+}
+
+func matchesAny(data []byte, q searchTerms) bool {
+	for i := range q.Res {
+		if q.findAll(i, data, 1) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hintSampleSize bounds how many entries the no-match hints read.
+const hintSampleSize = 200
+
+// sampleEntries returns at most n entries spread evenly over entries.
+func sampleEntries(entries []Entry, n int) []Entry {
+	if len(entries) <= n {
+		return entries
+	}
+	sample := make([]Entry, 0, n)
+	for i := 0; i < n; i++ {
+		sample = append(sample, entries[i*len(entries)/n])
+	}
+	return sample
+}
+
+// sampleMatches reports whether any entry of a bounded sample matches one
+// of terms, compiled as res in mode m.  Large entries are streamed, as
+// searching them is.
+func sampleMatches(cfg Configuration, entries []Entry, terms []string, res []*regexp.Regexp, m termMode) bool {
+	q := searchTerms{Terms: terms, Res: res, Mode: m, Folds: termFolds(terms, m), Any: true, FirstOnly: true}
+	for _, e := range sampleEntries(entries, hintSampleSize) {
+		if streamable(e) {
+			if r, ok, streamed := streamSearch(e, q); streamed {
+				if ok && len(r.Hits) > 0 {
+					return true
+				}
+				continue
+			}
+		}
+		data, err := readEntry(cfg, e.Path)
+		if err == nil && matchesAny(data, q) {
+			return true
+		}
+	}
+	return false
+}
+
+// explainNoMatches says plainly that nothing matched and where it looked,
+// with hints when a case-insensitive search or a wider range would have found
+// something.  The hints only read a bounded sample of entries.
+func explainNoMatches(w io.Writer, cfg Configuration, params Parameters, all []Entry, searched []Entry) {
+	quoted := make([]string, len(params.Term))
+	for i, t := range params.Term {
+		quoted[i] = "'" + t + "'"
+	}
+	// Scratch and other notes have no date, so the span is of the entries
+	// alone and the notes are counted apart.
+	var dated []Entry
+	scratch, notes := 0, 0
+	for _, e := range searched {
+		switch {
+		case len(e.Scratch) > 0:
+			scratch++
+		case len(e.Note) > 0:
+			notes++
+		default:
+			dated = append(dated, e)
+		}
+	}
+	span := ""
+	if len(dated) > 0 {
+		span = fmt.Sprintf(" (%s to %s)", dated[0].Date.Iso(), dated[len(dated)-1].Date.Iso())
+	}
+	noun := "entries"
+	if len(dated) == 1 {
+		noun = "entry"
+	}
+	joiner := " and "
+	if params.Any {
+		joiner = " or "
+	}
+	fmt.Fprintf(w, "no matches for %s across %d %s%s", strings.Join(quoted, joiner), len(dated), noun, span)
+	for _, n := range []struct {
+		count int
+		noun  string
+	}{{scratch, "scratch note"}, {notes, "note"}} {
+		if n.count == 1 {
+			fmt.Fprintf(w, ", 1 %s", n.noun)
+		} else if n.count > 1 {
+			fmt.Fprintf(w, ", %d %ss", n.count, n.noun)
+		}
+	}
+	fmt.Fprintln(w)
+
+	if len(params.Term) > 1 && !params.Any {
+		if res, err := compileTerms(params.Term, termModeFor(params)); err == nil && sampleMatches(cfg, searched, params.Term, res, termModeFor(params)) {
+			fmt.Fprintln(w, "hint: some entries match one of the terms but not all of them; try --any")
+		}
+	}
+
+	if m := termModeFor(params); !m.IgnoreCase || m.SmartCase {
+		flag := "--case-sensitive"
+		if m.SmartCase {
+			flag = "-S, or write the terms in lower case"
+		}
+		m.IgnoreCase, m.SmartCase = true, false
+		if res, err := compileTerms(params.Term, m); err == nil && sampleMatches(cfg, searched, params.Term, res, m) {
+			fmt.Fprintf(w, "hint: some entries match when ignoring case; drop %s\n", flag)
+		}
+	}
+	if len(dated) < len(all) {
+		var outside []Entry
+		in := map[string]bool{}
+		for _, e := range searched {
+			in[e.Path] = true
+		}
+		for _, e := range all {
+			if !in[e.Path] {
+				outside = append(outside, e)
+			}
+		}
+		res, err := compileTerms(params.Term, termModeFor(params))
+		if err == nil && sampleMatches(cfg, outside, params.Term, res, termModeFor(params)) {
+			fmt.Fprintf(w, "hint: there are matches outside the selected range; widen or drop it to see them\n")
+		} else {
+			fmt.Fprintf(w, "hint: the range filters left out %d of %d entries; try widening it\n", len(outside), len(all))
+		}
+	}
+}
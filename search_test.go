@@ -0,0 +1,513 @@
+package wm
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/docopt/docopt-go"
+)
+
+// testTerms compiles terms in mode m as runSearch does.
+func testTerms(t *testing.T, m termMode, terms ...string) searchTerms {
+	t.Helper()
+	res, err := compileTerms(terms, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return searchTerms{Terms: terms, Res: res, Mode: m, Folds: termFolds(terms, m)}
+}
+
+// testSearchBoth searches text both read whole and streamed, returning the
+// hits of each.
+func testSearchBoth(t *testing.T, text string, q searchTerms) (whole, streamed []SearchHit) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "7.txt")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := Entry{Path: path}
+	r, ok, done := streamSearch(e, q)
+	if !ok || !done {
+		t.Fatalf("streamSearch = %v, %v", ok, done)
+	}
+	return searchData(e, []byte(text), q).Hits, r.Hits
+}
+
+func TestSearchHitTerm(t *testing.T) {
+	tests := []struct {
+		m                        termMode
+		term                     string
+		text                     string
+		caseSensitive, word, fix bool
+	}{
+		{termMode{IgnoreCase: true}, "hello", "Hello there\n", false, false, false},
+		{termMode{IgnoreCase: true, SmartCase: true}, "Hello", "Hello there\n", true, false, false},
+		{termMode{IgnoreCase: true}, "(?-i)Hello", "Hello there\n", true, false, false},
+		{termMode{IgnoreCase: true, Fixed: true, Word: true}, "#tag1", "a #tag1 b\n", false, true, true},
+		{termMode{}, "h.llo", "hello\n", true, false, false},
+	}
+	for _, tt := range tests {
+		whole, streamed := testSearchBoth(t, tt.text, testTerms(t, tt.m, tt.term))
+		for _, hits := range [][]SearchHit{whole, streamed} {
+			if len(hits) != 1 {
+				t.Errorf("%q in %q: %d hits, want 1", tt.term, tt.text, len(hits))
+				continue
+			}
+			h := hits[0]
+			if h.Term != tt.term || h.Case != tt.caseSensitive || h.Word != tt.word || h.Fixed != tt.fix || h.Fold {
+				t.Errorf("%q in mode %+v: term %q, case %v, word %v, fixed %v, fold %v; want the term as given, case %v, word %v, fixed %v",
+					tt.term, tt.m, h.Term, h.Case, h.Word, h.Fixed, h.Fold, tt.caseSensitive, tt.word, tt.fix)
+			}
+		}
+	}
+}
+
+func TestSearchHitColumns(t *testing.T) {
+	tests := []struct {
+		text, term   string
+		line, column int
+		length       int
+	}{
+		{"plain text\n", "text", 1, 7, 4},
+		{"header\n🎉 party time\n", "party", 2, 3, 5},
+		{"👩‍💻 at work\n", "work", 1, 8, 4}, // three runes joined
+		{"会議の議事録\n", "議事", 1, 4, 2},
+		{"日本語 and 中文 notes\n", "中文", 1, 9, 2},
+		{"tab\there\n", "here", 1, 5, 4},
+		{"café and cafe\n", "cafe", 1, 10, 4},
+		{"crlf\r\nline two 🎉\r\n", "two", 2, 6, 3},
+	}
+	for _, tt := range tests {
+		q := testTerms(t, termMode{IgnoreCase: true, Fixed: true}, tt.term)
+		whole, streamed := testSearchBoth(t, tt.text, q)
+		for _, hits := range [][]SearchHit{whole, streamed} {
+			if len(hits) != 1 {
+				t.Errorf("%q in %q: %d hits, want 1", tt.term, tt.text, len(hits))
+				continue
+			}
+			h := hits[0]
+			if h.Line != tt.line || h.Column != tt.column || h.Length != tt.length {
+				t.Errorf("%q in %q at %d:%d, length %d; want %d:%d, length %d",
+					tt.term, tt.text, h.Line, h.Column, h.Length, tt.line, tt.column, tt.length)
+			}
+			if got := tt.text[h.Offset : h.Offset+len(h.Text)]; got != h.Text || h.Text != tt.term {
+				t.Errorf("%q in %q: offset %d holds %q, text %q", tt.term, tt.text, h.Offset, got, h.Text)
+			}
+		}
+	}
+}
+
+func TestRunSearchWithoutTerms(t *testing.T) {
+	cfg := Configuration{Root: t.TempDir()}
+	testEntries(t, cfg.Root, DatePath{2024, 3, 7})
+	found, err := runSearch(cfg, Parameters{Search: true})
+	if err == nil || found {
+		t.Errorf("runSearch without terms = %v, %v, want an error", found, err)
+	}
+}
+
+func TestSearchPrint0Stdout(t *testing.T) {
+	root := t.TempDir()
+	testEntries(t, root, DatePath{2024, 3, 7}, DatePath{2024, 3, 8}, DatePath{2024, 3, 9})
+	for _, name := range []string{"7.txt", "9.txt"} {
+		f, err := os.OpenFile(filepath.Join(root, "2024", "3", name), os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString("kubecon speaker\n")
+		f.Close()
+	}
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+
+	stdout, stderr, code := runWM(t, root, env, "search", "kubecon", "-l", "-0", "--verbose")
+	want := filepath.Join(root, "2024", "3", "7.txt") + "\x00" + filepath.Join(root, "2024", "3", "9.txt") + "\x00"
+	if code != 0 || string(stdout) != want {
+		t.Errorf("wm search -l -0 exited %d with stdout %q, want only %q\nstderr:\n%s", code, stdout, want, stderr)
+	}
+
+	stdout, _, code = runWM(t, root, env, "search", "nowhere", "-l", "-0")
+	if code != exitNoMatch || len(stdout) > 0 {
+		t.Errorf("wm search -l -0 without a match exited %d with stdout %q, want %d and nothing", code, stdout, exitNoMatch)
+	}
+}
+
+// snippetText joins the text of lines as they are shown.
+func snippetText(lines []snippetLine) string {
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		texts[i] = l.Text
+	}
+	return strings.Join(texts, "\n")
+}
+
+const snippetData = "alpha beta\ngamma delta\nepsilon zeta\n"
+
+// matchOf returns the location of the first match of s in data.
+func matchOf(t *testing.T, data, s string) []int {
+	t.Helper()
+	i := strings.Index(data, s)
+	if i < 0 {
+		t.Fatalf("%q not in %q", s, data)
+	}
+	return []int{i, i + len(s)}
+}
+
+func TestByteSnippet(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		match string
+		size  int
+		want  string
+	}{
+		{"at offset 0", snippetData, "alpha", 3, "alpha"},
+		{"at offset 0, size 0", snippetData, "alpha", 0, "alpha"},
+		{"at offset 0, wide", snippetData, "alpha", 8, "alpha beta"},
+		{"mid-file", snippetData, "delta", 4, "a delta\n"},
+		{"mid-file, size 0", snippetData, "delta", 0, "delta"},
+		{"mid-file, wide", snippetData, "delta", 100, snippetData},
+		{"end of file, centered", snippetData, "zeta", 10, "epsilon zeta\n"},
+		{"end of file, size 0", snippetData, "zeta", 0, "zeta"},
+		{"size smaller than the match", snippetData, "epsilon zeta", 2, "epsilon zeta"},
+		{"cut inside a rune", "héllo wörld", "wörld", 8, "llo wörld"},
+		{"multi-byte match", "héllo wörld", "wörld", 1, "wörld"},
+	}
+	for _, tt := range tests {
+		got := snippetText(byteSnippet([]byte(tt.data), matchOf(t, tt.data, tt.match), tt.size))
+		if got != tt.want {
+			t.Errorf("%s: byteSnippet(%q, %d) = %q, want %q", tt.name, tt.match, tt.size, got, tt.want)
+		}
+	}
+	// an empty match has no window, so its whole line is shown
+	if got := snippetText(byteSnippet([]byte(snippetData), []int{11, 11}, 0)); got != "gamma delta" {
+		t.Errorf("an empty match = %q, want its line", got)
+	}
+}
+
+func TestContextSnippet(t *testing.T) {
+	tests := []struct {
+		name   string
+		match  string
+		n      int
+		want   string
+		first  int
+		marked []bool
+	}{
+		{"at offset 0", "alpha", 0, "alpha beta", 1, []bool{true}},
+		{"at offset 0, one around", "alpha", 1, "alpha beta\ngamma delta", 1, []bool{true, false}},
+		{"mid-file", "delta", 0, "gamma delta", 2, []bool{true}},
+		{"mid-file, one around", "delta", 1, "alpha beta\ngamma delta\nepsilon zeta", 1, []bool{false, true, false}},
+		{"end of file", "zeta", 1, "gamma delta\nepsilon zeta", 2, []bool{false, true}},
+		{"more than there is", "zeta", 5, "alpha beta\ngamma delta\nepsilon zeta", 1, []bool{false, false, true}},
+		{"across lines", "beta\ngamma", 0, "alpha beta\ngamma delta", 1, []bool{true, true}},
+	}
+	for _, tt := range tests {
+		lines := contextSnippet([]byte(snippetData), matchOf(t, snippetData, tt.match), tt.n)
+		if got := snippetText(lines); got != tt.want {
+			t.Errorf("%s: contextSnippet(%q, %d) = %q, want %q", tt.name, tt.match, tt.n, got, tt.want)
+			continue
+		}
+		for i, l := range lines {
+			if l.Number != tt.first+i || l.Match != tt.marked[i] {
+				t.Errorf("%s: line %d is number %d, match %v; want %d, %v", tt.name, i, l.Number, l.Match, tt.first+i, tt.marked[i])
+			}
+		}
+	}
+}
+
+func TestContextSnippetText(t *testing.T) {
+	tests := []struct {
+		name, data, match string
+		n                 int
+		want              string
+	}{
+		{"crlf", "one\r\ntwo\r\nthree\r\n", "two", 1, "one\ntwo\nthree"},
+		{"no final newline", "one\ntwo", "two", 1, "one\ntwo"},
+		{"multi-byte", "café ünd\nnaïve résumé\n日本語\n", "résumé", 1, "café ünd\nnaïve résumé\n日本語"},
+		{"invalid bytes", "ok\nbad \xff here\nfine\n", "here", 1, "ok\nbad � here\nfine"},
+		{"empty lines", "\n\nhit\n\n\n", "hit", 1, "\nhit\n"},
+	}
+	for _, tt := range tests {
+		lines := contextSnippet([]byte(tt.data), matchOf(t, tt.data, tt.match), tt.n)
+		if got := snippetText(lines); got != tt.want {
+			t.Errorf("%s: contextSnippet = %q, want %q", tt.name, got, tt.want)
+		}
+		for _, l := range lines {
+			if !utf8.ValidString(l.Text) {
+				t.Errorf("%s: line %d is not valid UTF-8: %q", tt.name, l.Number, l.Text)
+			}
+			if l.Match != strings.Contains(l.Text, tt.match) {
+				t.Errorf("%s: line %d %q has match %v", tt.name, l.Number, l.Text, l.Match)
+			}
+		}
+	}
+}
+
+func TestSearchContextLines(t *testing.T) {
+	root := t.TempDir()
+	testEntryBody(t, Configuration{Root: root}, DatePath{2024, 3, 7}, "one\ntwo parser\nthree\nfour\n")
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+	stdout, stderr, code := runWM(t, root, env, "search", "parser")
+	want := "\t  one\n\t> two parser\n\t  three\n\t  four\n"
+	if code != exitOK || !strings.Contains(string(stdout), want) {
+		t.Fatalf("search = %q, %d (%s), want two lines around the match, marked:\n%s", stdout, code, stderr, want)
+	}
+
+	testConfigAppend(t, cfgFile, "context_lines = 0\n")
+	stdout, stderr, code = runWM(t, root, env, "search", "parser")
+	if code != exitOK || !strings.Contains(string(stdout), "\t> two parser\n") || strings.Contains(string(stdout), "three") {
+		t.Errorf("search with context_lines 0 = %q, %d (%s), want the matching line alone", stdout, code, stderr)
+	}
+}
+
+func TestValidateContextSize(t *testing.T) {
+	if err := validateConfig(Configuration{ContextSize: -1}); err == nil || !strings.Contains(err.Error(), "contextSize") {
+		t.Errorf("a negative contextSize: %v, want it rejected", err)
+	}
+	if err := validateConfig(Configuration{ContextSize: 0}); err != nil && strings.Contains(err.Error(), "contextSize") {
+		t.Errorf("contextSize 0 was rejected: %v", err)
+	}
+}
+
+func TestExplainNoMatchesSpan(t *testing.T) {
+	dir := t.TempDir()
+	var all []Entry
+	for _, pd := range []DatePath{{2024, 3, 1}, {2024, 3, 5}} {
+		all = append(all, Entry{Date: pd, Path: filepath.Join(dir, pd.Iso()+".txt")})
+	}
+	searched := append(append([]Entry{}, all...), Entry{Scratch: "ideas", Path: filepath.Join(dir, "ideas.txt")})
+	var out strings.Builder
+	explainNoMatches(&out, Configuration{}, Parameters{Term: []string{"absent"}}, all, searched)
+	want := "no matches for 'absent' across 2 entries (2024-03-01 to 2024-03-05), 1 scratch note\n"
+	if out.String() != want {
+		t.Errorf("explainNoMatches wrote %q, want %q", out.String(), want)
+	}
+}
+
+func TestFixedTermsLiteral(t *testing.T) {
+	for _, term := range []string{"c++", "a.b*c", "(wip)", "[x]", "$HOME", `C:\temp`, "^start", "100%|", "?{}"} {
+		re := testTerms(t, termMode{Fixed: true}, term).Res[0]
+		text := "see " + term + " here"
+		if loc := re.FindStringIndex(text); loc == nil || text[loc[0]:loc[1]] != term {
+			t.Errorf("-F %q in %q matched %v, want the term itself", term, text, loc)
+		}
+		if re.MatchString(strings.ReplaceAll(text, term, "x")) {
+			t.Errorf("-F %q matched text without it", term)
+		}
+	}
+	if _, err := compileTerms([]string{"c++"}, termMode{}); err == nil || !strings.Contains(err.Error(), "-F") {
+		t.Errorf("compiling c++ as a pattern = %v, want an error suggesting -F", err)
+	}
+}
+
+func TestWordTerms(t *testing.T) {
+	tests := []struct {
+		m     termMode
+		term  string
+		text  string
+		match bool
+	}{
+		{termMode{Word: true}, "go", "let's go.", true},
+		{termMode{Word: true}, "go", "(go)", true},
+		{termMode{Word: true}, "go", "go-to", true},
+		{termMode{Word: true}, "go", "going", false},
+		{termMode{Word: true}, "go", "cargo", false},
+		{termMode{Word: true}, "go", "go_lang", false},
+		{termMode{Word: true}, "go", "_go", false},
+		{termMode{Word: true}, "go", "go2", false},
+		{termMode{Word: true}, "g.", "go now", true},
+		{termMode{Word: true}, "g.", "gone", false},
+		{termMode{Word: true, Fixed: true}, "c++", "c++ code", true},
+		{termMode{Word: true, Fixed: true}, "c++", "abc++", false},
+		{termMode{Word: true, Fixed: true}, "#tag", "a #tag.", true},
+		{termMode{Word: true, Fixed: true}, "#tag", "#tags", false},
+		{termMode{Word: true, Fixed: true}, "snake_case", "a snake_case name", true},
+		{termMode{Word: true, Fixed: true}, "snake", "a snake_case name", false},
+		{termMode{Word: true, IgnoreCase: true}, "go", "GO!", true},
+		{termMode{Word: true}, "go", "GO!", false},
+	}
+	for _, tt := range tests {
+		re := testTerms(t, tt.m, tt.term).Res[0]
+		if got := re.MatchString(tt.text); got != tt.match {
+			t.Errorf("%q in mode %+v against %q = %v, want %v", tt.term, tt.m, tt.text, got, tt.match)
+		}
+	}
+}
+
+func TestSearchStructuredOutput(t *testing.T) {
+	root := t.TempDir()
+	testEntries(t, root, DatePath{2024, 3, 7})
+	path := filepath.Join(root, "2024", "3", "7.txt")
+	if err := os.WriteFile(path, []byte("before\nthe needle, \"quoted\"\nafter\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+
+	stdout, stderr, code := runWM(t, root, env, "search", "--json", "needle")
+	var hits []SearchHit
+	if code != 0 || json.Unmarshal(stdout, &hits) != nil || len(hits) != 1 {
+		t.Fatalf("search --json = %q, %d (%s), want one hit", stdout, code, stderr)
+	}
+	h := hits[0]
+	if h.Date != "2024-03-07" || h.File != path || h.Term != "needle" || h.Line != 2 || h.Offset != 11 || !strings.Contains(h.Context, "before\n") {
+		t.Errorf("search --json hit = %+v", h)
+	}
+
+	stdout, stderr, code = runWM(t, root, env, "search", "--csv", "needle")
+	records, err := csv.NewReader(bytes.NewReader(stdout)).ReadAll()
+	if code != 0 || err != nil || len(records) != 2 {
+		t.Fatalf("search --csv = %q, %d (%s), %v, want a header and one hit", stdout, code, stderr, err)
+	}
+	if records[0][0] != "kind" || records[0][len(records[0])-1] != "context" {
+		t.Errorf("search --csv header = %q", records[0])
+	}
+	if got := records[1]; got[1] != "2024-03-07" || got[3] != path || got[5] != "2" || got[10] != h.Context {
+		t.Errorf("search --csv row = %q, want the hit of --json with its context whole", got)
+	}
+}
+
+func TestSearchJSONWithCSV(t *testing.T) {
+	parser := &docopt.Parser{HelpHandler: docopt.NoHelpHandler}
+	if _, err := parser.ParseArgs(usage, []string{"search", "--json", "--csv", "needle"}, ""); err == nil {
+		t.Error("search --json --csv parsed, want a usage error")
+	}
+
+	root := t.TempDir()
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+	stdout, stderr, code := runWM(t, root, env, "search", "--json", "--csv", "needle")
+	if code != exitFailure || len(stdout) > 0 || !strings.Contains(string(stderr), "Usage:") {
+		t.Errorf("wm search --json --csv = %q, %d, %q, want the usage on stderr and status %d", stdout, code, stderr, exitFailure)
+	}
+}
+
+func TestCaseInsensitiveByDefault(t *testing.T) {
+	tests := []struct {
+		term          string
+		text          string
+		caseSensitive bool
+		match         bool
+	}{
+		{"kubernetes", "Kubernetes upgrade", false, true},
+		{"kubernetes", "Kubernetes upgrade", true, false},
+		{"Kubernetes", "KUBERNETES upgrade", false, true},
+		{"kube.*tes", "KubeCon and kubernetes", false, true},
+		// an explicit flag wins either way
+		{"(?i)K8S", "k8s cluster", true, true},
+		{"(?-i)Kubernetes", "kubernetes", false, false},
+		{"(?-i:K)ubernetes", "kubernetes", false, false},
+		{"(?-i:K)ubernetes", "Kubernetes", false, true},
+		// a term setting the case itself is left as it is whole
+		{"(?-i:K)ubernetes", "KUBERNETES", false, false},
+		// other flags are left as they are and still ignore case
+		{"(?s)a.b", "A\nB", false, true},
+		{"(?m)^done$", "x\nDONE\ny", false, true},
+		{`\bC\+\+`, "c++ code", false, true},
+		{"[K]8s", "k8s", false, true},
+		{"[K]8s", "k8s", true, false},
+	}
+	for _, tt := range tests {
+		m := termModeFor(Parameters{CaseSensitive: tt.caseSensitive})
+		re := testTerms(t, m, tt.term).Res[0]
+		if got := re.MatchString(tt.text); got != tt.match {
+			t.Errorf("%q (--case-sensitive %v) against %q = %v, want %v", tt.term, tt.caseSensitive, tt.text, got, tt.match)
+		}
+	}
+	for _, term := range []string{"(?i)x", "(?-i)x", "(?i:x)y"} {
+		if got := termPattern(term, termMode{IgnoreCase: true}); got != term {
+			t.Errorf("termPattern(%q) = %q, want the term left alone", term, got)
+		}
+	}
+}
+
+func TestSearchCaseSensitiveFlag(t *testing.T) {
+	root := t.TempDir()
+	testEntryBody(t, Configuration{Root: root}, DatePath{2024, 3, 7}, "Kubernetes upgrade\n")
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+	for _, tt := range []struct {
+		args []string
+		out  string
+		code int
+	}{
+		{[]string{"search", "-c", "kubernetes"}, "2024-03-07: 1\n", exitOK},
+		{[]string{"search", "-c", "--case-sensitive", "kubernetes"}, "", exitFailure},
+		{[]string{"search", "-c", "--case-sensitive", "Kubernetes"}, "2024-03-07: 1\n", exitOK},
+	} {
+		if out, stderr, code := runWM(t, root, env, tt.args...); string(out) != tt.out || code != tt.code {
+			t.Errorf("wm %q = %q, %d, want %q, %d: %s", tt.args, out, code, tt.out, tt.code, stderr)
+		}
+	}
+}
+
+func TestSearchAllTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		all   []string // the terms of the hits with every term required
+		any   []string // and with --any
+	}{
+		{"both on one line", "docker networking notes\n", []string{"docker", "networking"}, []string{"docker", "networking"}, []string{"docker", "networking"}},
+		{"on different lines", "docker\nlunch\nnetworking\n", []string{"docker", "networking"}, []string{"docker", "networking"}, []string{"docker", "networking"}},
+		{"one missing", "docker only\n", []string{"docker", "networking"}, nil, []string{"docker"}},
+		{"none", "lunch\n", []string{"docker", "networking"}, nil, nil},
+		{"overlapping", "dockerfile\n", []string{"docker", "kerf"}, []string{"docker", "kerf"}, []string{"docker", "kerf"}},
+		{"one inside the other", "docker\n", []string{"dock", "docker"}, []string{"dock", "docker"}, []string{"dock", "docker"}},
+		{"repeated", "docker docker\nnetworking\n", []string{"docker", "networking"}, []string{"docker", "docker", "networking"}, []string{"docker", "docker", "networking"}},
+		{"the same term twice", "docker\n", []string{"docker", "docker"}, []string{"docker", "docker"}, []string{"docker", "docker"}},
+	}
+	for _, tt := range tests {
+		for _, anyTerm := range []bool{false, true} {
+			q := testTerms(t, termMode{IgnoreCase: true}, tt.terms...)
+			q.Any = anyTerm
+			want := tt.all
+			if anyTerm {
+				want = tt.any
+			}
+			whole, streamed := testSearchBoth(t, tt.text, q)
+			for _, hits := range [][]SearchHit{whole, streamed} {
+				var got []string
+				for _, h := range hits {
+					got = append(got, h.Term)
+				}
+				sort.Strings(got)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s, --any %v: hits for %q, want %q", tt.name, anyTerm, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSearchAny(t *testing.T) {
+	root := t.TempDir()
+	cfg := Configuration{Root: root}
+	testEntryBody(t, cfg, DatePath{2024, 3, 6}, "docker\n")
+	testEntryBody(t, cfg, DatePath{2024, 3, 7}, "docker\nand networking\n")
+	testEntryBody(t, cfg, DatePath{2024, 3, 8}, "networking\n")
+	cfgFile, env := testHome(t, root)
+	env = append(env, "WMCFG="+cfgFile)
+	for _, tt := range []struct {
+		args []string
+		out  string
+	}{
+		{[]string{"search", "-c", "docker", "networking"}, "2024-03-07: 2\n"},
+		{[]string{"search", "-c", "--any", "docker", "networking"}, "2024-03-06: 1\n2024-03-07: 2\n2024-03-08: 1\n"},
+	} {
+		if out, stderr, _ := runWM(t, root, env, tt.args...); string(out) != tt.out {
+			t.Errorf("wm %q = %q, want %q: %s", tt.args, out, tt.out, stderr)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package wm
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// With index = true, search keeps the lowercased trigrams of every file it
+// reads in root/.wm-index, so that a term with a literal part, such as
+// "standup" or "deploy.*failed", only reads the files containing it.  The
+// files still have to be read for the hits themselves, and terms without a
+// literal of three or more characters read everything, as without the
+// index.  Files are indexed again whenever their mtime changes.
+
+const (
+	searchIndexDir     = ".wm-index"
+	searchIndexFile    = "search.gob"
+	searchIndexVersion = 1
+	// trigramLen is the length, in runes, of the substrings indexed.
+	trigramLen = 3
+)
+
+// indexedFile is what the index holds for one file, by its path relative
+// to the root.
+type indexedFile struct {
+	ModTime  int64
+	Trigrams []string
+}
+
+// searchIndex is the index of a root as read and updated by one command.
+type searchIndex struct {
+	path    string
+	files   map[string]indexedFile
+	changed bool
+}
+
+func searchIndexPath(cfg Configuration) string {
+	return filepath.Join(cfg.Root, searchIndexDir, searchIndexFile)
+}
+
+// loadSearchIndex reads the index of the root.  A missing or unreadable
+// index reads as an empty one, which is filled in as files are searched.
+func loadSearchIndex(cfg Configuration) *searchIndex {
+	x := &searchIndex{path: searchIndexPath(cfg), files: map[string]indexedFile{}}
+	data, err := readState(x.path, searchIndexVersion)
+	if err != nil || len(data) == 0 {
+		x.changed = err != nil
+		return x
+	}
+	if gob.NewDecoder(bytes.NewReader(data)).Decode(&x.files) != nil {
+		x.files, x.changed = map[string]indexedFile{}, true
+	}
+	return x
+}
+
+// trigrams returns the distinct lowercased trigrams of data, sorted.
+func trigrams(data []byte) []string {
+	text := strings.ToLower(string(data))
+	seen := map[string]bool{}
+	var starts []int
+	for i := range text {
+		starts = append(starts, i)
+		if len(starts) > trigramLen {
+			starts = starts[1:]
+		}
+		if len(starts) == trigramLen {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			seen[text[starts[0]:i+size]] = true
+		}
+	}
+	list := make([]string, 0, len(seen))
+	for t := range seen {
+		list = append(list, t)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// update indexes the entries whose mtime changed, returning how many were
+// indexed.  With prune, entries are every file of the root and the ones
+// not among them are forgotten.  Entries outside the root, such as another
+// profile's, are left out.
+func (x *searchIndex) update(root string, entries []Entry, prune bool) int {
+	indexed := 0
+	present := map[string]bool{}
+	for _, e := range entries {
+		rel, err := filepath.Rel(root, e.Path)
+		if err != nil || !insideDir(root, e.Path) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		present[rel] = true
+		if f, ok := x.files[rel]; ok && f.ModTime == e.ModTime.UnixNano() {
+			continue
+		}
+		data, err := readSynced(e.Path)
+		if err != nil {
+			continue
+		}
+		x.files[rel] = indexedFile{ModTime: e.ModTime.UnixNano(), Trigrams: trigrams(data)}
+		x.changed = true
+		indexed++
+	}
+	for rel := range x.files {
+		if prune && !present[rel] {
+			delete(x.files, rel)
+			x.changed = true
+		}
+	}
+	return indexed
+}
+
+// save writes the index if it changed.
+func (x *searchIndex) save() error {
+	if !x.changed {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(x.path), 0o700); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(x.files); err != nil {
+		return err
+	}
+	return updateState(x.path, searchIndexVersion, func([]byte) ([]byte, error) {
+		return b.Bytes(), nil
+	})
+}
+
+// requiredLiteral returns the longest literal every match of term contains,
+// lowercased, or "" when there is none.
+func requiredLiteral(term string) string {
+	re, err := syntax.Parse(term, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	var walk func(re *syntax.Regexp) string
+	walk = func(re *syntax.Regexp) string {
+		switch re.Op {
+		case syntax.OpLiteral:
+			return strings.ToLower(string(re.Rune))
+		case syntax.OpCapture, syntax.OpPlus:
+			return walk(re.Sub[0])
+		case syntax.OpRepeat:
+			if re.Min > 0 {
+				return walk(re.Sub[0])
+			}
+		case syntax.OpConcat:
+			longest := ""
+			for _, sub := range re.Sub {
+				if l := walk(sub); utf8.RuneCountInString(l) > utf8.RuneCountInString(longest) {
+					longest = l
+				}
+			}
+			return longest
+		}
+		return ""
+	}
+	return walk(re.Simplify())
+}
+
+// contains reports whether the file at rel may contain literal.  Files the
+// index doesn't have, and literals too short to have a trigram, may.
+func (x *searchIndex) contains(rel, literal string) bool {
+	f, ok := x.files[rel]
+	if !ok || utf8.RuneCountInString(literal) < trigramLen {
+		return true
+	}
+	for _, t := range trigrams([]byte(literal)) {
+		i := sort.SearchStrings(f.Trigrams, t)
+		if i == len(f.Trigrams) || f.Trigrams[i] != t {
+			return false
+		}
+	}
+	return true
+}
+
+// candidates returns the entries that may match q, in order.
+func (x *searchIndex) candidates(root string, entries []Entry, q searchTerms) []Entry {
+	literals := make([]string, len(q.Res))
+	for i, re := range q.Res {
+		literals[i] = requiredLiteral(re.String())
+	}
+	var kept []Entry
+	for _, e := range entries {
+		rel, err := filepath.Rel(root, e.Path)
+		if err != nil || !insideDir(root, e.Path) {
+			kept = append(kept, e)
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		found := 0
+		for _, l := range literals {
+			if x.contains(rel, l) {
+				found++
+			}
+		}
+		if (q.Any && found > 0) || found == len(literals) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// indexedCandidates brings the index of the root up to date with entries
+// and returns the ones that may match q.  Failing to save the index is
+// noted, and the search goes ahead.
+func indexedCandidates(cfg Configuration, entries []Entry, q searchTerms) []Entry {
+	x := loadSearchIndex(cfg)
+	x.update(cfg.Root, entries, false)
+	if dryRun {
+		return x.candidates(cfg.Root, entries, q)
+	}
+	if err := x.save(); err != nil {
+		log.Println(":::note::: failed to save the search index:", err)
+	}
+	return x.candidates(cfg.Root, entries, q)
+}
+
+// runIndex updates the search index of the root, from scratch with
+// --rebuild.
+func runIndex(cfg Configuration, params Parameters) error {
+	if !cfg.Index {
+		return errors.New("the search index is off; set index = true to turn it on")
+	}
+	entries, err := listEntries(cfg, walkOptions{})
+	if err != nil {
+		return err
+	}
+	notes, err := listScratch(cfg)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, notes...)
+	x := loadSearchIndex(cfg)
+	if params.Rebuild {
+		x.files, x.changed = map[string]indexedFile{}, true
+	}
+	indexed := x.update(cfg.Root, entries, true)
+	if err := x.save(); err != nil {
+		return err
+	}
+	fmt.Printf("%d files in %s, %d of them indexed now\n", len(x.files), displayPath(x.path), indexed)
+	return nil
+}
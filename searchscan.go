@@ -0,0 +1,96 @@
+package wm
+
+import (
+	"log"
+	"runtime"
+	"sync"
+)
+
+// maxSearchWorkers caps the default number of files search reads at once.
+// More than this mostly makes a spinning disk seek back and forth.
+const maxSearchWorkers = 8
+
+// searchWorkers is how many files search reads and scans at once:
+// search_workers, or the number of CPUs up to maxSearchWorkers.
+func searchWorkers(cfg Configuration) int {
+	if cfg.SearchWorkers > 0 {
+		return cfg.SearchWorkers
+	}
+	n := runtime.NumCPU()
+	if n > maxSearchWorkers {
+		n = maxSearchWorkers
+	}
+	return n
+}
+
+// scanFiles searches every entry with searchWorkers workers and returns the
+// results of the files that could be searched in the order of entries, which
+// is by date, however the workers finish.  Files that couldn't be read are
+// returned apart, so they can be reported after the results.  The contents of
+// files without hits are dropped rather than held until the scan is done.
+func scanFiles(cfg Configuration, entries []Entry, q searchTerms) ([]fileResult, []fileResult) {
+	type scanned struct {
+		i  int
+		r  fileResult
+		ok bool
+	}
+	workers := searchWorkers(cfg)
+	if workers > len(entries) {
+		workers = len(entries)
+	}
+	todo := make(chan int)
+	done := make(chan scanned)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range todo {
+				r, ok := searchFile(cfg, entries[i], q)
+				if len(r.Hits) == 0 {
+					r.Data = nil
+				}
+				done <- scanned{i, r, ok}
+			}
+		}()
+	}
+	go func() {
+		for i := range entries {
+			todo <- i
+		}
+		close(todo)
+		wg.Wait()
+		close(done)
+	}()
+
+	all := make([]scanned, len(entries))
+	for s := range done {
+		all[s.i] = s
+	}
+	var results, failed []fileResult
+	for _, s := range all {
+		switch {
+		case s.ok:
+			results = append(results, s.r)
+		case s.r.Err != nil:
+			failed = append(failed, s.r)
+		}
+	}
+	return results, failed
+}
+
+// noteUnreadable reports the files a search couldn't read, together once
+// its results are written.
+func noteUnreadable(failed []fileResult) {
+	if len(failed) == 0 {
+		return
+	}
+	files := "files"
+	if len(failed) == 1 {
+		files = "file"
+	}
+	log.Printf(":::note::: failed to read %d %s:", len(failed), files)
+	for _, r := range failed {
+		log.Printf(":::note:::   %s: %v", r.Entry.Path, r.Err)
+	}
+}
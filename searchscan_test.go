@@ -0,0 +1,84 @@
+package wm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCorpus writes n small entries, one a day from 2015-01-01, under a new
+// root, every tenth mentioning "needle".
+func testCorpus(tb testing.TB, n int) (Configuration, []Entry) {
+	tb.Helper()
+	cfg := Configuration{Root: tb.TempDir()}
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.Local)
+	entries := make([]Entry, n)
+	for i := range entries {
+		pd := datePathFromTime(start.AddDate(0, 0, i))
+		path := filepath.Join(cfg.Root, filepath.FromSlash(pd.String()))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		text := strings.Repeat(fmt.Sprintf("%s: meetings, notes, and a todo or two\n", pd.Iso()), 20)
+		if i%10 == 0 {
+			text += "found the needle\n"
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+		entries[i] = Entry{Date: pd, Path: path}
+	}
+	return cfg, entries
+}
+
+func TestScanFilesOrder(t *testing.T) {
+	cfg, entries := testCorpus(t, 200)
+	missing := Entry{Date: DatePath{2030, 1, 1}, Path: filepath.Join(cfg.Root, "2030", "1", "1.txt")}
+	entries = append(entries, missing)
+	q := testTerms(t, termMode{}, "needle")
+	for _, workers := range []int{1, 3, 16} {
+		cfg.SearchWorkers = workers
+		results, failed := scanFiles(cfg, entries, q)
+		if len(failed) != 1 || failed[0].Entry.Path != missing.Path {
+			t.Errorf("%d workers: failed = %v, want only %s", workers, failed, missing.Path)
+		}
+		hits := 0
+		for i, r := range results {
+			if i > 0 && !results[i-1].Entry.Date.Before(&r.Entry.Date) {
+				t.Fatalf("%d workers: %s comes after %s", workers, r.Entry.Date.Iso(), results[i-1].Entry.Date.Iso())
+			}
+			hits += len(r.Hits)
+		}
+		if hits != 20 {
+			t.Errorf("%d workers: %d hits, want 20", workers, hits)
+		}
+	}
+}
+
+func BenchmarkScanFiles(b *testing.B) {
+	cfg, entries := testCorpus(b, 3000)
+	res, err := compileTerms([]string{"needle"}, termMode{IgnoreCase: true})
+	if err != nil {
+		b.Fatal(err)
+	}
+	q := searchTerms{Terms: []string{"needle"}, Res: res, Mode: termMode{IgnoreCase: true}}
+	for _, bb := range []struct {
+		name    string
+		workers int
+	}{
+		{"sequential", 1},
+		{"workers=4", 4},
+		{fmt.Sprintf("default(%d)", searchWorkers(Configuration{})), 0},
+		{"workers=16", 16},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			cfg.SearchWorkers = bb.workers
+			for i := 0; i < b.N; i++ {
+				scanFiles(cfg, entries, q)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package wm
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"sort"
+	"unicode/utf8"
+)
+
+// Entries larger than streamSearchSize, typically days with a lot of log
+// output pasted in, are searched a line at a time through a bounded buffer
+// rather than read whole, so that a 50 MB entry costs a search a few hundred
+// kilobytes instead of its size.  The context of each hit is gathered as the
+// file goes by, from the lines kept before it and the lines read after it.
+//
+// Terms are matched against one line at a time there, so in such an entry a
+// match never spans lines: \n and \s find nothing at a line break, and ^ and
+// $ match at the start and end of every line.  A line longer than
+// streamMaxLine is searched in pieces, which a match can't span either.
+// The deprecated contextSize isn't honoured for them; their context is
+// context_lines lines.  Encrypted entries are decrypted in memory, and so
+// are still read whole.
+
+const (
+	// streamSearchSize is the size above which an entry is streamed.
+	streamSearchSize = 256 << 10
+	// streamBufferSize is the size of the read buffer of a streamed entry.
+	streamBufferSize = 64 << 10
+	// streamMaxLine is the most of a line that is searched at once.
+	streamMaxLine = 256 << 10
+)
+
+// streamable reports whether e is large enough to be streamed.
+func streamable(e Entry) bool {
+	info, err := os.Stat(e.Path)
+	return err == nil && info.Size() > streamSearchSize
+}
+
+// pendingContext is the context of the hits on one line, waiting for the
+// lines after it.
+type pendingContext struct {
+	lines []snippetLine
+	left  int
+	hits  []int
+}
+
+// streamScan is the state of searching one streamed entry: the result so
+// far, the lines kept for the context of the next hit, the contexts still
+// being read, and where in the file the next line starts.
+type streamScan struct {
+	q       searchTerms
+	skip    *lineSkip
+	r       fileResult
+	found   []bool
+	prev    []snippetLine
+	pending []*pendingContext
+	off     int
+	number  int
+	column  int
+}
+
+// streamSearch is searchFile for an entry that is streamed.  The last result
+// is false when e turned out not to be streamable, being encrypted, and has
+// to be read whole.
+func streamSearch(e Entry, q searchTerms) (fileResult, bool, bool) {
+	var f *os.File
+	err := retryLocked(func() error {
+		var err error
+		f, err = os.Open(e.Path)
+		return err
+	})
+	if err != nil {
+		return fileResult{Entry: e, Err: err}, false, true
+	}
+	defer f.Close()
+	br := bufio.NewReaderSize(f, streamBufferSize)
+	head, err := br.Peek(streamBufferSize)
+	if err != nil && err != io.EOF {
+		return fileResult{Entry: e, Err: err}, false, true
+	}
+	if isEncrypted(head) {
+		return fileResult{}, false, false
+	}
+	if len(e.Attachment) > 0 && isBinary(head) {
+		return fileResult{Entry: e}, false, true
+	}
+	s := &streamScan{
+		q:      q,
+		skip:   q.Skip.lines(e, head),
+		r:      fileResult{Entry: e},
+		found:  make([]bool, len(q.Res)),
+		number: 1,
+		column: 1,
+	}
+	var line []byte
+	for !s.decided() {
+		chunk, err := br.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull && len(line) < streamMaxLine {
+			continue
+		}
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			return fileResult{Entry: e, Err: err}, false, true
+		}
+		if len(line) > 0 {
+			if len(e.Attachment) == 0 && bytes.IndexByte(line, 0) >= 0 {
+				return fileResult{Entry: e}, false, true
+			}
+			s.add(line, err != bufio.ErrBufferFull)
+		}
+		line = line[:0]
+		if err == io.EOF {
+			break
+		}
+	}
+	for _, p := range s.pending {
+		s.finish(p)
+	}
+	found := 0
+	for _, ok := range s.found {
+		if ok {
+			found++
+		}
+	}
+	if found == 0 || (!q.Any && found < len(q.Res)) {
+		s.r.Hits, s.r.Terms, s.r.Contexts = nil, nil, nil
+	}
+	return s.r, true, true
+}
+
+// decided reports whether, with FirstOnly, the outcome is known and the rest
+// of the file needn't be read.
+func (s *streamScan) decided() bool {
+	if !s.q.FirstOnly {
+		return false
+	}
+	found := 0
+	for _, ok := range s.found {
+		if ok {
+			found++
+		}
+	}
+	return (s.q.Any && found > 0) || found == len(s.q.Res)
+}
+
+// add searches the next piece of the file, a whole line with its terminator
+// or, for a line too long to search at once, the next part of it.
+func (s *streamScan) add(seg []byte, whole bool) {
+	text := seg
+	if whole {
+		text = bytes.TrimRight(seg, "\r\n")
+	}
+	l := snippetLine{
+		Number: s.number,
+		Text:   validText(string(text)),
+		Start:  s.off,
+		End:    s.off + len(text),
+		raw:    string(text),
+	}
+	first := len(s.r.Hits)
+	s.match(text)
+	for _, p := range s.pending {
+		p.lines = append(p.lines, l)
+		p.left--
+	}
+	if len(s.r.Hits) > first && !s.q.FirstOnly {
+		l.Match = true
+		p := &pendingContext{lines: append(append([]snippetLine(nil), s.prev...), l), left: s.q.Context}
+		for i := first; i < len(s.r.Hits); i++ {
+			p.hits = append(p.hits, i)
+		}
+		s.pending = append(s.pending, p)
+		l.Match = false
+	}
+	kept := s.pending[:0]
+	for _, p := range s.pending {
+		if p.left > 0 {
+			kept = append(kept, p)
+		} else {
+			s.finish(p)
+		}
+	}
+	s.pending = kept
+	if s.q.Context > 0 {
+		if len(s.prev) == s.q.Context {
+			copy(s.prev, s.prev[1:])
+			s.prev = s.prev[:len(s.prev)-1]
+		}
+		s.prev = append(s.prev, l)
+	}
+	s.off += len(seg)
+	if whole {
+		s.number++
+		s.column = 1
+	} else {
+		s.column += utf8.RuneCount(seg)
+	}
+}
+
+// match adds the hits of every term in text, the piece of the file at
+// s.off, in the order they are on it.  With FirstOnly, a term found already
+// isn't looked for again.
+func (s *streamScan) match(text []byte) {
+	type termHit struct {
+		hit  SearchHit
+		term string
+	}
+	var hits []termHit
+	hidden := s.skip.hides(s.off, text)
+	for i := range s.q.Res {
+		if s.q.FirstOnly && s.found[i] {
+			continue
+		}
+		n := -1
+		if s.q.FirstOnly && !hidden {
+			n = 1
+		}
+		for _, m := range s.q.findAll(i, text, n) {
+			if hidden {
+				s.r.Hidden++
+				continue
+			}
+			hit := s.q.hit(s.r.Entry.Path, i)
+			hit.Line = s.number
+			hit.Column = s.column + utf8.RuneCount(text[:m[0]])
+			hit.Offset = s.off + m[0]
+			hit.Length = utf8.RuneCount(text[m[0]:m[1]])
+			hit.Text = string(text[m[0]:m[1]])
+			hits = append(hits, termHit{hit, s.q.Terms[i]})
+			s.found[i] = true
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].hit.Offset < hits[j].hit.Offset })
+	for _, h := range hits {
+		s.r.Hits = append(s.r.Hits, h.hit)
+		s.r.Terms = append(s.r.Terms, h.term)
+		s.r.Contexts = append(s.r.Contexts, nil)
+	}
+}
+
+// finish gives the hits of p their context.
+func (s *streamScan) finish(p *pendingContext) {
+	for _, i := range p.hits {
+		s.r.Contexts[i] = p.lines
+	}
+}
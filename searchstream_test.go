@@ -0,0 +1,175 @@
+package wm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLargeEntry writes an entry of at least size bytes of pasted log lines,
+// with every line in marks added after the line of that number.
+func testLargeEntry(tb testing.TB, size int, marks map[int]string) Entry {
+	tb.Helper()
+	var b bytes.Buffer
+	for n := 1; b.Len() < size; n++ {
+		fmt.Fprintf(&b, "2024-03-07T09:%02d:%02d INFO worker %d: request served in %dms\n", n/60%60, n%60, n%7, n%250)
+		if mark, ok := marks[n]; ok {
+			b.WriteString(mark)
+		}
+	}
+	path := filepath.Join(tb.TempDir(), "7.txt")
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	return Entry{Date: DatePath{2024, 3, 7}, Path: path}
+}
+
+func TestStreamSearchMatchesWhole(t *testing.T) {
+	e := testLargeEntry(t, 2*streamSearchSize, map[int]string{
+		1:    "ERROR the needle, first\n",
+		2:    "ERROR a second needle right after\n",
+		3000: "naïve needle with ünïcode\r\n",
+		5000: "needle and needle on one line\n",
+	})
+	f, err := os.OpenFile(e.Path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("the last needle, without a newline")
+	f.Close()
+	if !streamable(e) {
+		t.Fatalf("an entry of %d bytes isn't streamed", 2*streamSearchSize)
+	}
+	data, err := os.ReadFile(e.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{0, 1, 3} {
+		cfg := Configuration{ContextLines: &n}
+		q := testTerms(t, termMode{IgnoreCase: true}, "needle", "error")
+		q.Context = n
+		whole := searchData(e, data, q)
+		streamed, ok, done := streamSearch(e, q)
+		if !ok || !done {
+			t.Fatalf("streamSearch = %v, %v", ok, done)
+		}
+		if len(whole.Hits) != 8 || len(streamed.Hits) != len(whole.Hits) {
+			t.Fatalf("context %d: %d hits streamed, %d whole, want 8", n, len(streamed.Hits), len(whole.Hits))
+		}
+		for i, h := range whole.Hits {
+			s := streamed.Hits[i]
+			if s.Line != h.Line || s.Column != h.Column || s.Offset != h.Offset || s.Text != h.Text || s.Term != h.Term {
+				t.Errorf("context %d: hit %d streamed = %+v, whole %+v", n, i, s, h)
+			}
+			got, want := contextFor(cfg, streamed, i), contextFor(cfg, whole, i)
+			if snippetText(got) != snippetText(want) || got[0].Number != want[0].Number {
+				t.Errorf("context %d: hit %d at line %d streamed with context %q from %d, whole %q from %d",
+					n, i, h.Line, snippetText(got), got[0].Number, snippetText(want), want[0].Number)
+			}
+		}
+	}
+}
+
+func TestStreamSearchLines(t *testing.T) {
+	e := testLargeEntry(t, 2*streamSearchSize, map[int]string{
+		10: "start of a thought\ncontinued here\n",
+		20: strings.Repeat("x", streamMaxLine+100) + " tail needle\n",
+	})
+	// a match never spans lines in a streamed entry
+	streamed, _, _ := streamSearch(e, testTerms(t, termMode{}, `thought\ncontinued`))
+	if len(streamed.Hits) != 0 {
+		t.Errorf("a match across lines was found streamed: %+v", streamed.Hits)
+	}
+	streamed, _, _ = streamSearch(e, testTerms(t, termMode{}, `^continued`))
+	if len(streamed.Hits) != 1 || streamed.Hits[0].Line != 12 {
+		t.Errorf("^ in a streamed entry = %+v, want the start of line 12", streamed.Hits)
+	}
+	// a line longer than streamMaxLine is searched in pieces
+	streamed, _, _ = streamSearch(e, testTerms(t, termMode{}, "needle"))
+	if len(streamed.Hits) != 1 {
+		t.Fatalf("needle after a long line: %d hits, want 1", len(streamed.Hits))
+	}
+	if h := streamed.Hits[0]; h.Line != 23 || h.Column != streamMaxLine+107 {
+		t.Errorf("needle at %d:%d, want 23:%d", h.Line, h.Column, streamMaxLine+107)
+	}
+}
+
+func TestStreamSearchFirstOnly(t *testing.T) {
+	e := testLargeEntry(t, 4*streamSearchSize, map[int]string{5: "needle\n", 40000: "needle\n"})
+	q := testTerms(t, termMode{}, "needle")
+	q.FirstOnly = true
+	r, ok, done := streamSearch(e, q)
+	if !ok || !done || len(r.Hits) != 1 || r.Hits[0].Line != 6 {
+		t.Errorf("streamSearch with FirstOnly = %+v, %v, %v, want the first hit only", r.Hits, ok, done)
+	}
+}
+
+// peakHeap runs f while sampling the heap, returning the most that was in
+// use above what was in use before.
+func peakHeap(f func()) uint64 {
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	base, peak := ms.HeapAlloc, ms.HeapAlloc
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		for {
+			var ms runtime.MemStats
+			runtime.ReadMemStats(&ms)
+			if ms.HeapAlloc > peak {
+				peak = ms.HeapAlloc
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+	f()
+	close(done)
+	<-sampled
+	return peak - base
+}
+
+// BenchmarkSearchLargeEntry searches a 100 MB entry read whole and streamed,
+// reporting the peak heap of each.
+func BenchmarkSearchLargeEntry(b *testing.B) {
+	e := testLargeEntry(b, 100<<20, map[int]string{100000: "needle\n"})
+	res, err := compileTerms([]string{"needle"}, termMode{IgnoreCase: true})
+	if err != nil {
+		b.Fatal(err)
+	}
+	q := searchTerms{Terms: []string{"needle"}, Res: res, Mode: termMode{IgnoreCase: true}, Context: defaultContextLines}
+	for _, bb := range []struct {
+		name   string
+		search func()
+	}{
+		{"whole", func() {
+			data, err := os.ReadFile(e.Path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			searchData(e, data, q)
+		}},
+		{"streamed", func() { streamSearch(e, q) }},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				if p := peakHeap(bb.search); p > peak {
+					peak = p
+				}
+			}
+			b.ReportMetric(float64(peak), "peak-heap-B")
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package wm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const defaultSessionGap = time.Hour
+
+// sessionMarkerRe matches the marker line appended when a new editing session
+// starts on today's entry, e.g. "--- 09:12 ---".
+var sessionMarkerRe = regexp.MustCompile(`^\s*--- (\d{1,2}):(\d{2}) ---\s*$`)
+
+// isSessionMarker reports whether line is a session marker, which previews,
+// counts, and lint skip like the generated header.
+func isSessionMarker(line string) bool {
+	return sessionMarkerRe.MatchString(line)
+}
+
+// lastSessionMarker returns the time of the last marker in data, on day.
+func lastSessionMarker(data []byte, day time.Time) (time.Time, bool) {
+	var last time.Time
+	found := false
+	for _, line := range splitLines(data) {
+		m := sessionMarkerRe.FindSubmatch(bytes.TrimRight(line, "\r\n"))
+		if m == nil {
+			continue
+		}
+		h, _ := strconv.Atoi(string(m[1]))
+		min, _ := strconv.Atoi(string(m[2]))
+		last = time.Date(day.Year(), day.Month(), day.Day(), h, min, 0, 0, time.Local)
+		found = true
+	}
+	return last, found
+}
+
+// addSessionMarker appends a session marker to today's entry when
+// session_markers is on and the previous session started more than
+// session_gap ago.  The previous session is the last marker, or for an entry
+// without one, its last modification.  Newly created entries get none.
+func addSessionMarker(cfg Configuration, path string, pd *DatePath, created bool) error {
+	at := now()
+	if !cfg.SessionMarkers || created || *pd != datePathFromTime(dayNow()) {
+		return nil
+	}
+	gap := defaultSessionGap
+	if len(cfg.SessionGap) > 0 {
+		var err error
+		gap, err = parseAge(cfg.SessionGap)
+		if err != nil {
+			return fmt.Errorf("bad session_gap: %w", err)
+		}
+	}
+	data, err := readEntry(cfg, path)
+	if err != nil {
+		return err
+	}
+	previous, ok := lastSessionMarker(data, at)
+	if !ok {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		previous = info.ModTime()
+	}
+	if at.Sub(previous) < gap {
+		return nil
+	}
+	var b bytes.Buffer
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "--- %s ---\n", at.Format("15:04"))
+	return appendToEntry(cfg, path, b.String())
+}
@@ -0,0 +1,41 @@
+package wm
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// stateDir returns the directory holding wm's local, per-machine state.  It
+// honours $XDG_STATE_HOME, uses %LOCALAPPDATA% on Windows, and otherwise
+// falls back to ~/.local/state/wm.  Nothing in it is ever written into the
+// log root.
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); len(dir) > 0 {
+		return filepath.Join(dir, "wm"), nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("LOCALAPPDATA"); len(dir) > 0 {
+			return filepath.Join(dir, "wm"), nil
+		}
+	}
+	hd, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(hd, ".local", "state", "wm"), nil
+}
+
+// statePath returns the path of a named file in the state directory,
+// creating the directory if needed.
+func statePath(name string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
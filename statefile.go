@@ -0,0 +1,151 @@
+package wm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Derived-data stores (last-seen marks, and any future index or cache) are
+// written through this file so that several wm processes running at once
+// never interleave or lose each other's updates.  Every update holds a lock
+// file, writes a temporary file, and renames it over the store.  The stored
+// file starts with a header line carrying the store's format version and a
+// checksum of the rest:
+//
+//	wm-state 1 sha256:<hex>
+//
+// A store whose header or checksum doesn't match reads as errStateCorrupt and
+// is rebuilt by its owner.
+
+var errStateCorrupt = errors.New("state file is corrupt or from another version")
+
+const (
+	stateLockTimeout = 5 * time.Second
+	// stateLockStale is how old a lock file must be before it is assumed to be
+	// left over from a crashed process and removed.
+	stateLockStale = 30 * time.Second
+)
+
+// lockState takes the lock for the store at path, returning the function that
+// releases it.
+func lockState(path string) (func(), error) {
+	lock := path + ".lock"
+	deadline := time.Now().Add(stateLockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > stateLockStale {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", lock)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// encodeState prefixes data with the header for version.
+func encodeState(version int, data []byte) []byte {
+	sum := sha256.Sum256(data)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "wm-state %d sha256:%s\n", version, hex.EncodeToString(sum[:]))
+	b.Write(data)
+	return b.Bytes()
+}
+
+// decodeState checks the header of a stored file and returns its content.
+func decodeState(version int, stored []byte) ([]byte, error) {
+	nl := bytes.IndexByte(stored, '\n')
+	if nl < 0 {
+		return nil, errStateCorrupt
+	}
+	fields := bytes.Fields(stored[:nl])
+	data := stored[nl+1:]
+	if len(fields) != 3 || string(fields[0]) != "wm-state" || string(fields[1]) != strconv.Itoa(version) {
+		return nil, errStateCorrupt
+	}
+	sum := sha256.Sum256(data)
+	if string(fields[2]) != "sha256:"+hex.EncodeToString(sum[:]) {
+		return nil, errStateCorrupt
+	}
+	return data, nil
+}
+
+// readState returns the content of the store at path.  A missing store reads
+// as nil content and no error.
+func readState(path string, version int) ([]byte, error) {
+	stored, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeState(version, stored)
+}
+
+// writeStateAtomic replaces the store at path with data by writing a
+// temporary file next to it and renaming it into place.  The caller holds the
+// lock.
+func writeStateAtomic(path string, version int, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(encodeState(version, data))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o600)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// updateState runs update on the current content of the store at path and
+// stores what it returns, all under the store's lock.  A corrupt store is
+// passed to update as nil so it is rebuilt from scratch.
+func updateState(path string, version int, update func(old []byte) ([]byte, error)) error {
+	unlock, err := lockState(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	old, err := readState(path, version)
+	if errors.Is(err, errStateCorrupt) {
+		old, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	data, err := update(old)
+	if err != nil {
+		return err
+	}
+	return writeStateAtomic(path, version, data)
+}
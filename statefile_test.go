@@ -0,0 +1,175 @@
+package wm
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// incrementState adds one to the counter kept in the store at path.
+func incrementState(path string) error {
+	return updateState(path, 1, func(old []byte) ([]byte, error) {
+		n := 0
+		if len(old) > 0 {
+			var err error
+			if n, err = strconv.Atoi(string(old)); err != nil {
+				return nil, err
+			}
+		}
+		return []byte(strconv.Itoa(n + 1)), nil
+	})
+}
+
+func readCounter(t *testing.T, path string) int {
+	t.Helper()
+	data, err := readState(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(string(data))
+	if err != nil {
+		t.Fatalf("counter = %q: %v", data, err)
+	}
+	return n
+}
+
+func TestUpdateStateConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	const writers, updates = 8, 25
+	errs := make(chan error, writers*updates)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < updates; j++ {
+				errs <- incrementState(path)
+			}
+		}()
+	}
+	// a reader never sees a store half written
+	stop, read := make(chan struct{}), make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				read <- nil
+				return
+			default:
+			}
+			if _, err := readState(path, 1); err != nil {
+				read <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	if err := <-read; err != nil {
+		t.Errorf("reading while others write: %v", err)
+	}
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("updateState: %v", err)
+		}
+	}
+	if n := readCounter(t, path); n != writers*updates {
+		t.Errorf("counter = %d after %d updates, some were lost", n, writers*updates)
+	}
+}
+
+func TestUpdateStateConcurrentProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	const writers, updates = 4, 20
+	cmds := make([]*exec.Cmd, writers)
+	for i := range cmds {
+		cmds[i] = exec.Command(os.Args[0])
+		cmds[i].Env = append(os.Environ(), "WM_TEST_STATE_WRITER="+path, "WM_TEST_STATE_UPDATES="+strconv.Itoa(updates))
+		if err := cmds[i].Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("writer: %v", err)
+		}
+	}
+	if n := readCounter(t, path); n != writers*updates {
+		t.Errorf("counter = %d after %d updates from %d processes, some were lost", n, writers*updates, writers)
+	}
+}
+
+// stateWriter is what each process of TestUpdateStateConcurrentProcesses
+// runs instead of the tests.
+func stateWriter(path, updates string) {
+	n, _ := strconv.Atoi(updates)
+	for i := 0; i < n; i++ {
+		if err := incrementState(path); err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(1)
+		}
+	}
+	os.Exit(0)
+}
+
+func TestReadStateRejectsDamage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store")
+	unlock, err := lockState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeStateAtomic(path, 1, []byte("content"))
+	unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := readState(path, 1); err != nil || string(data) != "content" {
+		t.Fatalf("readState = %q, %v", data, err)
+	}
+	if _, err := readState(path, 2); !errors.Is(err, errStateCorrupt) {
+		t.Errorf("reading it as version 2: %v, want errStateCorrupt", err)
+	}
+	stored, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored[len(stored)-1] ^= 1
+	if err := os.WriteFile(path, stored, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readState(path, 1); !errors.Is(err, errStateCorrupt) {
+		t.Errorf("reading a damaged store: %v, want errStateCorrupt", err)
+	}
+	// its owner rebuilds it from nothing
+	if err := incrementState(path); err != nil {
+		t.Fatal(err)
+	}
+	if n := readCounter(t, path); n != 1 {
+		t.Errorf("counter = %d after rebuilding, want 1", n)
+	}
+}
+
+func TestLockStateStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store")
+	if err := os.WriteFile(path+".lock", []byte("1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * stateLockStale)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockState(path)
+	if err != nil {
+		t.Fatalf("taking over a stale lock: %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(path + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock left behind after unlocking: %v", err)
+	}
+}
@@ -0,0 +1,451 @@
+package wm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// topTagChanges is how many tags gained and lost a comparison lists.
+const topTagChanges = 5
+
+// rangeStats are the standard stats of the entries in a range.
+type rangeStats struct {
+	Range        string         `json:"range"`
+	From         string         `json:"from"`
+	To           string         `json:"to"`
+	Entries      int            `json:"entries"`
+	Words        int            `json:"words"`
+	AverageWords float64        `json:"average_words"`
+	Tags         map[string]int `json:"tags"`
+	// CurrentStreak is the run of consecutive days with an entry up to the
+	// end of the range or today, whichever is first, or the day before
+	// while that day has none yet.  LongestStreak is the longest run, from
+	// LongestFrom.
+	CurrentStreak int    `json:"current_streak"`
+	LongestStreak int    `json:"longest_streak"`
+	LongestFrom   string `json:"longest_streak_from,omitempty"`
+	// Weekdays counts the days written on each weekday, by English name.
+	Weekdays map[string]int `json:"weekdays"`
+	// Goal is how the days measured up to daily_word_goal, while it is set.
+	Goal *goalStats `json:"goal,omitempty"`
+}
+
+// goalStats are the days of a range that reached daily_word_goal: those
+// with an entry of at least that many words, out of the days from the start
+// of the range, or its first entry, to the end the streaks are counted to.
+type goalStats struct {
+	Words         int     `json:"words"`
+	DaysMet       int     `json:"days_met"`
+	Days          int     `json:"days"`
+	MetPercent    float64 `json:"met_percent"`
+	CurrentStreak int     `json:"current_streak"`
+}
+
+// streaks returns the longest run of consecutive days among days and its
+// first day, and the run ending at end, or the day before it when end has no
+// entry.
+func streaks(days map[DatePath]bool, end DatePath) (current, longest int, from DatePath) {
+	var sorted []DatePath
+	for d := range days {
+		sorted = append(sorted, d)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(&sorted[j]) })
+	run := 0
+	for i, d := range sorted {
+		if i > 0 && sorted[i-1].addDays(1) == d {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest, from = run, d.addDays(1-run)
+		}
+	}
+	d := end
+	if !days[d] {
+		d = d.addDays(-1)
+	}
+	for days[d] {
+		current++
+		d = d.addDays(-1)
+	}
+	return current, longest, from
+}
+
+// tagDelta is how much more, or less, often a tag was used.
+type tagDelta struct {
+	Tag    string `json:"tag"`
+	Change int    `json:"change"`
+}
+
+// statsChange is the difference from the stats of one range to another.
+// Percentages are nil when the first range has nothing to compare with.
+type statsChange struct {
+	Entries             int        `json:"entries"`
+	EntriesPercent      *float64   `json:"entries_percent"`
+	Words               int        `json:"words"`
+	WordsPercent        *float64   `json:"words_percent"`
+	AverageWords        float64    `json:"average_words"`
+	AverageWordsPercent *float64   `json:"average_words_percent"`
+	TagsGained          []tagDelta `json:"tags_gained"`
+	TagsLost            []tagDelta `json:"tags_lost"`
+}
+
+type statsComparison struct {
+	Before rangeStats  `json:"before"`
+	After  rangeStats  `json:"after"`
+	Change statsChange `json:"change"`
+}
+
+// collectStats computes the stats of the entries of every topic in r.
+func collectStats(cfg Configuration, all []Entry, spec string, r dateRange) (rangeStats, error) {
+	s := rangeStats{Range: spec, Tags: map[string]int{}, Weekdays: map[string]int{}}
+	if r.From != nil {
+		s.From = r.From.Iso()
+	}
+	if r.To != nil {
+		s.To = r.To.Iso()
+	}
+	days := map[DatePath]bool{}
+	met := map[DatePath]bool{}
+	var wc *wordCounter
+	if cfg.DailyWordGoal > 0 {
+		wc = newWordCounter(cfg)
+	}
+	var first *DatePath
+	for _, e := range filterEntries(all, r.From, r.To) {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return s, fmt.Errorf("failed to read %s: %w", e.Path, err)
+		}
+		if !days[e.Date] {
+			days[e.Date] = true
+			s.Weekdays[strings.ToLower(e.Date.Time().Weekday().String())]++
+		}
+		if first == nil || e.Date.Before(first) {
+			d := e.Date
+			first = &d
+		}
+		s.Entries++
+		s.Words += len(strings.Fields(string(stripHeader(data))))
+		if wc != nil && wc.count(&e.Date, data) >= cfg.DailyWordGoal {
+			met[e.Date] = true
+		}
+		for _, t := range entryTags(data) {
+			s.Tags[strings.ToLower(t)]++
+		}
+	}
+	if s.Entries > 0 {
+		s.AverageWords = float64(s.Words) / float64(s.Entries)
+	}
+	end := datePathFromTime(dayNow())
+	if r.To != nil && r.To.Before(&end) {
+		end = *r.To
+	}
+	var from DatePath
+	s.CurrentStreak, s.LongestStreak, from = streaks(days, end)
+	if s.LongestStreak > 0 {
+		s.LongestFrom = from.Iso()
+	}
+	if wc != nil {
+		g := &goalStats{Words: cfg.DailyWordGoal, DaysMet: len(met)}
+		if r.From != nil {
+			first = r.From
+		}
+		if first != nil && !end.Before(first) {
+			g.Days = daysBetween(*first, end) + 1
+		}
+		if g.Days > 0 {
+			g.MetPercent = float64(g.DaysMet) / float64(g.Days) * 100
+		}
+		g.CurrentStreak, _, _ = streaks(met, end)
+		s.Goal = g
+	}
+	return s, nil
+}
+
+func percentChange(before, after float64) *float64 {
+	if before == 0 {
+		return nil
+	}
+	p := (after - before) / before * 100
+	return &p
+}
+
+// compareStats works out what changed from before to after.
+func compareStats(before, after rangeStats) statsChange {
+	c := statsChange{
+		Entries:             after.Entries - before.Entries,
+		EntriesPercent:      percentChange(float64(before.Entries), float64(after.Entries)),
+		Words:               after.Words - before.Words,
+		WordsPercent:        percentChange(float64(before.Words), float64(after.Words)),
+		AverageWords:        after.AverageWords - before.AverageWords,
+		AverageWordsPercent: percentChange(before.AverageWords, after.AverageWords),
+		TagsGained:          []tagDelta{},
+		TagsLost:            []tagDelta{},
+	}
+	seen := map[string]bool{}
+	for _, tags := range []map[string]int{before.Tags, after.Tags} {
+		for t := range tags {
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			switch d := after.Tags[t] - before.Tags[t]; {
+			case d > 0:
+				c.TagsGained = append(c.TagsGained, tagDelta{t, d})
+			case d < 0:
+				c.TagsLost = append(c.TagsLost, tagDelta{t, d})
+			}
+		}
+	}
+	byChange := func(tags []tagDelta) []tagDelta {
+		sort.Slice(tags, func(i, j int) bool {
+			a, b := tags[i].Change, tags[j].Change
+			if a < 0 {
+				a, b = -a, -b
+			}
+			if a != b {
+				return a > b
+			}
+			return tags[i].Tag < tags[j].Tag
+		})
+		if len(tags) > topTagChanges {
+			tags = tags[:topTagChanges]
+		}
+		return tags
+	}
+	c.TagsGained, c.TagsLost = byChange(c.TagsGained), byChange(c.TagsLost)
+	return c
+}
+
+// statsSpecs returns the two ranges to compare, from --compare=<a>,<b> or
+// --range=<a> --vs=<b>.  The first is the one changes are measured from:
+// the first of --compare, and the --vs range for --range.
+func statsSpecs(params Parameters) (string, string, error) {
+	if len(params.Compare) > 0 {
+		specs := strings.Split(params.Compare, ",")
+		if len(specs) != 2 || len(strings.TrimSpace(specs[0])) == 0 || len(strings.TrimSpace(specs[1])) == 0 {
+			return "", "", fmt.Errorf("--compare needs two ranges separated by a comma, not '%s'", params.Compare)
+		}
+		return strings.TrimSpace(specs[0]), strings.TrimSpace(specs[1]), nil
+	}
+	return params.Vs, params.Range, nil
+}
+
+// runStats prints the stats of the entries in a range or, with --compare or
+// --vs, of two ranges side by side with what changed.  Entries dated after
+// today don't count unless --include-future is given.
+func runStats(cfg Configuration, params Parameters) error {
+	all, err := rootEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	all = historyEntries(all, params.IncludeFuture)
+	if params.JSON {
+		params.Format = "json"
+	}
+	if len(params.Compare) == 0 && len(params.Vs) == 0 {
+		q := queryFor(params)
+		if len(params.Year) > 0 {
+			if !yearRe.MatchString(params.Year) {
+				return fmt.Errorf("--year needs a year such as 2024, not '%s'", params.Year)
+			}
+			if len(q.In) > 0 {
+				return errors.New("--year and --in can't be combined")
+			}
+			q.In = params.Year
+		}
+		r, err := resolveQuery(cfg, q, dayNow())
+		if err != nil {
+			return err
+		}
+		s, err := collectStats(cfg, all, params.Range, r)
+		if err != nil {
+			return err
+		}
+		switch params.Format {
+		case "json":
+			return json.NewEncoder(os.Stdout).Encode(s)
+		case "", "human":
+			first, err := weekStart(cfg)
+			if err != nil {
+				return err
+			}
+			printStats(os.Stdout, s, first)
+			return nil
+		}
+		return fmt.Errorf("unknown stats format '%s', expected human or json", params.Format)
+	}
+
+	first, second, err := statsSpecs(params)
+	if err != nil {
+		return err
+	}
+	var ranges [2]dateRange
+	for i, spec := range []string{first, second} {
+		ranges[i], err = infoRange(cfg, spec)
+		if err != nil {
+			return err
+		}
+		if ranges[i].From == nil || ranges[i].To == nil {
+			return fmt.Errorf("range '%s' needs both a start and an end", spec)
+		}
+	}
+	a, b := ranges[0], ranges[1]
+	if !a.To.Before(b.From) && !b.To.Before(a.From) && !params.AllowOverlap {
+		return errors.New("the ranges overlap; give --allow-overlap to compare them anyway")
+	}
+	var cmp statsComparison
+	cmp.Before, err = collectStats(cfg, all, first, a)
+	if err != nil {
+		return err
+	}
+	cmp.After, err = collectStats(cfg, all, second, b)
+	if err != nil {
+		return err
+	}
+	cmp.Change = compareStats(cmp.Before, cmp.After)
+	switch params.Format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(cmp)
+	case "", "human":
+		printComparison(os.Stdout, cmp)
+		return nil
+	}
+	return fmt.Errorf("unknown stats format '%s', expected human or json", params.Format)
+}
+
+func statsRangeLabel(s rangeStats) string {
+	if s.From == s.To {
+		return s.From
+	}
+	return s.From + ".." + s.To
+}
+
+// statsBarWidth is the length of the longest bar of the weekday histogram.
+const statsBarWidth = 30
+
+// printStats writes s as an aligned table, followed by how many days were
+// written on each weekday, in weeks starting on first, with bars; or as
+// "label: value" lines with plain output.
+func printStats(w io.Writer, s rangeStats, first time.Weekday) {
+	tags := compareStats(rangeStats{}, s).TagsGained
+	longest := fmt.Sprintf("%d days", s.LongestStreak)
+	if s.LongestStreak > 0 {
+		longest += fmt.Sprintf(" from %s", s.LongestFrom)
+	}
+	rows := [][2]string{
+		{"entries", fmt.Sprint(s.Entries)},
+		{"words", fmt.Sprint(s.Words)},
+		{"average words", fmt.Sprintf("%.1f", s.AverageWords)},
+		{"current streak", fmt.Sprintf("%d days", s.CurrentStreak)},
+		{"longest streak", longest},
+		{"top tags", formatTagChanges(tags, false)},
+	}
+	if g := s.Goal; g != nil {
+		rows = append(rows,
+			[2]string{"word goal", fmt.Sprintf("%s words", groupDigits(g.Words))},
+			[2]string{"goal met", fmt.Sprintf("%d of %d days (%.0f%%)", g.DaysMet, g.Days, g.MetPercent)},
+			[2]string{"goal streak", fmt.Sprintf("%d days", g.CurrentStreak)})
+	}
+	if len(s.From) > 0 {
+		rows = append([][2]string{{"range", statsRangeLabel(s)}}, rows...)
+	}
+	most := 0
+	for _, n := range s.Weekdays {
+		if n > most {
+			most = n
+		}
+	}
+	if plainOutput {
+		for _, r := range rows {
+			fmt.Fprintf(w, "%s: %s\n", r[0], r[1])
+		}
+		for i := 0; i < 7; i++ {
+			wd := time.Weekday((int(first) + i) % 7)
+			fmt.Fprintf(w, "days written on %s: %d\n", strings.TrimSpace(weekdayAbbr(wd)), s.Weekdays[strings.ToLower(wd.String())])
+		}
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\n", r[0], r[1])
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for i := 0; i < 7; i++ {
+		wd := time.Weekday((int(first) + i) % 7)
+		n := s.Weekdays[strings.ToLower(wd.String())]
+		bar := ""
+		if most > 0 {
+			bar = strings.Repeat("█", (n*statsBarWidth+most-1)/most)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t %s\n", weekdayAbbr(wd), n, bar)
+	}
+	tw.Flush()
+}
+
+// formatTagChanges lists tags with their counts, or with signed changes.
+func formatTagChanges(tags []tagDelta, signed bool) string {
+	if len(tags) == 0 {
+		return "none"
+	}
+	var parts []string
+	for _, t := range tags {
+		if signed {
+			parts = append(parts, fmt.Sprintf("%s %+d", t.Tag, t.Change))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %d", t.Tag, t.Change))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatChange(d float64, p *float64, format string) string {
+	s := fmt.Sprintf("%+"+format, d)
+	if p != nil {
+		s += fmt.Sprintf(" (%+.1f%%)", *p)
+	}
+	return s
+}
+
+// printComparison writes the stats of both ranges side by side, or as "label:
+// value" lines with plain output.
+func printComparison(w io.Writer, cmp statsComparison) {
+	b, a, c := cmp.Before, cmp.After, cmp.Change
+	rows := [][4]string{
+		{"entries", fmt.Sprint(b.Entries), fmt.Sprint(a.Entries), formatChange(float64(c.Entries), c.EntriesPercent, ".0f")},
+		{"words", fmt.Sprint(b.Words), fmt.Sprint(a.Words), formatChange(float64(c.Words), c.WordsPercent, ".0f")},
+		{"average words", fmt.Sprintf("%.1f", b.AverageWords), fmt.Sprintf("%.1f", a.AverageWords), formatChange(c.AverageWords, c.AverageWordsPercent, ".1f")},
+	}
+	if plainOutput {
+		fmt.Fprintf(w, "first range: %s (%s)\n", b.Range, statsRangeLabel(b))
+		fmt.Fprintf(w, "second range: %s (%s)\n", a.Range, statsRangeLabel(a))
+		for _, r := range rows {
+			fmt.Fprintf(w, "%s: %s then %s, change %s\n", r[0], r[1], r[2], r[3])
+		}
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "\t%s\t%s\tchange\n", b.Range, a.Range)
+		if b.Range != statsRangeLabel(b) || a.Range != statsRangeLabel(a) {
+			fmt.Fprintf(tw, "\t%s\t%s\t\n", statsRangeLabel(b), statsRangeLabel(a))
+		}
+		for _, r := range rows {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r[0], r[1], r[2], r[3])
+		}
+		tw.Flush()
+	}
+	fmt.Fprintf(w, "tags gained: %s\n", formatTagChanges(c.TagsGained, true))
+	fmt.Fprintf(w, "tags lost: %s\n", formatTagChanges(c.TagsLost, true))
+}
@@ -0,0 +1,146 @@
+package wm
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+)
+
+// defaultImportantPattern matches the lines a summary keeps: decisions,
+// lines marked IMPORTANT:, and lines starting with "!", optionally as
+// bullets.
+const defaultImportantPattern = `^\s*(?:[-*+]\s+)?(?:DECISION:|IMPORTANT:|!)\s*`
+
+// summaryDay is one day of a summary and the important lines of its entry.
+type summaryDay struct {
+	Iso   string
+	Label string
+	Items []extracted
+}
+
+type summaryPage struct {
+	Range string
+	Days  []summaryDay
+}
+
+// summaryEmailTemplate renders a summary as an HTML fragment that survives
+// e-mail clients: tables for layout, inline styles only, and no external
+// assets.  Every day has an anchor the index at the top links to.
+var summaryEmailTemplate = template.Must(template.New("summary").Parse(`<table role="presentation" cellpadding="0" cellspacing="0" border="0" width="100%" style="max-width:640px;font-family:Arial,Helvetica,sans-serif;font-size:14px;line-height:1.45;color:#222222;">
+<tr><td style="padding:0 0 8px 0;font-size:18px;font-weight:bold;">Summary {{.Range}}</td></tr>
+<tr><td style="padding:0 0 12px 0;font-size:12px;">
+{{- range $i, $d := .Days}}{{if $i}} &middot; {{end}}<a href="#d{{$d.Iso}}" style="color:#1a5fb4;text-decoration:none;">{{$d.Label}}</a>{{end -}}
+</td></tr>
+{{- range .Days}}
+<tr><td style="padding:12px 0 4px 0;border-top:1px solid #dddddd;font-weight:bold;"><a name="d{{.Iso}}" id="d{{.Iso}}"></a>{{.Label}}</td></tr>
+<tr><td style="padding:0 0 4px 0;">
+<table role="presentation" cellpadding="0" cellspacing="0" border="0" width="100%">
+{{- range .Items}}
+<tr><td valign="top" width="16" style="padding:2px 0;">&bull;</td><td style="padding:2px 0;">{{.Text}}{{range .Continued}}<br>{{.}}{{end}}</td></tr>
+{{- end}}
+</table>
+</td></tr>
+{{- end}}
+</table>
+`))
+
+// collectSummary returns the days in entries with important lines, leaving
+// out entries carrying redactTag entirely.
+func collectSummary(cfg Configuration, x *extractor, entries []Entry, redactTag string) ([]summaryDay, error) {
+	var days []summaryDay
+	for _, e := range entries {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", e.Path, err)
+		}
+		if hasTag(data, redactTag) {
+			continue
+		}
+		items := x.extract(data)
+		if len(items) == 0 {
+			continue
+		}
+		for i := range items {
+			items[i].Text = validText(items[i].Text)
+			for j, c := range items[i].Continued {
+				items[i].Continued[j] = validText(c)
+			}
+		}
+		day := summaryDay{Iso: e.Date.Iso(), Label: fmt.Sprintf("%s %s%s", weekdayName(e.Date.Time().Weekday()), e.Date.Iso(), topicLabel(e)), Items: items}
+		if len(e.Topic) > 0 {
+			day.Iso += "-" + e.Topic
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// runSummary writes the important lines of the entries in the range, this
+// week by default, for reading or, with --format html-email, for mailing.
+func runSummary(cfg Configuration, params Parameters) error {
+	x, err := newExtractor("summary", cfg.Summary, defaultImportantPattern)
+	if err != nil {
+		return err
+	}
+	q := queryFor(params)
+	if q.empty() {
+		q.In = "this-week"
+	}
+	r, err := resolveQuery(cfg, q, dayNow())
+	if err != nil {
+		return err
+	}
+	all, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	redactTag := cfg.RedactTag
+	if len(redactTag) == 0 {
+		redactTag = defaultRedactTag
+	}
+	days, err := collectSummary(cfg, x, filterEntries(all, r.From, r.To), redactTag)
+	if err != nil {
+		return err
+	}
+	page := summaryPage{}
+	if r.From != nil && r.To != nil {
+		page.Range = r.From.Iso() + " – " + r.To.Iso()
+	}
+	page.Days = days
+
+	w := bufio.NewWriter(os.Stdout)
+	switch params.Format {
+	case "", "human":
+		err = summaryHuman(w, page)
+	case "html-email":
+		err = summaryEmailTemplate.Execute(w, page)
+	default:
+		return fmt.Errorf("unknown summary format '%s', expected human or html-email", params.Format)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	return err
+}
+
+func summaryHuman(w io.Writer, page summaryPage) error {
+	if len(page.Days) == 0 {
+		_, err := fmt.Fprintln(w, "nothing marked important in range")
+		return err
+	}
+	for i, d := range page.Days {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, d.Label)
+		for _, item := range d.Items {
+			fmt.Fprintf(w, "  - %s\n", item.Text)
+			for _, c := range item.Continued {
+				fmt.Fprintf(w, "    %s\n", c)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var tagNameRe = regexp.MustCompile(`^#[A-Za-z][A-Za-z0-9_-]*$`)
+
+// normalizeTag adds the '#' to a tag given without one and checks that the
+// result is a tag wm recognises.
+func normalizeTag(tag string) (string, error) {
+	tag = "#" + strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	if !tagNameRe.MatchString(tag) {
+		return "", fmt.Errorf("'%s' is not a valid tag", tag)
+	}
+	return tag, nil
+}
+
+var fenceRe = regexp.MustCompile("^\\s*(```|~~~)")
+
+// isMarkdown reports whether the entry at path is Markdown, where code fences
+// are left alone.
+func isMarkdown(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// scanTags returns the #tags in data as tagRe reads them, one at the start of
+// a line or after whitespace, so anchors in URLs are not tags.  In Markdown,
+// code fences are left out.
+func scanTags(data []byte, markdown bool) []string {
+	if !markdown {
+		return entryTags(data)
+	}
+	var tags []string
+	fence := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			switch {
+			case len(fence) == 0:
+				fence = m[1]
+			case fence == m[1]:
+				fence = ""
+			}
+			continue
+		}
+		if len(fence) == 0 {
+			tags = append(tags, entryTags([]byte(line))...)
+		}
+	}
+	return tags
+}
+
+// tagIndex returns the days each tag appears on, by lowercased tag, in the
+// order of entries.  A day is listed once however often the tag appears in
+// it and its topics.
+func tagIndex(cfg Configuration, entries []Entry) (map[string][]DatePath, error) {
+	index := map[string][]DatePath{}
+	for _, e := range entries {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", e.Path, err)
+		}
+		for _, t := range scanTags(data, isMarkdown(e.Path)) {
+			t = strings.ToLower(t)
+			days := index[t]
+			if len(days) == 0 || days[len(days)-1] != e.Date {
+				index[t] = append(days, e.Date)
+			}
+		}
+	}
+	return index, nil
+}
+
+// entriesTagged returns the entries carrying tag, matched regardless of case.
+func entriesTagged(cfg Configuration, entries []Entry, tag string) []Entry {
+	var tagged []Entry
+	for _, e := range entries {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			log.Println(":::note::: failed to read ", e.Path)
+			continue
+		}
+		for _, t := range scanTags(data, isMarkdown(e.Path)) {
+			if strings.EqualFold(t, tag) {
+				tagged = append(tagged, e)
+				break
+			}
+		}
+	}
+	return tagged
+}
+
+// listTags prints every tag in the range with the number of days it appears
+// on, the most used first.
+func listTags(cfg Configuration, params Parameters) error {
+	r, err := resolveQuery(cfg, queryFor(params), dayNow())
+	if err != nil {
+		return err
+	}
+	entries, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	index, err := tagIndex(cfg, filterEntries(entries, r.From, r.To))
+	if err != nil {
+		return err
+	}
+	if len(index) == 0 {
+		fmt.Println("no tags in range")
+		return nil
+	}
+	tags := make([]string, 0, len(index))
+	for t := range index {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		a, b := len(index[tags[i]]), len(index[tags[j]])
+		if a != b {
+			return a > b
+		}
+		return tags[i] < tags[j]
+	})
+	for _, t := range tags {
+		if plainOutput {
+			fmt.Printf("tag: %s, days: %d\n", t, len(index[t]))
+			continue
+		}
+		fmt.Printf("%5d  %s\n", len(index[t]), t)
+	}
+	return nil
+}
+
+// tagChange is the rewrite of one line.
+type tagChange struct {
+	Line     int
+	Old, New string
+}
+
+// renameTags rewrites every whole tag in data that is one of from, ignoring
+// case, to into.  Tags are matched as tagRe reads them, so renaming #mtg
+// leaves #mtgs alone.  In Markdown, lines inside code fences are kept as
+// they are.  It returns the new content, the number of tags rewritten, and
+// the changed lines.
+func renameTags(data []byte, markdown bool, from []string, into string) ([]byte, int, []tagChange) {
+	lines := strings.Split(string(data), "\n")
+	count := 0
+	var changes []tagChange
+	fence := ""
+	for i, line := range lines {
+		if markdown {
+			if m := fenceRe.FindStringSubmatch(line); m != nil {
+				switch {
+				case len(fence) == 0:
+					fence = m[1]
+				case fence == m[1]:
+					fence = ""
+				}
+				continue
+			}
+			if len(fence) > 0 {
+				continue
+			}
+		}
+		var b strings.Builder
+		last := 0
+		for _, m := range tagRe.FindAllStringSubmatchIndex(line, -1) {
+			tag := line[m[2]:m[3]]
+			for _, f := range from {
+				if strings.EqualFold(tag, f) {
+					b.WriteString(line[last:m[2]])
+					b.WriteString(into)
+					last = m[3]
+					count++
+					break
+				}
+			}
+		}
+		if last > 0 {
+			b.WriteString(line[last:])
+			changes = append(changes, tagChange{i + 1, line, b.String()})
+			lines[i] = b.String()
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), count, changes
+}
+
+// runTags handles "tags rename" and "tags merge": it shows what would change,
+// asks before rewriting unless --yes is given, backs every file up to the
+// versions store, and reports what was rewritten.  Plain "tags" lists them.
+func runTags(cfg Configuration, params Parameters) error {
+	if !params.Rename && !params.Merge {
+		return listTags(cfg, params)
+	}
+	var from []string
+	target := params.New
+	if params.Merge {
+		from, target = params.Tag, params.Into
+	} else {
+		from = []string{params.Old}
+	}
+	into, err := normalizeTag(target)
+	if err != nil {
+		return err
+	}
+	for i, f := range from {
+		from[i], err = normalizeTag(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	r, err := resolveQuery(cfg, queryFor(params), dayNow())
+	if err != nil {
+		return err
+	}
+	entries, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	type rewrite struct {
+		Entry Entry
+		Data  []byte
+		Count int
+	}
+	var rewrites []rewrite
+	total := 0
+	for _, e := range filterEntries(entries, r.From, r.To) {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return err
+		}
+		out, n, changes := renameTags(data, isMarkdown(e.Path), from, into)
+		if n == 0 {
+			continue
+		}
+		fmt.Printf("%s: %d\n", e.Path, n)
+		for i, c := range changes {
+			if i == 3 {
+				fmt.Printf("  ... and %d more lines\n", len(changes)-i)
+				break
+			}
+			fmt.Printf("  %d - %s\n  %d + %s\n", c.Line, strings.TrimSpace(c.Old), c.Line, strings.TrimSpace(c.New))
+		}
+		rewrites = append(rewrites, rewrite{e, out, n})
+		total += n
+	}
+	if total == 0 {
+		fmt.Printf("no occurrences of %s\n", strings.Join(from, ", "))
+		return nil
+	}
+	question := fmt.Sprintf("rewrite %d occurrences in %d files to %s?", total, len(rewrites), into)
+	if params.DryRun {
+		fmt.Println("would", question[:len(question)-1])
+		return nil
+	}
+	if !params.Yes && !confirm(question) {
+		return errors.New("nothing rewritten")
+	}
+	for _, rw := range rewrites {
+		if _, err := rewriteEntry(cfg, rw.Entry.Path, rw.Data, rewriteOptions{Backup: true}); err != nil {
+			return fmt.Errorf("failed to rewrite %s: %w", rw.Entry.Path, err)
+		}
+	}
+	fmt.Printf("rewrote %d occurrences in %d files:\n", total, len(rewrites))
+	for _, rw := range rewrites {
+		fmt.Printf("  %s  %d\n", rw.Entry.Path, rw.Count)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package wm
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateData is what entry templates can refer to, e.g. "{{.Weekday}}".
+type templateData struct {
+	Date    string
+	Weekday string
+	Month   string
+	Year    int
+	Day     int
+}
+
+// templateChoice is the template picked for a date and which setting picked
+// it.  Path is empty when no template applies.
+type templateChoice struct {
+	Path   string
+	Source string
+}
+
+// templater renders the content of new entries.  The template comes from,
+// in order, the --template flag, the WM_TEMPLATE environment variable, the
+// narrowest [templates] date range containing the date, the [templates] entry
+// for the weekday, and the template key.  Templates are
+// parsed once however many entries use them.
+type templater struct {
+	cfg     Configuration
+	flag    string
+	verbose bool
+	parsed  map[string]*template.Template
+	shown   map[string]bool
+}
+
+func newTemplater(cfg Configuration, flag string, verbose bool) *templater {
+	return &templater{cfg: cfg, flag: flag, verbose: verbose, parsed: map[string]*template.Template{}, shown: map[string]bool{}}
+}
+
+// choose returns the template that applies to pd.  Paths from the
+// configuration file are relative to its directory.
+func (t *templater) choose(pd *DatePath) templateChoice {
+	if len(t.flag) > 0 {
+		return templateChoice{t.flag, "--template"}
+	}
+	if env := os.Getenv("WM_TEMPLATE"); len(env) > 0 {
+		return templateChoice{env, "WM_TEMPLATE"}
+	}
+	fromConfig := func(p string, source string) templateChoice {
+		return templateChoice{configRelative(t.cfg, p), source}
+	}
+	if ranges, err := templateRanges(t.cfg); err == nil {
+		if r, ok := templateForRange(ranges, pd); ok {
+			return fromConfig(r.Path, fmt.Sprintf("templates.%q", r.Key))
+		}
+	}
+	weekday := strings.ToLower(pd.Time().Weekday().String())
+	for k, p := range t.cfg.Templates {
+		if strings.ToLower(k) == weekday && len(p) > 0 {
+			return fromConfig(p, "templates."+weekday)
+		}
+	}
+	if len(t.cfg.Template) > 0 {
+		return fromConfig(t.cfg.Template, "template")
+	}
+	return templateChoice{Source: "default"}
+}
+
+// load parses the template chosen for pd, reading each file once.
+func (t *templater) load(pd *DatePath) (*template.Template, error) {
+	choice := t.choose(pd)
+	if t.verbose && !t.shown[choice.Path] {
+		t.shown[choice.Path] = true
+		if len(choice.Path) == 0 {
+			log.Println(":::note::: template: none, header only")
+		} else {
+			log.Printf(":::note::: template: %s (from %s)", choice.Path, choice.Source)
+		}
+	}
+	if len(choice.Path) == 0 {
+		return nil, nil
+	}
+	if tmpl, ok := t.parsed[choice.Path]; ok {
+		return tmpl, nil
+	}
+	data, err := os.ReadFile(choice.Path)
+	if err != nil {
+		return nil, fmt.Errorf("template from %s: %w", choice.Source, err)
+	}
+	tmpl, err := template.New(filepath.Base(choice.Path)).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("template %s from %s: %w", choice.Path, choice.Source, err)
+	}
+	t.parsed[choice.Path] = tmpl
+	return tmpl, nil
+}
+
+// content returns the full text of a new entry for pd: the generated header,
+// the todos carried over, and the rendered template, if any, though after
+// the template's front matter.  It is rendered in memory so a
+// template that fails never leaves a half-written entry behind.
+func (t *templater) content(pd *DatePath) (string, error) {
+	tmpl, err := t.load(pd)
+	if err != nil {
+		return "", err
+	}
+	carried, err := carriedOver(t.cfg, pd)
+	if err != nil {
+		return "", fmt.Errorf("failed to carry todos forward: %w", err)
+	}
+	if tmpl == nil {
+		return renderHeader(pd) + carried, nil
+	}
+	body, err := t.execute(tmpl, pd)
+	if err != nil {
+		return "", err
+	}
+	// Front matter the template starts with has to stay right below the
+	// header to be read as such.
+	_, end, _ := frontMatterSpan([]byte(body))
+	return renderHeader(pd) + body[:end] + carried + body[end:], nil
+}
+
+// render returns the template chosen for pd rendered on its own, without
+// the header or carried todos, or "" when no template applies.
+func (t *templater) render(pd *DatePath) (string, error) {
+	tmpl, err := t.load(pd)
+	if err != nil || tmpl == nil {
+		return "", err
+	}
+	return t.execute(tmpl, pd)
+}
+
+func (t *templater) execute(tmpl *template.Template, pd *DatePath) (string, error) {
+	var b strings.Builder
+	err := tmpl.Execute(&b, templateData{
+		Date:    pd.Iso(),
+		Weekday: weekdayName(pd.Time().Weekday()),
+		Month:   monthName(pd.month),
+		Year:    pd.year,
+		Day:     pd.day,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", t.choose(pd).Path, err)
+	}
+	return b.String(), nil
+}
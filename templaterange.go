@@ -0,0 +1,85 @@
+package wm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// templateRange is a [templates] entry keyed by a date range, such as
+// "2024-01-01..2024-03-31" = "templates/q1.md".
+type templateRange struct {
+	Key  string
+	From DatePath
+	To   DatePath
+	Path string
+}
+
+// days is the length of the range, inclusive.
+func (r templateRange) days() int {
+	return daysBetween(r.From, r.To) + 1
+}
+
+func (r templateRange) contains(pd *DatePath) bool {
+	return !pd.Before(&r.From) && !r.To.Before(pd)
+}
+
+// isWeekdayName reports whether key names a day of the week.
+func isWeekdayName(key string) bool {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(key, d.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateRanges reads the range-keyed entries of [templates].  Keys that
+// aren't weekdays must be a date or a "<from>..<to>" range.  Ranges may nest,
+// the narrowest one winning, but two ranges that overlap without one
+// containing the other, or identical ranges, are reported because no date in
+// the overlap would have a clear template.
+func templateRanges(cfg Configuration) ([]templateRange, error) {
+	var ranges []templateRange
+	for key, path := range cfg.Templates {
+		if isWeekdayName(key) {
+			continue
+		}
+		from, to, err := parseDateRange(cfg, key)
+		if err != nil || from == nil {
+			return nil, fmt.Errorf("'%s' is neither a weekday nor a date range", key)
+		}
+		ranges = append(ranges, templateRange{Key: key, From: *from, To: *to, Path: path})
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].From != ranges[j].From {
+			return ranges[i].From.Before(&ranges[j].From)
+		}
+		return ranges[i].Key < ranges[j].Key
+	})
+	for i, a := range ranges {
+		for _, b := range ranges[i+1:] {
+			if b.From.Before(&a.From) || a.To.Before(&b.From) {
+				continue
+			}
+			aInB := b.contains(&a.From) && b.contains(&a.To)
+			bInA := a.contains(&b.From) && a.contains(&b.To)
+			if aInB == bInA {
+				return nil, fmt.Errorf("ranges '%s' and '%s' overlap; nest one inside the other or split them", a.Key, b.Key)
+			}
+		}
+	}
+	return ranges, nil
+}
+
+// templateForRange returns the narrowest range entry containing pd.
+func templateForRange(ranges []templateRange, pd *DatePath) (templateRange, bool) {
+	best, found := templateRange{}, false
+	for _, r := range ranges {
+		if r.contains(pd) && (!found || r.days() < best.days()) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,99 @@
+package wm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultSections are the sections of timed_sections when no [sections]
+// table is given, by the hour each starts at.
+var defaultSections = map[string]int{"Morning": 5, "Afternoon": 12, "Evening": 17}
+
+// timedSection is a part of the day that appends are grouped under.
+type timedSection struct {
+	Name  string
+	Start int
+}
+
+// timedSections returns the configured sections, or the default ones, in
+// order of their start hours.
+func timedSections(cfg Configuration) ([]timedSection, error) {
+	table := cfg.Sections
+	if len(table) == 0 {
+		table = defaultSections
+	}
+	names := make([]string, 0, len(table))
+	for name := range table {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var list []timedSection
+	starts := map[int]string{}
+	for _, name := range names {
+		start := table[name]
+		if len(strings.TrimSpace(name)) == 0 || strings.ContainsAny(name, "\r\n") {
+			return nil, fmt.Errorf(`config: [sections] has a name that isn't a single line of text: %q`, name)
+		}
+		if start < 0 || start > 23 {
+			return nil, fmt.Errorf(`config: [sections] "%s" must start at an hour from 0 to 23, got %d`, name, start)
+		}
+		if other, ok := starts[start]; ok {
+			return nil, fmt.Errorf(`config: [sections] "%s" and "%s" both start at %d`, other, name, start)
+		}
+		starts[start] = name
+		list = append(list, timedSection{strings.TrimSpace(name), start})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Start < list[j].Start })
+	return list, nil
+}
+
+// sectionAt returns the name of the section t falls in: the last one to
+// have started, or before the first has, the last of the day before.
+func sectionAt(sections []timedSection, t time.Time) string {
+	name := sections[len(sections)-1].Name
+	for _, s := range sections {
+		if t.Hour() >= s.Start {
+			name = s.Name
+		}
+	}
+	return name
+}
+
+// lastHeading returns the text of the last Markdown heading in data, or ""
+// when it has none.
+func lastHeading(data []byte) string {
+	lines := strings.Split(string(data), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		l := strings.TrimRight(lines[i], "\r")
+		if m := headingRe.FindStringSubmatch(l); m != nil {
+			return strings.TrimSpace(l[len(m[1]):])
+		}
+	}
+	return ""
+}
+
+// sectionHeading returns the heading to append before text added to data,
+// the entry's content, at t: "## Afternoon" and the blank lines around it
+// when the last heading of the entry isn't that of t's section, or ""
+// when the text goes under the heading already there.
+func sectionHeading(cfg Configuration, data []byte, t time.Time) (string, error) {
+	sections, err := timedSections(cfg)
+	if err != nil {
+		return "", err
+	}
+	name := sectionAt(sections, t)
+	if strings.EqualFold(lastHeading(data), name) {
+		return "", nil
+	}
+	var b strings.Builder
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		b.WriteString("\n")
+	}
+	if len(data) > 0 && !strings.HasSuffix(strings.TrimRight(string(data), " \t\r"), "\n\n") {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "## %s\n\n", name)
+	return b.String(), nil
+}
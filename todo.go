@@ -0,0 +1,154 @@
+package wm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// taskRe matches a Markdown task list item, "- [ ] text" or "* [x] text",
+// at any indentation so that nested items are found too, and after the
+// "[HH:MM] " append stamps lines with.  The groups are the indentation, the
+// stamp, the mark in the box, and the text.
+var taskRe = regexp.MustCompile(`^(\s*)(\[\d\d:\d\d\]\s+)?[-*+]\s+\[([ xX])\]\s*(.*)$`)
+
+// todoItem is a task list item of an entry.  Line is 1-based and Raw the
+// line as written.  Stamp is the time append put in front of it, with the
+// space after, when it has one.
+type todoItem struct {
+	Line   int
+	Raw    string
+	Indent string
+	Stamp  string
+	Text   string
+	Done   bool
+}
+
+// extractTodos returns the task list items in data, in order.  Code fences
+// are skipped, so that a checkbox shown as an example isn't taken for a
+// task.
+func extractTodos(data []byte) []todoItem {
+	var items []todoItem
+	fence := ""
+	for i, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			switch {
+			case len(fence) == 0:
+				fence = m[1]
+			case m[1] == fence:
+				fence = ""
+			}
+			continue
+		}
+		if len(fence) > 0 {
+			continue
+		}
+		m := taskRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		items = append(items, todoItem{
+			Line:   i + 1,
+			Raw:    line,
+			Indent: m[1],
+			Stamp:  m[2],
+			Text:   strings.TrimSpace(m[4]),
+			Done:   m[3] != " ",
+		})
+	}
+	return items
+}
+
+// entryTodos is the task list items of one entry.
+type entryTodos struct {
+	Entry Entry
+	Items []todoItem
+}
+
+// collectTodos returns the task list items of entries that have any, open
+// ones only unless done is set.
+func collectTodos(cfg Configuration, entries []Entry, done bool) ([]entryTodos, error) {
+	var out []entryTodos
+	for _, e := range entries {
+		data, err := readEntry(cfg, e.Path)
+		if err != nil {
+			return nil, err
+		}
+		var kept []todoItem
+		for _, it := range extractTodos(data) {
+			if done || !it.Done {
+				kept = append(kept, it)
+			}
+		}
+		if len(kept) > 0 {
+			out = append(out, entryTodos{e, kept})
+		}
+	}
+	return out, nil
+}
+
+// runTodo lists the open task list items of the entries in range, grouped by
+// entry with their line numbers.  --include-done lists ticked items too, and
+// --stale=<n> marks open ones written more than n days ago.
+func runTodo(cfg Configuration, params Parameters) error {
+	stale := 0
+	if len(params.Stale) > 0 {
+		n, err := strconv.Atoi(params.Stale)
+		if err != nil || n < 1 {
+			return fmt.Errorf("--stale must be a positive number of days, not '%s'", params.Stale)
+		}
+		stale = n
+	}
+	r, err := resolveQuery(cfg, queryFor(params), dayNow())
+	if err != nil {
+		return err
+	}
+	entries, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	todos, err := collectTodos(cfg, filterEntries(entries, r.From, r.To), params.IncludeDone)
+	if err != nil {
+		return err
+	}
+	if len(todos) == 0 {
+		fmt.Println("no open todos")
+		return nil
+	}
+	printTodos(os.Stdout, cfg, todos, stale)
+	return nil
+}
+
+func printTodos(w io.Writer, cfg Configuration, todos []entryTodos, stale int) {
+	today := datePathFromTime(dayNow())
+	color := colorful()
+	for i, t := range todos {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		date := humanDate(t.Entry.Date)
+		if color {
+			date = heading(date)
+		}
+		fmt.Fprintf(w, "%s  %s\n", date, faint(relEntryPath(cfg, t.Entry.Path)))
+		age := daysBetween(t.Entry.Date, today)
+		for _, it := range t.Items {
+			box := "[ ]"
+			if it.Done {
+				box = "[x]"
+			}
+			line := fmt.Sprintf("  %4d  %s%s %s", it.Line, it.Indent, box, it.Text)
+			if stale > 0 && !it.Done && age > stale {
+				mark := fmt.Sprintf("stale, %d days", age)
+				if color {
+					mark = highlight(mark)
+				}
+				line += "  " + mark
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
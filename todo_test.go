@@ -0,0 +1,32 @@
+package wm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractTodos(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []todoItem
+	}{
+		{"open", "- [ ] buy milk", []todoItem{{Line: 1, Raw: "- [ ] buy milk", Text: "buy milk"}}},
+		{"done", "* [x] buy milk", []todoItem{{Line: 1, Raw: "* [x] buy milk", Text: "buy milk", Done: true}}},
+		{"nested", "  + [X] sub", []todoItem{{Line: 1, Raw: "  + [X] sub", Indent: "  ", Text: "sub", Done: true}}},
+		{"stamped", "[09:15] - [ ] call Bob", []todoItem{{Line: 1, Raw: "[09:15] - [ ] call Bob", Stamp: "[09:15] ", Text: "call Bob"}}},
+		{"stamped done", "[23:59]  - [x] ship it", []todoItem{{Line: 1, Raw: "[23:59]  - [x] ship it", Stamp: "[23:59]  ", Text: "ship it", Done: true}}},
+		{"stamped note", "[09:15] called Bob", nil},
+		{"bad stamp", "[9:15] - [ ] call Bob", nil},
+		{"no box", "- buy milk", nil},
+		{"fenced", "```\n- [ ] example\n```", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractTodos([]byte(tt.line))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractTodos(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package wm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// wm edits its configuration file by surgery rather than by re-encoding the
+// Configuration struct, so comments, key order, formatting, and keys wm
+// doesn't know about all survive.  Only the value of the edited key changes,
+// or one line is added for a missing key.
+
+var (
+	tableHeaderRe = regexp.MustCompile(`^\s*\[\[?\s*([^\[\]]+?)\s*\]\]?\s*(#.*)?$`)
+	keyLineRe     = regexp.MustCompile(`^\s*("([^"]*)"|'([^']*)'|[A-Za-z0-9_-]+)\s*=`)
+)
+
+// tomlLine is one physical line of the file with its table and, for a
+// key/value line, the key and the byte span of the value.
+type tomlLine struct {
+	Start, End int // span of the line without its newline
+	Table      string
+	Key        string
+	ValStart   int
+	ValEnd     int
+	Header     bool
+	Blank      bool
+}
+
+// valueEnd returns the offset just past the TOML value starting at i,
+// following strings, multi-line strings, arrays, and inline tables across
+// lines, and stopping before a trailing comment.
+func valueEnd(data []byte, i int) int {
+	depth := 0
+	last := i
+	for i < len(data) {
+		c := data[i]
+		switch {
+		case bytes.HasPrefix(data[i:], []byte(`"""`)) || bytes.HasPrefix(data[i:], []byte(`'''`)):
+			delim := data[i : i+3]
+			j := i + 3
+			for j < len(data) && !bytes.HasPrefix(data[j:], delim) {
+				if delim[0] == '"' && data[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			i = j + 3
+			for i < len(data) && data[i] == delim[0] {
+				i++
+			}
+			last = i
+			continue
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(data) && data[j] != c && data[j] != '\n' {
+				if c == '"' && data[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			i = j + 1
+			last = i
+			continue
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == '#':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '\n':
+			if depth <= 0 {
+				return last
+			}
+		}
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			last = i + 1
+		}
+		i++
+	}
+	if last > len(data) {
+		last = len(data)
+	}
+	return last
+}
+
+// scanTOML splits data into logical lines, each value spanning as many
+// physical lines as it needs.
+func scanTOML(data []byte) []tomlLine {
+	var lines []tomlLine
+	table := ""
+	for off := 0; off < len(data); {
+		end := bytes.IndexByte(data[off:], '\n')
+		if end < 0 {
+			end = len(data)
+		} else {
+			end += off
+		}
+		text := data[off:end]
+		l := tomlLine{Start: off, End: end, Table: table, Blank: len(bytes.TrimSpace(text)) == 0}
+		if m := tableHeaderRe.FindSubmatch(text); m != nil && !bytes.Contains(text, []byte("=")) {
+			table = strings.Trim(string(m[1]), `"' `)
+			l.Table, l.Header = table, true
+		} else if m := keyLineRe.FindSubmatchIndex(text); m != nil {
+			key := string(text[m[2]:m[3]])
+			l.Key = strings.Trim(key, `"'`)
+			vs := off + m[1]
+			for vs < len(data) && (data[vs] == ' ' || data[vs] == '\t') {
+				vs++
+			}
+			l.ValStart, l.ValEnd = vs, valueEnd(data, vs)
+			if nl := bytes.IndexByte(data[l.ValEnd:], '\n'); nl >= 0 {
+				l.End = l.ValEnd + nl
+			} else {
+				l.End = len(data)
+			}
+		}
+		lines = append(lines, l)
+		off = l.End + 1
+	}
+	return lines
+}
+
+// setTOMLValue returns data with key in table set to value, a TOML literal
+// such as `"~/logs"` or `true`.  table is "" for top-level keys.  An existing
+// value is replaced in place; a missing key is added after the last line of
+// its table, and a missing table is added at the end.
+func setTOMLValue(data []byte, table string, key string, value string) []byte {
+	lines := scanTOML(data)
+	splice := func(start, end int, text string) []byte {
+		var b bytes.Buffer
+		b.Write(data[:start])
+		b.WriteString(text)
+		b.Write(data[end:])
+		return b.Bytes()
+	}
+	lastInTable := -1
+	seenTable := table == ""
+	for i, l := range lines {
+		if l.Table != table {
+			continue
+		}
+		if l.Key == key && !l.Header {
+			return splice(l.ValStart, l.ValEnd, value)
+		}
+		if l.Header {
+			seenTable = true
+		}
+		if !l.Blank && seenTable {
+			lastInTable = i
+		} else if lastInTable < 0 && table == "" && !l.Blank {
+			lastInTable = i
+		}
+	}
+	line := key + " = " + value
+	if !keyLineRe.MatchString(line) {
+		line = tomlString(key) + " = " + value
+	}
+	switch {
+	case lastInTable >= 0:
+		at := lines[lastInTable].End
+		return splice(at, at, "\n"+line)
+	case table == "":
+		return append([]byte(line+"\n"), data...)
+	}
+	var b bytes.Buffer
+	b.Write(data)
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		b.WriteString("\n")
+	}
+	if len(data) > 0 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "[%s]\n%s\n", table, line)
+	return b.Bytes()
+}
+
+// tomlString encodes s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// tomlLiteral returns value unchanged when it is already a valid TOML value,
+// such as 200, true, or "x", and quotes it as a string otherwise.
+func tomlLiteral(value string) string {
+	var v map[string]interface{}
+	if _, err := toml.Decode("v = "+value, &v); err == nil {
+		return value
+	}
+	return tomlString(value)
+}
+
+// editConfig sets a key in the configuration file in place.  The edited file
+// must still decode, the previous version is kept as <file>.bak, and the new
+// one is written to a temporary file and renamed into place.
+func editConfig(cfgFile string, table string, key string, value string) error {
+	data, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return err
+	}
+	edited := setTOMLValue(data, table, key, value)
+	var check Configuration
+	if _, err := toml.Decode(string(edited), &check); err != nil {
+		return fmt.Errorf("refusing to write an invalid configuration: %w", err)
+	}
+	info, err := os.Stat(cfgFile)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(cfgFile+".bak", data, info.Mode())
+	if err != nil {
+		return fmt.Errorf("failed to back up the configuration: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(cfgFile), "."+filepath.Base(cfgFile)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(edited)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), cfgFile)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// runConfigSet handles "config set <key> <value>", where key may name a
+// table key as "lint.max_line_length".
+func runConfigSet(cfgFile string, params Parameters) error {
+	table, key := "", params.Key
+	if i := strings.LastIndex(key, "."); i > 0 {
+		table, key = key[:i], key[i+1:]
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("bad key '%s'", params.Key)
+	}
+	return editConfig(cfgFile, table, key, tomlLiteral(params.Value))
+}
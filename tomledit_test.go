@@ -0,0 +1,111 @@
+package wm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gnarlyConfig is a configuration as people keep them: comments everywhere,
+// odd spacing, values across lines, and keys wm has never heard of.
+const gnarlyConfig = `# my wm setup
+   root   =	"~/logs"   # synced
+editor = 'vim'
+my_own_key = { a = 1, b = "x # not a comment" }
+
+extra_roots = [
+  "~/old",   # 2019
+  "~/older", # 2015
+]
+"quoted key" = 1
+template = """
+Plan:
+# not a header
+"""
+
+[lint]
+  # strict
+  max_line_length = 100
+  unknown_lint = true
+
+[[notebooks]]
+name = "work"
+
+[redact]
+patterns = ['\d{4}']
+`
+
+func TestSetTOMLValue(t *testing.T) {
+	for _, tt := range []struct {
+		name, table, key, value string
+		old, new                string // what the edit replaces, and with what
+	}{
+		{"spaced top-level", "", "root", `"~/wm"`, "root   =\t\"~/logs\"   # synced", "root   =\t\"~/wm\"   # synced"},
+		{"literal string", "", "editor", `"nvim"`, "editor = 'vim'", `editor = "nvim"`},
+		{"multi-line array", "", "extra_roots", `["~/a"]`, "extra_roots = [\n  \"~/old\",   # 2019\n  \"~/older\", # 2015\n]", `extra_roots = ["~/a"]`},
+		{"after a multi-line string", "", "template", `"x"`, "template = \"\"\"\nPlan:\n# not a header\n\"\"\"", `template = "x"`},
+		{"quoted key", "", "quoted key", "2", `"quoted key" = 1`, `"quoted key" = 2`},
+		{"indented table key", "lint", "max_line_length", "80", "  max_line_length = 100", "  max_line_length = 80"},
+		{"missing table key", "lint", "ignore", "[]", "  unknown_lint = true\n", "  unknown_lint = true\nignore = []\n"},
+		{"missing top-level key", "", "backups", "3", "\"\"\"\n\n[lint]", "\"\"\"\nbackups = 3\n\n[lint]"},
+		{"regexp in a literal string", "redact", "patterns", `['\w+']`, `patterns = ['\d{4}']`, `patterns = ['\w+']`},
+		{"missing table", "search", "context_lines", "2", "patterns = ['\\d{4}']\n", "patterns = ['\\d{4}']\n\n[search]\ncontext_lines = 2\n"},
+	} {
+		got := string(setTOMLValue([]byte(gnarlyConfig), tt.table, tt.key, tt.value))
+		if strings.Count(gnarlyConfig, tt.old) != 1 {
+			t.Fatalf("%s: %q isn't in the configuration once", tt.name, tt.old)
+		}
+		// everything but the edit survives byte for byte
+		if want := strings.Replace(gnarlyConfig, tt.old, tt.new, 1); got != want {
+			t.Errorf("%s: setting %s.%s = %s gave\n%s\nwant\n%s", tt.name, tt.table, tt.key, tt.value, got, want)
+		}
+	}
+}
+
+func TestSetTOMLValueEmpty(t *testing.T) {
+	if got := string(setTOMLValue(nil, "", "root", `"~/wm"`)); got != "root = \"~/wm\"\n" {
+		t.Errorf("top-level key in an empty file = %q", got)
+	}
+	if got := string(setTOMLValue([]byte("root = 1"), "lint", "ignore", "[]")); got != "root = 1\n\n[lint]\nignore = []\n" {
+		t.Errorf("table key after a file without a final newline = %q", got)
+	}
+}
+
+func TestEditConfig(t *testing.T) {
+	cfgFile := filepath.Join(t.TempDir(), "wm.toml")
+	if err := os.WriteFile(cfgFile, []byte(gnarlyConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := editConfig(cfgFile, "lint", "max_line_length", "80"); err != nil {
+		t.Fatalf("editConfig: %v", err)
+	}
+	assertEntry(t, cfgFile+".bak", gnarlyConfig)
+	assertEntry(t, cfgFile, strings.Replace(gnarlyConfig, "max_line_length = 100", "max_line_length = 80", 1))
+	assertMode(t, cfgFile, 0o600)
+
+	// a value that breaks the file is refused and nothing is written
+	if err := editConfig(cfgFile, "", "root", `"unterminated`); err == nil || !strings.Contains(err.Error(), "invalid configuration") {
+		t.Errorf("editConfig with an invalid value = %v, want it refused", err)
+	}
+	assertEntry(t, cfgFile, strings.Replace(gnarlyConfig, "max_line_length = 100", "max_line_length = 80", 1))
+	assertEntry(t, cfgFile+".bak", gnarlyConfig)
+	if tmp, _ := filepath.Glob(filepath.Join(filepath.Dir(cfgFile), ".wm.toml.*.tmp")); len(tmp) > 0 {
+		t.Errorf("editConfig left %q behind", tmp)
+	}
+}
+
+func TestTOMLLiteral(t *testing.T) {
+	for in, want := range map[string]string{
+		"200":      "200",
+		"true":     "true",
+		`"x"`:      `"x"`,
+		"~/logs":   `"~/logs"`,
+		`say "hi"`: `"say \"hi\""`,
+		"[1, 2]":   "[1, 2]",
+	} {
+		if got := tomlLiteral(in); got != want {
+			t.Errorf("tomlLiteral(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
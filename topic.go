@@ -0,0 +1,84 @@
+package wm
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Topics are per-project threads inside a day.  The entry for topic
+// "zephyr" on 2024-03-07 lives next to the day's main entry as
+// 2024/3/7.zephyr.txt: the topic goes between the name the layout gives the
+// date and the extension.  Topic names are slugs with at least one letter, so
+// they never read as part of a date.
+
+const maxTopicLen = 64
+
+// checkTopic rejects topic names that aren't slugs.
+func checkTopic(name string) error {
+	if !scratchNameRe.MatchString(name) || len(name) > maxTopicLen {
+		if slug := scratchSlug(name); len(slug) > 0 && slug != name && len(slug) <= maxTopicLen {
+			return fmt.Errorf("'%s' is not a valid topic; use lowercase letters, digits, and dashes, such as '%s'", name, slug)
+		}
+		return fmt.Errorf("'%s' is not a valid topic; use up to %d lowercase letters, digits, and dashes", name, maxTopicLen)
+	}
+	if strings.Trim(name, "0123456789-") == "" {
+		return fmt.Errorf("'%s' is not a valid topic; topics need a letter", name)
+	}
+	return nil
+}
+
+// withTopic inserts topic into p, a path ending in ext.
+func withTopic(p, ext, topic string) string {
+	if len(topic) == 0 {
+		return p
+	}
+	return strings.TrimSuffix(p, ext) + "." + topic + ext
+}
+
+// splitTopic splits the topic off p, a path ending in ext, returning the
+// path of the day's main entry and the topic, which is "" for a main entry.
+func splitTopic(p, ext string) (string, string) {
+	stem := strings.TrimSuffix(p, ext)
+	i := strings.LastIndexByte(stem, '.')
+	if i < 0 || strings.ContainsAny(stem[i:], `/\`) {
+		return p, ""
+	}
+	topic := stem[i+1:]
+	if checkTopic(topic) != nil {
+		return p, ""
+	}
+	return stem[:i] + ext, topic
+}
+
+// topicEntryPath returns the path of the entry for topic on pd, or of the
+// main entry when topic is "".
+func topicEntryPath(cfg Configuration, pd *DatePath, topic string) (string, error) {
+	p, err := entryPath(cfg, pd)
+	if err != nil {
+		return "", err
+	}
+	return withTopic(p, filepath.Ext(p), topic), nil
+}
+
+// filterTopic keeps the entries of topic, or all of them when topic is "".
+func filterTopic(entries []Entry, topic string) []Entry {
+	if len(topic) == 0 {
+		return entries
+	}
+	var kept []Entry
+	for _, e := range entries {
+		if e.Topic == topic {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// topicLabel is the label shown after an entry's date for its topic.
+func topicLabel(e Entry) string {
+	if len(e.Topic) == 0 {
+		return ""
+	}
+	return " (topic " + e.Topic + ")"
+}
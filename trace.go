@@ -0,0 +1,29 @@
+package wm
+
+import (
+	"log"
+	"strings"
+)
+
+// verbose makes wm log the decisions that lead to the file it works on, such
+// as which configuration file it read, the root after expanding it, the date
+// it parsed, the entry's path, and the editor command line, for finding out
+// why the wrong file was opened.  It is set by --verbose, which every command
+// accepts, and turns on what -v notes for the command too.
+var verbose = false
+
+// tracef logs one decision when verbose is set, to stderr like the notes.
+func tracef(format string, args ...interface{}) {
+	if verbose {
+		log.Printf(":::verbose::: "+format, args...)
+	}
+}
+
+// argvLine renders argv as a command line for a POSIX shell.
+func argvLine(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, a := range argv {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
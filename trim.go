@@ -0,0 +1,279 @@
+package wm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TrimConfig tunes how "trim" tells pasted machine output, such as stack
+// traces and logs, from writing.  A block is taken for pasted output when at
+// most MaxProseRatio of its lines read as prose, when at least half of them
+// are longer than LongLine characters, or when at least MinIndented of them
+// share the same indentation.
+type TrimConfig struct {
+	MaxProseRatio float64 `toml:"max_prose_ratio"`
+	LongLine      int     `toml:"long_line"`
+	MinIndented   float64 `toml:"min_indented"`
+}
+
+const (
+	defaultTrimOver      = 200
+	defaultMaxProseRatio = 0.3
+	defaultLongLine      = 160
+	defaultMinIndented   = 0.6
+	// trimKeep is how many lines are kept at either end of a trimmed block.
+	trimKeep = 3
+)
+
+var trimMarkerRe = regexp.MustCompile(`^\[\.\.\. [\d,]+ lines trimmed, original in (attachments/\S+)\]$`)
+
+// withDefaults fills in the settings left unset.
+func (c TrimConfig) withDefaults() TrimConfig {
+	if c.MaxProseRatio == 0 {
+		c.MaxProseRatio = defaultMaxProseRatio
+	}
+	if c.LongLine == 0 {
+		c.LongLine = defaultLongLine
+	}
+	if c.MinIndented == 0 {
+		c.MinIndented = defaultMinIndented
+	}
+	return c
+}
+
+// textBlock is a run of lines, Start counted from 0.
+type textBlock struct {
+	Start, End int
+}
+
+// textBlocks splits lines into blocks separated by blank lines.  A code
+// fence is a single block up to its closing fence, blank lines and all.
+func textBlocks(lines []string) []textBlock {
+	var blocks []textBlock
+	for i := 0; i < len(lines); {
+		if isBlank([]byte(lines[i])) {
+			i++
+			continue
+		}
+		start := i
+		if fenceRe.MatchString(lines[i]) {
+			for i++; i < len(lines) && !fenceRe.MatchString(lines[i]); i++ {
+			}
+			if i < len(lines) {
+				i++
+			}
+		} else {
+			for i < len(lines) && !isBlank([]byte(lines[i])) && !fenceRe.MatchString(lines[i]) {
+				i++
+			}
+		}
+		blocks = append(blocks, textBlock{start, i})
+	}
+	return blocks
+}
+
+// isProse reports whether line reads like a written sentence: several words
+// made mostly of letters and spaces.
+func isProse(line string) bool {
+	line = strings.TrimSpace(line)
+	if len(strings.Fields(line)) < 4 {
+		return false
+	}
+	letters, total := 0, 0
+	for _, r := range line {
+		total++
+		if unicode.IsLetter(r) || r == ' ' || r == ',' || r == '.' || r == '\'' {
+			letters++
+		}
+	}
+	return float64(letters) >= 0.85*float64(total)
+}
+
+// pasteScore is how a block measures against the TrimConfig heuristics.
+type pasteScore struct {
+	Prose, Long, Indented float64
+}
+
+func scoreBlock(lines []string, c TrimConfig) pasteScore {
+	prose, long := 0, 0
+	indents := map[string]int{}
+	for _, l := range lines {
+		if isProse(l) {
+			prose++
+		}
+		if len([]rune(l)) > c.LongLine {
+			long++
+		}
+		if indent := l[:len(l)-len(strings.TrimLeft(l, " \t"))]; len(indent) > 0 {
+			indents[indent]++
+		}
+	}
+	common := 0
+	for _, n := range indents {
+		if n > common {
+			common = n
+		}
+	}
+	n := float64(len(lines))
+	return pasteScore{float64(prose) / n, float64(long) / n, float64(common) / n}
+}
+
+func (s pasteScore) pasted(c TrimConfig) bool {
+	return s.Prose <= c.MaxProseRatio || s.Long >= 0.5 || s.Indented >= c.MinIndented
+}
+
+// groupDigits renders n with commas between groups of three digits.
+func groupDigits(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// trimAttachment returns a path for the trimmed block starting at line
+// under the attachments of pd that isn't taken yet, relative to the root.
+func trimAttachment(cfg Configuration, pd *DatePath, line int) string {
+	base := fmt.Sprintf("%s/%s/trimmed-L%d", attachmentsDir, pd.Iso(), line)
+	rel := base + ".txt"
+	for n := 2; ; n++ {
+		if _, err := os.Stat(filepath.Join(cfg.Root, filepath.FromSlash(rel))); errors.Is(err, os.ErrNotExist) {
+			return rel
+		}
+		rel = fmt.Sprintf("%s-%d.txt", base, n)
+	}
+}
+
+// runTrim finds blocks of the entry for the date given that are longer than
+// --over lines and look like pasted output, shows each, and on confirmation
+// moves the block to an attachment, leaving its first and last lines and a
+// marker naming the attachment.  The entry is backed up to the versions
+// store first, and "trim --restore" puts the blocks back.
+func runTrim(cfg Configuration, params Parameters) error {
+	pd, err := parseDateString(cfg, strings.Join(params.DateWords, " "))
+	if err != nil {
+		return err
+	}
+	path, err := entryPath(cfg, pd)
+	if err != nil {
+		return err
+	}
+	data, err := readEntry(cfg, path)
+	if err != nil {
+		return err
+	}
+	if params.Restore {
+		return restoreTrimmed(cfg, path, data, params)
+	}
+	over := defaultTrimOver
+	if len(params.Over) > 0 {
+		over, err = strconv.Atoi(params.Over)
+		if err != nil || over <= 2*trimKeep {
+			return fmt.Errorf("--over must be a number of lines above %d, not '%s'", 2*trimKeep, params.Over)
+		}
+	}
+	c := cfg.Trim.withDefaults()
+	lines := strings.Split(string(data), "\n")
+	var out []string
+	next, trimmed := 0, 0
+	for _, b := range textBlocks(lines) {
+		block := lines[b.Start:b.End]
+		if len(block) <= over {
+			continue
+		}
+		s := scoreBlock(block, c)
+		if !s.pasted(c) {
+			continue
+		}
+		cut := len(block) - 2*trimKeep
+		fmt.Printf("lines %d-%d (%s lines): %.0f%% prose, %.0f%% long lines, %.0f%% same indentation\n",
+			b.Start+1, b.End, groupDigits(len(block)), 100*s.Prose, 100*s.Long, 100*s.Indented)
+		for _, l := range block[:trimKeep] {
+			fmt.Printf("  | %s\n", truncate(l, 100))
+		}
+		fmt.Printf("  | [... %s lines ...]\n", groupDigits(cut))
+		for _, l := range block[len(block)-trimKeep:] {
+			fmt.Printf("  | %s\n", truncate(l, 100))
+		}
+		if params.DryRun || (!params.Yes && !confirm("trim this block?")) {
+			continue
+		}
+		rel := trimAttachment(cfg, pd, b.Start+1)
+		full := filepath.Join(cfg.Root, filepath.FromSlash(rel))
+		if err := makeDir(cfg, filepath.Dir(full)); err != nil {
+			return err
+		}
+		archived, err := sealEntry(cfg, full, []byte(strings.Join(block, "\n")+"\n"))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(full, archived, fileMode(cfg)); err != nil {
+			return err
+		}
+		out = append(out, lines[next:b.Start+trimKeep]...)
+		out = append(out, fmt.Sprintf("[... %s lines trimmed, original in %s]", groupDigits(cut), rel))
+		next = b.End - trimKeep
+		trimmed++
+	}
+	if trimmed == 0 {
+		fmt.Println("nothing trimmed")
+		return nil
+	}
+	out = append(out, lines[next:]...)
+	backup, err := rewriteEntry(cfg, path, []byte(strings.Join(out, "\n")), rewriteOptions{Backup: true})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("trimmed %d blocks; the entry as it was is %s\n", trimmed, backup)
+	return nil
+}
+
+// restoreTrimmed puts back every trimmed block of the entry at path whose
+// attachment still matches the lines kept around its marker.
+func restoreTrimmed(cfg Configuration, path string, data []byte, params Parameters) error {
+	lines := strings.Split(string(data), "\n")
+	var out []string
+	next, restored := 0, 0
+	for i, l := range lines {
+		m := trimMarkerRe.FindStringSubmatch(l)
+		if m == nil || i < next+trimKeep || i+trimKeep >= len(lines) {
+			continue
+		}
+		original, err := readEntry(cfg, filepath.Join(cfg.Root, filepath.FromSlash(m[1])))
+		if err != nil {
+			fmt.Printf("line %d: not restored: %v\n", i+1, err)
+			continue
+		}
+		block := strings.Split(strings.TrimSuffix(string(original), "\n"), "\n")
+		if len(block) < 2*trimKeep ||
+			strings.Join(block[:trimKeep], "\n") != strings.Join(lines[i-trimKeep:i], "\n") ||
+			strings.Join(block[len(block)-trimKeep:], "\n") != strings.Join(lines[i+1:i+1+trimKeep], "\n") {
+			fmt.Printf("line %d: not restored: the lines around the marker no longer match %s\n", i+1, m[1])
+			continue
+		}
+		fmt.Printf("line %d: restoring %d lines from %s\n", i+1, len(block), m[1])
+		out = append(out, lines[next:i-trimKeep]...)
+		out = append(out, block...)
+		next = i + 1 + trimKeep
+		restored++
+	}
+	if restored == 0 || params.DryRun {
+		if restored == 0 {
+			fmt.Println("nothing restored")
+		}
+		return nil
+	}
+	out = append(out, lines[next:]...)
+	backup, err := rewriteEntry(cfg, path, []byte(strings.Join(out, "\n")), rewriteOptions{Backup: true})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored %d blocks; the attachments are kept, and the trimmed entry is %s\n", restored, backup)
+	return nil
+}
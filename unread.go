@@ -0,0 +1,124 @@
+package wm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	lastSeenFile    = "last-seen.json"
+	lastSeenVersion = 1
+)
+
+// rootKey returns the key under which per-root state is stored, so that
+// several roots or profiles are tracked independently.
+func rootKey(root string) string {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return filepath.Clean(root)
+}
+
+// readLastSeen returns the last-seen marks of every root.  A missing store
+// means nothing has been seen yet, and a corrupt one is noted and treated the
+// same way so it is rebuilt on the next update.
+func readLastSeen() (map[string]time.Time, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := readState(filepath.Join(dir, lastSeenFile), lastSeenVersion)
+	if errors.Is(err, errStateCorrupt) {
+		log.Println(":::note::: ignoring unreadable", lastSeenFile)
+		data, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeLastSeen(data)
+}
+
+func decodeLastSeen(data []byte) (map[string]time.Time, error) {
+	marks := map[string]time.Time{}
+	if len(data) == 0 {
+		return marks, nil
+	}
+	err := json.Unmarshal(data, &marks)
+	if err != nil {
+		return nil, fmt.Errorf("bad %s: %w", lastSeenFile, err)
+	}
+	return marks, nil
+}
+
+// writeLastSeen sets the last-seen mark of root, keeping the marks of other
+// roots written meanwhile by other processes.
+func writeLastSeen(root string, mark time.Time) error {
+	path, err := statePath(lastSeenFile)
+	if err != nil {
+		return err
+	}
+	return updateState(path, lastSeenVersion, func(old []byte) ([]byte, error) {
+		marks, err := decodeLastSeen(old)
+		if err != nil {
+			marks = map[string]time.Time{}
+		}
+		marks[rootKey(root)] = mark
+		return json.MarshalIndent(marks, "", "  ")
+	})
+}
+
+// runUnread lists entries edited since the root's last-seen mark, then moves
+// the mark to the time of this run unless --peek was given.
+func runUnread(cfg Configuration, params Parameters) error {
+	if params.MarkAllRead {
+		return writeLastSeen(cfg.Root, now())
+	}
+	if len(params.MarkRead) > 0 {
+		dp, err := parseDateString(cfg, params.MarkRead)
+		if err != nil {
+			return err
+		}
+		return writeLastSeen(cfg.Root, dp.Time().AddDate(0, 0, 1))
+	}
+
+	marks, err := readLastSeen()
+	if err != nil {
+		return err
+	}
+	mark := marks[rootKey(cfg.Root)]
+	seen := now()
+	entries, err := listEntries(cfg, walkOptionsFor(params))
+	if err != nil {
+		return err
+	}
+	var unread []Entry
+	for _, e := range entries {
+		if e.ModTime.After(mark) {
+			unread = append(unread, e)
+		}
+	}
+	sort.SliceStable(unread, func(i, j int) bool { return unread[i].ModTime.After(unread[j].ModTime) })
+	if len(unread) == 0 {
+		if mark.IsZero() {
+			fmt.Println("no entries")
+		} else {
+			fmt.Printf("nothing new since %s\n", formatTime(cfg, mark))
+		}
+	}
+	for _, e := range unread {
+		preview := ""
+		if data, err := readEntry(cfg, e.Path); err == nil {
+			preview = entryPreview(data)
+		}
+		fmt.Printf("%s  edited %s  %s\n", e.Date.Iso(), formatTime(cfg, e.ModTime), preview)
+	}
+	if params.Peek {
+		return nil
+	}
+	return writeLastSeen(cfg.Root, seen)
+}
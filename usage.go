@@ -0,0 +1,170 @@
+package wm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usageFile = "usage.tsv"
+
+// commandName returns the subcommand that docopt matched, or "open" for the
+// default open-by-date flow.
+func commandName(opts map[string]interface{}) string {
+	for k, v := range opts {
+		if strings.HasPrefix(k, "-") || strings.HasPrefix(k, "<") {
+			continue
+		}
+		if b, ok := v.(bool); ok && b {
+			return k
+		}
+	}
+	return "open"
+}
+
+// recordUsage appends one line to the local usage stats file under its lock.  Only the
+// time, command, duration, and exit status are stored, never arguments or
+// content.  Failures are ignored and a slow disk is given a short grace
+// period at most, so recording can never cause a command to fail.
+func recordUsage(command string, started time.Time, status int) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		path, err := statePath(usageFile)
+		if err != nil {
+			return
+		}
+		unlock, err := lockState(path)
+		if err != nil {
+			return
+		}
+		defer unlock()
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		fmt.Fprintf(f, "%s\t%s\t%d\t%d\n", started.Format(time.RFC3339), command, time.Since(started).Milliseconds(), status)
+	}()
+	select {
+	case <-done:
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+type usageRecord struct {
+	When     time.Time
+	Command  string
+	Duration time.Duration
+	Status   int
+}
+
+func readUsage(path string) ([]usageRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []usageRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 4 {
+			continue
+		}
+		when, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			continue
+		}
+		ms, err1 := strconv.Atoi(fields[2])
+		status, err2 := strconv.Atoi(fields[3])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		records = append(records, usageRecord{when, fields[1], time.Duration(ms) * time.Millisecond, status})
+	}
+	return records, scanner.Err()
+}
+
+// runUsage summarizes the local usage stats or wipes them with --clear.
+func runUsage(params Parameters) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, usageFile)
+	if params.Clear {
+		err = os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		fmt.Println("usage stats cleared")
+		return nil
+	}
+	records, err := readUsage(path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(records) == 0) {
+		fmt.Println("no usage recorded yet; set usage_stats = true to start")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	weeks := map[string]map[string]int{}
+	var weekKeys []string
+	var hours [24]int
+	var searchTotal time.Duration
+	searches := 0
+	for _, r := range records {
+		y, w := r.When.ISOWeek()
+		key := fmt.Sprintf("%d-W%02d", y, w)
+		if _, ok := weeks[key]; !ok {
+			weeks[key] = map[string]int{}
+			weekKeys = append(weekKeys, key)
+		}
+		weeks[key][r.Command]++
+		hours[r.When.Local().Hour()]++
+		if r.Command == "search" {
+			searchTotal += r.Duration
+			searches++
+		}
+	}
+	sort.Strings(weekKeys)
+
+	fmt.Println("commands per week:")
+	for _, key := range weekKeys {
+		var cmds []string
+		for c := range weeks[key] {
+			cmds = append(cmds, c)
+		}
+		sort.Strings(cmds)
+		var parts []string
+		for _, c := range cmds {
+			parts = append(parts, fmt.Sprintf("%s %d", c, weeks[key][c]))
+		}
+		fmt.Printf("  %s  %s\n", key, strings.Join(parts, ", "))
+	}
+
+	order := make([]int, 24)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return hours[order[i]] > hours[order[j]] })
+	fmt.Println("busiest hours:")
+	for _, h := range order[:3] {
+		if hours[h] == 0 {
+			break
+		}
+		fmt.Printf("  %02d:00  %d\n", h, hours[h])
+	}
+	if searches > 0 {
+		fmt.Printf("average search duration: %s over %d searches\n", (searchTotal / time.Duration(searches)).Round(time.Millisecond), searches)
+	}
+	return nil
+}
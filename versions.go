@@ -0,0 +1,33 @@
+package wm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const versionsDir = ".versions"
+
+// backupEntry copies the entry at path into the root's versions store before
+// wm modifies or moves it, returning the path of the copy.  The copy keeps the
+// entry's position relative to the root and is suffixed with a timestamp.
+func backupEntry(cfg Configuration, path string) (string, error) {
+	rel, err := filepath.Rel(cfg.Root, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	dest := filepath.Join(cfg.Root, versionsDir, rel) + "." + now().Format("20060102T150405.000000000")
+	err = makeDir(cfg, filepath.Dir(dest))
+	if err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	err = os.WriteFile(dest, data, fileMode(cfg))
+	if err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	return dest, nil
+}
@@ -0,0 +1,168 @@
+package wm
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directories never treated as part of the archive unless --all is given.
+var (
+	vcsDirs      = []string{".git", ".hg", ".svn"}
+	internalDirs = []string{".trash", ".versions", ".wm-index", ".wm-trash", "attachments", "scratch"}
+)
+
+// walkOptions controls which directories the walker descends into.  Hidden
+// includes dot-directories other than VCS metadata and wm's own internal
+// directories; All includes everything.
+type walkOptions struct {
+	Hidden bool
+	All    bool
+}
+
+// walkOptionsFor returns the walk options selected on the command line.
+func walkOptionsFor(params Parameters) walkOptions {
+	return walkOptions{Hidden: params.Hidden, All: params.All}
+}
+
+// walkResult is everything the walker found.  Excluded lists the directories
+// that were skipped, relative to the root, so commands can report them.
+// Problems holds the files shaped like entries whose date couldn't be read,
+// such as an unknown month name, so they can be reported individually
+// instead of aborting the walk.  Conflicts lists the conflict copies sync
+// clients left next to entries, relative to the root; they are neither
+// entries nor problems, and wm doctor and wm conflicts report them.
+type walkResult struct {
+	Entries   []Entry
+	Excluded  []string
+	Problems  []error
+	Conflicts []string
+}
+
+func containsString(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// skipDir reports whether the walker should not descend into the directory
+// with the given base name.
+func (o walkOptions) skipDir(name string) bool {
+	if o.All {
+		return false
+	}
+	if containsString(vcsDirs, name) || containsString(internalDirs, name) {
+		return true
+	}
+	return strings.HasPrefix(name, ".") && !o.Hidden
+}
+
+// walkRoot is the single walker every command uses to decide what "the
+// archive" is.  It finds every entry laid out by path_layout, root/YYYY/M/D.txt
+// by default, with the configured extension or a legacy one, sorted by date
+// oldest first, skipping files that don't have that shape and the
+// directories excluded by opts.  Entries are never looked for
+// deeper than the layout puts them, so the walk is bounded even when the root
+// contains other trees.
+func walkRoot(cfg Configuration, opts walkOptions) (walkResult, error) {
+	root, layout, exts := cfg.Root, entryLayout(cfg), entryExts(cfg)
+	// the configuration was checked when it was read
+	conflicts, _ := conflictPatterns(cfg)
+	var res walkResult
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+		depth := strings.Count(rel, string(filepath.Separator))
+		if d.IsDir() {
+			if opts.skipDir(d.Name()) {
+				res.Excluded = append(res.Excluded, rel)
+				return filepath.SkipDir
+			}
+			if depth >= layout.depth() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := matchExt(exts, path)
+		if depth != layout.depth() || len(ext) == 0 {
+			return nil
+		}
+		if len(conflictBase(conflicts, d.Name())) > 0 {
+			res.Conflicts = append(res.Conflicts, rel)
+			return nil
+		}
+		var dp *DatePath
+		main, topic := splitTopic(path, ext)
+		if layout.nested() {
+			if d.Name() == monthFilename {
+				return nil
+			}
+			year := filepath.Base(filepath.Dir(filepath.Dir(path)))
+			if len(year) != 4 || year[0] < '1' || year[0] > '9' {
+				return nil
+			}
+			dp, err = parseEntryPath(main)
+			if err != nil {
+				res.Problems = append(res.Problems, err)
+				return nil
+			}
+		} else {
+			mainRel, _ := splitTopic(rel, ext)
+			dp, err = layout.parse(mainRel, ext)
+			if err != nil {
+				return nil
+			}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		res.Entries = append(res.Entries, Entry{Date: *dp, Path: path, ModTime: info.ModTime(), Topic: topic})
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	sort.SliceStable(res.Entries, func(i, j int) bool {
+		a, b := res.Entries[i], res.Entries[j]
+		if a.Date != b.Date {
+			return a.Date.Before(&b.Date)
+		}
+		return a.Topic < b.Topic
+	})
+	return res, nil
+}
+
+// listEntries returns every entry under the root of cfg sorted by date,
+// oldest first.  Files whose date couldn't be read are noted on stderr.
+// The month index is brought up to date on the way.
+func listEntries(cfg Configuration, opts walkOptions) ([]Entry, error) {
+	started := time.Now()
+	res, err := walkRoot(cfg, opts)
+	for _, p := range res.Problems {
+		log.Println(":::note::: skipped", p)
+	}
+	if err == nil {
+		recordWalk(cfg, res.Entries, started)
+	}
+	return res.Entries, err
+}
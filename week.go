@@ -0,0 +1,83 @@
+package wm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// weekStart is the day weeks start on for "week", from week_start.
+func weekStart(cfg Configuration) (time.Weekday, error) {
+	name := strings.ToLower(strings.TrimSpace(cfg.WeekStart))
+	if len(name) == 0 {
+		return time.Monday, nil
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if name == full || (len(name) >= 3 && strings.HasPrefix(full, name)) {
+			return d, nil
+		}
+	}
+	return time.Monday, fmt.Errorf(`config: "week_start" must be a weekday name such as "sunday", got '%s'`, cfg.WeekStart)
+}
+
+// weekOf returns the seven days of the week containing pd, starting on
+// start.  The days are counted on time.Time so that weeks running into the
+// next month or year come out right.
+func weekOf(pd *DatePath, start time.Weekday) []DatePath {
+	t := pd.Time()
+	first := t.AddDate(0, 0, -((int(t.Weekday()) - int(start) + 7) % 7))
+	days := make([]DatePath, 7)
+	for i := range days {
+		days[i] = datePathFromTime(first.AddDate(0, 0, i))
+	}
+	return days
+}
+
+// runWeek opens the existing entries of the week of the date given, today
+// by default, in the editor at once or, with --cat, prints them one after
+// another under a line naming each day.  Days without an entry are skipped.
+func runWeek(cfg Configuration, params Parameters) error {
+	pd, err := parseDateString(cfg, strings.Join(params.DateWords, " "))
+	if err != nil {
+		return err
+	}
+	start, err := weekStart(cfg)
+	if err != nil {
+		return err
+	}
+	days := weekOf(pd, start)
+	var paths []string
+	var dates []*DatePath
+	for i := range days {
+		path, err := entryPath(cfg, &days[i])
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if params.Cat {
+			data, err := readEntry(cfg, path)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("=== %s (%s) ===\n", days[i].Iso(), weekdayName(days[i].Time().Weekday()))
+			os.Stdout.Write(data)
+			if len(data) > 0 && data[len(data)-1] != '\n' {
+				fmt.Println()
+			}
+		}
+		paths = append(paths, path)
+		dates = append(dates, &days[i])
+	}
+	if len(paths) == 0 {
+		fmt.Printf("no entries in the week of %s to %s\n", days[0].Iso(), days[6].Iso())
+		return nil
+	}
+	if params.Cat {
+		return nil
+	}
+	return editFiles(cfg, fileEdit{Target: editTarget{Kind: kindEntry, Date: dates[0]}, Paths: paths, Dates: dates})
+}
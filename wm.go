@@ -1,14 +1,11 @@
-package main
+package wm
 
 import (
 	"errors"
 	"fmt"
-	"io/fs"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 
@@ -23,121 +20,731 @@ type DatePath struct {
 }
 
 type Parameters struct {
-	Config bool
-	Search bool
-	Term   []string
-	Date   string
+	Config             bool
+	Search             bool
+	Term               []string
+	Date               string   `docopt:"--date"`
+	DateWords          []string `docopt:"<date>"`
+	Format             string
+	Limit              string
+	PageToken          string
+	Lint               bool
+	Fix                bool
+	Range              string `docopt:"<range>,--range"`
+	Pick               bool
+	Modified           bool
+	Since              string
+	Usage              bool
+	Clear              bool
+	Hidden             bool
+	All                bool
+	HelpCmd            bool `docopt:"help"`
+	Dates              bool
+	Meetings           bool
+	FromIcs            string
+	SkipAllday         bool
+	FilesWithMatches   bool
+	JSON               bool `docopt:"--json"`
+	CSV                bool `docopt:"--csv"`
+	Print0             bool
+	CountMatches       bool `docopt:"--count"`
+	Quiet              bool
+	Check              bool
+	Headers            bool
+	FixByHeader        bool
+	DryRun             bool
+	Bundle             bool
+	Export             bool
+	Import             bool
+	Out                string
+	Bundlefile         string
+	Yes                bool
+	Unread             bool
+	Peek               bool
+	MarkRead           string
+	MarkAllRead        bool
+	Consolidate        bool
+	Year               string `docopt:"<year>,--year"`
+	Gzip               bool
+	Split              bool
+	Consolidated       string
+	Html               bool
+	Print              bool
+	RedactTag          bool
+	From               string `docopt:"--from,<from>"`
+	To                 string `docopt:"--to,<to>"`
+	In                 string
+	Last               string
+	Weeks              string
+	Migrate            bool
+	Layout             string
+	IgnoreCase         bool
+	CaseSensitive      bool
+	SmartCase          bool
+	FoldDiacritics     bool
+	Line               bool
+	FixedStrings       bool
+	Word               bool
+	At                 string
+	AtTag              string
+	EnsureTemplate     bool
+	Fill               bool
+	Template           string
+	Verbose            bool
+	InlineDates        bool
+	Show               bool
+	ConfigPath         bool `docopt:"--path"`
+	Schema             bool
+	CheckConfig        bool `docopt:"--check"`
+	LintPatterns       bool `docopt:"--lint-patterns"`
+	NoLocal            bool
+	Set                bool
+	Key                string
+	Value              string
+	IncludeAttachments bool
+	ReviewQueue        bool `docopt:"review-queue"`
+	Add                bool
+	Next               bool
+	Stats              bool
+	Compare            string
+	Vs                 string
+	AllowOverlap       bool
+	Pattern            []string
+	Decisions          bool
+	Review             bool
+	Summary            bool
+	Holidays           bool
+	Country            string
+	Init               bool
+	ForceRoot          bool
+	Follow             bool
+	OpenEditor         bool `docopt:"--open"`
+	First              bool
+	ExistingOnly       bool
+	Force              bool
+	Tags               bool
+	Rename             bool
+	Merge              bool
+	Conflicts          bool `docopt:"conflicts"`
+	MergeConflicts     bool `docopt:"--merge"`
+	Move               bool `docopt:"move"`
+	Archive            bool `docopt:"archive"`
+	Before             string
+	Dest               string
+	Compress           bool
+	IncludeArchives    bool
+	Old                string
+	New                string
+	Tag                []string
+	TagFilter          string `docopt:"--tag"`
+	Index              bool
+	Rebuild            bool
+	Redact             bool
+	Redacted           bool
+	PatternFlags       []string `docopt:"--pattern"`
+	PatternFile        []string
+	MappingOut         string
+	Into               string
+	Append             bool
+	Clip               bool
+	Attach             bool
+	Attachments        bool
+	File               string `docopt:"<file>"`
+	Recursive          bool
+	Text               []string
+	NoTime             bool
+	DoubleDash         bool `docopt:"--"`
+	Each               string
+	SkipIfPresent      bool
+	Create             bool
+	Doctor             bool
+	Exists             bool
+	Path               bool
+	CatCmd             bool `docopt:"cat"`
+	ReadOnly           bool
+	Info               bool
+	Encoding           bool
+	Links              bool
+	Trim               bool
+	Over               string
+	Days               string
+	Restore            bool
+	RestoreCmd         bool `docopt:"restore"`
+	Backup             string
+	Week               bool
+	Month              bool
+	Cat                bool
+	OnThisDay          bool `docopt:"onthisday"`
+	Full               bool
+	FromEncoding       string
+	Dir                []string `docopt:"<dir>"`
+	Source             string   `docopt:"<source>"`
+	DateFrom           string   `docopt:"--date-from"`
+	Scratch            bool
+	Name               string
+	Note               bool   `docopt:"note"`
+	NoteKind           string `docopt:"<kind>"`
+	NoteArg            string `docopt:"<arg>"`
+	AllNotes           bool
+	List               bool `docopt:"list,--list"`
+	EntriesOnly        bool
+	NoBoilerplate      bool
+	Coverage           bool
+	Explain            bool
+	Any                bool
+	Topic              string `docopt:"--topic"`
+	LastCmd            bool   `docopt:"last"`
+	History            bool
+	AllProfiles        bool
+	Redo               bool
+	Edit               bool
+	Count              string `docopt:"<count>"`
+	IncludeFuture      bool
+	Due                bool
+	Within             string
+	Todo               bool
+	Meta               bool
+	IncludeDone        bool
+	Stale              string
+	Notify             bool
+	Sync               bool
+	Unlock             bool
+	DueWithin          string `docopt:"--due-within"`
+	ShowMtime          bool
+	DatesOnly          bool
+	Completion         bool
+	Shell              string `docopt:"<shell>"`
+	PrintPath          bool
+	NoEdit             bool
+	NoCreate           bool
+	Strict             bool
+	FailIfCreated      bool
+	FailIfEmpty        bool
+	ExplainDate        bool
 }
 
-func parseDateString(inDate string) (*DatePath, error) {
+// dateFormats are the layouts tried, in order, for dates that aren't keywords.
+// Two-digit years are 1969 to 2068.
+var dateFormats = []string{
+	"2006-01-02",
+	"20060102",
+	"1/2/2006",
+	"1-2-2006",
+	"1/2/06",
+	"1-2-06",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+	"Jan 2 06",
+	"2 Jan 2006",
+	"2 Jan, 2006",
+	"2 Jan 06",
+	"2/1/2006",
+	"2-1-2006",
+	"2/1/06",
+	"2-1-06",
+	"2-Jan-2006",
+	"2-Jan-06",
+	"January 2 2006",
+	"January 2, 2006",
+	"2 January 2006",
+	"2 January, 2006",
+}
+
+func parseDateString(cfg Configuration, inDate string) (*DatePath, error) {
+	pd, _, err := parseDate(cfg, inDate)
+	return pd, err
+}
+
+// dateMatch is how parseDate read a date: Rule describes the keyword, phrase,
+// or layout that matched, and Other is the date the input would have been
+// read as with day and month swapped, for the numeric layouts where both
+// readings are valid.
+type dateMatch struct {
+	Input string
+	Rule  string
+	Other *DatePath
+}
+
+// swappableLayouts are the numeric layouts that read day and month in either
+// order, mapped to the order they read, so "3/7/2024" is the 7th of March
+// or the 3rd of July depending on which is tried.
+var swappableLayouts = map[string]string{
+	"1/2/2006": "mdy",
+	"1-2-2006": "mdy",
+	"1/2/06":   "mdy",
+	"1-2-06":   "mdy",
+	"2/1/2006": "dmy",
+	"2-1-2006": "dmy",
+	"2/1/06":   "dmy",
+	"2-1-06":   "dmy",
+}
+
+// dateOrder returns the date_order setting, "mdy" or "dmy", that decides
+// how a numeric date reading as a valid date either way is meant.  Unset,
+// such a date is refused rather than guessed.
+func dateOrder(cfg Configuration) (string, error) {
+	order := strings.ToLower(strings.TrimSpace(cfg.DateOrder))
+	switch order {
+	case "", "mdy", "dmy":
+		return order, nil
+	}
+	return "", fmt.Errorf("unknown date_order '%s', expected mdy or dmy", order)
+}
+
+// parseDate is parseDateString, also reporting how the date was read.
+func parseDate(cfg Configuration, inDate string) (*DatePath, dateMatch, error) {
 	inDate = strings.ToLower(inDate)
 	inDate = strings.TrimSpace(inDate)
+	m := dateMatch{Input: inDate}
 
-	if inDate == "today" || len(inDate) == 0 {
-		return &DatePath{
-			year:  time.Now().Year(),
-			month: int(time.Now().Month()),
-			day:   time.Now().Day()}, nil
-	} else if inDate == "yesterday" {
-		yest := time.Now().Add(-1 * time.Hour * 24)
-		return &DatePath{
-			year:  yest.Year(),
-			month: int(yest.Month()),
-			day:   yest.Day(),
-		}, nil
-	} else if inDate == "tomorrow" {
-		tom := time.Now().Add(time.Hour * 24)
-		return &DatePath{
-			year:  tom.Year(),
-			month: int(tom.Month()),
-			day:   tom.Day(),
-		}, nil
-	}
-
-	dateFormats := []string{
-		"1/2/2006",
-		"1-2-2006",
-		"Jan 2 2006",
-		"Jan 2, 2006",
-		"2 Jan 2006",
-		"2 Jan, 2006",
-		"2/1/2006",
-		"2-1-2006",
-		"2-Jan-2006",
-		"January 2 2006",
-		"January 2, 2006",
-		"2 January 2006",
-		"2 January, 2006",
+	if len(inDate) == 0 {
+		inDate = "today"
+	}
+	t, rule, ok, err := matchKeyword(inDate, dayNow())
+	if ok {
+		if err != nil {
+			return nil, m, err
+		}
+		m.Rule = rule
+		dp := datePathFromTime(t)
+		return &dp, m, nil
 	}
 
+	inDate = englishMonths(inDate)
 	for _, df := range dateFormats {
 		pd, err := time.Parse(df, inDate)
 		if err != nil {
 			continue
 		}
-		return &DatePath{
+		m.Rule = fmt.Sprintf("the layout %s", df)
+		dp := &DatePath{
 			year:  pd.Year(),
 			month: int(pd.Month()),
 			day:   pd.Day(),
-		}, nil
+		}
+		if layoutOrder := swappableLayouts[df]; len(layoutOrder) > 0 && dp.day <= 12 && dp.day != dp.month {
+			other := &DatePath{year: dp.year, month: dp.day, day: dp.month}
+			order, err := dateOrder(cfg)
+			if err != nil {
+				return nil, m, err
+			}
+			if len(order) == 0 {
+				return nil, m, fmt.Errorf("'%s' could be %s or %s; write it as YYYY-MM-DD, or set date_order = \"mdy\" or \"dmy\"", inDate, dp.Iso(), other.Iso())
+			}
+			if layoutOrder != order {
+				// the layout of the other order comes later in the list
+				continue
+			}
+			m.Other = other
+		}
+		return dp, m, nil
 	}
 
-	return nil, fmt.Errorf("unable to parse '%s'", inDate)
+	if isMonthString(inDate) {
+		return nil, m, fmt.Errorf("'%s' is a month, not a day; give a day of it, or read the month with \"wm month %s\"", m.Input, m.Input)
+	}
+	return nil, m, fmt.Errorf("unable to parse '%s'; matched no keyword, phrase, or any of %d layouts (see \"wm help dates\")", inDate, len(dateFormats))
 
 }
 
+// String returns the path of the entry for ds in the nested layout, as
+// "/2024/3/7.txt"; entryPath knows the configured one.
 func (ds *DatePath) String() string {
-	return fmt.Sprintf("/%d/%d/%d.txt", ds.year, ds.month, ds.day)
+	return "/" + nestedLayout.render(ds)
 }
 
 type Configuration struct {
-	Root        string
-	Editor      string
+	Root string
+	// ExtraRoots are further trees of entries, such as archived years, that
+	// search, list, stats, and export read too; see extraroots.go.
+	ExtraRoots []string `toml:"extra_roots"`
+	// Editor is the command line that opens entries, such as "code --wait";
+	// unset, $VISUAL, $EDITOR, or the platform's editor is used.
+	Editor string
+	// EditorWait runs the editor on the terminal and waits for it to exit;
+	// see editorWait for the default.
+	EditorWait *bool `toml:"editor_wait"`
+	// Detach starts the editor and leaves it, for GUI editors that return
+	// at once; see editorWait.
+	Detach bool `toml:"detach"`
+	// ClipboardCommand is the command line clip reads the clipboard with,
+	// instead of the platform's; see pasteCommands.
+	ClipboardCommand string `toml:"clipboard_command"`
+	// ReadonlyArgs are the editor arguments that open a file read-only, such
+	// as "-R" for vim, for --read-only.
+	ReadonlyArgs string `toml:"readonly_args"`
+	// ContextSize is the deprecated byte count of context around search
+	// matches, used only when ContextLines isn't set.
 	ContextSize int
+	// ContextLines is how many lines search shows around a match, 2 by
+	// default.
+	ContextLines *int `toml:"context_lines"`
+	Lint         LintConfig
+	TimeFormat   string `toml:"time_format"`
+	UsageStats   bool   `toml:"usage_stats"`
+	// CommandHistory records invocations for "history" and "redo"; it is on
+	// unless set to false.
+	CommandHistory *bool `toml:"command_history"`
+	// DateKeywords defines custom date keywords in terms of the built-in
+	// ones, e.g. payday = "eom-2".
+	DateKeywords map[string]string `toml:"date_keywords"`
+	DateLocale   string            `toml:"date_locale"`
+	// DateOrder, "mdy" or "dmy", is how numeric dates such as 3/7/2024 are
+	// read when both readings are valid dates.
+	DateOrder string `toml:"date_order"`
+	// GapWarningDays warns when today's entry is opened more than this many
+	// days after the previous one; 0 only says when that was.
+	GapWarningDays int `toml:"gap_warning_days"`
+	// MonthFilename names the notes file of each month; see periodnotes.go.
+	MonthFilename string `toml:"month_filename"`
+	// Backups keeps this many copies of an entry in the versions store,
+	// taken before the editor is started on it; see backupBeforeEdit.
+	Backups int `toml:"backups"`
+	// CarryForward starts today's new entry with the open todos of the
+	// previous one; see carriedOver.
+	CarryForward bool `toml:"carry_forward"`
+	// SessionMarkers appends a "--- HH:MM ---" line when today's entry is
+	// opened after a break of at least SessionGap (default 60m).
+	SessionMarkers bool   `toml:"session_markers"`
+	SessionGap     string `toml:"session_gap"`
+	// DefaultCommand is what "wm" given no arguments runs: open, the
+	// default, list, last, or search; see defaultCommandArgs.
+	DefaultCommand string `toml:"default_command"`
+	// DailyWordGoal is how many words an entry should have; list, stats,
+	// and the end of an edit show how entries measure up.  0 is no goal.
+	DailyWordGoal int `toml:"daily_word_goal"`
+	// TimedSections puts what append adds to today's entry under a heading
+	// for the part of the day, such as "## Morning"; Sections gives the
+	// parts by the hour each starts at.  See timedsection.go.
+	TimedSections bool           `toml:"timed_sections"`
+	Sections      map[string]int `toml:"sections"`
+	// RedactTag marks entries that export --redact-tag leaves out.
+	RedactTag string `toml:"redact_tag"`
+	// ReviewStopwords are words review leaves out of the top terms, on top
+	// of its own list of common English words.
+	ReviewStopwords []string `toml:"review_stopwords"`
+	// PathLayout is "nested", "flat", or a Go time layout for entry paths
+	// such as "2006-01-02.md".
+	PathLayout string `toml:"path_layout"`
+	// Normalize rewrites an entry once the editor exits with LineEnding
+	// line endings and without trailing whitespace; see normalize.go.
+	Normalize          bool   `toml:"normalize"`
+	LineEnding         string `toml:"line_ending"`
+	PreserveCodeBlocks bool   `toml:"preserve_code_blocks"`
+	// OpenRangeLimit is the most days opening a range such as 3/1..3/5
+	// takes without --force; unset, 31.
+	OpenRangeLimit int `toml:"open_range_limit"`
+	// ArchiveDir is where archive moves old entries to; unset, the root's
+	// path with -archive appended.
+	ArchiveDir string `toml:"archive_dir"`
+	// Trash moves the entries move merges into others to .wm-trash rather
+	// than removing them.
+	Trash bool `toml:"trash"`
+	// ConflictPatterns match the names of the copies sync clients leave of
+	// an entry changed in two places; see conflicts.go.
+	ConflictPatterns []string `toml:"conflict_patterns"`
+	// Pager is the command line long output goes through on a terminal;
+	// unset, $PAGER, or "off"; see pager.go.
+	Pager string `toml:"pager"`
+	// ImportFilenameRegex finds the date in the path of a file import
+	// brings in, with year, month, and day groups.
+	ImportFilenameRegex string `toml:"import_filename_regex"`
+	// PathFormat is another name for PathLayout.
+	PathFormat string `toml:"path_format"`
+	// Extension is the extension of new entries, such as "md"; unset, the
+	// one path_layout gives, .txt by default.
+	Extension string `toml:"extension"`
+	// LegacyExtensions are the other extensions entries are read with, so
+	// that a tree can keep its .txt entries after extension changes; unset,
+	// ["txt"].
+	LegacyExtensions []string `toml:"legacy_extensions"`
+	// Hooks are commands run before an entry is opened and after it is
+	// saved, each killed after HookTimeoutSeconds; unset, 30.  See hooks.go.
+	Hooks              HooksConfig `toml:"hooks"`
+	HookTimeoutSeconds int         `toml:"hook_timeout_seconds"`
+	// Profiles names the configuration files of other profiles for search
+	// --all-profiles.
+	Profiles map[string]string `toml:"profiles"`
+	// Notebooks are logs kept in this configuration by name, selected with
+	// --notebook or DefaultNotebook; see notebooks.go.
+	Notebooks       map[string]notebook `toml:"notebooks"`
+	DefaultNotebook string              `toml:"default_notebook"`
+	// Notes are the kinds of notes "note" opens, by name; see notes.go.
+	Notes map[string]noteKind `toml:"notes"`
+	// DirMode and FileMode are the permissions of the directories and files
+	// created for entries, 0o755 and 0o644 by default.
+	DirMode  permSetting `toml:"dir_mode"`
+	FileMode permSetting `toml:"file_mode"`
+	// Redact holds the patterns every redaction applies.
+	Redact RedactConfig `toml:"redact"`
+	// Index keeps a search index in root/.wm-index.
+	Index bool `toml:"index"`
+	// SearchWorkers is how many files search reads at once, by default the
+	// number of CPUs up to 8.
+	SearchWorkers int `toml:"search_workers"`
+	// SearchFoldDiacritics makes search match regardless of accents, as
+	// --fold-diacritics does; see fold.go.
+	SearchFoldDiacritics bool `toml:"search_fold_diacritics"`
+	// Encrypt keeps entries encrypted with the passphrase PassphraseCommand
+	// prints; see crypt.go.
+	Encrypt           bool   `toml:"encrypt"`
+	PassphraseCommand string `toml:"passphrase_command"`
+	// GitAutocommit commits an entry after it is edited, when the root is
+	// in a git work tree.
+	GitAutocommit bool `toml:"git_autocommit"`
+	// LockEntries locks an entry while it is open in the editor, against
+	// another wm on the same synced root; locks older than LockTimeout
+	// (default 8h) are stolen.
+	LockEntries bool   `toml:"lock_entries"`
+	LockTimeout string `toml:"lock_timeout"`
+	// WeekStart is the day "week" starts weeks on, Monday by default.
+	WeekStart string `toml:"week_start"`
+	// Trim tunes how "trim" recognizes pasted output.
+	Trim TrimConfig `toml:"trim"`
+	// EditorLineArg tells wm how to open the editor at a line, e.g. "+{line}".
+	EditorLineArg string `toml:"editor_line_arg"`
+	// EditorGotoFormat is another name for EditorLineArg.
+	EditorGotoFormat string `toml:"editor_goto_format"`
+	// DayStartHour and Timezone decide which day "today" is; see day.go.
+	DayStartHour int    `toml:"day_start_hour"`
+	Timezone     string `toml:"timezone"`
+	// DayRolloverHour is another name for DayStartHour.
+	DayRolloverHour int `toml:"day_rollover_hour"`
+	// Template is the default template for new entries and Templates
+	// overrides it per weekday, e.g. monday = "templates/monday.md", or per
+	// date range, e.g. "2024-01-01..2024-03-31" = "templates/q1.md".
+	Template  string
+	Templates map[string]string
+	// AttachmentTypes and AttachmentMaxSize limit which attachments search
+	// --include-attachments reads.
+	AttachmentTypes   []string `toml:"attachment_types"`
+	AttachmentMaxSize int64    `toml:"attachment_max_size"`
+	// ConfirmDistance is how far from today a new entry may be dated before
+	// wm asks first; it defaults to 365d.
+	ConfirmDistance string `toml:"confirm_distance"`
+	// ConfirmParsedDate asks before opening a date read by a numeric layout
+	// that could also have meant day and month the other way around.
+	ConfirmParsedDate bool `toml:"confirm_parsed_date"`
+	// Holidays lists extra days off as "YYYY-MM-DD Name", on top of those
+	// in HolidaysFile (holidays.txt by default); see holidays.go.
+	Holidays     []string
+	HolidaysFile string `toml:"holidays_file"`
+	// Output is "plain" for screen-reader friendly output; see output.go.
+	Output string
+	// StrictCreate makes wm create entries only when asked to with --create
+	// or by a command such as fill; see creationAllowed.
+	StrictCreate bool `toml:"strict_create"`
+	// NearestEntryDays is how many days either side of a past date without
+	// an entry are searched for one to open instead; unset, 3, and 0 turns
+	// it off.
+	NearestEntryDays *int `toml:"nearest_entry_days"`
+	// FollowInterval is how often search --follow polls, e.g. "500ms".
+	FollowInterval string `toml:"follow_interval"`
+	// Decisions configures how "decisions" finds decision lines.
+	Decisions ExtractConfig
+	// Summary configures how "summary" finds the important lines.
+	Summary ExtractConfig
+	// Due configures how "due" finds @due() annotations.
+	Due DueConfig
+	// SearchSkipBoilerplate makes search behave as with --no-boilerplate.
+	SearchSkipBoilerplate bool `toml:"search_skip_boilerplate"`
+
+	// file is the configuration file, and dir its directory, which relative
+	// paths in it are resolved against.
+	file string
+	dir  string
 }
 
-func GetConfig(cfgFile string) Configuration {
+// defaultConfig is written to a configuration file that doesn't exist yet.
+const defaultConfig = `root = "~/.wm/logs"
+context_lines = 2
+`
+
+// errInvalidConfig marks configurations that were read but whose settings
+// don't make sense, as opposed to ones that couldn't be read at all.
+var errInvalidConfig = errors.New("invalid configuration")
+
+// GetConfig reads the configuration file, creating it with defaults first if
+// it doesn't exist.
+func GetConfig(cfgFile string) (Configuration, error) {
 	if _, err := os.Stat(cfgFile); err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			f, err := os.Create(cfgFile)
-			if err != nil {
-				log.Fatalln("config file not found at '", cfgFile, "' and failed to create.")
-			}
-			_, err = f.WriteString(`root = "~/.wm/logs"
-editor = "notepad"
-context_size = 200`)
-			if err != nil {
-				log.Fatalln("config file not found at '", cfgFile, "'. Created, but failed to write defaults.")
-			}
-			err = f.Close()
-			if err != nil {
-				log.Fatalln("failed to close file with error ", err)
-			}
-		} else {
-			log.Fatalln("failed to verify configuration file exists:", err)
+		if !errors.Is(err, os.ErrNotExist) {
+			return Configuration{}, fmt.Errorf("failed to verify configuration file exists: %w", err)
 		}
+		if err := os.MkdirAll(filepath.Dir(cfgFile), 0o755); err != nil {
+			return Configuration{}, fmt.Errorf("failed to create the directory for %s: %w", cfgFile, err)
+		}
+		if err := os.WriteFile(cfgFile, []byte(defaultConfig), 0o644); err != nil {
+			return Configuration{}, fmt.Errorf("config file not found at '%s' and failed to create it: %w", cfgFile, err)
+		}
+	}
+	return readConfig(cfgFile)
+}
+
+// PeekConfig reads the configuration file if it exists and otherwise returns
+// an empty configuration without creating anything.  It is used by commands
+// that don't touch the log root, so they never pay for creating a config.
+func PeekConfig(cfgFile string) (Configuration, error) {
+	if _, err := os.Stat(cfgFile); err != nil {
+		return Configuration{}, nil
 	}
+	return readConfig(cfgFile)
+}
 
+// readConfig decodes the configuration file and applies the settings that
+// configure the process as a whole.  Keys that match no setting are noted so
+// that typos don't go unnoticed.  When every setting could be applied but
+// some are invalid, the configuration is returned along with an error
+// wrapping errInvalidConfig.
+func readConfig(cfgFile string) (Configuration, error) {
+	var cfg Configuration
 	cfgData, err := os.ReadFile(cfgFile)
 	if err != nil {
-		log.Fatalln("error reading config file:", err)
+		return cfg, fmt.Errorf("error reading config file: %w", err)
 	}
-	var cfg Configuration
-	_, err = toml.Decode(string(cfgData), &cfg)
+	md, err := toml.Decode(string(cfgData), &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("error decoding configuration file %s: %w", cfgFile, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, k := range undecoded {
+			keys[i] = fmt.Sprintf("%q", k.String())
+		}
+		log.Printf(":::note::: unknown keys in %s, ignored: %s", cfgFile, strings.Join(keys, ", "))
+	}
+	err = selectNotebook(&cfg, notebookName)
+	if err != nil {
+		return cfg, fmt.Errorf("error in configuration file: %w", err)
+	}
+	err = setDateLocale(cfg.DateLocale)
 	if err != nil {
-		log.Fatalln("error decoding configuration file:", err)
+		return cfg, fmt.Errorf("error in configuration file: %w", err)
 	}
-	return cfg
+	_, err = dateOrder(cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("error in configuration file: %w", err)
+	}
+	cfg.file, cfg.dir = cfgFile, filepath.Dir(cfgFile)
+	err = setDayBoundary(dayStartSetting(cfg), cfg.Timezone)
+	if err != nil {
+		return cfg, fmt.Errorf("error in configuration file: %w", err)
+	}
+	err = setMonthFilename(cfg.MonthFilename)
+	if err != nil {
+		return cfg, fmt.Errorf("error in configuration file: %w", err)
+	}
+	_, _, err = configuredLayout(cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("error in configuration file: %w", err)
+	}
+	err = registerCustomKeywords(cfg.DateKeywords)
+	if err != nil {
+		return cfg, fmt.Errorf("error in [date_keywords]: %w", err)
+	}
+	_, err = templateRanges(cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("error in [templates]: %w", err)
+	}
+	err = loadHolidays(cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("error reading holidays: %w", err)
+	}
+	return cfg, validateConfig(cfg)
 }
 
-func main() {
-	usage := `WM.  A working-memory log system.
+// validateConfig checks the settings every command relies on, reporting all
+// that are wrong at once.
+func validateConfig(cfg Configuration) error {
+	var problems []string
+	if len(strings.TrimSpace(cfg.Root)) == 0 {
+		problems = append(problems, `config: "root" must be set to the directory entries are kept in`)
+	}
+	if _, err := splitCommandLine(cfg.Editor); err != nil {
+		problems = append(problems, fmt.Sprintf(`config: "editor" can't be read as a command line: %v`, err))
+	}
+	if _, err := splitCommandLine(cfg.ClipboardCommand); err != nil {
+		problems = append(problems, fmt.Sprintf(`config: "clipboard_command" can't be read as a command line: %v`, err))
+	}
+	if _, err := splitCommandLine(cfg.ReadonlyArgs); err != nil {
+		problems = append(problems, fmt.Sprintf(`config: "readonly_args" can't be read as arguments: %v`, err))
+	}
+	if cfg.ContextSize < 0 {
+		problems = append(problems, fmt.Sprintf(`config: "contextSize" must be >= 0, got %d`, cfg.ContextSize))
+	}
+	if cfg.ContextLines != nil && *cfg.ContextLines < 0 {
+		problems = append(problems, fmt.Sprintf(`config: "context_lines" must be >= 0, got %d`, *cfg.ContextLines))
+	}
+	if _, err := weekStart(cfg); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if cfg.GapWarningDays < 0 {
+		problems = append(problems, fmt.Sprintf(`config: "gap_warning_days" must be >= 0, got %d`, cfg.GapWarningDays))
+	}
+	if cfg.Backups < 0 {
+		problems = append(problems, fmt.Sprintf(`config: "backups" must be >= 0, got %d`, cfg.Backups))
+	}
+	if err := checkDefaultCommand(cfg); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if cfg.DailyWordGoal < 0 {
+		problems = append(problems, fmt.Sprintf(`config: "daily_word_goal" must be >= 0, got %d`, cfg.DailyWordGoal))
+	}
+	if _, err := timedSections(cfg); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := normalizeOptionsFor(cfg); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if cfg.DayStartHour != 0 && cfg.DayRolloverHour != 0 && cfg.DayStartHour != cfg.DayRolloverHour {
+		problems = append(problems, fmt.Sprintf(`config: "day_start_hour" %d and "day_rollover_hour" %d are the same setting; keep one`, cfg.DayStartHour, cfg.DayRolloverHour))
+	}
+	if cfg.NearestEntryDays != nil && *cfg.NearestEntryDays < 0 {
+		problems = append(problems, fmt.Sprintf(`config: "nearest_entry_days" must be >= 0, got %d`, *cfg.NearestEntryDays))
+	}
+	if cfg.OpenRangeLimit < 0 {
+		problems = append(problems, fmt.Sprintf(`config: "open_range_limit" must be >= 0, got %d`, cfg.OpenRangeLimit))
+	}
+	if cfg.SearchWorkers < 0 {
+		problems = append(problems, fmt.Sprintf(`config: "search_workers" must be >= 0, got %d`, cfg.SearchWorkers))
+	}
+	if _, err := lockTimeout(cfg); err != nil {
+		problems = append(problems, fmt.Sprintf(`config: "lock_timeout" %v`, err))
+	}
+	if cfg.Encrypt && len(strings.TrimSpace(cfg.PassphraseCommand)) == 0 {
+		problems = append(problems, `config: "encrypt" needs "passphrase_command" to be set`)
+	}
+	if cfg.Encrypt && cfg.Index {
+		problems = append(problems, `config: "index" keeps what entries say in plain text and can't be used with "encrypt"`)
+	}
+	if _, err := conflictPatterns(cfg); err != nil {
+		problems = append(problems, "config: "+err.Error())
+	}
+	if _, err := pagerArgs(cfg); err != nil {
+		problems = append(problems, err.Error())
+	}
+	problems = append(problems, hookProblems(cfg)...)
+	problems = append(problems, noteKindProblems(cfg)...)
+	problems = append(problems, patternProblems(cfg)...)
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", errInvalidConfig, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// exitHook runs as Main returns, with the status the process is to exit
+// with.
+var exitHook = func(status int) {}
+
+// usage is the usage text docopt parses the command line with, which is also
+// the grammar shell completion is generated from; see completion.go.
+const usage = `WM.  A working-memory log system.
 
 WM will open the log file for the day provided.  If none is provided, the
-current date is assumed.  If the file for the provided date already exists, it
-is opened; if not, it is created first.  The program that is used to open the
-log is defined in the configuration file.  By invoking the 'config' command,
-the configuration file is opened for editing.
+current date is assumed.  If the file for the provided date already exists,
+it is opened; if not, it is created first.  The program that is used to open
+the log is defined in the configuration file.  By invoking the 'config'
+command, the configuration file is opened for editing.
 
 Configuration is done using a TOML file with the following recognized keys.
 	root	A string representing the complete path to the root folder for
@@ -145,150 +752,1849 @@ Configuration is done using a TOML file with the following recognized keys.
 	editor	A string for the file path of the program to edit working
 		memory logs.
 
-The configuration file is stored next to the executable file itself by default
-but can be changed by providing a WMCFG environment variable.
+The configuration file is $XDG_CONFIG_HOME/wm/config.toml when that is set,
+and otherwise ~/.config/wm/config.toml, or %APPDATA%\wm\config.toml on
+Windows, created with its directory on first use; a wm/wm.toml that older
+versions kept in the platform's configuration directory is still read while
+there is no config.toml.  The WMCFG environment variable names another file
+instead.  Otherwise, a .wm.toml in the current directory or any directory
+above it is used instead, such as one kept in a team repository, with a
+relative root taken relative to that file, and root = "." meaning the
+directory it is in; --no-local turns this off.  Pass --relative to have
+paths printed relative to that directory, with forward slashes, as
+--print-path, info, search, and index print them, so that output committed
+to the repository doesn't name anyone's home directory.  "config --show"
+tells which file is in use and why, "config --path" only prints its path,
+and "config set <key> <value>" changes one setting, such as
+"lint.max_line_length 100", in place, keeping comments, formatting, and
+other keys untouched and the previous file as <file>.bak.
+
+Older versions read wm.toml from the directory wm was run in, which is still
+used while there is no user configuration, with a note saying so; after it,
+a wm.toml next to the executable is, for portable installs.  "config
+migrate" looks for wm.toml in the current, home, and executable directories,
+or the ones given, and moves the one picked to the user configuration, or
+merges them all with the newest file winning each conflicting key.  Each old
+file is kept as wm.toml.bak and replaced by a stub naming the new location,
+which wm follows with a warning so that scripts setting WMCFG keep working.
+
+"config schema" prints a JSON Schema of the configuration file, with the
+type, default, allowed values, and a description of every key, for editors
+and other tools to validate wm.toml with.  "config --check" checks the file
+against it first, reporting unknown keys and values of the wrong type, and
+then the way wm reads it, exiting with 1 when anything is wrong.  Every
+regular expression the file sets, such as due.pattern or the redact
+patterns, is compiled whenever it is read, and one that doesn't compile is
+reported under its key.  --lint-patterns also warns about patterns that
+match the empty string and so every line, and about nested repetitions such
+as (a+)+, which cost wm nothing but can hang a backtracking engine such as
+grep -P if the pattern is reused there; warnings alone don't fail the check.
+
+Use "list" to see which days have entries, newest first, with their size and
+first line; it shows the last 30 days unless given a range, such as "list
+2024-03-01 2024-03-31" or "list --in=march".  --show-mtime adds when each
+was last edited.  A day's main entry comes before its topics.  --limit pages
+through a long listing: only that many entries are read, followed by the
+token that --page-token takes to carry on after them.  The token names the
+last entry shown, not a position, so entries added or removed in between
+don't skip or repeat any.  --format=json prints a page as an object with
+the entries and next_token, "" on the last page.  --dates-only prints
+nothing but the dates, once each, for scripts.
+
+Use "doctor" to check the setup: that the configuration file is there and
+reads, its keys are valid, the root exists or can be created and is
+writable, the editor is on PATH, "~" was expanded, dates parse, entries are
+found, and templates can be read.  It prints a PASS, WARN, or FAIL line for
+each check, with a hint on what to do under the ones that didn't pass, and
+exits 1 when any failed.  It also warns when the root is inside a Dropbox,
+OneDrive, or similar synced folder.  There, a file the sync client
+holds open is read or written again once after a moment, and on Windows
+files that are online-only are left out of search and list, which say how
+many they skipped.
+
+Use "conflicts" to find the copies a sync client left of entries changed in
+two places, such as 7.sync-conflict-20240307-101500-ABCDEF1.txt from
+Syncthing or "7 (conflicted copy 2024-03-07).txt" from Dropbox or
+Nextcloud: each is listed with the entry it is a copy of, both sizes, and a
+unified diff between them.  "conflicts --merge" appends the lines only the
+copy has to the entry, under a "## Merged from conflict copy" heading after
+backing the entry up, and moves the copy to .wm-trash under the root.  It
+exits 1 while copies are left.  Other commands leave copies out rather than
+reading them as entries, and "doctor" counts them in one warning.
+conflict_patterns replaces the regular expressions copies are recognized by,
+which match the file name and whose groups put together are the entry's.
+
+Use "move <from> <to>" to move an entry written on the wrong day, such as
+"move today yesterday" after midnight.  When <to> has no entry the file is
+renamed and its header rewritten to the new date; otherwise its text is
+appended to the entry of <to> under a "## Moved from <from>" heading, and it
+is removed, backed up to the versions store first, or moved to .wm-trash
+with trash = true.  --dry-run prints what would be done.
+
+Use "archive --before=<date>" to move the entries dated before that day out
+of the root into archive_dir, by default the root's path with -archive
+appended, keeping their paths under the root; --compress writes them into a
+.tar.gz per year there instead, such as 2019.tar.gz, adding to one that
+exists.  Each entry is copied, read back, and compared before it is removed,
+so an interrupted run never loses one, and what was moved is listed with the
+bytes taken off the root.  "archive --list" shows the archives with their
+entry counts and dates.  search --include-archives also searches them,
+reading the .tar.gz files without extracting them.  Opening the date of an
+archived entry opens it where it was moved to; one in a .tar.gz is refused
+instead of starting an empty entry under the root, unless --create is
+given.
+
+Provide "search" space separated terms to search the working memory database
+for. A table of results that includes all hits will be provided ordered by
+date.  At least one term is needed unless --tag is given, which alone
+searches for the tag; without either, search exits 2.  Terms are regular
+expressions, matched regardless of case unless --case-sensitive is given or
+a term sets its own with a flag such as "(?-i)". -F matches terms as literal
+text instead, so "c++" finds itself, and -w only as whole words, so "go"
+doesn't find "going" or "cargo"; with both, a word boundary is only required
+at ends of a term that are letters, digits, or underscores.  -S, smart case,
+ignores case only for terms written without capitals, so "todo" finds "TODO"
+but "TODO" only finds itself. --fold-diacritics, or search_fold_diacritics =
+true, matches regardless of accents, so "reunion" finds "reunión" and
+"reunión" finds "reunion", and terms matched regardless of case then fold it
+the Unicode way, so "strasse" finds "Straße".  Hits still give the lines,
+columns, and text of the entry as it is written.  The search index isn't
+used with it. An entry is reported when every term matches somewhere in it,
+or with --any when one of them does; --follow prints new lines matching any
+term. Each match is shown with context_lines lines around it (default 2),
+the lines of the match marked with ">". Entries over 256 KiB, such as days
+with log output pasted in, are read a line at a time instead of whole, so
+there a match can't run across lines and "^" and "$" match at the start and
+end of every line. The --format option selects "json", "csv", or "grep"
+output for editor integrations and scripts, --json and --csv being short for
+the first two; each hit then carries the term as it was given, its 1-based
+line and rune column, the byte offset of the match in the file, and the
+match length in runes, and JSON hits also say how the term was matched:
+case_sensitive, whole_word, fold_diacritics, and fixed_strings.  With -l
+only the paths of matching entries are printed, NUL-separated with -0 for
+use with "xargs -0"; nothing else is written to standard output in that
+mode.  -c prints how many times each matching entry matches instead, as
+"2024-03-07: 3", in date order. --line prints each hit as "2024-03-07:42:
+the line", the line as written, a line over 500 characters cut to the part
+around the match. Neither reads a file further than it needs to, and neither
+goes with the options that shape context output. Search exits as grep does:
+0 when anything matched, 1 when nothing did, and 2 on errors such as a bad
+pattern or a root that can't be read.  It exits 3 when the root has no
+entries at all, naming the root as expanded and the paths entries are looked
+for at, as that is more often a root set to the wrong directory than a
+search that found nothing.  Files that can't be read are noted and left out,
+which only makes an error when no other file matched.  -q prints no results
+at all and stops at the first match, for shell conditionals such as "if wm
+search -q 'oncall handoff'; then".
+
+With --follow, search keeps running after printing the current matches and
+prints new ones as entries in the range change, with the entry's date and
+the time they were seen, until Ctrl-C.  Files are polled every
+follow_interval (default 2s) and a line is only searched once it has been
+written completely.
+
+With --open, search opens the editor at the first hit instead of printing
+the hits, using editor_line_arg as opening a date with --at does.  That
+takes exactly one file with hits, so a search that narrows to it by term,
+date, or --topic goes straight there.  When several files have hits they
+are listed and search exits 1, unless --first opens the newest of them.
+
+With --no-boilerplate, or search_skip_boilerplate = true in the
+configuration, search ignores matches in an entry's generated header and in
+lines still exactly as the entry's template wrote them, and ends with how
+many matches it showed and how many it hid.
+
+With normalize = true, an entry is tidied once the editor exits: every line
+ends in line_ending, "lf" (the default), "crlf", or "native" for the
+platform's, trailing spaces and tabs are stripped, trailing blank lines are
+dropped, and the entry ends in exactly one line ending.  The entry is only
+rewritten when that changes it.  With preserve_code_blocks = true, lines
+inside fenced code blocks keep their trailing whitespace.  Note that this
+also strips the two trailing spaces of a Markdown hard line break.  As the
+entry is tidied after the edit, wm always waits for the editor while
+normalize is on.
+
+Give a range, such as "wm 3/1/2024..3/5/2024", to open the entries of every
+day in it in one run of the editor, as tabs or buffers.  Entries missing
+from the range are created from their templates first, unless
+--existing-only opens only the ones there are.  The ends may be given either
+way round, and a range longer than open_range_limit (default 31 days) needs
+--force, so that a mistyped year doesn't create months of empty entries.
+
+Use "last" to open the most recent entry that exists, or "last 2" for the
+one before it, instead of creating an empty one for yesterday.  It exits 5
+when there is no such entry.
+
+Use --topic to keep a thread per project within a day: "wm --topic zephyr"
+opens 2024/3/7.zephyr.txt next to the day's entry, creating it like any
+entry.  Topics are lowercase letters, digits, and dashes, with a letter.
+"list --topic" and "search --topic" only look at that topic's entries;
+without it they include every topic, labeled, and so does export.
+
+With index = true, search keeps an index of the trigrams of every file in
+root/.wm-index and only reads the files that can match terms with a literal
+part of three or more characters, such as "standup" or "deploy.*failed".
+Changed files are indexed again as they are searched; "index" brings the
+whole index up to date and "index --rebuild" starts it afresh.
+
+Search reads several files at once, as many as there are CPUs up to 8, and
+still prints the results in date order; files it couldn't read are listed
+after them.  Set search_workers to read more or fewer at once, or 1 to read
+one after the other, as on a spinning disk.
+
+With git_autocommit = true and the root in a git work tree, opening a date
+waits for the editor to exit and commits the entry as "wm: 2024-03-07",
+only when it changed and leaving anything else staged alone.  A missing git
+or a repository in the middle of a merge is noted and the entry is left
+uncommitted.  "sync" commits every change under the root and then runs git
+pull --rebase and git push.
+
+With lock_entries = true, opening a date waits for the editor to exit and
+holds a lock on the entry until it does, in a file next to it naming the
+machine, process, and time, so that another wm on a root synced between
+machines refuses to open the same entry and says who has it.  append, clip,
+attach, import, and the other commands that add to an entry take the same
+lock, so that what they add isn't lost when the editor saves.  A lock older
+than lock_timeout, 8h by default, is taken to be left over and stolen with a
+note; "unlock <date>" removes one by hand.
+
+With encrypt = true, entries are written encrypted with AES-256-GCM under a
+key derived with Argon2id from the passphrase passphrase_command prints,
+such as "pass show wm", with the salt and a check of the passphrase kept in
+root/.wm-key.  Each entry is sealed together with its path under the root,
+so one moved or swapped by hand is refused rather than read as another
+day's; move, migrate, and check --fix encrypt it again where they put it.
+Search and every other command decrypt entries in memory.  Opening an entry
+decrypts it to a temporary file only you can read, waits for the editor to
+exit, so an editor that returns at once needs its wait flag, such as "code
+--wait", and encrypts the file again and shreds the copy; a wrong passphrase
+is refused before the editor starts.  Entries still in plain text are read
+as they are and encrypted once they are opened.  The search index would
+keep what entries say in plain text and can't be used with it.
+
+Use "coverage" to see what a search with the same flags reads: the entries
+in range by year, the scratch notes and attachments it adds, the directories
+it skips and how many files each holds.  "search --explain" prints the same
+before searching, with the patterns as compiled.
+
+Use "exists" and "info" to ask about one day cheaply, from scripts or a
+shell prompt; both read at most the one entry.  "exists today" exits 0 when
+the entry exists and 1 otherwise, printing nothing.  "info" reports the
+date, path, whether it exists, and its size, mtime, word count, and open
+todos; with --range it reports every day in the range.  Their --format json
+output, one object per line, is stable: fields may be added but never
+change. "path yesterday" prints the absolute path of an entry for other
+tools, as in cat $(wm path yesterday), reading the date and --topic as
+opening it does; it exits 1, still printing the path, when the entry doesn't
+exist, and only creates it, from its template, with --create.
+
+Use "lint" to check entries against the structural conventions configured in
+the [lint] table (required_sections, heading_level, max_line_length, and
+per-rule severity overrides of "error", "warning", or "off").  The range is
+a date or "<from>..<to>"; all entries are linted when it is omitted.  lint
+exits non-zero when an error-level rule fails, and --fix repairs trailing
+whitespace and todo checkbox syntax in place.
+
+Use "pick" to choose entries of the last 30 days, or as many as --days
+gives, from a fuzzy-filterable list showing each date, weekday, and a
+preview.  Type to filter by date, tags, or preview text, press Tab to select
+several entries, and Enter to open them.  Without a terminal, over ssh for
+instance, the list is numbered and the numbers chosen are read from stdin.
+
+Use "modified" to list entries edited recently, whatever date they are for.
+The window defaults to 7d and accepts forms like 2d, 1w, or 12h.  Edit times
+are shown using the time_format key, a Go time layout defaulting to
+"2006-01-02 15:04".
+
+Use "unread" to list entries added or edited since you last ran it, for
+example by someone sharing the root over a synced drive.  The last-seen mark
+is kept per root in the local state directory, never in the root itself, and
+moves forward after each listing unless --peek is given.
+
+Use "review-queue add" to queue entries for rereading, by date, range, or a
+glob such as 2023-11-* matched against dates and paths.  "review-queue"
+lists what is left oldest first, "review-queue next" opens the next entry
+and marks it reviewed once the editor exits, and "review-queue stats" shows
+progress.  The queue is kept per root in the local state directory.
+
+Setting usage_stats = true records the time, command, duration, and exit
+status of every invocation in a file in the local state directory; arguments
+and content are never recorded and nothing is ever transmitted.  "usage"
+summarizes the file and "usage --clear" deletes it.
+
+wm keeps the last 100 commands run on each root, their arguments but never
+entry content, in the local state directory.  "history" lists them, most
+recent first, "redo" (or "!!") runs the last one again through the same
+parsing, "redo 3" the third most recent, and "redo --edit" prints the
+command line to edit instead.  info, exists, path, and append are not
+recorded, nor is anything run with --now or WM_NOW set; command_history =
+false records nothing.
+
+With the default layout, which days of each month have an entry is also
+kept in the local state directory, updated whenever the root is listed, so
+that exists and fill look at one directory per month rather than one file
+per day.  A month whose directory changed since is listed again, so the
+index can go stale without ever giving a wrong answer.
+
+Opening today's entry on a terminal says when the last entry before it was
+written, as "last entry: 2024-03-01 (6 days ago)", looking back as far as
+January of last year, and with gap_warning_days = 3 warns instead when that
+is more than 3 days ago.
+
+"wm" with no arguments at all opens today's entry unless default_command
+says otherwise: "list" lists the last 30 days, "last" opens the latest
+entry, and "search" asks what to search for.  Global flags such as --plain
+and --verbose apply to it; anything else given, a date or a command, is read
+as always.
+
+Giving a month without a day, as "wm 2024-03", "wm 3/2024", or "wm march
+2024", opens that month's notes, root/2024/3/month.txt, creating them with a
+header naming the month first; a bare year such as "wm 2024" opens
+root/2024/year.txt.  month_filename names the month's file, and the year's
+is "year" with the same extension.  3/2/2024 is still a day.  Neither is
+an entry, so list, search, and other commands working on dates don't see
+them.
+
+With backups = 3, opening an entry first copies it to the .versions
+directory under the root, keeping the 3 newest copies of each entry, so a
+"select all, delete, save" in the editor loses nothing.  An entry that
+hasn't changed since its newest copy isn't copied again.  "restore <date>"
+lists the copies of that day's entry, newest first, along with those that
+trim, check --fix, and other rewrites keep, and restores the one given by
+--backup=<n> or asked for on a terminal.  The entry as it was is copied
+first, so a restore can be undone the same way.
 
-Provide "search" space separated terms to search the working memory database for.
-A table of results that includes all hits will be provided ordered by date.
+Setting session_markers = true appends a "--- 09:12 ---" line when today's
+entry is opened after a break, so sessions within a day stand apart.  A
+marker is only added when the previous one, or the last edit of an entry
+without one, is older than session_gap (default 60m).
+
+A writing habit can be given a number: daily_word_goal = 300 makes "list"
+show each entry's words, with a check mark on those that reached 300, and
+"stats" how many of the days in the range met the goal and how many days in
+a row up to today have.  Once the editor exits, "412 words today — goal met
+(goal: 300)", or how many are still to go, is printed.  Only what was
+written is counted: not the generated header, front matter, session
+markers, or lines the entry's template put there.  0, the default, turns
+all of this off.
+
+With timed_sections = true, what append adds to today's entry is grouped
+by the part of the day: the first append of the morning puts a
+"## Morning" heading before its line, and later ones go under it until
+the afternoon starts, when "## Afternoon" is added, and so on.  A heading
+is added whenever the last heading of the entry isn't the one for now, so
+one removed by hand comes back with the next append.  The parts are a
+[sections] table of names and the hour each starts at, Morning = 5,
+Afternoon = 12, and Evening = 17 unless set; before the first starts, it is
+still the last.
+
+Creating an entry dated further from today than confirm_distance (default
+365d) asks for confirmation first, so a typo like 3/7/2002 doesn't quietly
+create a file in the wrong year.  --yes, or running without a terminal,
+skips the question.
+
+New entries start with the generated header followed by a template, if one
+applies: the --template flag, then the WM_TEMPLATE environment variable,
+then the narrowest [templates] date range containing the date
+("2024-01-01..2024-03-31" = "templates/q1.md"), then the [templates] entry
+for the weekday (monday = "templates/monday.md"), then the template key.
+Ranges may nest but not otherwise overlap.  Paths in the configuration file
+are relative to it, and templates may use {{.Date}}, {{.Weekday}},
+{{.Month}}, {{.Year}}, and {{.Day}}.  -v notes which template was used.
+
+Opening a date with --at "## Next" or --at-tag meeting puts the editor on
+the first matching heading or tagged line, using the editor_line_arg
+template to pass the line, such as "+{line}" for vim or "--goto
+{file}:{line}" for VS Code.  --ensure-template appends the section when it
+is missing.  Without a template or a match the entry is opened as usual.
+editor_goto_format is another name for editor_line_arg.
+
+The editor is a command line, such as editor = "code --wait", with quotes
+around words holding spaces; unset, $VISUAL, $EDITOR, and notepad on Windows
+or vi elsewhere are tried in that order.  An editor from the environment or
+the platform is run on the terminal, and wm waits for it to exit, as it does
+for a configured one.  An editor that isn't found fails before anything is
+started, naming programs on PATH with a similar name; one that exits with a
+failure makes wm exit with the same status, after printing the command line
+run and what the editor wrote to standard error.  detach = true, or
+editor_wait = false, starts the editor and leaves it running instead, for
+GUI editors that return at once, unless encrypt, git_autocommit,
+lock_entries, normalize, or post_save hooks need the edit to be finished.
+
+The editor is always started with these environment variables, for editor
+plugins to rely on: WM_FILE, the absolute path opened (the first, when
+several are); WM_DATE, its date as YYYY-MM-DD; WM_PROFILE, the name the
+configuration is listed under in [profiles]; WM_ROOT, the root; WM_KIND,
+one of entry, config, scratch, month, or note; and WM_CREATED, 1 when this
+run created the file and 0 otherwise.  Ones that don't apply are set to "".
+
+A [hooks] table runs commands of your own around editing an entry, such as
+pushing to a backup server or rebuilding a site: pre_open = ["..."] before
+the editor starts and post_save = ["..."] once it exits, each on every entry
+opened with the editor's environment variables.  Hooks write to standard
+error.  A pre_open hook exiting non-zero stops the entry from being opened,
+with what it wrote to standard error; a post_save one failing is only
+noted.  A hook still running after hook_timeout_seconds, 30 by default, is
+killed.  Hooks aren't run with --dry-run, and as post_save hooks run after
+the edit, wm always waits for the editor while there are any.
+
+Which day "today" is follows timezone, an IANA zone name defaulting to the
+system's, and day_start_hour, or day_rollover_hour by its other name: with
+day_start_hour = 4, work at 02:30 still belongs to the previous day, so
+opening today, appending, and the today, yesterday, and tomorrow keywords
+all go by the previous day's date until 04:00.  The day is taken once when
+wm starts, and --now=2024-03-07T01:30, or WM_NOW, runs a command as though
+it were that time, for scripts and tests.  Commands that summarize history
+leave out entries dated after today, such as files created ahead for
+planning, unless --include-future is given; commands that address explicit
+dates see them.
+
+Dates may be given as keywords such as today, yesterday, eom, or
+lastworkday, optionally followed by a day offset like "eom-2", as days from
+today such as "-3", as weekdays such as "friday", "last monday", or "next
+fri", or in one of several layouts.  Custom keywords can be defined in the
+[date_keywords] table; run "wm help dates" to list them all.  Month names
+are understood in English and in the language set by date_locale (de, es,
+fr, it, nl, or pt), both in dates given on the command line and in month
+directory names such as "03-März". Weekday and month names wm writes, in
+search, due, pick, holidays list, export, and templates, are in that
+language too, or in the one given with --locale, such as --locale=en.
+
+"completion <shell>" prints a tab completion script for bash, zsh, fish, or
+powershell, covering the commands and their options as listed under Usage
+below, and completing dates to the keywords and to the dates of recent
+entries.  Load it with "source <(wm completion bash)" in ~/.bashrc, and the
+same for zsh; the script says where it goes for the others.
+
+Use "meetings" to write the day's events from an iCalendar file into a
+"Meetings" section of the entry, one line per event with its time range,
+title, and location.  The section is enclosed in wm:begin/wm:end marker
+lines and is regenerated in place on each run; notes written under a meeting
+line are kept. Only simple daily and weekly RRULE recurrences are expanded;
+other recurring events appear on their first occurrence only.
+
+Use "check --headers" to find entries whose header date disagrees with the
+date of their path.  --fix rewrites the header to match the path and
+--fix-by-header moves the file to the header's date instead; either way the
+original is first copied to the .versions directory under the root.
+
+Use "check --encoding" to find entries that aren't valid UTF-8, such as ones
+imported from older tools, shown with a sample line and their likely
+encoding.  --fix --from-encoding=windows-1252 converts them to UTF-8 in
+place, keeping the original in .versions.  Until then, search and previews
+show the bytes they can't read as �.
+
+Use "bundle export" to package the configuration file, with its root
+parameterized, and any templates, snippets, or prompts directories next to
+it into a portable tarball (wm-setup.tar.gz by default).  Log content is
+never included.  "bundle import" installs a bundle next to this machine's
+configuration file, asking for the root to use and before overwriting files.
+
+Use "import <source>" to bring notes kept elsewhere, such as a directory of
+dated files, into the archive.  Each file under <source>, or each whose
+name matches a --pattern such as "*.md", goes into the entry of its date,
+told by --date-from: "filename" (the default) reads it from the file's
+path with import_filename_regex, which must have (?P<year>), (?P<month>),
+and (?P<day>) groups and finds 2023-05-06, 20230506, and 2023/05/06 unless
+set; "mtime" takes the day the file was last modified; and "frontmatter"
+reads its date field.  A day without an entry gets a new one, the generated
+header followed by the note; one with an entry gets the note appended after
+an "--- Imported from <path> ---" line.  --dry-run lists where every file
+would go, and files whose date can't be told are listed once the rest are
+in, and make import exit 1.
+
+Use "export" to write the entries in a range, or the whole archive, as one
+Markdown document on stdout or the file given with -o, such as "export
+2024-01-01 2024-03-31 -o q1.md" for what was worked on in a quarter.  Each
+entry's header becomes a heading with its full date, its body is kept as it
+is, entries with nothing written are left out, and the number of entries
+and words exported is reported.  "export --html", or --format=html, writes
+one HTML file instead.  --print adds a print
+stylesheet with a serif body, a page break before each month, and a running
+header with the date range, ready for "print to PDF" in a browser.  With
+--redact-tag, entries carrying the redact_tag tag (default #private) still
+appear by date but their content is replaced by "redacted".  With
+--redacted, what the patterns of the [redact] table match is replaced as by
+"redact".
+
+Use "redact" for a copy of an entry safe to share, written to stdout, the
+clipboard with --to=clipboard, or a file with --to=file -o <file>; the entry
+itself is never changed.  Each --pattern is a regular expression, optionally
+labelled as in 'PERSON=\b[A-Z][a-z]+ [A-Z][a-z]+\b', and each line of a
+--pattern-file is a literal secret.  Every match becomes a placeholder named
+by its label, PERSON-1, SECRET-2, or REDACTED-3 without one, numbered in
+order of appearance; the same text, in any case, gets the same placeholder
+throughout.  Where matches overlap the earliest, then the longest, wins.
+--mapping-out writes each placeholder and the text it replaced to a file
+readable only by you.  The patterns and pattern_files of a [redact] table
+always apply as well.
+
+Entries are stored as root/YYYY/M/D.txt unless path_layout says otherwise:
+"flat" keeps them all in the root as 2006-01-02.txt, and any Go time layout
+can be given, with '/' for directories and an optional extension, such as
+"2006-01-02.md".  Use "migrate --layout=<layout>" to move the archive from
+the configured layout to another, then set path_layout to match.
+path_format is another name for path_layout.  When the entry for a date
+isn't where the configured layout puts it but is at the default
+root/YYYY/M/D.txt, opening the date opens that one rather than creating a
+second entry.
+
+New entries get the extension of extension, such as extension = "md", or
+else the one path_layout ends in, .txt by default.  Entries with one of
+legacy_extensions, ["txt"] unless set, are still opened, searched, listed,
+and counted, so an archive can keep its .txt entries while new ones are .md.
+Opening a date opens its entry whatever its extension rather than creating
+one with the configured extension; a day with both a .txt and an .md entry
+opens the one with the configured extension and notes the other.
+
+Directories and entries are created with dir_mode and file_mode, 0o755 and
+0o644 unless set, such as dir_mode = 0o700 and file_mode = 0o600 to keep
+them private; the umask still applies.  A directory under the root that
+can't be entered, as earlier versions created on Linux, is repaired to
+dir_mode with a note.
+
+Use "append" to add a line to today's entry, or with a range to every day in
+it that --each selects, such as --each=weekday --in=this-month.  Missing
+entries are created from their templates first, and --skip-if-present leaves
+entries that already contain the exact line alone so it can be rerun.  A
+line appended to today's entry alone starts with the time, as in "[15:04]
+deployed v1.4.2", unless --no-time is given; one appended to another day
+with --date, or to a range, doesn't, as the time would be of today.  The
+words after "--" are the line; without any, the lines are read from stdin,
+so "echo deployed | wm append" works.
+
+Use "clip" to append what is on the clipboard, a stack trace or a link, to
+today's entry or the date given, as a fenced block under a line with the
+time.  The clipboard is read with pbpaste on macOS, Get-Clipboard on
+Windows, and wl-paste, xclip, or xsel elsewhere, unless clipboard_command
+names another command.  An empty clipboard appends nothing, and more than
+1 MB, or anything that isn't text, is refused.
+
+Opening a past date that has no entry opens the nearest entry within
+nearest_entry_days (default 3) days either side of it instead, the earlier
+one when two are as near, and says so, never one after today.  When there is
+none, nothing is created and wm exits 5.  --create creates the entry of the
+date given anyway, --strict fails rather than open another day, and
+nearest_entry_days = 0 turns this off, so that the entry is created.  Today,
+later dates, and topics are never redirected.
+
+Setting strict_create = true makes wm create nothing implicitly, for shared
+or audited roots: opening a date without an entry, appending to one, and
+writing meetings into one fail unless --create is given.  Existing entries
+can still be written to, and "fill" still creates the entries it is asked
+for.
+
+Use "fill" to create the missing entries, with their headers, for every day
+in a range.
+
+Use "consolidate" to merge every entry of a year into one file for cold
+storage, compressed with --gzip or when the output name ends in .gz, and
+"split" to reconstruct the original per-day files from it byte for byte,
+under the root or the directory given with -o.  Existing files are only
+replaced with --yes.
+
+Use "due" to list the open items annotated with @due(3/15) in the last 90
+days of entries, grouped into overdue, due today, and due within the next 14
+days or --within, with the entry and line they were written on.  It exits 1
+when anything is overdue.  Dates without a year fall in the year nearest the
+day they were written, and keywords such as "tomorrow" count from it;
+malformed dates are noted.  Ticked checkboxes are left out, as is an item
+whose latest copy is ticked.  The [due] table sets pattern, a regular
+expression whose first group is the date, lookback, and within.
+
+Use "todo" to list the open Markdown task list items, "- [ ] call Dana" or
+"* [ ] ...", nested ones included, of every entry or of the range given,
+grouped by entry with the line each is on.  Boxes inside code fences are
+not tasks.  --include-done lists ticked items too, and --stale=<n> marks the
+open ones written more than n days ago.  With carry_forward = true, today's
+entry starts, when it is created, with a "## Carried over" section copying
+the open items of the latest entry before it, nested ones with their
+indentation; that entry itself isn't changed.
+
+An entry can start with front matter, fields between two "---" lines either
+as TOML or as "key: value" lines, such as "project: apollo" and "mood:
+good", at the very top or right below the header; a template can write the
+block for new entries.  "meta <key>" lists the entries that set the field
+with its value, and "meta <key> <value>" those setting it to that value, or
+to a list containing it, ignoring case.  A "---" further down the entry is
+never taken for front matter.
+
+"notify" is "due" for cron or a scheduled task: it posts one desktop
+notification of what is overdue or due within --due-within, a day by
+default, through notify-send, osascript on macOS, or a PowerShell toast on
+Windows, and prints the items too.  When the notification can't be posted it
+exits 1 with the items already on standard output, so cron mails them
+instead; --dry-run prints the notification without posting it.
+
+Use "decisions" to collect the lines marked "DECISION:" into a chronological
+register with their dates and entries, as Markdown or CSV with --format.
+The indented or bulleted lines right after a decision belong to it.  The
+[decisions] table takes a different pattern, a regular expression, and
+continuation = false to keep only the matching line.
+
+Use "review --year=2024" at the end of a year for a Markdown report of it,
+to standard output or the file given with -o: for the year and then each
+month, the number of entries and words, the 15 most frequent words that
+aren't common English ones, and the tags used.  Months without entries say
+so.  review_stopwords lists more words to leave out, such as names.
+
+Use "week" to open the entries of this week, or of the week of a date, in
+the editor at once, or "week --cat" to print them one after another under a
+"=== 2024-03-04 (Monday) ===" line per day.  Weeks start on Monday unless
+week_start names another day, such as week_start = "sunday".
+
+Use "onthisday" to look back at the entries of the same day in earlier
+years, today's or that of a date, the most recent first: each under a
+"=== Thu 2024-03-07 (2 years ago) ===" line with its first three lines, the
+whole entry with --full, or with --open all of them opened in the editor.
+Extra roots are looked in too.  For February 29 the years without one show
+February 28.
+
+Use "month" to read a whole month at once, such as for writing its summary:
+the month's entries, under a "### March 5, 2024" heading each, are copied
+into one read-only file in the temporary directory and opened in the editor,
+or printed with --cat.  The month is the current one or that of a date, and
+can be given as "2024-03", "3/2024", "march 2024", or just "march".  Only
+month reads all of these forms, and --in all but "3/2024"; wherever a day is
+asked for, they are refused with a note rather than taken as a day of the
+month.
+
+Use "summary" for the important lines of the week, or of a range: decisions,
+lines marked "IMPORTANT:", and lines starting with "!", taken the same way
+and configured in a [summary] table.  Entries carrying redact_tag are left
+out.  --format html-email writes a self-contained HTML fragment with inline
+styles and a link to each day, for piping into mail.
+
+Use "stats" for the number of entries, words, average words per entry, the
+current and longest runs of consecutive days written, and most used tags in
+a range, such as "stats --year=2024", with how many days were written on
+each weekday; --json prints them for scripts, and "stats --compare=q1,q2",
+or "stats --range=this-quarter --vs=last-quarter", to set two ranges side by
+side with the change from the first, or the --vs range, to the other: in
+counts and percentages, and the tags used more and less.  Ranges that share
+days are refused unless --allow-overlap is given.
+
+Holidays are skipped by workday keywords such as lastworkday.  List them in
+holidays = ["2024-12-24 Christmas Eve"] or in holidays_file, a file of
+"YYYY-MM-DD Name" lines next to the configuration.  "holidays import" adds a
+country's public holidays for a year to that file, moved to the observed
+weekday where the country does so, and "holidays list" shows the active
+ones. Presets: CA, DE, FR, GB (England and Wales), and US (federal).
+
+Use "links" for an inventory of the links and images in Markdown entries,
+inline and reference-style, with their date, text, target, and whether a
+relative target exists under the root; --format csv or json suits a
+spreadsheet or a script.  Relative targets are taken from the entry's
+directory, or from the root when they start with "/", and ones that lead out
+of the root, even through a symbolic link, are flagged and never followed.
+Code fences and code spans are skipped.  "check --links" lists only the
+broken ones and exits 1 if there are any.
+
+Use "tags" to list every tag with the number of days it appears on, most
+used first.  A tag is a word such as #standup at the start of a line or
+after a space, so the anchor of http://x/#anchor is not one, and never text
+inside a code fence of a Markdown entry.  search --tag=standup only reads
+the entries carrying the tag, and without terms shows where it appears.
+
+Use "tags rename #mtg #meeting" to rewrite a tag across the archive, or
+"tags merge #mtg #meetings --into=#meeting" to rewrite several.  Only whole
+tags are rewritten, never text inside code fences of Markdown entries.  The
+changes are shown and confirmed first, and every file is backed up to the
+versions store before it is rewritten.
+
+Commands that take a range of entries accept either a positional range,
+"<from>..<to>" or a single date, or exactly one of --in (2024, 2024-03,
+march, march 2023, q1, 2024-q1, this-week, last-week, this-month,
+last-month, this-quarter, last-quarter, this-year, last-year), --last (10d,
+2w), or --weeks (weeks starting Monday), or --from and/or --to.  Without any
+of them the whole archive is used; search with --from alone reads through
+today.  Search and export also take --from as --since, and --from, --since,
+and --to take any date, relative phrases among them: --since "3 months ago",
+--to "in 2 weeks".  --since also takes an age back from now, 2d, 1w, or 12h,
+and reads the same in every command: search and export start at the day it
+falls on, and "modified" lists what was edited after it, as it is about edit
+times, not entry dates.
+
+List other configurations under [profiles], as work =
+"~/.config/wm/work.toml", and "search --all-profiles" searches every
+profile's root with that profile's own path_layout and attachment settings,
+in date order across them.  Results are labeled with the profile, and the
+json format gains profile and editor fields so a result can be opened with
+the editor of the profile it came from.  The active configuration is
+searched as "current" unless listed.  Profiles whose root is unavailable are
+skipped with a note, and scratch notes are not searched.
+
+To keep several logs in one configuration, define them as [notebooks.work],
+[notebooks.personal], and so on, each with its own root and optionally its
+own editor and template; whatever a notebook leaves unset comes from the
+top level.  Every command works in the notebook given with -n/--notebook,
+such as "wm -n personal search dentist", or else in the one default_notebook
+names, or else in the top-level root.
+
+Years archived elsewhere can stay searchable: list their directories in
+extra_roots, as extra_roots = ['D:\archive\wm'], and search, list, stats,
+and export read them along with the root.  A date in both keeps the root's
+entry, with a note.  Opening a date, path, and cat find an entry only an
+archive has there, but new entries are always created under the root.
+
+Attachments of an entry are kept under attachments/<date>/ in the root, e.g.
+attachments/2024-03-07/build.log.  search --include-attachments also
+searches those whose extension is in attachment_types (default txt, md, csv,
+log, and json) and whose size is at most attachment_max_size bytes (default
+1 MiB), labeling hits with the attachment and its entry's date.  Binary
+files are never searched. Use "attach <file>" to copy a file there for the
+date given, today by default, and note it in the entry as "attachment:
+attachments/2024-03-07/report.csv (24 KB)".  A name already taken gets -1,
+-2, and so on before its extension, and a directory is only copied with
+--recursive.  "attachments" lists what the date given, or every date, has.
+
+wm marks a root it creates with a .wm-root file.  Commands that move,
+rewrite, or create entries in bulk (lint --fix, check --fix, fill, migrate,
+consolidate, tags, and split into the root) refuse to run on a root without
+one unless --force-root is given, in case root points at the wrong
+directory.  Use "init" to adopt an existing directory after seeing what is
+in it.
+
+Use "trim <date>" when a pasted stack trace or log has swollen an entry.  It
+shows every block of more than --over lines that looks like pasted output
+and, once confirmed, moves it to an attachment such as
+attachments/2024-03-07/trimmed-L12.txt, keeping its first and last three
+lines around a "[... 1,842 lines trimmed, original in ...]" marker.  A
+block looks pasted when at most [trim] max_prose_ratio (default 0.3) of its
+lines read as prose, when half of them are longer than long_line (default
+160) characters, or when min_indented (default 0.6) of them share the same
+indentation.  The entry is backed up first, and "trim --restore <date>"
+puts the blocks back from the attachments.
+
+Use "scratch <name>" for a note that belongs to no day, such as
+"interview-questions", kept as scratch/<name>.txt under the root.  Names are
+lowercase letters, digits, and dashes, and can't read as a date.  "scratch
+--list" lists them, and search includes them, labeled, unless --entries-only
+or a range is given.  Nothing else that works on dates sees them.
+
+Use "note <kind> [<arg>]" for notes that don't map to one day, such as a
+file per person for one-on-ones.  Each kind is a [notes.<kind>] table with
+the path of its notes under the root, such as path = "people/{{.Arg}}.md"
+or path = "retros/{{.Year}}-W{{.ISOWeek}}.md", and optionally a template
+for new ones, rendered with the same fields: Arg, the argument, which a
+path using it needs and any other refuses; Year; Month and ISOWeek, two
+digits; and Date, today as YYYY-MM-DD.  "wm note oneonone alice" opens
+people/alice.md, creating it first.  "search --all-notes" searches the
+notes of every kind too.
+
+Setting output = "plain", or passing --plain, makes every command write
+linear "label: value" output with words instead of glyphs and no drawing or
+color, search label each "match:" and its "context:", and the picker ask for
+the number of a listed entry instead of taking over the screen.
+
+On a terminal, search shows the date above each file's results in color
+and highlights every match in the context below it, of whichever term.
+Output piped elsewhere is never colored, and --no-color or a non-empty
+NO_COLOR environment variable turn color off on terminals too.
+
+On a terminal, the output of search, list, cat, and export that is longer
+than the terminal goes through a pager: pager, a command line such as
+pager = "less -FRX", or $PAGER, or less -R, more on Windows.  Quitting the
+pager early drops the rest of the output.  pager = "off" or --no-pager
+writes it straight to the terminal.
+
+Opening a date exits 0 whether the entry existed or was created, 3 instead
+when it was created and --fail-if-created is given, 4 when it has nothing
+but its header and --fail-if-empty is given, 5 when it doesn't exist and
+--no-create is given, 6 when the editor can't be started or exits with a
+failure, whatever its own status, which is reported on stderr, and 1 on any
+other error.  --print-path prints the entry's path instead of opening it and
+--no-edit opens nothing, so "wm --print-path --no-create --fail-if-empty"
+cheaply tells a shell prompt whether today has been written in.
+
+When wm opens the wrong file, run it again with --verbose: it logs to stderr
+the configuration file it read and why, the root after expanding "~", the
+date it parsed, the entry's path, and the editor command line.  Opening a
+date with --dry-run resolves all of that and prints what it would do, such
+as "would create directory ..." and "would run ...", without creating a file
+or starting the editor.  search --dry-run searches as usual, as it only
+reads, and says so.  Commands without a --dry-run of their own refuse it
+rather than run for real.
+
+To look at an entry without any chance of changing it, "cat <date>" prints
+it and exits 1 when it doesn't exist, and --read-only opens it in the editor
+with the arguments readonly_args gives, such as "-R" for vim, or else as a
+read-only temporary copy.  Neither creates the entry or touches its
+modification time, and --read-only exits 5 when there is nothing to open.
+
+--explain-date, or -v, prints which keyword, phrase, or layout the date
+matched and the date it resolved to before anything else happens.  Dates
+may be written as 2024-03-07 or 20240307, and numeric dates with a two-digit
+year, such as 3/7/24, too.  A numeric date such as 3/7/2024 that is a valid
+date read either way is only accepted with date_order = "mdy" or "dmy"
+set, and refused otherwise rather than guessed; 3/25/2024 can only be read
+one way and needs neither.  With confirm_parsed_date = true, a press of y
+is needed to go on when the other order would also have been a valid date.
+
+Commands that scan the archive skip version control metadata, wm's internal
+directories (.trash, .versions, .wm-index, .wm-trash, attachments, scratch),
+and other hidden directories under the root unless --hidden or --all is
+given.
 
 Usage:
-  wm config
-  wm search [<term>...]
-  wm [<date>]
+  wm init [--yes]
+  wm config set <key> <value>
+  wm config migrate [--yes] [<dir>...]
+  wm config schema [--format=<fmt>]
+  wm config --check [--lint-patterns]
+  wm config [--show | --path]
+  wm doctor
+  wm conflicts [--merge] [--dry-run] [--hidden | --all]
+  wm move <from> <to> [--topic=<name>] [--dry-run]
+  wm archive --before=<date> [--dest=<dir>] [--compress] [--dry-run] [--yes]
+  wm archive --list [--dest=<dir>]
+  wm search [--format=<fmt> | --json | --csv | --line] [-l [-0] | -c | -q]
+            [-i | --case-sensitive | -S] [-F] [-w] [--fold-diacritics]
+            [--any] [--inline-dates] [--include-attachments]
+            [--include-archives]
+            [--follow | --open [--first]] [--entries-only | --all-notes]
+            [--no-boilerplate] [--explain] [--topic=<name>] [--tag=<tag>]
+            [--all-profiles] [--hidden | --all]
+            [--from=<date> | --since=<when>] [--to=<date>] [--in=<period>]
+            [--last=<age>] [--weeks=<n>] [<term>...]
+  wm index [--rebuild]
+  wm coverage [--include-attachments] [--entries-only] [--hidden | --all]
+            [--from=<date>] [--to=<date>] [--in=<period>] [--last=<age>]
+            [--weeks=<n>]
+  wm lint [--fix] [--force-root] [--hidden | --all] [--from=<date>]
+            [--to=<date>] [--in=<period>] [--last=<age>] [--weeks=<n>]
+            [<range>]
+  wm review [--year=<year>] [-o <file>] [--include-future]
+            [--hidden | --all]
+  wm decisions [--format=<fmt>] [-o <file>] [--hidden | --all]
+            [--from=<date>] [--to=<date>] [--in=<period>] [--last=<age>]
+            [--weeks=<n>] [<range>]
+  wm summary [--format=<fmt>] [--hidden | --all]
+            [--from=<date>] [--to=<date>] [--in=<period>] [--last=<age>]
+            [--weeks=<n>] [<range>]
+  wm stats [--format=<fmt> | --json] [--year=<year>] [--include-future]
+            [--hidden | --all] [--from=<date>] [--to=<date>] [--in=<period>]
+            [--last=<age>] [--weeks=<n>] [<range>]
+  wm stats (--compare=<ranges> | --range=<range> --vs=<range>)
+            [--allow-overlap] [--format=<fmt>] [--include-future]
+            [--hidden | --all]
+  wm links [--format=<fmt>] [--hidden | --all]
+            [--from=<date>] [--to=<date>] [--in=<period>] [--last=<age>]
+            [--weeks=<n>] [<range>]
+  wm due [--within=<age>] [--hidden | --all]
+            [--from=<date>] [--to=<date>] [--in=<period>] [--last=<age>]
+            [--weeks=<n>] [<range>]
+  wm todo [--include-done] [--stale=<n>] [--hidden | --all]
+            [--from=<date>] [--to=<date>] [--in=<period>] [--last=<age>]
+            [--weeks=<n>] [<range>]
+  wm meta <key> [<value>] [--hidden | --all] [--from=<date>] [--to=<date>]
+            [--in=<period>] [--last=<age>] [--weeks=<n>]
+  wm sync
+  wm unlock <date>... [--topic=<name>] [--yes]
+  wm notify [--due-within=<age>] [--dry-run] [--hidden | --all]
+            [--from=<date>] [--to=<date>] [--in=<period>] [--last=<age>]
+            [--weeks=<n>] [<range>]
+  wm list [--show-mtime | --dates-only] [--topic=<name>] [--format=<fmt>]
+            [--limit=<n> [--page-token=<token>]] [--hidden | --all]
+            [--in=<period>] [--last=<age>] [--weeks=<n>] [<from> [<to>]]
+  wm pick [--days=<n>]
+  wm last [<count>] [--include-future] [--hidden | --all]
+            [--print-path | --no-edit]
+  wm trim [<date>...] [--over=<n>] [--yes] [--dry-run]
+  wm trim --restore [<date>...] [--dry-run]
+  wm restore [<date>...] [--backup=<n>] [--topic=<name>]
+  wm week [--cat] [<date>...]
+  wm onthisday [<date>...] [--full | --open] [--hidden | --all]
+  wm month [--cat] [<date>...]
+  wm scratch <name>
+  wm scratch --list
+  wm note <kind> [<arg>]
+  wm exists [<date>...]
+  wm cat [<date>...] [--topic=<name>]
+  wm path [<date>...] [--create] [--yes] [--template=<path>]
+            [--topic=<name>]
+  wm info [--format=<fmt>] [<date>...]
+  wm info --range=<range> [--format=<fmt>]
+  wm tags rename <old> <new> [--dry-run] [--yes] [--force-root]
+            [--hidden | --all]
+            [--from=<date>] [--to=<date>] [--in=<period>] [--last=<age>]
+            [--weeks=<n>] [<range>]
+  wm tags merge <tag>... --into=<tag> [--dry-run] [--yes] [--force-root]
+            [--hidden | --all]
+            [--from=<date>] [--to=<date>] [--in=<period>] [--last=<age>]
+            [--weeks=<n>]
+  wm tags [--hidden | --all] [--from=<date>] [--to=<date>] [--in=<period>]
+            [--last=<age>] [--weeks=<n>] [<range>]
+  wm modified [--since=<when>] [--hidden | --all]
+  wm unread [--peek] [--hidden | --all]
+  wm unread (--mark-read=<date> | --mark-all-read)
+  wm review-queue add <pattern>...
+  wm review-queue [stats | next]
+  wm usage [--clear]
+  wm history
+  wm redo [<count>] [--edit]
+  wm holidays import --country=<code> --year=<year>
+  wm holidays list [--year=<year>]
+  wm help dates
+  wm completion <shell>
+  wm redact [<date>...] [--pattern=<re>...] [--pattern-file=<file>...]
+            [--to=<dest>] [-o <file>] [--mapping-out=<file>]
+  wm export [--html | --format=<fmt>] [--print] [--redact-tag] [--redacted]
+            [-o <file>] [--hidden | --all] <from> <to>
+  wm export [--html | --format=<fmt>] [--print] [--redact-tag] [--redacted]
+            [-o <file>] [--hidden | --all]
+            [--from=<date> | --since=<when>] [--to=<date>] [--in=<period>]
+            [--last=<age>] [--weeks=<n>] [<range>]
+  wm append [--date=<date>] [--no-time] [--create] [--dry-run]
+            [--template=<path>] [-v] [--] [<text>...]
+  wm append [--each=<days>] [--skip-if-present] [--create] [--dry-run]
+            [--force-root] [--template=<path>] [-v]
+            [--from=<date>] [--to=<date>] [--in=<period>] [--last=<age>]
+            [--weeks=<n>] [--] [<text>...]
+  wm clip [<date>...] [--create] [--yes] [--topic=<name>]
+  wm attach <file> [<date>...] [--recursive] [--create] [--yes]
+            [--topic=<name>]
+  wm attachments [<date>...]
+  wm fill [--dry-run] [--force-root] [--template=<path>] [-v]
+            [--from=<date>] [--to=<date>] [--in=<period>] [--last=<age>]
+            [--weeks=<n>] [<range>]
+  wm consolidate <year> [-o <file>] [--gzip] [--force-root]
+            [--hidden | --all]
+  wm split <consolidated> [-o <dir>] [--yes] [--force-root]
+  wm migrate --layout=<layout> [--dry-run] [--force-root] [--hidden | --all]
+  wm bundle export [-o <file>]
+  wm bundle import <bundlefile> [--yes]
+  wm import <source> [--pattern=<glob>...] [--date-from=<how>] [--dry-run]
+  wm check --headers [--fix | --fix-by-header] [--dry-run] [--force-root]
+            [--hidden | --all]
+  wm check --encoding [--fix --from-encoding=<enc>] [--dry-run]
+            [--force-root] [--hidden | --all]
+  wm check --links [--hidden | --all] [--from=<date>] [--to=<date>]
+            [--in=<period>] [--last=<age>] [--weeks=<n>] [<range>]
+  wm meetings --from-ics=<src> [--date=<date>] [--skip-allday] [--create]
+  wm [<date>...] [--create | --no-create | --strict] [--yes]
+            [--template=<path>] [-v]
+            [--at=<section> [--ensure-template] | --at-tag=<tag>]
+            [--print-path | --no-edit | --read-only] [--fail-if-created]
+            [--fail-if-empty] [--topic=<name>] [--explain-date]
+            [--existing-only] [--force]
   wm -h | --help
   wm --version
 
 Options:
-  -h --help     Display this screen
-  --version     Display the current version`
+  -h --help         Display this screen
+  --show            Show which configuration file is used and why
+  --check           Check the configuration file against the schema and the
+                    settings wm accepts, exiting with 1 on any problem
+  --lint-patterns   With --check, also warn about configured patterns that
+                    match the empty string or nest repetitions
+  --no-local        Don't look for a .wm.toml above the current directory;
+                    accepted by every command
+  --plain           Linear output without drawing, glyphs, or color for
+                    screen readers; accepted by every command
+  --no-color        Don't color output even on a terminal, as NO_COLOR does;
+                    accepted by every command
+  --no-pager        Write output longer than the terminal straight to it
+                    instead of through the pager; accepted by every command
+  --relative        Print paths relative to the .wm.toml in use, or the
+                    root, with forward slashes; accepted by every command
+  --locale=<code>   Render weekday and month names in this locale instead of
+                    date_locale's; accepted by every command
+  -n, --notebook=<name>
+                    Work in this notebook of [notebooks] instead of
+                    default_notebook's; accepted by every command
+  --version         Display the current version
+  --json            Print search hits as a JSON array, as --format=json
+  --csv             Print search hits as CSV with a header row
+  --format=<fmt>    Output format: human, json, csv, or grep for search;
+                    human, markdown, or csv for decisions; human or
+                    html-email for summary; human or json for info, stats,
+                    and list; human, csv, or json for links; json-schema for
+                    config schema [default: human]
+  --range=<range>   Days to report on: a period such as last-week, a date,
+                    or "<from>..<to>"
+  --compare=<ranges>
+                    Two ranges to compare, separated by a comma, e.g. q1,q2
+  --vs=<range>      The range to compare --range with
+  --allow-overlap   Compare ranges even when they share days
+  -l --files-with-matches
+                    Only print the paths of entries that match
+  -i --ignore-case  Match search terms regardless of case, the default
+  --case-sensitive  Match search terms only in the case given
+  -S --smart-case   Match search terms in lower case regardless of case, and
+                    terms with capitals only in the case given
+  --line            Print one line per search hit, as "2024-03-07:42: text"
+  -F --fixed-strings
+                    Match search terms as literal text, not as patterns
+  --fold-diacritics
+                    Match search terms regardless of accents
+  -w --word         Match search terms only as whole words
+  --any             Report entries matching any search term, not all of them
+  --inline-dates    Prefix every search context block with the entry's date
+  --follow          Keep running and print new matches as entries change
+  --open            Open the editor instead of printing: at the first search
+                    hit, or with all of onthisday's entries
+  --first           With --open, open the newest of several files with hits
+  --include-attachments
+                    Also search text attachments of entries
+  --include-archives
+                    Search the archives wm archive wrote to archive_dir too
+  --before=<date>   Archive the entries dated before this day
+  --dest=<dir>      The archive directory, instead of archive_dir
+  --compress        Archive into a .tar.gz per year
+  --entries-only    Leave scratch notes out of the search
+  --all-notes       Also search the notes of the kinds in [notes]
+  --no-boilerplate  Ignore matches in entries' headers and template lines
+  --explain         Print what search reads and the compiled patterns first
+  --topic=<name>    Open, list, or search the entries of a topic of the day
+  --all-profiles    Search the roots of every profile in [profiles] too
+  --explain-date    Print how the date was read before opening it
+  --include-future  Count entries dated after today as history
+  --list            List the scratch notes
+  -0 --print0       Separate -l paths with NUL bytes instead of newlines
+  -c --count        Only print how many matches each matching entry has
+  -q --quiet        Print no search results, only exit 0 on a match and 1 on
+                    none
+  --fix             Repair mechanical lint findings in place, or rewrite
+                    mismatched headers to match the entry's path
+  --fix-by-header   Move entries to the date their header names
+  --encoding        Find entries that aren't valid UTF-8
+  --from-encoding=<enc>
+                    Convert entries from this encoding with --fix:
+                    windows-1252, iso-8859-1, or iso-8859-15
+  -o <file> --out=<file>
+                    Write output to this file or directory
+  --html            Export as a single self-contained HTML file
+  --print           Include a print stylesheet for printing to PDF
+  --redact-tag      Replace entries tagged with redact_tag by a placeholder
+  --redacted        Replace what the [redact] patterns match by placeholders
+  --pattern=<re>    A regular expression to redact, optionally LABEL=<re>,
+                    or for import, a glob of the file names to import
+  --date-from=<how>
+                    How import dates files: filename (the default), mtime,
+                    or frontmatter
+  --pattern-file=<file>
+                    A file of literal secrets to redact, one a line
+  --mapping-out=<file>
+                    Write which placeholder stands for what to this file
+  --template=<path> Template for entries created by this command
+  -v --verbose      Note which template new entries are created from; as
+                    --verbose, accepted by every command, also log how the
+                    file worked on was found
+  --at=<section>    Open the entry at this section heading, e.g. "## Next"
+  --ensure-template
+                    Add the section when the entry doesn't have it
+  --at-tag=<tag>    Open the entry at the first line carrying this tag
+  --layout=<layout>
+                    The path layout to move entries to
+  --gzip            Compress the output with gzip
+  --yes             Don't ask before overwriting or for input
+  --over=<n>        Only trim blocks longer than this many lines
+                    [default: 200]
+  --days=<n>        Pick from the entries of this many days up to today
+                    [default: 30]
+  --restore         Put trimmed blocks back from their attachments
+  --recursive       Attach a directory with everything in it
+  --backup=<n>      The backup to restore, 1 being the newest
+  --cat             Print the week's entries instead of opening them
+  --full            Print onthisday's entries whole, not their first lines
+  --into=<tag>      The tag that tags merge renames the others to
+  --merge           Merge conflict copies into their entries and move them
+                    away
+  --tag=<tag>       Search only the entries carrying this tag
+  --rebuild         Index every file again rather than only the changed ones
+  --each=<days>     Which days of the range to append to: day, weekday,
+                    workday, or weekday names such as "mon,thu"
+                    [default: day]
+  --skip-if-present
+                    Leave entries that already have the line alone
+  --create          Create the entry even though strict_create is set, and
+                    for a past date rather than open the nearest entry
+  --no-create       Don't create the entry when it doesn't exist
+  --strict          Open the entry of exactly the date given, failing when
+                    it doesn't exist
+  --print-path      Print the entry's path instead of opening it
+  --no-edit         Don't open the entry in the editor
+  --read-only       Open an existing entry without creating or changing it
+  --fail-if-created
+                    Exit 3 when the entry had to be created
+  --fail-if-empty   Exit 4 when the entry has nothing but its header
+  --existing-only   Open only the days of a range that have an entry
+  --force           Open a range longer than open_range_limit
+  --force-root      Run even though the root has no .wm-root marker
+  --dry-run         Print what would be changed without changing anything;
+                    also accepted by opening a date and by search
+  --from=<date>     Start the range at this date
+  --to=<date>       End the range at this date; for redact, where the copy
+                    goes: stdout, clipboard, or file with -o
+  --in=<period>     Limit the range to a year, month, or relative period
+  --last=<age>      Limit the range to this many days or weeks up to today
+  --weeks=<n>       Limit the range to the current and previous n-1 weeks
+  --since=<when>    An age such as 2d or 1w back from now, or a date; for
+                    modified the edit time to start at, for search and
+                    export another name for --from
+  --limit=<n>       Show at most this many entries, with a token for the
+                    rest
+  --page-token=<token>
+                    Carry on a listing after the page this token ended
+  --show-mtime      Also show when each entry was last edited
+  --dates-only      Print only the dates of the entries, one a line
+  --within=<age>    Show items due up to this far ahead
+  --include-done    List ticked todos too
+  --stale=<n>       Mark open todos written more than this many days ago
+  --due-within=<age>
+                    Notify of items due up to this far ahead [default: 1d]
+  --peek            List unread entries without marking them read
+  --mark-read=<date>
+                    Mark entries edited up to the end of this date as read
+  --mark-all-read   Mark every entry as read
+  --country=<code>  Country whose holidays to import
+  --year=<year>     The year to import or list holidays for
+  --clear           Delete the recorded usage stats
+  --edit            Print the command line instead of running it
+  --hidden          Also look inside hidden directories under the root
+  --from-ics=<src>  iCalendar file path or http(s) URL to read meetings from
+  --date=<date>     The date to operate on instead of today
+  --no-time         Append the line without the time in front
+  --skip-allday     Leave out all-day events
+  --all             Look everywhere under the root, including version
+                    control metadata (.git, .hg, .svn) and wm's internal
+                    directories`
 
-	opts, err := docopt.ParseArgs(usage, nil, "0.2.0")
-	if err != nil {
-		log.Fatalln("could not parse arguments:", err)
+// Main runs the wm command line with args, the arguments after the program
+// name, and returns how it failed, if it did; cmd/wm prints the error and
+// exits with ExitStatus.  An error with an empty message, such as that of
+// "exists" for a missing entry, only carries the status.  Programs using wm
+// as a library call the functions of api.go instead.
+func Main(args []string) error {
+	err := runMain(args)
+	stopPager()
+	exitHook(exitCode(err))
+	return err
+}
+
+// parseArgs parses args with the usage text.  --help and --version print
+// what they ask for, and a command line that doesn't parse prints the usage
+// on stderr, as docopt does; done reports that there is nothing left to
+// run, with the status a bad command line exits with as the error.
+func parseArgs(args []string) (opts docopt.Opts, done bool, err error) {
+	printed := false
+	parser := &docopt.Parser{HelpHandler: func(err error, text string) {
+		docopt.PrintHelpOnly(err, text)
+		printed = true
+	}}
+	opts, err = parser.ParseArgs(usage, args, "0.2.0")
+	switch {
+	case printed && err != nil:
+		return nil, true, exitStatus(exitFailure)
+	case printed:
+		return nil, true, nil
+	case err != nil:
+		return nil, true, failed("could not parse arguments", err)
+	}
+	return opts, false, nil
+}
+
+// runMain is Main before the pager is stopped and exitHook run.
+func runMain(args []string) error {
+	started := time.Now()
+	cmdline := args
+	args, nowFlag := takeValueFlag(args, "--now")
+	if err := setClock(nowFlag); err != nil {
+		return withExitCode(exitFailure, err)
+	}
+
+	args, noLocal := takeFlag(args, "--no-local")
+	args, plain := takeFlag(args, "--plain")
+	args, noColor = takeFlag(args, "--no-color")
+	args, noPager = takeFlag(args, "--no-pager")
+	args, relative := takeFlag(args, "--relative")
+	args, verbose = takeFlag(args, "--verbose")
+	args, locale := takeValueFlag(args, "--locale")
+	args, notebookName = takeValueFlag(args, "--notebook")
+	args, short := takeValueFlag(args, "-n")
+	if len(short) > 0 {
+		notebookName = short
+	}
+	args = lastDateArgs(relativeDayArgs(historyArgs(args)))
+	args, dryRun = dryRunArgs(args)
+	bare := len(args) == 0
+	opts, done, err := parseArgs(args)
+	if done || err != nil {
+		return err
 	}
 	var params Parameters
 	err = opts.Bind(&params)
 	if err != nil {
-		log.Fatalln("failed to bind provided parameters: ", err)
+		return failed("failed to bind provided parameters", err)
 	}
-
-	cfgFile := os.Getenv("WMCFG")
-	if len(cfgFile) == 0 {
-		cfgFile = "wm.toml"
+	if err := checkDryRun(opts, params); err != nil {
+		return withExitCode(exitFailure, err)
 	}
+	params.Verbose = params.Verbose || verbose
 
-	cfg := GetConfig(cfgFile)
+	src := followConfigStub(findConfig(noLocal))
+	cfgFile := src.Path
+	tracef("config: %s (%s)", cfgFile, src.Reason)
+	if len(src.Hint) > 0 && !(params.Config && (params.Show || params.ConfigPath)) {
+		log.Printf(":::note::: %s", src.Hint)
+	}
 
-	if params.Config {
-		cmd := exec.Command(cfg.Editor, cfgFile)
-		err = cmd.Start()
+	// Neither needs, nor should stop at, a configuration that can't be read.
+	if params.Config && params.Schema {
+		err = runConfigSchema(os.Stdout, params)
 		if err != nil {
-			log.Fatalln("failed to open configuration file using", cfg.Editor, ":", err)
+			return failed("config schema failed", err)
 		}
-		err = cmd.Wait()
+		return nil
+	}
+	if params.Config && params.CheckConfig {
+		bad, err := runConfigCheck(os.Stdout, cfgFile, params.LintPatterns)
 		if err != nil {
-			log.Fatalln("configuration failed to update:", err)
+			return failed("config --check failed", err)
+		}
+		if bad {
+			return exitStatus(1)
 		}
-		os.Exit(0)
+		return nil
 	}
 
-	if params.Search {
-		searchPath := fmt.Sprintf("%s[1-9][0-9][0-9][0-9]/*/*.txt", cfg.Root)
-		files, err := filepath.Glob(searchPath)
+	// Only commands that work with the log root may create the configuration
+	// file; the rest read it if it is there.
+	var cfg Configuration
+	if dryRun || params.Doctor || params.HelpCmd || params.Completion || params.Usage || (params.Bundle && params.Import) || params.Show || params.ConfigPath || (params.Config && params.Migrate) {
+		cfg, err = PeekConfig(cfgFile)
+	} else {
+		cfg, err = GetConfig(cfgFile)
+	}
+	loadErr := err
+	switch {
+	case err == nil:
+	case params.Doctor:
+		// doctor reports it along with whatever else it finds.
+	case !errors.Is(err, errInvalidConfig) || !params.Config:
+		return withExitCode(exitFailure, err)
+	default:
+		// The config command is how an invalid configuration gets fixed.
+		log.Println(":::note:::", err)
+	}
+	if bare {
+		argv, err := defaultCommandArgs(cfg)
 		if err != nil {
-			log.Fatalln("failed to read all files in the root directory: ", err)
+			return withExitCode(exitFailure, err)
 		}
-		var res []regexp.Regexp
-		for _, term := range params.Term {
-			re, err := regexp.Compile(term)
-			if err != nil {
-				log.Fatalln("could not compile search term: ", term)
+		if argv != nil {
+			tracef("default_command: wm %s", argvLine(argv))
+			opts, done, err = parseArgs(argv)
+			if done || err != nil {
+				return err
 			}
-			res = append(res, *re)
-		}
-		fmt.Println("searching for", params.Term)
-		for _, file := range files {
-			fileData, err := os.ReadFile(file)
-			if err != nil {
-				log.Println(":::note::: failed to read ", file)
+			params = Parameters{}
+			if err := opts.Bind(&params); err != nil {
+				return failed("failed to bind provided parameters", err)
 			}
-			fmt.Println(file, "\n----------\n")
-			for _, re := range res {
-				locs := re.FindAllIndex(fileData, -1)
-				if locs == nil {
-					continue
-				}
-				for i, loc := range locs {
-					lb := loc[0] - cfg.ContextSize
-					rb := loc[0] + cfg.ContextSize
-					if lb < 0 {
-						lb = 0
-					}
-					if rb > len(fileData) {
-						rb = len(fileData)
-					}
-					context := string(fileData[lb:rb])
-					contextLines := strings.Split(context, "\n")
-					context = ""
-					for _, line := range contextLines {
-						context += fmt.Sprintf("\t%s\n", line)
-					}
-					fmt.Println(i+1, ":\n", context)
-				}
+			if err := checkDryRun(opts, params); err != nil {
+				return withExitCode(exitFailure, err)
 			}
+			params.Verbose = verbose
+		}
+	}
+	rawRoot := cfg.Root
+	cfg.Root = expandPath(cfg.Root)
+	for i, root := range cfg.ExtraRoots {
+		cfg.ExtraRoots[i] = expandPath(root)
+	}
+	resolveLocalRoot(&cfg, src)
+	tracef("root: %s, from root = '%s'", cfg.Root, rawRoot)
+	if relative {
+		setRelativeBase(cfg, src)
+	}
+	if err := setOutputMode(cfg.Output, plain); err != nil {
+		return failed("error in configuration file", err)
+	}
+	if len(locale) > 0 {
+		if err := setOutputLocale(locale); err != nil {
+			return withExitCode(exitFailure, err)
+		}
+	}
+	// Under --dry-run nothing is replayed or recorded.
+	if !dryRun {
+		if err := replayAppendJournal(cfg); err != nil {
+			log.Println(":::note::: failed to replay the append journal:", err)
+		}
+	}
+	if !dryRun && historyEnabled(cfg, commandName(opts)) {
+		recordHistory(cfg.Root, cmdline)
+	}
+	if !dryRun && cfg.UsageStats {
+		command := commandName(opts)
+		exitHook = func(status int) {
+			recordUsage(command, started, status)
 		}
-		os.Exit(0)
 	}
 
-	pd, err := parseDateString(params.Date)
-	if err != nil {
-		log.Fatalln("error parsing date:", err)
+	if pagedCommand(params) {
+		startPager(cfg)
+	}
+
+	if params.HelpCmd {
+		printDateHelp()
+		return nil
 	}
-	wmPath := cfg.Root + pd.String()
-	if strings.Contains(wmPath, "~/") {
-		hd, err := os.UserHomeDir()
+
+	if params.Completion {
+		err = runCompletion(os.Stdout, params.Shell)
 		if err != nil {
-			log.Fatalln("failed to convert '~' to the users home directory:", err)
+			return failed("completion failed", err)
 		}
-		wmPath = strings.Replace(wmPath, "~/", hd+"/", 1)
-		wmPath = strings.ReplaceAll(wmPath, "/", "\\")
+		return nil
 	}
-	wmDir := filepath.Dir(wmPath)
-	err = os.MkdirAll(wmDir, fs.ModeDir)
-	if err != nil {
-		log.Fatalln("failed to create directory for working memory file:", err)
+
+	if params.Meetings {
+		err = runMeetings(cfg, params)
+		if err != nil {
+			return failed("failed to write meetings", err)
+		}
+		return nil
 	}
 
-	if _, err := os.Stat(wmPath); err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			f, err := os.Create(wmPath)
-			if err != nil {
-				log.Fatalln("working memory file not found at '", wmPath, "' and failed to create:", err)
-			}
-			_, err = f.WriteString(fmt.Sprintf(`Working Memory File
-%d/%d/%d
--------------------
+	if params.Export && !params.Bundle {
+		err = runExport(cfg, params)
+		if err != nil {
+			return failed("export failed", err)
+		}
+		return nil
+	}
 
-`, pd.month, pd.day, pd.year))
-			if err != nil {
-				log.Fatalln("working memory file not found at '", wmPath, "'. Created, but failed to write defaults.")
-			}
+	if params.Exists {
+		pd, err := parseDateString(cfg, strings.Join(params.DateWords, " "))
+		if err != nil {
+			return failed("error parsing date", err)
+		}
+		ok, err := entryExists(cfg, pd)
+		if err != nil {
+			return failed("exists failed", err)
+		}
+		if !ok {
+			return exitStatus(1)
+		}
+		return nil
+	}
 
-			err = f.Close()
-			if err != nil {
-				log.Fatalln("failed to close file with error ", err)
+	if params.Path {
+		exists, err := runPath(cfg, params)
+		if err != nil {
+			return failed("path failed", err)
+		}
+		if !exists {
+			return exitStatus(1)
+		}
+		return nil
+	}
+
+	if params.CatCmd {
+		err = runCat(cfg, params)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if params.Info {
+		err = runInfo(cfg, params)
+		if err != nil {
+			return failed("info failed", err)
+		}
+		return nil
+	}
+
+	if params.Archive {
+		err = runArchive(cfg, params)
+		if err != nil {
+			return failed("archive failed", err)
+		}
+		return nil
+	}
+
+	if params.Move {
+		err = runMove(cfg, params)
+		if err != nil {
+			return failed("move failed", err)
+		}
+		return nil
+	}
+
+	if params.Conflicts {
+		left, err := runConflicts(cfg, params)
+		if err != nil {
+			return failed("conflicts failed", err)
+		}
+		if left {
+			return exitStatus(1)
+		}
+		return nil
+	}
+
+	if params.Doctor {
+		if runDoctor(doctorInput{cfg, src, loadErr}) {
+			return exitStatus(1)
+		}
+		return nil
+	}
+
+	if params.Init {
+		err = runInit(cfg, params)
+		if err != nil {
+			return failed("init failed", err)
+		}
+		return nil
+	}
+
+	if needsRootMarker(params) && !params.ForceRoot {
+		err = requireRootMarker(cfg)
+		if err != nil {
+			return withExitCode(exitFailure, err)
+		}
+	}
+
+	if params.Migrate && !params.Config {
+		err = runMigrate(cfg, params)
+		if err != nil {
+			return failed("migrate failed", err)
+		}
+		return nil
+	}
+
+	if params.Append {
+		err = runAppend(cfg, params)
+		if err != nil {
+			return failed("append failed", err)
+		}
+		return nil
+	}
+
+	if params.Clip {
+		err = runClip(cfg, params)
+		if err != nil {
+			return failed("clip failed", err)
+		}
+		return nil
+	}
+
+	if params.Attach {
+		err = runAttach(cfg, params)
+		if err != nil {
+			return failed("attach failed", err)
+		}
+		return nil
+	}
+
+	if params.Attachments {
+		err = runAttachments(cfg, params)
+		if err != nil {
+			return failed("attachments failed", err)
+		}
+		return nil
+	}
+
+	if params.Fill {
+		err = runFill(cfg, params)
+		if err != nil {
+			return failed("fill failed", err)
+		}
+		return nil
+	}
+
+	if params.Consolidate {
+		err = runConsolidate(cfg, params)
+		if err != nil {
+			return failed("consolidate failed", err)
+		}
+		return nil
+	}
+
+	if params.Split {
+		err = runSplit(cfg, params)
+		if err != nil {
+			return failed("split failed", err)
+		}
+		return nil
+	}
+
+	if params.Bundle && params.Export {
+		err = runBundleExport(cfg, cfgFile, params)
+		if err != nil {
+			return failed("bundle export failed", err)
+		}
+		return nil
+	}
+
+	if params.Bundle && params.Import {
+		err = runBundleImport(cfgFile, params)
+		if err != nil {
+			return failed("bundle import failed", err)
+		}
+		return nil
+	}
+
+	if params.Check {
+		check := checkHeaders
+		switch {
+		case params.Encoding:
+			check = checkEncoding
+		case params.Links:
+			check = checkLinks
+		}
+		unresolved, err := check(cfg, params)
+		if err != nil {
+			return failed("check failed", err)
+		}
+		if unresolved {
+			return exitStatus(1)
+		}
+		return nil
+	}
+
+	if params.Usage {
+		err = runUsage(params)
+		if err != nil {
+			return failed("failed to summarize usage", err)
+		}
+		return nil
+	}
+
+	if params.History {
+		err = runHistory(cfg)
+		if err != nil {
+			return failed("history failed", err)
+		}
+		return nil
+	}
+
+	if params.Redo {
+		status, err := runRedo(cfg, params)
+		if err != nil {
+			return failed("redo failed", err)
+		}
+		return exitStatus(status)
+	}
+
+	if params.Config && params.Migrate {
+		err = runConfigMigrate(params)
+		if err != nil {
+			return failed("config migrate failed", err)
+		}
+		return nil
+	}
+
+	if params.Config && params.Set {
+		err = runConfigSet(cfgFile, params)
+		if err != nil {
+			return failed("config set failed", err)
+		}
+		return nil
+	}
+
+	if params.Config && params.Show {
+		runConfigShow(cfg, src)
+		return nil
+	}
+
+	if params.Config && params.ConfigPath {
+		runConfigPath(src)
+		return nil
+	}
+
+	if params.Config {
+		path, err := configEditPath(cfgFile)
+		if err != nil {
+			return failed("the configuration file can't be edited", err)
+		}
+		// the edit is what the command is for, so it always waits
+		cmd := editorCommand(cfg, editTarget{Kind: kindConfig}, path, path)
+		err = startEditor(cmd, true)
+		if err != nil {
+			return failed("editing the configuration file with "+editorName(cfg)+" failed", err)
+		}
+		return nil
+	}
+
+	if params.Coverage {
+		err = runCoverage(cfg, params)
+		if err != nil {
+			return failed("coverage failed", err)
+		}
+		return nil
+	}
+
+	if params.Search {
+		found, err := runSearch(cfg, params)
+		if err != nil {
+			code := exitSearchError
+			var e *exitError
+			if errors.As(err, &e) {
+				code = e.Code
 			}
+			return withExitCode(code, fmt.Errorf("search failed: %w", err))
+		}
+		if !found {
+			return exitStatus(exitNoMatch)
+		}
+		return nil
+	}
+
+	if params.Lint {
+		problems, err := runLint(cfg, params)
+		if err != nil {
+			return failed("lint failed", err)
+		}
+		if problems {
+			return exitStatus(1)
+		}
+		return nil
+	}
 
-		} else {
-			log.Fatalln("failed to verify working memory file exists:", err)
+	if params.List && !params.Holidays && !params.Scratch {
+		err = runList(cfg, params)
+		if err != nil {
+			return failed("list failed", err)
 		}
+		return nil
 	}
 
-	cmd := exec.Command(cfg.Editor, wmPath)
-	err = cmd.Start()
-	if err != nil {
-		log.Fatalln("failed to open working memory file using", cfg.Editor, ":", err)
+	if params.Pick {
+		err = runPick(cfg, params)
+		if err != nil {
+			return failed("pick failed", err)
+		}
+		return nil
+	}
+
+	if params.Scratch {
+		err = runScratch(cfg, params)
+		if err != nil {
+			return failed("scratch failed", err)
+		}
+		return nil
+	}
+	if params.Note {
+		err = runNote(cfg, params)
+		if err != nil {
+			return failed("note failed", err)
+		}
+		return nil
+	}
+
+	if params.Stats && !params.ReviewQueue {
+		err = runStats(cfg, params)
+		if err != nil {
+			return failed("stats failed", err)
+		}
+		return nil
+	}
+
+	if params.ReviewQueue {
+		err = runReviewQueue(cfg, params)
+		if err != nil {
+			return failed("review-queue failed", err)
+		}
+		return nil
+	}
+
+	if params.Holidays && params.Import {
+		err = runHolidaysImport(cfg, cfgFile, params)
+		if err != nil {
+			return failed("holidays import failed", err)
+		}
+		return nil
+	}
+
+	if params.Import && !params.Bundle && !params.Holidays {
+		err = runImport(cfg, params)
+		if err != nil {
+			return failed("import failed", err)
+		}
+		return nil
+	}
+
+	if params.Holidays {
+		err = runHolidaysList(params)
+		if err != nil {
+			return failed("holidays failed", err)
+		}
+		return nil
+	}
+
+	if params.Tags {
+		err = runTags(cfg, params)
+		if err != nil {
+			return failed("tags failed", err)
+		}
+		return nil
+	}
+
+	if params.Unlock {
+		err = runUnlock(cfg, params)
+		if err != nil {
+			return failed("unlock failed", err)
+		}
+		return nil
+	}
+
+	if params.Sync {
+		err = runSync(cfg)
+		if err != nil {
+			return failed("sync failed", err)
+		}
+		return nil
+	}
+
+	if params.Notify {
+		err = runNotify(cfg, params)
+		if err != nil {
+			return failed("notify failed", err)
+		}
+		return nil
+	}
+
+	if params.Meta {
+		err = runMeta(cfg, params)
+		if err != nil {
+			return failed("meta failed", err)
+		}
+		return nil
+	}
+
+	if params.Todo {
+		err = runTodo(cfg, params)
+		if err != nil {
+			return failed("todo failed", err)
+		}
+		return nil
 	}
-	os.Exit(0)
 
+	if params.Due {
+		overdue, err := runDue(cfg, params)
+		if err != nil {
+			return failed("due failed", err)
+		}
+		if overdue {
+			return exitStatus(1)
+		}
+		return nil
+	}
+
+	if params.Redact {
+		err = runRedact(cfg, params)
+		if err != nil {
+			return failed("redact failed", err)
+		}
+		return nil
+	}
+	if params.Index {
+		err = runIndex(cfg, params)
+		if err != nil {
+			return failed("index failed", err)
+		}
+		return nil
+	}
+	if params.Month {
+		err = runMonth(cfg, params)
+		if err != nil {
+			return failed("month failed", err)
+		}
+		return nil
+	}
+
+	if params.Week {
+		err = runWeek(cfg, params)
+		if err != nil {
+			return failed("week failed", err)
+		}
+		return nil
+	}
+	if params.OnThisDay {
+		err = runOnThisDay(cfg, params)
+		if err != nil {
+			return failed("onthisday failed", err)
+		}
+		return nil
+	}
+	if params.RestoreCmd {
+		err = runRestore(cfg, params)
+		if err != nil {
+			return failed("restore failed", err)
+		}
+		return nil
+	}
+
+	if params.Trim {
+		err = runTrim(cfg, params)
+		if err != nil {
+			return failed("trim failed", err)
+		}
+		return nil
+	}
+
+	if params.Links && !params.Check {
+		err = runLinks(cfg, params)
+		if err != nil {
+			return failed("links failed", err)
+		}
+		return nil
+	}
+
+	if params.Summary {
+		err = runSummary(cfg, params)
+		if err != nil {
+			return failed("summary failed", err)
+		}
+		return nil
+	}
+
+	if params.Decisions {
+		err = runDecisions(cfg, params)
+		if err != nil {
+			return failed("decisions failed", err)
+		}
+		return nil
+	}
+
+	if params.Review {
+		err = runReview(cfg, params)
+		if err != nil {
+			return failed("review failed", err)
+		}
+		return nil
+	}
+
+	if params.Unread {
+		err = runUnread(cfg, params)
+		if err != nil {
+			return failed("unread failed", err)
+		}
+		return nil
+	}
+
+	if params.Modified {
+		err = runModified(cfg, params)
+		if err != nil {
+			return failed("failed to list modified entries", err)
+		}
+		return nil
+	}
+
+	if params.LastCmd {
+		out, err := runLast(cfg, params)
+		if err != nil {
+			return err
+		}
+		return exitStatus(openStatus(out, params))
+	}
+
+	out, err := runOpen(cfg, params)
+	if err != nil {
+		return err
+	}
+	return exitStatus(openStatus(out, params))
 }
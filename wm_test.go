@@ -0,0 +1,88 @@
+package wm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDateLayouts(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"2024-03-07", "2024-03-07"},
+		{"20240307", "2024-03-07"},
+		{" 2024-03-07 ", "2024-03-07"},
+		{"3/17/2024", "2024-03-17"},
+		{"3-17-2024", "2024-03-17"},
+		{"3/17/24", "2024-03-17"},
+		{"3-17-24", "2024-03-17"},
+		{"17/3/2024", "2024-03-17"},
+		{"17-3-2024", "2024-03-17"},
+		{"17/3/24", "2024-03-17"},
+		{"17-3-24", "2024-03-17"},
+		{"3/17/98", "1998-03-17"},
+		{"7/7/2024", "2024-07-07"},
+		{"Mar 7 2024", "2024-03-07"},
+		{"Mar 7, 2024", "2024-03-07"},
+		{"Mar 7 24", "2024-03-07"},
+		{"7 Mar 2024", "2024-03-07"},
+		{"7 Mar, 2024", "2024-03-07"},
+		{"7 Mar 24", "2024-03-07"},
+		{"7-Mar-2024", "2024-03-07"},
+		{"7-Mar-24", "2024-03-07"},
+		{"March 7 2024", "2024-03-07"},
+		{"March 7, 2024", "2024-03-07"},
+		{"MARCH 7, 2024", "2024-03-07"},
+		{"7 March 2024", "2024-03-07"},
+		{"7 March, 2024", "2024-03-07"},
+	}
+	for _, order := range []string{"", "mdy", "dmy"} {
+		cfg := Configuration{DateOrder: order}
+		for _, tt := range tests {
+			pd, err := parseDateString(cfg, tt.in)
+			if err != nil || pd.Iso() != tt.want {
+				t.Errorf("date_order %q: parseDateString(%q) = %v, %v, want %s", order, tt.in, pd, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestParseDateAmbiguous(t *testing.T) {
+	tests := []struct {
+		in       string
+		mdy, dmy string
+	}{
+		{"3/4/2024", "2024-03-04", "2024-04-03"},
+		{"3-4-2024", "2024-03-04", "2024-04-03"},
+		{"3/4/24", "2024-03-04", "2024-04-03"},
+		{"12-1-24", "2024-12-01", "2024-01-12"},
+	}
+	for _, tt := range tests {
+		if pd, err := parseDateString(Configuration{}, tt.in); err == nil || !strings.Contains(err.Error(), "could be") {
+			t.Errorf("parseDateString(%q) without date_order = %v, %v, want it refused as ambiguous", tt.in, pd, err)
+		}
+		for order, want := range map[string]string{"mdy": tt.mdy, "dmy": tt.dmy} {
+			pd, m, err := parseDate(Configuration{DateOrder: order}, tt.in)
+			if err != nil || pd.Iso() != want {
+				t.Errorf("date_order %q: parseDate(%q) = %v, %v, want %s", order, tt.in, pd, err, want)
+				continue
+			}
+			if m.Other == nil || m.Other.Iso() == want {
+				t.Errorf("date_order %q: parseDate(%q) doesn't note the other reading: %v", order, tt.in, m.Other)
+			}
+		}
+	}
+}
+
+func TestParseDateRejects(t *testing.T) {
+	for _, in := range []string{"2024-02-30", "13/13/2024", "2024-3-7-1", "20241307", "someday", "3/4"} {
+		if pd, err := parseDateString(Configuration{}, in); err == nil {
+			t.Errorf("parseDateString(%q) = %v, want an error", in, pd)
+		}
+	}
+	for _, order := range []string{"ymd", "us"} {
+		if _, err := dateOrder(Configuration{DateOrder: order}); err == nil {
+			t.Errorf("date_order %q was taken", order)
+		}
+	}
+}